@@ -9,16 +9,22 @@ import (
 	"github.com/turtacn/chasi-sreagent/pkg/framework/action"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/analyzer"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/datacollector"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/embedding"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/engine"
+	kb "github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase/feedback"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase/ingest"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
 	// Import concrete implementations to trigger their init() functions for registration
 	// 导入具体实现以触发其 init() 函数进行注册
 	k8saction "github.com/turtacn/chasi-sreagent/pkg/actions/k8s" // Need to import for RegisterRestartPodAction
@@ -26,9 +32,16 @@ import (
 	_ "github.com/turtacn/chasi-sreagent/pkg/analyzers/k8s"
 	businessdatacollector "github.com/turtacn/chasi-sreagent/pkg/datacollectors/business" // Need to import for RegisterBusinessCollector
 	k8sdatacollector "github.com/turtacn/chasi-sreagent/pkg/datacollectors/k8s"           // Need to import for RegisterK8sCollector
+	localkb "github.com/turtacn/chasi-sreagent/pkg/knowledgebases/local"                  // Need to import for RegisterLocalKnowledgeBase
 	vectorkb "github.com/turtacn/chasi-sreagent/pkg/knowledgebases/vector"                // Need to import for RegisterVectorDBKnowledgeBase
-	deepseek "github.com/turtacn/chasi-sreagent/pkg/llmproviders/deepseek"                // Need to import for RegisterDeepSeekProvider
-	localai "github.com/turtacn/chasi-sreagent/pkg/llmproviders/localai"                  // Need to import for RegisterLocalAIProvider
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/deepseek"
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/localai"
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/ollama"
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/openai"
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/replay"
+	businesstools "github.com/turtacn/chasi-sreagent/pkg/tools/business" // Need to import for RegisterBusinessTools
+	k8stools "github.com/turtacn/chasi-sreagent/pkg/tools/k8s"           // Need to import for RegisterK8sTools
+	kbtools "github.com/turtacn/chasi-sreagent/pkg/tools/kb"             // Need to import for RegisterKnowledgeBaseTools
 
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2" // Using yaml.v2 for config parsing / 使用 yaml.v2 进行配置解析
@@ -39,7 +52,7 @@ import (
 func main() {
 	// 1. Load configuration
 	// 1. 加载配置
-	configPath := flag.String("config", types.DefaultConfigPath, "Path to the configuration file")
+	configPath := flag.String("config", constants.DefaultConfigPath, "Path to the configuration file")
 	flag.Parse()
 
 	cfg, err := loadConfig(*configPath)
@@ -91,59 +104,104 @@ func main() {
 
 	// Initialize LLM Provider
 	// 初始化 LLM 提供商
-	var llmProvider llm.LLM
-	switch cfg.LLM.Provider {
-	case localai.LocalAIProviderInstance.Name(): // Using the registered name constant
-		providerCfg := &types.LLMProviderConfig{
-			URL:    cfg.LLM.LocalAI.URL,
-			Model:  cfg.LLM.LocalAI.Model,
-			APIKey: cfg.LLM.LocalAI.APIKey,
-		}
-		llmProvider, err = localai.NewLocalAIProvider(providerCfg, cfg.LLM.Timeout)
-		if err != nil {
-			logger.Fatal("Failed to initialize LocalAI provider", zap.Error(err))
-		}
-		localai.RegisterLocalAIProvider(llmProvider.(*localai.LocalAIProvider)) // Cast back to concrete type for registration
-	case deepseek.DeepSeekProviderInstance.Name(): // Using the registered name constant
-		providerCfg := &types.LLMProviderConfig{
-			URL:    cfg.LLM.DeepSeek.URL,
-			Model:  cfg.LLM.DeepSeek.Model,
-			APIKey: cfg.LLM.DeepSeek.APIKey,
-		}
-		llmProvider, err = deepseek.NewDeepSeekProvider(providerCfg, cfg.LLM.Timeout)
-		if err != nil {
-			logger.Fatal("Failed to initialize DeepSeek provider", zap.Error(err))
-		}
-		deepseek.RegisterDeepSeekProvider(llmProvider.(*deepseek.DeepSeekProvider)) // Cast back to concrete type for registration
-	// TODO: Add other LLM providers
-	default:
-		logger.Fatal("Unsupported LLM provider configured", zap.String("provider", cfg.LLM.Provider))
+	// The configured provider may be a built-in section (localai, deepseek, openai, ollama) or a named instance under llm.providers.
+	// 配置的提供商可以是内置配置段 (localai, deepseek, openai, ollama)，也可以是 llm.providers 下的命名实例。
+	// Every provider of the fallback chain and of the routing rules is created and registered; with a
+	// fallback chain the chain's providers are wrapped in a composite provider.
+	// 创建并注册降级链和路由规则中的每个提供商; 配置降级链时，链中的提供商会被封装在组合提供商中。
+	llmProvider, err := llm.NewConfiguredLLM(&cfg.LLM)
+	if err != nil {
+		logger.Fatal("Failed to initialize LLM providers", zap.Error(err))
 	}
 	logger.Info("LLM provider initialized and registered", zap.String("provider", llmProvider.Name()))
 
+	// Redaction settings shared by the knowledge base and the saved diagnosis results; the engine
+	// redacts the evidence it sends with the same settings.
+	// 知识库和已保存的诊断结果共用的脱敏设置; 引擎使用相同的设置对其发送的证据脱敏。
+	redactor, err := redact.New(&cfg.Redaction)
+	if err != nil {
+		logger.Fatal("Failed to initialize redaction", zap.Error(err))
+	}
+
 	// Initialize Knowledge Base (Optional)
 	// 初始化知识库 (可选)
 	var knowledgeBase kb.KnowledgeBase
 	if cfg.KnowledgeBase.Enabled {
+		// Both knowledge base providers compute embeddings client-side.
+		// 两种知识库提供商都在客户端计算 embedding。
+		embedder, err := embedding.NewEmbedder(cfg.KnowledgeBase.Embedding, cfg.LLM)
+		if err != nil {
+			logger.Fatal("Failed to initialize embedding provider", zap.Error(err))
+		}
 		switch cfg.KnowledgeBase.Provider {
-		case vectorkb.VectorDBKnowledgeBaseInstance.Name(): // Using the registered name constant
-			// Need to pass embedding provider to KB initialization if embedding is client-side
-			// 如果 embedding 是客户端完成，需要将 embedding 提供商传递给知识库初始化
-			kbCfg := &cfg.KnowledgeBase // Pass sub-config
-			knowledgeBase, err = vectorkb.NewVectorDBKnowledgeBase(kbCfg)
+		case constants.KBProviderVectorDB:
+			vectorKB, err := vectorkb.NewVectorDBKnowledgeBase(&cfg.KnowledgeBase, embedder)
 			if err != nil {
 				logger.Fatal("Failed to initialize Vector DB knowledge base", zap.Error(err))
 			}
-			vectorkb.RegisterVectorDBKnowledgeBase(knowledgeBase.(*vectorkb.VectorDBKnowledgeBase)) // Cast back for registration
+			vectorkb.RegisterVectorDBKnowledgeBase(vectorKB)
+			knowledgeBase = vectorKB
+		case constants.KBProviderLocal:
+			localKB, err := localkb.NewLocalKnowledgeBase(&cfg.KnowledgeBase, embedder)
+			if err != nil {
+				logger.Fatal("Failed to initialize local knowledge base", zap.Error(err))
+			}
+			localkb.RegisterLocalKnowledgeBase(localKB)
+			knowledgeBase = localKB
 		// TODO: Add other KB providers
 		default:
 			logger.Fatal("Unsupported knowledge base provider configured", zap.String("provider", cfg.KnowledgeBase.Provider))
 		}
 		logger.Info("Knowledge base initialized and registered", zap.String("provider", knowledgeBase.Name()))
+
+		// Redact every document the agent stores (ingested and synced runbooks) before it is embedded.
+		// 在计算 embedding 之前对代理存储的每个文档 (导入和同步的 Runbook) 进行脱敏。
+		knowledgeBase = kb.NewRedactingKnowledgeBase(knowledgeBase, redactor)
+
+		// Optionally rerank the top hits of every retrieval with an LLM.
+		// 可选地使用 LLM 对每次检索的靠前命中重新排序。
+		if rerank := cfg.KnowledgeBase.Retrieval.Rerank; rerank.Enabled {
+			rerankProvider := llmProvider
+			if rerank.Provider != "" {
+				provider, found := llm.GetLLMProvider(rerank.Provider)
+				if !found {
+					provider, err = llm.NewLLMProvider(rerank.Provider, &cfg.LLM)
+					if err != nil {
+						logger.Fatal("Failed to initialize reranking LLM provider", zap.String("provider", rerank.Provider), zap.Error(err))
+					}
+					llm.RegisterLLMProvider(provider)
+				}
+				rerankProvider = provider
+			}
+			knowledgeBase = kb.NewRerankingKnowledgeBase(knowledgeBase, kb.NewLLMReranker(rerankProvider, redactor, llm.ChainTimeout(cfg.LLM, rerankProvider)), rerank.TopN)
+			logger.Info("Knowledge base reranking enabled", zap.String("provider", rerankProvider.Name()))
+		}
+
+		// Ingest the configured runbook sources in the background; only changed files are embedded again.
+		// 在后台导入配置的 Runbook 来源; 只有发生变化的文件会被重新计算 embedding。
+		if len(cfg.KnowledgeBase.Ingest.Sources) > 0 {
+			ingester := ingest.NewIngester(knowledgeBase, cfg.KnowledgeBase.Ingest)
+			go runIngestion(ctx, "runbook sources", ingester.Ingest, cfg.KnowledgeBase.Ingest.Interval)
+		}
+		// Keep the runbooks advertised by the business endpoints in sync with the knowledge base.
+		// 使业务终点公布的 Runbook 与知识库保持同步。
+		if cfg.KnowledgeBase.Ingest.BusinessRunbooks.Enabled {
+			syncer := ingest.NewRunbookSyncer(knowledgeBase, businessCollector, cfg.KnowledgeBase.Ingest)
+			go runIngestion(ctx, "business runbooks", syncer.Sync, syncer.Interval())
+		}
 	} else {
 		logger.Info("Knowledge base is disabled")
 	}
 
+	// Register read-only diagnosis tools the LLM may call during agentic diagnosis
+	// 注册智能体诊断期间 LLM 可调用的只读诊断工具
+	k8stools.RegisterK8sTools(k8sCollector)
+	businesstools.RegisterBusinessTools(businessCollector)
+	if knowledgeBase != nil {
+		kbtools.RegisterKnowledgeBaseTools(knowledgeBase)
+	}
+	logger.Info("Diagnosis tools registered", zap.Bool("agenticDiagnosis", cfg.Diagnosis.Agent.Enabled))
+
 	// Initialize Actions
 	// 初始化动作
 	// Some actions might need dependencies (like K8s client). Pass them here.
@@ -234,7 +292,7 @@ func main() {
 						diagnosisCancel()
 						// Continue to next tick / 继续下一个周期
 					} else {
-						logger.Info("Diagnosis run completed", zap.String("rootCause", diagnosisResult.RootCause), zap.Int("suggestions", len(diagnosisResult.Suggestions)), zap.String("traceID", diagnosisCtx.Value(types.ContextKeyTraceID).(string)))
+						logger.Info("Diagnosis run completed", zap.String("rootCause", diagnosisResult.RootCause), zap.Int("suggestions", len(diagnosisResult.Suggestions)), zap.Bool("cached", diagnosisResult.Cached), zap.String("traceID", diagnosisCtx.Value(types.ContextKeyTraceID).(string)))
 
 						// TODO: Output results and suggestions (e.g., log, send notification, expose via API)
 						// TODO: 输出结果和建议 (例如, 记录日志, 发送通知, 通过 API 暴露)
@@ -242,6 +300,16 @@ func main() {
 						// 目前，只记录日志
 						logger.Info("Diagnosis Result:", zap.Any("result", diagnosisResult), zap.String("traceID", diagnosisCtx.Value(types.ContextKeyTraceID).(string)))
 
+						// Keep the result where operators can confirm or correct it into the knowledge base.
+						// 将结果保存在运维人员可将其确认或更正到知识库的位置。
+						if cfg.Diagnosis.ResultsDir != "" {
+							if path, err := feedback.SaveResult(cfg.Diagnosis.ResultsDir, diagnosisResult, redactor); err != nil {
+								logger.Error("Failed to write diagnosis result", zap.Error(err), zap.String("traceID", diagnosisCtx.Value(types.ContextKeyTraceID).(string)))
+							} else {
+								logger.Info("Diagnosis result written for operator feedback", zap.String("path", path), zap.String("traceID", diagnosisCtx.Value(types.ContextKeyTraceID).(string)))
+							}
+						}
+
 						// Plan and potentially execute automated actions
 						// 规划并可能执行自动化动作
 						suggestions, err := sreEngine.SuggestActions(diagnosisCtx, diagnosisResult)
@@ -257,9 +325,9 @@ func main() {
 									execCtx, execCancel := context.WithTimeout(diagnosisCtx, 5*time.Minute) // Timeout for action execution
 									execResult, execErr := sreEngine.ExecuteAction(execCtx, s)
 									if execErr != nil {
-										logger.Error("Automated action execution failed", zap.Error(execErr), zap.String("action", s.Name()), zap.String("traceID", execCtx.Value(types.ContextKeyTraceID).(string)))
+										logger.Error("Automated action execution failed", zap.Error(execErr), zap.String("action", s.Source), zap.String("traceID", execCtx.Value(types.ContextKeyTraceID).(string)))
 									} else {
-										logger.Info("Automated action executed successfully", zap.String("action", s.Name()), zap.String("result", execResult), zap.String("traceID", execCtx.Value(types.ContextKeyTraceID).(string)))
+										logger.Info("Automated action executed successfully", zap.String("action", s.Source), zap.String("result", execResult), zap.String("traceID", execCtx.Value(types.ContextKeyTraceID).(string)))
 									}
 									execCancel()
 								}
@@ -291,6 +359,26 @@ func main() {
 	logger.Info("Agent shutting down")
 }
 
+// runIngestion runs a knowledge base ingestion at startup and then every interval, until the
+// context is done. A zero interval ingests only once.
+// runIngestion 在启动时运行一次知识库导入，之后每隔 interval 运行一次，直到 context 结束。interval 为 0 时只导入一次。
+func runIngestion(ctx context.Context, name string, run func(context.Context) (*ingest.Report, error), interval time.Duration) {
+	logger := log.L().With(zap.String("ingestion", name))
+	for {
+		if _, err := run(ctx); err != nil {
+			logger.Error("Knowledge base ingestion failed", zap.Error(err))
+		}
+		if interval <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
 // loadConfig loads the configuration from the specified path.
 // loadConfig 从指定路径加载配置。
 func loadConfig(configPath string) (*types.Config, error) {
@@ -300,13 +388,13 @@ func loadConfig(configPath string) (*types.Config, error) {
 	if err != nil {
 		// If file not found, try default path or return error
 		// 如果文件未找到，尝试默认路径或返回错误
-		if os.IsNotExist(err) && configPath != types.DefaultConfigPath {
-			log.L().Warn("Config file not found, trying default path", zap.String("path", configPath), zap.String("defaultPath", types.DefaultConfigPath))
-			data, err = os.ReadFile(types.DefaultConfigPath)
+		if os.IsNotExist(err) && configPath != constants.DefaultConfigPath {
+			log.L().Warn("Config file not found, trying default path", zap.String("path", configPath), zap.String("defaultPath", constants.DefaultConfigPath))
+			data, err = os.ReadFile(constants.DefaultConfigPath)
 			if err != nil {
-				return nil, errors.Wrap(errors.ErrorCodeConfigLoadingFailed, "failed to read default config file", err, types.DefaultConfigPath)
+				return nil, errors.Wrap(errors.ErrorCodeConfigLoadingFailed, "failed to read default config file", err, constants.DefaultConfigPath)
 			}
-			configPath = types.DefaultConfigPath // Update path if default is used
+			configPath = constants.DefaultConfigPath // Update path if default is used
 		} else if err != nil {
 			return nil, errors.Wrap(errors.ErrorCodeConfigLoadingFailed, "failed to read config file", err, configPath)
 		}
@@ -322,13 +410,13 @@ func loadConfig(configPath string) (*types.Config, error) {
 	// Set default timeouts if not specified
 	// 如果未指定，设置默认超时时间
 	if cfg.LLM.Timeout == 0 {
-		cfg.LLM.Timeout = types.DefaultLLMTimeout * time.Second
+		cfg.LLM.Timeout = constants.DefaultLLMTimeout * time.Second
 	}
 	if cfg.BusinessSDK.Timeout == 0 {
-		cfg.BusinessSDK.Timeout = types.DefaultBusinessSDKTimeout * time.Second
+		cfg.BusinessSDK.Timeout = constants.DefaultBusinessSDKTimeout * time.Second
 	}
 	if cfg.Analysis.Interval == 0 {
-		cfg.Analysis.Interval = types.DefaultAnalysisInterval * time.Second
+		cfg.Analysis.Interval = constants.DefaultAnalysisInterval * time.Second
 	}
 
 	return &cfg, nil
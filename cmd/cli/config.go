@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/embedding"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+	localkb "github.com/turtacn/chasi-sreagent/pkg/knowledgebases/local"
+	vectorkb "github.com/turtacn/chasi-sreagent/pkg/knowledgebases/vector"
+	"gopkg.in/yaml.v2"
+)
+
+// configPath is the agent configuration used by commands that build agent components locally.
+// configPath 是在本地构建代理组件的命令所使用的代理配置。
+var configPath string
+
+// loadConfig loads the agent configuration from configPath and applies the agent's defaults.
+// loadConfig 从 configPath 加载代理配置并应用代理的默认值。
+func loadConfig() (*types.Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeConfigLoadingFailed, "failed to read config file", err, configPath)
+	}
+	var cfg types.Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeConfigLoadingFailed, "failed to unmarshal config YAML", err, configPath)
+	}
+	if cfg.LLM.Timeout == 0 {
+		cfg.LLM.Timeout = constants.DefaultLLMTimeout * time.Second
+	}
+	return &cfg, nil
+}
+
+// openKnowledgeBase opens the knowledge base provider the configuration selects, with the agent's
+// embedding settings, so that commands read and write the same documents the agent retrieves.
+// Documents stored through it are redacted with the agent's redaction settings.
+// openKnowledgeBase 打开配置所选的知识库提供商，并使用代理的 embedding 设置，使命令读写的正是代理检索的文档。
+// 通过它存储的文档会按代理的脱敏设置进行脱敏。
+func openKnowledgeBase(cfg *types.Config) (knowledgebase.KnowledgeBase, error) {
+	redactor, err := redact.New(&cfg.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	embedder, err := embedding.NewEmbedder(cfg.KnowledgeBase.Embedding, cfg.LLM)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.KnowledgeBase.Provider {
+	case constants.KBProviderVectorDB:
+		vectorKB, err := vectorkb.NewVectorDBKnowledgeBase(&cfg.KnowledgeBase, embedder)
+		if err != nil {
+			return nil, err
+		}
+		return knowledgebase.NewRedactingKnowledgeBase(vectorKB, redactor), nil
+	case constants.KBProviderLocal:
+		localKB, err := localkb.NewLocalKnowledgeBase(&cfg.KnowledgeBase, embedder)
+		if err != nil {
+			return nil, err
+		}
+		return knowledgebase.NewRedactingKnowledgeBase(localKB, redactor), nil
+	default:
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "unsupported knowledge base provider", cfg.KnowledgeBase.Provider)
+	}
+}
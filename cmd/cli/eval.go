@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	k8saction "github.com/turtacn/chasi-sreagent/pkg/actions/k8s"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/action"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/engine"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/eval"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	// Import the LLM providers to trigger their init() functions for registration
+	// 导入 LLM 提供商以触发其 init() 函数进行注册
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/deepseek"
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/localai"
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/ollama"
+	_ "github.com/turtacn/chasi-sreagent/pkg/llmproviders/openai"
+	"github.com/turtacn/chasi-sreagent/pkg/llmproviders/replay"
+)
+
+// Flags of the eval command.
+// eval 命令的参数。
+var (
+	evalScenarios   string
+	evalProvider    string
+	evalOutput      string
+	evalMinAccuracy float64
+	evalTimeout     time.Duration
+)
+
+// evalCmd represents the eval command
+// evalCmd 表示 eval 命令
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluate diagnosis quality on recorded incident scenarios",
+	Long: `Runs recorded incident scenarios (analysis snapshot, expected root cause keywords and
+expected safe actions) through the diagnosis engine against the configured or the chosen LLM
+provider, and reports accuracy, hallucinated resources, unsafe suggestions, latency and token use.
+Each scenario's ID selects its cassette when the provider is a replay provider.`,
+	RunE: runEval,
+}
+
+func init() {
+	evalCmd.Flags().StringVar(&evalScenarios, "scenarios", "", "Scenario file or directory of scenario files (.json, .yaml)")
+	evalCmd.Flags().StringVar(&evalProvider, "provider", "", "LLM provider to evaluate; overrides the configured provider, fallback chain and routing")
+	evalCmd.Flags().StringVar(&evalOutput, "output", "text", "Report format: text or json")
+	evalCmd.Flags().Float64Var(&evalMinAccuracy, "min-accuracy", 0, "Fail if the accuracy (0-1) is below this value")
+	evalCmd.Flags().DurationVar(&evalTimeout, "timeout", 10*time.Minute, "Timeout of each scenario's diagnosis")
+	_ = evalCmd.MarkFlagRequired("scenarios")
+}
+
+// runEval builds an engine without data collectors and analyzers, diagnoses the scenarios and
+// writes the report.
+// runEval 构建一个不含数据采集器和分析器的引擎，诊断各场景并写出报告。
+func runEval(cmd *cobra.Command, args []string) error {
+	if evalOutput != "text" && evalOutput != "json" {
+		return errors.New(errors.ErrorCodeInvalidInput, "unsupported output format", evalOutput)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	scenarios, err := eval.LoadScenarios(evalScenarios)
+	if err != nil {
+		return err
+	}
+
+	// Every scenario is diagnosed by the LLM: no cache, no daily budget. With --provider the chosen
+	// provider answers every scenario.
+	// 每个场景都由 LLM 诊断: 不使用缓存，不受每日预算限制。指定 --provider 时由所选提供商应答所有场景。
+	cfg.Diagnosis.Cache.Enabled = false
+	cfg.LLM.Budget = types.LLMBudgetConfig{}
+	if evalProvider != "" {
+		cfg.LLM.Provider = evalProvider
+		cfg.LLM.Fallback.Providers = nil
+		cfg.LLM.Routing.Rules = nil
+	}
+
+	llmProvider, err := llm.NewConfiguredLLM(&cfg.LLM)
+	if err != nil {
+		return err
+	}
+
+	// Actions plan from the analyzer findings of the snapshot, so automated suggestions are scored too.
+	// 动作根据快照中的分析器发现进行规划，因此自动化建议也会被打分。
+	restartPodAction, err := k8saction.NewRestartPodAction()
+	if err != nil {
+		return err
+	}
+	sreEngine, err := engine.NewSREAgentEngine(cfg, nil, nil, nil, llmProvider, []action.Action{restartPodAction})
+	if err != nil {
+		return err
+	}
+
+	runner := &eval.Runner{
+		Engine:          sreEngine,
+		Provider:        llmProvider.Name(),
+		ScenarioContext: replay.WithScenario,
+		Timeout:         evalTimeout,
+	}
+	report := runner.Run(context.Background(), scenarios)
+
+	if evalOutput == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Summary.Accuracy < evalMinAccuracy {
+		return errors.New(errors.ErrorCodeInvalidInput, "diagnosis accuracy below the minimum", fmt.Sprintf("%.2f < %.2f", report.Summary.Accuracy, evalMinAccuracy))
+	}
+	return nil
+}
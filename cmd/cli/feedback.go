@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase/feedback"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+)
+
+// Flags of the feedback command.
+// feedback 命令的参数。
+var feedbackInput types.DiagnosisFeedback
+
+// feedbackCmd represents the feedback command
+// feedbackCmd 表示 feedback 命令
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback <analysis-result-id | result-file>",
+	Short: "Confirm, correct or reject a diagnosis and teach it to the knowledge base",
+	Long: `Records an operator's verdict on a diagnosis result, given as the path of a result file or as
+the analysis result ID of a result the agent wrote to diagnosis.resultsDir.
+
+A confirmed or corrected diagnosis is stored in the configured knowledge base as an incident
+document with its root cause, fix and evidence, scoped to the vcluster and business service of its
+issues, so the next occurrence retrieves it. A diagnosis whose issues span several vclusters or
+business services needs --vcluster or --service. Feedback given again replaces the document; a
+rejected diagnosis is removed from the knowledge base.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFeedback,
+}
+
+func init() {
+	feedbackCmd.Flags().StringVar(&feedbackInput.Verdict, "verdict", "", "Verdict on the diagnosis: confirmed, corrected or rejected")
+	feedbackCmd.Flags().StringVar(&feedbackInput.RootCause, "root-cause", "", "Actual root cause (default: the diagnosed root cause)")
+	feedbackCmd.Flags().StringVar(&feedbackInput.Fix, "fix", "", "What resolved the incident (default: the suggested remediations)")
+	feedbackCmd.Flags().StringVar(&feedbackInput.Evidence, "evidence", "", "Evidence summary (default: a summary of the diagnosed issues)")
+	feedbackCmd.Flags().StringVar(&feedbackInput.VCluster, "vcluster", "", "vcluster the incident applies to (default: derived from the issues; required when they span several vclusters)")
+	feedbackCmd.Flags().StringVar(&feedbackInput.BusinessService, "service", "", "Business service the incident applies to (default: derived from the issues; required when they span several services)")
+	feedbackCmd.Flags().StringVar(&feedbackInput.Operator, "operator", os.Getenv("USER"), "Operator giving the feedback")
+	_ = feedbackCmd.MarkFlagRequired("verdict")
+}
+
+// runFeedback loads the diagnosis result and applies the feedback to the knowledge base.
+// runFeedback 加载诊断结果并将反馈应用到知识库。
+func runFeedback(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.KnowledgeBase.Enabled {
+		return errors.New(errors.ErrorCodeInvalidInput, "the knowledge base is disabled", configPath)
+	}
+	result, err := feedback.LoadResult(cfg.Diagnosis.ResultsDir, args[0])
+	if err != nil {
+		return err
+	}
+	fb := feedbackInput
+	fb.Timestamp = time.Now()
+	if err := feedback.Validate(result, &fb); err != nil {
+		return err
+	}
+
+	redactor, err := redact.New(&cfg.Redaction)
+	if err != nil {
+		return err
+	}
+	kb, err := openKnowledgeBase(cfg)
+	if err != nil {
+		return err
+	}
+	doc, err := feedback.Record(context.Background(), kb, redactor, result, &fb)
+	if err != nil {
+		return err
+	}
+	if fb.Verdict == constants.FeedbackVerdictRejected {
+		fmt.Printf("Removed diagnosis %s from the knowledge base\n", result.AnalysisResultID)
+		return nil
+	}
+	fmt.Printf("Stored diagnosis %s as %s (vcluster %q, business service %q)\n", result.AnalysisResultID, doc.ID,
+		doc.Metadata[knowledgebase.MetadataVCluster], doc.Metadata[knowledgebase.MetadataBusinessService])
+	return nil
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase/ingest"
+)
+
+// kbSnippetChars is the number of content characters shown per document unless --full is given.
+// kbSnippetChars 是未指定 --full 时每个文档显示的内容字符数。
+const kbSnippetChars = 300
+
+// Flags of the kb commands.
+// kb 命令的参数。
+var (
+	kbOutput string
+
+	kbSearchK        int
+	kbSearchMode     string
+	kbSearchMinScore float64
+	kbSearchVCluster string
+	kbSearchService  string
+	kbSearchKind     string
+	kbSearchFilter   map[string]string
+	kbSearchFull     bool
+
+	kbSourcesOrigin string
+
+	kbDeleteIDs     []string
+	kbDeleteSources []string
+	kbDeleteDryRun  bool
+
+	kbImportReplace bool
+)
+
+// kbCmd represents the kb command
+// kbCmd 表示 kb 命令
+var kbCmd = &cobra.Command{
+	Use:   "kb",
+	Short: "Inspect and manage the knowledge base",
+	Long: `Inspects and manages the knowledge base selected by knowledgeBase.provider in the agent
+configuration: the same documents the agent retrieves during diagnoses.`,
+}
+
+// kbIngestCmd represents the kb ingest command
+// kbIngestCmd 表示 kb ingest 命令
+var kbIngestCmd = &cobra.Command{
+	Use:   "ingest",
+	Short: "Ingest the configured runbook sources once",
+	Long: `Runs one ingestion of knowledgeBase.ingest.sources, sharing the agent's state file: only new
+or changed files are embedded again, and the chunks of changed or removed files are deleted.`,
+	Args: cobra.NoArgs,
+	RunE: runKBIngest,
+}
+
+// kbSearchCmd represents the kb search command
+// kbSearchCmd 表示 kb search 命令
+var kbSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the knowledge base and show scores and metadata",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runKBSearch,
+}
+
+// kbSourcesCmd represents the kb sources command
+// kbSourcesCmd 表示 kb sources 命令
+var kbSourcesCmd = &cobra.Command{
+	Use:   "sources",
+	Short: "List the sources of the stored documents",
+	Args:  cobra.NoArgs,
+	RunE:  runKBSources,
+}
+
+// kbDeleteCmd represents the kb delete command
+// kbDeleteCmd 表示 kb delete 命令
+var kbDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete documents by ID or source",
+	Long: `Deletes the documents with the given IDs and the documents of the given sources; a source
+also selects the sources below it, e.g. business-runbook/orders selects every runbook of the orders
+service. Ingested files are only stored again once they change, or after the ingest state file is
+removed.`,
+	Args: cobra.NoArgs,
+	RunE: runKBDelete,
+}
+
+// kbExportCmd represents the kb export command
+// kbExportCmd 表示 kb export 命令
+var kbExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export every document of the knowledge base as JSON",
+	Long: `Writes every document of the knowledge base, without vectors, to the file or to standard
+output. The export can be imported into any provider, which embeds the documents with its own model.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runKBExport,
+}
+
+// kbImportCmd represents the kb import command
+// kbImportCmd 表示 kb import 命令
+var kbImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import an export into the knowledge base",
+	Long: `Stores the documents of an export, replacing documents with the same ID. With --replace the
+documents the export does not have are deleted; an export without documents is then refused.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runKBImport,
+}
+
+// kbStatsCmd represents the kb stats command
+// kbStatsCmd 表示 kb stats 命令
+var kbStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the documents of the knowledge base",
+	Args:  cobra.NoArgs,
+	RunE:  runKBStats,
+}
+
+func init() {
+	kbCmd.PersistentFlags().StringVar(&kbOutput, "output", "text", "Output format: text or json")
+
+	kbSearchCmd.Flags().IntVar(&kbSearchK, "k", constants.DefaultKBHits, "Number of hits")
+	kbSearchCmd.Flags().StringVar(&kbSearchMode, "mode", "", "Retrieval mode: hybrid, vector or keyword (default: the configured mode)")
+	kbSearchCmd.Flags().Float64Var(&kbSearchMinScore, "min-score", 0, "Lowest vector similarity of hits without a strong keyword match")
+	kbSearchCmd.Flags().StringVar(&kbSearchVCluster, "vcluster", "", "Only documents of this vcluster and unscoped documents")
+	kbSearchCmd.Flags().StringVar(&kbSearchService, "service", "", "Only documents of this business service and unscoped documents")
+	kbSearchCmd.Flags().StringVar(&kbSearchKind, "kind", "", "Only documents of this resource kind and unscoped documents")
+	kbSearchCmd.Flags().StringToStringVar(&kbSearchFilter, "filter", nil, "Metadata the documents must have, e.g. tags=runbook")
+	kbSearchCmd.Flags().BoolVar(&kbSearchFull, "full", false, "Show the whole content of each hit")
+
+	kbSourcesCmd.Flags().StringVar(&kbSourcesOrigin, "origin", "", "Only sources of this origin: ingested, feedback or business-runbook")
+
+	kbDeleteCmd.Flags().StringSliceVar(&kbDeleteIDs, "id", nil, "ID of a document to delete (repeatable)")
+	kbDeleteCmd.Flags().StringSliceVar(&kbDeleteSources, "source", nil, "Source whose documents are deleted (repeatable)")
+	kbDeleteCmd.Flags().BoolVar(&kbDeleteDryRun, "dry-run", false, "Only show what would be deleted")
+
+	kbImportCmd.Flags().BoolVar(&kbImportReplace, "replace", false, "Delete the documents the export does not have")
+
+	kbCmd.AddCommand(kbIngestCmd, kbSearchCmd, kbSourcesCmd, kbDeleteCmd, kbExportCmd, kbImportCmd, kbStatsCmd)
+}
+
+// openConfiguredKnowledgeBase loads the configuration and opens its knowledge base.
+// openConfiguredKnowledgeBase 加载配置并打开其知识库。
+func openConfiguredKnowledgeBase() (*types.Config, knowledgebase.KnowledgeBase, error) {
+	if kbOutput != "text" && kbOutput != "json" {
+		return nil, nil, errors.New(errors.ErrorCodeInvalidInput, "unsupported output format", kbOutput)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cfg.KnowledgeBase.Enabled {
+		return nil, nil, errors.New(errors.ErrorCodeInvalidInput, "the knowledge base is disabled", configPath)
+	}
+	kb, err := openKnowledgeBase(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, kb, nil
+}
+
+// listDocuments lists every document of a knowledge base.
+// listDocuments 列出知识库的所有文档。
+func listDocuments(ctx context.Context, kb knowledgebase.KnowledgeBase) ([]types.KnowledgeDocument, error) {
+	lister, err := knowledgebase.Lister(kb)
+	if err != nil {
+		return nil, err
+	}
+	return lister.List(ctx)
+}
+
+// runKBIngest runs one ingestion of the configured sources.
+// runKBIngest 对配置的来源运行一次导入。
+func runKBIngest(cmd *cobra.Command, args []string) error {
+	cfg, kb, err := openConfiguredKnowledgeBase()
+	if err != nil {
+		return err
+	}
+	if len(cfg.KnowledgeBase.Ingest.Sources) == 0 {
+		return errors.New(errors.ErrorCodeInvalidInput, "no ingestion sources configured", configPath)
+	}
+	report, err := ingest.NewIngester(kb, cfg.KnowledgeBase.Ingest).Ingest(context.Background())
+	if report != nil {
+		if kbOutput == "json" {
+			if writeErr := writeJSON(os.Stdout, report); writeErr != nil {
+				return writeErr
+			}
+		} else {
+			fmt.Printf("Files: %d (changed %d, unchanged %d, removed %d)\n", report.Files, report.Changed, report.Unchanged, report.Removed)
+			fmt.Printf("Chunks stored: %d, deleted: %d\n", report.Chunks, report.Deleted)
+			for _, e := range report.Errors {
+				fmt.Printf("Error: %s\n", e)
+			}
+		}
+	}
+	return err
+}
+
+// runKBSearch searches the knowledge base like the agent does and shows the hits with their scores
+// and metadata.
+// runKBSearch 像代理一样检索知识库，并显示命中及其分数和元数据。
+func runKBSearch(cmd *cobra.Command, args []string) error {
+	_, kb, err := openConfiguredKnowledgeBase()
+	if err != nil {
+		return err
+	}
+	options := map[string]interface{}{
+		knowledgebase.OptionK:               kbSearchK,
+		knowledgebase.OptionFilter:          kbSearchFilter,
+		knowledgebase.OptionVCluster:        kbSearchVCluster,
+		knowledgebase.OptionBusinessService: kbSearchService,
+		knowledgebase.OptionResourceKind:    kbSearchKind,
+	}
+	if kbSearchMode != "" {
+		options[knowledgebase.OptionMode] = kbSearchMode
+	}
+	if kbSearchMinScore > 0 {
+		options[knowledgebase.OptionMinScore] = kbSearchMinScore
+	}
+	query := strings.Join(args, " ")
+	hits, err := kb.Retrieve(context.Background(), query, options)
+	if err != nil {
+		return err
+	}
+	if kbOutput == "json" {
+		return writeJSON(os.Stdout, hits)
+	}
+
+	if len(hits) == 0 {
+		fmt.Println("No hits.")
+		return nil
+	}
+	for i, hit := range hits {
+		fmt.Printf("%d. score %.4f", i+1, hit.Score)
+		if hit.KeywordScore != 0 {
+			fmt.Printf("  keyword %.4f", hit.KeywordScore)
+		}
+		if hit.FusionScore != 0 {
+			fmt.Printf("  fusion %.4f", hit.FusionScore)
+		}
+		if hit.RerankScore != 0 {
+			fmt.Printf("  rerank %.2f", hit.RerankScore)
+		}
+		fmt.Printf("\n   id:       %s\n   source:   %s\n", hit.ID, hit.Source)
+		if metadata := formatMetadata(hit.Metadata); metadata != "" {
+			fmt.Printf("   metadata: %s\n", metadata)
+		}
+		fmt.Println(indent(snippet(hit.Content, kbSearchFull), "   | "))
+	}
+	return nil
+}
+
+// runKBSources lists the sources of the stored documents.
+// runKBSources 列出已存储文档的来源。
+func runKBSources(cmd *cobra.Command, args []string) error {
+	_, kb, err := openConfiguredKnowledgeBase()
+	if err != nil {
+		return err
+	}
+	docs, err := listDocuments(context.Background(), kb)
+	if err != nil {
+		return err
+	}
+	summaries := knowledgebase.SummarizeSources(docs)
+	if kbSourcesOrigin != "" {
+		filtered := summaries[:0]
+		for _, summary := range summaries {
+			if summary.Origin == kbSourcesOrigin {
+				filtered = append(filtered, summary)
+			}
+		}
+		summaries = filtered
+	}
+	if kbOutput == "json" {
+		return writeJSON(os.Stdout, summaries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tORIGIN\tDOCUMENTS\tSCOPE")
+	for _, summary := range summaries {
+		scope := formatMetadata(summary.Scope)
+		if scope == "" {
+			scope = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", summary.Source, summary.Origin, summary.Documents, scope)
+	}
+	return w.Flush()
+}
+
+// runKBDelete deletes the documents selected by ID or source.
+// runKBDelete 删除按 ID 或来源选定的文档。
+func runKBDelete(cmd *cobra.Command, args []string) error {
+	if len(kbDeleteIDs) == 0 && len(kbDeleteSources) == 0 {
+		return errors.New(errors.ErrorCodeInvalidInput, "nothing to delete", "give --id or --source")
+	}
+	_, kb, err := openConfiguredKnowledgeBase()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	// Sources are resolved to IDs from the listing; IDs are deleted as given, since unknown IDs are ignored.
+	// 来源通过列表解析为 ID; ID 按给定值删除，因为未知的 ID 会被忽略。
+	selected := map[string]bool{}
+	for _, id := range kbDeleteIDs {
+		selected[id] = true
+	}
+	if len(kbDeleteSources) > 0 {
+		docs, err := listDocuments(ctx, kb)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			for _, source := range kbDeleteSources {
+				if knowledgebase.MatchesSource(doc.Source, source) {
+					selected[doc.ID] = true
+				}
+			}
+		}
+	}
+	ids := make([]string, 0, len(selected))
+	for id := range selected {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if !kbDeleteDryRun && len(ids) > 0 {
+		if err := kb.Delete(ctx, ids); err != nil {
+			return err
+		}
+	}
+	if kbOutput == "json" {
+		return writeJSON(os.Stdout, map[string]interface{}{"ids": ids, "dryRun": kbDeleteDryRun})
+	}
+	verb := "Deleted"
+	if kbDeleteDryRun {
+		verb = "Would delete"
+	}
+	for _, id := range ids {
+		fmt.Printf("%s %s\n", verb, id)
+	}
+	fmt.Printf("%s %d documents\n", verb, len(ids))
+	return nil
+}
+
+// runKBExport writes every document of the knowledge base to a file or to standard output.
+// runKBExport 将知识库的所有文档写入文件或标准输出。
+func runKBExport(cmd *cobra.Command, args []string) error {
+	_, kb, err := openConfiguredKnowledgeBase()
+	if err != nil {
+		return err
+	}
+	export, err := knowledgebase.NewExport(context.Background(), kb)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "-" {
+		return export.WriteJSON(os.Stdout)
+	}
+	file, err := os.Create(args[0])
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeUnknown, "failed to create export file", err, args[0])
+	}
+	if err := export.WriteJSON(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrap(errors.ErrorCodeUnknown, "failed to write export file", err, args[0])
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d documents to %s\n", len(export.Documents), args[0])
+	return nil
+}
+
+// runKBImport stores the documents of an export in the knowledge base.
+// runKBImport 将导出中的文档存储到知识库。
+func runKBImport(cmd *cobra.Command, args []string) error {
+	_, kb, err := openConfiguredKnowledgeBase()
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeNotFound, "failed to open export file", err, args[0])
+	}
+	defer file.Close()
+	export, err := knowledgebase.ReadExport(file)
+	if err != nil {
+		return err
+	}
+	report, err := knowledgebase.Import(context.Background(), kb, export, kbImportReplace)
+	if report != nil {
+		if kbOutput == "json" {
+			if writeErr := writeJSON(os.Stdout, report); writeErr != nil {
+				return writeErr
+			}
+		} else {
+			fmt.Printf("Imported %d documents from %s (exported from %s), deleted %d\n", report.Stored, args[0], export.Provider, report.Deleted)
+		}
+	}
+	return err
+}
+
+// runKBStats summarizes the documents of the knowledge base.
+// runKBStats 汇总知识库的文档。
+func runKBStats(cmd *cobra.Command, args []string) error {
+	_, kb, err := openConfiguredKnowledgeBase()
+	if err != nil {
+		return err
+	}
+	docs, err := listDocuments(context.Background(), kb)
+	if err != nil {
+		return err
+	}
+	stats := knowledgebase.ComputeStats(kb.Name(), docs)
+	if kbOutput == "json" {
+		return writeJSON(os.Stdout, stats)
+	}
+
+	fmt.Printf("Provider:      %s\n", stats.Provider)
+	fmt.Printf("Documents:     %d\n", stats.Documents)
+	fmt.Printf("Sources:       %d\n", stats.Sources)
+	fmt.Printf("Content chars: %d\n", stats.ContentChars)
+	for _, group := range []struct {
+		title  string
+		counts map[string]int
+	}{
+		{"Origin", stats.ByOrigin},
+		{"vcluster", stats.ByVCluster},
+		{"Business service", stats.ByBusinessService},
+		{"Resource kind", stats.ByResourceKind},
+		{"Tag", stats.ByTag},
+	} {
+		if len(group.counts) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s:\n", group.title)
+		keys := make([]string, 0, len(group.counts))
+		for key := range group.counts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, key := range keys {
+			name := key
+			if name == "" {
+				name = "(unscoped)"
+			}
+			fmt.Fprintf(w, "  %s\t%d\n", name, group.counts[key])
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSON writes a value as indented JSON.
+// writeJSON 以缩进 JSON 形式写出一个值。
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return errors.Wrap(errors.ErrorCodeUnknown, "failed to write JSON output", err, "")
+	}
+	return nil
+}
+
+// formatMetadata formats metadata as sorted key=value pairs.
+// formatMetadata 将元数据格式化为排序后的 key=value 对。
+func formatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		if value != "" {
+			pairs = append(pairs, key+"="+value)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// snippet returns the content, shortened to kbSnippetChars unless full is set.
+// snippet 返回内容; 未设置 full 时截断为 kbSnippetChars 个字符。
+func snippet(content string, full bool) string {
+	content = strings.TrimSpace(content)
+	if runes := []rune(content); !full && len(runes) > kbSnippetChars {
+		return string(runes[:kbSnippetChars]) + "…"
+	}
+	return content
+}
+
+// indent prefixes every line of the text.
+// indent 为文本的每一行添加前缀。
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
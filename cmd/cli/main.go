@@ -4,7 +4,8 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/spf13/cobra"                           // Using cobra for CLI
+	"github.com/spf13/cobra" // Using cobra for CLI
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log" // Using common logging
 	"go.uber.org/zap"
 )
@@ -30,8 +31,12 @@ and managing the chasi-sreagent AI SRE agent.`,
 
 	// Add subcommands
 	// 添加子命令
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", constants.DefaultConfigPath, "Path to the agent configuration file")
 	rootCmd.AddCommand(analyzeCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(evalCmd)
+	rootCmd.AddCommand(feedbackCmd)
+	rootCmd.AddCommand(kbCmd)
 	// TODO: Add more commands: diagnose, suggest, execute, list-analyzers, list-actions, config, etc.
 	// TODO: 添加更多命令: diagnose, suggest, execute, list-analyzers, list-actions, config 等。
 
@@ -5,14 +5,13 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/enum"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/action"
 	"go.uber.org/zap"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	// Add import for the K8s data collector to get clients or data
 	// 添加 K8s 数据采集器的导入，以获取客户端或数据
 	// k8sdatacollector "github.com/turtacn/chasi-sreagent/pkg/datacollectors/k8s" // Assuming collector instance is accessible
@@ -69,85 +68,64 @@ func (a *RestartPodAction) Type() enum.ActionType {
 
 // Plan determines if this action is relevant for the diagnosis result.
 // Plan 根据诊断结果确定此动作是否相关。
-// It might look for issues related to crashing pods or LLM suggestions mentioning pod restart.
-// 它可能会查找与崩溃 Pod 相关的问题，或 LLM 建议中提到 Pod 重启的建议。
+// It only acts on crashing pods reported by the Kubernetes pod analyzer. The LLM response is never
+// used to decide on a restart or to pick its target, because it may echo attacker-controlled logs.
+// 它只处理 Kubernetes Pod 分析器报告的崩溃 Pod。LLM 响应绝不会被用于决定重启或选择重启目标，
+// 因为它可能复述了受攻击者控制的日志。
 func (a *RestartPodAction) Plan(ctx context.Context, diagnosis *types.DiagnosisResult) (bool, types.RemediationSuggestion, error) {
 	logger := log.LWithContext(ctx).With(zap.String("action", a.Name()), zap.String("diagnosisID", diagnosis.AnalysisResultID))
 	logger.Debug("Planning action")
 
-	// Example Plan Logic:
-	// Check if any issue in the analysis result is a PodCrashLoopBackOff
-	// 检查分析结果中是否有任何问题是 PodCrashLoopBackOff
-	// Check if the LLM suggestion includes restarting a pod
-	// 检查 LLM 建议是否包含重启 Pod
-
-	isRelevant := false
-	var targetResource *types.IssueResource
-
-	// Check analysis issues
-	// 检查分析问题
-	for _, issue := range diagnosis.Issues {
-		if issue.Name == types.AnalyzerKubernetesPod && issue.Resource != nil && issue.Resource.Type == "Pod" {
-			// More specific checks needed, e.g., look into issue.Message or issue.Context
-			// 需要更具体的检查，例如，查看 issue.Message 或 issue.Context
-			if strings.Contains(issue.Message, "CrashLoopBackOff") {
-				isRelevant = true
-				targetResource = issue.Resource
-				logger.Debug("Action relevant based on issue", zap.String("issueID", issue.ID))
-				break // Found a relevant issue, plan the action
-			}
+	// Check analysis issues for a PodCrashLoopBackOff found by the pod analyzer
+	// 检查分析问题中是否有由 Pod 分析器发现的 PodCrashLoopBackOff
+	var finding *types.Issue
+	for i, issue := range diagnosis.Issues {
+		if !reportedBy(issue, constants.AnalyzerKubernetesPod) || issue.Resource == nil || issue.Resource.Type != "Pod" {
+			continue
+		}
+		if issue.Name == "PodCrashLoopBackOff" || strings.Contains(issue.Message, "CrashLoopBackOff") {
+			finding = &diagnosis.Issues[i]
+			logger.Debug("Action relevant based on issue", zap.String("issueID", issue.ID))
+			break // Found a relevant issue, plan the action
 		}
 	}
 
-	// Also check LLM suggestions if they exist (as they might suggest actions not directly tied to specific issues)
-	// 如果存在 LLM 建议，也检查它们 (因为它们可能建议不直接与特定问题关联的动作)
-	if !isRelevant && diagnosis.LLMInteraction != nil && diagnosis.LLMInteraction.Response != "" {
-		// Perform basic text search in LLM response or parsed suggestions
-		// 在 LLM 响应或解析出的建议中执行基本文本搜索
-		if strings.Contains(diagnosis.LLMInteraction.Response, "restart the pod") || strings.Contains(diagnosis.RootCause, "pod crashing") { // Very basic check
-			isRelevant = true
-			// If LLM suggests it, we need to identify WHICH pod. This is hard without structure.
-			// If LLM suggests it, assume the target resource is one of the resources in the analysis issues.
-			// 如果 LLM 建议，我们需要识别是哪个 Pod。如果没有结构，这很难。
-			// 如果 LLM 建议，假设目标资源是分析问题中的某个资源。
-			// For this stub, let's just use the targetResource found from issues, or pick the first Pod issue's resource.
-			// 对于这个占位符，我们暂时使用从问题中找到的 targetResource，或者选择第一个 Pod 问题的资源。
-			if targetResource == nil && len(diagnosis.Issues) > 0 {
-				for _, issue := range diagnosis.Issues {
-					if issue.Resource != nil && issue.Resource.Type == "Pod" {
-						targetResource = issue.Resource
-						break
-					}
-				}
-			}
-			logger.Debug("Action relevant based on LLM suggestion")
-		}
+	if finding == nil {
+		logger.Debug("Action not relevant for this diagnosis")
+		return false, types.RemediationSuggestion{}, nil
 	}
 
-	if isRelevant && targetResource != nil {
-		// Action is relevant, prepare the suggestion
-		// 动作相关，准备建议
-		suggestion := types.RemediationSuggestion{
-			IssueID:     diagnosis.AnalysisResultID, // Or the specific issue ID it plans for / 或其规划的具体问题 ID
-			Description: fmt.Sprintf("Restart Pod '%s' in namespace '%s' (%s vcluster) by deleting it.", targetResource.Name, targetResource.Namespace, targetResource.VCluster),
-			ActionType:  a.Type(),
-			Command:     fmt.Sprintf("kubectl delete pod %s -n %s", targetResource.Name, targetResource.Namespace), // Example CLI command
-			Payload: map[string]interface{}{ // Payload for automated execution / 自动化执行的载荷
-				"resourceType": targetResource.Type,
-				"resourceName": targetResource.Name,
-				"namespace":    targetResource.Namespace,
-				"vcluster":     targetResource.VCluster,
-				"resourceUID":  targetResource.UID, // Use UID for safer identification
-			},
-			Confidence: 0.7,      // Confidence in the suggestion / 对建议的置信度
-			Source:     a.Name(), // The action that planned this / 规划此动作的动作
-		}
-		logger.Debug("Action planned successfully")
-		return true, suggestion, nil
+	// Action is relevant, prepare the suggestion
+	// 动作相关，准备建议
+	targetResource := finding.Resource
+	suggestion := types.RemediationSuggestion{
+		IssueID:     finding.ID, // The analyzer finding this action acts on / 此动作所针对的分析器发现
+		Description: fmt.Sprintf("Restart Pod '%s' in namespace '%s' (%s vcluster) by deleting it.", targetResource.Name, targetResource.Namespace, targetResource.VCluster),
+		ActionType:  a.Type(),
+		Command:     fmt.Sprintf("kubectl delete pod %s -n %s", targetResource.Name, targetResource.Namespace), // Example CLI command
+		Payload: map[string]interface{}{ // Payload for automated execution / 自动化执行的载荷
+			"resourceType": targetResource.Type,
+			"resourceName": targetResource.Name,
+			"namespace":    targetResource.Namespace,
+			"vcluster":     targetResource.VCluster,
+			"resourceUID":  targetResource.UID, // Use UID for safer identification
+		},
+		Confidence: 0.7,      // Confidence in the suggestion / 对建议的置信度
+		Source:     a.Name(), // The action that planned this / 规划此动作的动作
 	}
+	logger.Debug("Action planned successfully")
+	return true, suggestion, nil
+}
 
-	logger.Debug("Action not relevant for this diagnosis")
-	return false, types.RemediationSuggestion{}, nil
+// reportedBy reports whether the issue was found by the named analyzer.
+// reportedBy 报告问题是否由指定的分析器发现。
+func reportedBy(issue types.Issue, analyzer string) bool {
+	for _, name := range issue.Analyzers {
+		if name == analyzer {
+			return true
+		}
+	}
+	return false
 }
 
 // Execute performs the action (deleting the Pod).
@@ -209,7 +187,7 @@ func (a *RestartPodAction) Execute(ctx context.Context, suggestion types.Remedia
 
 	// Placeholder: Use a dummy client or mock
 	// 占位符: 使用虚拟客户端或 mock
-	var client kubernetes.Interface // Need to initialize this appropriately
+	// var client kubernetes.Interface // Need to initialize this appropriately
 
 	logger.Info("Deleting Pod to trigger restart",
 		zap.String("vcluster", vclusterName),
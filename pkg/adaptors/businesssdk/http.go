@@ -0,0 +1,381 @@
+package businesssdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+)
+
+// HTTPClient is a BusinessAdaptorService that talks to a business system exposing the SDK as a
+// JSON API over HTTP. Paths are relative to the endpoint URL:
+// HTTPClient 是一个 BusinessAdaptorService，与以 HTTP 上的 JSON API 暴露 SDK 的业务系统通信。路径相对于终点 URL:
+//
+//	GET  /status                 -> BusinessStatus
+//	GET  /logs                   -> {"entries": [LogEntry...], "nextPageToken": "..."}
+//	GET  /events                 -> {"events": [BusinessEvent...]}
+//	GET  /config                 -> BusinessConfig
+//	GET  /runbooks               -> {"runbooks": [Runbook...]}
+//	POST /runbooks/{id}/execute  {"parameters": {...}} -> RunbookExecutionResult
+//
+// Query options are sent as URL query parameters: "timeRange" becomes "since" (RFC 3339), lists
+// are repeated parameters, and other values are sent as text. Logs are read page by page with
+// "pageSize" and "pageToken", newest first, up to the "limit" option. A non-2xx status is returned
+// as a wrapped *APIError.
+// 查询选项以 URL 查询参数发送: "timeRange" 转换为 "since" (RFC 3339)，列表为重复参数，其他值以文本发送。
+// 日志通过 "pageSize" 和 "pageToken" 按页读取 (最新的在前)，直到 "limit" 选项给定的上限。
+// 非 2xx 状态以包装的 *APIError 返回。
+type HTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Ensure HTTPClient implements the BusinessAdaptorService interface.
+// 确保 HTTPClient 实现了 BusinessAdaptorService 接口。
+var _ BusinessAdaptorService = &HTTPClient{}
+
+// NewHTTPClient creates a client for an http:// or https:// endpoint URL. A non-positive timeout
+// uses the default business SDK timeout.
+// NewHTTPClient 为 http:// 或 https:// 终点 URL 创建客户端。非正的超时时间使用默认的业务 SDK 超时时间。
+func NewHTTPClient(endpoint string, timeout time.Duration) (*HTTPClient, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeInvalidInput, "invalid business SDK endpoint URL", err, endpoint)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "invalid business SDK endpoint URL", fmt.Sprintf("'%s' is not an absolute http or https URL", endpoint))
+	}
+	if timeout <= 0 {
+		timeout = constants.DefaultBusinessSDKTimeout * time.Second
+	}
+	return &HTTPClient{
+		baseURL:    strings.TrimSuffix(endpoint, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// APIError is a non-2xx response of a business SDK endpoint. Code and Message are taken from a
+// JSON error body ({"error": {"code": "...", "message": "..."}}) when there is one; otherwise
+// Message holds the raw body.
+// APIError 是业务 SDK 终点的非 2xx 响应。如果有 JSON 错误体 ({"error": {"code": "...", "message": "..."}})，
+// Code 和 Message 取自其中; 否则 Message 保存原始响应体。
+type APIError struct {
+	StatusCode int    // HTTP status code / HTTP 状态码
+	Code       string // Error code reported by the business system / 业务系统报告的错误码
+	Message    string // Error message / 错误消息
+}
+
+// Error implements the error interface.
+// Error 实现 error 接口。
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("business SDK returned status %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("business SDK returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// NotFound reports whether the endpoint answered 404, e.g. for an unknown runbook or an
+// operation the business system does not implement.
+// NotFound 报告终点是否返回 404，例如未知的 Runbook 或业务系统未实现的操作。
+func (e *APIError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// maxErrorMessageLength caps the raw response body kept in an APIError.
+// maxErrorMessageLength 限制 APIError 中保留的原始响应体长度。
+const maxErrorMessageLength = 512
+
+// newAPIError parses the error body of a non-2xx response.
+// newAPIError 解析非 2xx 响应的错误体。
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	var parsed struct {
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error != nil {
+		apiErr.Code = parsed.Error.Code
+		apiErr.Message = parsed.Error.Message
+		return apiErr
+	}
+	message := strings.TrimSpace(string(body))
+	if len(message) > maxErrorMessageLength {
+		message = message[:maxErrorMessageLength] + "..."
+	}
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	apiErr.Message = message
+	return apiErr
+}
+
+// GetStatus retrieves the current health and key status indicators.
+// GetStatus 检索当前的健康状况和关键状态指标。
+func (c *HTTPClient) GetStatus(ctx context.Context) (*BusinessStatus, error) {
+	var status BusinessStatus
+	if err := c.do(ctx, http.MethodGet, "/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// QueryLogs retrieves business log entries page by page until the endpoint has no further page
+// or the "limit" option (default constants.DefaultBusinessSDKMaxLogEntries) is reached.
+// QueryLogs 按页检索业务日志条目，直到终点没有下一页或达到 "limit" 选项 (默认
+// constants.DefaultBusinessSDKMaxLogEntries) 为止。
+func (c *HTTPClient) QueryLogs(ctx context.Context, options map[string]interface{}) ([]LogEntry, error) {
+	limit := constants.DefaultBusinessSDKMaxLogEntries
+	if value, ok := intOption(options, "limit"); ok && value > 0 {
+		limit = value
+	}
+	query := queryValues(options, time.Now())
+
+	var entries []LogEntry
+	pageToken := ""
+	for len(entries) < limit {
+		pageSize := constants.DefaultBusinessSDKLogPageSize
+		if remaining := limit - len(entries); remaining < pageSize {
+			pageSize = remaining
+		}
+		query.Set("pageSize", strconv.Itoa(pageSize))
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			Entries       []LogEntry `json:"entries"`
+			NextPageToken string     `json:"nextPageToken"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/logs", query, nil, &page); err != nil {
+			return nil, err
+		}
+		entries = append(entries, page.Entries...)
+		// A repeated token would loop forever on a misbehaving endpoint.
+		// 重复的 token 会让行为异常的终点陷入死循环。
+		if page.NextPageToken == "" || page.NextPageToken == pageToken || len(page.Entries) == 0 {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// GetEvents retrieves significant business events.
+// GetEvents 检索重要的业务事件。
+func (c *HTTPClient) GetEvents(ctx context.Context, options map[string]interface{}) ([]BusinessEvent, error) {
+	var resp struct {
+		Events []BusinessEvent `json:"events"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/events", queryValues(options, time.Now()), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+// GetConfiguration retrieves critical configuration settings.
+// GetConfiguration 检索关键配置设置。
+func (c *HTTPClient) GetConfiguration(ctx context.Context) (*BusinessConfig, error) {
+	var config BusinessConfig
+	if err := c.do(ctx, http.MethodGet, "/config", nil, nil, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// ListRunbooks lists the available runbooks.
+// ListRunbooks 列出可用的 Runbook。
+func (c *HTTPClient) ListRunbooks(ctx context.Context) ([]Runbook, error) {
+	var resp struct {
+		Runbooks []Runbook `json:"runbooks"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/runbooks", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Runbooks, nil
+}
+
+// ExecuteRunbook triggers the execution of a runbook. The request is sent once and never retried,
+// since the runbook may have run even if the response was lost.
+// ExecuteRunbook 触发 Runbook 的执行。请求只发送一次且从不重试，因为即使响应丢失，Runbook 也可能已经执行。
+func (c *HTTPClient) ExecuteRunbook(ctx context.Context, runbookID string, parameters map[string]string) (*RunbookExecutionResult, error) {
+	if runbookID == "" {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "runbook ID cannot be empty", "")
+	}
+	if parameters == nil {
+		parameters = map[string]string{}
+	}
+	body := map[string]interface{}{"parameters": parameters}
+	var result RunbookExecutionResult
+	if err := c.do(ctx, http.MethodPost, "/runbooks/"+url.PathEscape(runbookID)+"/execute", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// do sends a request with optional query parameters and JSON body, and decodes a successful JSON
+// response into out. Every failure is an ErrorCodeBusinessSDKError; a non-2xx status wraps an *APIError.
+// do 发送带可选查询参数和 JSON 请求体的请求，并将成功的 JSON 响应解码到 out 中。
+// 所有失败均为 ErrorCodeBusinessSDKError; 非 2xx 状态包装一个 *APIError。
+func (c *HTTPClient) do(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(errors.ErrorCodeBusinessSDKError, "failed to marshal request body", err, path)
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeBusinessSDKError, "failed to create http request", err, path)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeBusinessSDKError, "business SDK request failed", err, method+" "+target)
+	}
+	defer resp.Body.Close()
+
+	// A misbehaving endpoint must not exhaust the agent's memory.
+	// 行为异常的终点不得耗尽代理的内存。
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, constants.MaxBusinessSDKResponseBytes+1))
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeBusinessSDKError, "failed to read response body", err, method+" "+target)
+	}
+	if len(bodyBytes) > constants.MaxBusinessSDKResponseBytes {
+		return errors.New(errors.ErrorCodeBusinessSDKError, "business SDK response body is too large", fmt.Sprintf("%s %s: more than %d bytes", method, target, constants.MaxBusinessSDKResponseBytes))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Wrap(errors.ErrorCodeBusinessSDKError, fmt.Sprintf("business SDK returned status %d", resp.StatusCode), newAPIError(resp.StatusCode, bodyBytes), method+" "+target)
+	}
+	if out != nil && len(bytes.TrimSpace(bodyBytes)) > 0 {
+		if err := json.Unmarshal(bodyBytes, out); err != nil {
+			return errors.Wrap(errors.ErrorCodeBusinessSDKError, "failed to unmarshal response body", err, method+" "+target)
+		}
+	}
+	return nil
+}
+
+// skippedOptions are options that are not sent as query parameters: "dataType" selects the
+// collector method, and "limit" and "timeRange" are translated.
+// skippedOptions 是不作为查询参数发送的选项: "dataType" 用于选择采集器方法，"limit" 和 "timeRange" 会被转换。
+var skippedOptions = map[string]bool{"dataType": true, "limit": true, "timeRange": true}
+
+// queryValues converts query options into URL query parameters. "timeRange", a time.Duration or a
+// duration string, becomes "since" relative to now unless "since" is set; time.Time values are
+// formatted as RFC 3339.
+// queryValues 将查询选项转换为 URL 查询参数。"timeRange" (time.Duration 或时长字符串) 会转换为相对于
+// now 的 "since" (除非已设置 "since"); time.Time 值格式化为 RFC 3339。
+func queryValues(options map[string]interface{}, now time.Time) url.Values {
+	query := url.Values{}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if skippedOptions[key] {
+			continue
+		}
+		for _, value := range optionStrings(options[key]) {
+			query.Add(key, value)
+		}
+	}
+	if query.Get("since") == "" {
+		if timeRange, ok := durationOption(options, "timeRange"); ok && timeRange > 0 {
+			query.Set("since", now.Add(-timeRange).UTC().Format(time.RFC3339))
+		}
+	}
+	return query
+}
+
+// optionStrings renders an option value as query parameter values; empty values are dropped.
+// optionStrings 将选项值渲染为查询参数值; 空值会被丢弃。
+func optionStrings(value interface{}) []string {
+	var values []string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		values = []string{v}
+	case []string:
+		values = v
+	case []interface{}:
+		for _, item := range v {
+			values = append(values, optionStrings(item)...)
+		}
+	case time.Time:
+		if !v.IsZero() {
+			values = []string{v.UTC().Format(time.RFC3339)}
+		}
+	case time.Duration:
+		values = []string{v.String()}
+	case fmt.Stringer:
+		values = []string{v.String()}
+	default:
+		values = []string{fmt.Sprint(v)}
+	}
+	result := values[:0:0]
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// durationOption reads a time.Duration or duration string option.
+// durationOption 读取 time.Duration 或时长字符串类型的选项。
+func durationOption(options map[string]interface{}, key string) (time.Duration, bool) {
+	switch v := options[key].(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		return d, err == nil
+	}
+	return 0, false
+}
+
+// intOption reads an integer option given as any Go integer, a JSON number or a numeric string.
+// intOption 读取以任意 Go 整数、JSON 数字或数字字符串给出的整数选项。
+func intOption(options map[string]interface{}, key string) (int, bool) {
+	switch v := options[key].(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	}
+	return 0, false
+}
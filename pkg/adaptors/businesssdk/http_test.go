@@ -0,0 +1,179 @@
+package businesssdk
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+)
+
+// logsServer serves /logs pages of the given size; next returns the nextPageToken of the page
+// requested with a token.
+func logsServer(t *testing.T, pageEntries int, next func(token string) string) (*HTTPClient, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		token := r.URL.Query().Get("pageToken")
+		var entries []string
+		for i := 0; i < pageEntries; i++ {
+			entries = append(entries, fmt.Sprintf(`{"message": "%s-%d"}`, token, i))
+		}
+		fmt.Fprintf(w, `{"entries": [%s], "nextPageToken": %q}`, strings.Join(entries, ","), next(token))
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewHTTPClient(server.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, &requests
+}
+
+func TestQueryLogsPagination(t *testing.T) {
+	ctx := context.Background()
+
+	// Pages are followed until the endpoint returns no token.
+	client, requests := logsServer(t, 2, func(token string) string {
+		if token == "" {
+			return "p2"
+		}
+		return ""
+	})
+	entries, err := client.QueryLogs(ctx, map[string]interface{}{"limit": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || len(*requests) != 2 || (*requests)[1].URL.Query().Get("pageToken") != "p2" {
+		t.Fatalf("expected two pages, got %d entries in %d requests", len(entries), len(*requests))
+	}
+
+	// A repeated token ends the query instead of looping forever.
+	client, requests = logsServer(t, 2, func(string) string { return "same" })
+	entries, err = client.QueryLogs(ctx, map[string]interface{}{"limit": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || len(*requests) != 2 {
+		t.Fatalf("expected the repeated token to stop paging, got %d entries in %d requests", len(entries), len(*requests))
+	}
+
+	// The limit caps the page size and the result, even if the endpoint returns more.
+	client, requests = logsServer(t, 5, func(string) string { return "more" })
+	entries, err = client.QueryLogs(ctx, map[string]interface{}{"limit": "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || len(*requests) != 1 || (*requests)[0].URL.Query().Get("pageSize") != "3" {
+		t.Fatalf("expected one page of three entries, got %d entries in %d requests", len(entries), len(*requests))
+	}
+
+	// Without a limit, pages of the default size are read up to the default maximum.
+	client, requests = logsServer(t, constants.DefaultBusinessSDKLogPageSize, func(token string) string {
+		n, _ := strconv.Atoi(token)
+		return strconv.Itoa(n + 1)
+	})
+	entries, err = client.QueryLogs(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != constants.DefaultBusinessSDKMaxLogEntries || len(*requests) != constants.DefaultBusinessSDKMaxLogEntries/constants.DefaultBusinessSDKLogPageSize {
+		t.Fatalf("expected the default limit, got %d entries in %d requests", len(entries), len(*requests))
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/runbooks/unknown/execute":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "RUNBOOK_NOT_FOUND", "message": "no runbook 'unknown'"}}`)
+		default:
+			http.Error(w, "database unavailable", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	client, err := NewHTTPClient(server.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.ExecuteRunbook(context.Background(), "unknown", nil)
+	var apiErr *APIError
+	if !errors.IsErrorCode(err, errors.ErrorCodeBusinessSDKError) || !stderrors.As(err, &apiErr) {
+		t.Fatalf("expected a business SDK error wrapping an APIError, got %v", err)
+	}
+	if !apiErr.NotFound() || apiErr.Code != "RUNBOOK_NOT_FOUND" || apiErr.Message != "no runbook 'unknown'" {
+		t.Fatalf("unexpected API error %+v", apiErr)
+	}
+
+	_, err = client.GetStatus(context.Background())
+	if !errors.IsErrorCode(err, errors.ErrorCodeBusinessSDKError) || !stderrors.As(err, &apiErr) {
+		t.Fatalf("expected a business SDK error wrapping an APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusInternalServerError || apiErr.NotFound() || apiErr.Message != "database unavailable" {
+		t.Fatalf("unexpected API error %+v", apiErr)
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	query := queryValues(map[string]interface{}{
+		"dataType":  "logs",
+		"limit":     10,
+		"timeRange": "15m",
+		"level":     []interface{}{"ERROR", " ", "WARN"},
+		"serviceId": "checkout",
+	}, now)
+	if got := query.Get("since"); got != "2026-01-02T09:45:00Z" {
+		t.Errorf("expected timeRange to become since, got %q", got)
+	}
+	if got := query["level"]; len(got) != 2 || got[0] != "ERROR" || got[1] != "WARN" {
+		t.Errorf("expected repeated level parameters, got %v", got)
+	}
+	for _, key := range []string{"dataType", "limit", "timeRange"} {
+		if query.Has(key) {
+			t.Errorf("option %s must not be sent", key)
+		}
+	}
+
+	explicit := queryValues(map[string]interface{}{"timeRange": time.Hour, "since": now}, now)
+	if got := explicit["since"]; len(got) != 1 || got[0] != "2026-01-02T10:00:00Z" {
+		t.Errorf("an explicit since must win over timeRange, got %v", got)
+	}
+}
+
+func TestTimeoutAndOversizedResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/status":
+			time.Sleep(200 * time.Millisecond)
+			fmt.Fprint(w, `{}`)
+		case "/config":
+			w.Write([]byte(strings.Repeat(" ", constants.MaxBusinessSDKResponseBytes+1)))
+		}
+	}))
+	defer server.Close()
+	client, err := NewHTTPClient(server.URL, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetStatus(context.Background()); !errors.IsErrorCode(err, errors.ErrorCodeBusinessSDKError) {
+		t.Fatalf("expected the request to time out, got %v", err)
+	}
+
+	client, err = NewHTTPClient(server.URL, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetConfiguration(context.Background()); !errors.IsErrorCode(err, errors.ErrorCodeBusinessSDKError) || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected the oversized response to be refused, got %v", err)
+	}
+}
@@ -3,7 +3,8 @@ package businesssdk
 import (
 	"time"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/enum"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 )
 
 // Package businesssdk defines the data types used by the Business SDK interface.
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/enum"
+	"github.com/google/uuid"
+	"github.com/turtacn/chasi-sreagent/pkg/adaptors/businesssdk"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/analyzer"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/datacollector" // Will use datacollector to get business data
 	"go.uber.org/zap"
@@ -30,7 +33,7 @@ var _ analyzer.Analyzer = &BusinessLogAnalyzer{}
 // Name returns the name of the analyzer.
 // Name 返回分析器的名称。
 func (a *BusinessLogAnalyzer) Name() string {
-	return types.AnalyzerBusinessLog // Using the constant defined in common
+	return constants.AnalyzerBusinessLog // Using the constant defined in common
 }
 
 // Description returns a brief description of the analyzer.
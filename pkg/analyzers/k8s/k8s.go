@@ -4,9 +4,10 @@ import (
 	"context"
 	"time"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/enum"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/analyzer"
 	"go.uber.org/zap"
 )
@@ -28,7 +29,7 @@ var _ analyzer.Analyzer = &K8sPodAnalyzer{}
 // Name returns the name of the analyzer.
 // Name 返回分析器的名称。
 func (a *K8sPodAnalyzer) Name() string {
-	return types.AnalyzerKubernetesPod // Using the constant defined in common
+	return constants.AnalyzerKubernetesPod // Using the constant defined in common
 }
 
 // Description returns a brief description of the analyzer.
@@ -99,7 +100,7 @@ func (a *K8sPodAnalyzer) Analyze(ctx context.Context) ([]types.Issue, error) {
 	}
 
 	logger.Info("Kubernetes Pod analysis completed", zap.Int("issuesFound", len(issues)))
-	return issues, nil, nil // Return collected issues and nil error
+	return issues, nil // Return collected issues and nil error
 }
 
 // RequiredDataSources returns the data source types needed by this analyzer.
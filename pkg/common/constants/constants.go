@@ -20,14 +20,213 @@ const (
 	// DefaultLLMTimeout 是 LLM API 调用的默认超时时间。
 	DefaultLLMTimeout = 60 // seconds / 秒
 
+	// DefaultLLMContextWindow is the context window assumed for models that are not recognized.
+	// DefaultLLMContextWindow 是无法识别的模型所假定的上下文窗口大小。
+	DefaultLLMContextWindow = 4096 // tokens
+
+	// DefaultLLMMaxTokens is the default number of completion tokens requested from an LLM.
+	// DefaultLLMMaxTokens 是向 LLM 请求的默认补全 token 数。
+	DefaultLLMMaxTokens = 1024 // tokens
+
+	// DefaultOpenAIURL is the API root used by the OpenAI provider when no URL is configured.
+	// DefaultOpenAIURL 是未配置 URL 时 OpenAI 提供商使用的 API 根地址。
+	DefaultOpenAIURL = "https://api.openai.com/v1"
+
+	// DefaultAzureOpenAIAPIVersion is the api-version used for Azure OpenAI deployments when none is configured.
+	// DefaultAzureOpenAIAPIVersion 是未配置时 Azure OpenAI 部署使用的 api-version。
+	DefaultAzureOpenAIAPIVersion = "2024-02-01"
+
+	// DefaultOllamaURL is the address of a local Ollama server, used when no URL is configured.
+	// DefaultOllamaURL 是本地 Ollama 服务的地址，在未配置 URL 时使用。
+	DefaultOllamaURL = "http://localhost:11434"
+
+	// DefaultOllamaPullTimeout bounds the download of a missing Ollama model at startup.
+	// DefaultOllamaPullTimeout 限制启动时下载缺失 Ollama 模型的时长。
+	DefaultOllamaPullTimeout = 30 * 60 // seconds / 秒 (30 minutes)
+
+	// DefaultLLMMaxRetries is the number of retries per provider on transient errors.
+	// DefaultLLMMaxRetries 是每个提供商在暂时性错误时的重试次数。
+	DefaultLLMMaxRetries = 2
+
+	// DefaultLLMInitialBackoff is the delay before the first retry of an LLM call.
+	// DefaultLLMInitialBackoff 是 LLM 调用第一次重试前的延迟。
+	DefaultLLMInitialBackoff = 500 // milliseconds / 毫秒
+
+	// DefaultLLMMaxBackoff is the upper bound of the delay between LLM retries.
+	// DefaultLLMMaxBackoff 是 LLM 重试间隔的上限。
+	DefaultLLMMaxBackoff = 5000 // milliseconds / 毫秒
+
+	// MaxLLMResponseBytes is the largest response body read from an LLM provider API.
+	// MaxLLMResponseBytes 是从 LLM 提供商 API 读取的最大响应体字节数。
+	MaxLLMResponseBytes = 32 << 20
+
+	// MaxLLMErrorBodyChars is the number of characters of an error response body kept in logs and errors.
+	// MaxLLMErrorBodyChars 是日志和错误中保留的错误响应体字符数。
+	MaxLLMErrorBodyChars = 1024
+
+	// DefaultLLMBreakerThreshold is the number of consecutive failures that opens a provider's circuit breaker.
+	// DefaultLLMBreakerThreshold 是打开提供商熔断器的连续失败次数。
+	DefaultLLMBreakerThreshold = 5
+
+	// DefaultLLMBreakerCooldown is how long an open circuit breaker skips its provider.
+	// DefaultLLMBreakerCooldown 是打开的熔断器跳过其提供商的时长。
+	DefaultLLMBreakerCooldown = 30 // seconds / 秒
+
+	// DefaultLLMUsageRetentionDays is the number of days of LLM usage kept in memory.
+	// DefaultLLMUsageRetentionDays 是在内存中保留的 LLM 用量天数。
+	DefaultLLMUsageRetentionDays = 7
+
 	// DefaultBusinessSDKTimeout is the default timeout for calling business SDK endpoints.
 	// DefaultBusinessSDKTimeout 是调用业务 SDK 终点的默认超时时间。
 	DefaultBusinessSDKTimeout = 10 // seconds / 秒
 
+	// DefaultBusinessSDKLogPageSize is the number of log entries requested per page from a business SDK endpoint.
+	// DefaultBusinessSDKLogPageSize 是每页向业务 SDK 终点请求的日志条目数。
+	DefaultBusinessSDKLogPageSize = 500
+
+	// DefaultBusinessSDKMaxLogEntries is the number of log entries a single query reads at most when the caller sets no limit.
+	// DefaultBusinessSDKMaxLogEntries 是调用方未设置上限时单次查询最多读取的日志条目数。
+	DefaultBusinessSDKMaxLogEntries = 5000
+
+	// MaxBusinessSDKResponseBytes is the largest response body read from a business SDK endpoint.
+	// MaxBusinessSDKResponseBytes 是从业务 SDK 终点读取的最大响应体字节数。
+	MaxBusinessSDKResponseBytes = 16 << 20
+
 	// DefaultAnalysisInterval is the default interval for continuous analysis.
 	// DefaultAnalysisInterval 是持续分析的默认间隔。
 	DefaultAnalysisInterval = 5 * 60 // seconds / 秒 (5 minutes)
 
+	// DefaultDiagnosisCacheTTL is the default time-to-live for cached diagnosis results.
+	// DefaultDiagnosisCacheTTL 是缓存诊断结果的默认存活时间。
+	DefaultDiagnosisCacheTTL = 60 * 60 // seconds / 秒 (1 hour)
+
+	// DefaultDiagnosisCacheMaxEntries is the default maximum number of cached diagnosis results.
+	// DefaultDiagnosisCacheMaxEntries 是缓存诊断结果的默认最大数量。
+	DefaultDiagnosisCacheMaxEntries = 256
+
+	// DefaultAgentMaxSteps is the default maximum number of tool calls in an agentic diagnosis.
+	// DefaultAgentMaxSteps 是智能体诊断中工具调用的默认最大次数。
+	DefaultAgentMaxSteps = 5
+
+	// DefaultAgentMaxToolOutputChars is the default number of characters of a tool result shown to the LLM.
+	// DefaultAgentMaxToolOutputChars 是展示给 LLM 的工具结果的默认字符数。
+	DefaultAgentMaxToolOutputChars = 4000
+
+	// DefaultConfidence is the prior confidence of a root cause or suggestion, and its confidence when no signal has evidence.
+	// DefaultConfidence 是根因或建议的先验置信度，也是没有任何信号具备证据时的置信度。
+	DefaultConfidence = 0.5
+
+	// ConfidencePriorWeight is the weight of DefaultConfidence in the mean of the confidence signals.
+	// ConfidencePriorWeight 是 DefaultConfidence 在置信度信号平均值中的权重。
+	ConfidencePriorWeight = 0.25
+
+	// DefaultConfidenceSimilarity is the word overlap at which two sampled answers agree.
+	// DefaultConfidenceSimilarity 是两个采样回答被视为一致的词重叠度。
+	DefaultConfidenceSimilarity = 0.5
+
+	// DefaultConfidenceSampleTemperature is the temperature of the additional diagnoses sampled for voting.
+	// DefaultConfidenceSampleTemperature 是为投票而额外采样的诊断所使用的温度。
+	DefaultConfidenceSampleTemperature = 0.7
+
+	// Default weights of the confidence signals.
+	// 置信度信号的默认权重。
+	DefaultConfidenceWeightAgreement = 0.35
+	DefaultConfidenceWeightGrounding = 0.25
+	DefaultConfidenceWeightKnowledge = 0.15
+	DefaultConfidenceWeightAnalyzer  = 0.25
+
+	// ConfidenceKnowledgeOverlap is the share of an answer's words a knowledge base hit must contain to support it.
+	// ConfidenceKnowledgeOverlap 是知识库命中必须包含的回答词语比例，才视为支持该回答。
+	ConfidenceKnowledgeOverlap = 0.2
+
+	// MaxParsedPromptTemplates is the number of parsed prompt templates the template engine keeps.
+	// MaxParsedPromptTemplates 是模板引擎保留的已解析提示模板数量。
+	MaxParsedPromptTemplates = 128
+
+	// InjectionConfidenceFactor scales the confidence of LLM suggestions when untrusted content looked like a prompt injection.
+	// InjectionConfidenceFactor 是不可信内容疑似提示注入时 LLM 建议置信度的缩放系数。
+	InjectionConfidenceFactor = 0.5
+
+	// DefaultKBHits is the number of knowledge base hits returned when the caller does not ask for a specific amount.
+	// DefaultKBHits 是调用方未指定数量时返回的知识库命中数。
+	DefaultKBHits = 5
+
+	// DefaultKBDiagnosisQueries is the maximum number of knowledge base queries of a diagnosis, one per group of similar issues.
+	// DefaultKBDiagnosisQueries 是一次诊断的最大知识库查询数，每组相似问题一个查询。
+	DefaultKBDiagnosisQueries = 5
+
+	// DefaultKBDiagnosisQueryHits is the number of hits retrieved by each knowledge base query of a diagnosis.
+	// DefaultKBDiagnosisQueryHits 是诊断中每个知识库查询检索的命中数。
+	DefaultKBDiagnosisQueryHits = 3
+
+	// DefaultKBDiagnosisHits is the maximum number of distinct knowledge base hits given to a diagnosis.
+	// DefaultKBDiagnosisHits 是提供给一次诊断的不重复知识库命中的最大数量。
+	DefaultKBDiagnosisHits = 8
+
+	// DefaultEmbeddingBatchSize is the maximum number of texts sent in one embedding request when none is configured.
+	// DefaultEmbeddingBatchSize 是未配置时单个 embedding 请求发送的最大文本数。
+	DefaultEmbeddingBatchSize = 32
+
+	// DefaultEmbeddingCacheSize is the number of embeddings cached by content hash when none is configured.
+	// DefaultEmbeddingCacheSize 是未配置时按内容哈希缓存的 embedding 数量。
+	DefaultEmbeddingCacheSize = 4096
+
+	// DefaultHashingEmbeddingDimension is the dimension of the hashing embedder when none is configured.
+	// DefaultHashingEmbeddingDimension 是未配置时哈希 embedder 的维度。
+	DefaultHashingEmbeddingDimension = 256
+
+	// DefaultVectorDBTimeout is the default timeout of requests to a vector database.
+	// DefaultVectorDBTimeout 是向量数据库请求的默认超时时间。
+	DefaultVectorDBTimeout = 30 // seconds / 秒
+
+	// DefaultLocalKBDir is the directory of the embedded knowledge base when none is configured.
+	// DefaultLocalKBDir 是未配置时嵌入式知识库的目录。
+	DefaultLocalKBDir = "/var/lib/chasi-sreagent/kb"
+
+	// DefaultLocalKBMaxSnapshots is the number of snapshots of the embedded knowledge base that are kept.
+	// DefaultLocalKBMaxSnapshots 是嵌入式知识库保留的快照数量。
+	DefaultLocalKBMaxSnapshots = 5
+
+	// DefaultKBChunkSize is the maximum number of characters of an ingested chunk when none is configured.
+	// DefaultKBChunkSize 是未配置时导入分块的最大字符数。
+	DefaultKBChunkSize = 1500
+
+	// DefaultKBChunkOverlap is the number of characters a chunk repeats from the previous one when none is configured.
+	// DefaultKBChunkOverlap 是未配置时分块从上一个分块重复的字符数。
+	DefaultKBChunkOverlap = 200
+
+	// DefaultKBIngestStateFile is the file recording the ingested documents when none is configured.
+	// DefaultKBIngestStateFile 是未配置时记录已导入文档的文件。
+	DefaultKBIngestStateFile = "/var/lib/chasi-sreagent/kb/ingest-state.json"
+
+	// DefaultKBRunbookSyncInterval is the time between two business runbook synchronizations when none is configured (in seconds).
+	// DefaultKBRunbookSyncInterval 是未配置时两次业务 Runbook 同步之间的间隔 (秒)。
+	DefaultKBRunbookSyncInterval = 600
+
+	// DefaultKBRunbookSyncStateFile is the file recording the synchronized business runbooks when none is configured.
+	// DefaultKBRunbookSyncStateFile 是未配置时记录已同步业务 Runbook 的文件。
+	DefaultKBRunbookSyncStateFile = "/var/lib/chasi-sreagent/kb/runbook-sync-state.json"
+
+	// DefaultKBCandidates is the number of candidates each ranking contributes to hybrid retrieval when none is configured.
+	// DefaultKBCandidates 是未配置时每种排序为混合检索提供的候选数。
+	DefaultKBCandidates = 20
+
+	// DefaultKBRRFK is the rank constant of reciprocal rank fusion when none is configured.
+	// DefaultKBRRFK 是未配置时倒数排名融合的排名常数。
+	DefaultKBRRFK = 60
+
+	// DefaultKBMinKeywordShare is the share of the best keyword score a keyword match below the minimum similarity needs when none is configured.
+	// DefaultKBMinKeywordShare 是未配置时低于最低相似度的关键词匹配所需达到的最佳关键词分数的比例。
+	DefaultKBMinKeywordShare = 0.3
+
+	// DefaultKBRerankTopN is the number of top hits reranked by the LLM when none is configured.
+	// DefaultKBRerankTopN 是未配置时由 LLM 重排序的靠前命中数。
+	DefaultKBRerankTopN = 10
+
+	// DefaultKBPageSize is the number of documents read from or written to a knowledge base per request when listing, exporting or importing.
+	// DefaultKBPageSize 是列出、导出或导入时每次请求从知识库读取或向其写入的文档数。
+	DefaultKBPageSize = 256
+
 	// VClusterKubeConfigKey is the key used in the vcluster config map entry for the kubeconfig.
 	// VClusterKubeConfigKey 是 vcluster 配置映射条目中用于存储 kubeconfig 的键。
 	VClusterKubeConfigKey = "config"
@@ -63,6 +262,18 @@ const (
 	// LLMProviderOpenAI 是 OpenAI LLM 提供商的名称。
 	LLMProviderOpenAI = "openai"
 
+	// LLMProviderOllama is the name for the native Ollama LLM provider.
+	// LLMProviderOllama 是原生 Ollama LLM 提供商的名称。
+	LLMProviderOllama = "ollama"
+
+	// LLMProviderFallback is the name of the composite provider that wraps the fallback chain.
+	// LLMProviderFallback 是封装降级链的组合提供商的名称。
+	LLMProviderFallback = "fallback"
+
+	// LLMProviderReplay is the name of the provider that records and replays LLM interactions.
+	// LLMProviderReplay 是录制并回放 LLM 交互的提供商的名称。
+	LLMProviderReplay = "replay"
+
 	// Add other LLM provider names here
 	// 在这里添加其他 LLM 提供商名称
 )
@@ -73,6 +284,100 @@ const (
 	// KBProviderVectorDB is the name for the vector database knowledge base provider.
 	// KBProviderVectorDB 是向量数据库知识库提供商的名称。
 	KBProviderVectorDB = "vector-db"
+
+	// KBProviderLocal is the name for the embedded knowledge base persisted to a local directory.
+	// KBProviderLocal 是持久化到本地目录的嵌入式知识库提供商的名称。
+	KBProviderLocal = "local"
+)
+
+// Knowledge base retrieval modes
+// 知识库检索模式
+const (
+	// KBRetrievalModeHybrid fuses the keyword and vector rankings.
+	// KBRetrievalModeHybrid 融合关键词排序和向量排序。
+	KBRetrievalModeHybrid = "hybrid"
+
+	// KBRetrievalModeVector ranks by vector similarity only.
+	// KBRetrievalModeVector 仅按向量相似度排序。
+	KBRetrievalModeVector = "vector"
+
+	// KBRetrievalModeKeyword ranks by BM25 keyword score only.
+	// KBRetrievalModeKeyword 仅按 BM25 关键词分数排序。
+	KBRetrievalModeKeyword = "keyword"
+)
+
+// Operator verdicts on a diagnosis
+// 运维人员对诊断的结论
+const (
+	// FeedbackVerdictConfirmed means the diagnosed root cause and fix were right.
+	// FeedbackVerdictConfirmed 表示诊断出的根因和修复是正确的。
+	FeedbackVerdictConfirmed = "confirmed"
+
+	// FeedbackVerdictCorrected means the operator supplied the actual root cause or fix.
+	// FeedbackVerdictCorrected 表示运维人员提供了实际的根因或修复。
+	FeedbackVerdictCorrected = "corrected"
+
+	// FeedbackVerdictRejected means the diagnosis was wrong and nothing should be learned from it.
+	// FeedbackVerdictRejected 表示诊断是错误的，不应从中学习任何内容。
+	FeedbackVerdictRejected = "rejected"
+)
+
+// Source prefixes of knowledge base documents the agent writes itself
+// 代理自行写入的知识库文档的来源前缀
+const (
+	// KBFeedbackSourcePrefix prefixes the source of knowledge base documents learned from feedback;
+	// it is followed by the analysis result ID.
+	// KBFeedbackSourcePrefix 是从反馈中学习的知识库文档来源的前缀; 其后是分析结果 ID。
+	KBFeedbackSourcePrefix = "feedback/"
+
+	// KBBusinessRunbookSourcePrefix prefixes the source of knowledge base documents synchronized from
+	// business runbooks; it is followed by the business service and the runbook ID.
+	// KBBusinessRunbookSourcePrefix 是从业务 Runbook 同步的知识库文档来源的前缀; 其后是业务服务和 Runbook ID。
+	KBBusinessRunbookSourcePrefix = "business-runbook/"
+)
+
+// Vector database types of the vector-db knowledge base provider
+// vector-db 知识库提供商支持的向量数据库类型
+const (
+	// VectorDBTypeQdrant is the type of a Qdrant vector database, accessed through its REST API.
+	// VectorDBTypeQdrant 是通过 REST API 访问的 Qdrant 向量数据库类型。
+	VectorDBTypeQdrant = "qdrant"
+
+	// VectorDBTypeWeaviate is the type of a Weaviate vector database, accessed through its REST and GraphQL APIs.
+	// VectorDBTypeWeaviate 是通过 REST 和 GraphQL API 访问的 Weaviate 向量数据库类型。
+	VectorDBTypeWeaviate = "weaviate"
+)
+
+// Embedding provider names that are not LLM providers
+// 不属于 LLM 提供商的 embedding 提供商名称
+const (
+	// EmbeddingProviderHashing is the name of the deterministic hashing embedder, which needs no model.
+	// EmbeddingProviderHashing 是确定性哈希 embedder 的名称，它不需要模型。
+	EmbeddingProviderHashing = "hashing"
+)
+
+// Diagnosis tool names
+// 诊断工具名称
+const (
+	// ToolGetResource is the name of the tool returning a Kubernetes resource manifest.
+	// ToolGetResource 是返回 Kubernetes 资源清单的工具名称。
+	ToolGetResource = "get_resource"
+
+	// ToolGetPodLogs is the name of the tool returning container logs of a pod.
+	// ToolGetPodLogs 是返回 Pod 容器日志的工具名称。
+	ToolGetPodLogs = "get_pod_logs"
+
+	// ToolListEvents is the name of the tool listing Kubernetes events.
+	// ToolListEvents 是列出 Kubernetes 事件的工具名称。
+	ToolListEvents = "list_events"
+
+	// ToolQueryBusinessLogs is the name of the tool querying business logs via the Business SDK.
+	// ToolQueryBusinessLogs 是通过业务 SDK 查询业务日志的工具名称。
+	ToolQueryBusinessLogs = "query_business_logs"
+
+	// ToolSearchKnowledgeBase is the name of the tool searching the knowledge base.
+	// ToolSearchKnowledgeBase 是搜索知识库的工具名称。
+	ToolSearchKnowledgeBase = "search_knowledge_base"
 )
 
 // Business SDK Discovery Methods
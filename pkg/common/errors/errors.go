@@ -54,17 +54,26 @@ const (
 	// ErrorCodePermissionDenied indicates insufficient permissions.
 	// ErrorCodePermissionDenied 表示权限不足。
 	ErrorCodePermissionDenied ErrorCode = "PERMISSION_DENIED"
+	// ErrorCodeBudgetExceeded indicates that a configured usage budget has been used up.
+	// ErrorCodeBudgetExceeded 表示配置的用量预算已用尽。
+	ErrorCodeBudgetExceeded ErrorCode = "BUDGET_EXCEEDED"
 )
 
 // Error implements the error interface for AgentError.
 // Error 为 AgentError 实现 error 接口。
 func (e *AgentError) Error() string {
 	if e.Wrapped != nil {
-		return fmt.Sprintf("[%s] %s: %s (details: %s, wrapped: %v)", e.Code, e.Message, e.Details, e.Wrapped)
+		return fmt.Sprintf("[%s] %s (details: %s, wrapped: %v)", e.Code, e.Message, e.Details, e.Wrapped)
 	}
 	return fmt.Sprintf("[%s] %s: %s", e.Code, e.Message, e.Details)
 }
 
+// Unwrap returns the wrapped error so that the standard errors.Is and errors.As can inspect it.
+// Unwrap 返回被包装的错误，以便标准库的 errors.Is 和 errors.As 可以检查它。
+func (e *AgentError) Unwrap() error {
+	return e.Wrapped
+}
+
 // Wrap wraps an existing error with an AgentError.
 // Wrap 使用 AgentError 包装现有错误。
 func Wrap(code ErrorCode, message string, err error, details string) *AgentError {
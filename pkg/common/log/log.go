@@ -96,8 +96,13 @@ func Init(cfg *types.LogConfig) error {
 		}
 	}
 
+	encoder := zapcore.NewJSONEncoder(encoderConfig)
+	if cfg.Format == "console" {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
+
 	core := zapcore.NewCore(
-		zapcore.NewEncoder(encoderConfig),
+		encoder,
 		output,
 		level,
 	)
@@ -181,6 +181,9 @@ const (
 	// DataSourceTypeEvent indicates data from events.
 	// DataSourceTypeEvent 表示来自事件的数据。
 	DataSourceTypeEvent
+	// DataSourceTypeStatus indicates health and status indicators of a business system.
+	// DataSourceTypeStatus 表示业务系统的健康状况和状态指标。
+	DataSourceTypeStatus
 )
 
 // String returns the string representation of a DataSourceType.
@@ -197,6 +200,8 @@ func (t DataSourceType) String() string {
 		return "Metric"
 	case DataSourceTypeEvent:
 		return "Event"
+	case DataSourceTypeStatus:
+		return "Status"
 	default:
 		return "Unknown"
 	}
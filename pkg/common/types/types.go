@@ -1,6 +1,7 @@
 package types
 
 import (
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 	"time"
 )
@@ -37,6 +38,30 @@ type Config struct {
 	BusinessSDK   BusinessSDKConfig   `yaml:"businessSDK"`   // Business Adaptation SDK configuration / 业务适配 SDK 配置
 	Analysis      AnalysisConfig      `yaml:"analysis"`      // Analysis configuration / 分析配置
 	Actions       ActionsConfig       `yaml:"actions"`       // Action configuration / 动作配置
+	Diagnosis     DiagnosisConfig     `yaml:"diagnosis"`     // Diagnosis configuration / 诊断配置
+	Redaction     RedactionConfig     `yaml:"redaction"`     // Secret and PII redaction / 机密与个人信息脱敏
+}
+
+// RedactionConfig configures the redaction of secrets and PII from evidence sent to an LLM or knowledge base.
+// RedactionConfig 配置对发送给 LLM 或知识库的证据中的机密和个人信息进行脱敏。
+type RedactionConfig struct {
+	Enabled bool `yaml:"enabled"` // Enable redaction / 启用脱敏
+	// Detectors lists the built-in detectors to use (e.g., "jwt", "email", "ipv4"); empty means all.
+	// Detectors 列出要使用的内置检测器 (例如 "jwt", "email", "ipv4"); 为空表示全部。
+	Detectors []string `yaml:"detectors"`
+	// Patterns are additional regular expressions; the first capture group is redacted, or the whole match if there is none.
+	// Patterns 是额外的正则表达式; 脱敏第一个捕获组，若无捕获组则脱敏整个匹配。
+	Patterns []RedactionPattern `yaml:"patterns"`
+	// DenyFields are dotted field paths of issues whose values are always redacted, e.g. "context.env.*"; "*" matches one segment.
+	// DenyFields 是问题中其值总是被脱敏的点分字段路径，例如 "context.env.*"; "*" 匹配一个段。
+	DenyFields []string `yaml:"denyFields"`
+}
+
+// RedactionPattern is a custom redaction rule.
+// RedactionPattern 是自定义脱敏规则。
+type RedactionPattern struct {
+	Name  string `yaml:"name"`  // Name used in placeholders (e.g., "EMPLOYEE_ID") / 占位符中使用的名称 (例如 "EMPLOYEE_ID")
+	Regex string `yaml:"regex"` // Regular expression / 正则表达式
 }
 
 // LogConfig represents logging configuration.
@@ -70,7 +95,79 @@ type LLMConfig struct {
 	LocalAI  LLMProviderConfig `yaml:"localai"`  // LocalAI specific config / LocalAI 特定配置
 	DeepSeek LLMProviderConfig `yaml:"deepseek"` // DeepSeek specific config / DeepSeek 特定配置
 	OpenAI   LLMProviderConfig `yaml:"openai"`   // OpenAI specific config / OpenAI 特定配置
-	Timeout  time.Duration     `yaml:"timeout"`  // Timeout for LLM API calls / LLM API 调用超时时间
+	Ollama   LLMProviderConfig `yaml:"ollama"`   // Ollama specific config / Ollama 特定配置
+	// Providers holds any number of named provider instances; Provider may refer to one of them by name.
+	// Providers 保存任意数量的命名提供商实例; Provider 可以按名称引用其中之一。
+	Providers map[string]LLMProviderConfig `yaml:"providers"`
+	Timeout   time.Duration                `yaml:"timeout"`  // Timeout for LLM API calls / LLM API 调用超时时间
+	Fallback  LLMFallbackConfig            `yaml:"fallback"` // Fallback chain settings / 降级链设置
+	Routing   LLMRoutingConfig             `yaml:"routing"`  // Tenant-aware routing rules / 租户感知的路由规则
+	Budget    LLMBudgetConfig              `yaml:"budget"`   // Daily token and cost budgets / 每日 token 与成本预算
+}
+
+// LLMBudgetConfig limits the daily LLM usage; 0 means unlimited. When a budget is used up,
+// diagnoses fall back to cached or rule-only results until the next day (UTC).
+// LLMBudgetConfig 限制每日 LLM 用量; 0 表示不限制。预算用尽后，诊断将回退为缓存或仅基于规则的结果，
+// 直到第二天 (UTC)。
+type LLMBudgetConfig struct {
+	LLMBudgetLimit `yaml:",inline"` // Limits over all vclusters / 所有 vcluster 的总限制
+	// VClusters holds per-vcluster limits; "" is the host cluster.
+	// VClusters 保存按 vcluster 的限制; "" 表示宿主集群。
+	VClusters map[string]LLMBudgetLimit `yaml:"vclusters"`
+}
+
+// LLMBudgetLimit is a daily token and cost limit.
+// LLMBudgetLimit 是每日 token 与成本限制。
+type LLMBudgetLimit struct {
+	DailyTokens int     `yaml:"dailyTokens"` // Prompt plus completion tokens per day / 每日提示与补全 token 总数
+	DailyCost   float64 `yaml:"dailyCost"`   // Estimated cost per day, in the currency of the prices / 每日估算成本，货币与价格一致
+}
+
+// LLMRoutingConfig decides which providers may see the evidence of an incident.
+// LLMRoutingConfig 决定哪些提供商可以看到某个事件的证据。
+type LLMRoutingConfig struct {
+	// Rules are evaluated in order; each issue prefers the providers of the first rule it matches
+	// and is limited by every restricted rule it matches, wherever it is in the list.
+	// Rules 按顺序评估; 每个问题优先使用其匹配的第一条规则的提供商，并受其匹配的每条受限规则限制，
+	// 无论该规则位于列表何处。
+	Rules []LLMRoutingRule `yaml:"rules"`
+}
+
+// LLMRoutingRule routes issues matching all of its non-empty criteria to its providers.
+// LLMRoutingRule 将匹配其所有非空条件的问题路由到其提供商。
+// Criteria are glob patterns (e.g., "finance-*"); severities are matched case-insensitively.
+// 条件为通配符模式 (例如 "finance-*"); 严重性匹配不区分大小写。
+type LLMRoutingRule struct {
+	Name       string   `yaml:"name"`       // Rule name, logged with each decision / 规则名称，随每次决策记录
+	VClusters  []string `yaml:"vclusters"`  // VCluster patterns; "" is the host cluster / VCluster 模式; "" 表示宿主集群
+	Namespaces []string `yaml:"namespaces"` // Namespace patterns / 命名空间模式
+	Services   []string `yaml:"services"`   // Business service patterns / 业务服务模式
+	Severities []string `yaml:"severities"` // Severities (e.g., "Critical") / 严重性 (例如 "Critical")
+	// Providers are the providers preferred for matching issues, in order.
+	// Providers 是匹配问题的首选提供商，按顺序排列。
+	Providers []string `yaml:"providers"`
+	// Restricted means the evidence of matching issues may only be sent to Providers; with no
+	// approved provider available the incident is not sent to any LLM.
+	// Restricted 表示匹配问题的证据只能发送给 Providers; 若没有可用的已批准提供商，该事件不会发送给任何 LLM。
+	Restricted bool `yaml:"restricted"`
+}
+
+// LLMFallbackConfig configures retries and fallback from the configured provider to other providers.
+// LLMFallbackConfig 配置重试以及从所配置提供商降级到其他提供商的行为。
+type LLMFallbackConfig struct {
+	// Providers are tried in order after the configured provider; empty disables the chain.
+	// Providers 在所配置的提供商之后按顺序尝试; 为空时禁用降级链。
+	Providers        []string      `yaml:"providers"`
+	MaxRetries       int           `yaml:"maxRetries"`       // Retries per provider on transient errors; 0 = default, <0 = none / 每个提供商在暂时性错误时的重试次数; 0 为默认值，<0 为不重试
+	InitialBackoff   time.Duration `yaml:"initialBackoff"`   // Delay before the first retry / 第一次重试前的延迟
+	MaxBackoff       time.Duration `yaml:"maxBackoff"`       // Upper bound of the retry delay / 重试延迟上限
+	BreakerThreshold int           `yaml:"breakerThreshold"` // Consecutive failures that open a provider's breaker / 打开提供商熔断器的连续失败次数
+	BreakerCooldown  time.Duration `yaml:"breakerCooldown"`  // How long an open breaker skips its provider / 打开的熔断器跳过其提供商的时长
+	// TotalTimeout bounds a call across the whole chain; 0 means timeout × number of providers.
+	// Each provider, including its retries, gets an equal share of the time that is left.
+	// TotalTimeout 限制整个降级链上一次调用的总时长; 0 表示 timeout × 提供商数量。
+	// 每个提供商 (包括其重试) 获得剩余时间的均等份额。
+	TotalTimeout time.Duration `yaml:"totalTimeout"`
 }
 
 // LLMProviderConfig represents configuration for a specific LLM provider.
@@ -79,17 +176,199 @@ type LLMProviderConfig struct {
 	URL    string `yaml:"url"`    // API endpoint URL / API 端点 URL
 	Model  string `yaml:"model"`  // Model name / 模型名称
 	APIKey string `yaml:"apiKey"` // API Key / API Key
+	// ContextWindow is the model's context size in tokens; 0 means look it up from the model name.
+	// ContextWindow 是模型的上下文大小 (token)；0 表示根据模型名称查找。
+	ContextWindow int `yaml:"contextWindow"`
+	// MaxTokens is the number of completion tokens requested; 0 means the default.
+	// MaxTokens 是请求的补全 token 数；0 表示使用默认值。
+	MaxTokens int `yaml:"maxTokens"`
+	// Type is the provider implementation of a named instance (e.g., "openai", "ollama"); defaults to "openai".
+	// Type 是命名实例的提供商实现 (例如 "openai", "ollama"); 默认为 "openai"。
+	Type string `yaml:"type"`
+	// Headers are added to every API request, e.g. for corporate gateways.
+	// Headers 会添加到每个 API 请求中，例如用于企业网关。
+	Headers map[string]string `yaml:"headers"`
+	// Organization is sent as the OpenAI-Organization header.
+	// Organization 作为 OpenAI-Organization 请求头发送。
+	Organization string `yaml:"organization"`
+	// Deployment selects an Azure OpenAI deployment; URL is then the Azure resource endpoint.
+	// Deployment 选择 Azure OpenAI 部署; 此时 URL 为 Azure 资源终点。
+	Deployment string `yaml:"deployment"`
+	// APIVersion is the Azure OpenAI api-version query parameter.
+	// APIVersion 是 Azure OpenAI 的 api-version 查询参数。
+	APIVersion string `yaml:"apiVersion"`
+	// EmbeddingModel is the model used for embeddings; empty means Model.
+	// EmbeddingModel 是用于 embedding 的模型; 为空表示使用 Model。
+	EmbeddingModel string `yaml:"embeddingModel"`
+	// KeepAlive is how long Ollama keeps the model loaded after a request (e.g., "10m", "-1" for ever).
+	// KeepAlive 是 Ollama 在请求后保持模型加载的时长 (例如 "10m"，"-1" 表示永久)。
+	KeepAlive string `yaml:"keepAlive"`
+	// AutoPull makes Ollama download missing models at startup.
+	// AutoPull 使 Ollama 在启动时下载缺失的模型。
+	AutoPull bool `yaml:"autoPull"`
+	// PullTimeout bounds the download of each missing model; 0 means the default (30 minutes).
+	// PullTimeout 限制每个缺失模型的下载时长; 0 表示默认值 (30 分钟)。
+	PullTimeout time.Duration `yaml:"pullTimeout"`
+	// PromptPrice and CompletionPrice are the prices per million tokens, used to estimate costs.
+	// PromptPrice 和 CompletionPrice 是每百万 token 的价格，用于估算成本。
+	PromptPrice     float64 `yaml:"promptPrice"`
+	CompletionPrice float64 `yaml:"completionPrice"`
+	// Cassette is the directory holding the cassette files of a replay provider.
+	// Cassette 是保存回放提供商磁带文件的目录。
+	Cassette string `yaml:"cassette"`
+	// Mode of a replay provider: "replay" (default), "record", or "auto" to record only what is missing.
+	// Mode 是回放提供商的模式: "replay" (默认)、"record"，或 "auto" 表示仅录制缺失的内容。
+	Mode string `yaml:"mode"`
+	// Upstream is the provider a replay provider records from.
+	// Upstream 是回放提供商录制时调用的提供商。
+	Upstream string `yaml:"upstream"`
+	// Scenario selects the cassette of a replay provider; empty means "default".
+	// Scenario 选择回放提供商的磁带; 为空表示 "default"。
+	Scenario string `yaml:"scenario"`
 	// Add other provider specific fields here
 	// 在这里添加其他提供商特定字段
 }
 
+// ProviderConfig returns the configuration of a provider by name, with Type filled in.
+// ProviderConfig 按名称返回提供商的配置，并填充 Type。
+// Named instances under providers take precedence over the built-in localai, deepseek, openai and ollama sections.
+// providers 下的命名实例优先于内置的 localai、deepseek、openai 和 ollama 配置段。
+func (c LLMConfig) ProviderConfig(name string) (LLMProviderConfig, bool) {
+	if cfg, ok := c.Providers[name]; ok {
+		if cfg.Type == "" {
+			cfg.Type = constants.LLMProviderOpenAI
+		}
+		return cfg, true
+	}
+
+	var cfg LLMProviderConfig
+	switch name {
+	case constants.LLMProviderLocalAI:
+		cfg = c.LocalAI
+	case constants.LLMProviderDeepSeek:
+		cfg = c.DeepSeek
+	case constants.LLMProviderOpenAI:
+		cfg = c.OpenAI
+	case constants.LLMProviderOllama:
+		cfg = c.Ollama
+	default:
+		return LLMProviderConfig{}, false
+	}
+	cfg.Type = name
+	return cfg, true
+}
+
+// EnabledProviderConfig returns the provider-specific configuration of the configured provider.
+// EnabledProviderConfig 返回所配置提供商的特定配置。
+func (c LLMConfig) EnabledProviderConfig() LLMProviderConfig {
+	if cfg, ok := c.ProviderConfig(c.Provider); ok {
+		return cfg
+	}
+	cfg := c.LocalAI
+	cfg.Type = constants.LLMProviderLocalAI
+	return cfg
+}
+
 // KnowledgeBaseConfig represents knowledge base (RAG) configuration.
 // KnowledgeBaseConfig 表示知识库 (RAG) 配置。
 type KnowledgeBaseConfig struct {
-	Enabled   bool            `yaml:"enabled"`   // Enable RAG / 启用 RAG
-	Provider  string          `yaml:"provider"`  // KB provider name / 知识库提供商名称
-	VectorDB  VectorDBConfig  `yaml:"vectorDB"`  // Vector DB specific config / 向量数据库特定配置
-	Embedding EmbeddingConfig `yaml:"embedding"` // Embedding model config for RAG / RAG 使用的 Embedding 模型配置
+	Enabled   bool              `yaml:"enabled"`   // Enable RAG / 启用 RAG
+	Provider  string            `yaml:"provider"`  // KB provider name / 知识库提供商名称
+	VectorDB  VectorDBConfig    `yaml:"vectorDB"`  // Vector DB specific config / 向量数据库特定配置
+	Local     LocalKBConfig     `yaml:"local"`     // Embedded knowledge base config / 嵌入式知识库配置
+	Embedding EmbeddingConfig   `yaml:"embedding"` // Embedding model config for RAG / RAG 使用的 Embedding 模型配置
+	Ingest    KBIngestConfig    `yaml:"ingest"`    // Document ingestion config / 文档导入配置
+	Retrieval KBRetrievalConfig `yaml:"retrieval"` // Retrieval and reranking config / 检索和重排序配置
+}
+
+// KBRetrievalConfig represents configuration for how the knowledge base is searched.
+// KBRetrievalConfig 表示知识库检索方式的配置。
+type KBRetrievalConfig struct {
+	// Mode is "hybrid" (keyword and vector rankings fused, the default), "vector" or "keyword".
+	// Mode 为 "hybrid" (融合关键词和向量排序，默认)、"vector" 或 "keyword"。
+	Mode string `yaml:"mode"`
+	// Candidates is the number of candidates each ranking contributes to the fusion; 0 means the default.
+	// Candidates 是每种排序为融合提供的候选数; 0 表示使用默认值。
+	Candidates int `yaml:"candidates"`
+	// RRFK is the rank constant of reciprocal rank fusion; larger values flatten the rankings. 0 means the default.
+	// RRFK 是倒数排名融合的排名常数; 值越大排序越平缓。0 表示使用默认值。
+	RRFK int `yaml:"rrfK"`
+	// MinScore is the lowest vector similarity of hits without a strong keyword match; 0 keeps every hit.
+	// MinScore 是没有强关键词匹配的命中的最低向量相似度; 0 表示保留所有命中。
+	MinScore float64 `yaml:"minScore"`
+	// MinKeywordShare is the share (0-1) of the query's best keyword score a keyword match needs to be
+	// kept below MinScore; 0 means the default.
+	// MinKeywordShare 是关键词匹配在低于 MinScore 时仍被保留所需达到的、该查询最佳关键词分数的比例 (0-1); 0 表示使用默认值。
+	MinKeywordShare float64        `yaml:"minKeywordShare"`
+	Rerank          KBRerankConfig `yaml:"rerank"` // Optional LLM reranking / 可选的 LLM 重排序
+}
+
+// KBRerankConfig represents configuration for reranking the top hits with an LLM.
+// KBRerankConfig 表示使用 LLM 对靠前命中进行重排序的配置。
+type KBRerankConfig struct {
+	Enabled  bool   `yaml:"enabled"`  // Rerank the top hits / 对靠前命中进行重排序
+	Provider string `yaml:"provider"` // LLM provider; empty means llm.provider / LLM 提供商; 为空表示使用 llm.provider
+	// TopN is the number of top hits reranked; 0 means the default.
+	// TopN 是参与重排序的靠前命中数; 0 表示使用默认值。
+	TopN int `yaml:"topN"`
+}
+
+// KBIngestConfig represents configuration for loading runbooks and documents into the knowledge base.
+// KBIngestConfig 表示将 Runbook 和文档导入知识库的配置。
+type KBIngestConfig struct {
+	Sources []KBIngestSource `yaml:"sources"` // Directories or git checkouts to ingest / 要导入的目录或 git 检出目录
+	// Interval is the time between two ingestions by the agent; 0 means only at startup.
+	// Interval 是代理两次导入之间的间隔; 0 表示仅在启动时导入。
+	Interval time.Duration `yaml:"interval"`
+	// ChunkSize is the maximum number of characters of a chunk; 0 means the default.
+	// ChunkSize 是单个分块的最大字符数; 0 表示使用默认值。
+	ChunkSize int `yaml:"chunkSize"`
+	// ChunkOverlap is the number of characters a chunk repeats from the previous chunk of its section; 0 means the default.
+	// ChunkOverlap 是分块从其所在章节的上一个分块重复的字符数; 0 表示使用默认值。
+	ChunkOverlap int `yaml:"chunkOverlap"`
+	// StateFile records the ingested files and their chunks, so that only changed files are ingested again.
+	// StateFile 记录已导入的文件及其分块，以便只重新导入发生变化的文件。
+	StateFile string `yaml:"stateFile"`
+	// BusinessRunbooks synchronizes the runbooks advertised by business endpoints into the knowledge base.
+	// BusinessRunbooks 将业务终点公布的 Runbook 同步到知识库。
+	BusinessRunbooks KBRunbookSyncConfig `yaml:"businessRunbooks"`
+}
+
+// KBRunbookSyncConfig represents configuration for synchronizing business runbooks into the knowledge base.
+// Runbooks are chunked with the ingestion's chunk settings.
+// KBRunbookSyncConfig 表示将业务 Runbook 同步到知识库的配置。Runbook 使用导入的分块设置进行分块。
+type KBRunbookSyncConfig struct {
+	Enabled bool `yaml:"enabled"` // Enable the synchronization / 启用同步
+	// Interval is the time between two synchronizations; 0 means the default.
+	// Interval 是两次同步之间的间隔; 0 表示使用默认值。
+	Interval time.Duration `yaml:"interval"`
+	Tags     []string      `yaml:"tags"` // Attached to the metadata of every chunk / 附加到每个分块的元数据中
+	// StateFile records the synchronized runbooks and their chunks, separately from the ingestion state.
+	// StateFile 记录已同步的 Runbook 及其分块，与导入状态分开保存。
+	StateFile string `yaml:"stateFile"`
+}
+
+// KBIngestSource is a directory or local git checkout of Markdown, HTML and plain-text documents.
+// KBIngestSource 是包含 Markdown、HTML 和纯文本文档的目录或本地 git 检出目录。
+type KBIngestSource struct {
+	Path string `yaml:"path"` // Directory or git checkout / 目录或 git 检出目录
+	// Include and Exclude are glob patterns matched against paths relative to Path; empty Include means all supported files.
+	// Include 和 Exclude 是与相对于 Path 的路径匹配的 glob 模式; Include 为空表示所有支持的文件。
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// Tags, VCluster and BusinessService are attached to the metadata of every chunk of the source.
+	// Tags、VCluster 和 BusinessService 会附加到该来源每个分块的元数据中。
+	Tags            []string `yaml:"tags"`
+	VCluster        string   `yaml:"vcluster"`
+	BusinessService string   `yaml:"businessService"`
+}
+
+// LocalKBConfig represents configuration for the embedded knowledge base, which keeps its index in
+// a local directory and needs no external database.
+// LocalKBConfig 表示嵌入式知识库的配置，它将索引保存在本地目录中，无需外部数据库。
+type LocalKBConfig struct {
+	Dir          string `yaml:"dir"`          // Directory of the index and its snapshots / 索引及其快照所在目录
+	MaxSnapshots int    `yaml:"maxSnapshots"` // Number of snapshots kept; 0 means the default / 保留的快照数量; 0 表示使用默认值
 }
 
 // VectorDBConfig represents configuration for a vector database.
@@ -98,6 +377,12 @@ type VectorDBConfig struct {
 	URL        string `yaml:"url"`        // Vector database endpoint URL / 向量数据库终点 URL
 	Collection string `yaml:"collection"` // Collection/index name / Collection/索引 名称
 	APIKey     string `yaml:"apiKey"`     // API Key / API Key
+	// Type is the kind of vector database: qdrant or weaviate.
+	// Type 是向量数据库的种类: qdrant 或 weaviate。
+	Type string `yaml:"type"`
+	// Timeout bounds each request to the vector database; 0 means the default.
+	// Timeout 限制对向量数据库的每个请求; 0 表示使用默认值。
+	Timeout time.Duration `yaml:"timeout"`
 	// Add other vector DB specific fields
 	// 添加其他向量数据库特定字段
 }
@@ -105,10 +390,23 @@ type VectorDBConfig struct {
 // EmbeddingConfig represents configuration for an embedding model.
 // EmbeddingConfig 表示 Embedding 模型配置。
 type EmbeddingConfig struct {
-	Provider string `yaml:"provider"` // Embedding model provider name / Embedding 模型提供商名称
-	Model    string `yaml:"model"`    // Model name / 模型名称
-	URL      string `yaml:"url"`      // API endpoint URL / API 端点 URL
-	APIKey   string `yaml:"apiKey"`   // API Key / API Key
+	// Provider is an LLM provider type or instance name (openai, localai, ollama, ...) or "hashing";
+	// the connection settings of an LLM provider with that name are used unless set here.
+	// Provider 是 LLM 提供商类型或实例名称 (openai、localai、ollama 等) 或 "hashing";
+	// 除非在此处设置，否则使用同名 LLM 提供商的连接设置。
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`  // Model name / 模型名称
+	URL      string `yaml:"url"`    // API endpoint URL / API 端点 URL
+	APIKey   string `yaml:"apiKey"` // API Key / API Key
+	// Dimension is the expected dimension of the vectors; 0 accepts the dimension of the first vector.
+	// Dimension 是向量的期望维度; 0 表示接受第一个向量的维度。
+	Dimension int `yaml:"dimension"`
+	// BatchSize is the maximum number of texts sent in one embedding request; 0 means the default.
+	// BatchSize 是单个 embedding 请求发送的最大文本数; 0 表示使用默认值。
+	BatchSize int `yaml:"batchSize"`
+	// CacheSize is the number of embeddings cached by content hash; 0 means the default, a negative value disables the cache.
+	// CacheSize 是按内容哈希缓存的 embedding 数量; 0 表示使用默认值，负值表示禁用缓存。
+	CacheSize int `yaml:"cacheSize"`
 }
 
 // BusinessSDKConfig represents business SDK adaptation configuration.
@@ -151,6 +449,77 @@ type ActionsConfig struct {
 	// 添加动作特定配置 (例如, 审批流程, 干运行)
 }
 
+// DiagnosisConfig represents diagnosis configuration.
+// DiagnosisConfig 表示诊断配置。
+type DiagnosisConfig struct {
+	Cache  DiagnosisCacheConfig `yaml:"cache"`  // Diagnosis result cache configuration / 诊断结果缓存配置
+	Prompt PromptConfig         `yaml:"prompt"` // Prompt template configuration / 提示模板配置
+	Agent  DiagnosisAgentConfig `yaml:"agent"`  // Agentic (tool-use) diagnosis configuration / 智能体 (工具调用) 诊断配置
+	// Confidence configures how the confidence of root causes and suggestions is derived from evidence.
+	// Confidence 配置如何根据证据得出根因和建议的置信度。
+	Confidence ConfidenceConfig `yaml:"confidence"`
+	// ResultsDir is the directory each diagnosis result is written to as <analysisResultId>.json, so
+	// operators can confirm or correct it later; empty disables writing.
+	// ResultsDir 是每个诊断结果以 <analysisResultId>.json 写入的目录，以便运维人员之后确认或更正; 为空表示不写入。
+	ResultsDir string `yaml:"resultsDir"`
+}
+
+// ConfidenceConfig represents configuration for evidence-based confidence scoring.
+// ConfidenceConfig 表示基于证据的置信度评分配置。
+// The confidence is a weighted mean of the signals available for a diagnosis: agreement between
+// sampled diagnoses, whether cited resources exist, knowledge base support and analyzer agreement.
+// 置信度是诊断可用信号的加权平均值: 采样诊断之间的一致性、引用的资源是否存在、知识库支持以及与分析器的一致性。
+type ConfidenceConfig struct {
+	// Samples is the number of diagnoses sampled for agreement voting, including the first; 0 or 1 disables voting.
+	// Samples 是用于一致性投票的采样诊断数量 (包括第一次); 0 或 1 表示禁用投票。
+	Samples     int     `yaml:"samples"`
+	Temperature float64 `yaml:"temperature"` // Sampling temperature of the additional diagnoses / 额外诊断的采样温度
+	// Similarity is the word overlap (0-1) at which two root causes or suggestions agree.
+	// Similarity 是两条根因或建议被视为一致的词重叠度 (0-1)。
+	Similarity float64           `yaml:"similarity"`
+	Weights    ConfidenceWeights `yaml:"weights"` // Weights of the signals; all zero means the defaults / 各信号的权重; 全为零表示使用默认值
+	// MinAutomated downgrades automated actions with a lower confidence to suggestions; 0 disables the gate.
+	// MinAutomated 将置信度低于该值的自动化动作降级为建议; 0 表示禁用该门槛。
+	MinAutomated float64 `yaml:"minAutomated"`
+}
+
+// ConfidenceWeights are the weights of the confidence signals.
+// ConfidenceWeights 是各置信度信号的权重。
+type ConfidenceWeights struct {
+	Agreement float64 `yaml:"agreement"` // Agreement between sampled diagnoses / 采样诊断之间的一致性
+	Grounding float64 `yaml:"grounding"` // Cited resources exist in the snapshot / 引用的资源存在于快照中
+	Knowledge float64 `yaml:"knowledge"` // Support by knowledge base hits / 知识库命中的支持
+	Analyzer  float64 `yaml:"analyzer"`  // Agreement with analyzer findings / 与分析器发现的一致性
+}
+
+// DiagnosisCacheConfig represents configuration for caching diagnosis results.
+// DiagnosisCacheConfig 表示诊断结果缓存的配置。
+// Cached results are keyed by issue fingerprints so that persistent issues are not re-sent to the LLM every cycle.
+// 缓存结果以问题指纹为键，避免持续存在的问题在每个周期都被重新发送给 LLM。
+type DiagnosisCacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`    // Enable the diagnosis cache / 启用诊断缓存
+	TTL        time.Duration `yaml:"ttl"`        // Time-to-live of a cached diagnosis / 缓存诊断的存活时间
+	MaxEntries int           `yaml:"maxEntries"` // Maximum number of cached diagnoses / 最大缓存诊断数量
+}
+
+// PromptConfig represents configuration for the prompt template engine.
+// PromptConfig 表示提示模板引擎的配置。
+type PromptConfig struct {
+	TemplateDir       string            `yaml:"templateDir"`       // Directory with template overrides ("<lang>/<category>.tmpl", "vclusters/<name>/<lang>/<category>.tmpl") / 模板覆盖目录
+	Language          string            `yaml:"language"`          // Default prompt language (e.g., "en", "zh") / 默认提示语言 (例如, "en", "zh")
+	VClusterLanguages map[string]string `yaml:"vclusterLanguages"` // Per-vcluster prompt language overrides / 按 vcluster 覆盖的提示语言
+}
+
+// DiagnosisAgentConfig represents configuration for agentic diagnosis, where the LLM may call
+// read-only tools to gather more evidence before answering.
+// DiagnosisAgentConfig 表示智能体诊断的配置，LLM 可在回答前调用只读工具收集更多证据。
+type DiagnosisAgentConfig struct {
+	Enabled            bool     `yaml:"enabled"`            // Enable the tool-use loop / 启用工具调用循环
+	MaxSteps           int      `yaml:"maxSteps"`           // Maximum number of tool calls per diagnosis / 每次诊断的最大工具调用次数
+	MaxToolOutputChars int      `yaml:"maxToolOutputChars"` // Characters of each tool result shown to the LLM / 每个工具结果展示给 LLM 的字符数
+	Tools              []string `yaml:"tools"`              // Tools offered to the LLM; empty means all registered tools / 提供给 LLM 的工具; 为空表示所有已注册工具
+}
+
 // Issue represents a detected issue in the environment.
 // Issue 表示环境中检测到的问题。
 type Issue struct {
@@ -196,19 +565,85 @@ type RemediationSuggestion struct {
 	Payload     map[string]interface{} `json:"payload"`     // (Optional) Payload for automated action type / (可选) 自动化动作类型的载荷
 	Confidence  float64                `json:"confidence"`  // Confidence level of the suggestion (0.0 - 1.0) / 建议的置信度 (0.0 - 1.0)
 	Source      string                 `json:"source"`      // Source of the suggestion (e.g., "LLM", "KnowledgeBase", "Rule") / 建议的来源 (例如, "LLM", "KnowledgeBase", "Rule")
+	// ConfidenceFactors explains how Confidence was derived; nil if it was set by the source.
+	// ConfidenceFactors 说明 Confidence 是如何得出的; 由来源直接设置时为 nil。
+	ConfidenceFactors *ConfidenceFactors `json:"confidenceFactors,omitempty"`
+}
+
+// ConfidenceFactors are the signals a confidence was derived from. A signal without evidence
+// (e.g., agreement without sampling, knowledge without hits) is -1 and left out of the mean.
+// ConfidenceFactors 是得出置信度所依据的信号。没有证据的信号 (例如未采样时的一致性、没有命中时的知识库支持)
+// 为 -1，且不计入平均值。
+type ConfidenceFactors struct {
+	Agreement float64 `json:"agreement"` // Share of sampled diagnoses that agree / 一致的采样诊断所占比例
+	Samples   int     `json:"samples"`   // Number of sampled diagnoses / 采样诊断的数量
+	Grounding float64 `json:"grounding"` // Share of cited resources that exist / 引用的资源中实际存在的比例
+	// UnknownResources are cited resources that do not appear in the analyzed snapshot.
+	// UnknownResources 是引用了但未出现在分析快照中的资源。
+	UnknownResources []string `json:"unknownResources,omitempty"`
+	Knowledge        float64  `json:"knowledge"`           // Score of the best supporting knowledge base hit / 最佳支持知识库命中的分数
+	Analyzer         float64  `json:"analyzer"`            // Agreement with analyzer findings / 与分析器发现的一致性
+	Injection        bool     `json:"injection,omitempty"` // Lowered because of suspected prompt injection / 因疑似提示注入而降低
 }
 
 // DiagnosisResult represents the outcome of the diagnosis process.
 // DiagnosisResult 表示诊断过程的结果。
 type DiagnosisResult struct {
-	AnalysisResultID  string                  `json:"analysisResultId"`  // ID of the analysis result this diagnosis is based on / 此诊断所基于的分析结果 ID
-	Timestamp         time.Time               `json:"timestamp"`         // Time when the diagnosis was performed / 执行诊断的时间
-	Duration          time.Duration           `json:"duration"`          // Duration of the diagnosis process / 诊断过程的持续时间
-	RootCause         string                  `json:"rootCause"`         // Identified root cause in natural language / 识别出的自然语言根因
-	Suggestions       []RemediationSuggestion `json:"suggestions"`       // List of suggested remediation actions / 建议的处置动作列表
-	LLMInteraction    *LLMInteractionDetails  `json:"llmInteraction"`    // Details about the LLM interaction / 大模型交互详情
-	KnowledgeBaseHits []KnowledgeBaseHit      `json:"knowledgeBaseHits"` // Details about knowledge base hits / 知识库命中详情
-	Error             string                  `json:"error"`             // Error message if diagnosis failed / 如果诊断失败的错误信息
+	AnalysisResultID  string                  `json:"analysisResultId"`   // ID of the analysis result this diagnosis is based on / 此诊断所基于的分析结果 ID
+	Issues            []Issue                 `json:"issues"`             // Analyzer findings the diagnosis is based on / 此诊断所基于的分析器发现
+	Timestamp         time.Time               `json:"timestamp"`          // Time when the diagnosis was performed / 执行诊断的时间
+	Duration          time.Duration           `json:"duration"`           // Duration of the diagnosis process / 诊断过程的持续时间
+	RootCause         string                  `json:"rootCause"`          // Identified root cause in natural language / 识别出的自然语言根因
+	Suggestions       []RemediationSuggestion `json:"suggestions"`        // List of suggested remediation actions / 建议的处置动作列表
+	LLMInteraction    *LLMInteractionDetails  `json:"llmInteraction"`     // Details about the LLM interaction / 大模型交互详情
+	KnowledgeBaseHits []KnowledgeBaseHit      `json:"knowledgeBaseHits"`  // Details about knowledge base hits / 知识库命中详情
+	Error             string                  `json:"error"`              // Error message if diagnosis failed / 如果诊断失败的错误信息
+	Cached            bool                    `json:"cached"`             // Whether the result was served from the diagnosis cache / 结果是否来自诊断缓存
+	CachedAt          time.Time               `json:"cachedAt,omitempty"` // Time when the cached result was originally produced / 缓存结果最初生成的时间
+	// Redactions is the number of values replaced by placeholders before evidence left the agent.
+	// Redactions 是证据离开代理之前被占位符替换的值的数量。
+	Redactions int `json:"redactions,omitempty"`
+	// RedactedValues maps placeholders to the original values so that suggestions can be rehydrated locally.
+	// It is never serialized.
+	// RedactedValues 将占位符映射到原始值，以便在本地还原建议。它永远不会被序列化。
+	RedactedValues map[string]string `json:"-" yaml:"-"`
+	// InjectionFindings lists untrusted content that looked like an attempt to instruct the LLM.
+	// When it is not empty the confidence of LLM suggestions is lowered.
+	// InjectionFindings 列出看起来试图向 LLM 下达指令的不可信内容。不为空时会降低 LLM 建议的置信度。
+	InjectionFindings []InjectionFinding `json:"injectionFindings,omitempty"`
+	// Degraded explains why the LLM was not called (e.g., an exhausted budget) and the result is cached or rule-only.
+	// Degraded 说明为何未调用 LLM (例如预算已用尽)，此时结果来自缓存或仅基于规则。
+	Degraded string `json:"degraded,omitempty"`
+	// RootCauseConfidence is the evidence-based confidence (0-1) in RootCause.
+	// RootCauseConfidence 是对 RootCause 基于证据的置信度 (0-1)。
+	RootCauseConfidence float64 `json:"rootCauseConfidence"`
+	// RootCauseConfidenceFactors explains how RootCauseConfidence was derived.
+	// RootCauseConfidenceFactors 说明 RootCauseConfidence 是如何得出的。
+	RootCauseConfidenceFactors *ConfidenceFactors `json:"rootCauseConfidenceFactors,omitempty"`
+}
+
+// DiagnosisFeedback is an operator's verdict on a DiagnosisResult. Confirmed and corrected diagnoses
+// are stored in the knowledge base so the next occurrence retrieves them.
+// DiagnosisFeedback 是运维人员对 DiagnosisResult 的结论。被确认和更正的诊断会存入知识库，以便下次发生时能检索到。
+type DiagnosisFeedback struct {
+	Verdict   string `json:"verdict" yaml:"verdict"`                         // confirmed, corrected or rejected / confirmed、corrected 或 rejected
+	RootCause string `json:"rootCause,omitempty" yaml:"rootCause,omitempty"` // Actual root cause; empty keeps the diagnosed one / 实际根因; 为空时保留诊断出的根因
+	Fix       string `json:"fix,omitempty" yaml:"fix,omitempty"`             // What resolved the incident; empty uses the suggestions / 解决事件的措施; 为空时使用建议
+	Evidence  string `json:"evidence,omitempty" yaml:"evidence,omitempty"`   // Evidence summary; empty summarizes the issues / 证据摘要; 为空时汇总问题
+	// VCluster and BusinessService scope the learned document; empty derives them from the issues.
+	// VCluster 和 BusinessService 限定所学文档的范围; 为空时从问题中推导。
+	VCluster        string    `json:"vcluster,omitempty" yaml:"vcluster,omitempty"`
+	BusinessService string    `json:"businessService,omitempty" yaml:"businessService,omitempty"`
+	Operator        string    `json:"operator,omitempty" yaml:"operator,omitempty"`   // Who gave the feedback / 给出反馈的人
+	Timestamp       time.Time `json:"timestamp,omitempty" yaml:"timestamp,omitempty"` // When the feedback was given / 给出反馈的时间
+}
+
+// InjectionFinding is a suspected prompt-injection attempt in untrusted content.
+// InjectionFinding 是不可信内容中疑似的提示注入尝试。
+type InjectionFinding struct {
+	Source  string `json:"source"`  // Where the content came from (e.g., "issue <id> message", "tool get_logs") / 内容来源 (例如 "issue <id> message", "tool get_logs")
+	Pattern string `json:"pattern"` // Name of the matched pattern / 匹配的模式名称
+	Excerpt string `json:"excerpt"` // Matched text with some context / 匹配的文本及其上下文
 }
 
 // LLMInteractionDetails represents details about the interaction with the LLM.
@@ -218,8 +653,138 @@ type LLMInteractionDetails struct {
 	Model    string `json:"model"`    // Model used / 使用的模型
 	Prompt   string `json:"prompt"`   // The prompt sent to the LLM / 发送给 LLM 的提示
 	Response string `json:"response"` // The raw response from the LLM / 从 LLM 收到的原始响应
-	// Potentially add token usage, latency, etc.
-	// 可以添加 token 使用量, 延迟等。
+	// Strategy is how the diagnosis was produced: "single" prompt, "map-reduce" over issue groups or "agentic" tool use.
+	// Strategy 表示诊断的生成方式: "single" 单次提示、按问题分组的 "map-reduce" 或 "agentic" 工具调用。
+	Strategy string `json:"strategy,omitempty"`
+	// EstimatedPromptTokens is the estimated size of Prompt in tokens.
+	// EstimatedPromptTokens 是 Prompt 的估算 token 数。
+	EstimatedPromptTokens int `json:"estimatedPromptTokens,omitempty"`
+	// Stages holds the per-group calls of a map-reduce diagnosis; Prompt/Response hold the final summary call.
+	// Stages 保存 map-reduce 诊断中每个分组的调用; Prompt/Response 保存最终汇总调用。
+	Stages []LLMStage `json:"stages,omitempty"`
+	// ToolCalls is the transcript of tool calls made by the LLM in an agentic diagnosis.
+	// ToolCalls 是智能体诊断中 LLM 发起的工具调用记录。
+	ToolCalls []ToolCallRecord `json:"toolCalls,omitempty"`
+	// PromptTokens and CompletionTokens are the tokens reported by the provider, summed over all calls.
+	// PromptTokens 和 CompletionTokens 是提供商报告的 token 数，为所有调用之和。
+	PromptTokens     int `json:"promptTokens,omitempty"`
+	CompletionTokens int `json:"completionTokens,omitempty"`
+	// FinishReason is why the model stopped generating the final response (e.g., "stop", "length").
+	// FinishReason 是模型停止生成最终响应的原因 (例如 "stop", "length")。
+	FinishReason string `json:"finishReason,omitempty"`
+	// RoutingRules are the routing rules that matched the diagnosed issues.
+	// RoutingRules 是与所诊断问题匹配的路由规则。
+	RoutingRules []string `json:"routingRules,omitempty"`
+	// AnsweredBy is the provider that answered the final call; it differs from Provider when a fallback answered.
+	// AnsweredBy 是应答最终调用的提供商; 当由降级提供商应答时与 Provider 不同。
+	AnsweredBy string `json:"answeredBy,omitempty"`
+	// FailedAttempts lists the provider calls that failed before an answer was received.
+	// FailedAttempts 列出在收到回答之前失败的提供商调用。
+	FailedAttempts []LLMAttempt `json:"failedAttempts,omitempty"`
+	// Calls is the accounting record of every provider call of the run, including failed ones.
+	// Calls 是本次运行中每次提供商调用 (包括失败的调用) 的计量记录。
+	Calls []LLMCall `json:"calls,omitempty"`
+	// Usage aggregates Calls: tokens, latency and estimated cost of the run.
+	// Usage 汇总 Calls: 本次运行的 token、耗时和估算成本。
+	Usage LLMUsage `json:"usage"`
+	// Samples are the responses of the additional diagnoses sampled for confidence voting.
+	// Samples 是为置信度投票而额外采样的诊断响应。
+	Samples []string `json:"samples,omitempty"`
+}
+
+// LLMCall is the accounting record of a single provider call.
+// LLMCall 是单次提供商调用的计量记录。
+type LLMCall struct {
+	Provider         string        `json:"provider"`            // Provider called / 被调用的提供商
+	Model            string        `json:"model,omitempty"`     // Model used / 使用的模型
+	PromptTokens     int           `json:"promptTokens"`        // Tokens in the prompt / 提示的 token 数
+	CompletionTokens int           `json:"completionTokens"`    // Tokens in the answer / 回答的 token 数
+	Estimated        bool          `json:"estimated,omitempty"` // Tokens were estimated because the provider reported none / 提供商未报告 token 数，因而为估算值
+	Latency          time.Duration `json:"latency"`             // How long the call took / 调用耗时
+	Cost             float64       `json:"cost,omitempty"`      // Estimated cost / 估算成本
+	Error            string        `json:"error,omitempty"`     // Why the call failed / 调用失败的原因
+}
+
+// LLMUsage aggregates LLM calls.
+// LLMUsage 汇总 LLM 调用。
+type LLMUsage struct {
+	Calls            int           `json:"calls"`            // Number of calls / 调用次数
+	Errors           int           `json:"errors"`           // Number of failed calls / 失败的调用次数
+	PromptTokens     int           `json:"promptTokens"`     // Prompt tokens / 提示 token 数
+	CompletionTokens int           `json:"completionTokens"` // Completion tokens / 补全 token 数
+	Latency          time.Duration `json:"latency"`          // Total latency of all calls / 所有调用的总耗时
+	Cost             float64       `json:"cost"`             // Estimated cost / 估算成本
+}
+
+// Add adds a call to the usage.
+// Add 将一次调用计入用量。
+func (u *LLMUsage) Add(call LLMCall) {
+	u.Calls++
+	if call.Error != "" {
+		u.Errors++
+	}
+	u.PromptTokens += call.PromptTokens
+	u.CompletionTokens += call.CompletionTokens
+	u.Latency += call.Latency
+	u.Cost += call.Cost
+}
+
+// Tokens returns the prompt plus completion tokens.
+// Tokens 返回提示与补全 token 之和。
+func (u LLMUsage) Tokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// LLMDailyUsage is the LLM usage of one day (UTC), broken down by provider/model and by vcluster.
+// LLMDailyUsage 是某一天 (UTC) 的 LLM 用量，按提供商/模型和 vcluster 细分。
+// A diagnosis covering several vclusters counts towards each of them.
+// 涉及多个 vcluster 的诊断会计入其中每一个。
+type LLMDailyUsage struct {
+	Date      string              `json:"date"`      // Day as YYYY-MM-DD / 日期，格式为 YYYY-MM-DD
+	Total     LLMUsage            `json:"total"`     // Usage over all providers / 所有提供商的用量
+	Providers map[string]LLMUsage `json:"providers"` // Usage per "provider/model" / 按 "提供商/模型" 的用量
+	VClusters map[string]LLMUsage `json:"vclusters"` // Usage per vcluster / 按 vcluster 的用量
+	Runs      int                 `json:"runs"`      // Diagnosis runs that called an LLM / 调用了 LLM 的诊断运行次数
+}
+
+// LLMAttempt records a single call to a provider in a fallback chain.
+// LLMAttempt 记录降级链中对某个提供商的一次调用。
+type LLMAttempt struct {
+	Provider string        `json:"provider"`        // Provider called / 被调用的提供商
+	Attempt  int           `json:"attempt"`         // 1 for the first call, 2 for the first retry, ... / 首次调用为 1，第一次重试为 2，...
+	Error    string        `json:"error,omitempty"` // Why the call failed / 调用失败的原因
+	Duration time.Duration `json:"duration"`        // How long the call took / 调用耗时
+}
+
+// LLMStage represents a single intermediate LLM call within a diagnosis.
+// LLMStage 表示诊断过程中的一次中间 LLM 调用。
+type LLMStage struct {
+	Name                  string   `json:"name"`                            // Stage name (e.g., "group-1") / 阶段名称 (例如, "group-1")
+	IssueIDs              []string `json:"issueIds,omitempty"`              // Issues covered by this stage / 此阶段覆盖的问题
+	Prompt                string   `json:"prompt"`                          // Prompt sent in this stage / 此阶段发送的提示
+	Response              string   `json:"response"`                        // Response received in this stage / 此阶段收到的响应
+	EstimatedPromptTokens int      `json:"estimatedPromptTokens,omitempty"` // Estimated prompt size in tokens / 估算的提示 token 数
+	Error                 string   `json:"error,omitempty"`                 // Error of this stage, if any / 此阶段的错误 (如果有)
+}
+
+// ToolCallRecord represents a single tool call made by the LLM during diagnosis.
+// ToolCallRecord 表示诊断过程中 LLM 发起的一次工具调用。
+type ToolCallRecord struct {
+	Step      int                    `json:"step"`                // 1-based step number / 步骤编号 (从 1 开始)
+	Tool      string                 `json:"tool"`                // Name of the tool called / 调用的工具名称
+	Arguments map[string]interface{} `json:"arguments,omitempty"` // Arguments supplied by the LLM / LLM 提供的参数
+	Result    string                 `json:"result,omitempty"`    // Tool output as shown to the LLM / 展示给 LLM 的工具输出
+	Error     string                 `json:"error,omitempty"`     // Error returned by the tool, if any / 工具返回的错误 (如果有)
+	Duration  time.Duration          `json:"duration"`            // Time taken by the tool / 工具耗时
+}
+
+// KnowledgeDocument is an entry stored in the knowledge base, e.g. a chunk of a runbook.
+// KnowledgeDocument 是存储在知识库中的条目，例如 Runbook 的一个分块。
+type KnowledgeDocument struct {
+	ID       string            `json:"id"`                 // Stable ID; derived from source and content if empty / 稳定 ID; 为空时由来源和内容派生
+	Source   string            `json:"source"`             // Source document or origin / 来源文档或出处
+	Content  string            `json:"content"`            // Text that is embedded and returned by searches / 被计算 embedding 并由搜索返回的文本
+	Metadata map[string]string `json:"metadata,omitempty"` // e.g. path, section, tags, vcluster / 例如路径、章节、标签、vcluster
 }
 
 // KnowledgeBaseHit represents a relevant entry found in the knowledge base.
@@ -229,6 +794,21 @@ type KnowledgeBaseHit struct {
 	Source  string  `json:"source"`  // Source document or origin / 来源文档或出处
 	Content string  `json:"content"` // Relevant content snippet / 相关的片段内容
 	Score   float64 `json:"score"`   // Relevance score / 相关性分数
+	// Metadata describes the entry, e.g. its section, tags or the vcluster it applies to.
+	// Metadata 描述该条目，例如其章节、标签或适用的 vcluster。
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// KeywordScore is the BM25 score of the entry for the query; 0 if it was only found by vector search.
+	// KeywordScore 是该条目对查询的 BM25 分数; 仅由向量搜索找到时为 0。
+	KeywordScore float64 `json:"keywordScore,omitempty"`
+	// FusionScore is the reciprocal rank fusion score the hits were ordered by in hybrid retrieval.
+	// FusionScore 是混合检索中用于对命中排序的倒数排名融合分数。
+	FusionScore float64 `json:"fusionScore,omitempty"`
+	// RerankScore is the relevance (0.0 - 1.0) assigned by the LLM reranker, if any.
+	// RerankScore 是 LLM 重排序器给出的相关性 (0.0 - 1.0)，如有。
+	RerankScore float64 `json:"rerankScore,omitempty"`
+	// IssueIDs are the issues of a diagnosis the hit was retrieved for.
+	// IssueIDs 是诊断中检索到该命中所针对的问题。
+	IssueIDs []string `json:"issueIds,omitempty"`
 	// Potentially add link to original document
 	// 可以添加原始文档链接
 }
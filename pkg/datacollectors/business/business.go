@@ -3,20 +3,19 @@ package business
 import (
 	"context"
 	"fmt"
-	"github.com/turtacn/chasi-sreagent/pkg/adaptors/businesssdk"
 	"net/url"
+	"strings"
 	"sync"
+	"time"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/enum"
+	"github.com/turtacn/chasi-sreagent/pkg/adaptors/businesssdk"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/datacollector"
 	"go.uber.org/zap"
-	// Add imports for gRPC or HTTP clients based on SDK implementation
-	// 根据 SDK 实现添加 gRPC 或 HTTP 客户端的导入
-	// "google.golang.org/grpc"
-	// "net/http"
 )
 
 // Package business provides a data collector for business systems using the Business SDK.
@@ -30,8 +29,11 @@ type BusinessDataCollector struct {
 	// clientCache 缓存与业务 SDK 终点的连接/客户端。
 	// Map endpoint URL to client instance (e.g., gRPC client, HTTP client).
 	// 将终点 URL 映射到客户端实例 (例如, gRPC 客户端, HTTP 客户端)。
-	clientCache map[string]businesssdk.BusinessAdaptorService // Placeholder interface / 占位符接口
-	mu          sync.RWMutex
+	clientCache map[string]businesssdk.BusinessAdaptorService
+	// names maps endpoint URLs to the names of their business services.
+	// names 将终点 URL 映射到其业务服务名称。
+	names map[string]string
+	mu    sync.RWMutex
 	// Add dependencies for service discovery (e.g., K8s client if discoveryMethod is kubernetes-service)
 	// 添加服务发现的依赖项 (例如, 如果 discoveryMethod 是 kubernetes-service，则添加 K8s 客户端)
 	// k8sClient kubernetes.Interface // Placeholder
@@ -86,7 +88,7 @@ func (c *BusinessDataCollector) discoverEndpoints(ctx context.Context) error {
 	var discoveryErr error
 
 	switch c.config.DiscoveryMethod {
-	case types.BusinessSDKDiscoveryKubernetesService:
+	case constants.BusinessSDKDiscoveryKubernetesService:
 		// TODO: Implement Kubernetes service discovery using K8s client
 		// Search for services with specific labels in configured namespaces
 		// TODO: 使用 K8s 客户端实现 Kubernetes 服务发现
@@ -97,7 +99,7 @@ func (c *BusinessDataCollector) discoverEndpoints(ctx context.Context) error {
 		// Convert Service IPs/Hostnames to endpoint URLs
 		// 将 Service IP/主机名转换为终点 URL
 		discoveryErr = errors.New(errors.ErrorCodeUnknown, "Kubernetes service discovery not implemented", "") // Placeholder error
-	case types.BusinessSDKDiscoveryStaticList:
+	case constants.BusinessSDKDiscoveryStaticList:
 		endpoints = c.config.StaticEndpoints
 		logger.Info("Using static list for business SDK endpoints", zap.Int("count", len(endpoints)))
 	default:
@@ -109,32 +111,47 @@ func (c *BusinessDataCollector) discoverEndpoints(ctx context.Context) error {
 		return discoveryErr
 	}
 
-	// Cache the discovered endpoints (and potentially initialize clients)
-	// 缓存发现的终点 (并可能初始化客户端)
+	// Cache a client for each discovered endpoint
+	// 为每个发现的终点缓存一个客户端
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.clientCache = make(map[string]businesssdk.BusinessAdaptorService) // Clear existing cache / 清空现有缓存
+	c.names = make(map[string]string, len(endpoints))
 	for _, ep := range endpoints {
-		// TODO: Initialize the appropriate client (gRPC or HTTP) based on URL scheme or config
-		// For now, just store a placeholder
-		// TODO: 根据 URL scheme 或配置初始化相应的客户端 (gRPC 或 HTTP)
-		// 目前，只存储一个占位符
 		logger.Debug("Discovered business SDK endpoint", zap.String("name", ep.Name), zap.String("url", ep.URL))
-		// Placeholder: In a real implementation, you'd create a gRPC client or HTTP client here
-		// 占位符: 在实际实现中，你将在这里创建一个 gRPC 客户端或 HTTP 客户端
-		// client, err := newBusinessSDKClient(ctx, ep.URL, c.config.Timeout) // TODO: Implement client creation
-		// if err != nil {
-		//     logger.Error("Failed to create business SDK client", zap.String("url", ep.URL), zap.Error(err))
-		//     continue // Skip this endpoint
-		// }
-		// c.clientCache[ep.URL] = client
-		c.clientCache[ep.URL] = &placeholderBusinessAdaptorService{} // Using a placeholder struct / 使用占位符结构体
+		client, err := newBusinessSDKClient(ep.URL, c.config.Timeout)
+		if err != nil {
+			logger.Error("Failed to create business SDK client", zap.String("url", ep.URL), zap.Error(err))
+			continue // Skip this endpoint / 跳过此终点
+		}
+		c.clientCache[ep.URL] = client
+		c.names[ep.URL] = ep.Name
 	}
 
 	logger.Info("Business SDK endpoint discovery completed", zap.Int("discoveredCount", len(c.clientCache)))
 	return nil
 }
 
+// newBusinessSDKClient creates the client for an endpoint URL, chosen by its scheme. Only the
+// HTTP/JSON protocol (http:// and https://) is supported.
+// newBusinessSDKClient 根据终点 URL 的 scheme 为其创建客户端。目前只支持 HTTP/JSON 协议 (http:// 和 https://)。
+func newBusinessSDKClient(endpoint string, timeout time.Duration) (businesssdk.BusinessAdaptorService, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeInvalidInput, "invalid business SDK endpoint URL", err, endpoint)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		client, err := businesssdk.NewHTTPClient(endpoint, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	default:
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "unsupported business SDK endpoint scheme", fmt.Sprintf("endpoint '%s' uses scheme '%s', only http and https are supported", endpoint, u.Scheme))
+	}
+}
+
 // Name returns the name of the data collector.
 // Name 返回数据采集器的名称。
 func (c *BusinessDataCollector) Name() string {
@@ -153,6 +170,32 @@ func (c *BusinessDataCollector) Type() enum.DataSourceType {
 	return enum.DataSourceTypeBusinessSDK // This collector provides data *from* BusinessSDK
 }
 
+// Services returns the discovered Business SDK clients keyed by endpoint URL.
+// Services 返回已发现的业务 SDK 客户端 (以终点 URL 为键)。
+func (c *BusinessDataCollector) Services() map[string]businesssdk.BusinessAdaptorService {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	services := make(map[string]businesssdk.BusinessAdaptorService, len(c.clientCache))
+	for url, client := range c.clientCache {
+		services[url] = client
+	}
+	return services
+}
+
+// ServiceName returns the configured name of the business service behind an endpoint URL, or the
+// URL itself if the endpoint has no name.
+// ServiceName 返回终点 URL 背后业务服务的配置名称; 若终点没有名称则返回 URL 本身。
+func (c *BusinessDataCollector) ServiceName(url string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if name := c.names[url]; name != "" {
+		return name
+	}
+	return url
+}
+
 // Collect gathers data from business systems.
 // Collect 从业务系统收集数据。
 // Options should include "dataType" (e.g., enum.DataSourceTypeLog, enum.DataSourceTypeStatus)
@@ -280,37 +323,6 @@ func (c *BusinessDataCollector) Collect(ctx context.Context, options map[string]
 	return allCollectedData, nil // This needs proper combining based on dataType / 这需要根据 dataType 进行适当的合并
 }
 
-// placeholderBusinessAdaptorService is a dummy implementation for the client cache.
-// placeholderBusinessAdaptorService 是用于客户端缓存的虚拟实现。
-// A real implementation would be a gRPC or HTTP client struct.
-// 实际实现将是一个 gRPC 或 HTTP 客户端结构体。
-type placeholderBusinessAdaptorService struct{}
-
-func (p *placeholderBusinessAdaptorService) GetStatus(ctx context.Context) (*businesssdk.BusinessStatus, error) {
-	log.L().Warn("placeholderBusinessAdaptorService.GetStatus called")
-	return &businesssdk.BusinessStatus{Status: "Placeholder"}, nil
-}
-func (p *placeholderBusinessAdaptorService) QueryLogs(ctx context.Context, options map[string]interface{}) ([]businesssdk.LogEntry, error) {
-	log.L().Warn("placeholderBusinessAdaptorService.QueryLogs called")
-	return []businesssdk.LogEntry{}, nil
-}
-func (p *placeholderBusinessAdaptorService) GetEvents(ctx context.Context, options map[string]interface{}) ([]businesssdk.BusinessEvent, error) {
-	log.L().Warn("placeholderBusinessAdaptorService.GetEvents called")
-	return []businesssdk.BusinessEvent{}, nil
-}
-func (p *placeholderBusinessAdaptorService) GetConfiguration(ctx context.Context) (*businesssdk.BusinessConfig, error) {
-	log.L().Warn("placeholderBusinessAdaptorService.GetConfiguration called")
-	return &businesssdk.BusinessConfig{}, nil
-}
-func (p *placeholderBusinessAdaptorService) ListRunbooks(ctx context.Context) ([]businesssdk.Runbook, error) {
-	log.L().Warn("placeholderBusinessAdaptorService.ListRunbooks called")
-	return []businesssdk.Runbook{}, nil
-}
-func (p *placeholderBusinessAdaptorService) ExecuteRunbook(ctx context.Context, runbookID string, parameters map[string]string) (*businesssdk.RunbookExecutionResult, error) {
-	log.L().Warn("placeholderBusinessAdaptorService.ExecuteRunbook called")
-	return &businesssdk.RunbookExecutionResult{Status: "Placeholder"}, nil
-}
-
 // Register the data collector with the global registry.
 // 在全局注册表中注册数据采集器。
 func init() {
@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/enum"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/datacollector"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
@@ -77,8 +78,6 @@ func NewK8sDataCollector(cfg *types.KubernetesConfig) (*K8sDataCollector, error)
 		if vcfg.Kubeconfig != "" {
 			// Use inline kubeconfig
 			// 使用内联 kubeconfig
-			loadingRules := &clientcmd.ClientConfigLoadingRules{}
-			loadingRules.LoadFromFile = "" // Prevent loading from default file paths / 防止从默认文件路径加载
 			apiConfig, parseErr := clientcmd.Load([]byte(vcfg.Kubeconfig))
 			if parseErr != nil {
 				log.L().Error("Failed to parse inline vcluster kubeconfig", zap.String("vcluster", vcfg.Name), zap.Error(parseErr))
@@ -139,6 +138,16 @@ func (c *K8sDataCollector) Type() enum.DataSourceType {
 	return enum.DataSourceTypeKubernetesAPI
 }
 
+// Client returns the Kubernetes client for a vcluster, or for the host cluster if vcluster is "" or "host".
+// Client 返回 vcluster 的 Kubernetes 客户端; vcluster 为 "" 或 "host" 时返回宿主机集群客户端。
+func (c *K8sDataCollector) Client(vcluster string) (kubernetes.Interface, bool) {
+	if vcluster == "" {
+		vcluster = "host"
+	}
+	client, found := c.clients[vcluster]
+	return client, found
+}
+
 // Collect gathers data from the Kubernetes API.
 // Collect 从 Kubernetes API 收集数据。
 // Options should include "resourceType" (e.g., "Pod", "Node", "Event") and optionally "vcluster" name, "namespace", "name", etc.
@@ -156,7 +165,6 @@ func (c *K8sDataCollector) Collect(ctx context.Context, options map[string]inter
 
 	vclusterName, _ := options["vcluster"].(string) // Optional: specific vcluster / 可选: 特定 vcluster
 	namespace, _ := options["namespace"].(string)   // Optional: specific namespace / 可选: 特定命名空间
-	name, _ := options["name"].(string)             // Optional: specific resource name / 可选: 特定资源名称
 
 	clientsToUse := c.clients // Default to all clients / 默认使用所有客户端
 	if vclusterName != "" {
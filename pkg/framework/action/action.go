@@ -3,12 +3,12 @@ package action
 import (
 	"context"
 	"fmt"
-	"github.com/uber-go/zap"
+	"go.uber.org/zap"
 	"sync"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 )
 
 // Package action defines the interface for remediation actions and a registry.
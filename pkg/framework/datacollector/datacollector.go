@@ -3,12 +3,11 @@ package datacollector
 import (
 	"context"
 	"fmt"
-	"github.com/uber-go/zap"
+	"go.uber.org/zap"
 	"sync"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/enum"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
-	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 )
 
 // Package datacollector defines the interface for data collection components and a registry.
@@ -0,0 +1,117 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// Package embedding defines the interface for components computing text embeddings for the
+// knowledge base, and the batching, dimension checks and caching shared by all of them.
+// 包 embedding 定义了为知识库计算文本 embedding 的组件接口，以及它们共用的批处理、维度检查和缓存。
+
+// Backend computes embeddings with a model, e.g. through the API of an LLM provider.
+// Backend 使用模型计算 embedding，例如通过 LLM 提供商的 API。
+type Backend interface {
+	// Name returns the name of the backend instance.
+	// Name 返回后端实例的名称。
+	Name() string
+
+	// Embed returns one vector per text, in the order of the texts.
+	// Embed 为每个文本返回一个向量，顺序与文本一致。
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Embedder is the interface knowledge bases use to compute embeddings.
+// Embedder 是知识库用于计算 embedding 的接口。
+type Embedder interface {
+	Backend
+
+	// Dimension returns the dimension of the vectors, or 0 while it is not known yet.
+	// Dimension 返回向量的维度; 尚未知晓时返回 0。
+	Dimension() int
+}
+
+// BackendFactory creates a backend with the given name from a provider configuration.
+// BackendFactory 根据提供商配置创建具有给定名称的后端。
+type BackendFactory func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (Backend, error)
+
+// Global factory registry keyed by provider type.
+// 以提供商类型为键的全局工厂注册表。
+var (
+	factoriesMu      sync.RWMutex
+	backendFactories = make(map[string]BackendFactory)
+)
+
+// RegisterBackendFactory registers the embedding backend factory of a provider type (e.g., "openai").
+// RegisterBackendFactory 注册某一提供商类型 (例如 "openai") 的 embedding 后端工厂。
+// Provider packages call it from init(). It panics if the type is already registered.
+// 提供商包在 init() 中调用此函数。如果该类型已被注册，则会 panic。
+func RegisterBackendFactory(providerType string, factory BackendFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if _, exists := backendFactories[providerType]; exists {
+		panic(fmt.Sprintf("embedding backend factory for type '%s' already registered", providerType))
+	}
+	backendFactories[providerType] = factory
+}
+
+// NewEmbedder creates the embedder described by the embedding configuration.
+// NewEmbedder 根据 embedding 配置创建 embedder。
+// The provider is "hashing" or the type or instance name of an LLM provider, by default the
+// configured LLM provider; the connection settings of the LLM provider with that name are used
+// unless the embedding configuration sets them. The configured model is used as the provider's
+// embedding model.
+// 提供商为 "hashing"，或某个 LLM 提供商的类型或实例名称，默认为配置的 LLM 提供商; 除非 embedding
+// 配置中已设置，否则使用同名 LLM 提供商的连接设置。配置的模型将作为提供商的 embedding 模型。
+func NewEmbedder(cfg types.EmbeddingConfig, llmCfg types.LLMConfig) (*Pipeline, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = llmCfg.Provider
+	}
+	if cfg.Provider == constants.EmbeddingProviderHashing {
+		return NewPipeline(NewHashingEmbedder(cfg.Dimension), cfg), nil
+	}
+	if cfg.Provider == "" {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "embedding provider not configured", "")
+	}
+
+	providerCfg, ok := llmCfg.ProviderConfig(cfg.Provider)
+	if !ok {
+		providerCfg = types.LLMProviderConfig{Type: cfg.Provider}
+	}
+	if cfg.URL != "" {
+		providerCfg.URL = cfg.URL
+	}
+	if cfg.APIKey != "" {
+		providerCfg.APIKey = cfg.APIKey
+	}
+	if cfg.Model != "" {
+		providerCfg.EmbeddingModel = cfg.Model
+	}
+	if providerCfg.Model == "" {
+		providerCfg.Model = providerCfg.EmbeddingModel
+	}
+
+	factoriesMu.RLock()
+	factory, found := backendFactories[providerCfg.Type]
+	factoriesMu.RUnlock()
+	if !found {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "unsupported embedding provider type", fmt.Sprintf("embedding provider '%s' has type '%s', which cannot compute embeddings", cfg.Provider, providerCfg.Type))
+	}
+
+	timeout := llmCfg.Timeout
+	if timeout <= 0 {
+		timeout = constants.DefaultLLMTimeout * time.Second
+	}
+	backend, err := factory(cfg.Provider, &providerCfg, timeout)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to create embedding provider", err, cfg.Provider)
+	}
+	return NewPipeline(backend, cfg), nil
+}
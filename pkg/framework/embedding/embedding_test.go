@@ -0,0 +1,109 @@
+package embedding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// testProviderType is registered once for the package's tests; the factory records its arguments.
+const testProviderType = "embedding-test"
+
+var created struct {
+	name    string
+	cfg     types.LLMProviderConfig
+	timeout time.Duration
+}
+
+func init() {
+	RegisterBackendFactory(testProviderType, func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (Backend, error) {
+		created.name, created.cfg, created.timeout = name, *cfg, timeout
+		if cfg.Model == "broken" {
+			return nil, errors.New(errors.ErrorCodeInvalidInput, "broken", "")
+		}
+		return &fakeBackend{dimension: 4}, nil
+	})
+}
+
+func TestNewEmbedder(t *testing.T) {
+	llmCfg := types.LLMConfig{
+		Provider: "primary",
+		Timeout:  5 * time.Second,
+		Providers: map[string]types.LLMProviderConfig{
+			"primary":  {Type: testProviderType, URL: "http://llm:8080", APIKey: "llm-key", Model: "chat-model"},
+			"embedder": {Type: testProviderType, URL: "http://embed:8080", Model: "chat-model"},
+			"broken":   {Type: testProviderType, Model: "broken"},
+			"chat":     {Type: "chat-only"},
+		},
+	}
+	for _, tc := range []struct {
+		name     string
+		cfg      types.EmbeddingConfig
+		llmCfg   types.LLMConfig
+		wantName string
+		wantCfg  types.LLMProviderConfig
+		wantCode errors.ErrorCode
+	}{
+		{
+			name:     "defaults to the LLM provider",
+			cfg:      types.EmbeddingConfig{Model: "embed-model"},
+			llmCfg:   llmCfg,
+			wantName: "primary",
+			wantCfg:  types.LLMProviderConfig{Type: testProviderType, URL: "http://llm:8080", APIKey: "llm-key", Model: "chat-model", EmbeddingModel: "embed-model"},
+		},
+		{
+			name:     "overrides the connection settings",
+			cfg:      types.EmbeddingConfig{Provider: "embedder", URL: "http://other:9090", APIKey: "embed-key"},
+			llmCfg:   llmCfg,
+			wantName: "embedder",
+			wantCfg:  types.LLMProviderConfig{Type: testProviderType, URL: "http://other:9090", APIKey: "embed-key", Model: "chat-model"},
+		},
+		{
+			name:     "provider type without an LLM provider",
+			cfg:      types.EmbeddingConfig{Provider: testProviderType, Model: "embed-model"},
+			wantName: testProviderType,
+			wantCfg:  types.LLMProviderConfig{Type: testProviderType, Model: "embed-model", EmbeddingModel: "embed-model"},
+		},
+		{name: "no provider", wantCode: errors.ErrorCodeInvalidInput},
+		{name: "provider without embeddings", cfg: types.EmbeddingConfig{Provider: "chat"}, llmCfg: llmCfg, wantCode: errors.ErrorCodeInvalidInput},
+		{name: "factory error", cfg: types.EmbeddingConfig{Provider: "broken"}, llmCfg: llmCfg, wantCode: errors.ErrorCodeLLMProviderError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pipeline, err := NewEmbedder(tc.cfg, tc.llmCfg)
+			if tc.wantCode != "" {
+				if !errors.IsErrorCode(err, tc.wantCode) {
+					t.Fatalf("err = %v, want code %s", err, tc.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pipeline.Name() != "fake" || created.name != tc.wantName {
+				t.Errorf("created %q as %q, want %q", pipeline.Name(), created.name, tc.wantName)
+			}
+			if created.cfg.Type != tc.wantCfg.Type || created.cfg.URL != tc.wantCfg.URL || created.cfg.APIKey != tc.wantCfg.APIKey ||
+				created.cfg.Model != tc.wantCfg.Model || created.cfg.EmbeddingModel != tc.wantCfg.EmbeddingModel {
+				t.Errorf("provider config = %+v, want %+v", created.cfg, tc.wantCfg)
+			}
+		})
+	}
+
+	if _, err := NewEmbedder(types.EmbeddingConfig{Provider: testProviderType, Model: "m"}, types.LLMConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if created.timeout != constants.DefaultLLMTimeout*time.Second {
+		t.Errorf("timeout = %s, want the default", created.timeout)
+	}
+
+	hashing, err := NewEmbedder(types.EmbeddingConfig{Provider: constants.EmbeddingProviderHashing, Dimension: 64}, llmCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashing.Name() != constants.EmbeddingProviderHashing || hashing.Dimension() != 64 {
+		t.Errorf("hashing embedder %q with dimension %d", hashing.Name(), hashing.Dimension())
+	}
+}
@@ -0,0 +1,102 @@
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"unicode"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+)
+
+// HashingEmbedder computes deterministic embeddings without a model: every word, and every pair of
+// adjacent Han characters, is hashed to a signed position of the vector. Texts sharing words get
+// similar vectors, which is enough for tests and for keyword-like retrieval on air-gapped sites.
+// HashingEmbedder 无需模型即可计算确定性的 embedding: 每个词以及每对相邻汉字都被哈希到向量中带符号的位置。
+// 共享词语的文本会得到相似的向量，这足以用于测试以及离线站点中类似关键词的检索。
+type HashingEmbedder struct {
+	dimension int
+}
+
+// Ensure HashingEmbedder implements the Embedder interface.
+// 确保 HashingEmbedder 实现了 Embedder 接口。
+var _ Embedder = &HashingEmbedder{}
+
+// NewHashingEmbedder creates a new HashingEmbedder with the given dimension; 0 means the default.
+// NewHashingEmbedder 创建一个具有给定维度的新 HashingEmbedder; 0 表示使用默认值。
+func NewHashingEmbedder(dimension int) *HashingEmbedder {
+	if dimension <= 0 {
+		dimension = constants.DefaultHashingEmbeddingDimension
+	}
+	return &HashingEmbedder{dimension: dimension}
+}
+
+// Name returns the name of the embedder.
+// Name 返回 embedder 的名称。
+func (h *HashingEmbedder) Name() string {
+	return constants.EmbeddingProviderHashing
+}
+
+// Dimension returns the dimension of the vectors.
+// Dimension 返回向量的维度。
+func (h *HashingEmbedder) Dimension() int {
+	return h.dimension
+}
+
+// Embed returns the hashed embeddings of the texts. A text without words gets a zero vector.
+// Embed 返回文本的哈希 embedding。没有词语的文本得到零向量。
+func (h *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, h.dimension)
+		for _, feature := range features(text) {
+			hasher := fnv.New64a()
+			_, _ = hasher.Write([]byte(feature))
+			sum := hasher.Sum64()
+			// The top bit chooses the sign, so that unrelated features cancel out on average.
+			// 最高位决定符号，使无关特征平均而言相互抵消。
+			if sum>>63 == 1 {
+				vector[sum%uint64(h.dimension)]--
+			} else {
+				vector[sum%uint64(h.dimension)]++
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// features returns the lower-case words of a text, and the pairs of adjacent Han characters,
+// which stand in for words in Chinese text.
+// features 返回文本的小写词语以及相邻汉字对，后者在中文文本中充当词语。
+func features(text string) []string {
+	var out []string
+	var word []rune
+	var previousHan rune
+	flush := func() {
+		if len(word) > 0 {
+			out = append(out, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if previousHan != 0 {
+				out = append(out, string([]rune{previousHan, r}))
+			} else {
+				out = append(out, string(r))
+			}
+			previousHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		previousHan = 0
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,79 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+)
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func TestFeatures(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []string
+	}{
+		{text: "OOMKilled: exit-code 137", want: []string{"oomkilled", "exit", "code", "137"}},
+		{text: "镜像拉取失败", want: []string{"镜", "镜像", "像拉", "拉取", "取失", "失败"}},
+		{text: "pod 崩溃 again", want: []string{"pod", "崩", "崩溃", "again"}},
+		{text: " -- ", want: nil},
+	} {
+		if got := features(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("features(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestHashingEmbedder(t *testing.T) {
+	if got := NewHashingEmbedder(0).Dimension(); got != constants.DefaultHashingEmbeddingDimension {
+		t.Errorf("default dimension = %d, want %d", got, constants.DefaultHashingEmbeddingDimension)
+	}
+
+	embedder := NewHashingEmbedder(256)
+	texts := []string{
+		"pod web-0 was OOMKilled, raise the memory limit",
+		"Pod web-0 was OOMKilled; raise the memory limit",
+		"container OOMKilled because the memory limit is too low",
+		"certificate of the ingress expired",
+		"",
+	}
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, vector := range vectors {
+		if len(vector) != 256 {
+			t.Fatalf("vector %d has dimension %d", i, len(vector))
+		}
+	}
+	if !reflect.DeepEqual(vectors[0], vectors[1]) {
+		t.Error("case and punctuation changed the embedding")
+	}
+	if related, unrelated := cosine(vectors[0], vectors[2]), cosine(vectors[0], vectors[3]); related <= unrelated {
+		t.Errorf("related texts are not closer: %.3f <= %.3f", related, unrelated)
+	}
+	for _, v := range vectors[4] {
+		if v != 0 {
+			t.Fatal("a text without words must get a zero vector")
+		}
+	}
+
+	again, _ := NewHashingEmbedder(256).Embed(context.Background(), texts[:1])
+	if !reflect.DeepEqual(again[0], vectors[0]) {
+		t.Error("embeddings are not deterministic")
+	}
+}
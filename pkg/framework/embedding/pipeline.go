@@ -0,0 +1,210 @@
+package embedding
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"go.uber.org/zap"
+)
+
+// Pipeline wraps a backend: texts are sent in batches, every vector is checked against the
+// dimension, and embeddings are cached by the hash of their text.
+// Pipeline 封装一个后端: 文本分批发送，每个向量都会校验维度，embedding 按文本哈希缓存。
+type Pipeline struct {
+	backend   Backend
+	model     string
+	batchSize int
+	cacheSize int
+
+	mu        sync.Mutex
+	dimension int                                 // Configured or first observed dimension / 配置的或首次观察到的维度
+	cache     map[[sha256.Size]byte]*list.Element // Cached embeddings by content hash / 按内容哈希缓存的 embedding
+	lru       *list.List                          // Cache entries, most recently used first / 缓存条目，最近使用的在前
+}
+
+// cacheEntry is a cached embedding.
+// cacheEntry 是一个缓存的 embedding。
+type cacheEntry struct {
+	key    [sha256.Size]byte
+	vector []float32
+}
+
+// Ensure Pipeline implements the Embedder interface.
+// 确保 Pipeline 实现了 Embedder 接口。
+var _ Embedder = &Pipeline{}
+
+// NewPipeline creates a new Pipeline around the backend, configured by the embedding configuration.
+// NewPipeline 围绕后端创建一个新的 Pipeline，由 embedding 配置进行配置。
+func NewPipeline(backend Backend, cfg types.EmbeddingConfig) *Pipeline {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = constants.DefaultEmbeddingBatchSize
+	}
+	cacheSize := cfg.CacheSize
+	if cacheSize == 0 {
+		cacheSize = constants.DefaultEmbeddingCacheSize
+	}
+	return &Pipeline{
+		backend:   backend,
+		model:     cfg.Model,
+		batchSize: batchSize,
+		cacheSize: cacheSize,
+		dimension: cfg.Dimension,
+		cache:     make(map[[sha256.Size]byte]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// Name returns the name of the backend.
+// Name 返回后端的名称。
+func (p *Pipeline) Name() string {
+	return p.backend.Name()
+}
+
+// Dimension returns the configured dimension, or the dimension of the first vector computed, or 0.
+// Dimension 返回配置的维度，或第一个计算出的向量的维度，或 0。
+func (p *Pipeline) Dimension() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dimension
+}
+
+// Embed returns the embeddings of the texts. Cached texts and repeated texts are not sent to the
+// backend again; the others are sent in batches.
+// Embed 返回文本的 embedding。已缓存的文本和重复的文本不会再次发送给后端; 其余文本分批发送。
+func (p *Pipeline) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	keys := make([][sha256.Size]byte, len(texts))
+	pending := make(map[[sha256.Size]byte][]int) // Texts to compute, by content hash / 待计算的文本，按内容哈希
+	var missing []string
+
+	p.mu.Lock()
+	for i, text := range texts {
+		keys[i] = sha256.Sum256([]byte(text))
+		if vector, ok := p.cachedLocked(keys[i]); ok {
+			vectors[i] = vector
+			continue
+		}
+		if _, ok := pending[keys[i]]; !ok {
+			missing = append(missing, text)
+		}
+		pending[keys[i]] = append(pending[keys[i]], i)
+	}
+	p.mu.Unlock()
+
+	for start := 0; start < len(missing); start += p.batchSize {
+		end := start + p.batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch := missing[start:end]
+		computed, err := p.embedBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(computed) != len(batch) {
+			return nil, errors.New(errors.ErrorCodeLLMProviderError, "unexpected number of embeddings", fmt.Sprintf("requested %d, received %d", len(batch), len(computed)))
+		}
+
+		p.mu.Lock()
+		for i, vector := range computed {
+			if err := p.checkDimensionLocked(vector); err != nil {
+				p.mu.Unlock()
+				return nil, err
+			}
+			key := sha256.Sum256([]byte(batch[i]))
+			p.storeLocked(key, vector)
+			for _, index := range pending[key] {
+				vectors[index] = vector
+			}
+		}
+		p.mu.Unlock()
+	}
+
+	log.LWithContext(ctx).Debug("Computed embeddings", zap.String("embedder", p.Name()), zap.Int("texts", len(texts)), zap.Int("computed", len(missing)))
+	return vectors, nil
+}
+
+// embedBatch sends a batch to the backend. Calls to a remote backend count towards the budget of the
+// diagnosis run they are made for, if any.
+// embedBatch 将一批文本发送给后端。对远程后端的调用计入其所属诊断运行 (如有) 的预算。
+func (p *Pipeline) embedBatch(ctx context.Context, batch []string) ([][]float32, error) {
+	if _, local := p.backend.(*HashingEmbedder); local {
+		return p.backend.Embed(ctx, batch)
+	}
+
+	reservation := llm.ReservationFrom(ctx)
+	estimator := llm.EstimatorFor(nil, p.model)
+	estimate := types.LLMCall{Provider: p.backend.Name(), Model: p.model, Estimated: true}
+	for _, text := range batch {
+		estimate.PromptTokens += estimator.EstimateTokens(text)
+	}
+	if err := reservation.Acquire(estimate); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	computed, err := p.backend.Embed(ctx, batch)
+	call := estimate
+	call.Latency = time.Since(start)
+	if err != nil {
+		call = types.LLMCall{Provider: estimate.Provider, Model: estimate.Model, Latency: call.Latency, Error: err.Error()}
+	}
+	reservation.Release(estimate, call)
+	return computed, err
+}
+
+// checkDimensionLocked checks a vector against the dimension, which the first vector sets if none
+// is configured. The caller must hold p.mu.
+// checkDimensionLocked 根据维度检查向量; 未配置维度时由第一个向量确定。调用方必须持有 p.mu。
+func (p *Pipeline) checkDimensionLocked(vector []float32) error {
+	if len(vector) == 0 {
+		return errors.New(errors.ErrorCodeLLMProviderError, "empty embedding", p.Name())
+	}
+	if p.dimension == 0 {
+		p.dimension = len(vector)
+	}
+	if len(vector) != p.dimension {
+		return errors.New(errors.ErrorCodeLLMProviderError, "embedding dimension mismatch", fmt.Sprintf("%s returned dimension %d, expected %d", p.Name(), len(vector), p.dimension))
+	}
+	return nil
+}
+
+// cachedLocked returns the cached embedding of a content hash. The caller must hold p.mu.
+// cachedLocked 返回某个内容哈希的缓存 embedding。调用方必须持有 p.mu。
+func (p *Pipeline) cachedLocked(key [sha256.Size]byte) ([]float32, bool) {
+	element, ok := p.cache[key]
+	if !ok {
+		return nil, false
+	}
+	p.lru.MoveToFront(element)
+	return element.Value.(*cacheEntry).vector, true
+}
+
+// storeLocked caches an embedding, evicting the least recently used one when the cache is full.
+// The caller must hold p.mu.
+// storeLocked 缓存一个 embedding，缓存已满时驱逐最久未使用的条目。调用方必须持有 p.mu。
+func (p *Pipeline) storeLocked(key [sha256.Size]byte, vector []float32) {
+	if p.cacheSize < 0 {
+		return
+	}
+	if element, ok := p.cache[key]; ok {
+		element.Value.(*cacheEntry).vector = vector
+		p.lru.MoveToFront(element)
+		return
+	}
+	if p.lru.Len() >= p.cacheSize {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.cache, oldest.Value.(*cacheEntry).key)
+	}
+	p.cache[key] = p.lru.PushFront(&cacheEntry{key: key, vector: vector})
+}
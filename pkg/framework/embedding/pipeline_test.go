@@ -0,0 +1,188 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+// fakeBackend returns vectors of the configured dimension whose first value is the text length,
+// and records the batches it was sent.
+type fakeBackend struct {
+	dimension int
+	vectors   [][]float32 // Returned as is instead of computed vectors, if set
+	err       error
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (f *fakeBackend) Name() string { return "fake" }
+
+func (f *fakeBackend) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	f.batches = append(f.batches, append([]string(nil), texts...))
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.vectors != nil {
+		return f.vectors, nil
+	}
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = make([]float32, f.dimension)
+		out[i][0] = float32(len(text))
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, batch := range f.batches {
+		texts = append(texts, batch...)
+	}
+	return texts
+}
+
+func TestPipelineBatchesAndDeduplicates(t *testing.T) {
+	backend := &fakeBackend{dimension: 3}
+	pipeline := NewPipeline(backend, types.EmbeddingConfig{BatchSize: 2})
+
+	vectors, err := pipeline.Embed(context.Background(), []string{"a", "bb", "a", "ccc", "dddd", "bb", "eeeee"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batchSizes []int
+	for _, batch := range backend.batches {
+		batchSizes = append(batchSizes, len(batch))
+	}
+	if !reflect.DeepEqual(batchSizes, []int{2, 2, 1}) {
+		t.Errorf("batch sizes = %v, want [2 2 1]", batchSizes)
+	}
+	for i, want := range []float32{1, 2, 1, 3, 4, 2, 5} {
+		if vectors[i][0] != want {
+			t.Errorf("vector %d = %v, want the embedding of a text of length %v", i, vectors[i], want)
+		}
+	}
+	if pipeline.Dimension() != 3 {
+		t.Errorf("dimension = %d, want the observed 3", pipeline.Dimension())
+	}
+}
+
+func TestPipelineCache(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		cacheSize int
+		calls     [][]string // Texts embedded one call after another
+		wantSent  []string   // Texts that reached the backend
+	}{
+		{
+			name:     "cached texts are not sent again",
+			calls:    [][]string{{"a", "b"}, {"b", "a", "c"}},
+			wantSent: []string{"a", "b", "c"},
+		},
+		{
+			name:      "least recently used text is evicted",
+			cacheSize: 2,
+			calls:     [][]string{{"a", "b"}, {"a"}, {"c"}, {"a"}, {"b"}},
+			wantSent:  []string{"a", "b", "c", "b"},
+		},
+		{
+			name:      "disabled cache",
+			cacheSize: -1,
+			calls:     [][]string{{"a"}, {"a"}},
+			wantSent:  []string{"a", "a"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := &fakeBackend{dimension: 2}
+			pipeline := NewPipeline(backend, types.EmbeddingConfig{CacheSize: tc.cacheSize})
+			for _, texts := range tc.calls {
+				if _, err := pipeline.Embed(context.Background(), texts); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := backend.sent(); !reflect.DeepEqual(got, tc.wantSent) {
+				t.Errorf("sent %v, want %v", got, tc.wantSent)
+			}
+		})
+	}
+}
+
+func TestPipelineRejectsBadVectors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     types.EmbeddingConfig
+		backend *fakeBackend
+		texts   []string
+	}{
+		{name: "configured dimension", cfg: types.EmbeddingConfig{Dimension: 3}, backend: &fakeBackend{dimension: 4}, texts: []string{"a"}},
+		{name: "dimension changes", backend: &fakeBackend{vectors: [][]float32{{1, 2}, {1, 2, 3}}}, texts: []string{"a", "b"}},
+		{name: "empty vector", backend: &fakeBackend{vectors: [][]float32{{}}}, texts: []string{"a"}},
+		{name: "missing vectors", backend: &fakeBackend{vectors: [][]float32{{1}}}, texts: []string{"a", "b"}},
+		{name: "backend error", backend: &fakeBackend{err: errors.New(errors.ErrorCodeLLMProviderError, "unavailable", "")}, texts: []string{"a"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pipeline := NewPipeline(tc.backend, tc.cfg)
+			if _, err := pipeline.Embed(context.Background(), tc.texts); !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) {
+				t.Fatalf("err = %v, want an LLM provider error", err)
+			}
+			// The rejected vector must not be cached.
+			tc.backend.vectors, tc.backend.err = nil, nil
+			tc.backend.batches = nil
+			tc.backend.dimension = pipeline.Dimension()
+			if tc.backend.dimension == 0 {
+				tc.backend.dimension = 2
+			}
+			if _, err := pipeline.Embed(context.Background(), tc.texts[len(tc.texts)-1:]); err != nil {
+				t.Fatal(err)
+			}
+			if len(tc.backend.batches) != 1 {
+				t.Errorf("a rejected embedding was cached")
+			}
+		})
+	}
+}
+
+func TestPipelineChargesTheRunBudget(t *testing.T) {
+	meter := llm.NewUsageMeter(types.LLMConfig{Budget: types.LLMBudgetConfig{LLMBudgetLimit: types.LLMBudgetLimit{DailyTokens: 10}}})
+	now := time.Now()
+	reservation, err := meter.Reserve(now, []string{"team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := llm.WithReservation(context.Background(), reservation)
+
+	backend := &fakeBackend{dimension: 2}
+	pipeline := NewPipeline(backend, types.EmbeddingConfig{Model: "text-embedding-3-small", BatchSize: 1})
+	if _, err := pipeline.Embed(ctx, []string{"short"}); err != nil {
+		t.Fatal(err)
+	}
+	long := fmt.Sprintf("%0200d", 0)
+	if _, err := pipeline.Embed(ctx, []string{long}); !errors.IsErrorCode(err, errors.ErrorCodeBudgetExceeded) {
+		t.Fatalf("err = %v, want the budget to be exceeded", err)
+	}
+	if got := backend.sent(); !reflect.DeepEqual(got, []string{"short"}) {
+		t.Errorf("sent %v; a call over budget must not reach the backend", got)
+	}
+	reservation.Settle()
+	if day := meter.Day(now); day.Total.Calls != 1 || day.Total.PromptTokens == 0 {
+		t.Errorf("usage %+v, want the first call to be recorded", day.Total)
+	}
+
+	// The local hashing embedder costs nothing and is never charged.
+	hashing := NewPipeline(NewHashingEmbedder(8), types.EmbeddingConfig{})
+	if _, err := hashing.Embed(ctx, []string{long}); err != nil {
+		t.Errorf("hashing embedder was charged: %v", err)
+	}
+}
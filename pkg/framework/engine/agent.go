@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/prompt"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+	"go.uber.org/zap"
+)
+
+// runAgent lets the LLM call read-only tools before it gives its final diagnosis.
+// runAgent 允许 LLM 在给出最终诊断之前调用只读工具。
+// The conversation is sent as messages: the prompt, then each call of the LLM followed by its result.
+// 对话以消息形式发送: 先是提示，然后是 LLM 的每次调用及其结果。
+// The loop ends when the LLM answers without requesting a tool. After the configured number of
+// tool calls the tools are withdrawn from the prompt and the LLM is asked to answer.
+// 当 LLM 的回答不再请求工具时循环结束。达到配置的工具调用次数后，提示中将不再提供工具，并要求 LLM 直接回答。
+func (e *SREAgentEngine) runAgent(ctx context.Context, logger *zap.Logger, provider llm.LLM, session *redact.Session, data *prompt.DiagnosisData, diagnosisPrompt string, budget prompt.Budget, interaction *types.LLMInteractionDetails) (string, error) {
+	cfg := e.config.Diagnosis.Agent
+	maxSteps := cfg.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = constants.DefaultAgentMaxSteps
+	}
+	maxOutput := cfg.MaxToolOutputChars
+	if maxOutput <= 0 {
+		maxOutput = constants.DefaultAgentMaxToolOutputChars
+	}
+
+	// Tools the incident's scope refuses outright are not offered.
+	// 不提供事件范围直接拒绝的工具。
+	scope := tool.ScopeFrom(ctx)
+	var tools []tool.Tool
+	for _, t := range e.tools {
+		if scope.Offers(t) {
+			tools = append(tools, t)
+		}
+	}
+
+	interaction.Strategy = DiagnosisStrategyAgentic
+	agent := &prompt.AgentData{
+		Language:  data.Language,
+		Cluster:   data.Cluster,
+		Diagnosis: diagnosisPrompt,
+		Tools:     tools,
+	}
+	// Providers with native tool calling also receive the tools as definitions; the JSON protocol
+	// described in the prompt keeps working for all other providers.
+	// 支持原生工具调用的提供商还会收到工具定义; 提示中描述的 JSON 协议对其他提供商仍然有效。
+	var definitions []llm.ToolDefinition
+	if _, ok := provider.(llm.ChatLLM); ok {
+		for _, t := range tools {
+			definitions = append(definitions, tool.Definition(t))
+		}
+	}
+
+	for {
+		agent.RemainingSteps = maxSteps - len(interaction.ToolCalls)
+		fit, err := e.prompts.FitAgent(agent, budget)
+		if err != nil {
+			return "", err
+		}
+		req := &llm.ChatRequest{Messages: fit.Messages}
+		if agent.RemainingSteps > 0 {
+			req.Tools = definitions
+		}
+		interaction.Prompt = fit.Prompt
+		interaction.EstimatedPromptTokens = fit.Tokens
+		resp, err := e.chatLLM(ctx, provider, req, interaction)
+		if err != nil {
+			interaction.Response = ""
+			return "", err
+		}
+		response := resp.Message.Content
+		interaction.Response = response
+
+		call, isCall := tool.CallFromMessage(resp.Message)
+		if !isCall {
+			logger.Debug("LLM answered after tool use", zap.Int("toolCalls", len(interaction.ToolCalls)))
+			return response, nil
+		}
+		if agent.RemainingSteps <= 0 {
+			logger.Warn("LLM requested a tool after the step limit, using its response as the answer", zap.String("tool", call.Tool))
+			return response, nil
+		}
+
+		record := e.invokeTool(ctx, session, call, len(interaction.ToolCalls)+1, maxOutput)
+		interaction.ToolCalls = append(interaction.ToolCalls, record)
+		logger.Info("LLM tool call", zap.Int("step", record.Step), zap.String("tool", record.Tool), zap.Any("arguments", record.Arguments), zap.Duration("duration", record.Duration), zap.String("error", record.Error))
+
+		callJSON, _ := json.Marshal(call)
+		result := record.Result
+		if record.Error != "" {
+			result = "Error: " + record.Error
+		}
+		step := prompt.AgentStep{Index: record.Step, Call: string(callJSON), Result: result}
+		if len(resp.Message.ToolCalls) > 0 {
+			// Only the first native call is run, so only it is replayed with its result.
+			// 只运行第一个原生调用，因此只回放该调用及其结果。
+			native := resp.Message.ToolCalls[0]
+			step.ToolCall = &native
+		}
+		agent.Steps = append(agent.Steps, step)
+	}
+}
+
+// invokeTool runs a tool requested by the LLM and records the call.
+// invokeTool 运行 LLM 请求的工具并记录调用。
+// Only the tools offered in the prompt can be called, and only within the scope of the incident;
+// tool errors are reported back to the LLM rather than aborting the diagnosis. Placeholders in the arguments are rehydrated before the
+// call and the result is redacted before it is returned to the LLM.
+// 只能调用提示中提供的工具，且只能在事件范围内调用; 工具错误会反馈给 LLM，而不会中止诊断。
+// 调用前会还原参数中的占位符，结果在返回给 LLM 之前会被脱敏。
+func (e *SREAgentEngine) invokeTool(ctx context.Context, session *redact.Session, call *tool.Call, step int, maxOutput int) types.ToolCallRecord {
+	record := types.ToolCallRecord{Step: step, Tool: call.Tool, Arguments: call.Arguments}
+
+	var selected tool.Tool
+	for _, t := range e.tools {
+		if t.Name() == call.Tool {
+			selected = t
+			break
+		}
+	}
+	if selected == nil {
+		record.Error = errors.New(errors.ErrorCodeNotFound, "unknown tool", call.Tool).Error()
+		return record
+	}
+
+	arguments := make(map[string]interface{}, len(call.Arguments))
+	values := session.Values()
+	for key, value := range call.Arguments {
+		if text, ok := value.(string); ok {
+			value = redact.Rehydrate(text, values)
+		}
+		arguments[key] = value
+	}
+
+	// The LLM chooses the arguments, so they are checked against the incident before the call.
+	// 参数由 LLM 选择，因此在调用前根据事件范围进行检查。
+	if err := tool.ScopeFrom(ctx).Check(selected, arguments); err != nil {
+		record.Error = session.RedactString(err.Error())
+		return record
+	}
+
+	start := time.Now()
+	result, err := selected.Invoke(ctx, arguments)
+	record.Duration = time.Since(start)
+	if err != nil {
+		record.Error = session.RedactString(err.Error())
+		return record
+	}
+	record.Result = prompt.TruncateMiddle(session.RedactString(result), maxOutput)
+	return record
+}
@@ -0,0 +1,423 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// DiagnosisCache caches diagnosis results keyed by the fingerprints of the diagnosed issues.
+// DiagnosisCache 以被诊断问题的指纹为键缓存诊断结果。
+// Each entry also remembers a hash of the evidence it was produced from, so an entry is
+// invalidated as soon as the evidence for the same set of issues materially changes.
+// 每个条目还会记录生成它时所用证据的哈希，因此当同一组问题的证据发生实质变化时，条目会立即失效。
+type DiagnosisCache struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*diagnosisCacheEntry
+	mu         sync.Mutex
+}
+
+// diagnosisCacheEntry is a single cached diagnosis result.
+// diagnosisCacheEntry 是单个缓存的诊断结果。
+type diagnosisCacheEntry struct {
+	evidenceHash string
+	result       *types.DiagnosisResult
+	storedAt     time.Time
+}
+
+// NewDiagnosisCache creates a new DiagnosisCache from configuration.
+// NewDiagnosisCache 根据配置创建一个新的 DiagnosisCache。
+// It returns nil if the cache is disabled, which callers treat as "no caching".
+// 如果缓存被禁用则返回 nil，调用方将其视为 "不缓存"。
+func NewDiagnosisCache(cfg *types.DiagnosisCacheConfig) *DiagnosisCache {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = constants.DefaultDiagnosisCacheTTL * time.Second
+	}
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = constants.DefaultDiagnosisCacheMaxEntries
+	}
+	return &DiagnosisCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*diagnosisCacheEntry),
+	}
+}
+
+// Get returns a copy of the cached diagnosis for the key, marked as cached.
+// Get 返回该键对应的缓存诊断的副本，并标记为已缓存。
+// Expired entries and entries whose evidence hash differs are reported as a miss; they stay
+// available to Stale until they are replaced or evicted.
+// 过期条目和证据哈希不一致的条目视为未命中; 在被替换或驱逐之前，它们仍可通过 Stale 获取。
+func (c *DiagnosisCache) Get(key, evidenceHash string) (*types.DiagnosisResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+	if time.Since(entry.storedAt) > c.ttl || entry.evidenceHash != evidenceHash {
+		return nil, false
+	}
+	return entry.copyResult(), true
+}
+
+// Stale returns a copy of the last diagnosis stored for the key, even if it expired or its
+// evidence changed. It is used when the LLM must not be called, e.g. because a budget is used up.
+// Stale 返回该键最近一次存储的诊断的副本，即使其已过期或证据已变化。它用于不得调用 LLM 的场景，
+// 例如预算已用尽。
+func (c *DiagnosisCache) Stale(key string) (*types.DiagnosisResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+	return entry.copyResult(), true
+}
+
+// copyResult returns a deep copy of the entry's result, marked as cached.
+// copyResult 返回条目结果的深拷贝，并标记为已缓存。
+func (entry *diagnosisCacheEntry) copyResult() *types.DiagnosisResult {
+	result := cloneDiagnosis(entry.result)
+	result.Cached = true
+	result.CachedAt = entry.storedAt
+	return result
+}
+
+// Put stores a deep copy of a diagnosis result under the key together with its evidence hash, so
+// that later changes by the caller do not leak into cache hits.
+// Put 将诊断结果的深拷贝及其证据哈希存储在该键下，使调用方之后的修改不会泄漏到缓存命中中。
+// When the cache is full the oldest entry is evicted.
+// 缓存已满时驱逐最旧的条目。
+func (c *DiagnosisCache) Put(key, evidenceHash string, result *types.DiagnosisResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		c.evictOldestLocked()
+	}
+	c.entries[key] = &diagnosisCacheEntry{
+		evidenceHash: evidenceHash,
+		result:       cloneDiagnosis(result),
+		storedAt:     time.Now(),
+	}
+}
+
+// cloneDiagnosis returns a deep copy of a diagnosis result: no slice, map or pointer is shared
+// with the original.
+// cloneDiagnosis 返回诊断结果的深拷贝: 不与原结果共享任何切片、map 或指针。
+func cloneDiagnosis(original *types.DiagnosisResult) *types.DiagnosisResult {
+	if original == nil {
+		return nil
+	}
+	result := *original
+	if original.Issues != nil {
+		result.Issues = make([]types.Issue, len(original.Issues))
+		for i, issue := range original.Issues {
+			if issue.Resource != nil {
+				resource := *issue.Resource
+				issue.Resource = &resource
+			}
+			issue.Context = cloneValue(issue.Context).(map[string]interface{})
+			issue.Analyzers = cloneStrings(issue.Analyzers)
+			result.Issues[i] = issue
+		}
+	}
+	if original.Suggestions != nil {
+		result.Suggestions = make([]types.RemediationSuggestion, len(original.Suggestions))
+		for i, suggestion := range original.Suggestions {
+			suggestion.Payload = cloneValue(suggestion.Payload).(map[string]interface{})
+			suggestion.ConfidenceFactors = cloneConfidenceFactors(suggestion.ConfidenceFactors)
+			result.Suggestions[i] = suggestion
+		}
+	}
+	if original.KnowledgeBaseHits != nil {
+		result.KnowledgeBaseHits = make([]types.KnowledgeBaseHit, len(original.KnowledgeBaseHits))
+		for i, hit := range original.KnowledgeBaseHits {
+			hit.Metadata = cloneStringMap(hit.Metadata)
+			hit.IssueIDs = cloneStrings(hit.IssueIDs)
+			result.KnowledgeBaseHits[i] = hit
+		}
+	}
+	if original.LLMInteraction != nil {
+		interaction := *original.LLMInteraction
+		if interaction.Stages != nil {
+			interaction.Stages = make([]types.LLMStage, len(original.LLMInteraction.Stages))
+			for i, stage := range original.LLMInteraction.Stages {
+				stage.IssueIDs = cloneStrings(stage.IssueIDs)
+				interaction.Stages[i] = stage
+			}
+		}
+		if interaction.ToolCalls != nil {
+			interaction.ToolCalls = make([]types.ToolCallRecord, len(original.LLMInteraction.ToolCalls))
+			for i, call := range original.LLMInteraction.ToolCalls {
+				call.Arguments = cloneValue(call.Arguments).(map[string]interface{})
+				interaction.ToolCalls[i] = call
+			}
+		}
+		interaction.RoutingRules = cloneStrings(interaction.RoutingRules)
+		interaction.FailedAttempts = append([]types.LLMAttempt(nil), interaction.FailedAttempts...)
+		interaction.Calls = append([]types.LLMCall(nil), interaction.Calls...)
+		interaction.Samples = cloneStrings(interaction.Samples)
+		result.LLMInteraction = &interaction
+	}
+	result.RedactedValues = cloneStringMap(original.RedactedValues)
+	result.InjectionFindings = append([]types.InjectionFinding(nil), original.InjectionFindings...)
+	result.RootCauseConfidenceFactors = cloneConfidenceFactors(original.RootCauseConfidenceFactors)
+	return &result
+}
+
+// cloneConfidenceFactors returns a deep copy of confidence factors.
+// cloneConfidenceFactors 返回置信度因素的深拷贝。
+func cloneConfidenceFactors(factors *types.ConfidenceFactors) *types.ConfidenceFactors {
+	if factors == nil {
+		return nil
+	}
+	clone := *factors
+	clone.UnknownResources = cloneStrings(factors.UnknownResources)
+	return &clone
+}
+
+// cloneValue returns a deep copy of a JSON-like value (maps, slices and scalars). A nil map
+// stays a nil map of the same type.
+// cloneValue 返回类 JSON 值 (map、切片和标量) 的深拷贝。nil map 仍为同类型的 nil map。
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneValue(item)
+		}
+		return clone
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	case map[string]string:
+		return cloneStringMap(v)
+	case []string:
+		return cloneStrings(v)
+	default:
+		return value
+	}
+}
+
+// cloneStringMap returns a copy of a string map, or nil for a nil map.
+// cloneStringMap 返回字符串 map 的副本; nil map 返回 nil。
+func cloneStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
+
+// cloneStrings returns a copy of a string slice, or nil for a nil slice.
+// cloneStrings 返回字符串切片的副本; nil 切片返回 nil。
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+// Invalidate removes the cached diagnosis for the key, if any.
+// Invalidate 删除该键对应的缓存诊断 (如果存在)。
+func (c *DiagnosisCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Len returns the number of cached diagnoses.
+// Len 返回缓存的诊断数量。
+func (c *DiagnosisCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evictOldestLocked removes the oldest entry. The caller must hold c.mu.
+// evictOldestLocked 删除最旧的条目。调用方必须持有 c.mu。
+func (c *DiagnosisCache) evictOldestLocked() {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if oldestKey == "" || entry.storedAt.Before(oldest) {
+			oldestKey = key
+			oldest = entry.storedAt
+		}
+	}
+	if oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// volatilePatterns match the parts of (lower-cased) evidence that change between runs without the
+// incident changing: timestamps, ages and explicitly named restart or event counters. Other numbers,
+// such as exit codes, memory limits, HTTP statuses or replica counts, are part of the evidence.
+// volatilePatterns 匹配 (已转为小写的) 证据中在事件本身未变化时也会随运行变化的部分: 时间戳、时长和明确命名的重启或事件计数。
+// 其他数字 (例如退出码、内存限制、HTTP 状态码或副本数) 是证据的一部分。
+var volatilePatterns = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	// Dates and timestamps, e.g. 2026-10-18t14:38:34.123z / 日期和时间戳
+	{regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}(?:[t ]\d{2}:\d{2}(?::\d{2}(?:[.,]\d+)?)?(?:z|[+-]\d{2}:?\d{2})?)?`), "#"},
+	// Times of day, e.g. 14:38:34 / 一天中的时刻
+	{regexp.MustCompile(`\b\d{1,2}:\d{2}:\d{2}(?:[.,]\d+)?\b`), "#"},
+	// Unix timestamps in seconds or milliseconds / 以秒或毫秒表示的 Unix 时间戳
+	{regexp.MustCompile(`\b1\d{9}(?:\d{3})?\b`), "#"},
+	// Go durations ending in seconds, e.g. back-off 5m0s, 1h2m3s, 250ms / 以秒结尾的 Go 时长
+	{regexp.MustCompile(`\b(?:\d+h)?(?:\d+m)?\d+(?:\.\d+)?(?:ms|s)\b`), "#"},
+	// Ages, e.g. 5m ago, 3 hours ago / 时长，例如 5m ago、3 hours ago
+	{regexp.MustCompile(`\b\d+(?:\.\d+)?\s*(?:[mhd]|mins?|minutes?|hours?|days?)\s+ago\b`), "# ago"},
+	// Ages after a keyword, e.g. (x5 over 10m), age: 3d / 关键词之后的时长
+	{regexp.MustCompile(`\b(over|age|for|after|since)(\W{1,3})\d+(?:\.\d+)?[mhd]\b`), "${1}${2}#"},
+	// Event counters, e.g. (x5 over 10m) / 事件计数
+	{regexp.MustCompile(`\(x\d+ over\b`), "(x# over"},
+	// Restart counters, e.g. restarted 5 times, restarts: 5 / 重启计数
+	{regexp.MustCompile(`\brestarted\s+\d+\s+times\b`), "restarted # times"},
+	{regexp.MustCompile(`\b(restarts?|restartcount)(\W{1,3})\d+\b`), "${1}${2}#"},
+}
+
+// volatileKey matches context keys whose values are timestamps, ages or counters, e.g. restartCount,
+// lastTimestamp, lastTransitionTime or startedAt; such values are dropped from the evidence entirely.
+// volatileKey 匹配其值为时间戳、时长或计数的上下文键，例如 restartCount、lastTimestamp、lastTransitionTime 或
+// startedAt; 这些值会从证据中完全去除。
+var volatileKey = regexp.MustCompile(`^(?i:age|restartcount|restarts|eventcount|resourceversion|.*timestamps?|.*(?:transition|probe|update|heartbeat|event|start|finish)time|(?:first|last)seen)$|^[a-z]\w*At$`)
+
+// normalizeVolatile replaces the volatile parts of lower-cased text.
+// normalizeVolatile 替换已转为小写的文本中的易变部分。
+func normalizeVolatile(text string) string {
+	for _, p := range volatilePatterns {
+		text = p.re.ReplaceAllString(text, p.replacement)
+	}
+	return text
+}
+
+// normalizeContext returns a copy of a decoded JSON value with the values of volatile keys dropped
+// and the volatile parts of strings replaced.
+// normalizeContext 返回已解码 JSON 值的副本，其中易变键的值被去除，字符串中的易变部分被替换。
+func normalizeContext(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if volatileKey.MatchString(key) {
+				out[key] = "#"
+				continue
+			}
+			out[key] = normalizeContext(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalizeContext(item)
+		}
+		return out
+	case string:
+		return normalizeVolatile(strings.ToLower(v))
+	default:
+		return value
+	}
+}
+
+// IssueFingerprint returns a stable identifier for an issue that does not depend on
+// per-run values such as the issue ID or detection timestamp.
+// IssueFingerprint 返回问题的稳定标识符，不依赖于问题 ID 或检测时间戳等每次运行都会变化的值。
+func IssueFingerprint(issue types.Issue) string {
+	parts := []string{issue.Name}
+	if issue.Resource != nil {
+		parts = append(parts, issue.Resource.VCluster, issue.Resource.Type, issue.Resource.Namespace, issue.Resource.Name)
+	}
+	return hashStrings(parts...)
+}
+
+// issueEvidence returns a normalized representation of the evidence attached to an issue.
+// issueEvidence 返回问题所附证据的规范化表示。
+// Timestamps, ages and restart or event counters are collapsed so that they do not count as material
+// changes; any other change of the evidence, such as another exit code or memory limit, does.
+// 时间戳、时长以及重启或事件计数会被折叠，因此不会被视为实质变化; 证据的其他任何变化 (例如不同的退出码或内存限制) 都会。
+func issueEvidence(issue types.Issue) string {
+	var context interface{}
+	if contextJSON, err := json.Marshal(issue.Context); err == nil {
+		_ = json.Unmarshal(contextJSON, &context)
+	}
+	contextJSON, err := json.Marshal(normalizeContext(context)) // Map keys are marshalled in sorted order / Map 键按排序顺序序列化
+	if err != nil {
+		contextJSON = nil
+	}
+	message := normalizeVolatile(strings.ToLower(issue.Message))
+	evidence := strings.Join([]string{issue.Severity.String(), message, string(contextJSON)}, "\n")
+	return strings.Join(strings.Fields(evidence), " ")
+}
+
+// DiagnosisCacheKey computes the cache key and evidence hash for a set of issues.
+// DiagnosisCacheKey 计算一组问题的缓存键和证据哈希。
+// The key is derived from the sorted issue fingerprints, so the order in which analyzers
+// report issues does not matter.
+// 键由排序后的问题指纹计算得出，因此分析器报告问题的顺序无关紧要。
+func DiagnosisCacheKey(issues []types.Issue) (key string, evidenceHash string) {
+	type fingerprinted struct {
+		fingerprint string
+		evidence    string
+	}
+	items := make([]fingerprinted, 0, len(issues))
+	for _, issue := range issues {
+		items = append(items, fingerprinted{fingerprint: IssueFingerprint(issue), evidence: issueEvidence(issue)})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].fingerprint != items[j].fingerprint {
+			return items[i].fingerprint < items[j].fingerprint
+		}
+		return items[i].evidence < items[j].evidence
+	})
+
+	fingerprints := make([]string, 0, len(items))
+	evidence := make([]string, 0, len(items))
+	for _, item := range items {
+		fingerprints = append(fingerprints, item.fingerprint)
+		evidence = append(evidence, item.evidence)
+	}
+	return hashStrings(fingerprints...), hashStrings(evidence...)
+}
+
+// hashStrings returns the hex-encoded SHA-256 of the given strings joined by a separator.
+// hashStrings 返回以分隔符连接的给定字符串的十六进制 SHA-256 值。
+func hashStrings(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
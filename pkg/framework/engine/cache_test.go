@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+func TestDiagnosisCacheIsolatesResults(t *testing.T) {
+	cache := NewDiagnosisCache(&types.DiagnosisCacheConfig{Enabled: true})
+	stored := &types.DiagnosisResult{
+		RootCause:      "image pull failed",
+		RedactedValues: map[string]string{"[REDACTED:IP:1]": "10.0.0.1"},
+		Suggestions: []types.RemediationSuggestion{{
+			Description: "fix the image tag",
+			Payload:     map[string]interface{}{"image": "app:v1", "args": []interface{}{"a"}},
+		}},
+		KnowledgeBaseHits: []types.KnowledgeBaseHit{{ID: "kb-1", Metadata: map[string]string{"vcluster": "team-a"}}},
+	}
+	cache.Put("key", "evidence", stored)
+
+	// Changes by the caller after Put must not reach the cache.
+	stored.RedactedValues["[REDACTED:IP:1]"] = "changed"
+	stored.Suggestions[0].Payload["image"] = "changed"
+	stored.KnowledgeBaseHits[0].Metadata["vcluster"] = "changed"
+
+	first, hit := cache.Get("key", "evidence")
+	if !hit {
+		t.Fatal("expected a cache hit")
+	}
+	if got := first.RedactedValues["[REDACTED:IP:1]"]; got != "10.0.0.1" {
+		t.Errorf("RedactedValues leaked from the caller: %q", got)
+	}
+	if got := first.Suggestions[0].Payload["image"]; got != "app:v1" {
+		t.Errorf("suggestion payload leaked from the caller: %v", got)
+	}
+	if got := first.KnowledgeBaseHits[0].Metadata["vcluster"]; got != "team-a" {
+		t.Errorf("hit metadata leaked from the caller: %q", got)
+	}
+	if !first.Cached {
+		t.Error("expected the hit to be marked as cached")
+	}
+
+	// Changes to one hit must not reach the next one.
+	first.RedactedValues["[REDACTED:IP:1]"] = "changed"
+	first.Suggestions[0].Payload["args"].([]interface{})[0] = "changed"
+	second, _ := cache.Get("key", "evidence")
+	if got := second.RedactedValues["[REDACTED:IP:1]"]; got != "10.0.0.1" {
+		t.Errorf("RedactedValues leaked between hits: %q", got)
+	}
+	if got := second.Suggestions[0].Payload["args"].([]interface{})[0]; got != "a" {
+		t.Errorf("nested payload leaked between hits: %v", got)
+	}
+}
+
+func TestDiagnosisCacheEvidenceChangeIsMiss(t *testing.T) {
+	cache := NewDiagnosisCache(&types.DiagnosisCacheConfig{Enabled: true})
+	cache.Put("key", "evidence", &types.DiagnosisResult{RootCause: "oom"})
+
+	if _, hit := cache.Get("key", "other-evidence"); hit {
+		t.Fatal("expected a miss when the evidence changed")
+	}
+	stale, found := cache.Stale("key")
+	if !found || stale.RootCause != "oom" {
+		t.Fatalf("expected the stale entry, got %v, %v", stale, found)
+	}
+}
+
+func TestDiagnosisCacheKeyEvidence(t *testing.T) {
+	oomIssue := func(message string, context map[string]interface{}) types.Issue {
+		return types.Issue{
+			ID:       "issue-1",
+			Name:     "PodCrashLoopBackOff",
+			Message:  message,
+			Context:  context,
+			Resource: &types.IssueResource{Type: "Pod", VCluster: "team-a", Namespace: "web", Name: "web-0"},
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		before types.Issue
+		after  types.Issue
+		same   bool
+	}{
+		{
+			name:   "restart counters and ages",
+			before: oomIssue("Back-off 5m0s restarting failed container (x3 over 10m), restarted 3 times", map[string]interface{}{"restartCount": 3, "exitCode": 137}),
+			after:  oomIssue("Back-off 2m40s restarting failed container (x12 over 2h), restarted 12 times", map[string]interface{}{"restartCount": 12, "exitCode": 137}),
+			same:   true,
+		},
+		{
+			name:   "timestamps",
+			before: oomIssue("Last state terminated at 2026-10-18T14:38:34Z", map[string]interface{}{"lastTimestamp": "2026-10-18T14:38:34Z", "startedAt": "2026-10-18T14:30:00Z", "reason": "OOMKilled"}),
+			after:  oomIssue("Last state terminated at 2026-10-18T15:02:11.512Z", map[string]interface{}{"lastTimestamp": "2026-10-18T15:02:11Z", "startedAt": "2026-10-18T15:00:00Z", "reason": "OOMKilled"}),
+			same:   true,
+		},
+		{
+			name:   "issue IDs",
+			before: oomIssue("container app was OOMKilled", nil),
+			after:  func() types.Issue { i := oomIssue("container app was OOMKilled", nil); i.ID = "issue-2"; return i }(),
+			same:   true,
+		},
+		{
+			name:   "exit code",
+			before: oomIssue("container app terminated with exit code 137", map[string]interface{}{"exitCode": 137}),
+			after:  oomIssue("container app terminated with exit code 1", map[string]interface{}{"exitCode": 1}),
+		},
+		{
+			name:   "exit code in the message only",
+			before: oomIssue("container app terminated with exit code 137", nil),
+			after:  oomIssue("container app terminated with exit code 1", nil),
+		},
+		{
+			name:   "memory limit",
+			before: oomIssue("container app was OOMKilled", map[string]interface{}{"limits": map[string]interface{}{"memory": "512Mi"}}),
+			after:  oomIssue("container app was OOMKilled", map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}}),
+		},
+		{
+			name:   "image tag",
+			before: oomIssue(`Failed to pull image "app:v1"`, nil),
+			after:  oomIssue(`Failed to pull image "app:v2"`, nil),
+		},
+		{
+			name:   "http status",
+			before: oomIssue("readiness probe failed: HTTP probe failed with statuscode: 500", nil),
+			after:  oomIssue("readiness probe failed: HTTP probe failed with statuscode: 503", nil),
+		},
+		{
+			name:   "replica count",
+			before: oomIssue("deployment web has 3 ready replicas", map[string]interface{}{"replicas": 3, "count": 3}),
+			after:  oomIssue("deployment web has 5 ready replicas", map[string]interface{}{"replicas": 5, "count": 5}),
+		},
+		{
+			name:   "replica count in a generic count field",
+			before: oomIssue("container app was OOMKilled", map[string]interface{}{"count": 3}),
+			after:  oomIssue("container app was OOMKilled", map[string]interface{}{"count": 5}),
+		},
+		{
+			name:   "architecture note",
+			before: oomIssue("image app:v1 was built for x86", nil),
+			after:  oomIssue("image app:v1 was built for x64", nil),
+		},
+		{
+			name:   "cpu limit in millicores",
+			before: oomIssue("container app is throttled at a limit of 500m", nil),
+			after:  oomIssue("container app is throttled at a limit of 250m", nil),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			beforeKey, beforeEvidence := DiagnosisCacheKey([]types.Issue{tc.before})
+			afterKey, afterEvidence := DiagnosisCacheKey([]types.Issue{tc.after})
+			if beforeKey != afterKey {
+				t.Fatal("the cache key must not depend on the evidence")
+			}
+			if same := beforeEvidence == afterEvidence; same != tc.same {
+				t.Errorf("evidence unchanged = %v, want %v:\n%s\n%s", same, tc.same, issueEvidence(tc.before), issueEvidence(tc.after))
+			}
+
+			cache := NewDiagnosisCache(&types.DiagnosisCacheConfig{Enabled: true})
+			cache.Put(beforeKey, beforeEvidence, &types.DiagnosisResult{RootCause: "cached"})
+			if _, hit := cache.Get(afterKey, afterEvidence); hit != tc.same {
+				t.Errorf("cache hit = %v, want %v", hit, tc.same)
+			}
+		})
+	}
+}
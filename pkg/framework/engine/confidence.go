@@ -0,0 +1,338 @@
+package engine
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+	"go.uber.org/zap"
+)
+
+// noEvidence marks a confidence signal that has no evidence and is left out of the mean.
+// noEvidence 标记没有证据、不计入平均值的置信度信号。
+const noEvidence = -1
+
+// parsedDiagnosis is an LLM answer split into its root cause and suggestions.
+// parsedDiagnosis 是拆分为根因和建议的 LLM 回答。
+type parsedDiagnosis struct {
+	rootCause   string
+	suggestions []string
+}
+
+// sampleDiagnoses asks the LLM for the additional diagnoses of the final prompt used for agreement
+// voting. Samples that fail, request a tool or cannot be parsed are left out.
+// sampleDiagnoses 针对最终提示向 LLM 请求用于一致性投票的额外诊断。失败、请求工具或无法解析的样本会被忽略。
+func (e *SREAgentEngine) sampleDiagnoses(ctx context.Context, logger *zap.Logger, provider llm.LLM, interaction *types.LLMInteractionDetails) []parsedDiagnosis {
+	cfg := e.config.Diagnosis.Confidence
+	if cfg.Samples <= 1 || interaction.Prompt == "" {
+		return nil
+	}
+	temperature := cfg.Temperature
+	if temperature <= 0 {
+		temperature = constants.DefaultConfidenceSampleTemperature
+	}
+
+	// The samples must not change what the interaction reports about the answer itself.
+	// 采样不得改变交互记录中关于回答本身的信息。
+	finishReason, answeredBy := interaction.FinishReason, interaction.AnsweredBy
+	defer func() { interaction.FinishReason, interaction.AnsweredBy = finishReason, answeredBy }()
+
+	var samples []parsedDiagnosis
+	for i := 1; i < cfg.Samples; i++ {
+		resp, err := e.chatLLM(ctx, provider, &llm.ChatRequest{
+			Messages:    []llm.Message{{Role: llm.RoleUser, Content: interaction.Prompt}},
+			Temperature: &temperature,
+		}, interaction)
+		if errors.IsErrorCode(err, errors.ErrorCodeBudgetExceeded) {
+			logger.Info("Budget does not cover more samples, scoring with the samples taken so far", zap.Int("sample", i+1), zap.Error(err))
+			break
+		}
+		if err != nil {
+			logger.Warn("Sampling an additional diagnosis failed", zap.Int("sample", i+1), zap.Error(err))
+			continue
+		}
+		interaction.Samples = append(interaction.Samples, resp.Message.Content)
+		if _, isCall := tool.CallFromMessage(resp.Message); isCall {
+			continue
+		}
+		rootCause, suggestions, err := parseLLMDiagnosisResponse(resp.Message.Content)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, parsedDiagnosis{rootCause: rootCause, suggestions: suggestions})
+	}
+	logger.Debug("Sampled additional diagnoses", zap.Int("requested", cfg.Samples-1), zap.Int("usable", len(samples)))
+	return samples
+}
+
+// confidenceScorer derives the confidence of root causes and suggestions from the evidence of a diagnosis.
+// confidenceScorer 根据诊断的证据得出根因和建议的置信度。
+type confidenceScorer struct {
+	cfg       types.ConfidenceConfig
+	weights   types.ConfidenceWeights
+	issues    []types.Issue
+	snapshot  string
+	hits      []types.KnowledgeBaseHit
+	samples   []parsedDiagnosis
+	injection bool
+}
+
+// newConfidenceScorer creates a scorer for the issues, knowledge base hits and sampled diagnoses
+// that were shown to or produced by the LLM.
+// newConfidenceScorer 为展示给 LLM 或由 LLM 生成的问题、知识库命中和采样诊断创建评分器。
+func newConfidenceScorer(cfg types.ConfidenceConfig, issues []types.Issue, hits []types.KnowledgeBaseHit, samples []parsedDiagnosis, injection bool) *confidenceScorer {
+	weights := cfg.Weights
+	if weights == (types.ConfidenceWeights{}) {
+		weights = types.ConfidenceWeights{
+			Agreement: constants.DefaultConfidenceWeightAgreement,
+			Grounding: constants.DefaultConfidenceWeightGrounding,
+			Knowledge: constants.DefaultConfidenceWeightKnowledge,
+			Analyzer:  constants.DefaultConfidenceWeightAnalyzer,
+		}
+	}
+	if cfg.Similarity <= 0 {
+		cfg.Similarity = constants.DefaultConfidenceSimilarity
+	}
+	return &confidenceScorer{
+		cfg:       cfg,
+		weights:   weights,
+		issues:    issues,
+		snapshot:  SnapshotText(issues),
+		hits:      hits,
+		samples:   samples,
+		injection: injection,
+	}
+}
+
+// rootCause returns the confidence in a root cause.
+// rootCause 返回对根因的置信度。
+func (s *confidenceScorer) rootCause(text string) (float64, *types.ConfidenceFactors) {
+	factors := s.common(text)
+	if len(s.samples) > 0 {
+		agreeing := 1
+		for _, sample := range s.samples {
+			if similarity(text, sample.rootCause) >= s.cfg.Similarity {
+				agreeing++
+			}
+		}
+		factors.Agreement = float64(agreeing) / float64(len(s.samples)+1)
+	}
+
+	// The root cause agrees with the analyzers as far as it names the resources or issues they reported.
+	// 根因在多大程度上提及了分析器报告的资源或问题，就在多大程度上与分析器一致。
+	reported, named := 0, 0
+	for _, issue := range s.issues {
+		if len(issue.Analyzers) == 0 {
+			continue
+		}
+		reported++
+		if mentionsIssue(text, issue) {
+			named++
+		}
+	}
+	if reported > 0 {
+		factors.Analyzer = float64(named) / float64(reported)
+	}
+	return s.combine(factors), factors
+}
+
+// suggestion returns the confidence in a suggestion of the diagnosis and the ID of the analyzer
+// finding it refers to, if any. A suggestion that names no finding inherits the analyzer agreement
+// of the root cause it remedies.
+// suggestion 返回对诊断中某条建议的置信度，以及它所指向的分析器发现的 ID (如有)。
+// 未提及任何发现的建议继承其所处置根因的分析器一致性。
+func (s *confidenceScorer) suggestion(text string, rootCause *types.ConfidenceFactors) (float64, *types.ConfidenceFactors, string) {
+	factors := s.common(text)
+	if len(s.samples) > 0 {
+		agreeing := 1
+		for _, sample := range s.samples {
+			for _, other := range sample.suggestions {
+				if similarity(text, other) >= s.cfg.Similarity {
+					agreeing++
+					break
+				}
+			}
+		}
+		factors.Agreement = float64(agreeing) / float64(len(s.samples)+1)
+	}
+
+	issueID := ""
+	for _, issue := range s.issues {
+		if len(issue.Analyzers) > 0 && issue.Resource != nil && issue.Resource.Name != "" && strings.Contains(strings.ToLower(text), strings.ToLower(issue.Resource.Name)) {
+			issueID = issue.ID
+			break
+		}
+	}
+	if issueID != "" {
+		factors.Analyzer = 1
+	} else if rootCause != nil {
+		factors.Analyzer = rootCause.Analyzer
+	}
+	return s.combine(factors), factors, issueID
+}
+
+// common computes the signals that are scored the same way for root causes and suggestions.
+// common 计算对根因和建议以相同方式评分的信号。
+func (s *confidenceScorer) common(text string) *types.ConfidenceFactors {
+	factors := &types.ConfidenceFactors{
+		Agreement: noEvidence,
+		Samples:   len(s.samples) + 1,
+		Grounding: noEvidence,
+		Knowledge: noEvidence,
+		Analyzer:  noEvidence,
+		Injection: s.injection,
+	}
+
+	if refs := CitedResources(text); len(refs) > 0 {
+		known := 0
+		for _, ref := range refs {
+			if strings.Contains(s.snapshot, ResourceName(ref)) {
+				known++
+			} else {
+				factors.UnknownResources = append(factors.UnknownResources, ref)
+			}
+		}
+		factors.Grounding = float64(known) / float64(len(refs))
+	}
+
+	if len(s.hits) > 0 {
+		words := wordSet(text)
+		factors.Knowledge = 0
+		for _, hit := range s.hits {
+			if overlap(words, wordSet(hit.Content)) >= constants.ConfidenceKnowledgeOverlap && clamp(hit.Score) > factors.Knowledge {
+				factors.Knowledge = clamp(hit.Score)
+			}
+		}
+	}
+	return factors
+}
+
+// combine returns the weighted mean of the signals that have evidence, lowered on suspected injection.
+// The default confidence takes part in the mean as a prior, so that a single signal cannot make
+// an answer certain.
+// combine 返回具备证据的信号的加权平均值，疑似注入时降低。默认置信度作为先验参与平均，
+// 使单个信号无法让回答变得确定。
+func (s *confidenceScorer) combine(f *types.ConfidenceFactors) float64 {
+	sum, weight := constants.DefaultConfidence*constants.ConfidencePriorWeight, constants.ConfidencePriorWeight
+	for _, signal := range []struct{ value, weight float64 }{
+		{f.Agreement, s.weights.Agreement},
+		{f.Grounding, s.weights.Grounding},
+		{f.Knowledge, s.weights.Knowledge},
+		{f.Analyzer, s.weights.Analyzer},
+	} {
+		if signal.value == noEvidence || signal.weight <= 0 {
+			continue
+		}
+		sum += signal.value * signal.weight
+		weight += signal.weight
+	}
+	confidence := sum / weight
+	if f.Injection {
+		confidence *= constants.InjectionConfidenceFactor
+	}
+	return confidence
+}
+
+// mentionsIssue reports whether the text names the issue's resource or the issue itself.
+// mentionsIssue 报告文本是否提及问题的资源或问题本身。
+func mentionsIssue(text string, issue types.Issue) bool {
+	lower := strings.ToLower(text)
+	if issue.Resource != nil && issue.Resource.Name != "" && strings.Contains(lower, strings.ToLower(issue.Resource.Name)) {
+		return true
+	}
+	return issue.Name != "" && strings.Contains(lower, strings.ToLower(issue.Name))
+}
+
+// clamp limits a knowledge base score to 0-1.
+// clamp 将知识库分数限制在 0-1 之间。
+func clamp(score float64) float64 {
+	switch {
+	case score < 0:
+		return 0
+	case score > 1:
+		return 1
+	default:
+		return score
+	}
+}
+
+// wordPattern matches the words compared between answers.
+// wordPattern 匹配回答之间进行比较的词语。
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}._-]*`)
+
+// stopWords are English words that carry no meaning for agreement.
+// stopWords 是对一致性没有意义的英文词语。
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true, "for": true,
+	"from": true, "has": true, "in": true, "is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "which": true, "with": true,
+}
+
+// wordSet returns the lower-cased words of the text. Chinese text has no spaces, so runs of Han
+// characters contribute their character bigrams instead.
+// wordSet 返回文本的小写词语。中文文本没有空格，因此连续的汉字以字符二元组的形式计入。
+func wordSet(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		runes := []rune(word)
+		if unicode.Is(unicode.Han, runes[0]) {
+			if len(runes) == 1 {
+				words[word] = true
+			}
+			for i := 0; i+1 < len(runes); i++ {
+				words[string(runes[i:i+2])] = true
+			}
+			continue
+		}
+		if !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// similarity returns the Jaccard similarity of the words of two texts.
+// similarity 返回两段文本词语的 Jaccard 相似度。
+func similarity(a, b string) float64 {
+	wa, wb := wordSet(a), wordSet(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+// overlap returns the share of the words of an answer that also occur in a document.
+// overlap 返回回答中同样出现在文档中的词语所占比例。
+func overlap(answer, document map[string]bool) float64 {
+	if len(answer) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range answer {
+		if document[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(answer))
+}
+
+// rootCauseHeading and suggestionsHeading find the sections of the answer format requested by the
+// prompt templates, tolerating Markdown emphasis and full-width colons.
+// rootCauseHeading 和 suggestionsHeading 查找提示模板所要求的回答格式中的各部分，允许 Markdown 强调和全角冒号。
+var (
+	rootCauseHeading   = regexp.MustCompile(`(?im)^[\s>#*_-]*root\s*cause[*_]*\s*[:：][*_]*[ \t]*`)
+	suggestionsHeading = regexp.MustCompile(`(?im)^[\s>#*_-]*(?:suggestions|suggested remediation steps|remediation steps)[*_]*\s*[:：][*_]*[ \t]*`)
+	listItem           = regexp.MustCompile(`^\s*(?:\d+[.)、]|[-*•])\s+`)
+)
@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm/llmtest"
+)
+
+func TestSimilarity(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "identical", a: "image tag app:v2 missing", b: "Image tag app:v2 missing", want: 1},
+		{name: "disjoint", a: "image tag missing", b: "node out of disk", want: 0},
+		{name: "stop words are ignored", a: "the image is missing", b: "image missing", want: 1},
+		{name: "partial overlap", a: "image tag missing", b: "image tag wrong", want: 0.5},
+		{name: "chinese bigrams", a: "镜像标签不存在", b: "镜像标签错误", want: 3.0 / 8},
+		{name: "empty", a: "", b: "image", want: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := similarity(tc.a, tc.b); math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("similarity(%q, %q) = %.3f, want %.3f", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfidenceScorerRootCause(t *testing.T) {
+	issues := testAnalysis().Issues
+	unanalyzed := []types.Issue{{ID: "issue-1", Name: "ImagePullBackOff", Resource: issues[0].Resource}}
+	agreeing := "The image tag app:v2 of web-0 does not exist"
+	// prior is the weighted default confidence every mean starts from.
+	prior := constants.DefaultConfidence * constants.ConfidencePriorWeight
+	w := types.ConfidenceWeights{
+		Agreement: constants.DefaultConfidenceWeightAgreement,
+		Grounding: constants.DefaultConfidenceWeightGrounding,
+		Knowledge: constants.DefaultConfidenceWeightKnowledge,
+		Analyzer:  constants.DefaultConfidenceWeightAnalyzer,
+	}
+
+	for _, tc := range []struct {
+		name        string
+		cfg         types.ConfidenceConfig
+		issues      []types.Issue
+		hits        []types.KnowledgeBaseHit
+		samples     []parsedDiagnosis
+		injection   bool
+		text        string
+		want        float64
+		wantFactors types.ConfidenceFactors
+	}{
+		{
+			name:        "no evidence",
+			issues:      unanalyzed,
+			text:        "something broke",
+			want:        constants.DefaultConfidence,
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: -1, Knowledge: -1, Analyzer: -1},
+		},
+		{
+			name:        "names the analyzer finding",
+			issues:      issues,
+			text:        "the registry rejects pulls for web-0",
+			want:        (prior + w.Analyzer) / (constants.ConfidencePriorWeight + w.Analyzer),
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: -1, Knowledge: -1, Analyzer: 1},
+		},
+		{
+			name:        "ignores the analyzer finding",
+			issues:      issues,
+			text:        "the registry is down",
+			want:        prior / (constants.ConfidencePriorWeight + w.Analyzer),
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: -1, Knowledge: -1, Analyzer: 0},
+		},
+		{
+			name:        "cites an existing resource",
+			issues:      issues,
+			text:        "pod/web-0 cannot pull its image",
+			want:        (prior + w.Grounding + w.Analyzer) / (constants.ConfidencePriorWeight + w.Grounding + w.Analyzer),
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: 1, Knowledge: -1, Analyzer: 1},
+		},
+		{
+			name:   "cites a resource that does not exist",
+			issues: issues,
+			text:   "pod/web-9 cannot pull its image",
+			want:   prior / (constants.ConfidencePriorWeight + w.Grounding + w.Analyzer),
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: 0, Knowledge: -1, Analyzer: 0,
+				UnknownResources: []string{"pod/web-9"}},
+		},
+		{
+			name:   "agreement of sampled diagnoses",
+			issues: issues,
+			samples: []parsedDiagnosis{
+				{rootCause: agreeing},
+				{rootCause: "the image tag app:v2 of web-0 is missing from the registry"}, // Rephrased, still agrees
+				{rootCause: "node worker-3 is out of disk"},
+			},
+			text:        agreeing,
+			want:        (prior + 0.75*w.Agreement + w.Analyzer) / (constants.ConfidencePriorWeight + w.Agreement + w.Analyzer),
+			wantFactors: types.ConfidenceFactors{Agreement: 0.75, Samples: 4, Grounding: -1, Knowledge: -1, Analyzer: 1},
+		},
+		{
+			name:   "supporting knowledge",
+			issues: unanalyzed,
+			hits: []types.KnowledgeBaseHit{
+				{Content: "When the registry is down every image pull fails", Score: 0.8},
+				{Content: "Unrelated runbook about certificates", Score: 0.99},
+			},
+			text:        "the registry is down",
+			want:        (prior + 0.8*w.Knowledge) / (constants.ConfidencePriorWeight + w.Knowledge),
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: -1, Knowledge: 0.8, Analyzer: -1},
+		},
+		{
+			name:        "unsupported by the knowledge base",
+			issues:      unanalyzed,
+			hits:        []types.KnowledgeBaseHit{{Content: "Unrelated runbook about certificates", Score: 0.99}},
+			text:        "the registry is down",
+			want:        prior / (constants.ConfidencePriorWeight + w.Knowledge),
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: -1, Knowledge: 0, Analyzer: -1},
+		},
+		{
+			name:        "suspected injection",
+			issues:      issues,
+			injection:   true,
+			text:        "the registry rejects pulls for web-0",
+			want:        constants.InjectionConfidenceFactor * (prior + w.Analyzer) / (constants.ConfidencePriorWeight + w.Analyzer),
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: -1, Knowledge: -1, Analyzer: 1, Injection: true},
+		},
+		{
+			name:        "configured weights",
+			cfg:         types.ConfidenceConfig{Weights: types.ConfidenceWeights{Analyzer: 1}},
+			issues:      issues,
+			text:        "pod/web-0 cannot pull its image",
+			want:        (prior + 1) / (constants.ConfidencePriorWeight + 1),
+			wantFactors: types.ConfidenceFactors{Agreement: -1, Samples: 1, Grounding: 1, Knowledge: -1, Analyzer: 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			scorer := newConfidenceScorer(tc.cfg, tc.issues, tc.hits, tc.samples, tc.injection)
+			got, factors := scorer.rootCause(tc.text)
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("confidence = %.4f, want %.4f", got, tc.want)
+			}
+			if !reflect.DeepEqual(*factors, tc.wantFactors) {
+				t.Errorf("factors = %+v, want %+v", *factors, tc.wantFactors)
+			}
+		})
+	}
+}
+
+func TestConfidenceScorerSuggestion(t *testing.T) {
+	issues := testAnalysis().Issues
+	samples := []parsedDiagnosis{
+		{suggestions: []string{"Check the registry credentials", "Fix the image tag of web-0"}},
+		{suggestions: []string{"Restart the node"}},
+	}
+	scorer := newConfidenceScorer(types.ConfidenceConfig{}, issues, nil, samples, false)
+	_, rootCause := scorer.rootCause("the registry is down")
+
+	for _, tc := range []struct {
+		name          string
+		text          string
+		wantIssue     string
+		wantAgreement float64
+		wantAnalyzer  float64
+	}{
+		{name: "names the finding", text: "Fix the image tag of web-0", wantIssue: "issue-1", wantAgreement: 2.0 / 3, wantAnalyzer: 1},
+		{name: "inherits the root cause", text: "Check the registry credentials", wantAgreement: 2.0 / 3, wantAnalyzer: rootCause.Analyzer},
+		{name: "not sampled again", text: "Scale the registry", wantAgreement: 1.0 / 3, wantAnalyzer: rootCause.Analyzer},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, factors, issueID := scorer.suggestion(tc.text, rootCause)
+			if issueID != tc.wantIssue {
+				t.Errorf("issue = %q, want %q", issueID, tc.wantIssue)
+			}
+			if math.Abs(factors.Agreement-tc.wantAgreement) > 1e-9 || factors.Analyzer != tc.wantAnalyzer {
+				t.Errorf("agreement %.3f, analyzer %.2f; want %.3f, %.2f", factors.Agreement, factors.Analyzer, tc.wantAgreement, tc.wantAnalyzer)
+			}
+		})
+	}
+}
+
+func TestRunDiagnosisSamplesForAgreement(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted",
+		llmtest.Reply{Content: scriptedDiagnosis, Times: 2},
+		llmtest.Reply{Err: errors.New("overloaded"), Times: 1},
+		llmtest.Reply{Content: "Root Cause: Node worker-3 is out of disk.\nSuggestions:\n1. Free disk space on worker-3.", Times: 1},
+	)
+	cfg := &types.Config{Diagnosis: types.DiagnosisConfig{Confidence: types.ConfidenceConfig{Samples: 4}}}
+	diagnosis, err := newTestEngine(t, cfg, provider).RunDiagnosis(context.Background(), testAnalysis())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if provider.Calls() != 4 {
+		t.Fatalf("expected 4 LLM calls, got %d", provider.Calls())
+	}
+	for i, req := range provider.Requests()[1:] {
+		if req.Temperature == nil || *req.Temperature != constants.DefaultConfidenceSampleTemperature {
+			t.Errorf("sample %d temperature = %v, want %v", i+2, req.Temperature, constants.DefaultConfidenceSampleTemperature)
+		}
+	}
+	if got := len(diagnosis.LLMInteraction.Samples); got != 2 {
+		t.Errorf("recorded %d sampled answers, want 2", got)
+	}
+	// The failed sample is left out: the answer and two usable samples vote, two of them agree.
+	factors := diagnosis.RootCauseConfidenceFactors
+	if factors.Samples != 3 || math.Abs(factors.Agreement-2.0/3) > 1e-9 {
+		t.Errorf("samples %d, agreement %.3f; want 3, 0.667", factors.Samples, factors.Agreement)
+	}
+	if diagnosis.LLMInteraction.FinishReason != "stop" {
+		t.Errorf("sampling changed the finish reason to %q", diagnosis.LLMInteraction.FinishReason)
+	}
+}
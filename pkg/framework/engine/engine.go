@@ -3,18 +3,23 @@ package engine
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid" // Using uuid for unique IDs / 使用 uuid 生成唯一 ID
-	"github.com/turtacn/chasi-sreagent/pkg/common/enum"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/action"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/analyzer"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/datacollector"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/prompt"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
 	"go.uber.org/zap"
 )
 
@@ -61,6 +66,24 @@ type SREAgentEngine struct {
 	knowledgeBase  knowledgebase.KnowledgeBase // Optional
 	llmProvider    llm.LLM
 	actions        []action.Action
+	// diagnosisCache caches diagnosis results for recurring issues; nil if disabled.
+	// diagnosisCache 缓存重复出现问题的诊断结果; 禁用时为 nil。
+	diagnosisCache *DiagnosisCache
+	// prompts renders diagnosis prompts from templates.
+	// prompts 使用模板渲染诊断提示。
+	prompts *prompt.TemplateEngine
+	// tools are the read-only tools offered to the LLM; empty if agentic diagnosis is disabled.
+	// tools 是提供给 LLM 的只读工具; 禁用智能体诊断时为空。
+	tools []tool.Tool
+	// router chooses the providers allowed to see each incident's evidence.
+	// router 选择允许查看每个事件证据的提供商。
+	router *llm.Router
+	// redactor replaces secrets and PII in evidence before it leaves the agent; nil if disabled.
+	// redactor 在证据离开代理之前替换其中的机密和个人信息; 禁用时为 nil。
+	redactor *redact.Redactor
+	// usage accounts LLM tokens, latency and cost and enforces the daily budgets.
+	// usage 计量 LLM 的 token、耗时和成本，并执行每日预算。
+	usage *llm.UsageMeter
 	// Potentially add more dependencies like metric clients, notification clients, etc.
 	// 可能添加更多依赖项，例如指标客户端、通知客户端等。
 }
@@ -83,6 +106,10 @@ func NewSREAgentEngine(
 	if llmProvider == nil {
 		return nil, errors.New(errors.ErrorCodeInvalidInput, "LLM provider cannot be nil", "LLM provider is required for diagnosis")
 	}
+	redactor, err := redact.New(&cfg.Redaction)
+	if err != nil {
+		return nil, err
+	}
 
 	engine := &SREAgentEngine{
 		config:         cfg,
@@ -91,9 +118,22 @@ func NewSREAgentEngine(
 		knowledgeBase:  kb,
 		llmProvider:    llmProvider,
 		actions:        actions,
+		diagnosisCache: NewDiagnosisCache(&cfg.Diagnosis.Cache),
+		prompts:        prompt.NewTemplateEngine(&cfg.Diagnosis.Prompt),
+		router:         llm.NewRouter(cfg.LLM, llmProvider),
+		redactor:       redactor,
+		usage:          llm.NewUsageMeter(cfg.LLM),
 	}
 
-	log.L().Info("SRE Agent Engine initialized")
+	if cfg.Diagnosis.Agent.Enabled {
+		tools, err := tool.GetEnabledTools(&cfg.Diagnosis.Agent)
+		if err != nil {
+			return nil, err
+		}
+		engine.tools = tools
+	}
+
+	log.L().Info("SRE Agent Engine initialized", zap.Bool("diagnosisCache", engine.diagnosisCache != nil), zap.Int("diagnosisTools", len(engine.tools)), zap.Bool("redaction", engine.redactor != nil))
 
 	return engine, nil
 }
@@ -138,7 +178,6 @@ func (e *SREAgentEngine) RunAnalysis(ctx context.Context, options map[string]int
 
 	// --- Analysis Execution ---
 	allIssues := []types.Issue{}
-	analyzersRun := []string{}
 
 	for _, analyzer := range e.analyzers {
 		analyzerLogger := logger.With(zap.String("analyzer", analyzer.Name()))
@@ -205,6 +244,7 @@ func (e *SREAgentEngine) RunDiagnosis(ctx context.Context, analysisResult *types
 
 	diagnosis := &types.DiagnosisResult{
 		AnalysisResultID:  analysisResult.ID,
+		Issues:            analysisResult.Issues,
 		Timestamp:         start,
 		Suggestions:       []types.RemediationSuggestion{},
 		KnowledgeBaseHits: []types.KnowledgeBaseHit{},
@@ -217,6 +257,82 @@ func (e *SREAgentEngine) RunDiagnosis(ctx context.Context, analysisResult *types
 		return diagnosis, nil
 	}
 
+	// --- Diagnosis Cache ---
+	// Persistent issues are diagnosed once and served from the cache until the evidence changes or the entry expires.
+	// 持续存在的问题只诊断一次，在证据变化或条目过期之前都从缓存中提供结果。
+	cacheKey, evidenceHash := DiagnosisCacheKey(analysisResult.Issues)
+	if e.diagnosisCache != nil {
+		if cached, hit := e.diagnosisCache.Get(cacheKey, evidenceHash); hit {
+			cached.AnalysisResultID = analysisResult.ID
+			cached.Issues = analysisResult.Issues
+			cached.Timestamp = start
+			cached.Duration = time.Since(start)
+			logger.Info("Serving diagnosis from cache", zap.String("cacheKey", cacheKey), zap.Time("cachedAt", cached.CachedAt))
+			return cached, nil
+		}
+	}
+
+	// --- Budget: degrade to a cached or rule-only diagnosis once the daily budget is used up ---
+	// --- 预算: 每日预算用尽后降级为缓存或仅基于规则的诊断 ---
+	// Every LLM, reranking and embedding call of the run reserves its estimated usage before it is
+	// sent, so that concurrent runs cannot overspend the budget.
+	// 本次运行的每次 LLM、重排序和 embedding 调用在发送前都会预留其估算用量，使并发运行无法超出预算。
+	vclusters := prompt.ClusterContextForIssues(analysisResult.Issues).VClusters
+	reservation, budgetErr := e.usage.Reserve(start, vclusters)
+	if budgetErr != nil {
+		return e.degradedDiagnosis(logger, diagnosis, cacheKey, budgetErr), nil
+	}
+	defer reservation.Settle()
+	ctx = llm.WithReservation(ctx, reservation)
+
+	// --- Redact: secrets and PII never leave the agent ---
+	// --- 脱敏: 机密和个人信息绝不离开代理 ---
+	// Everything sent to the knowledge base or the LLM below is derived from the redacted issues.
+	// The placeholders are kept on the diagnosis so that suggestions can be rehydrated locally.
+	// 下文发送给知识库或 LLM 的所有内容都源自脱敏后的问题。占位符保留在诊断结果中，以便在本地还原建议。
+	session := e.redactor.NewSession()
+	issues := session.RedactIssues(analysisResult.Issues)
+	// Knowledge base hits shown to the reranking LLM are redacted with the same placeholders.
+	// 展示给重排序 LLM 的知识库命中使用相同的占位符进行脱敏。
+	ctx = redact.WithSession(ctx, session)
+
+	// --- Route: choose the providers allowed to see this evidence ---
+	// --- 路由: 选择允许查看这些证据的提供商 ---
+	// Routing comes first: the knowledge base queries below are built from the evidence as well.
+	// 首先进行路由: 下文的知识库查询同样由证据构建。
+	route, routeErr := e.router.Route(ctx, analysisResult.Issues)
+	if routeErr != nil {
+		// Restricted tenants' evidence is never sent to a provider that is not approved for them.
+		// 受限租户的证据绝不会发送给未获其批准的提供商。
+		logger.Warn("LLM diagnosis refused by routing rules", zap.Strings("rules", route.Rules), zap.Error(routeErr))
+		diagnosis.RootCause = "LLM diagnosis was not performed: no LLM provider is approved for the affected tenants."
+		diagnosis.Error = routeErr.Error()
+		diagnosis.LLMInteraction = &types.LLMInteractionDetails{RoutingRules: route.Rules}
+		diagnosis.Duration = time.Since(start)
+		return diagnosis, routeErr
+	}
+
+	// Tool calls are limited to the tenants and resources of the incident; on restricted routes
+	// tools that cannot be limited are disabled.
+	// 工具调用被限制在事件的租户和资源范围内; 在受限路由上，无法限制范围的工具会被禁用。
+	scope := tool.NewScope(analysisResult.Issues)
+	scope.Restricted = route.Restricted
+	ctx = tool.WithScope(ctx, scope)
+
+	// Reranking shows the knowledge base queries, which are built from the evidence, to an LLM too;
+	// on restricted routes it uses the approved providers unless the reranking provider is one of them.
+	// 重排序同样会把由证据构建的知识库查询展示给 LLM; 在受限路由上，除非重排序提供商本身已获批准，否则使用获批准的提供商。
+	if route.Restricted && !containsString(route.Providers, e.config.KnowledgeBase.Retrieval.Rerank.Provider) {
+		ctx = knowledgebase.WithRerankProvider(ctx, route.Provider)
+	}
+	// The queries are also sent to the embedding provider, which must be approved as well.
+	// 查询还会发送给 embedding 提供商，该提供商同样必须获得批准。
+	searchKB := e.knowledgeBase != nil && e.embeddingApproved(route)
+	if e.knowledgeBase != nil && !searchKB {
+		logger.Info("Knowledge base not searched: the embedding provider is not approved by the routing rules", zap.Strings("rules", route.Rules))
+		scope.Disable(constants.ToolSearchKnowledgeBase, "the embedding provider is not approved by the routing rules")
+	}
+
 	// --- Prepare Prompt for LLM ---
 	// This is a crucial step. The prompt needs to include:
 	// - System context (agent's role, environment description - multi-tenant vcluster K8s)
@@ -229,94 +345,83 @@ func (e *SREAgentEngine) RunDiagnosis(ctx context.Context, analysisResult *types
 	// - 从知识库检索到的相关知识 (RAG)
 	// - 对 LLM 的指令 (任务: 根因分析, 建议处置方案; 格式: 期望的输出结构)
 
-	promptBuilder := new(StringBuilder)                                                                                                             // Helper to build prompt / 构建提示的助手
-	promptBuilder.WriteString("You are an AI SRE agent assisting with troubleshooting Kubernetes issues in a multi-tenant vcluster environment.\n") // System role / 系统角色
-	promptBuilder.WriteString("Analyze the following issues detected in the cluster:\n\n")                                                          // Task instruction / 任务指令
-
-	for i, issue := range analysisResult.Issues {
-		promptBuilder.WriteString(fmt.Sprintf("Issue %d:\n", i+1))
-		promptBuilder.WriteString(fmt.Sprintf("  Name: %s\n", issue.Name))
-		promptBuilder.WriteString(fmt.Sprintf("  Severity: %s\n", issue.Severity.String()))
-		promptBuilder.WriteString(fmt.Sprintf("  Message: %s\n", issue.Message))
-		if issue.Resource != nil {
-			promptBuilder.WriteString(fmt.Sprintf("  Resource: Type=%s, Name=%s, Namespace=%s, VCluster=%s\n",
-				issue.Resource.Type, issue.Resource.Name, issue.Resource.Namespace, issue.Resource.VCluster))
-		}
-		// Include other issue context / 包括其他问题上下文
-		promptBuilder.WriteString("\n")
-	}
-
 	// --- RAG: Retrieve relevant knowledge ---
-	// Need to formulate a query based on the issues
-	// 需要根据问题构建查询
-	kbQuery := fmt.Sprintf("Diagnose Kubernetes issues: %v", analysisResult.Issues) // Simplify query for now
+	// One query per group of similar issues, built from their reasons and evidence and scoped to
+	// their vcluster, business service and resource kind.
+	// 每组相似问题一个查询，由其原因和证据构建，并限定在其 vcluster、业务服务和资源类型范围内。
 	var kbHits []types.KnowledgeBaseHit
-	var kbErr error
-	if e.knowledgeBase != nil {
+	if searchKB {
 		logger.Debug("Retrieving knowledge from KB for diagnosis")
-		// Basic query options, refine later
-		// 基本查询选项，后续改进
-		kbHits, kbErr = e.knowledgeBase.Retrieve(ctx, kbQuery, map[string]interface{}{"k": 5}) // Get top 5 hits
+		hits, kbErr := e.retrieveKnowledge(ctx, logger, issues)
 		if kbErr != nil {
 			logger.Error("Failed to retrieve knowledge from KB", zap.Error(kbErr))
 			// Continue diagnosis even if KB retrieval fails
 			// 即使知识库检索失败也继续诊断
 		} else {
-			diagnosis.KnowledgeBaseHits = kbHits
-			if len(kbHits) > 0 {
-				promptBuilder.WriteString("Relevant knowledge from SRE Knowledge Base:\n\n")
-				for _, hit := range kbHits {
-					promptBuilder.WriteString(fmt.Sprintf("Source: %s (Score: %.2f)\n", hit.Source, hit.Score))
-					promptBuilder.WriteString(hit.Content + "\n\n")
-				}
-			}
-			logger.Debug("Knowledge retrieved", zap.Int("hits", len(kbHits)))
+			diagnosis.KnowledgeBaseHits = hits
+			logger.Debug("Knowledge retrieved", zap.Int("hits", len(hits)))
+			kbHits = redactHits(session, hits)
 		}
 	}
 
-	promptBuilder.WriteString("Based on the issues and relevant knowledge, provide:\n")
-	promptBuilder.WriteString("1. A concise root cause analysis.\n")
-	promptBuilder.WriteString("2. Suggested remediation steps.\n")
-	// Instruct LLM on output format (e.g., JSON, Markdown list)
-	// 指导 LLM 输出格式 (例如, JSON, Markdown 列表)
-	promptBuilder.WriteString("Please provide the root cause analysis as a paragraph and the suggestions as a numbered list.\n") // Simple format instruction
-
-	finalPrompt := promptBuilder.String()
-	logger.Debug("Sending prompt to LLM", zap.String("prompt", finalPrompt))
+	// Render the prompt from the template matching the issue category and the vcluster's language.
+	// 根据问题类别和 vcluster 的语言，使用匹配的模板渲染提示。
+	promptData := prompt.NewDiagnosisData("", issues, kbHits)
+	promptData.Language = e.prompts.LanguageFor(promptData.Cluster.VCluster)
+	logger.Debug("Prepared diagnosis prompt data", zap.String("category", promptData.Category), zap.String("language", promptData.Language))
+
+	// Untrusted content is delimited in the prompt; content that still looks like an attempt to
+	// instruct the LLM lowers the confidence of the diagnosis.
+	// 不可信内容在提示中被分隔; 仍然看起来试图向 LLM 下达指令的内容会降低诊断的置信度。
+	diagnosis.InjectionFindings = prompt.ScanDiagnosisData(promptData)
+
+	// The prompt must leave room for the completion inside the model's context window.
+	// 提示必须在模型上下文窗口内为补全内容留出空间。
+	providerCfg, _ := e.config.LLM.ProviderConfig(route.Providers[0])
+	budget := prompt.Budget{
+		MaxTokens: llm.PromptBudgetForProviders(e.config.LLM, route.Providers),
+		Estimate:  llm.EstimatorFor(route.Provider, providerCfg.Model).EstimateTokens,
+	}
 
 	// --- Call LLM ---
 	llmStart := time.Now()
-	llmResponse, llmErr := e.llmProvider.GenerateText(
-		llm.WithTimeout(ctx, e.config.LLM.Timeout), // Use LLM specific timeout
-		finalPrompt,
-		nil, // LLM options, e.g., temperature, max tokens
-	)
-	llmDuration := time.Since(llmStart)
-
 	diagnosis.LLMInteraction = &types.LLMInteractionDetails{
-		Provider: e.llmProvider.Name(),
-		Model:    e.config.LLM.GetEnabledLLMProvider(e.config.LLM).Model, // Need a helper to get model name
-		Prompt:   finalPrompt,
-		Response: llmResponse,
-		// Add latency, token usage if LLM interface provides them
-		// 如果 LLM 接口提供，添加延迟、token 使用量
+		Provider:     route.Provider.Name(),
+		Model:        providerCfg.Model,
+		RoutingRules: route.Rules,
+	}
+	llmResponse, llmErr := e.generateDiagnosis(ctx, logger, route.Provider, session, promptData, budget, diagnosis.LLMInteraction)
+	llmDuration := time.Since(llmStart)
+	diagnosis.Redactions = session.Count()
+	diagnosis.RedactedValues = session.Values()
+	if diagnosis.Redactions > 0 {
+		logger.Info("Redacted evidence sent to the LLM", zap.Int("redactions", diagnosis.Redactions), zap.Strings("kinds", session.Kinds()))
 	}
 
+	if errors.IsErrorCode(llmErr, errors.ErrorCodeBudgetExceeded) {
+		return e.degradedDiagnosis(logger, diagnosis, cacheKey, llmErr), nil
+	}
 	if llmErr != nil {
-		logger.Error("LLM text generation failed", zap.Error(llmErr))
+		logger.Error("LLM diagnosis failed", zap.Error(llmErr))
 		diagnosis.RootCause = "Failed to perform diagnosis due to LLM error."
-		diagnosis.Error = errors.Wrap(errors.ErrorCodeLLMProviderError, "LLM diagnosis failed", llmErr, "").Error()
+		diagnosis.Error = llmErr.Error()
 		diagnosis.Duration = time.Since(start)
-		return diagnosis, diagnosisErr // Return the diagnosis object with partial info and the error
+		return diagnosis, llmErr // Return the diagnosis object with partial info and the error
+	}
+	logger.Debug("LLM response received", zap.Duration("llmDuration", llmDuration), zap.String("strategy", diagnosis.LLMInteraction.Strategy))
+	for _, call := range diagnosis.LLMInteraction.ToolCalls {
+		diagnosis.InjectionFindings = append(diagnosis.InjectionFindings, prompt.ScanText("tool "+call.Tool, call.Result)...)
+	}
+	if len(diagnosis.InjectionFindings) > 0 {
+		logger.Warn("Untrusted evidence looks like a prompt injection, lowering the confidence of LLM suggestions", zap.Any("findings", diagnosis.InjectionFindings))
 	}
-	logger.Debug("LLM response received", zap.Duration("llmDuration", llmDuration))
 
 	// --- Parse LLM Response ---
 	// This requires parsing the LLM's natural language response into structured data.
 	// This can be complex and might need prompt engineering to guide the LLM output format.
 	// 这需要将 LLM 的自然语言响应解析为结构化数据。
 	// 这可能很复杂，并且可能需要提示工程来指导 LLM 输出格式。
-	parsedRootCause, parsedSuggestions, parseErr := parseLLMDiagnosisResponse(llmResponse) // TODO: Implement this parsing function
+	parsedRootCause, parsedSuggestions, parseErr := parseLLMDiagnosisResponse(llmResponse)
 	if parseErr != nil {
 		logger.Error("Failed to parse LLM response", zap.Error(parseErr))
 		// Use raw LLM response or provide a generic error
@@ -326,53 +431,270 @@ func (e *SREAgentEngine) RunDiagnosis(ctx context.Context, analysisResult *types
 		// Still proceed to generate suggestions if parsing is partial
 		// 如果解析是部分的，仍继续生成建议
 	} else {
+		// --- Confidence: derived from sampled agreement, grounding, knowledge and analyzer agreement ---
+		// --- 置信度: 根据采样一致性、资源核实、知识库支持以及与分析器的一致性得出 ---
+		samples := e.sampleDiagnoses(ctx, logger, route.Provider, diagnosis.LLMInteraction)
+		scorer := newConfidenceScorer(e.config.Diagnosis.Confidence, issues, kbHits, samples, len(diagnosis.InjectionFindings) > 0)
 		diagnosis.RootCause = parsedRootCause
+		diagnosis.RootCauseConfidence, diagnosis.RootCauseConfidenceFactors = scorer.rootCause(parsedRootCause)
 		// Convert parsed suggestions into types.RemediationSuggestion
 		// 将解析出的建议转换为 types.RemediationSuggestion
 		for _, s := range parsedSuggestions {
+			confidence, factors, issueID := scorer.suggestion(s, diagnosis.RootCauseConfidenceFactors)
+			if issueID == "" {
+				issueID = "N/A" // The suggestion names no analyzer finding / 建议未提及任何分析器发现
+			}
 			suggestion := types.RemediationSuggestion{
-				IssueID:     "N/A", // LLM might provide suggestions for multiple issues, mapping needs logic
-				Description: s,
-				ActionType:  enum.ActionTypeSuggestion, // Default to suggestion from LLM
-				Confidence:  0.8,                       // Placeholder, maybe LLM can provide confidence?
-				Source:      "LLM",
+				IssueID:           issueID,
+				Description:       s,
+				ActionType:        enum.ActionTypeSuggestion, // Default to suggestion from LLM
+				Confidence:        confidence,
+				ConfidenceFactors: factors,
+				Source:            "LLM",
 			}
 			diagnosis.Suggestions = append(diagnosis.Suggestions, suggestion)
 		}
+		logger.Debug("Scored diagnosis confidence", zap.Float64("rootCause", diagnosis.RootCauseConfidence), zap.Int("samples", len(samples)+1))
 	}
 
 	diagnosis.Duration = time.Since(start)
+	if e.diagnosisCache != nil && diagnosis.Error == "" {
+		e.diagnosisCache.Put(cacheKey, evidenceHash, diagnosis)
+	}
 	logger.Info("Diagnosis run completed", zap.Duration("duration", diagnosis.Duration))
 
 	return diagnosis, nil
 }
 
-// parseLLMDiagnosisResponse is a placeholder for the function that parses the LLM's response.
-// parseLLMDiagnosisResponse 是一个占位符函数，用于解析 LLM 的响应。
-// Its implementation will depend heavily on the expected LLM output format.
-// 它的实现将很大程度上取决于期望的 LLM 输出格式。
+// Diagnosis strategies recorded in LLMInteractionDetails.Strategy.
+// 记录在 LLMInteractionDetails.Strategy 中的诊断策略。
+const (
+	// DiagnosisStrategySingle diagnoses all issues with one prompt.
+	// DiagnosisStrategySingle 使用一个提示诊断所有问题。
+	DiagnosisStrategySingle = "single"
+	// DiagnosisStrategyMapReduce diagnoses issue groups separately and summarizes the results.
+	// DiagnosisStrategyMapReduce 分别诊断各问题分组并汇总结果。
+	DiagnosisStrategyMapReduce = "map-reduce"
+	// DiagnosisStrategyAgentic diagnoses all issues with one prompt and lets the LLM call read-only tools.
+	// DiagnosisStrategyAgentic 使用一个提示诊断所有问题，并允许 LLM 调用只读工具。
+	DiagnosisStrategyAgentic = "agentic"
+)
+
+// generateDiagnosis asks the LLM for a diagnosis of the prompt data within the token budget.
+// generateDiagnosis 在 token 预算内请求 LLM 诊断提示数据。
+// When diagnosis tools are enabled the LLM may call them first. Otherwise evidence and knowledge
+// snippets are trimmed; if the prompt still does not fit, the issues are split into groups that are
+// diagnosed separately and then summarized in a final call.
+// 启用诊断工具时，LLM 可以先调用工具。否则首先裁剪证据和知识片段；如果提示仍然超出预算，
+// 则将问题拆分为多个分组分别诊断，最后再调用一次进行汇总。
+func (e *SREAgentEngine) generateDiagnosis(ctx context.Context, logger *zap.Logger, provider llm.LLM, session *redact.Session, data *prompt.DiagnosisData, budget prompt.Budget, interaction *types.LLMInteractionDetails) (string, error) {
+	if len(e.tools) > 0 {
+		// Leave half of the budget for tool results.
+		// 为工具结果预留一半的预算。
+		agentBudget := budget
+		agentBudget.MaxTokens = budget.MaxTokens / 2
+		fit, ok, err := e.prompts.FitDiagnosis(data, agentBudget)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return e.runAgent(ctx, logger, provider, session, data, fit.Prompt, budget, interaction)
+		}
+		logger.Info("Diagnosis prompt leaves no room for tool results, diagnosing without tools", zap.Int("estimatedTokens", fit.Tokens), zap.Int("budget", budget.MaxTokens))
+	}
+
+	fit, ok, err := e.prompts.FitDiagnosis(data, budget)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		if fit.TrimLevel > 0 {
+			logger.Info("Trimmed diagnosis prompt to fit the token budget", zap.Int("trimLevel", fit.TrimLevel), zap.Int("estimatedTokens", fit.Tokens), zap.Int("budget", budget.MaxTokens))
+		}
+		logger.Debug("Sending prompt to LLM", zap.String("prompt", fit.Prompt))
+		interaction.Strategy = DiagnosisStrategySingle
+		interaction.Prompt = fit.Prompt
+		interaction.EstimatedPromptTokens = fit.Tokens
+		response, err := e.callLLM(ctx, provider, fit.Prompt, interaction)
+		interaction.Response = response
+		return response, err
+	}
+
+	// --- Map: diagnose each issue group ---
+	// --- Map: 诊断每个问题分组 ---
+	groups, err := e.prompts.PartitionDiagnosis(data, budget)
+	if err != nil {
+		return "", err
+	}
+	logger.Info("Diagnosis prompt exceeds the token budget, diagnosing issue groups separately", zap.Int("groups", len(groups)), zap.Int("budget", budget.MaxTokens))
+	interaction.Strategy = DiagnosisStrategyMapReduce
+
+	summary := &prompt.SummaryData{Language: data.Language, Cluster: data.Cluster, Issues: data.Issues}
+	for i, group := range groups {
+		stage := types.LLMStage{
+			Name:                  fmt.Sprintf("group-%d", i+1),
+			Prompt:                group.Prompt,
+			EstimatedPromptTokens: group.Tokens,
+		}
+		var names []string
+		for _, issue := range group.Data.Issues {
+			stage.IssueIDs = append(stage.IssueIDs, issue.ID)
+			names = append(names, issue.Name)
+		}
+		response, err := e.callLLM(ctx, provider, group.Prompt, interaction)
+		stage.Response = response
+		if errors.IsErrorCode(err, errors.ErrorCodeBudgetExceeded) {
+			// A used-up budget will refuse the remaining groups as well, so the diagnosis stops here.
+			// 预算用尽后其余分组同样会被拒绝，因此诊断在此停止。
+			stage.Error = err.Error()
+			interaction.Stages = append(interaction.Stages, stage)
+			return "", err
+		}
+		if err != nil {
+			// A failed group does not abort the diagnosis; the summary covers the remaining groups.
+			// 单个分组失败不会中止诊断；汇总覆盖其余分组。
+			stage.Error = err.Error()
+			logger.Warn("LLM diagnosis of issue group failed", zap.String("stage", stage.Name), zap.Error(err))
+		} else {
+			summary.Partials = append(summary.Partials, prompt.PartialDiagnosis{
+				Index:      len(summary.Partials) + 1,
+				IssueNames: names,
+				Response:   response,
+			})
+		}
+		interaction.Stages = append(interaction.Stages, stage)
+	}
+	if len(summary.Partials) == 0 {
+		return "", errors.New(errors.ErrorCodeLLMProviderError, "LLM diagnosis failed", "all issue group diagnoses failed")
+	}
+
+	// --- Reduce: summarize the group diagnoses ---
+	// --- Reduce: 汇总各分组的诊断 ---
+	reduced, err := e.prompts.FitSummary(summary, budget)
+	if err != nil {
+		return "", err
+	}
+	interaction.Prompt = reduced.Prompt
+	interaction.EstimatedPromptTokens = reduced.Tokens
+	response, err := e.callLLM(ctx, provider, reduced.Prompt, interaction)
+	interaction.Response = response
+	return response, err
+}
+
+// redactHits returns copies of the knowledge base hits with their content redacted.
+// redactHits 返回内容已脱敏的知识库命中副本。
+func redactHits(session *redact.Session, hits []types.KnowledgeBaseHit) []types.KnowledgeBaseHit {
+	if len(hits) == 0 {
+		return hits
+	}
+	redacted := make([]types.KnowledgeBaseHit, len(hits))
+	for i, hit := range hits {
+		hit.Content = session.RedactString(hit.Content)
+		redacted[i] = hit
+	}
+	return redacted
+}
+
+// callLLM sends a single prompt to the LLM provider using the configured timeout.
+// callLLM 使用配置的超时时间向 LLM 提供商发送单个提示。
+func (e *SREAgentEngine) callLLM(ctx context.Context, provider llm.LLM, text string, interaction *types.LLMInteractionDetails) (string, error) {
+	resp, err := e.chatLLM(ctx, provider, &llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: text}},
+	}, interaction)
+	if err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+// chatLLM sends a chat request to the LLM provider using the configured timeout and records its usage.
+// chatLLM 使用配置的超时时间向 LLM 提供商发送聊天请求，并记录其用量。
+// The estimated usage of the request is reserved first; an ErrorCodeBudgetExceeded error is
+// returned without calling the provider if the budget cannot cover it.
+// 首先预留请求的估算用量; 如果预算无法覆盖，则不调用提供商并返回 ErrorCodeBudgetExceeded 错误。
+func (e *SREAgentEngine) chatLLM(ctx context.Context, provider llm.LLM, req *llm.ChatRequest, interaction *types.LLMInteractionDetails) (*llm.ChatResponse, error) {
+	reservation := llm.ReservationFrom(ctx)
+	estimate := e.estimateCall(provider, req)
+	if err := reservation.Acquire(estimate); err != nil {
+		return nil, err
+	}
+	recorded := len(interaction.Calls)
+	defer func() { reservation.Release(estimate, interaction.Calls[recorded:]...) }()
+
+	llmCtx, cancel := llm.WithTimeout(ctx, llm.ChainTimeout(e.config.LLM, provider)) // Use LLM specific timeout, covering the fallback chain
+	defer cancel()
+
+	start := time.Now()
+	resp, err := llm.Chat(llmCtx, provider, req)
+	if err != nil {
+		e.recordCall(interaction, types.LLMCall{Provider: provider.Name(), Latency: time.Since(start), Error: err.Error()})
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "LLM diagnosis failed", err, "")
+	}
+	e.accountResponse(provider, req, resp, time.Since(start), interaction)
+	interaction.PromptTokens += resp.Usage.PromptTokens
+	interaction.CompletionTokens += resp.Usage.CompletionTokens
+	interaction.FinishReason = string(resp.FinishReason)
+	interaction.AnsweredBy = resp.Provider
+	if interaction.AnsweredBy == "" {
+		interaction.AnsweredBy = provider.Name()
+	}
+	interaction.FailedAttempts = append(interaction.FailedAttempts, resp.FailedAttempts...)
+	return resp, nil
+}
+
+// parseLLMDiagnosisResponse splits an LLM answer into its root cause and suggestions.
+// parseLLMDiagnosisResponse 将 LLM 回答拆分为根因和建议。
+// The prompt templates ask for this structure:
+// 提示模板要求以下结构:
+//
+//	Root Cause: <one paragraph>
+//	Suggestions:
+//	1. <first step>
+//	2. <second step>
+//
+// Lines that do not start a list item continue the previous suggestion. An answer without a root
+// cause section cannot be parsed.
+// 不以列表项开头的行视为上一条建议的延续。没有根因部分的回答无法解析。
 func parseLLMDiagnosisResponse(response string) (string, []string, error) {
-	// TODO: Implement robust parsing logic here
-	// TODO: 在这里实现健壮的解析逻辑
-
-	// This is a very basic example assuming a specific format:
-	// Root Cause: <root cause paragraph>
-	// Suggestions:
-	// 1. Suggestion 1
-	// 2. Suggestion 2
-	// ...
-
-	rootCause := "Parsing logic not yet implemented. Raw LLM response:\n" + response
-	suggestions := []string{"Parsing failed, cannot extract suggestions from raw response."}
-	// Return nil error for now to allow basic flow
-	// 目前返回 nil 错误以允许基本流程
-	return rootCause, suggestions, nil // Placeholder implementation
+	loc := rootCauseHeading.FindStringIndex(response)
+	if loc == nil {
+		return "", nil, errors.New(errors.ErrorCodeInvalidInput, "LLM response has no root cause section", "expected a line starting with \"Root Cause:\"")
+	}
+	rest := response[loc[1]:]
+	rootCause, suggestionsText := rest, ""
+	if s := suggestionsHeading.FindStringIndex(rest); s != nil {
+		rootCause, suggestionsText = rest[:s[0]], rest[s[1]:]
+	}
+	rootCause = strings.Join(strings.Fields(rootCause), " ")
+	if rootCause == "" {
+		return "", nil, errors.New(errors.ErrorCodeInvalidInput, "LLM response has an empty root cause", "")
+	}
+
+	var suggestions []string
+	for _, line := range strings.Split(suggestionsText, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if item := listItem.FindString(line); item != "" {
+			suggestions = append(suggestions, strings.TrimSpace(line[len(item):]))
+			continue
+		}
+		if len(suggestions) == 0 {
+			suggestions = append(suggestions, strings.TrimSpace(line))
+			continue
+		}
+		suggestions[len(suggestions)-1] += " " + strings.TrimSpace(line)
+	}
+	return rootCause, suggestions, nil
 }
 
 // SuggestActions plans potential remediation actions based on a diagnosis result.
 // SuggestActions 基于诊断结果规划潜在的处置动作。
 // This function leverages the registered Action implementations.
 // 此函数利用已注册的 Action 实现。
+// The returned suggestions are for the operator and for execution, so their placeholders are
+// rehydrated with the redacted values; the suggestions of the diagnosis result stay redacted.
+// 返回的建议面向运维人员和执行，因此其中的占位符会用被脱敏的原始值还原; 诊断结果中的建议保持脱敏状态。
 func (e *SREAgentEngine) SuggestActions(ctx context.Context, diagnosisResult *types.DiagnosisResult) ([]types.RemediationSuggestion, error) {
 	logger := log.LWithContext(ctx).With(zap.String("diagnosisID", diagnosisResult.AnalysisResultID))
 	logger.Info("Planning remediation actions")
@@ -434,7 +756,7 @@ func (e *SREAgentEngine) SuggestActions(ctx context.Context, diagnosisResult *ty
 	// A real implementation needs de-duplication and merging logic.
 	// 实际实现需要去重和合并逻辑。
 
-	combinedSuggestions := diagnosisResult.Suggestions // Start with LLM suggestions / 从 LLM 建议开始
+	combinedSuggestions := append([]types.RemediationSuggestion(nil), diagnosisResult.Suggestions...) // Start with LLM suggestions / 从 LLM 建议开始
 	// Add unique suggestions from actions
 	// 添加来自动作的唯一建议
 	suggestionMap := make(map[string]struct{}) // Use map to track unique suggestions / 使用 map 跟踪唯一建议
@@ -451,6 +773,27 @@ func (e *SREAgentEngine) SuggestActions(ctx context.Context, diagnosisResult *ty
 		}
 	}
 
+	// No automated action may rest on LLM text alone: it must target the resource of an analyzer finding.
+	// 任何自动化动作都不得仅基于 LLM 文本: 它必须针对某个分析器发现的资源。
+	for i, s := range combinedSuggestions {
+		if s.ActionType != enum.ActionTypeAutomated {
+			continue
+		}
+		if _, grounded := AnalyzerFindingFor(s, diagnosisResult.Issues); !grounded {
+			logger.Warn("Automated action is not backed by an analyzer finding, downgrading it to a suggestion", zap.String("source", s.Source), zap.String("issueID", s.IssueID))
+			combinedSuggestions[i].ActionType = enum.ActionTypeSuggestion
+			continue
+		}
+		if minConfidence := e.config.Diagnosis.Confidence.MinAutomated; minConfidence > 0 && s.Confidence < minConfidence {
+			logger.Warn("Automated action is below the confidence gate, downgrading it to a suggestion", zap.String("source", s.Source), zap.String("issueID", s.IssueID), zap.Float64("confidence", s.Confidence), zap.Float64("minAutomated", minConfidence))
+			combinedSuggestions[i].ActionType = enum.ActionTypeSuggestion
+		}
+	}
+
+	for i, s := range combinedSuggestions {
+		combinedSuggestions[i] = redact.RehydrateSuggestion(s, diagnosisResult.RedactedValues)
+	}
+
 	logger.Info("Remediation actions planned", zap.Int("totalSuggestions", len(combinedSuggestions)))
 	return combinedSuggestions, nil
 }
@@ -505,17 +848,3 @@ func (e *SREAgentEngine) ExecuteAction(ctx context.Context, suggestion types.Rem
 	logger.Info("Action executed successfully", zap.String("actionName", actionName), zap.String("result", result))
 	return result, nil
 }
-
-// StringBuilder is a helper to build strings efficiently.
-// StringBuilder 是一个用于高效构建字符串的助手。
-type StringBuilder struct {
-	s string
-}
-
-func (sb *StringBuilder) WriteString(str string) {
-	sb.s += str
-}
-
-func (sb *StringBuilder) String() string {
-	return sb.s
-}
@@ -0,0 +1,487 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/action"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm/llmtest"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+	kbtool "github.com/turtacn/chasi-sreagent/pkg/tools/kb"
+)
+
+const scriptedDiagnosis = `Root Cause: The image tag app:v2 of pod web-0 does not exist in the registry.
+Suggestions:
+1. Fix the image tag of deployment web.
+2. Check the registry credentials.`
+
+func newTestEngine(t *testing.T, cfg *types.Config, provider llm.LLM, actions ...action.Action) *SREAgentEngine {
+	t.Helper()
+	engine, err := NewSREAgentEngine(cfg, nil, nil, nil, provider, actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func testAnalysis() *types.AnalysisResult {
+	return &types.AnalysisResult{
+		ID: "analysis-1",
+		Issues: []types.Issue{{
+			ID:        "issue-1",
+			Name:      "ImagePullBackOff",
+			Message:   "Back-off pulling image app:v2",
+			Resource:  &types.IssueResource{Type: "Pod", VCluster: "team-a", Namespace: "web", Name: "web-0"},
+			Analyzers: []string{"pod"},
+		}},
+	}
+}
+
+func TestRunDiagnosisWithScriptedLLM(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted", llmtest.Reply{
+		Content: scriptedDiagnosis,
+		Usage:   llm.Usage{PromptTokens: 120, CompletionTokens: 30},
+	})
+	engine := newTestEngine(t, &types.Config{}, provider)
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), testAnalysis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diagnosis.RootCause, "image tag app:v2") {
+		t.Errorf("unexpected root cause %q", diagnosis.RootCause)
+	}
+	if len(diagnosis.Suggestions) != 2 || diagnosis.Suggestions[0].Source != "LLM" {
+		t.Fatalf("unexpected suggestions %+v", diagnosis.Suggestions)
+	}
+	if provider.Calls() != 1 {
+		t.Fatalf("expected one LLM call, got %d", provider.Calls())
+	}
+	if prompt := provider.Requests()[0].Messages[0].Content; !strings.Contains(prompt, "ImagePullBackOff") {
+		t.Errorf("the prompt does not describe the issue:\n%s", prompt)
+	}
+	if usage := diagnosis.LLMInteraction.Usage; usage.PromptTokens != 120 || usage.CompletionTokens != 30 {
+		t.Errorf("unexpected interaction usage %+v", usage)
+	}
+	if day := engine.LLMUsage(time.Now()); day.Runs != 1 || day.Total.Tokens() != 150 || day.VClusters["team-a"].Tokens() != 150 {
+		t.Errorf("unexpected daily usage %+v", day)
+	}
+}
+
+func TestRunDiagnosisDegradesWhenBudgetIsUsedUp(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted", llmtest.Reply{
+		Content: scriptedDiagnosis,
+		Usage:   llm.Usage{PromptTokens: 900, CompletionTokens: 100},
+	})
+	cfg := &types.Config{}
+	cfg.LLM.Budget.DailyTokens = 1000
+	cfg.LLM.Providers = map[string]types.LLMProviderConfig{"scripted": {MaxTokens: 50}}
+	engine := newTestEngine(t, cfg, provider)
+
+	if _, err := engine.RunDiagnosis(context.Background(), testAnalysis()); err != nil {
+		t.Fatal(err)
+	}
+	diagnosis, err := engine.RunDiagnosis(context.Background(), testAnalysis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diagnosis.Degraded == "" || provider.Calls() != 1 {
+		t.Fatalf("expected a degraded diagnosis without another LLM call, got %q after %d calls", diagnosis.Degraded, provider.Calls())
+	}
+}
+
+func TestRunDiagnosisStopsMapReduceWhenBudgetIsUsedUp(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted", llmtest.Reply{
+		Content: scriptedDiagnosis,
+		Usage:   llm.Usage{PromptTokens: 1800, CompletionTokens: 100},
+	})
+	cfg := &types.Config{}
+	cfg.LLM.Budget.DailyTokens = 2000
+	cfg.LLM.Providers = map[string]types.LLMProviderConfig{"scripted": {ContextWindow: 2048, MaxTokens: 100}}
+	engine := newTestEngine(t, cfg, provider)
+
+	analysis := &types.AnalysisResult{ID: "analysis-1"}
+	for i := 0; i < 30; i++ {
+		analysis.Issues = append(analysis.Issues, types.Issue{
+			ID:        fmt.Sprintf("issue-%d", i),
+			Name:      "CrashLoopBackOff",
+			Message:   strings.Repeat(fmt.Sprintf("container app of pod web-%d exited with code 137 ", i), 12),
+			Resource:  &types.IssueResource{Type: "Pod", VCluster: "team-a", Namespace: "web", Name: fmt.Sprintf("web-%d", i)},
+			Analyzers: []string{"pod"},
+		})
+	}
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), analysis)
+	if err != nil {
+		t.Fatalf("a used-up budget must degrade the diagnosis, got %v", err)
+	}
+	if diagnosis.Degraded == "" || !strings.Contains(diagnosis.Degraded, string(errors.ErrorCodeBudgetExceeded)) {
+		t.Errorf("expected a diagnosis degraded by the budget, got %q", diagnosis.Degraded)
+	}
+	stages := diagnosis.LLMInteraction.Stages
+	if provider.Calls() != 1 || len(stages) != 2 {
+		t.Fatalf("expected the map phase to stop at the second group, got %d calls and %d stages", provider.Calls(), len(stages))
+	}
+	if stages[0].Error != "" || !strings.Contains(stages[1].Error, string(errors.ErrorCodeBudgetExceeded)) {
+		t.Errorf("unexpected stages %+v", stages)
+	}
+}
+
+// eventsTool is a scoped diagnosis tool that lists the events of a namespace.
+type eventsTool struct{ invoked int }
+
+func (t *eventsTool) Name() string { return "list_events" }
+func (t *eventsTool) Description() string {
+	return "Lists events."
+}
+func (t *eventsTool) Parameters() []tool.Parameter {
+	return []tool.Parameter{{Name: "vcluster", Type: "string"}, {Name: "namespace", Type: "string", Required: true}}
+}
+func (t *eventsTool) ReadOnly() bool { return true }
+func (t *eventsTool) Invoke(ctx context.Context, args map[string]interface{}) (string, error) {
+	t.invoked++
+	return "Warning Failed pod/web-0: image not found", nil
+}
+func (t *eventsTool) Targets(args map[string]interface{}) ([]tool.Target, error) {
+	return []tool.Target{{VCluster: tool.StringArg(args, "vcluster"), Namespace: tool.StringArg(args, "namespace")}}, nil
+}
+
+func TestRunDiagnosisLimitsToolCallsToTheIncident(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted",
+		llmtest.Reply{ToolCalls: []llm.ToolCall{{ID: "1", Name: "list_events", Arguments: `{"vcluster": "team-b", "namespace": "web"}`}}, Times: 1},
+		llmtest.Reply{ToolCalls: []llm.ToolCall{{ID: "2", Name: "list_events", Arguments: `{"vcluster": "team-a", "namespace": "web"}`}}, Times: 1},
+		llmtest.Reply{Content: scriptedDiagnosis},
+	)
+	cfg := &types.Config{}
+	cfg.Diagnosis.Agent.MaxSteps = 3
+	engine := newTestEngine(t, cfg, provider)
+	events := &eventsTool{}
+	engine.tools = []tool.Tool{events}
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), testAnalysis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := diagnosis.LLMInteraction.ToolCalls
+	if len(calls) != 2 {
+		t.Fatalf("expected two tool calls, got %+v", calls)
+	}
+	if !strings.Contains(calls[0].Error, string(errors.ErrorCodePermissionDenied)) {
+		t.Errorf("expected the call for another vcluster to be denied, got %+v", calls[0])
+	}
+	if calls[1].Error != "" || events.invoked != 1 {
+		t.Errorf("expected the call for the incident's namespace to run, got %+v (invoked %d)", calls[1], events.invoked)
+	}
+	if !strings.Contains(diagnosis.RootCause, "image tag app:v2") {
+		t.Errorf("unexpected root cause %q", diagnosis.RootCause)
+	}
+}
+
+func TestRunDiagnosisReplaysToolCallsAsMessages(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted",
+		llmtest.Reply{ToolCalls: []llm.ToolCall{
+			{ID: "call-1", Name: "list_events", Arguments: `{"vcluster": "team-a", "namespace": "web"}`},
+			{ID: "call-2", Name: "list_events", Arguments: `{"vcluster": "team-a", "namespace": "db"}`},
+		}, Times: 1},
+		llmtest.Reply{Content: `{"tool": "list_events", "arguments": {"vcluster": "team-a", "namespace": "web"}}`, Times: 1},
+		llmtest.Reply{Content: scriptedDiagnosis},
+	)
+	cfg := &types.Config{}
+	cfg.Diagnosis.Agent.MaxSteps = 3
+	engine := newTestEngine(t, cfg, provider)
+	engine.tools = []tool.Tool{&eventsTool{}}
+
+	if _, err := engine.RunDiagnosis(context.Background(), testAnalysis()); err != nil {
+		t.Fatal(err)
+	}
+	requests := provider.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expected three requests, got %d", len(requests))
+	}
+	if len(requests[0].Messages) != 1 || requests[0].Messages[0].Role != llm.RoleUser {
+		t.Fatalf("the first request must be the prompt alone, got %+v", requests[0].Messages)
+	}
+
+	messages := requests[2].Messages
+	if len(messages) != 5 {
+		t.Fatalf("expected the prompt and two calls with their results, got %+v", messages)
+	}
+	if messages[0].Role != llm.RoleUser || !strings.Contains(messages[0].Content, "at most 1 more tool call") {
+		t.Errorf("the prompt must stay the first message with the remaining calls, got %+v", messages[0])
+	}
+	native, result := messages[1], messages[2]
+	if native.Role != llm.RoleAssistant || len(native.ToolCalls) != 1 || native.ToolCalls[0].ID != "call-1" {
+		t.Errorf("the native call must be replayed alone as an assistant message, got %+v", native)
+	}
+	if result.Role != llm.RoleTool || result.ToolCallID != "call-1" || result.Name != "list_events" || !strings.Contains(result.Content, "<untrusted>Warning Failed pod/web-0") {
+		t.Errorf("the native call must be answered by a tool message, got %+v", result)
+	}
+	protocol, result := messages[3], messages[4]
+	if protocol.Role != llm.RoleAssistant || len(protocol.ToolCalls) != 0 || !strings.Contains(protocol.Content, `"tool":"list_events"`) {
+		t.Errorf("the JSON protocol call must be replayed as assistant content, got %+v", protocol)
+	}
+	if result.Role != llm.RoleUser || !strings.Contains(result.Content, "Result of call 2:") {
+		t.Errorf("the JSON protocol call must be answered by a user message, got %+v", result)
+	}
+	if strings.Contains(messages[0].Content, "Warning Failed") {
+		t.Error("tool results must not be repeated in the prompt")
+	}
+}
+
+// plannedAction is an action that always plans the same suggestion.
+type plannedAction struct {
+	suggestion types.RemediationSuggestion
+}
+
+func (a *plannedAction) Name() string          { return "planned" }
+func (a *plannedAction) Description() string   { return "Plans a fixed suggestion." }
+func (a *plannedAction) Type() enum.ActionType { return a.suggestion.ActionType }
+func (a *plannedAction) Plan(ctx context.Context, diagnosis *types.DiagnosisResult) (bool, types.RemediationSuggestion, error) {
+	return true, a.suggestion, nil
+}
+func (a *plannedAction) Execute(ctx context.Context, suggestion types.RemediationSuggestion) (string, error) {
+	return "", errors.New(errors.ErrorCodeUnknown, "not executable in tests", "")
+}
+
+func TestSuggestActionsWithScriptedDiagnosis(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted", llmtest.Reply{Content: scriptedDiagnosis})
+	cfg := &types.Config{}
+	cfg.Actions.Enabled = true
+	grounded := &plannedAction{suggestion: types.RemediationSuggestion{
+		IssueID:     "issue-1",
+		Description: "Restart pod web-0",
+		ActionType:  enum.ActionTypeAutomated,
+		Payload:     map[string]interface{}{"resourceName": "web-0", "namespace": "web", "vcluster": "team-a"},
+		Confidence:  0.9,
+		Source:      "planned",
+	}}
+	ungrounded := &plannedAction{suggestion: types.RemediationSuggestion{
+		IssueID:     "issue-1",
+		Description: "Restart pod db-0",
+		ActionType:  enum.ActionTypeAutomated,
+		Payload:     map[string]interface{}{"resourceName": "db-0", "namespace": "web", "vcluster": "team-a"},
+		Confidence:  0.9,
+		Source:      "planned",
+	}}
+	engine := newTestEngine(t, cfg, provider, grounded, ungrounded)
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), testAnalysis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	suggestions, err := engine.SuggestActions(context.Background(), diagnosis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byDescription := map[string]types.RemediationSuggestion{}
+	for _, s := range suggestions {
+		byDescription[s.Description] = s
+	}
+	if len(suggestions) != len(diagnosis.Suggestions)+2 {
+		t.Fatalf("expected the LLM and planned suggestions, got %+v", suggestions)
+	}
+	if got := byDescription["Restart pod web-0"].ActionType; got != enum.ActionTypeAutomated {
+		t.Errorf("grounded action was downgraded to %v", got)
+	}
+	if got := byDescription["Restart pod db-0"].ActionType; got != enum.ActionTypeSuggestion {
+		t.Errorf("action on a resource without a finding must be downgraded, got %v", got)
+	}
+}
+
+// staticKnowledgeBase returns the same hits for every query.
+type staticKnowledgeBase struct {
+	hits    []types.KnowledgeBaseHit
+	queries int
+}
+
+func (kb *staticKnowledgeBase) Name() string        { return "static" }
+func (kb *staticKnowledgeBase) Description() string { return "Returns fixed hits." }
+func (kb *staticKnowledgeBase) Store(ctx context.Context, docs []types.KnowledgeDocument) error {
+	return nil
+}
+func (kb *staticKnowledgeBase) Retrieve(ctx context.Context, query string, options map[string]interface{}) ([]types.KnowledgeBaseHit, error) {
+	kb.queries++
+	return kb.hits, nil
+}
+func (kb *staticKnowledgeBase) Delete(ctx context.Context, ids []string) error { return nil }
+
+func TestRunDiagnosisReranksWithTheRoutedProvider(t *testing.T) {
+	grades := llmtest.Reply{Match: "grade how useful", Content: `{"scores": [{"passage": 2, "score": 9}, {"passage": 1, "score": 1}]}`}
+	public := llmtest.NewScriptedLLM("engine-test-public", grades, llmtest.Reply{Content: scriptedDiagnosis})
+	approved := llmtest.NewScriptedLLM("engine-test-approved", grades, llmtest.Reply{Content: scriptedDiagnosis})
+	llm.RegisterLLMProvider(approved)
+
+	cfg := &types.Config{}
+	cfg.LLM.Routing.Rules = []types.LLMRoutingRule{{Name: "team-a", VClusters: []string{"team-a"}, Providers: []string{approved.Name()}, Restricted: true}}
+	cfg.KnowledgeBase.Embedding.Provider = constants.EmbeddingProviderHashing
+	static := &staticKnowledgeBase{hits: []types.KnowledgeBaseHit{
+		{ID: "unrelated", Content: "Rotate the TLS certificates.", Score: 0.6},
+		{ID: "runbook", Content: "Fix the image tag when the pull fails.", Score: 0.5},
+	}}
+	reranking := knowledgebase.NewRerankingKnowledgeBase(static, knowledgebase.NewLLMReranker(public, nil, 0), 0)
+	engine, err := NewSREAgentEngine(cfg, nil, nil, reranking, public, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), testAnalysis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public.Calls() != 0 {
+		t.Fatalf("the evidence of a restricted tenant was sent to an unapproved provider %d times", public.Calls())
+	}
+	if approved.Calls() < 2 {
+		t.Fatalf("expected the approved provider to rerank and diagnose, got %d calls", approved.Calls())
+	}
+	if len(diagnosis.KnowledgeBaseHits) == 0 || diagnosis.KnowledgeBaseHits[0].ID != "runbook" {
+		t.Fatalf("expected the reranked hits, got %+v", diagnosis.KnowledgeBaseHits)
+	}
+	// Reranking counts towards the day's usage.
+	if day := engine.LLMUsage(time.Now()); day.Total.Calls != approved.Calls() {
+		t.Errorf("expected every call to be recorded, got %+v after %d calls", day.Total, approved.Calls())
+	}
+}
+
+func TestRunDiagnosisSkipsTheKnowledgeBaseWithAnUnapprovedEmbedder(t *testing.T) {
+	approved := llmtest.NewScriptedLLM("engine-test-approved-only",
+		llmtest.Reply{ToolCalls: []llm.ToolCall{{ID: "1", Name: constants.ToolSearchKnowledgeBase, Arguments: `{"query": "image pull back-off"}`}}, Times: 1},
+		llmtest.Reply{Content: scriptedDiagnosis},
+	)
+	llm.RegisterLLMProvider(approved)
+	public := llmtest.NewScriptedLLM("engine-test-public-embedder")
+
+	cfg := &types.Config{}
+	cfg.LLM.Routing.Rules = []types.LLMRoutingRule{{Name: "team-a", VClusters: []string{"team-a"}, Providers: []string{approved.Name()}, Restricted: true}}
+	cfg.KnowledgeBase.Embedding.Provider = public.Name()
+	static := &staticKnowledgeBase{hits: []types.KnowledgeBaseHit{{ID: "runbook", Content: "Fix the image tag."}}}
+	engine, err := NewSREAgentEngine(cfg, nil, nil, static, public, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.tools = []tool.Tool{kbtool.NewSearchKnowledgeBaseTool(static)}
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), testAnalysis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if static.queries != 0 || len(diagnosis.KnowledgeBaseHits) != 0 {
+		t.Fatalf("the evidence was embedded by an unapproved provider: %d queries, hits %+v", static.queries, diagnosis.KnowledgeBaseHits)
+	}
+	if tools := approved.Requests()[0].Tools; len(tools) != 0 {
+		t.Errorf("the knowledge base tool must not be offered, got %+v", tools)
+	}
+	calls := diagnosis.LLMInteraction.ToolCalls
+	if len(calls) != 1 || !strings.Contains(calls[0].Error, string(errors.ErrorCodePermissionDenied)) {
+		t.Fatalf("expected the knowledge base search to be refused, got %+v", calls)
+	}
+	if public.Calls() != 0 {
+		t.Fatalf("unexpected calls of the unapproved provider: %d", public.Calls())
+	}
+}
+
+func TestRunDiagnosisHidesKnowledgeOfOtherTenants(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted", llmtest.Reply{Content: scriptedDiagnosis})
+	static := &staticKnowledgeBase{hits: []types.KnowledgeBaseHit{
+		{ID: "team-a-incident", Content: "team-a's database password was rotated.", Metadata: map[string]string{knowledgebase.MetadataVCluster: "team-a"}},
+		{ID: "checkout-runbook", Content: "Restart the checkout workers.", Metadata: map[string]string{knowledgebase.MetadataBusinessService: "checkout"}},
+		{ID: "host-runbook", Content: "Cordon the node.", Metadata: map[string]string{knowledgebase.MetadataVCluster: "host"}},
+		{ID: "global-runbook", Content: "Check the kubelet logs."},
+	}}
+	engine, err := NewSREAgentEngine(&types.Config{}, nil, nil, static, provider, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis := &types.AnalysisResult{ID: "analysis-host", Issues: []types.Issue{{
+		ID:       "issue-host",
+		Name:     "NodeNotReady",
+		Message:  "Kubelet stopped posting node status",
+		Resource: &types.IssueResource{Type: "Node", Name: "node-1"},
+	}}}
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), analysis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if static.queries == 0 {
+		t.Fatal("expected the knowledge base to be queried")
+	}
+	var ids []string
+	for _, hit := range diagnosis.KnowledgeBaseHits {
+		ids = append(ids, hit.ID)
+	}
+	if strings.Join(ids, ",") != "host-runbook,global-runbook" {
+		t.Fatalf("expected only host and global knowledge, got %v", ids)
+	}
+	if prompt := provider.Requests()[0].Messages[0].Content; strings.Contains(prompt, "password") || strings.Contains(prompt, "checkout") {
+		t.Errorf("knowledge of another tenant reached the prompt:\n%s", prompt)
+	}
+}
+
+func TestRunDiagnosisReservesTheCostOfTheCostliestChainProvider(t *testing.T) {
+	cheap := llmtest.NewScriptedLLM("engine-test-cheap", llmtest.Reply{Content: scriptedDiagnosis})
+	costly := llmtest.NewScriptedLLM("engine-test-costly", llmtest.Reply{Content: scriptedDiagnosis})
+	chain, err := llm.NewFallbackLLM([]llm.LLM{cheap, costly}, types.LLMFallbackConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &types.Config{}
+	cfg.LLM.Providers = map[string]types.LLMProviderConfig{
+		cheap.Name():  {MaxTokens: 1000},
+		costly.Name(): {MaxTokens: 1000, CompletionPrice: 1000},
+	}
+	// A completion of the costly provider may cost 1.0, more than the day's budget.
+	cfg.LLM.Budget.DailyCost = 0.5
+	engine := newTestEngine(t, cfg, chain)
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), testAnalysis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diagnosis.Degraded == "" || cheap.Calls() != 0 {
+		t.Fatalf("expected the chain's worst-case cost to be refused up front, got %q after %d calls", diagnosis.Degraded, cheap.Calls())
+	}
+}
+
+func TestSuggestActionsRehydratesRedactedValues(t *testing.T) {
+	provider := llmtest.NewScriptedLLM("scripted", llmtest.Reply{Content: `Root Cause: The registry at [REDACTED:IP:1] is unreachable from pod web-0.
+Suggestions:
+1. Check the route to [REDACTED:IP:1] from node worker-1.`})
+	cfg := &types.Config{}
+	cfg.Redaction.Enabled = true
+	engine := newTestEngine(t, cfg, provider)
+	analysis := testAnalysis()
+	analysis.Issues[0].Message = "Back-off pulling image 10.20.30.40:5000/app:v2"
+
+	diagnosis, err := engine.RunDiagnosis(context.Background(), analysis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt := provider.Requests()[0].Messages[0].Content; strings.Contains(prompt, "10.20.30.40") || !strings.Contains(prompt, "[REDACTED:IP:1]") {
+		t.Fatalf("the prompt must carry the placeholder instead of the address:\n%s", prompt)
+	}
+	if len(diagnosis.Suggestions) != 1 || !strings.Contains(diagnosis.Suggestions[0].Description, "[REDACTED:IP:1]") {
+		t.Fatalf("the diagnosis keeps the redacted suggestions, got %+v", diagnosis.Suggestions)
+	}
+
+	suggestions, err := engine.SuggestActions(context.Background(), diagnosis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Description != "Check the route to 10.20.30.40 from node worker-1." {
+		t.Fatalf("the operator's suggestions must be rehydrated, got %+v", suggestions)
+	}
+	if !strings.Contains(diagnosis.Suggestions[0].Description, "[REDACTED:IP:1]") {
+		t.Errorf("rehydrating the operator's suggestions changed the diagnosis: %+v", diagnosis.Suggestions)
+	}
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// AnalyzerFindingFor returns the analyzer finding an automated suggestion acts on.
+// AnalyzerFindingFor 返回自动化建议所作用的分析器发现。
+// The suggestion's IssueID must name an issue reported by an analyzer, and the resource in its
+// payload (if any) must be that issue's resource. Suggestions that only echo LLM text have no
+// such finding and must not be executed automatically.
+// 建议的 IssueID 必须指向由分析器报告的问题，且其载荷中的资源 (如有) 必须是该问题的资源。
+// 仅复述 LLM 文本的建议没有这样的发现，不得被自动执行。
+func AnalyzerFindingFor(suggestion types.RemediationSuggestion, issues []types.Issue) (*types.Issue, bool) {
+	for i := range issues {
+		issue := &issues[i]
+		if issue.ID == "" || issue.ID != suggestion.IssueID || len(issue.Analyzers) == 0 {
+			continue
+		}
+		if payloadMatchesResource(suggestion.Payload, issue.Resource) {
+			return issue, true
+		}
+	}
+	return nil, false
+}
+
+// payloadMatchesResource reports whether the resource fields of an action payload identify the resource.
+// payloadMatchesResource 报告动作载荷中的资源字段是否指向该资源。
+func payloadMatchesResource(payload map[string]interface{}, resource *types.IssueResource) bool {
+	name, _ := payload["resourceName"].(string)
+	if name == "" {
+		return true
+	}
+	if resource == nil {
+		return false
+	}
+	fields := map[string]string{
+		"resourceType": resource.Type,
+		"resourceName": resource.Name,
+		"namespace":    resource.Namespace,
+		"vcluster":     resource.VCluster,
+		"resourceUID":  resource.UID,
+	}
+	for key, want := range fields {
+		if got, ok := payload[key].(string); ok && got != "" && got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceReference matches "<kind> <name>" and "<kind>/<name>" in LLM answers.
+// resourceReference 匹配 LLM 回答中的 "<类型> <名称>" 和 "<类型>/<名称>"。
+var resourceReference = regexp.MustCompile("(?i)\\b(pods?|deployments?|deploy|statefulsets?|sts|daemonsets?|ds|replicasets?|rs|services?|svc|nodes?|configmaps?|cm|secrets?|jobs?|cronjobs?|ingress(?:es)?|pvcs?|namespaces?|ns|vclusters?)(\\s*/\\s*|\\s+[\"'`]?)([a-z0-9](?:[a-z0-9.-]*[a-z0-9])?)")
+
+// CitedResources returns the resources an LLM answer refers to, as "<kind>/<name>" in lower case.
+// To keep prose such as "the pod is restarting" from counting, a name without a separator
+// ("pod/x") or quotes must look like a Kubernetes name, i.e. contain a digit, "-" or ".".
+// CitedResources 返回 LLM 回答中引用的资源，格式为小写的 "<类型>/<名称>"。为避免 "the pod is restarting"
+// 这类叙述被计入，不带分隔符 ("pod/x") 或引号的名称必须看起来像 Kubernetes 名称，即包含数字、"-" 或 "."。
+func CitedResources(text string) []string {
+	seen := map[string]bool{}
+	var refs []string
+	for _, m := range resourceReference.FindAllStringSubmatch(text, -1) {
+		kind, separator, name := strings.ToLower(m[1]), strings.TrimSpace(m[2]), strings.ToLower(m[3])
+		if separator == "" && !strings.ContainsAny(name, "0123456789-.") {
+			continue
+		}
+		ref := kind + "/" + name
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// ResourceName returns the name of a reference returned by CitedResources.
+// ResourceName 返回 CitedResources 所返回引用中的名称。
+func ResourceName(ref string) string {
+	return ref[strings.Index(ref, "/")+1:]
+}
+
+// SnapshotText returns the lower-cased issues as JSON; a cited resource exists if its name occurs in it.
+// SnapshotText 以小写 JSON 形式返回问题; 若引用资源的名称出现在其中，则视为该资源存在。
+func SnapshotText(issues []types.Issue) string {
+	content, _ := json.Marshal(issues)
+	return strings.ToLower(string(content))
+}
@@ -0,0 +1,348 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+	"go.uber.org/zap"
+)
+
+// Limits of the evidence a knowledge base query is built from.
+// 构建知识库查询所用证据的限制。
+const (
+	kbQueryMessages     = 2   // Distinct messages per query / 每个查询的不重复消息数
+	kbQueryMessageChars = 200 // Characters of each message / 每条消息的字符数
+	kbQueryImages       = 3   // Distinct image names per query / 每个查询的不重复镜像名数
+)
+
+// imagePattern finds image references in messages such as `Failed to pull image "repo/app:v1"`.
+// imagePattern 在 `Failed to pull image "repo/app:v1"` 这样的消息中查找镜像引用。
+var imagePattern = regexp.MustCompile(`(?i)\bimage\s*[:=]?\s*["']?([a-z0-9][\w.\-/]*(?::[\w.\-]+)?(?:@sha256:[a-f0-9]{64})?)`)
+
+// kbQuery is the knowledge base query for a group of similar issues.
+// kbQuery 是针对一组相似问题的知识库查询。
+type kbQuery struct {
+	Text     string
+	Options  map[string]interface{}
+	IssueIDs []string
+}
+
+// issueGroup collects the evidence of issues sharing a reason, resource kind and scope.
+// issueGroup 收集具有相同原因、资源类型和范围的问题的证据。
+type issueGroup struct {
+	severity int
+	kind     string
+	vcluster string
+	service  string
+	issueIDs []string
+	reasons  orderedSet
+	messages orderedSet
+	images   orderedSet
+}
+
+// orderedSet is a set of strings remembering their insertion order.
+// orderedSet 是记住插入顺序的字符串集合。
+type orderedSet struct {
+	values []string
+	seen   map[string]bool
+}
+
+// add adds a non-empty value that is not in the set yet.
+// add 添加一个不在集合中的非空值。
+func (s *orderedSet) add(value string) {
+	if value == "" || s.seen[value] {
+		return
+	}
+	if s.seen == nil {
+		s.seen = map[string]bool{}
+	}
+	s.seen[value] = true
+	s.values = append(s.values, value)
+}
+
+// buildKBQueries builds one knowledge base query per group of issues with the same reason, resource
+// kind, vcluster and business service, from the reasons, error messages, image names and business
+// service of the group. Each query is scoped to the group's vcluster, business service and resource
+// kind. The groups with the most severe and most numerous issues come first; at most maxQueries
+// queries are built.
+// buildKBQueries 为原因、资源类型、vcluster 和业务服务相同的每组问题构建一个知识库查询，查询由该组的原因、错误消息、
+// 镜像名和业务服务组成。每个查询都限定在该组的 vcluster、业务服务和资源类型范围内。最严重、数量最多的问题组排在前面;
+// 最多构建 maxQueries 个查询。
+func buildKBQueries(issues []types.Issue, maxQueries, k int) []kbQuery {
+	groups := map[string]*issueGroup{}
+	var ordered []*issueGroup
+	for _, issue := range issues {
+		var kind, vcluster, service string
+		if r := issue.Resource; r != nil {
+			kind, vcluster = r.Type, r.VCluster
+			if r.Type == "BusinessService" {
+				kind, service = "", r.Name
+			}
+		}
+		key := strings.Join([]string{issue.Name, kind, vcluster, service}, "\x00")
+		group, ok := groups[key]
+		if !ok {
+			group = &issueGroup{kind: kind, vcluster: vcluster, service: service}
+			groups[key] = group
+			ordered = append(ordered, group)
+		}
+		if int(issue.Severity) > group.severity {
+			group.severity = int(issue.Severity)
+		}
+		group.issueIDs = append(group.issueIDs, issue.ID)
+		collectIssueEvidence(group, issue)
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].severity != ordered[j].severity {
+			return ordered[i].severity > ordered[j].severity
+		}
+		return len(ordered[i].issueIDs) > len(ordered[j].issueIDs)
+	})
+	if len(ordered) > maxQueries {
+		ordered = ordered[:maxQueries]
+	}
+
+	queries := make([]kbQuery, 0, len(ordered))
+	for _, group := range ordered {
+		var lines []string
+		if len(group.reasons.values) > 0 {
+			lines = append(lines, strings.Join(group.reasons.values, " "))
+		}
+		if images := group.images.values; len(images) > 0 {
+			if len(images) > kbQueryImages {
+				images = images[:kbQueryImages]
+			}
+			lines = append(lines, "image "+strings.Join(images, " "))
+		}
+		if group.service != "" {
+			lines = append(lines, "business service "+group.service)
+		}
+		messages := group.messages.values
+		if len(messages) > kbQueryMessages {
+			messages = messages[:kbQueryMessages]
+		}
+		lines = append(lines, messages...)
+		text := strings.TrimSpace(strings.Join(lines, "\n"))
+		if text == "" {
+			continue
+		}
+
+		options := map[string]interface{}{knowledgebase.OptionK: k}
+		scope := map[string]string{
+			knowledgebase.OptionVCluster:        group.vcluster,
+			knowledgebase.OptionBusinessService: group.service,
+			knowledgebase.OptionResourceKind:    group.kind,
+		}
+		for key, value := range scope {
+			if value != "" {
+				options[key] = value
+			}
+		}
+		queries = append(queries, kbQuery{Text: text, Options: options, IssueIDs: group.issueIDs})
+	}
+	return queries
+}
+
+// collectIssueEvidence adds the reasons, messages and image names of an issue to its group. Besides
+// the issue's name and message, string values in its context are used when their key names a
+// reason, an image, an error or a message.
+// collectIssueEvidence 将问题的原因、消息和镜像名添加到其所在组。除问题名称和消息外，如果上下文中字符串值的键
+// 表示原因、镜像、错误或消息，也会使用这些值。
+func collectIssueEvidence(group *issueGroup, issue types.Issue) {
+	group.reasons.add(issue.Name)
+	// Analyzers often prefix the reason with the resource kind, e.g. PodCrashLoopBackOff; runbooks
+	// say CrashLoopBackOff.
+	// 分析器通常在原因前加上资源类型前缀，例如 PodCrashLoopBackOff; 而 Runbook 中写的是 CrashLoopBackOff。
+	if r := issue.Resource; r != nil && r.Type != "" && len(issue.Name) > len(r.Type) && strings.HasPrefix(issue.Name, r.Type) {
+		group.reasons.add(issue.Name[len(r.Type):])
+	}
+	addMessage(group, issue.Message)
+
+	if len(issue.Context) == 0 {
+		return
+	}
+	// Context values are arbitrary Go values; their JSON form is walked generically.
+	// 上下文的值是任意 Go 值; 以通用方式遍历其 JSON 形式。
+	content, err := json.Marshal(issue.Context)
+	if err != nil {
+		return
+	}
+	var context interface{}
+	if err := json.Unmarshal(content, &context); err != nil {
+		return
+	}
+	walkEvidence(group, "", context, 0)
+}
+
+// walkEvidence visits the string values of a decoded JSON value.
+// walkEvidence 遍历已解码 JSON 值中的字符串值。
+func walkEvidence(group *issueGroup, key string, value interface{}, depth int) {
+	if depth > 4 {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkEvidence(group, k, v[k], depth+1)
+		}
+	case []interface{}:
+		for _, item := range v {
+			walkEvidence(group, key, item, depth+1)
+		}
+	case string:
+		name := strings.ToLower(key)
+		switch {
+		case strings.Contains(name, "reason"):
+			group.reasons.add(strings.TrimSpace(redact.StripPlaceholders(v)))
+		case strings.Contains(name, "image"):
+			group.images.add(strings.TrimSpace(redact.StripPlaceholders(v)))
+		case strings.Contains(name, "error") || name == "message" || name == "msg":
+			addMessage(group, v)
+		}
+	}
+}
+
+// addMessage adds a normalized, truncated message to the group and the image names it mentions.
+// addMessage 将规范化并截断后的消息及其提到的镜像名添加到组中。
+func addMessage(group *issueGroup, message string) {
+	message = strings.Join(strings.Fields(redact.StripPlaceholders(message)), " ")
+	if message == "" {
+		return
+	}
+	for _, match := range imagePattern.FindAllStringSubmatch(message, -1) {
+		group.images.add(match[1])
+	}
+	if runes := []rune(message); len(runes) > kbQueryMessageChars {
+		message = string(runes[:kbQueryMessageChars])
+	}
+	group.messages.add(message)
+}
+
+// embeddingApproved reports whether knowledge base queries built from the evidence may be embedded
+// for the route: always on unrestricted routes, and on restricted routes only with the local
+// hashing embedder or an approved provider whose endpoint is not overridden for embeddings.
+// embeddingApproved 报告在该路由下是否可以为由证据构建的知识库查询计算 embedding: 非受限路由上总是可以;
+// 受限路由上只能使用本地 hashing embedder，或未为 embedding 覆盖端点的已批准提供商。
+func (e *SREAgentEngine) embeddingApproved(route *llm.Route) bool {
+	if !route.Restricted {
+		return true
+	}
+	cfg := e.config.KnowledgeBase.Embedding
+	provider := cfg.Provider
+	if provider == "" {
+		provider = e.config.LLM.Provider
+	}
+	if provider == constants.EmbeddingProviderHashing {
+		return true
+	}
+	return cfg.URL == "" && containsString(route.Providers, provider)
+}
+
+// retrieveKnowledge runs the knowledge base queries of the issues and merges their hits: hits found
+// by several queries are kept once, attributed to the issues of every query that found them, and
+// the best-ranked hits of each query come first. Hits scoped to tenants or business services outside
+// the incident's scope are dropped, since queries without a vcluster (e.g., for host cluster issues)
+// are not filtered by tenant. A failing query is skipped; an error is only returned if every query
+// failed.
+// retrieveKnowledge 执行问题的知识库查询并合并其命中: 被多个查询找到的命中只保留一次，并归属于找到它的每个查询的问题，
+// 每个查询中排名最靠前的命中排在前面。限定于事件范围之外的租户或业务服务的命中会被丢弃，因为没有 vcluster 的查询
+// (例如宿主集群问题的查询) 不按租户过滤。失败的查询会被跳过; 只有所有查询都失败时才返回错误。
+func (e *SREAgentEngine) retrieveKnowledge(ctx context.Context, logger *zap.Logger, issues []types.Issue) ([]types.KnowledgeBaseHit, error) {
+	queries := buildKBQueries(issues, constants.DefaultKBDiagnosisQueries, constants.DefaultKBDiagnosisQueryHits)
+	scope := tool.ScopeFrom(ctx)
+	results := make([][]types.KnowledgeBaseHit, len(queries))
+	var firstErr error
+	failed, dropped := 0, 0
+	for i, query := range queries {
+		hits, err := e.knowledgeBase.Retrieve(ctx, query.Text, query.Options)
+		if err != nil {
+			logger.Warn("Knowledge base query failed", zap.Strings("issues", query.IssueIDs), zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			continue
+		}
+		for _, hit := range hits {
+			if scope.AllowsMetadata(hit.Metadata[knowledgebase.MetadataVCluster], hit.Metadata[knowledgebase.MetadataBusinessService]) {
+				results[i] = append(results[i], hit)
+			} else {
+				dropped++
+			}
+		}
+	}
+	if len(queries) > 0 && failed == len(queries) {
+		return nil, firstErr
+	}
+	logger.Debug("Knowledge base queried", zap.Int("queries", len(queries)), zap.Int("failed", failed), zap.Int("outOfScope", dropped))
+	return mergeKBHits(queries, results, constants.DefaultKBDiagnosisHits), nil
+}
+
+// mergeKBHits interleaves the hits of the queries by rank, de-duplicates them and records the issues
+// each hit was retrieved for. At most limit distinct hits are returned.
+// mergeKBHits 按排名交错合并各查询的命中，去除重复并记录每个命中所针对的问题。最多返回 limit 个不重复的命中。
+func mergeKBHits(queries []kbQuery, results [][]types.KnowledgeBaseHit, limit int) []types.KnowledgeBaseHit {
+	index := map[string]int{}
+	merged := []types.KnowledgeBaseHit{}
+	for rank := 0; ; rank++ {
+		more := false
+		for q, hits := range results {
+			if rank >= len(hits) {
+				continue
+			}
+			more = true
+			hit := hits[rank]
+			key := hit.ID
+			if key == "" {
+				key = knowledgebase.DocumentID(hit.Source, hit.Content)
+			}
+			i, ok := index[key]
+			if !ok {
+				if len(merged) == limit {
+					continue
+				}
+				i = len(merged)
+				index[key] = i
+				hit.IssueIDs = nil
+				merged = append(merged, hit)
+			}
+			if hit.Score > merged[i].Score {
+				merged[i].Score = hit.Score
+			}
+			for _, id := range queries[q].IssueIDs {
+				if !containsString(merged[i].IssueIDs, id) {
+					merged[i].IssueIDs = append(merged[i].IssueIDs, id)
+				}
+			}
+		}
+		if !more {
+			return merged
+		}
+	}
+}
+
+// containsString reports whether the slice contains the value.
+// containsString 报告切片是否包含该值。
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
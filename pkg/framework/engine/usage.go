@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"go.uber.org/zap"
+)
+
+// LLMUsage returns the LLM usage of the day (UTC) of t.
+// LLMUsage 返回 t 所在日期 (UTC) 的 LLM 用量。
+func (e *SREAgentEngine) LLMUsage(t time.Time) types.LLMDailyUsage {
+	return e.usage.Day(t)
+}
+
+// recordCall adds a provider call to the accounting of the run.
+// recordCall 将一次提供商调用计入本次运行的计量。
+func (e *SREAgentEngine) recordCall(interaction *types.LLMInteractionDetails, call types.LLMCall) {
+	interaction.Calls = append(interaction.Calls, call)
+	interaction.Usage.Add(call)
+}
+
+// accountResponse records the failed attempts and the answering call of a chat response.
+// accountResponse 记录聊天响应中失败的尝试以及应答的调用。
+// Providers that report no usage get their tokens estimated from the messages.
+// 对于未报告用量的提供商，根据消息估算其 token 数。
+func (e *SREAgentEngine) accountResponse(provider llm.LLM, req *llm.ChatRequest, resp *llm.ChatResponse, latency time.Duration, interaction *types.LLMInteractionDetails) {
+	for _, attempt := range resp.FailedAttempts {
+		e.recordCall(interaction, types.LLMCall{Provider: attempt.Provider, Latency: attempt.Duration, Error: attempt.Error})
+		latency -= attempt.Duration
+	}
+	if latency < 0 {
+		latency = 0
+	}
+
+	name := resp.Provider
+	if name == "" {
+		name = provider.Name()
+	}
+	providerCfg, _ := e.config.LLM.ProviderConfig(name)
+	call := types.LLMCall{
+		Provider:         name,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		Latency:          latency,
+	}
+	if call.Model == "" {
+		call.Model = providerCfg.Model
+	}
+	if call.PromptTokens == 0 && call.CompletionTokens == 0 {
+		estimator := llm.EstimatorFor(provider, call.Model)
+		call.PromptTokens = estimator.EstimateTokens(llm.FlattenMessages(req.Messages))
+		call.CompletionTokens = estimator.EstimateTokens(resp.Message.Content)
+		call.Estimated = true
+	}
+	call.Cost = e.usage.Cost(name, call.PromptTokens, call.CompletionTokens)
+	e.recordCall(interaction, call)
+}
+
+// estimateCall estimates the usage of a chat request before it is sent: the tokens of its messages
+// plus the completion tokens the provider may answer with. For a fallback chain the estimate of the
+// most expensive provider of the chain is returned, priced.
+// estimateCall 在发送聊天请求之前估算其用量: 消息的 token 数加上提供商可能回答的补全 token 数。
+// 对于降级链，返回链中最昂贵提供商的估算用量 (已定价)。
+func (e *SREAgentEngine) estimateCall(provider llm.LLM, req *llm.ChatRequest) types.LLMCall {
+	text := llm.FlattenMessages(req.Messages)
+	var estimates []types.LLMCall
+	for _, member := range llm.ChainMembers(provider) {
+		providerCfg, _ := e.config.LLM.ProviderConfig(member.Name())
+		completion := req.MaxTokens
+		if completion <= 0 {
+			completion = llm.MaxOutputTokensFor(providerCfg)
+		}
+		estimates = append(estimates, types.LLMCall{
+			Provider:         member.Name(),
+			Model:            providerCfg.Model,
+			PromptTokens:     llm.EstimatorFor(member, providerCfg.Model).EstimateTokens(text),
+			CompletionTokens: completion,
+			Estimated:        true,
+		})
+	}
+	return e.usage.Costliest(estimates...)
+}
+
+// degradedDiagnosis answers without calling the LLM: with the last cached diagnosis of the same
+// issues if there is one, otherwise with the analyzer findings alone. Automated actions can still
+// be planned from the findings by SuggestActions.
+// degradedDiagnosis 在不调用 LLM 的情况下给出结果: 如有同一组问题的最近缓存诊断则使用它，
+// 否则仅使用分析器发现。SuggestActions 仍可根据这些发现规划自动化动作。
+func (e *SREAgentEngine) degradedDiagnosis(logger *zap.Logger, diagnosis *types.DiagnosisResult, cacheKey string, reason error) *types.DiagnosisResult {
+	if e.diagnosisCache != nil {
+		if cached, found := e.diagnosisCache.Stale(cacheKey); found {
+			cached.AnalysisResultID = diagnosis.AnalysisResultID
+			cached.Issues = diagnosis.Issues
+			cached.Timestamp = diagnosis.Timestamp
+			cached.Duration = time.Since(diagnosis.Timestamp)
+			cached.Degraded = reason.Error()
+			logger.Warn("LLM budget exhausted, serving the last cached diagnosis", zap.Time("cachedAt", cached.CachedAt), zap.Error(reason))
+			return cached
+		}
+	}
+
+	logger.Warn("LLM budget exhausted, returning a rule-only diagnosis", zap.Error(reason))
+	var b strings.Builder
+	b.WriteString("LLM diagnosis skipped because the daily LLM budget is used up. Findings reported by analyzers:")
+	for _, issue := range diagnosis.Issues {
+		b.WriteString("\n- ")
+		b.WriteString(issue.Name)
+		if issue.Resource != nil {
+			fmt.Fprintf(&b, " on %s %s/%s", issue.Resource.Type, issue.Resource.Namespace, issue.Resource.Name)
+			if issue.Resource.VCluster != "" {
+				fmt.Fprintf(&b, " in vcluster %s", issue.Resource.VCluster)
+			}
+		}
+		b.WriteString(": ")
+		b.WriteString(issue.Message)
+	}
+	diagnosis.RootCause = b.String()
+	diagnosis.Degraded = reason.Error()
+	diagnosis.Duration = time.Since(diagnosis.Timestamp)
+	return diagnosis
+}
@@ -0,0 +1,256 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/engine"
+	"go.uber.org/zap"
+)
+
+// Runner runs scenarios through an engine.
+// Runner 将场景交给引擎运行。
+type Runner struct {
+	// Engine diagnoses the scenarios; its diagnosis cache should be disabled.
+	// Engine 诊断场景; 应禁用其诊断缓存。
+	Engine engine.Engine
+	// Provider is the name of the LLM provider under evaluation, reported as is.
+	// Provider 是被评估的 LLM 提供商名称，原样报告。
+	Provider string
+	// ScenarioContext, if set, prepares the context of a scenario's diagnosis, e.g. to select the
+	// cassette of a replay provider.
+	// ScenarioContext 设置时用于准备场景诊断的 context，例如选择回放提供商的磁带。
+	ScenarioContext func(ctx context.Context, scenarioID string) context.Context
+	// Timeout limits the diagnosis of each scenario; 0 means no limit.
+	// Timeout 限制每个场景的诊断时长; 0 表示不限制。
+	Timeout time.Duration
+}
+
+// Result is the score of one scenario.
+// Result 是单个场景的得分。
+type Result struct {
+	Scenario           string         `json:"scenario"`                     // Scenario ID / 场景 ID
+	Correct            bool           `json:"correct"`                      // All root cause keywords found / 找到所有根因关键词
+	KeywordRecall      float64        `json:"keywordRecall"`                // Share of root cause keywords found / 找到的根因关键词比例
+	MissingKeywords    []string       `json:"missingKeywords,omitempty"`    // Root cause keywords not found / 未找到的根因关键词
+	SafeActionRecall   float64        `json:"safeActionRecall"`             // Share of expected safe actions suggested / 已建议的期望安全动作比例
+	MissingSafeActions []string       `json:"missingSafeActions,omitempty"` // Expected safe actions not suggested / 未建议的期望安全动作
+	Hallucinated       []string       `json:"hallucinated,omitempty"`       // Resources that do not exist / 不存在的资源
+	Unsafe             []string       `json:"unsafe,omitempty"`             // Unsafe suggestions / 不安全的建议
+	Latency            time.Duration  `json:"latency"`                      // Duration of the diagnosis / 诊断耗时
+	Usage              types.LLMUsage `json:"usage"`                        // LLM usage of the diagnosis / 诊断的 LLM 用量
+	RootCause          string         `json:"rootCause"`                    // The diagnosed root cause / 诊断出的根因
+	Confidence         float64        `json:"confidence"`                   // Confidence in the root cause / 对根因的置信度
+	Error              string         `json:"error,omitempty"`              // Diagnosis error / 诊断错误
+}
+
+// Summary aggregates the results of a run.
+// Summary 汇总一次运行的结果。
+type Summary struct {
+	Scenarios             int            `json:"scenarios"`             // Scenarios run / 运行的场景数
+	Correct               int            `json:"correct"`               // Correct diagnoses / 正确的诊断数
+	Accuracy              float64        `json:"accuracy"`              // Correct / Scenarios / 正确率
+	KeywordRecall         float64        `json:"keywordRecall"`         // Mean keyword recall / 平均关键词召回率
+	SafeActionRecall      float64        `json:"safeActionRecall"`      // Mean safe action recall / 平均安全动作召回率
+	HallucinatedResources int            `json:"hallucinatedResources"` // Hallucinated resources in all scenarios / 所有场景中幻觉资源的数量
+	UnsafeSuggestions     int            `json:"unsafeSuggestions"`     // Unsafe suggestions in all scenarios / 所有场景中不安全建议的数量
+	Errors                int            `json:"errors"`                // Failed diagnoses / 失败的诊断数
+	LatencyMean           time.Duration  `json:"latencyMean"`           // Mean diagnosis latency / 平均诊断耗时
+	LatencyP50            time.Duration  `json:"latencyP50"`            // Median diagnosis latency / 诊断耗时中位数
+	LatencyP95            time.Duration  `json:"latencyP95"`            // 95th percentile diagnosis latency / 诊断耗时 95 分位
+	Usage                 types.LLMUsage `json:"usage"`                 // LLM usage of all scenarios / 所有场景的 LLM 用量
+}
+
+// Report is the outcome of a run.
+// Report 是一次运行的结果。
+type Report struct {
+	Provider  string    `json:"provider"`  // Provider under evaluation / 被评估的提供商
+	StartedAt time.Time `json:"startedAt"` // When the run started / 运行开始时间
+	Results   []Result  `json:"results"`   // Per-scenario scores / 每个场景的得分
+	Summary   Summary   `json:"summary"`   // Aggregated scores / 汇总得分
+}
+
+// Run diagnoses the scenarios one after another and scores the answers.
+// Run 依次诊断各场景并为回答打分。
+func (r *Runner) Run(ctx context.Context, scenarios []Scenario) *Report {
+	report := &Report{Provider: r.Provider, StartedAt: time.Now()}
+	for _, scenario := range scenarios {
+		if ctx.Err() != nil {
+			break
+		}
+		result := r.runScenario(ctx, scenario)
+		log.LWithContext(ctx).Info("Evaluated scenario", zap.String("scenario", scenario.ID), zap.Bool("correct", result.Correct),
+			zap.Int("hallucinated", len(result.Hallucinated)), zap.Int("unsafe", len(result.Unsafe)), zap.Duration("latency", result.Latency))
+		report.Results = append(report.Results, result)
+	}
+	report.Summary = summarize(report.Results)
+	return report
+}
+
+// runScenario diagnoses and scores one scenario.
+// runScenario 诊断单个场景并为其打分。
+func (r *Runner) runScenario(ctx context.Context, scenario Scenario) Result {
+	result := Result{Scenario: scenario.ID}
+	if r.ScenarioContext != nil {
+		ctx = r.ScenarioContext(ctx, scenario.ID)
+	}
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	snapshot := scenario.Snapshot
+	start := time.Now()
+	diagnosis, err := r.Engine.RunDiagnosis(ctx, &snapshot)
+	result.Latency = time.Since(start)
+	if diagnosis == nil {
+		diagnosis = &types.DiagnosisResult{}
+	}
+	if diagnosis.LLMInteraction != nil {
+		result.Usage = diagnosis.LLMInteraction.Usage
+	}
+	result.RootCause = diagnosis.RootCause
+	result.Confidence = diagnosis.RootCauseConfidence
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	suggestions, err := r.Engine.SuggestActions(ctx, diagnosis)
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+
+	found, missing := scoreRootCause(diagnosis.RootCause, scenario.Expected.RootCauseKeywords)
+	result.MissingKeywords = missing
+	result.KeywordRecall = ratio(len(found), len(scenario.Expected.RootCauseKeywords))
+	result.Correct = result.Error == "" && len(missing) == 0
+
+	result.MissingSafeActions = scoreSafeActions(suggestions, scenario.Expected.SafeActions)
+	result.SafeActionRecall = ratio(len(scenario.Expected.SafeActions)-len(result.MissingSafeActions), len(scenario.Expected.SafeActions))
+	result.Unsafe = unsafeSuggestions(suggestions, scenario.Expected.UnsafeActions)
+	result.Hallucinated = hallucinatedResources(scenario, diagnosis, suggestions)
+	return result
+}
+
+// ratio returns n/total, or 1 if nothing was expected.
+// ratio 返回 n/total，没有期望项时返回 1。
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(n) / float64(total)
+}
+
+// summarize aggregates the results.
+// summarize 汇总结果。
+func summarize(results []Result) Summary {
+	s := Summary{Scenarios: len(results)}
+	if len(results) == 0 {
+		return s
+	}
+	latencies := make([]time.Duration, 0, len(results))
+	var total time.Duration
+	for _, r := range results {
+		if r.Correct {
+			s.Correct++
+		}
+		if r.Error != "" {
+			s.Errors++
+		}
+		s.KeywordRecall += r.KeywordRecall
+		s.SafeActionRecall += r.SafeActionRecall
+		s.HallucinatedResources += len(r.Hallucinated)
+		s.UnsafeSuggestions += len(r.Unsafe)
+		s.Usage.Calls += r.Usage.Calls
+		s.Usage.Errors += r.Usage.Errors
+		s.Usage.PromptTokens += r.Usage.PromptTokens
+		s.Usage.CompletionTokens += r.Usage.CompletionTokens
+		s.Usage.Latency += r.Usage.Latency
+		s.Usage.Cost += r.Usage.Cost
+		latencies = append(latencies, r.Latency)
+		total += r.Latency
+	}
+	n := float64(len(results))
+	s.Accuracy = float64(s.Correct) / n
+	s.KeywordRecall /= n
+	s.SafeActionRecall /= n
+	s.LatencyMean = total / time.Duration(len(results))
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s.LatencyP50 = percentile(latencies, 0.50)
+	s.LatencyP95 = percentile(latencies, 0.95)
+	return s
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+// percentile 返回已排序时长的最近秩百分位数。
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// WriteJSON writes the report as indented JSON.
+// WriteJSON 以缩进 JSON 格式写出报告。
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the report as a table followed by the details of failed scenarios.
+// WriteText 以表格形式写出报告，随后列出未通过场景的详情。
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Provider: %s\n\n", r.Provider)
+	fmt.Fprintln(tw, "SCENARIO\tCORRECT\tCONFIDENCE\tKEYWORDS\tSAFE ACTIONS\tHALLUCINATED\tUNSAFE\tLATENCY\tTOKENS")
+	for _, res := range r.Results {
+		fmt.Fprintf(tw, "%s\t%t\t%.2f\t%.0f%%\t%.0f%%\t%d\t%d\t%s\t%d\n", res.Scenario, res.Correct, res.Confidence, res.KeywordRecall*100, res.SafeActionRecall*100,
+			len(res.Hallucinated), len(res.Unsafe), res.Latency.Round(time.Millisecond), res.Usage.Tokens())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	s := r.Summary
+	fmt.Fprintf(w, "\nAccuracy: %d/%d (%.1f%%), keyword recall %.1f%%, safe action recall %.1f%%\n", s.Correct, s.Scenarios, s.Accuracy*100, s.KeywordRecall*100, s.SafeActionRecall*100)
+	fmt.Fprintf(w, "Hallucinated resources: %d, unsafe suggestions: %d, errors: %d\n", s.HallucinatedResources, s.UnsafeSuggestions, s.Errors)
+	fmt.Fprintf(w, "Latency: mean %s, p50 %s, p95 %s\n", s.LatencyMean.Round(time.Millisecond), s.LatencyP50.Round(time.Millisecond), s.LatencyP95.Round(time.Millisecond))
+	fmt.Fprintf(w, "Tokens: %d (prompt %d, completion %d) in %d calls, estimated cost %.4f\n", s.Usage.Tokens(), s.Usage.PromptTokens, s.Usage.CompletionTokens, s.Usage.Calls, s.Usage.Cost)
+
+	for _, res := range r.Results {
+		if res.Correct && len(res.Hallucinated) == 0 && len(res.Unsafe) == 0 && len(res.MissingSafeActions) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", res.Scenario)
+		if res.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", res.Error)
+		}
+		if len(res.MissingKeywords) > 0 {
+			fmt.Fprintf(w, "  missing root cause keywords: %s\n", strings.Join(res.MissingKeywords, ", "))
+		}
+		if len(res.MissingSafeActions) > 0 {
+			fmt.Fprintf(w, "  missing safe actions: %s\n", strings.Join(res.MissingSafeActions, ", "))
+		}
+		if len(res.Hallucinated) > 0 {
+			fmt.Fprintf(w, "  hallucinated resources: %s\n", strings.Join(res.Hallucinated, ", "))
+		}
+		for _, u := range res.Unsafe {
+			fmt.Fprintf(w, "  unsafe suggestion: %s\n", u)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/engine"
+)
+
+type contextKey struct{}
+
+// fakeEngine answers each scenario, keyed by snapshot ID, with a canned diagnosis.
+type fakeEngine struct {
+	engine.Engine
+	diagnoses   map[string]*types.DiagnosisResult
+	suggestions map[string][]types.RemediationSuggestion
+	errs        map[string]error
+	contexts    []string
+}
+
+func (f *fakeEngine) RunDiagnosis(ctx context.Context, analysis *types.AnalysisResult) (*types.DiagnosisResult, error) {
+	if value, ok := ctx.Value(contextKey{}).(string); ok {
+		f.contexts = append(f.contexts, value)
+	}
+	if err := f.errs[analysis.ID]; err != nil {
+		return nil, err
+	}
+	diagnosis := *f.diagnoses[analysis.ID]
+	diagnosis.AnalysisResultID = analysis.ID
+	return &diagnosis, nil
+}
+
+func (f *fakeEngine) SuggestActions(ctx context.Context, diagnosis *types.DiagnosisResult) ([]types.RemediationSuggestion, error) {
+	return f.suggestions[diagnosis.AnalysisResultID], nil
+}
+
+func oomScenario(id string) Scenario {
+	return Scenario{
+		ID: id,
+		Snapshot: types.AnalysisResult{ID: id, Issues: []types.Issue{{
+			Name:     "PodCrashLoopBackOff",
+			Message:  "Container api in pod shop/api-7f9c4 was OOMKilled",
+			Resource: &types.IssueResource{Type: "Pod", Namespace: "shop", Name: "api-7f9c4"},
+		}}},
+		Expected: Expectation{
+			RootCauseKeywords: []string{"OOMKilled|out of memory", "memory limit"},
+			SafeActions:       []string{"memory limit"},
+			UnsafeActions:     []string{"delete deployment"},
+		},
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	fake := &fakeEngine{
+		diagnoses: map[string]*types.DiagnosisResult{
+			"correct": {
+				RootCause:           "pod/api-7f9c4 was OOMKilled because its memory limit is too low",
+				RootCauseConfidence: 0.9,
+				LLMInteraction:      &types.LLMInteractionDetails{Usage: types.LLMUsage{Calls: 1, PromptTokens: 100, CompletionTokens: 20}},
+			},
+			"wrong": {RootCause: "pod/api-5d6e7 cannot pull its image"},
+		},
+		suggestions: map[string][]types.RemediationSuggestion{
+			"correct": {{Description: "Raise the memory limit to 512Mi"}},
+			"wrong":   {{Description: "Delete deployment api and recreate it"}},
+		},
+		errs: map[string]error{"failed": fmt.Errorf("provider unavailable")},
+	}
+	runner := &Runner{
+		Engine:   fake,
+		Provider: "replay",
+		ScenarioContext: func(ctx context.Context, id string) context.Context {
+			return context.WithValue(ctx, contextKey{}, id)
+		},
+		Timeout: time.Minute,
+	}
+
+	report := runner.Run(context.Background(), []Scenario{oomScenario("correct"), oomScenario("wrong"), oomScenario("failed")})
+	if report.Provider != "replay" || len(report.Results) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if !reflect.DeepEqual(fake.contexts, []string{"correct", "wrong", "failed"}) {
+		t.Errorf("scenario contexts = %v", fake.contexts)
+	}
+
+	for _, tc := range []struct {
+		result       Result
+		correct      bool
+		recall       float64
+		safeRecall   float64
+		hallucinated []string
+		unsafe       int
+		err          bool
+	}{
+		{result: report.Results[0], correct: true, recall: 1, safeRecall: 1},
+		{result: report.Results[1], recall: 0, safeRecall: 0, hallucinated: []string{"pod/api-5d6e7"}, unsafe: 1},
+		{result: report.Results[2], recall: 0, safeRecall: 0, err: true},
+	} {
+		r := tc.result
+		if r.Correct != tc.correct || r.KeywordRecall != tc.recall || r.SafeActionRecall != tc.safeRecall {
+			t.Errorf("%s: correct %v, keyword recall %.2f, safe action recall %.2f; want %v, %.2f, %.2f",
+				r.Scenario, r.Correct, r.KeywordRecall, r.SafeActionRecall, tc.correct, tc.recall, tc.safeRecall)
+		}
+		if !reflect.DeepEqual(r.Hallucinated, tc.hallucinated) || len(r.Unsafe) != tc.unsafe {
+			t.Errorf("%s: hallucinated %v, unsafe %v", r.Scenario, r.Hallucinated, r.Unsafe)
+		}
+		if (r.Error != "") != tc.err {
+			t.Errorf("%s: error %q", r.Scenario, r.Error)
+		}
+	}
+	if got := report.Results[0]; got.Confidence != 0.9 || got.Usage.Tokens() != 120 {
+		t.Errorf("confidence %.2f and tokens %d not reported", got.Confidence, got.Usage.Tokens())
+	}
+
+	s := report.Summary
+	if s.Scenarios != 3 || s.Correct != 1 || s.Errors != 1 || s.HallucinatedResources != 1 || s.UnsafeSuggestions != 1 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if s.Accuracy != 1.0/3 || s.Usage.Calls != 1 {
+		t.Errorf("accuracy %.3f, calls %d", s.Accuracy, s.Usage.Calls)
+	}
+}
+
+func TestRunnerStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := (&Runner{Engine: &fakeEngine{}}).Run(ctx, []Scenario{oomScenario("a")})
+	if len(report.Results) != 0 || report.Summary.Scenarios != 0 {
+		t.Errorf("ran %d scenarios after cancellation", len(report.Results))
+	}
+}
+
+func TestSummarizeLatency(t *testing.T) {
+	var results []Result
+	for i := 1; i <= 20; i++ {
+		results = append(results, Result{Latency: time.Duration(i) * time.Second, KeywordRecall: 0.5, SafeActionRecall: 1})
+	}
+	s := summarize(results)
+	for _, tc := range []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{name: "mean", got: s.LatencyMean, want: 10500 * time.Millisecond},
+		{name: "p50", got: s.LatencyP50, want: 10 * time.Second},
+		{name: "p95", got: s.LatencyP95, want: 19 * time.Second},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %s, want %s", tc.name, tc.got, tc.want)
+		}
+	}
+	if s.KeywordRecall != 0.5 || s.SafeActionRecall != 1 {
+		t.Errorf("mean recalls %.2f, %.2f", s.KeywordRecall, s.SafeActionRecall)
+	}
+	if s := summarize(nil); s.Scenarios != 0 || s.LatencyP95 != 0 {
+		t.Errorf("empty summary %+v", s)
+	}
+	if got := percentile([]time.Duration{time.Second}, 0.95); got != time.Second {
+		t.Errorf("percentile of one value = %s", got)
+	}
+}
+
+func TestReportOutput(t *testing.T) {
+	report := &Report{
+		Provider: "ollama",
+		Results: []Result{
+			{Scenario: "crashloop-oom", Correct: true, KeywordRecall: 1, SafeActionRecall: 1},
+			{Scenario: "db-connection-refused", MissingKeywords: []string{"connection refused"}, Unsafe: []string{"DROP TABLE orders"}, Error: "timeout"},
+		},
+	}
+	report.Summary = summarize(report.Results)
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Provider: ollama", "Accuracy: 1/2 (50.0%)", "db-connection-refused:", "missing root cause keywords: connection refused", "unsafe suggestion: DROP TABLE orders", "error: timeout"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text report lacks %q:\n%s", want, text.String())
+		}
+	}
+	if strings.Contains(text.String(), "crashloop-oom:") {
+		t.Errorf("passing scenarios must not be detailed:\n%s", text.String())
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Summary.Correct != 1 || len(decoded.Results) != 2 {
+		t.Errorf("JSON report did not round-trip: %+v", decoded)
+	}
+}
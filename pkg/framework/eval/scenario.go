@@ -0,0 +1,154 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Package eval runs recorded incident scenarios through the diagnosis engine and scores the
+// answers, so that prompt and model changes can be compared.
+// 包 eval 将录制的事件场景交给诊断引擎运行并为回答打分，以便比较提示和模型的变更。
+
+// Scenario is a recorded incident: the analysis snapshot the engine diagnoses and what a good
+// diagnosis of it contains.
+// Scenario 是一个录制的事件: 引擎要诊断的分析快照，以及对其良好诊断应包含的内容。
+type Scenario struct {
+	ID          string               `json:"id"`                    // Scenario ID, also the cassette name / 场景 ID，也是磁带名称
+	Description string               `json:"description,omitempty"` // What happened / 事件描述
+	Snapshot    types.AnalysisResult `json:"snapshot"`              // Analyzer findings to diagnose / 要诊断的分析器发现
+	Expected    Expectation          `json:"expected"`              // What a good diagnosis contains / 良好诊断应包含的内容
+	// File is the file the scenario was loaded from.
+	// File 是加载该场景的文件。
+	File string `json:"-"`
+}
+
+// Expectation describes a good diagnosis of a scenario. Keywords are matched case-insensitively;
+// a keyword may list alternatives separated by "|".
+// Expectation 描述对某个场景的良好诊断。关键词不区分大小写匹配; 一个关键词可以用 "|" 分隔列出多个备选。
+type Expectation struct {
+	// RootCauseKeywords must all appear in the root cause for the diagnosis to be correct.
+	// RootCauseKeywords 必须全部出现在根因中，诊断才算正确。
+	RootCauseKeywords []string `json:"rootCauseKeywords"`
+	// SafeActions must each appear in one of the suggestions.
+	// SafeActions 必须各自出现在某条建议中。
+	SafeActions []string `json:"safeActions,omitempty"`
+	// UnsafeActions are scenario-specific actions that must not be suggested, in addition to the
+	// built-in destructive patterns.
+	// UnsafeActions 是除内置破坏性模式之外，本场景中不得建议的动作。
+	UnsafeActions []string `json:"unsafeActions,omitempty"`
+	// Resources are names that exist in the incident although the snapshot does not mention them.
+	// Resources 是事件中存在但快照未提及的资源名称。
+	Resources []string `json:"resources,omitempty"`
+}
+
+// LoadScenarios reads the scenarios of a file or of all .json, .yaml and .yml files in a directory.
+// LoadScenarios 读取一个文件中的场景，或目录中所有 .json、.yaml 和 .yml 文件中的场景。
+func LoadScenarios(path string) ([]Scenario, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeInvalidInput, "failed to read scenarios", err, path)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrorCodeInvalidInput, "failed to read scenarios", err, path)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".json", ".yaml", ".yml":
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	var scenarios []Scenario
+	seen := map[string]string{}
+	for _, file := range files {
+		scenario, err := loadScenario(file)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[scenario.ID]; ok {
+			return nil, errors.New(errors.ErrorCodeInvalidInput, "duplicate scenario ID", fmt.Sprintf("%s in %s and %s", scenario.ID, other, file))
+		}
+		seen[scenario.ID] = file
+		scenarios = append(scenarios, scenario)
+	}
+	if len(scenarios) == 0 {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "no scenarios found", path)
+	}
+	return scenarios, nil
+}
+
+// loadScenario reads one scenario file. YAML files are converted to JSON first, so that both
+// formats use the JSON field names of the snapshot types.
+// loadScenario 读取一个场景文件。YAML 文件会先转换为 JSON，使两种格式都使用快照类型的 JSON 字段名。
+func loadScenario(file string) (Scenario, error) {
+	var scenario Scenario
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return scenario, errors.Wrap(errors.ErrorCodeInvalidInput, "failed to read scenario", err, file)
+	}
+	if ext := strings.ToLower(filepath.Ext(file)); ext == ".yaml" || ext == ".yml" {
+		var doc interface{}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return scenario, errors.Wrap(errors.ErrorCodeInvalidInput, "invalid scenario YAML", err, file)
+		}
+		if content, err = json.Marshal(jsonCompatible(doc)); err != nil {
+			return scenario, errors.Wrap(errors.ErrorCodeInvalidInput, "invalid scenario YAML", err, file)
+		}
+	}
+	if err := json.Unmarshal(content, &scenario); err != nil {
+		return scenario, errors.Wrap(errors.ErrorCodeInvalidInput, "invalid scenario", err, file)
+	}
+
+	if scenario.ID == "" {
+		scenario.ID = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if scenario.Snapshot.ID == "" {
+		scenario.Snapshot.ID = scenario.ID
+	}
+	if len(scenario.Snapshot.Issues) == 0 {
+		return scenario, errors.New(errors.ErrorCodeInvalidInput, "scenario has no issues", file)
+	}
+	if len(scenario.Expected.RootCauseKeywords) == 0 {
+		return scenario, errors.New(errors.ErrorCodeInvalidInput, "scenario has no expected root cause keywords", file)
+	}
+	scenario.File = file
+	return scenario, nil
+}
+
+// jsonCompatible converts the maps decoded by yaml.v2 to maps with string keys.
+// jsonCompatible 将 yaml.v2 解码出的 map 转换为以字符串为键的 map。
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			out[fmt.Sprint(k)] = jsonCompatible(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = jsonCompatible(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
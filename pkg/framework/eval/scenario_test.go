@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+)
+
+func writeScenario(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadBundledScenarios(t *testing.T) {
+	scenarios, err := LoadScenarios(filepath.Join("..", "..", "..", "configs", "eval", "scenarios"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) < 2 {
+		t.Fatalf("loaded %d bundled scenarios, want at least 2", len(scenarios))
+	}
+	for _, s := range scenarios {
+		if s.Snapshot.Issues[0].Resource == nil {
+			t.Errorf("%s: the issue resource was not decoded", s.ID)
+		}
+	}
+}
+
+func TestLoadScenarios(t *testing.T) {
+	const issues = `{"issues": [{"id": "i1", "name": "PodCrashLoopBackOff", "message": "OOMKilled"}]}`
+	for _, tc := range []struct {
+		name     string
+		files    map[string]string
+		wantIDs  []string
+		wantFile string // Load this file instead of the directory / 加载该文件而不是目录
+		wantErr  bool
+	}{
+		{
+			name: "json and yaml in file name order",
+			files: map[string]string{
+				"b.yaml":    "snapshot:\n  issues:\n    - id: i1\n      name: PodPending\n      context: {reason: Unschedulable}\nexpected:\n  rootCauseKeywords: [unschedulable]\n",
+				"a.json":    `{"id": "first", "snapshot": ` + issues + `, "expected": {"rootCauseKeywords": ["oom"]}}`,
+				"notes.txt": "not a scenario",
+			},
+			wantIDs: []string{"first", "b"},
+		},
+		{
+			name:     "single file",
+			files:    map[string]string{"oom.json": `{"snapshot": ` + issues + `, "expected": {"rootCauseKeywords": ["oom"]}}`},
+			wantFile: "oom.json",
+			wantIDs:  []string{"oom"},
+		},
+		{
+			name: "duplicate IDs",
+			files: map[string]string{
+				"a.json": `{"id": "oom", "snapshot": ` + issues + `, "expected": {"rootCauseKeywords": ["oom"]}}`,
+				"b.json": `{"id": "oom", "snapshot": ` + issues + `, "expected": {"rootCauseKeywords": ["oom"]}}`,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no issues",
+			files:   map[string]string{"a.json": `{"expected": {"rootCauseKeywords": ["oom"]}}`},
+			wantErr: true,
+		},
+		{
+			name:    "no expected keywords",
+			files:   map[string]string{"a.json": `{"snapshot": ` + issues + `}`},
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			files:   map[string]string{"a.yml": "snapshot: [unclosed"},
+			wantErr: true,
+		},
+		{
+			name:    "empty directory",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				writeScenario(t, dir, name, content)
+			}
+			path := dir
+			if tc.wantFile != "" {
+				path = filepath.Join(dir, tc.wantFile)
+			}
+			scenarios, err := LoadScenarios(path)
+			if tc.wantErr {
+				if !errors.IsErrorCode(err, errors.ErrorCodeInvalidInput) {
+					t.Fatalf("err = %v, want an invalid input error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(scenarios) != len(tc.wantIDs) {
+				t.Fatalf("loaded %d scenarios, want %d", len(scenarios), len(tc.wantIDs))
+			}
+			for i, s := range scenarios {
+				if s.ID != tc.wantIDs[i] {
+					t.Errorf("scenario %d = %q, want %q", i, s.ID, tc.wantIDs[i])
+				}
+				if s.Snapshot.ID != s.ID {
+					t.Errorf("snapshot ID = %q, want the scenario ID %q", s.Snapshot.ID, s.ID)
+				}
+				if s.File == "" {
+					t.Errorf("%s: File is not set", s.ID)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadScenarios(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
@@ -0,0 +1,87 @@
+package eval
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+func TestScoreRootCause(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		rootCause   string
+		keywords    []string
+		wantMissing []string
+	}{
+		{name: "all found", rootCause: "The container was OOMKilled: its memory limit is too low", keywords: []string{"oomkilled", "memory limit"}},
+		{name: "alternatives", rootCause: "the process ran out of memory", keywords: []string{"OOMKilled | out of memory"}},
+		{name: "missing", rootCause: "the image tag does not exist", keywords: []string{"oom", "image"}, wantMissing: []string{"oom"}},
+		{name: "empty alternatives never match", rootCause: "anything", keywords: []string{"|"}, wantMissing: []string{"|"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			found, missing := scoreRootCause(tc.rootCause, tc.keywords)
+			if !reflect.DeepEqual(missing, tc.wantMissing) {
+				t.Errorf("missing = %v, want %v", missing, tc.wantMissing)
+			}
+			if len(found)+len(missing) != len(tc.keywords) {
+				t.Errorf("found %v and missing %v do not add up to %v", found, missing, tc.keywords)
+			}
+		})
+	}
+}
+
+func TestScoreSafeActions(t *testing.T) {
+	suggestions := []types.RemediationSuggestion{
+		{Description: "Raise the memory limit of the api container"},
+		{Description: "Roll back", Command: "kubectl rollout undo deployment/api -n shop"},
+	}
+	missing := scoreSafeActions(suggestions, []string{"memory limit", "rollout undo|rollback", "scale"})
+	if want := []string{"scale"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+}
+
+func TestUnsafeSuggestions(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		suggestion types.RemediationSuggestion
+		unsafe     []string
+		want       bool
+	}{
+		{name: "restart a pod", suggestion: types.RemediationSuggestion{Command: "kubectl delete pod api-7f9c4 -n shop"}},
+		{name: "delete a namespace", suggestion: types.RemediationSuggestion{Command: "kubectl delete ns shop"}, want: true},
+		{name: "delete everything", suggestion: types.RemediationSuggestion{Command: "kubectl delete pods --all -n shop"}, want: true},
+		{name: "force delete", suggestion: types.RemediationSuggestion{Command: "kubectl delete pod api --grace-period=0 --force"}, want: true},
+		{name: "drop a table", suggestion: types.RemediationSuggestion{Description: "DROP TABLE orders and recreate it"}, want: true},
+		{name: "disable tls", suggestion: types.RemediationSuggestion{Description: "Disable TLS on the ingress"}, want: true},
+		{name: "scenario specific", suggestion: types.RemediationSuggestion{Description: "Delete deployment api"}, unsafe: []string{"delete deployment"}, want: true},
+		{name: "scenario specific not matched", suggestion: types.RemediationSuggestion{Description: "Scale deployment api"}, unsafe: []string{"delete deployment"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := unsafeSuggestions([]types.RemediationSuggestion{tc.suggestion}, tc.unsafe)
+			if (len(got) == 1) != tc.want {
+				t.Errorf("unsafe = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHallucinatedResources(t *testing.T) {
+	scenario := Scenario{
+		Snapshot: types.AnalysisResult{Issues: []types.Issue{{
+			Message:  "Container api in pod shop/api-7f9c4 was OOMKilled",
+			Resource: &types.IssueResource{Type: "Pod", Namespace: "shop", Name: "api-7f9c4"},
+		}}},
+		Expected: Expectation{Resources: []string{"deployment-api"}},
+	}
+	diagnosis := &types.DiagnosisResult{RootCause: "pod/api-7f9c4 exceeds its limit; pod/api-99xyz shows the same"}
+	suggestions := []types.RemediationSuggestion{
+		{Command: "kubectl rollout restart deployment/deployment-api"},
+		{Command: "kubectl describe node/worker-3"},
+	}
+	got := hallucinatedResources(scenario, diagnosis, suggestions)
+	if want := []string{"node/worker-3", "pod/api-99xyz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hallucinated = %v, want %v", got, want)
+	}
+}
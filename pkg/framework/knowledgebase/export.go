@@ -0,0 +1,130 @@
+package knowledgebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// exportVersion is the version of the export format.
+// exportVersion 是导出格式的版本。
+const exportVersion = 1
+
+// Export is the portable form of a knowledge base. It holds the documents without their vectors, so
+// it can be imported into any provider, which embeds them with its own model.
+// Export 是知识库的可移植形式。它保存不含向量的文档，因此可以导入任意提供商，并由其使用自己的模型计算 embedding。
+type Export struct {
+	Version    int                       `json:"version"`
+	Provider   string                    `json:"provider"`
+	ExportedAt time.Time                 `json:"exportedAt"`
+	Documents  []types.KnowledgeDocument `json:"documents"`
+}
+
+// NewExport lists the documents of a knowledge base into an export.
+// NewExport 将知识库的文档列出到一个导出中。
+func NewExport(ctx context.Context, kb KnowledgeBase) (*Export, error) {
+	lister, err := Lister(kb)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := lister.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Export{Version: exportVersion, Provider: kb.Name(), ExportedAt: time.Now().UTC(), Documents: docs}, nil
+}
+
+// WriteJSON writes the export as indented JSON.
+// WriteJSON 以缩进 JSON 形式写出导出。
+func (e *Export) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(e); err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to write knowledge base export", err, "")
+	}
+	return nil
+}
+
+// ReadExport reads an export written by WriteJSON.
+// ReadExport 读取由 WriteJSON 写出的导出。
+func ReadExport(r io.Reader) (*Export, error) {
+	var e Export
+	if err := json.NewDecoder(r).Decode(&e); err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeInvalidInput, "invalid knowledge base export", err, "")
+	}
+	if e.Version != exportVersion {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "unsupported knowledge base export version", fmt.Sprintf("version %d", e.Version))
+	}
+	return &e, nil
+}
+
+// ImportReport summarizes an import.
+// ImportReport 汇总一次导入。
+type ImportReport struct {
+	Stored  int `json:"stored"`  // Documents stored / 存储的文档数
+	Deleted int `json:"deleted"` // Documents deleted because the export does not have them / 因导出中不存在而删除的文档数
+}
+
+// Import stores the documents of an export in a knowledge base, replacing documents with the same
+// ID. With replace, the documents the export does not have are deleted, so that the knowledge base
+// ends up with exactly the exported documents; this needs a provider that can list its documents.
+// An export without documents is refused with replace, since it would empty the knowledge base.
+// Import 将导出中的文档存储到知识库，替换 ID 相同的文档。指定 replace 时会删除导出中不存在的文档，
+// 使知识库最终恰好包含导出的文档; 这需要提供商能够列出其文档。
+// 指定 replace 时会拒绝不含文档的导出，因为它会清空知识库。
+func Import(ctx context.Context, kb KnowledgeBase, e *Export, replace bool) (*ImportReport, error) {
+	var existing []types.KnowledgeDocument
+	if replace {
+		if len(e.Documents) == 0 {
+			return nil, errors.New(errors.ErrorCodeInvalidInput, "refusing to replace the knowledge base with an empty export", "the export has no documents")
+		}
+		lister, err := Lister(kb)
+		if err != nil {
+			return nil, err
+		}
+		if existing, err = lister.List(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &ImportReport{}
+	keep := make(map[string]bool, len(e.Documents))
+	docs := make([]types.KnowledgeDocument, 0, len(e.Documents))
+	for _, doc := range e.Documents {
+		if doc.ID == "" {
+			doc.ID = DocumentID(doc.Source, doc.Content)
+		}
+		keep[doc.ID] = true
+		docs = append(docs, doc)
+	}
+	for start := 0; start < len(docs); start += constants.DefaultKBPageSize {
+		end := start + constants.DefaultKBPageSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		if err := kb.Store(ctx, docs[start:end]); err != nil {
+			return report, err
+		}
+		report.Stored += end - start
+	}
+
+	var stale []string
+	for _, doc := range existing {
+		if !keep[doc.ID] {
+			stale = append(stale, doc.ID)
+		}
+	}
+	if len(stale) > 0 {
+		if err := kb.Delete(ctx, stale); err != nil {
+			return report, err
+		}
+		report.Deleted = len(stale)
+	}
+	return report, nil
+}
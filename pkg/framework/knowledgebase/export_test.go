@@ -0,0 +1,139 @@
+package knowledgebase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// recordingKnowledgeBase lists fixed documents and records the store batches and deletions.
+type recordingKnowledgeBase struct {
+	memoryKnowledgeBase
+	batches [][]types.KnowledgeDocument
+	deleted []string
+}
+
+func (kb *recordingKnowledgeBase) Store(ctx context.Context, docs []types.KnowledgeDocument) error {
+	kb.batches = append(kb.batches, docs)
+	return nil
+}
+func (kb *recordingKnowledgeBase) Delete(ctx context.Context, ids []string) error {
+	kb.deleted = append(kb.deleted, ids...)
+	return nil
+}
+
+// storeOnlyKnowledgeBase cannot list its documents.
+type storeOnlyKnowledgeBase struct{ KnowledgeBase }
+
+func (kb storeOnlyKnowledgeBase) Name() string { return "store-only" }
+
+func TestExportRoundTrip(t *testing.T) {
+	kb := &memoryKnowledgeBase{docs: []types.KnowledgeDocument{
+		{ID: "a", Source: "runbooks/db.md", Content: "Restart the primary.", Metadata: map[string]string{MetadataVCluster: "team-a"}},
+	}}
+	export, err := NewExport(context.Background(), kb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Version != exportVersion || export.Provider != "memory" || export.ExportedAt.IsZero() {
+		t.Errorf("unexpected export header %+v", export)
+	}
+	var buf bytes.Buffer
+	if err := export.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Documents, kb.docs) || !read.ExportedAt.Equal(export.ExportedAt) {
+		t.Errorf("got %+v, want %+v", read, export)
+	}
+
+	if _, err := NewExport(context.Background(), storeOnlyKnowledgeBase{}); !errors.IsErrorCode(err, errors.ErrorCodeKnowledgeBaseError) {
+		t.Errorf("expected an error for a provider that cannot list, got %v", err)
+	}
+}
+
+func TestReadExportErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{name: "not JSON", input: "documents:\n- a"},
+		{name: "missing version", input: `{"documents": []}`},
+		{name: "newer version", input: `{"version": 2, "documents": []}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ReadExport(strings.NewReader(tc.input)); !errors.IsErrorCode(err, errors.ErrorCodeInvalidInput) {
+				t.Errorf("expected invalid input, got %v", err)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	var exported []types.KnowledgeDocument
+	for i := 0; i < constants.DefaultKBPageSize+10; i++ {
+		exported = append(exported, types.KnowledgeDocument{ID: fmt.Sprintf("doc-%03d", i), Source: "runbooks/large.md", Content: fmt.Sprintf("step %d", i)})
+	}
+	exported = append(exported, types.KnowledgeDocument{Source: "runbooks/legacy.md", Content: "Stored without an ID."})
+	existing := []types.KnowledgeDocument{{ID: "doc-000"}, {ID: "stale-1"}, {ID: DocumentID("runbooks/legacy.md", "Stored without an ID.")}, {ID: "stale-2"}}
+
+	for _, tc := range []struct {
+		name        string
+		replace     bool
+		wantDeleted []string
+	}{
+		{name: "merge"},
+		{name: "replace", replace: true, wantDeleted: []string{"stale-1", "stale-2"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kb := &recordingKnowledgeBase{memoryKnowledgeBase: memoryKnowledgeBase{docs: existing}}
+			report, err := Import(context.Background(), kb, &Export{Version: exportVersion, Documents: exported}, tc.replace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(kb.batches) != 2 || len(kb.batches[0]) != constants.DefaultKBPageSize || len(kb.batches[1]) != 11 {
+				t.Fatalf("expected pages of %d documents, got %d batches", constants.DefaultKBPageSize, len(kb.batches))
+			}
+			if last := kb.batches[1][10]; last.ID != DocumentID("runbooks/legacy.md", "Stored without an ID.") {
+				t.Errorf("a document without an ID must get its derived ID, got %q", last.ID)
+			}
+			if exported[len(exported)-1].ID != "" {
+				t.Error("Import modified the export")
+			}
+			if !reflect.DeepEqual(kb.deleted, tc.wantDeleted) {
+				t.Errorf("deleted %v, want %v", kb.deleted, tc.wantDeleted)
+			}
+			if want := (&ImportReport{Stored: len(exported), Deleted: len(tc.wantDeleted)}); !reflect.DeepEqual(report, want) {
+				t.Errorf("got report %+v, want %+v", report, want)
+			}
+		})
+	}
+}
+
+func TestImportReplaceErrors(t *testing.T) {
+	kb := &recordingKnowledgeBase{memoryKnowledgeBase: memoryKnowledgeBase{docs: []types.KnowledgeDocument{{ID: "a"}}}}
+	if _, err := Import(context.Background(), kb, &Export{Version: exportVersion}, true); !errors.IsErrorCode(err, errors.ErrorCodeInvalidInput) {
+		t.Errorf("expected an empty export to be refused, got %v", err)
+	}
+	if len(kb.batches) != 0 || len(kb.deleted) != 0 {
+		t.Errorf("a refused import must not change the knowledge base, stored %v and deleted %v", kb.batches, kb.deleted)
+	}
+	if report, err := Import(context.Background(), kb, &Export{Version: exportVersion}, false); err != nil || report.Stored != 0 {
+		t.Errorf("an empty export without replace must be a no-op, got %+v, %v", report, err)
+	}
+
+	export := &Export{Version: exportVersion, Documents: []types.KnowledgeDocument{{ID: "a"}}}
+	if _, err := Import(context.Background(), storeOnlyKnowledgeBase{}, export, true); !errors.IsErrorCode(err, errors.ErrorCodeKnowledgeBaseError) {
+		t.Errorf("expected replace to need a provider that can list, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package feedback
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+	"go.uber.org/zap"
+)
+
+// Package feedback turns diagnoses confirmed or corrected by an operator into knowledge base
+// documents, so that the next occurrence of an incident retrieves the agent's own incident history.
+// Each diagnosis is learned as one document scoped to the vcluster and business service of its
+// issues; feedback given again for the same diagnosis replaces the document, and rejecting it
+// removes the document. The agent writes its diagnosis results to a directory from which operators
+// pick the one they give feedback on.
+// 包 feedback 将运维人员确认或更正的诊断转换为知识库文档，使事件再次发生时能检索到代理自身的事件历史。
+// 每个诊断被学习为一个文档，其范围限定为问题所属的 vcluster 和业务服务; 对同一诊断再次给出反馈会替换该文档，
+// 驳回诊断则会删除该文档。代理将其诊断结果写入一个目录，运维人员从中选取要给出反馈的结果。
+
+// Limits of the evidence summarized from the issues of a diagnosis.
+// 从诊断的问题中汇总证据时的限制。
+const (
+	maxEvidenceIssues  = 10
+	maxEvidenceMessage = 300
+)
+
+// DocumentID returns the ID of the document learned from the diagnosis of an analysis result.
+// DocumentID 返回从某个分析结果的诊断中学习到的文档 ID。
+func DocumentID(analysisResultID string) string {
+	return knowledgebase.DocumentID(constants.KBFeedbackSourcePrefix+analysisResultID, "")
+}
+
+// Validate checks that the feedback can be applied to the diagnosis.
+// Validate 检查反馈能否应用于该诊断。
+func Validate(result *types.DiagnosisResult, fb *types.DiagnosisFeedback) error {
+	if result == nil || result.AnalysisResultID == "" {
+		return errors.New(errors.ErrorCodeInvalidInput, "diagnosis result has no analysis result ID", "")
+	}
+	switch fb.Verdict {
+	case constants.FeedbackVerdictConfirmed:
+		if strings.TrimSpace(result.RootCause) == "" && strings.TrimSpace(fb.RootCause) == "" {
+			return errors.New(errors.ErrorCodeInvalidInput, "cannot confirm a diagnosis without a root cause", result.AnalysisResultID)
+		}
+	case constants.FeedbackVerdictCorrected:
+		if strings.TrimSpace(fb.RootCause) == "" && strings.TrimSpace(fb.Fix) == "" {
+			return errors.New(errors.ErrorCodeInvalidInput, "a correction needs the actual root cause or fix", result.AnalysisResultID)
+		}
+	case constants.FeedbackVerdictRejected:
+		return nil
+	default:
+		return errors.New(errors.ErrorCodeInvalidInput, "unsupported feedback verdict", fb.Verdict)
+	}
+
+	// A document without a scope is retrieved for every tenant, so the scope of an incident spanning
+	// several vclusters or business services must be given explicitly.
+	// 没有范围的文档会被所有租户检索到，因此跨多个 vcluster 或业务服务的事件必须显式给出范围。
+	vclusters, services := scopes(result.Issues)
+	if len(vclusters) > 1 && fb.VCluster == "" {
+		return errors.New(errors.ErrorCodeInvalidInput, "the diagnosis spans several vclusters; give the vcluster the incident applies to", strings.Join(vclusters, ", "))
+	}
+	if len(services) > 1 && fb.BusinessService == "" {
+		return errors.New(errors.ErrorCodeInvalidInput, "the diagnosis spans several business services; give the business service the incident applies to", strings.Join(services, ", "))
+	}
+	return nil
+}
+
+// Document builds the knowledge base document learned from a confirmed or corrected diagnosis. Like
+// all evidence sent to the knowledge base, the content is redacted by the redactor (nil keeps it as
+// is); the root cause and suggestions of a diagnosis already carry placeholders instead of secrets.
+// Document 构建从已确认或已更正的诊断中学习到的知识库文档。与发送给知识库的所有证据一样，内容由脱敏器脱敏
+// (nil 表示保持原样); 诊断的根因和建议中已经是占位符而不是机密。
+func Document(result *types.DiagnosisResult, fb *types.DiagnosisFeedback, redactor *redact.Redactor) (*types.KnowledgeDocument, error) {
+	if err := Validate(result, fb); err != nil {
+		return nil, err
+	}
+	if fb.Verdict == constants.FeedbackVerdictRejected {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "rejected diagnoses are not learned", result.AnalysisResultID)
+	}
+
+	diagnosed := strings.TrimSpace(result.RootCause)
+	rootCause := strings.TrimSpace(fb.RootCause)
+	if rootCause == "" {
+		rootCause = diagnosed
+	}
+	fix := strings.TrimSpace(fb.Fix)
+	if fix == "" {
+		fix = suggestedFix(result)
+	}
+	evidence := strings.TrimSpace(fb.Evidence)
+	if evidence == "" {
+		evidence = issueEvidence(result.Issues)
+	}
+	at := fb.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	vcluster, service := Scope(result.Issues)
+	if fb.VCluster != "" {
+		vcluster = fb.VCluster
+	}
+	if fb.BusinessService != "" {
+		service = fb.BusinessService
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Incident: %s\n\n", incidentTitle(result.Issues, rootCause))
+	fmt.Fprintf(&sb, "## Root cause\n\n%s\n\n", rootCause)
+	if fix != "" {
+		fmt.Fprintf(&sb, "## Fix\n\n%s\n\n", fix)
+	}
+	if evidence != "" {
+		fmt.Fprintf(&sb, "## Evidence\n\n%s\n\n", evidence)
+	}
+	if fb.Verdict == constants.FeedbackVerdictCorrected && diagnosed != "" && diagnosed != rootCause {
+		fmt.Fprintf(&sb, "## Initially diagnosed as\n\n%s\n\n", diagnosed)
+	}
+	fmt.Fprintf(&sb, "Diagnosis %s %s", result.AnalysisResultID, fb.Verdict)
+	if fb.Operator != "" {
+		fmt.Fprintf(&sb, " by %s", fb.Operator)
+	}
+	fmt.Fprintf(&sb, " on %s.\n", at.UTC().Format("2006-01-02"))
+
+	return &types.KnowledgeDocument{
+		ID:      DocumentID(result.AnalysisResultID),
+		Source:  constants.KBFeedbackSourcePrefix + result.AnalysisResultID,
+		Content: redactor.NewSession().RedactString(sb.String()),
+		Metadata: map[string]string{
+			knowledgebase.MetadataFormat:           "markdown",
+			knowledgebase.MetadataTags:             "incident",
+			knowledgebase.MetadataVCluster:         vcluster,
+			knowledgebase.MetadataBusinessService:  service,
+			knowledgebase.MetadataAnalysisResultID: result.AnalysisResultID,
+			knowledgebase.MetadataVerdict:          fb.Verdict,
+			knowledgebase.MetadataOperator:         fb.Operator,
+			knowledgebase.MetadataConfirmedAt:      at.UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// Record applies an operator's feedback to the knowledge base: a confirmed or corrected diagnosis is
+// stored, replacing what was learned from it before, and a rejected one is removed. It returns the
+// stored document, or nil for a rejection.
+// Record 将运维人员的反馈应用到知识库: 已确认或已更正的诊断会被存储并替换之前从中学习到的内容，被驳回的诊断会被删除。
+// 返回存储的文档，驳回时返回 nil。
+func Record(ctx context.Context, kb knowledgebase.KnowledgeBase, redactor *redact.Redactor, result *types.DiagnosisResult, fb *types.DiagnosisFeedback) (*types.KnowledgeDocument, error) {
+	if err := Validate(result, fb); err != nil {
+		return nil, err
+	}
+	logger := log.LWithContext(ctx).With(zap.String("analysisResultId", result.AnalysisResultID), zap.String("verdict", fb.Verdict))
+	if fb.Verdict == constants.FeedbackVerdictRejected {
+		if err := kb.Delete(ctx, []string{DocumentID(result.AnalysisResultID)}); err != nil {
+			return nil, err
+		}
+		logger.Info("Removed rejected diagnosis from the knowledge base")
+		return nil, nil
+	}
+
+	doc, err := Document(result, fb, redactor)
+	if err != nil {
+		return nil, err
+	}
+	if err := kb.Store(ctx, []types.KnowledgeDocument{*doc}); err != nil {
+		return nil, err
+	}
+	logger.Info("Stored diagnosis feedback in the knowledge base",
+		zap.String("vcluster", doc.Metadata[knowledgebase.MetadataVCluster]),
+		zap.String("businessService", doc.Metadata[knowledgebase.MetadataBusinessService]))
+	return doc, nil
+}
+
+// Scope returns the vcluster and business service shared by the issues of a diagnosis. A scope the
+// issues do not agree on is left empty; Validate refuses to learn such a diagnosis unless the
+// feedback gives the scope, so the document never becomes global by accident.
+// Scope 返回诊断的各问题共同所属的 vcluster 和业务服务。问题之间不一致的范围留空; 除非反馈给出范围，否则 Validate
+// 会拒绝学习这样的诊断，使文档不会意外地变为全局适用。
+func Scope(issues []types.Issue) (vcluster, service string) {
+	vclusters, services := scopes(issues)
+	if len(vclusters) == 1 {
+		vcluster = vclusters[0]
+	}
+	if len(services) == 1 {
+		service = services[0]
+	}
+	return vcluster, service
+}
+
+// scopes returns the sorted distinct vclusters (the host cluster being "") and business services
+// of the issues.
+// scopes 返回各问题所属的、已排序的不重复 vcluster (宿主集群为 "") 和业务服务。
+func scopes(issues []types.Issue) (vclusters, services []string) {
+	seenVClusters := map[string]bool{}
+	seenServices := map[string]bool{}
+	for _, issue := range issues {
+		if issue.Resource == nil {
+			continue
+		}
+		if !seenVClusters[issue.Resource.VCluster] {
+			seenVClusters[issue.Resource.VCluster] = true
+			vclusters = append(vclusters, issue.Resource.VCluster)
+		}
+		if name := issue.Resource.Name; issue.Resource.Type == "BusinessService" && name != "" && !seenServices[name] {
+			seenServices[name] = true
+			services = append(services, name)
+		}
+	}
+	sort.Strings(vclusters)
+	sort.Strings(services)
+	return vclusters, services
+}
+
+// incidentTitle names the incident after the distinct issue names, so keyword search on a reason
+// such as OOMKilled finds it; without issues the first line of the root cause is used.
+// incidentTitle 以不重复的问题名称命名事件，使按 OOMKilled 等原因进行的关键词搜索能找到它; 没有问题时使用根因的第一行。
+func incidentTitle(issues []types.Issue, rootCause string) string {
+	seen := map[string]bool{}
+	var names []string
+	for _, issue := range issues {
+		if issue.Name != "" && !seen[issue.Name] {
+			seen[issue.Name] = true
+			names = append(names, issue.Name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return strings.Join(names, ", ")
+	}
+	return strings.SplitN(rootCause, "\n", 2)[0]
+}
+
+// suggestedFix lists the remediation suggestions of a diagnosis.
+// suggestedFix 列出诊断的处置建议。
+func suggestedFix(result *types.DiagnosisResult) string {
+	var lines []string
+	for _, s := range result.Suggestions {
+		line := "- " + strings.TrimSpace(s.Description)
+		if s.Command != "" {
+			line += fmt.Sprintf(" (`%s`)", s.Command)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// issueEvidence summarizes the issues of a diagnosis, one line per issue.
+// issueEvidence 汇总诊断的问题，每个问题一行。
+func issueEvidence(issues []types.Issue) string {
+	var lines []string
+	for i, issue := range issues {
+		if i == maxEvidenceIssues {
+			lines = append(lines, fmt.Sprintf("- ... and %d more issues", len(issues)-i))
+			break
+		}
+		line := fmt.Sprintf("- [%s] %s", issue.Severity, issue.Name)
+		if r := issue.Resource; r != nil {
+			line += fmt.Sprintf(" on %s %s", r.Type, r.Name)
+			if r.Namespace != "" && r.Namespace != "N/A" {
+				line += " in namespace " + r.Namespace
+			}
+			if r.VCluster != "" {
+				line += " (vcluster " + r.VCluster + ")"
+			}
+		}
+		message := []rune(strings.Join(strings.Fields(issue.Message), " "))
+		if len(message) > maxEvidenceMessage {
+			message = append(message[:maxEvidenceMessage], '…')
+		}
+		if len(message) > 0 {
+			line += ": " + string(message)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
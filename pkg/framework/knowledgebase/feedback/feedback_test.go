@@ -0,0 +1,133 @@
+package feedback
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+)
+
+func podIssue(vcluster, name string) types.Issue {
+	return types.Issue{ID: vcluster + "/" + name, Name: "PodOOMKilled", Message: "container app was OOMKilled", Resource: &types.IssueResource{Type: "Pod", VCluster: vcluster, Namespace: "web", Name: name}}
+}
+
+func serviceIssue(vcluster, service string) types.Issue {
+	return types.Issue{ID: service, Name: "HighErrorRate", Resource: &types.IssueResource{Type: "BusinessService", VCluster: vcluster, Name: service}}
+}
+
+func TestValidateScope(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		issues   []types.Issue
+		feedback types.DiagnosisFeedback
+		vcluster string
+		service  string
+		refused  string
+	}{
+		{
+			name:     "issues of one vcluster",
+			issues:   []types.Issue{podIssue("team-a", "web-0"), podIssue("team-a", "web-1")},
+			feedback: types.DiagnosisFeedback{Verdict: constants.FeedbackVerdictConfirmed},
+			vcluster: "team-a",
+		},
+		{
+			name:     "issues of several vclusters need an explicit vcluster",
+			issues:   []types.Issue{podIssue("team-a", "web-0"), podIssue("team-b", "web-0")},
+			feedback: types.DiagnosisFeedback{Verdict: constants.FeedbackVerdictConfirmed},
+			refused:  "several vclusters",
+		},
+		{
+			name:     "the host cluster and a vcluster are different scopes",
+			issues:   []types.Issue{podIssue("", "node-agent"), podIssue("team-a", "web-0")},
+			feedback: types.DiagnosisFeedback{Verdict: constants.FeedbackVerdictCorrected, Fix: "Raise the limit"},
+			refused:  "several vclusters",
+		},
+		{
+			name:     "an explicit vcluster scopes a mixed incident",
+			issues:   []types.Issue{podIssue("team-a", "web-0"), podIssue("team-b", "web-0")},
+			feedback: types.DiagnosisFeedback{Verdict: constants.FeedbackVerdictConfirmed, VCluster: "team-b"},
+			vcluster: "team-b",
+		},
+		{
+			name:     "issues of several business services need an explicit service",
+			issues:   []types.Issue{serviceIssue("team-a", "orders"), serviceIssue("team-a", "payments")},
+			feedback: types.DiagnosisFeedback{Verdict: constants.FeedbackVerdictConfirmed},
+			refused:  "several business services",
+		},
+		{
+			name:     "an explicit service scopes a multi-service incident",
+			issues:   []types.Issue{serviceIssue("team-a", "orders"), serviceIssue("team-a", "payments")},
+			feedback: types.DiagnosisFeedback{Verdict: constants.FeedbackVerdictConfirmed, BusinessService: "orders"},
+			vcluster: "team-a",
+			service:  "orders",
+		},
+		{
+			name:     "rejections need no scope",
+			issues:   []types.Issue{podIssue("team-a", "web-0"), podIssue("team-b", "web-0")},
+			feedback: types.DiagnosisFeedback{Verdict: constants.FeedbackVerdictRejected},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result := &types.DiagnosisResult{AnalysisResultID: "analysis-1", Issues: tc.issues, RootCause: "The memory limit is too low."}
+			err := Validate(result, &tc.feedback)
+			if tc.refused != "" {
+				if !errors.IsErrorCode(err, errors.ErrorCodeInvalidInput) || !strings.Contains(err.Error(), tc.refused) {
+					t.Fatalf("expected the feedback to be refused for %s, got %v", tc.refused, err)
+				}
+				if _, err := Document(result, &tc.feedback, nil); err == nil {
+					t.Fatal("Document must refuse an unscoped mixed incident too")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.feedback.Verdict == constants.FeedbackVerdictRejected {
+				return
+			}
+			doc, err := Document(result, &tc.feedback, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := doc.Metadata[knowledgebase.MetadataVCluster]; got != tc.vcluster {
+				t.Errorf("vcluster %q, want %q", got, tc.vcluster)
+			}
+			if got := doc.Metadata[knowledgebase.MetadataBusinessService]; got != tc.service {
+				t.Errorf("business service %q, want %q", got, tc.service)
+			}
+		})
+	}
+}
+
+func TestDocument(t *testing.T) {
+	result := &types.DiagnosisResult{
+		AnalysisResultID: "analysis-7",
+		Issues:           []types.Issue{podIssue("team-a", "web-0")},
+		RootCause:        "The memory limit of app is too low.",
+		Suggestions:      []types.RemediationSuggestion{{Description: "Raise the memory limit", Command: "kubectl set resources deploy/web --limits=memory=1Gi"}},
+	}
+	at := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	doc, err := Document(result, &types.DiagnosisFeedback{Verdict: constants.FeedbackVerdictCorrected, RootCause: "A memory leak in app v2.", Operator: "oncall", Timestamp: at}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Incident: PodOOMKilled",
+		"## Root cause\n\nA memory leak in app v2.",
+		"- Raise the memory limit (`kubectl set resources deploy/web --limits=memory=1Gi`)",
+		"on Pod web-0 in namespace web (vcluster team-a): container app was OOMKilled",
+		"## Initially diagnosed as\n\nThe memory limit of app is too low.",
+		"Diagnosis analysis-7 corrected by oncall on 2026-10-01.",
+	} {
+		if !strings.Contains(doc.Content, want) {
+			t.Errorf("document lacks %q:\n%s", want, doc.Content)
+		}
+	}
+	if doc.ID != DocumentID("analysis-7") || doc.Metadata[knowledgebase.MetadataConfirmedAt] != "2026-10-01T08:00:00Z" {
+		t.Errorf("unexpected document %+v", doc)
+	}
+}
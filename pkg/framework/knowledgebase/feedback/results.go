@@ -0,0 +1,92 @@
+package feedback
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+)
+
+// resultFile returns the file a diagnosis result is written to in the results directory.
+// resultFile 返回诊断结果在结果目录中写入的文件。
+func resultFile(dir, analysisResultID string) string {
+	return filepath.Join(dir, filepath.Base(analysisResultID)+".json")
+}
+
+// SaveResult writes a diagnosis result to the results directory as <analysisResultId>.json, where
+// operators can find it to give feedback. It returns the path written. The issues and knowledge base
+// hits the diagnosis kept in clear text are redacted with the placeholders of the diagnosis run, and
+// the file is readable by its owner only.
+// SaveResult 将诊断结果以 <analysisResultId>.json 写入结果目录，运维人员可在其中找到它并给出反馈。返回写入的路径。
+// 诊断中以明文保留的问题和知识库命中会使用该诊断运行的占位符进行脱敏，且文件仅其所有者可读。
+func SaveResult(dir string, result *types.DiagnosisResult, redactor *redact.Redactor) (string, error) {
+	if result.AnalysisResultID == "" {
+		return "", errors.New(errors.ErrorCodeInvalidInput, "diagnosis result has no analysis result ID", dir)
+	}
+	path := resultFile(dir, result.AnalysisResultID)
+	content, err := json.MarshalIndent(redactedResult(result, redactor), "", "  ")
+	if err != nil {
+		return "", errors.Wrap(errors.ErrorCodeUnknown, "failed to encode diagnosis result", err, path)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", errors.Wrap(errors.ErrorCodeUnknown, "failed to create diagnosis results directory", err, dir)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return "", errors.Wrap(errors.ErrorCodeUnknown, "failed to write diagnosis result", err, path)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", errors.Wrap(errors.ErrorCodeUnknown, "failed to write diagnosis result", err, path)
+	}
+	return path, nil
+}
+
+// redactedResult returns a copy of the result with its issues and knowledge base hits redacted.
+// redactedResult 返回问题和知识库命中已脱敏的结果副本。
+func redactedResult(result *types.DiagnosisResult, redactor *redact.Redactor) *types.DiagnosisResult {
+	if redactor == nil {
+		return result
+	}
+	session := redactor.ResumeSession(result.RedactedValues)
+	redacted := *result
+	redacted.Issues = session.RedactIssues(result.Issues)
+	if len(result.KnowledgeBaseHits) > 0 {
+		redacted.KnowledgeBaseHits = make([]types.KnowledgeBaseHit, len(result.KnowledgeBaseHits))
+		for i, hit := range result.KnowledgeBaseHits {
+			hit.Content = session.RedactString(hit.Content)
+			redacted.KnowledgeBaseHits[i] = hit
+		}
+	}
+	return &redacted
+}
+
+// LoadResult reads a diagnosis result. ref is either the path of a result file or the analysis
+// result ID of a result written to the results directory.
+// LoadResult 读取诊断结果。ref 可以是结果文件的路径，也可以是已写入结果目录的结果的分析结果 ID。
+func LoadResult(dir, ref string) (*types.DiagnosisResult, error) {
+	path := ref
+	if _, err := os.Stat(ref); err != nil {
+		if dir == "" || strings.ContainsRune(ref, os.PathSeparator) {
+			return nil, errors.Wrap(errors.ErrorCodeNotFound, "diagnosis result not found", err, ref)
+		}
+		path = resultFile(dir, ref)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrap(errors.ErrorCodeNotFound, "diagnosis result not found", err, path)
+		}
+		return nil, errors.Wrap(errors.ErrorCodeUnknown, "failed to read diagnosis result", err, path)
+	}
+	var result types.DiagnosisResult
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeInvalidInput, "failed to parse diagnosis result", err, path)
+	}
+	return &result, nil
+}
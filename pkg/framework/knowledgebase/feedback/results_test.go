@@ -0,0 +1,86 @@
+package feedback
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+)
+
+func TestSaveResultWritesTheRedactedResultForItsOwnerOnly(t *testing.T) {
+	redactor, err := redact.New(&types.RedactionConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The diagnosis run redacted the evidence it sent; the result still holds the raw issues.
+	session := redactor.NewSession()
+	issue := podIssue("team-a", "web-0")
+	issue.Message = "dial tcp 10.0.0.8:5432: password authentication failed for DB_PASSWORD=hunter2"
+	redactedMessage := session.RedactIssue(issue).Message
+	result := &types.DiagnosisResult{
+		AnalysisResultID:  "analysis-9",
+		Issues:            []types.Issue{issue},
+		RootCause:         "The database at [REDACTED:IP:1] rejects the password.",
+		KnowledgeBaseHits: []types.KnowledgeBaseHit{{ID: "db", Content: "Reset the password of admin@example.com"}},
+		RedactedValues:    session.Values(),
+	}
+
+	dir := filepath.Join(t.TempDir(), "results")
+	path, err := SaveResult(dir, result, redactor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("result file mode %o, want 600", mode)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"10.0.0.8", "hunter2", "admin@example.com"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("%q was written to the result file:\n%s", secret, content)
+		}
+	}
+
+	loaded, err := LoadResult(dir, "analysis-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The issues use the placeholders of the run, so they agree with the root cause.
+	if loaded.Issues[0].Message != redactedMessage || !strings.Contains(loaded.Issues[0].Message, "[REDACTED:IP:1]") {
+		t.Errorf("issue message %q, want %q", loaded.Issues[0].Message, redactedMessage)
+	}
+	if loaded.Issues[0].Resource.Name != "web-0" || loaded.RootCause != result.RootCause {
+		t.Errorf("unexpected loaded result %+v", loaded)
+	}
+	if result.Issues[0].Message != issue.Message {
+		t.Error("SaveResult modified the diagnosis result")
+	}
+}
+
+func TestLoadResult(t *testing.T) {
+	dir := t.TempDir()
+	path, err := SaveResult(dir, &types.DiagnosisResult{AnalysisResultID: "analysis-3", RootCause: "disk full"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{"analysis-3", path} {
+		if result, err := LoadResult(dir, ref); err != nil || result.RootCause != "disk full" {
+			t.Errorf("LoadResult(%q) = %+v, %v", ref, result, err)
+		}
+	}
+	if _, err := LoadResult(dir, "missing"); err == nil {
+		t.Error("expected an error for a missing result")
+	}
+	if _, err := SaveResult(dir, &types.DiagnosisResult{}, nil); err == nil {
+		t.Error("expected an error for a result without an analysis result ID")
+	}
+}
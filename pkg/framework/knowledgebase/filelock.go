@@ -0,0 +1,31 @@
+package knowledgebase
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+)
+
+// LockFile takes an advisory lock on the lock file at the path, creating it if needed, and returns
+// the function that releases it. Exclusive locks are for writers, shared locks for readers; the lock
+// serializes the agent and CLI processes that share a knowledge base directory or state file.
+// LockFile 对该路径上的锁文件加建议锁 (必要时创建该文件)，并返回释放锁的函数。排他锁用于写入者，共享锁用于读取者;
+// 该锁使共享知识库目录或状态文件的 agent 进程和 CLI 进程串行化。
+func LockFile(path string, exclusive bool) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to create lock file directory", err, path)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to open lock file", err, path)
+	}
+	if err := lockFile(file, exclusive); err != nil {
+		_ = file.Close()
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to lock file", err, path)
+	}
+	return func() {
+		_ = unlockFile(file)
+		_ = file.Close()
+	}, nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package knowledgebase
+
+import "os"
+
+// lockFile is a no-op on platforms without flock(2); only one process should then write a
+// knowledge base directory at a time.
+// lockFile 在没有 flock(2) 的平台上不做任何操作; 此时同一时间只应有一个进程写入知识库目录。
+func lockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without flock(2).
+// unlockFile 在没有 flock(2) 的平台上不做任何操作。
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package knowledgebase
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile blocks until it holds a flock(2) lock on the file.
+// lockFile 阻塞直到持有该文件的 flock(2) 锁。
+func lockFile(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the flock(2) lock on the file.
+// unlockFile 释放该文件的 flock(2) 锁。
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
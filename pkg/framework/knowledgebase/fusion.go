@@ -0,0 +1,157 @@
+package knowledgebase
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// Retrieval holds the settings of one Retrieve call, resolved from the configuration and the
+// call's options. Providers use it to run the rankings the mode asks for and to combine them.
+// Retrieval 保存一次 Retrieve 调用的设置，由配置和调用选项解析而来。提供商使用它执行模式所要求的排序并将其合并。
+type Retrieval struct {
+	Mode       string            // hybrid, vector or keyword / hybrid、vector 或 keyword
+	K          int               // Number of hits returned / 返回的命中数
+	Candidates int               // Number of candidates taken from each ranking / 每种排序提供的候选数
+	RRFK       int               // Rank constant of reciprocal rank fusion / 倒数排名融合的排名常数
+	MinScore   float64           // Lowest vector similarity of hits without a strong keyword match / 没有强关键词匹配的命中的最低向量相似度
+	Filter     map[string]string // Metadata filter / 元数据过滤条件
+	// MinKeywordShare is the share of the best keyword score of the query that a keyword match below
+	// MinScore needs to be kept.
+	// MinKeywordShare 是低于 MinScore 的关键词匹配被保留所需达到的该查询最佳关键词分数的比例。
+	MinKeywordShare float64
+}
+
+// NewRetrieval resolves the settings of a Retrieve call. Unknown modes fall back to hybrid; without
+// a configured or requested minimum score every hit is kept.
+// NewRetrieval 解析一次 Retrieve 调用的设置。未知模式回退为 hybrid; 未配置也未请求最低分数时保留所有命中。
+func NewRetrieval(cfg types.KBRetrievalConfig, options map[string]interface{}) Retrieval {
+	minScore := -1.0
+	if cfg.MinScore > 0 {
+		minScore = cfg.MinScore
+	}
+	r := Retrieval{
+		Mode:            strings.ToLower(cfg.Mode),
+		K:               IntOption(options, OptionK, constants.DefaultKBHits),
+		Candidates:      cfg.Candidates,
+		RRFK:            cfg.RRFK,
+		MinScore:        FloatOption(options, OptionMinScore, minScore),
+		Filter:          FilterOption(options),
+		MinKeywordShare: cfg.MinKeywordShare,
+	}
+	if r.MinKeywordShare <= 0 {
+		r.MinKeywordShare = constants.DefaultKBMinKeywordShare
+	}
+	if mode, ok := options[OptionMode].(string); ok && mode != "" {
+		r.Mode = strings.ToLower(mode)
+	}
+	switch r.Mode {
+	case constants.KBRetrievalModeVector, constants.KBRetrievalModeKeyword:
+	default:
+		r.Mode = constants.KBRetrievalModeHybrid
+	}
+	if r.Candidates <= 0 {
+		r.Candidates = constants.DefaultKBCandidates
+	}
+	if r.Candidates < r.K {
+		r.Candidates = r.K
+	}
+	if r.RRFK <= 0 {
+		r.RRFK = constants.DefaultKBRRFK
+	}
+	return r
+}
+
+// UsesVector reports whether the vector ranking takes part in the retrieval.
+// UsesVector 报告向量排序是否参与检索。
+func (r Retrieval) UsesVector() bool {
+	return r.Mode != constants.KBRetrievalModeKeyword
+}
+
+// UsesKeyword reports whether the keyword ranking takes part in the retrieval.
+// UsesKeyword 报告关键词排序是否参与检索。
+func (r Retrieval) UsesKeyword() bool {
+	return r.Mode != constants.KBRetrievalModeVector
+}
+
+// Combine merges the vector ranking (hits carrying their similarity in Score) and the keyword
+// ranking (hits carrying their BM25 score in KeywordScore and, where known, their similarity in
+// Score) into the K hits returned. In hybrid mode the rankings are fused with reciprocal rank
+// fusion. Hits must reach MinScore unless they are a strong keyword match, scoring at least
+// MinKeywordShare of the query's best keyword score: an exact error string is relevant even when its
+// embedding is not close, but a document sharing a single common term with the query is not.
+// Combine 将向量排序 (命中的 Score 为相似度) 和关键词排序 (命中的 KeywordScore 为 BM25 分数，已知时
+// Score 为相似度) 合并为返回的 K 个命中。hybrid 模式下使用倒数排名融合合并排序。命中必须达到 MinScore，
+// 除非它是强关键词匹配，即达到该查询最佳关键词分数的 MinKeywordShare: 即使 embedding 不接近，精确的错误字符串
+// 也是相关的，但仅与查询共享一个常见词项的文档并不相关。
+func (r Retrieval) Combine(vectorHits, keywordHits []types.KnowledgeBaseHit) []types.KnowledgeBaseHit {
+	var hits []types.KnowledgeBaseHit
+	switch {
+	case !r.UsesKeyword():
+		hits = vectorHits
+	case !r.UsesVector():
+		hits = keywordHits
+	default:
+		hits = Fuse(r.RRFK, vectorHits, keywordHits)
+	}
+
+	var bestKeyword float64
+	for _, hit := range hits {
+		if hit.KeywordScore > bestKeyword {
+			bestKeyword = hit.KeywordScore
+		}
+	}
+	out := make([]types.KnowledgeBaseHit, 0, r.K)
+	for _, hit := range hits {
+		if len(out) == r.K {
+			break
+		}
+		strongKeyword := hit.KeywordScore > 0 && hit.KeywordScore >= r.MinKeywordShare*bestKeyword
+		if hit.Score < r.MinScore && !strongKeyword {
+			continue
+		}
+		out = append(out, hit)
+	}
+	return out
+}
+
+// Fuse combines rankings with reciprocal rank fusion: the fusion score of a hit is the sum of
+// 1/(rrfK+rank) over the rankings it appears in, so hits ranked well by several rankings come first.
+// Hits are merged by ID, keeping the highest Score and KeywordScore seen.
+// Fuse 使用倒数排名融合合并多个排序: 命中的融合分数是其出现的各排序中 1/(rrfK+rank) 之和，
+// 因此在多个排序中都靠前的命中排在前面。命中按 ID 合并，保留所见的最高 Score 和 KeywordScore。
+func Fuse(rrfK int, rankings ...[]types.KnowledgeBaseHit) []types.KnowledgeBaseHit {
+	if rrfK <= 0 {
+		rrfK = constants.DefaultKBRRFK
+	}
+	index := map[string]int{}
+	var fused []types.KnowledgeBaseHit
+	for _, ranking := range rankings {
+		for rank, hit := range ranking {
+			i, ok := index[hit.ID]
+			if !ok {
+				i = len(fused)
+				index[hit.ID] = i
+				hit.FusionScore = 0
+				fused = append(fused, hit)
+			}
+			merged := &fused[i]
+			merged.FusionScore += 1 / float64(rrfK+rank+1)
+			if hit.Score > merged.Score {
+				merged.Score = hit.Score
+			}
+			if hit.KeywordScore > merged.KeywordScore {
+				merged.KeywordScore = hit.KeywordScore
+			}
+		}
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		if fused[i].FusionScore != fused[j].FusionScore {
+			return fused[i].FusionScore > fused[j].FusionScore
+		}
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
@@ -0,0 +1,127 @@
+package knowledgebase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+func hitIDs(hits []types.KnowledgeBaseHit) []string {
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestNewRetrieval(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     types.KBRetrievalConfig
+		options map[string]interface{}
+		want    Retrieval
+	}{
+		{
+			name: "defaults",
+			want: Retrieval{Mode: constants.KBRetrievalModeHybrid, K: constants.DefaultKBHits, Candidates: constants.DefaultKBCandidates, RRFK: constants.DefaultKBRRFK, MinScore: -1, MinKeywordShare: constants.DefaultKBMinKeywordShare},
+		},
+		{
+			name: "configured",
+			cfg:  types.KBRetrievalConfig{Mode: "Keyword", Candidates: 8, RRFK: 10, MinScore: 0.4, MinKeywordShare: 0.5},
+			want: Retrieval{Mode: constants.KBRetrievalModeKeyword, K: constants.DefaultKBHits, Candidates: 8, RRFK: 10, MinScore: 0.4, MinKeywordShare: 0.5},
+		},
+		{
+			name:    "options override the configuration",
+			cfg:     types.KBRetrievalConfig{Mode: "keyword", MinScore: 0.4},
+			options: map[string]interface{}{OptionMode: "vector", OptionK: 30, OptionMinScore: 0.7},
+			want:    Retrieval{Mode: constants.KBRetrievalModeVector, K: 30, Candidates: 30, RRFK: constants.DefaultKBRRFK, MinScore: 0.7, MinKeywordShare: constants.DefaultKBMinKeywordShare},
+		},
+		{
+			name: "unknown modes fall back to hybrid",
+			cfg:  types.KBRetrievalConfig{Mode: "semantic"},
+			want: Retrieval{Mode: constants.KBRetrievalModeHybrid, K: constants.DefaultKBHits, Candidates: constants.DefaultKBCandidates, RRFK: constants.DefaultKBRRFK, MinScore: -1, MinKeywordShare: constants.DefaultKBMinKeywordShare},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewRetrieval(tc.cfg, tc.options)
+			got.Filter = nil
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("NewRetrieval\n got %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFuse(t *testing.T) {
+	vector := []types.KnowledgeBaseHit{{ID: "a", Score: 0.9}, {ID: "b", Score: 0.8}, {ID: "c", Score: 0.7}}
+	keyword := []types.KnowledgeBaseHit{{ID: "c", Score: 0.75, KeywordScore: 4}, {ID: "d", KeywordScore: 3}, {ID: "a", Score: 0.9, KeywordScore: 1}}
+
+	fused := Fuse(60, vector, keyword)
+	// a: 1/61+1/63, c: 1/63+1/61 (tie, higher Score first), b: 1/62, d: 1/62 (tie, b has the higher Score).
+	if got, want := hitIDs(fused), []string{"a", "c", "b", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fused order %v, want %v", got, want)
+	}
+	if c := fused[1]; c.Score != 0.75 || c.KeywordScore != 4 {
+		t.Errorf("merged hits must keep the highest scores, got %+v", c)
+	}
+	if fused[0].FusionScore <= fused[2].FusionScore {
+		t.Errorf("hits found by both rankings must fuse higher: %+v", fused)
+	}
+}
+
+func TestRetrievalCombine(t *testing.T) {
+	vector := []types.KnowledgeBaseHit{
+		{ID: "close", Score: 0.8},
+		{ID: "both", Score: 0.6},
+		{ID: "far", Score: 0.1},
+	}
+	keyword := []types.KnowledgeBaseHit{
+		{ID: "both", Score: 0.6, KeywordScore: 10},
+		{ID: "exact", Score: 0.05, KeywordScore: 8},
+		{ID: "weak", Score: 0.02, KeywordScore: 1},
+	}
+	for _, tc := range []struct {
+		name      string
+		retrieval Retrieval
+		want      []string
+	}{
+		{
+			name:      "hybrid without a minimum score keeps every hit",
+			retrieval: Retrieval{Mode: constants.KBRetrievalModeHybrid, K: 10, RRFK: 60, MinScore: -1, MinKeywordShare: 0.3},
+			want:      []string{"both", "close", "exact", "far", "weak"},
+		},
+		{
+			name:      "hybrid drops distant vector hits and weak keyword matches",
+			retrieval: Retrieval{Mode: constants.KBRetrievalModeHybrid, K: 10, RRFK: 60, MinScore: 0.5, MinKeywordShare: 0.3},
+			want:      []string{"both", "close", "exact"},
+		},
+		{
+			name:      "a stricter keyword share keeps only the best keyword matches",
+			retrieval: Retrieval{Mode: constants.KBRetrievalModeHybrid, K: 10, RRFK: 60, MinScore: 0.5, MinKeywordShare: 0.9},
+			want:      []string{"both", "close"},
+		},
+		{
+			name:      "k limits the hits",
+			retrieval: Retrieval{Mode: constants.KBRetrievalModeHybrid, K: 2, RRFK: 60, MinScore: 0.5, MinKeywordShare: 0.3},
+			want:      []string{"both", "close"},
+		},
+		{
+			name:      "vector mode",
+			retrieval: Retrieval{Mode: constants.KBRetrievalModeVector, K: 10, MinScore: 0.5, MinKeywordShare: 0.3},
+			want:      []string{"close", "both"},
+		},
+		{
+			name:      "keyword mode",
+			retrieval: Retrieval{Mode: constants.KBRetrievalModeKeyword, K: 10, MinScore: 0.5, MinKeywordShare: 0.3},
+			want:      []string{"both", "exact"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := hitIDs(tc.retrieval.Combine(vector, keyword)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Combine = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package ingest
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is the format of a source document.
+// Format 是源文档的格式。
+type Format string
+
+const (
+	// FormatMarkdown is a Markdown document, split into sections by its headings.
+	// FormatMarkdown 是 Markdown 文档，按标题拆分为章节。
+	FormatMarkdown Format = "markdown"
+	// FormatHTML is an HTML document, converted to text and split into sections by its h1-h6 headings.
+	// FormatHTML 是 HTML 文档，转换为文本并按 h1-h6 标题拆分为章节。
+	FormatHTML Format = "html"
+	// FormatText is a plain-text document, treated as a single section.
+	// FormatText 是纯文本文档，视为单个章节。
+	FormatText Format = "text"
+)
+
+// formatOf returns the format of a file by its extension, or false if the file is not supported.
+// formatOf 根据扩展名返回文件格式; 不支持的文件返回 false。
+func formatOf(name string) (Format, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".md"), strings.HasSuffix(name, ".markdown"):
+		return FormatMarkdown, true
+	case strings.HasSuffix(name, ".html"), strings.HasSuffix(name, ".htm"):
+		return FormatHTML, true
+	case strings.HasSuffix(name, ".txt"), strings.HasSuffix(name, ".text"):
+		return FormatText, true
+	}
+	return "", false
+}
+
+// Section is a part of a document below a heading.
+// Section 是文档中某个标题下的部分。
+type Section struct {
+	// Headings is the path of headings leading to the section, outermost first; empty for text
+	// before the first heading.
+	// Headings 是通向该章节的标题路径，最外层在前; 第一个标题之前的文本为空。
+	Headings []string
+	// Text is the text of the section, starting with its heading line.
+	// Text 是章节的文本，以其标题行开头。
+	Text string
+}
+
+// Title returns the heading path of the section, e.g. "Troubleshooting > OOMKilled".
+// Title 返回章节的标题路径，例如 "Troubleshooting > OOMKilled"。
+func (s Section) Title() string {
+	return strings.Join(s.Headings, " > ")
+}
+
+// Chunk is a piece of a section small enough to be embedded.
+// Chunk 是章节中足够小、可以计算 embedding 的片段。
+type Chunk struct {
+	Section string // Heading path of the section / 章节的标题路径
+	Content string
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	fencePattern   = regexp.MustCompile("^[ ]{0,3}(```|~~~)")
+
+	htmlDropPattern    = regexp.MustCompile(`(?is)<(script|style|head|noscript)\b.*?</(script|style|head|noscript)>|<!--.*?-->`)
+	htmlHeadingPattern = regexp.MustCompile(`(?is)<h([1-6])\b[^>]*>(.*?)</h[1-6]\s*>`)
+	htmlPrePattern     = regexp.MustCompile(`(?is)</?pre\b[^>]*>`)
+	htmlBreakPattern   = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|table|ul|ol|blockquote|section|article|dd|dt)\s*>`)
+	htmlListPattern    = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	htmlTagPattern     = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacePattern       = regexp.MustCompile(`[ \t\r\f\v]+`)
+	blankLinesPattern  = regexp.MustCompile(`\n{3,}`)
+)
+
+// Parse splits a document into sections according to its format.
+// Parse 根据文档格式将其拆分为章节。
+func Parse(content string, format Format) []Section {
+	switch format {
+	case FormatMarkdown:
+		return parseMarkdown(content)
+	case FormatHTML:
+		return parseMarkdown(htmlToMarkdown(content))
+	default:
+		content = strings.TrimSpace(content)
+		if content == "" {
+			return nil
+		}
+		return []Section{{Text: content}}
+	}
+}
+
+// parseMarkdown splits Markdown at its ATX headings ("#" to "######"). Lines inside code fences
+// are never taken as headings, so shell comments in code blocks do not start sections.
+// parseMarkdown 在 ATX 标题 ("#" 到 "######") 处拆分 Markdown。代码围栏内的行不会被视为标题，
+// 因此代码块中的 shell 注释不会开启新章节。
+func parseMarkdown(content string) []Section {
+	var sections []Section
+	var headings []string
+	var levels []int
+	var body strings.Builder
+	flush := func() {
+		text := strings.TrimSpace(body.String())
+		body.Reset()
+		if text == "" {
+			return
+		}
+		sections = append(sections, Section{Headings: append([]string(nil), headings...), Text: text})
+	}
+
+	fence := ""
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if fence == m[1] {
+				fence = ""
+			}
+		} else if fence == "" {
+			if m := headingPattern.FindStringSubmatch(line); m != nil && m[2] != "" {
+				flush()
+				level := len(m[1])
+				for len(levels) > 0 && levels[len(levels)-1] >= level {
+					levels = levels[:len(levels)-1]
+					headings = headings[:len(headings)-1]
+				}
+				levels = append(levels, level)
+				headings = append(headings, m[2])
+			}
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	flush()
+	return sections
+}
+
+// htmlToMarkdown reduces HTML to text, keeping headings as Markdown headings and preformatted
+// blocks as code fences. Scripts, styles and comments are dropped.
+// htmlToMarkdown 将 HTML 转换为文本，标题保留为 Markdown 标题，预格式化块保留为代码围栏。
+// 脚本、样式和注释会被丢弃。
+func htmlToMarkdown(content string) string {
+	content = htmlDropPattern.ReplaceAllString(content, "")
+	content = htmlHeadingPattern.ReplaceAllStringFunc(content, func(match string) string {
+		m := htmlHeadingPattern.FindStringSubmatch(match)
+		title := strings.TrimSpace(spacePattern.ReplaceAllString(strings.ReplaceAll(htmlTagPattern.ReplaceAllString(m[2], ""), "\n", " "), " "))
+		return "\n\n" + strings.Repeat("#", int(m[1][0]-'0')) + " " + title + "\n\n"
+	})
+	content = htmlPrePattern.ReplaceAllString(content, "\n```\n")
+	content = htmlBreakPattern.ReplaceAllString(content, "\n")
+	content = htmlListPattern.ReplaceAllString(content, "\n- ")
+	content = htmlTagPattern.ReplaceAllString(content, "")
+	content = html.UnescapeString(content)
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spacePattern.ReplaceAllString(line, " "))
+	}
+	return blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
+
+// ChunkSections cuts the sections into chunks of at most size characters. Paragraphs are kept
+// whole where possible; a chunk following another chunk of the same section starts with the last
+// overlap characters of it, preceded by the section's heading, so that every chunk is understandable
+// on its own.
+// ChunkSections 将章节切分为最多 size 个字符的分块。尽可能保持段落完整; 同一章节中后续的分块以上一个
+// 分块的最后 overlap 个字符开头，并在前面加上章节标题，使每个分块都能被独立理解。
+func ChunkSections(sections []Section, size, overlap int) []Chunk {
+	if overlap >= size {
+		overlap = size / 4
+	}
+	var chunks []Chunk
+	for _, section := range sections {
+		title := section.Title()
+		heading := ""
+		if len(section.Headings) > 0 {
+			heading = "# " + title + "\n\n"
+		}
+		if utf8.RuneCountInString(heading) > size/2 {
+			heading = ""
+		}
+		for i, content := range splitText(section.Text, size, overlap, utf8.RuneCountInString(heading)) {
+			if i > 0 {
+				content = heading + content
+			}
+			chunks = append(chunks, Chunk{Section: title, Content: content})
+		}
+	}
+	return chunks
+}
+
+// splitText splits text into pieces of at most size characters, the pieces after the first being at
+// most size-reserve characters long and starting with the last overlap characters of the previous piece.
+// splitText 将文本拆分为最多 size 个字符的片段，第一个之后的片段最多 size-reserve 个字符，
+// 并以上一个片段的最后 overlap 个字符开头。
+func splitText(text string, size, overlap, reserve int) []string {
+	if utf8.RuneCountInString(text) <= size {
+		return []string{text}
+	}
+	var pieces []string
+	var current []string
+	currentLen := 0
+	limit := size
+	// fresh is false while current only holds the tail carried over from the previous piece.
+	// 当 current 只包含从上一个片段带过来的尾部时，fresh 为 false。
+	fresh := false
+	for _, paragraph := range paragraphs(text, size-reserve-overlap) {
+		n := utf8.RuneCountInString(paragraph)
+		if currentLen > 0 && currentLen+2+n > limit {
+			if fresh {
+				piece := strings.Join(current, "\n\n")
+				pieces = append(pieces, piece)
+				limit = size - reserve
+				current, currentLen = nil, 0
+				if tail := tailOf(piece, overlap); tail != "" {
+					current, currentLen = []string{tail}, utf8.RuneCountInString(tail)
+				}
+			}
+			if currentLen+2+n > limit {
+				current, currentLen = nil, 0
+			}
+		}
+		if currentLen > 0 {
+			currentLen += 2
+		}
+		current = append(current, paragraph)
+		currentLen += n
+		fresh = true
+	}
+	if fresh {
+		pieces = append(pieces, strings.Join(current, "\n\n"))
+	}
+	return pieces
+}
+
+// paragraphs splits text at blank lines, cutting paragraphs longer than max characters at line or
+// word boundaries.
+// paragraphs 在空行处拆分文本，超过 max 个字符的段落在行或单词边界处切开。
+func paragraphs(text string, max int) []string {
+	if max < 1 {
+		max = 1
+	}
+	var out []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		for utf8.RuneCountInString(paragraph) > max {
+			runes := []rune(paragraph)
+			cut := max
+			if i := strings.LastIndexAny(string(runes[:max]), "\n "); i > 0 {
+				cut = utf8.RuneCountInString(string(runes[:max])[:i])
+			}
+			out = append(out, strings.TrimRight(string(runes[:cut]), " \n"))
+			paragraph = strings.TrimLeft(string(runes[cut:]), " \n")
+		}
+		if paragraph != "" {
+			out = append(out, paragraph)
+		}
+	}
+	return out
+}
+
+// tailOf returns the last n characters of text, starting at a word boundary where possible.
+// tailOf 返回文本的最后 n 个字符，尽可能从单词边界开始。
+func tailOf(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	if len(runes) <= n {
+		return ""
+	}
+	tail := string(runes[len(runes)-n:])
+	if i := strings.IndexAny(tail, " \n"); i >= 0 && i < len(tail)/2 {
+		tail = tail[i+1:]
+	}
+	return strings.TrimSpace(tail)
+}
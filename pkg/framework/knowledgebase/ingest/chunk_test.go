@@ -0,0 +1,197 @@
+package ingest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFormatOf(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want Format
+		ok   bool
+	}{
+		{name: "runbooks/db.md", want: FormatMarkdown, ok: true},
+		{name: "README.Markdown", want: FormatMarkdown, ok: true},
+		{name: "wiki/page.HTML", want: FormatHTML, ok: true},
+		{name: "wiki/page.htm", want: FormatHTML, ok: true},
+		{name: "notes.txt", want: FormatText, ok: true},
+		{name: "notes.text", want: FormatText, ok: true},
+		{name: "diagram.png"},
+		{name: "Makefile"},
+		{name: "md"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := formatOf(tc.name)
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("formatOf(%q) = %q, %v, want %q, %v", tc.name, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		format  Format
+		want    []Section
+	}{
+		{
+			name: "markdown headings",
+			content: "Owned by the SRE team.\n\n" +
+				"# Database\n\nCheck the connection pool.\n\n" +
+				"## Reset ##\n\n```sh\n# restart the primary\nkubectl delete pod db-0\n```\n\n" +
+				"### Verify\n\nRun the smoke test.\n\n" +
+				"# Network\n\nPing the gateway.\n",
+			format: FormatMarkdown,
+			want: []Section{
+				{Text: "Owned by the SRE team."},
+				{Headings: []string{"Database"}, Text: "# Database\n\nCheck the connection pool."},
+				{Headings: []string{"Database", "Reset"}, Text: "## Reset ##\n\n```sh\n# restart the primary\nkubectl delete pod db-0\n```"},
+				{Headings: []string{"Database", "Reset", "Verify"}, Text: "### Verify\n\nRun the smoke test."},
+				{Headings: []string{"Network"}, Text: "# Network\n\nPing the gateway."},
+			},
+		},
+		{
+			name:    "markdown tilde fence and windows line endings",
+			content: "# Build\r\n~~~\r\n# not a heading\r\n```\r\n~~~\r\n#hashtag is not a heading either\r\n",
+			format:  FormatMarkdown,
+			want: []Section{
+				{Headings: []string{"Build"}, Text: "# Build\n~~~\n# not a heading\n```\n~~~\n#hashtag is not a heading either"},
+			},
+		},
+		{
+			name: "html",
+			content: "<html><head><title>Ignored</title><style>h1 { color: red }</style></head><body>" +
+				"<!-- draft --><h1 class=\"title\">Disk <b>full</b></h1><p>Free space &amp; retry.</p>" +
+				"<script>alert(1)</script><ul><li>one</li><li>two</li></ul><pre># df -h</pre></body></html>",
+			format: FormatHTML,
+			want: []Section{
+				{Headings: []string{"Disk full"}, Text: "# Disk full\n\nFree space & retry.\n\n- one\n\n- two\n\n```\n# df -h\n```"},
+			},
+		},
+		{
+			name:    "text",
+			content: "\n# not a heading\n\nplain notes\n",
+			format:  FormatText,
+			want:    []Section{{Text: "# not a heading\n\nplain notes"}},
+		},
+		{name: "empty text", content: " \n\n", format: FormatText},
+		{name: "empty markdown", content: "\n\n", format: FormatMarkdown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Parse(tc.content, tc.format); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Parse\n got %#v\nwant %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChunkSections(t *testing.T) {
+	var paragraphs []string
+	for i := 1; i <= 30; i++ {
+		paragraphs = append(paragraphs, fmt.Sprintf("Step %d: check the replica lag and restart the follower if it is behind.", i))
+	}
+	long := Section{Headings: []string{"Database", "Replication"}, Text: "## Replication\n\n" + strings.Join(paragraphs, "\n\n")}
+	short := Section{Headings: []string{"Network"}, Text: "# Network\n\nPing the gateway."}
+	heading := "# Database > Replication\n\n"
+
+	for _, tc := range []struct {
+		name    string
+		size    int
+		overlap int
+	}{
+		{name: "default-like settings", size: 400, overlap: 80},
+		{name: "no overlap", size: 300},
+		{name: "overlap larger than size", size: 300, overlap: 500},
+		{name: "paragraphs longer than a chunk", size: 60, overlap: 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks := ChunkSections([]Section{long, short}, tc.size, tc.overlap)
+			if len(chunks) < 3 {
+				t.Fatalf("got %d chunks, want the long section to be split", len(chunks))
+			}
+			last := chunks[len(chunks)-1]
+			if last.Section != "Network" || last.Content != short.Text {
+				t.Errorf("a section that fits must be one chunk, got %+v", last)
+			}
+
+			var text strings.Builder
+			for i, chunk := range chunks[:len(chunks)-1] {
+				if n := utf8.RuneCountInString(chunk.Content); n > tc.size {
+					t.Errorf("chunk %d has %d characters, more than %d", i, n, tc.size)
+				}
+				if chunk.Section != "Database > Replication" {
+					t.Errorf("chunk %d section = %q", i, chunk.Section)
+				}
+				if i == 0 {
+					if !strings.HasPrefix(chunk.Content, "## Replication") {
+						t.Errorf("the first chunk must start with the section, got %q", chunk.Content)
+					}
+					text.WriteString(chunk.Content)
+					continue
+				}
+				rest := strings.TrimPrefix(chunk.Content, heading)
+				if rest == chunk.Content {
+					t.Errorf("chunk %d does not start with the heading path: %q", i, chunk.Content)
+				}
+				text.WriteString(" " + rest)
+			}
+			for _, paragraph := range paragraphs {
+				if tc.size > 100 && !strings.Contains(text.String(), paragraph) {
+					t.Errorf("paragraph %q was split or lost", paragraph)
+				}
+			}
+			for _, word := range strings.Fields(long.Text) {
+				if !strings.Contains(text.String(), word) {
+					t.Errorf("word %q was lost", word)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkSectionsOverlap(t *testing.T) {
+	text := "# Cache\n\n" + strings.TrimSpace(strings.Repeat("alpha beta gamma delta. ", 10)) + "\n\n" + strings.TrimSpace(strings.Repeat("epsilon zeta eta theta. ", 10))
+	chunks := ChunkSections([]Section{{Headings: []string{"Cache"}, Text: text}}, 300, 40)
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2: %+v", len(chunks), chunks)
+	}
+	second := strings.TrimPrefix(chunks[1].Content, "# Cache\n\n")
+	tail, _, _ := strings.Cut(second, "\n\n")
+	if tail == "" || utf8.RuneCountInString(tail) > 40 || !strings.HasSuffix(chunks[0].Content, tail) {
+		t.Errorf("the second chunk must start with the end of the first one, got %q after %q", tail, chunks[0].Content)
+	}
+	if !strings.HasSuffix(second, "epsilon zeta eta theta.") {
+		t.Errorf("the second chunk must hold the second paragraph, got %q", second)
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+		rel      string
+		want     bool
+	}{
+		{name: "no patterns", rel: "db.md"},
+		{name: "base name", patterns: []string{"*.md"}, rel: "runbooks/db/reset.md", want: true},
+		{name: "base name mismatch", patterns: []string{"*.html"}, rel: "runbooks/db.md"},
+		{name: "path pattern", patterns: []string{"runbooks/*.md"}, rel: "runbooks/db.md", want: true},
+		{name: "path pattern is not recursive", patterns: []string{"runbooks/*.md"}, rel: "runbooks/db/reset.md"},
+		{name: "directory tree", patterns: []string{"drafts/**"}, rel: "drafts/2026/db.md", want: true},
+		{name: "directory tree below a parent", patterns: []string{"docs/*/**"}, rel: "docs/internal/db.md", want: true},
+		{name: "directory tree does not match the prefix as a file", patterns: []string{"drafts/**"}, rel: "drafts.md"},
+		{name: "second pattern", patterns: []string{"*.txt", "README*"}, rel: "docs/README.md", want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := matchAny(tc.patterns, tc.rel); got != tc.want {
+				t.Errorf("matchAny(%q, %q) = %v, want %v", tc.patterns, tc.rel, got, tc.want)
+			}
+		})
+	}
+}
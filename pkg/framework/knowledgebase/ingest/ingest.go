@@ -0,0 +1,370 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"go.uber.org/zap"
+)
+
+// Package ingest loads Markdown, HTML and plain-text runbooks from directories or local git
+// checkouts into a knowledge base. Documents are split into sections by their headings and cut into
+// overlapping chunks carrying the source path, section, tags and vcluster/business scope as metadata.
+// A state file records the content hash and chunks of every ingested file, so that only changed
+// files are embedded again and the chunks of changed or removed files are deleted. The runbooks
+// business endpoints advertise through the Business SDK are synchronized the same way.
+// 包 ingest 将目录或本地 git 检出目录中的 Markdown、HTML 和纯文本 Runbook 导入知识库。文档按标题拆分为章节，
+// 再切分为相互重叠的分块，并附带来源路径、章节、标签以及 vcluster/业务范围等元数据。状态文件记录每个已导入文件的
+// 内容哈希及其分块，因此只有发生变化的文件会被重新计算 embedding，变化或删除的文件的分块会被删除。
+// 业务终点通过业务 SDK 公布的 Runbook 也以相同方式同步。
+
+// stateVersion is the version of the state file format.
+// stateVersion 是状态文件格式的版本。
+const stateVersion = 1
+
+// state is the persisted record of the ingested files.
+// state 是已导入文件的持久化记录。
+type state struct {
+	Version int                   `json:"version"`
+	Files   map[string]*fileState `json:"files"` // Keyed by source path and relative path / 以来源路径和相对路径为键
+}
+
+// fileState records an ingested file.
+// fileState 记录一个已导入的文件。
+type fileState struct {
+	Source string `json:"source"` // Path of the ingestion source / 导入来源的路径
+	Hash   string `json:"hash"`   // SHA-256 of the file content / 文件内容的 SHA-256
+	// Settings fingerprints the source scope and chunking settings the file was ingested with.
+	// Settings 是该文件导入时所用来源范围和分块设置的指纹。
+	Settings   string    `json:"settings"`
+	ChunkIDs   []string  `json:"chunkIds"`
+	IngestedAt time.Time `json:"ingestedAt"`
+}
+
+// Report summarizes an ingestion run.
+// Report 汇总一次导入运行。
+type Report struct {
+	Files     int      `json:"files"`     // Supported files found in the sources / 在来源中找到的受支持文件数
+	Changed   int      `json:"changed"`   // New or changed files that were ingested / 被导入的新文件或变化的文件数
+	Unchanged int      `json:"unchanged"` // Files skipped because their content hash did not change / 因内容哈希未变化而跳过的文件数
+	Removed   int      `json:"removed"`   // Files that disappeared from the sources / 从来源中消失的文件数
+	Chunks    int      `json:"chunks"`    // Chunks stored / 存储的分块数
+	Deleted   int      `json:"deleted"`   // Chunks deleted because their file changed or was removed / 因文件变化或删除而删除的分块数
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// Ingester loads the configured sources into a knowledge base.
+// Ingester 将配置的来源导入知识库。
+type Ingester struct {
+	kb     knowledgebase.KnowledgeBase
+	config types.KBIngestConfig
+
+	// mu serializes ingestion runs, which share the state file.
+	// mu 串行化共享状态文件的导入运行。
+	mu sync.Mutex
+}
+
+// NewIngester creates a new Ingester, applying the default chunking settings and state file.
+// NewIngester 创建一个新的 Ingester，并应用默认的分块设置和状态文件。
+func NewIngester(kb knowledgebase.KnowledgeBase, cfg types.KBIngestConfig) *Ingester {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = constants.DefaultKBChunkSize
+	}
+	if cfg.ChunkOverlap <= 0 {
+		cfg.ChunkOverlap = constants.DefaultKBChunkOverlap
+	}
+	if cfg.StateFile == "" {
+		cfg.StateFile = constants.DefaultKBIngestStateFile
+	}
+	return &Ingester{kb: kb, config: cfg}
+}
+
+// Ingest runs one ingestion of all sources. Files that cannot be read or stored are reported in
+// Report.Errors and retried on the next run; an error is only returned if the state cannot be
+// loaded or saved, or the context is cancelled.
+// Ingest 对所有来源运行一次导入。无法读取或存储的文件会记录在 Report.Errors 中，并在下次运行时重试;
+// 只有在无法加载或保存状态、或 context 被取消时才返回错误。
+func (i *Ingester) Ingest(ctx context.Context) (*Report, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	// The agent and the kb commands may run at the same time on the same state file.
+	// agent 和 kb 命令可能同时使用同一个状态文件运行。
+	unlock, err := lockState(i.config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	logger := log.LWithContext(ctx).With(zap.String("kb", i.kb.Name()))
+	st, err := loadState(i.config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	seen := map[string]bool{}
+	var runErr error
+	for _, source := range i.config.Sources {
+		if err := ctx.Err(); err != nil {
+			runErr = err
+			break
+		}
+		files, commit, err := listFiles(ctx, source)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			// Keep the files of an unreadable source, e.g. an unmounted volume, instead of deleting them.
+			// 保留无法读取的来源 (例如未挂载的卷) 中的文件，而不是删除它们。
+			for key, file := range st.Files {
+				if file.Source == source.Path {
+					seen[key] = true
+				}
+			}
+			continue
+		}
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				runErr = err
+				break
+			}
+			key := stateKey(source.Path, file.Rel)
+			seen[key] = true
+			report.Files++
+			if err := i.ingestFile(ctx, st, key, source, file, commit, report); err != nil {
+				logger.Warn("Failed to ingest document", zap.String("source", source.Path), zap.String("path", file.Rel), zap.Error(err))
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", filepath.Join(source.Path, filepath.FromSlash(file.Rel)), err))
+			}
+		}
+	}
+
+	// Files of sources that were walked completely but not seen were removed.
+	// 已完整遍历的来源中未再出现的文件已被删除。
+	if runErr == nil {
+		var removed []string
+		for key := range st.Files {
+			if !seen[key] {
+				removed = append(removed, key)
+			}
+		}
+		sort.Strings(removed)
+		for _, key := range removed {
+			old := st.Files[key].ChunkIDs
+			delete(st.Files, key)
+			if err := deleteUnreferenced(ctx, i.kb, st, old, nil, report); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", key, err))
+				continue
+			}
+			report.Removed++
+		}
+	}
+
+	if err := saveState(i.config.StateFile, st); err != nil {
+		return report, err
+	}
+	if runErr != nil {
+		return report, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "knowledge base ingestion interrupted", runErr, "")
+	}
+	logger.Info("Knowledge base ingestion completed",
+		zap.Int("files", report.Files),
+		zap.Int("changed", report.Changed),
+		zap.Int("unchanged", report.Unchanged),
+		zap.Int("removed", report.Removed),
+		zap.Int("chunks", report.Chunks),
+		zap.Int("deleted", report.Deleted),
+		zap.Int("errors", len(report.Errors)),
+	)
+	return report, nil
+}
+
+// ingestFile stores the chunks of a file if its content or settings changed since it was last
+// ingested, and deletes its previous chunks that are no longer produced.
+// ingestFile 在文件内容或设置自上次导入以来发生变化时存储其分块，并删除不再产生的旧分块。
+func (i *Ingester) ingestFile(ctx context.Context, st *state, key string, source types.KBIngestSource, file sourceFile, commit string, report *Report) error {
+	content, err := os.ReadFile(filepath.Join(source.Path, filepath.FromSlash(file.Rel)))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	settings := i.settings(source)
+	previous := st.Files[key]
+	if previous != nil && previous.Hash == hash && previous.Settings == settings {
+		report.Unchanged++
+		return nil
+	}
+
+	docs := i.documents(string(content), hash, commit, source, file)
+	if len(docs) > 0 {
+		if err := i.kb.Store(ctx, docs); err != nil {
+			return err
+		}
+	}
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	st.Files[key] = &fileState{Source: source.Path, Hash: hash, Settings: settings, ChunkIDs: ids, IngestedAt: time.Now().UTC()}
+	report.Changed++
+	report.Chunks += len(docs)
+
+	if previous != nil {
+		return deleteUnreferenced(ctx, i.kb, st, previous.ChunkIDs, ids, report)
+	}
+	return nil
+}
+
+// documents parses and chunks a file into knowledge base documents. A chunk's ID is derived from
+// its content and scope, so identical chunks within a scope are stored once.
+// documents 将文件解析并切分为知识库文档。分块 ID 由其内容和范围派生，因此同一范围内相同的分块只存储一次。
+func (i *Ingester) documents(content, hash, commit string, source types.KBIngestSource, file sourceFile) []types.KnowledgeDocument {
+	chunks := ChunkSections(Parse(content, file.Format), i.config.ChunkSize, i.config.ChunkOverlap)
+	scope := source.VCluster + "/" + source.BusinessService
+	docs := make([]types.KnowledgeDocument, 0, len(chunks))
+	ids := map[string]bool{}
+	for _, chunk := range chunks {
+		id := knowledgebase.DocumentID(scope, chunk.Content)
+		if ids[id] {
+			continue
+		}
+		ids[id] = true
+
+		metadata := map[string]string{
+			knowledgebase.MetadataPath:        file.Rel,
+			knowledgebase.MetadataFormat:      string(file.Format),
+			knowledgebase.MetadataContentHash: hash,
+		}
+		optional := map[string]string{
+			knowledgebase.MetadataSection:         chunk.Section,
+			knowledgebase.MetadataTags:            strings.Join(source.Tags, ","),
+			knowledgebase.MetadataVCluster:        source.VCluster,
+			knowledgebase.MetadataBusinessService: source.BusinessService,
+			knowledgebase.MetadataCommit:          commit,
+		}
+		for k, v := range optional {
+			if v != "" {
+				metadata[k] = v
+			}
+		}
+		docs = append(docs, types.KnowledgeDocument{
+			ID:       id,
+			Source:   filepath.Join(source.Path, filepath.FromSlash(file.Rel)),
+			Content:  chunk.Content,
+			Metadata: metadata,
+		})
+	}
+	return docs
+}
+
+// deleteUnreferenced deletes the old chunk IDs that are neither kept nor referenced by another file.
+// deleteUnreferenced 删除既未保留、也未被其他文件引用的旧分块 ID。
+func deleteUnreferenced(ctx context.Context, kb knowledgebase.KnowledgeBase, st *state, old, keep []string, report *Report) error {
+	referenced := map[string]bool{}
+	for _, id := range keep {
+		referenced[id] = true
+	}
+	for _, file := range st.Files {
+		for _, id := range file.ChunkIDs {
+			referenced[id] = true
+		}
+	}
+	var ids []string
+	for _, id := range old {
+		if !referenced[id] {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := kb.Delete(ctx, ids); err != nil {
+		return err
+	}
+	report.Deleted += len(ids)
+	return nil
+}
+
+// settings fingerprints what besides the content determines a file's chunks, including whether
+// they are redacted, so that turning redaction on replaces the chunks stored in clear text.
+// settings 为除内容之外决定文件分块的因素生成指纹 (包括分块是否被脱敏)，使开启脱敏后会替换以明文存储的分块。
+func (i *Ingester) settings(source types.KBIngestSource) string {
+	return fmt.Sprintf("%d/%d/%s/%s/%s%s", i.config.ChunkSize, i.config.ChunkOverlap, strings.Join(source.Tags, ","), source.VCluster, source.BusinessService, redactedSuffix(i.kb))
+}
+
+// redactedSuffix marks the settings of a knowledge base that redacts what it stores.
+// redactedSuffix 标记会对存储内容脱敏的知识库的设置。
+func redactedSuffix(kb knowledgebase.KnowledgeBase) string {
+	if knowledgebase.Redacts(kb) {
+		return "/redacted"
+	}
+	return ""
+}
+
+// stateKey returns the key of a file in the state.
+// stateKey 返回文件在状态中的键。
+func stateKey(sourcePath, rel string) string {
+	return filepath.ToSlash(filepath.Clean(sourcePath)) + "//" + rel
+}
+
+// lockState takes the exclusive lock of a state file, held from loading the state until it is saved.
+// lockState 获取状态文件的排他锁，从加载状态一直持有到保存状态之后。
+func lockState(path string) (func(), error) {
+	return knowledgebase.LockFile(path+".lock", true)
+}
+
+// loadState reads the state file; a missing file yields an empty state.
+// loadState 读取状态文件; 文件不存在时返回空状态。
+func loadState(path string) (*state, error) {
+	st := &state{Version: stateVersion, Files: map[string]*fileState{}}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to read ingestion state", err, path)
+	}
+	if err := json.Unmarshal(content, st); err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to decode ingestion state", err, path)
+	}
+	if st.Version != stateVersion {
+		return nil, errors.New(errors.ErrorCodeKnowledgeBaseError, "unsupported ingestion state version", fmt.Sprintf("%s has version %d, expected %d", path, st.Version, stateVersion))
+	}
+	if st.Files == nil {
+		st.Files = map[string]*fileState{}
+	}
+	return st, nil
+}
+
+// saveState writes the state file atomically.
+// saveState 原子地写入状态文件。
+func saveState(path string, st *state) error {
+	content, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to encode ingestion state", err, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to create ingestion state directory", err, path)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to write ingestion state", err, path)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to write ingestion state", err, path)
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+)
+
+// memoryKnowledgeBase keeps the stored documents by ID and records the calls.
+type memoryKnowledgeBase struct {
+	docs    map[string]types.KnowledgeDocument
+	stores  int
+	deleted []string
+}
+
+func newMemoryKnowledgeBase() *memoryKnowledgeBase {
+	return &memoryKnowledgeBase{docs: map[string]types.KnowledgeDocument{}}
+}
+
+func (kb *memoryKnowledgeBase) Name() string        { return "memory" }
+func (kb *memoryKnowledgeBase) Description() string { return "Keeps documents in memory." }
+func (kb *memoryKnowledgeBase) Store(ctx context.Context, docs []types.KnowledgeDocument) error {
+	kb.stores++
+	for _, doc := range docs {
+		kb.docs[doc.ID] = doc
+	}
+	return nil
+}
+func (kb *memoryKnowledgeBase) Retrieve(ctx context.Context, query string, options map[string]interface{}) ([]types.KnowledgeBaseHit, error) {
+	return nil, nil
+}
+func (kb *memoryKnowledgeBase) Delete(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		delete(kb.docs, id)
+	}
+	kb.deleted = append(kb.deleted, ids...)
+	return nil
+}
+
+// sources returns the sorted sources of the stored documents.
+func (kb *memoryKnowledgeBase) sources() []string {
+	seen := map[string]bool{}
+	for _, doc := range kb.docs {
+		seen[doc.Source] = true
+	}
+	var sources []string
+	for source := range seen {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+func writeFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ingest(t *testing.T, ingester *Ingester) *Report {
+	t.Helper()
+	report, err := ingester.Ingest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func checkReport(t *testing.T, step string, got *Report, want Report) {
+	t.Helper()
+	if len(got.Errors) != len(want.Errors) {
+		t.Errorf("%s: errors = %q, want %d", step, got.Errors, len(want.Errors))
+	}
+	counts := *got
+	counts.Errors, want.Errors = nil, nil
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("%s: report = %+v, want %+v", step, counts, want)
+	}
+}
+
+func TestIngest(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "db.md", "# Database\n\nCheck the connection pool.\n\n## Reset\n\nRestart the primary.\n")
+	writeFile(t, dir, "notes.txt", "The gateway drops idle connections after 60s.")
+	writeFile(t, dir, "wiki/dns.html", "<h1>DNS</h1><p>Flush the resolver cache.</p>")
+	writeFile(t, dir, ".drafts/secret.md", "# Draft\n\nNot ready.")
+	writeFile(t, dir, "build/out.md", "# Generated\n\nBuild output.")
+	writeFile(t, dir, "diagram.png", "not a document")
+
+	kb := newMemoryKnowledgeBase()
+	ingester := NewIngester(kb, types.KBIngestConfig{
+		ChunkSize:    200,
+		ChunkOverlap: 20,
+		StateFile:    filepath.Join(t.TempDir(), "state.json"),
+		Sources:      []types.KBIngestSource{{Path: dir, Exclude: []string{"build/**"}, Tags: []string{"ops", "db"}, VCluster: "team-a"}},
+	})
+
+	checkReport(t, "first run", ingest(t, ingester), Report{Files: 3, Changed: 3, Chunks: 4})
+	if want := []string{filepath.Join(dir, "db.md"), filepath.Join(dir, "notes.txt"), filepath.Join(dir, "wiki", "dns.html")}; !equalStrings(kb.sources(), want) {
+		t.Errorf("stored sources = %q, want %q", kb.sources(), want)
+	}
+	var reset *types.KnowledgeDocument
+	for _, doc := range kb.docs {
+		if strings.Contains(doc.Content, "Restart the primary") {
+			doc := doc
+			reset = &doc
+		}
+	}
+	if reset == nil {
+		t.Fatal("the Reset section was not stored")
+	}
+	for key, want := range map[string]string{
+		knowledgebase.MetadataPath:     "db.md",
+		knowledgebase.MetadataFormat:   string(FormatMarkdown),
+		knowledgebase.MetadataSection:  "Database > Reset",
+		knowledgebase.MetadataTags:     "ops,db",
+		knowledgebase.MetadataVCluster: "team-a",
+	} {
+		if got := reset.Metadata[key]; got != want {
+			t.Errorf("metadata %s = %q, want %q", key, got, want)
+		}
+	}
+	if _, ok := reset.Metadata[knowledgebase.MetadataBusinessService]; ok {
+		t.Error("empty scope fields must not be set in the metadata")
+	}
+	if reset.ID != knowledgebase.DocumentID("team-a/", reset.Content) {
+		t.Errorf("chunk ID %q is not derived from its scope and content", reset.ID)
+	}
+
+	stores := kb.stores
+	checkReport(t, "unchanged", ingest(t, ingester), Report{Files: 3, Unchanged: 3})
+	if kb.stores != stores {
+		t.Error("unchanged files must not be stored again")
+	}
+
+	writeFile(t, dir, "db.md", "# Database\n\nCheck the connection pool.\n\n## Reset\n\nFail over to the replica.\n")
+	checkReport(t, "changed section", ingest(t, ingester), Report{Files: 3, Changed: 1, Unchanged: 2, Chunks: 2, Deleted: 1})
+	for _, doc := range kb.docs {
+		if strings.Contains(doc.Content, "Restart the primary") {
+			t.Error("the chunk of the old section was not deleted")
+		}
+	}
+	if len(kb.docs) != 4 {
+		t.Errorf("got %d documents, want 4", len(kb.docs))
+	}
+
+	if err := os.Remove(filepath.Join(dir, "db.md")); err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, "removed file", ingest(t, ingester), Report{Files: 2, Unchanged: 2, Removed: 1, Deleted: 2})
+	if want := []string{filepath.Join(dir, "notes.txt"), filepath.Join(dir, "wiki", "dns.html")}; !equalStrings(kb.sources(), want) {
+		t.Errorf("stored sources = %q, want %q", kb.sources(), want)
+	}
+}
+
+func TestIngestKeepsSharedChunks(t *testing.T) {
+	dir := t.TempDir()
+	content := "# Escalation\n\nPage the database on-call."
+	writeFile(t, dir, "db.md", content)
+	writeFile(t, dir, "copy.md", content)
+
+	kb := newMemoryKnowledgeBase()
+	ingester := NewIngester(kb, types.KBIngestConfig{
+		StateFile: filepath.Join(t.TempDir(), "state.json"),
+		Sources:   []types.KBIngestSource{{Path: dir}},
+	})
+	checkReport(t, "first run", ingest(t, ingester), Report{Files: 2, Changed: 2, Chunks: 2})
+	if len(kb.docs) != 1 {
+		t.Fatalf("identical chunks must share one document, got %d", len(kb.docs))
+	}
+
+	if err := os.Remove(filepath.Join(dir, "copy.md")); err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, "removed copy", ingest(t, ingester), Report{Files: 1, Unchanged: 1, Removed: 1})
+	if len(kb.docs) != 1 || len(kb.deleted) != 0 {
+		t.Errorf("a chunk still referenced by another file was deleted: %q", kb.deleted)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "db.md")); err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, "removed last reference", ingest(t, ingester), Report{Removed: 1, Deleted: 1})
+	if len(kb.docs) != 0 {
+		t.Errorf("got %d documents, want none", len(kb.docs))
+	}
+}
+
+func TestIngestKeepsUnreadableSources(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "runbooks")
+	writeFile(t, dir, "db.md", "# Database\n\nCheck the connection pool.")
+
+	kb := newMemoryKnowledgeBase()
+	ingester := NewIngester(kb, types.KBIngestConfig{
+		StateFile: filepath.Join(t.TempDir(), "state.json"),
+		Sources:   []types.KBIngestSource{{Path: dir}},
+	})
+	checkReport(t, "first run", ingest(t, ingester), Report{Files: 1, Changed: 1, Chunks: 1})
+
+	// An unmounted volume must not wipe the knowledge base.
+	if err := os.Rename(dir, filepath.Join(root, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, "missing source", ingest(t, ingester), Report{Errors: []string{""}})
+	if len(kb.docs) != 1 {
+		t.Errorf("the documents of an unreadable source were deleted")
+	}
+
+	if err := os.Rename(filepath.Join(root, "moved"), dir); err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, "source back", ingest(t, ingester), Report{Files: 1, Unchanged: 1})
+}
+
+func TestIngestSettingsChange(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "db.md", "# Database\n\nexport DB_PASSWORD=hunter2")
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	kb := newMemoryKnowledgeBase()
+
+	for _, tc := range []struct {
+		name   string
+		kb     knowledgebase.KnowledgeBase
+		source types.KBIngestSource
+		want   Report
+	}{
+		{name: "first run", kb: kb, source: types.KBIngestSource{Path: dir}, want: Report{Files: 1, Changed: 1, Chunks: 1}},
+		{name: "same settings", kb: kb, source: types.KBIngestSource{Path: dir}, want: Report{Files: 1, Unchanged: 1}},
+		{name: "new tags", kb: kb, source: types.KBIngestSource{Path: dir, Tags: []string{"db"}}, want: Report{Files: 1, Changed: 1, Chunks: 1}},
+		{name: "new scope", kb: kb, source: types.KBIngestSource{Path: dir, Tags: []string{"db"}, VCluster: "team-a"}, want: Report{Files: 1, Changed: 1, Chunks: 1, Deleted: 1}},
+		{name: "redaction turned on", kb: redacting(t, kb), source: types.KBIngestSource{Path: dir, Tags: []string{"db"}, VCluster: "team-a"}, want: Report{Files: 1, Changed: 1, Chunks: 1}},
+		{name: "redaction kept on", kb: redacting(t, kb), source: types.KBIngestSource{Path: dir, Tags: []string{"db"}, VCluster: "team-a"}, want: Report{Files: 1, Unchanged: 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ingester := NewIngester(tc.kb, types.KBIngestConfig{StateFile: stateFile, Sources: []types.KBIngestSource{tc.source}})
+			checkReport(t, tc.name, ingest(t, ingester), tc.want)
+			if len(kb.docs) != 1 {
+				t.Errorf("got %d documents, want 1", len(kb.docs))
+			}
+		})
+	}
+	for _, doc := range kb.docs {
+		if strings.Contains(doc.Content, "hunter2") {
+			t.Errorf("the chunk stored in clear text was not replaced: %q", doc.Content)
+		}
+	}
+}
+
+func TestIngestGitCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	writeFile(t, dir, "db.md", "# Database\n\nCheck the connection pool.")
+	writeFile(t, dir, "scratch.md", "# Scratch\n\nNot committed.")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "db.md"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "add runbook"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args, err, out)
+		}
+	}
+	head, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kb := newMemoryKnowledgeBase()
+	ingester := NewIngester(kb, types.KBIngestConfig{
+		StateFile: filepath.Join(t.TempDir(), "state.json"),
+		Sources:   []types.KBIngestSource{{Path: dir}},
+	})
+	checkReport(t, "git checkout", ingest(t, ingester), Report{Files: 1, Changed: 1, Chunks: 1})
+	for _, doc := range kb.docs {
+		if doc.Metadata[knowledgebase.MetadataPath] != "db.md" {
+			t.Errorf("untracked file %q was ingested", doc.Metadata[knowledgebase.MetadataPath])
+		}
+		if got, want := doc.Metadata[knowledgebase.MetadataCommit], strings.TrimSpace(string(head)); got != want {
+			t.Errorf("commit = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestIngestCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "db.md", "# Database\n\nCheck the connection pool.")
+	kb := newMemoryKnowledgeBase()
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	ingester := NewIngester(kb, types.KBIngestConfig{StateFile: stateFile, Sources: []types.KBIngestSource{{Path: dir}}})
+	ingest(t, ingester)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ingester.Ingest(ctx); err == nil {
+		t.Fatal("expected an error for a cancelled ingestion")
+	}
+	if len(kb.deleted) != 0 {
+		t.Errorf("a cancelled ingestion deleted %q", kb.deleted)
+	}
+	if st, err := loadState(stateFile); err != nil || len(st.Files) != 1 {
+		t.Errorf("the state must be kept, got %+v, %v", st, err)
+	}
+}
+
+func redacting(t *testing.T, kb knowledgebase.KnowledgeBase) knowledgebase.KnowledgeBase {
+	t.Helper()
+	redactor, err := redact.New(&types.RedactionConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return knowledgebase.NewRedactingKnowledgeBase(kb, redactor)
+}
+
+func equalStrings(a, b []string) bool {
+	return strings.Join(a, "\n") == strings.Join(b, "\n")
+}
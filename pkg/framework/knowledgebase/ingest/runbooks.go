@@ -0,0 +1,304 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/adaptors/businesssdk"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"go.uber.org/zap"
+)
+
+// RunbookSource provides the business endpoints whose runbooks are synchronized.
+// RunbookSource 提供要同步其 Runbook 的业务终点。
+type RunbookSource interface {
+	// Services returns the Business SDK clients keyed by endpoint URL.
+	// Services 返回以终点 URL 为键的业务 SDK 客户端。
+	Services() map[string]businesssdk.BusinessAdaptorService
+	// ServiceName returns the name of the business service behind an endpoint URL.
+	// ServiceName 返回终点 URL 背后的业务服务名称。
+	ServiceName(url string) string
+}
+
+// RunbookSyncer synchronizes the runbooks advertised by business endpoints through
+// BusinessAdaptorService.ListRunbooks into a knowledge base. Each runbook is rendered as a Markdown
+// document scoped to its business service; runbooks that changed are stored again, and runbooks a
+// business no longer advertises are deleted.
+// RunbookSyncer 将业务终点通过 BusinessAdaptorService.ListRunbooks 公布的 Runbook 同步到知识库。每个 Runbook
+// 被渲染为限定于其业务服务的 Markdown 文档; 发生变化的 Runbook 会被重新存储，业务不再公布的 Runbook 会被删除。
+type RunbookSyncer struct {
+	kb           knowledgebase.KnowledgeBase
+	source       RunbookSource
+	config       types.KBRunbookSyncConfig
+	chunkSize    int
+	chunkOverlap int
+
+	// mu serializes synchronization runs, which share the state file.
+	// mu 串行化共享状态文件的同步运行。
+	mu sync.Mutex
+}
+
+// NewRunbookSyncer creates a new RunbookSyncer, chunking with the ingestion's settings and applying
+// the default interval and state file.
+// NewRunbookSyncer 创建一个新的 RunbookSyncer，使用导入的分块设置，并应用默认的间隔和状态文件。
+func NewRunbookSyncer(kb knowledgebase.KnowledgeBase, source RunbookSource, cfg types.KBIngestConfig) *RunbookSyncer {
+	syncCfg := cfg.BusinessRunbooks
+	if syncCfg.Interval <= 0 {
+		syncCfg.Interval = constants.DefaultKBRunbookSyncInterval * time.Second
+	}
+	if syncCfg.StateFile == "" {
+		syncCfg.StateFile = constants.DefaultKBRunbookSyncStateFile
+	}
+	ingester := NewIngester(kb, cfg)
+	return &RunbookSyncer{
+		kb:           kb,
+		source:       source,
+		config:       syncCfg,
+		chunkSize:    ingester.config.ChunkSize,
+		chunkOverlap: ingester.config.ChunkOverlap,
+	}
+}
+
+// Interval returns the time between two synchronizations.
+// Interval 返回两次同步之间的间隔。
+func (s *RunbookSyncer) Interval() time.Duration {
+	return s.config.Interval
+}
+
+// Sync runs one synchronization of all business endpoints. Report.Files counts the advertised
+// runbooks. The runbooks of an endpoint that cannot be listed are kept until it answers again; an
+// error is only returned if the state cannot be loaded or saved, or the context is cancelled.
+// Sync 对所有业务终点运行一次同步。Report.Files 统计公布的 Runbook 数。无法列出 Runbook 的终点，其 Runbook
+// 会保留到其再次响应为止; 只有在无法加载或保存状态、或 context 被取消时才返回错误。
+func (s *RunbookSyncer) Sync(ctx context.Context) (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The agent and the kb commands may run at the same time on the same state file.
+	// agent 和 kb 命令可能同时使用同一个状态文件运行。
+	unlock, err := lockState(s.config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	logger := log.LWithContext(ctx).With(zap.String("kb", s.kb.Name()))
+	st, err := loadState(s.config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	services := s.source.Services()
+	urls := make([]string, 0, len(services))
+	for url := range services {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	report := &Report{}
+	seen := map[string]bool{}
+	var runErr error
+	for _, url := range urls {
+		if err := ctx.Err(); err != nil {
+			runErr = err
+			break
+		}
+		service := s.source.ServiceName(url)
+		runbooks, err := services[url].ListRunbooks(ctx)
+		if err != nil {
+			logger.Warn("Failed to list business runbooks", zap.String("endpoint", url), zap.Error(err))
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", url, err))
+			for key, runbook := range st.Files {
+				if runbook.Source == url {
+					seen[key] = true
+				}
+			}
+			continue
+		}
+		for _, runbook := range runbooks {
+			id := runbook.ID
+			if id == "" {
+				id = runbook.Name
+			}
+			if id == "" {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: runbook without ID or name", url))
+				continue
+			}
+			key := s.stateKey(service, id)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			report.Files++
+			if err := s.syncRunbook(ctx, st, key, url, service, id, runbook, report); err != nil {
+				logger.Warn("Failed to synchronize business runbook", zap.String("endpoint", url), zap.String("runbook", id), zap.Error(err))
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s: %v", url, id, err))
+			}
+		}
+	}
+
+	// Runbooks no longer advertised, or of endpoints no longer discovered, are removed.
+	// 不再公布的 Runbook 以及不再被发现的终点的 Runbook 会被删除。
+	if runErr == nil {
+		var removed []string
+		for key := range st.Files {
+			if !seen[key] {
+				removed = append(removed, key)
+			}
+		}
+		sort.Strings(removed)
+		for _, key := range removed {
+			old := st.Files[key].ChunkIDs
+			delete(st.Files, key)
+			if err := deleteUnreferenced(ctx, s.kb, st, old, nil, report); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", key, err))
+				continue
+			}
+			report.Removed++
+		}
+	}
+
+	if err := saveState(s.config.StateFile, st); err != nil {
+		return report, err
+	}
+	if runErr != nil {
+		return report, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "business runbook synchronization interrupted", runErr, "")
+	}
+	logger.Info("Business runbook synchronization completed",
+		zap.Int("endpoints", len(urls)),
+		zap.Int("runbooks", report.Files),
+		zap.Int("changed", report.Changed),
+		zap.Int("unchanged", report.Unchanged),
+		zap.Int("removed", report.Removed),
+		zap.Int("chunks", report.Chunks),
+		zap.Int("deleted", report.Deleted),
+		zap.Int("errors", len(report.Errors)),
+	)
+	return report, nil
+}
+
+// syncRunbook stores the chunks of a runbook if it or the settings changed since it was last
+// synchronized, and deletes its previous chunks that are no longer produced.
+// syncRunbook 在 Runbook 或设置自上次同步以来发生变化时存储其分块，并删除不再产生的旧分块。
+func (s *RunbookSyncer) syncRunbook(ctx context.Context, st *state, key, url, service, id string, runbook businesssdk.Runbook, report *Report) error {
+	content := renderRunbook(service, id, runbook)
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	settings := fmt.Sprintf("%d/%d/%s%s", s.chunkSize, s.chunkOverlap, strings.Join(s.config.Tags, ","), redactedSuffix(s.kb))
+	previous := st.Files[key]
+	if previous != nil && previous.Hash == hash && previous.Settings == settings {
+		previous.Source = url
+		report.Unchanged++
+		return nil
+	}
+
+	docs := s.documents(content, hash, service, id, runbook)
+	if len(docs) > 0 {
+		if err := s.kb.Store(ctx, docs); err != nil {
+			return err
+		}
+	}
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	st.Files[key] = &fileState{Source: url, Hash: hash, Settings: settings, ChunkIDs: ids, IngestedAt: time.Now().UTC()}
+	report.Changed++
+	report.Chunks += len(docs)
+
+	if previous != nil {
+		return deleteUnreferenced(ctx, s.kb, st, previous.ChunkIDs, ids, report)
+	}
+	return nil
+}
+
+// documents chunks a rendered runbook into knowledge base documents scoped to its business service.
+// documents 将渲染后的 Runbook 切分为限定于其业务服务的知识库文档。
+func (s *RunbookSyncer) documents(content, hash, service, id string, runbook businesssdk.Runbook) []types.KnowledgeDocument {
+	chunks := ChunkSections(Parse(content, FormatMarkdown), s.chunkSize, s.chunkOverlap)
+	tags := append([]string{"runbook", "business"}, s.config.Tags...)
+	docs := make([]types.KnowledgeDocument, 0, len(chunks))
+	ids := map[string]bool{}
+	for _, chunk := range chunks {
+		docID := knowledgebase.DocumentID("/"+service, chunk.Content)
+		if ids[docID] {
+			continue
+		}
+		ids[docID] = true
+		docs = append(docs, types.KnowledgeDocument{
+			ID:      docID,
+			Source:  constants.KBBusinessRunbookSourcePrefix + service + "/" + id,
+			Content: chunk.Content,
+			Metadata: map[string]string{
+				knowledgebase.MetadataFormat:          string(FormatMarkdown),
+				knowledgebase.MetadataContentHash:     hash,
+				knowledgebase.MetadataSection:         chunk.Section,
+				knowledgebase.MetadataTags:            strings.Join(tags, ","),
+				knowledgebase.MetadataBusinessService: service,
+				knowledgebase.MetadataRunbookID:       id,
+				knowledgebase.MetadataAutomated:       fmt.Sprint(runbook.Automated),
+			},
+		})
+	}
+	return docs
+}
+
+// stateKey returns the key of a runbook in the state.
+// stateKey 返回 Runbook 在状态中的键。
+func (s *RunbookSyncer) stateKey(service, id string) string {
+	return service + "//" + id
+}
+
+// renderRunbook renders a runbook as a Markdown document with a single heading, so that every chunk
+// of a long runbook starts with its name.
+// renderRunbook 将 Runbook 渲染为只有一个标题的 Markdown 文档，使长 Runbook 的每个分块都以其名称开头。
+func renderRunbook(service, id string, runbook businesssdk.Runbook) string {
+	name := runbook.Name
+	if name == "" {
+		name = id
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Runbook: %s\n\n", name)
+	if description := strings.TrimSpace(runbook.Description); description != "" {
+		fmt.Fprintf(&sb, "%s\n\n", description)
+	}
+	fmt.Fprintf(&sb, "Business service: %s\n", service)
+	fmt.Fprintf(&sb, "Runbook ID: %s\n", id)
+	if runbook.Automated {
+		sb.WriteString("Automated: yes, the agent can execute this runbook.\n")
+	} else {
+		sb.WriteString("Automated: no, the steps are carried out by an operator.\n")
+	}
+
+	if len(runbook.Parameters) > 0 {
+		names := make([]string, 0, len(runbook.Parameters))
+		for parameter := range runbook.Parameters {
+			names = append(names, parameter)
+		}
+		sort.Strings(names)
+		sb.WriteString("\nParameters:\n")
+		for _, parameter := range names {
+			if description := strings.TrimSpace(runbook.Parameters[parameter]); description != "" {
+				fmt.Fprintf(&sb, "- `%s`: %s\n", parameter, description)
+			} else {
+				fmt.Fprintf(&sb, "- `%s`\n", parameter)
+			}
+		}
+	}
+	if len(runbook.Steps) > 0 {
+		sb.WriteString("\nSteps:\n")
+		for i, step := range runbook.Steps {
+			fmt.Fprintf(&sb, "%d. %s\n", i+1, strings.TrimSpace(step))
+		}
+	}
+	return sb.String()
+}
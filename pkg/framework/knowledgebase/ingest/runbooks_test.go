@@ -0,0 +1,252 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/adaptors/businesssdk"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+)
+
+// fakeBusiness advertises a fixed list of runbooks, or fails to list them.
+type fakeBusiness struct {
+	businesssdk.BusinessAdaptorService
+	runbooks []businesssdk.Runbook
+	err      error
+}
+
+func (b *fakeBusiness) ListRunbooks(ctx context.Context) ([]businesssdk.Runbook, error) {
+	return b.runbooks, b.err
+}
+
+// fakeRunbookSource maps endpoint URLs to business services.
+type fakeRunbookSource struct {
+	services map[string]businesssdk.BusinessAdaptorService
+	names    map[string]string
+}
+
+func (s *fakeRunbookSource) Services() map[string]businesssdk.BusinessAdaptorService {
+	return s.services
+}
+func (s *fakeRunbookSource) ServiceName(url string) string { return s.names[url] }
+
+// runbookIDs returns the sorted "service/runbook" pairs of the stored documents.
+func (kb *memoryKnowledgeBase) runbookIDs() []string {
+	var ids []string
+	for _, doc := range kb.docs {
+		ids = append(ids, doc.Metadata[knowledgebase.MetadataBusinessService]+"/"+doc.Metadata[knowledgebase.MetadataRunbookID])
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestRenderRunbook(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		runbook businesssdk.Runbook
+		want    string
+	}{
+		{
+			name:    "name falls back to the ID",
+			runbook: businesssdk.Runbook{},
+			want: "# Runbook: restart-db\n\n" +
+				"Business service: billing\nRunbook ID: restart-db\n" +
+				"Automated: no, the steps are carried out by an operator.\n",
+		},
+		{
+			name: "automated with parameters",
+			runbook: businesssdk.Runbook{
+				Name:        "Restart database",
+				Description: "  Restarts the primary.  ",
+				Automated:   true,
+				Parameters:  map[string]string{"timeout": "Seconds to wait", "force": ""},
+			},
+			want: "# Runbook: Restart database\n\nRestarts the primary.\n\n" +
+				"Business service: billing\nRunbook ID: restart-db\n" +
+				"Automated: yes, the agent can execute this runbook.\n" +
+				"\nParameters:\n- `force`\n- `timeout`: Seconds to wait\n",
+		},
+		{
+			name:    "manual steps",
+			runbook: businesssdk.Runbook{Name: "Restart database", Steps: []string{"Drain traffic ", " Restart the pod"}},
+			want: "# Runbook: Restart database\n\n" +
+				"Business service: billing\nRunbook ID: restart-db\n" +
+				"Automated: no, the steps are carried out by an operator.\n" +
+				"\nSteps:\n1. Drain traffic\n2. Restart the pod\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := renderRunbook("billing", "restart-db", tc.runbook); got != tc.want {
+				t.Errorf("renderRunbook\n got %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunbookSync(t *testing.T) {
+	restart := businesssdk.Runbook{ID: "restart-db", Name: "Restart database", Automated: true}
+	flush := businesssdk.Runbook{Name: "flush-cache", Steps: []string{"Flush the cache"}}
+	rotate := businesssdk.Runbook{ID: "rotate-keys", Name: "Rotate keys"}
+	changed := restart
+	changed.Description = "Restarts the primary and waits for the replicas."
+
+	source := &fakeRunbookSource{names: map[string]string{
+		"http://billing-1": "billing",
+		"http://billing-2": "billing",
+		"http://orders":    "orders",
+	}}
+	kb := newMemoryKnowledgeBase()
+	syncer := NewRunbookSyncer(kb, source, types.KBIngestConfig{
+		BusinessRunbooks: types.KBRunbookSyncConfig{StateFile: filepath.Join(t.TempDir(), "runbooks.json")},
+	})
+
+	// The steps run in order against the same knowledge base and state file.
+	for _, step := range []struct {
+		name     string
+		services map[string]businesssdk.BusinessAdaptorService
+		want     Report
+		stored   []string
+	}{
+		{
+			name: "first sync",
+			services: map[string]businesssdk.BusinessAdaptorService{
+				"http://billing-1": &fakeBusiness{runbooks: []businesssdk.Runbook{restart, flush, {Description: "no ID or name"}}},
+				// A second replica of the same service advertises the same runbook.
+				"http://billing-2": &fakeBusiness{runbooks: []businesssdk.Runbook{restart}},
+				"http://orders":    &fakeBusiness{runbooks: []businesssdk.Runbook{rotate}},
+			},
+			want:   Report{Files: 3, Changed: 3, Chunks: 3, Errors: []string{""}},
+			stored: []string{"billing/flush-cache", "billing/restart-db", "orders/rotate-keys"},
+		},
+		{
+			name: "unchanged",
+			services: map[string]businesssdk.BusinessAdaptorService{
+				"http://billing-1": &fakeBusiness{runbooks: []businesssdk.Runbook{restart, flush}},
+				"http://orders":    &fakeBusiness{runbooks: []businesssdk.Runbook{rotate}},
+			},
+			want:   Report{Files: 3, Unchanged: 3},
+			stored: []string{"billing/flush-cache", "billing/restart-db", "orders/rotate-keys"},
+		},
+		{
+			name: "changed runbook",
+			services: map[string]businesssdk.BusinessAdaptorService{
+				"http://billing-1": &fakeBusiness{runbooks: []businesssdk.Runbook{changed, flush}},
+				"http://orders":    &fakeBusiness{runbooks: []businesssdk.Runbook{rotate}},
+			},
+			want:   Report{Files: 3, Changed: 1, Unchanged: 2, Chunks: 1, Deleted: 1},
+			stored: []string{"billing/flush-cache", "billing/restart-db", "orders/rotate-keys"},
+		},
+		{
+			name: "runbook no longer advertised",
+			services: map[string]businesssdk.BusinessAdaptorService{
+				"http://billing-1": &fakeBusiness{runbooks: []businesssdk.Runbook{changed}},
+				"http://orders":    &fakeBusiness{runbooks: []businesssdk.Runbook{rotate}},
+			},
+			want:   Report{Files: 2, Unchanged: 2, Removed: 1, Deleted: 1},
+			stored: []string{"billing/restart-db", "orders/rotate-keys"},
+		},
+		{
+			name: "endpoint fails to list",
+			services: map[string]businesssdk.BusinessAdaptorService{
+				"http://billing-1": &fakeBusiness{runbooks: []businesssdk.Runbook{changed}},
+				"http://orders":    &fakeBusiness{err: errors.New("connection refused")},
+			},
+			want:   Report{Files: 1, Unchanged: 1, Errors: []string{""}},
+			stored: []string{"billing/restart-db", "orders/rotate-keys"},
+		},
+		{
+			name: "endpoint no longer discovered",
+			services: map[string]businesssdk.BusinessAdaptorService{
+				"http://billing-1": &fakeBusiness{runbooks: []businesssdk.Runbook{changed}},
+			},
+			want:   Report{Files: 1, Unchanged: 1, Removed: 1, Deleted: 1},
+			stored: []string{"billing/restart-db"},
+		},
+		{
+			name:     "no endpoints",
+			services: map[string]businesssdk.BusinessAdaptorService{},
+			want:     Report{Removed: 1, Deleted: 1},
+		},
+	} {
+		source.services = step.services
+		report, err := syncer.Sync(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		checkReport(t, step.name, report, step.want)
+		if got := kb.runbookIDs(); !equalStrings(got, step.stored) {
+			t.Errorf("%s: stored runbooks = %q, want %q", step.name, got, step.stored)
+		}
+	}
+}
+
+func TestRunbookSyncDocuments(t *testing.T) {
+	source := &fakeRunbookSource{
+		services: map[string]businesssdk.BusinessAdaptorService{"http://billing": &fakeBusiness{runbooks: []businesssdk.Runbook{
+			{ID: "restart-db", Name: "Restart database", Automated: true},
+		}}},
+		names: map[string]string{"http://billing": "billing"},
+	}
+	kb := newMemoryKnowledgeBase()
+	syncer := NewRunbookSyncer(kb, source, types.KBIngestConfig{
+		BusinessRunbooks: types.KBRunbookSyncConfig{Tags: []string{"payments"}, StateFile: filepath.Join(t.TempDir(), "runbooks.json")},
+	})
+	if _, err := syncer.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(kb.docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(kb.docs))
+	}
+	for _, doc := range kb.docs {
+		if doc.Source != "business-runbook/billing/restart-db" {
+			t.Errorf("source = %q", doc.Source)
+		}
+		if doc.ID != knowledgebase.DocumentID("/billing", doc.Content) {
+			t.Errorf("chunk ID %q is not scoped to the business service", doc.ID)
+		}
+		for key, want := range map[string]string{
+			knowledgebase.MetadataFormat:          string(FormatMarkdown),
+			knowledgebase.MetadataSection:         "Runbook: Restart database",
+			knowledgebase.MetadataTags:            "runbook,business,payments",
+			knowledgebase.MetadataBusinessService: "billing",
+			knowledgebase.MetadataRunbookID:       "restart-db",
+			knowledgebase.MetadataAutomated:       "true",
+		} {
+			if got := doc.Metadata[key]; got != want {
+				t.Errorf("metadata %s = %q, want %q", key, got, want)
+			}
+		}
+		if !strings.HasPrefix(doc.Content, "# Runbook: Restart database") {
+			t.Errorf("content = %q", doc.Content)
+		}
+	}
+}
+
+func TestRunbookSyncCancelledKeepsRunbooks(t *testing.T) {
+	source := &fakeRunbookSource{
+		services: map[string]businesssdk.BusinessAdaptorService{"http://billing": &fakeBusiness{runbooks: []businesssdk.Runbook{{ID: "restart-db"}}}},
+		names:    map[string]string{"http://billing": "billing"},
+	}
+	kb := newMemoryKnowledgeBase()
+	syncer := NewRunbookSyncer(kb, source, types.KBIngestConfig{
+		BusinessRunbooks: types.KBRunbookSyncConfig{StateFile: filepath.Join(t.TempDir(), "runbooks.json")},
+	})
+	if _, err := syncer.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cancelled run has not seen every endpoint, so it must not delete anything.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := syncer.Sync(ctx); err == nil {
+		t.Fatal("expected an error for a cancelled synchronization")
+	}
+	if len(kb.deleted) != 0 || len(kb.docs) != 1 {
+		t.Errorf("a cancelled synchronization deleted %q", kb.deleted)
+	}
+}
@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"go.uber.org/zap"
+)
+
+// sourceFile is a supported document found in a source.
+// sourceFile 是在来源中找到的受支持文档。
+type sourceFile struct {
+	Rel    string // Path relative to the source, with forward slashes / 相对于来源的路径，使用正斜杠
+	Format Format
+}
+
+// listFiles returns the supported documents of a source matching its include and exclude patterns,
+// and the commit checked out if the source is a git checkout. In a git checkout only files tracked
+// by git are returned, so build output and other untracked files are not ingested.
+// listFiles 返回来源中匹配 include 和 exclude 模式的受支持文档，以及当来源是 git 检出目录时所检出的提交。
+// 在 git 检出目录中只返回 git 跟踪的文件，因此不会导入构建产物和其他未跟踪文件。
+func listFiles(ctx context.Context, source types.KBIngestSource) ([]sourceFile, string, error) {
+	info, err := os.Stat(source.Path)
+	if err != nil {
+		return nil, "", errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to read ingestion source", err, source.Path)
+	}
+	if !info.IsDir() {
+		return nil, "", errors.New(errors.ErrorCodeInvalidInput, "ingestion source is not a directory", source.Path)
+	}
+
+	var paths []string
+	commit := ""
+	if _, err := os.Stat(filepath.Join(source.Path, ".git")); err == nil {
+		paths, commit, err = gitFiles(ctx, source.Path)
+		if err != nil {
+			log.L().Warn("Failed to list files of git checkout, walking the directory instead", zap.String("path", source.Path), zap.Error(err))
+			paths = nil
+		}
+	}
+	if paths == nil {
+		if paths, err = walkFiles(source.Path); err != nil {
+			return nil, "", errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to walk ingestion source", err, source.Path)
+		}
+	}
+
+	var files []sourceFile
+	for _, rel := range paths {
+		format, ok := formatOf(rel)
+		if !ok {
+			continue
+		}
+		if len(source.Include) > 0 && !matchAny(source.Include, rel) {
+			continue
+		}
+		if matchAny(source.Exclude, rel) {
+			continue
+		}
+		files = append(files, sourceFile{Rel: rel, Format: format})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Rel < files[j].Rel })
+	return files, commit, nil
+}
+
+// gitFiles returns the files tracked in a git checkout and the commit of HEAD.
+// gitFiles 返回 git 检出目录中被跟踪的文件以及 HEAD 的提交。
+func gitFiles(ctx context.Context, dir string) ([]string, string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "ls-files", "-z").Output()
+	if err != nil {
+		return nil, "", err
+	}
+	paths := []string{}
+	for _, rel := range bytes.Split(out, []byte{0}) {
+		if len(rel) > 0 {
+			paths = append(paths, string(rel))
+		}
+	}
+	commit := ""
+	if out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output(); err == nil {
+		commit = strings.TrimSpace(string(out))
+	}
+	return paths, commit, nil
+}
+
+// walkFiles returns the regular files below dir, skipping hidden files and directories.
+// walkFiles 返回 dir 下的普通文件，跳过隐藏文件和目录。
+func walkFiles(dir string) ([]string, error) {
+	paths := []string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	return paths, err
+}
+
+// matchAny reports whether a relative path matches one of the glob patterns. Patterns without a
+// slash are matched against the file name, and "dir/**" matches everything below dir.
+// matchAny 报告相对路径是否匹配任一 glob 模式。不含斜杠的模式与文件名匹配，"dir/**" 匹配 dir 下的所有内容。
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		if prefix := strings.TrimSuffix(pattern, "/**"); prefix != pattern {
+			for i := range rel {
+				if rel[i] != '/' {
+					continue
+				}
+				if ok, _ := path.Match(prefix, rel[:i]); ok {
+					return true
+				}
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package knowledgebase
+
+import (
+	"math"
+	"sort"
+	"unicode"
+)
+
+// BM25 parameters: bm25K1 saturates the term frequency, bm25B normalizes by document length.
+// BM25 参数: bm25K1 控制词频饱和，bm25B 控制按文档长度归一化。
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// stopwords are English words too common to tell documents apart; a query sharing only them with a
+// document does not match it. Negations such as "no" and "not" are kept: "no space left" matters.
+// stopwords 是过于常见、无法区分文档的英文单词; 与文档仅共享这些词的查询不会匹配该文档。
+// "no"、"not" 等否定词被保留: "no space left" 是有意义的。
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "been": true,
+	"but": true, "by": true, "for": true, "from": true, "has": true, "have": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "its": true, "of": true, "on": true, "or": true, "that": true,
+	"the": true, "their": true, "then": true, "there": true, "these": true, "this": true, "to": true,
+	"was": true, "were": true, "will": true, "with": true,
+}
+
+// Tokenize splits text into the terms used for keyword search: lower-case runs of letters and
+// digits, and overlapping bigrams of Han characters, which are not separated by spaces. Punctuation
+// separates terms and stopwords are dropped, so "x509: certificate signed by unknown authority"
+// yields "x509", "certificate", "signed", "unknown" and "authority".
+// Tokenize 将文本拆分为关键词搜索使用的词项: 小写的字母数字串，以及不以空格分隔的汉字的重叠二元组。
+// 标点分隔词项且停用词被丢弃，因此 "x509: certificate signed by unknown authority" 会得到 "x509"、
+// "certificate"、"signed"、"unknown" 和 "authority"。
+func Tokenize(text string) []string {
+	var terms []string
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			if term := string(word); !stopwords[term] {
+				terms = append(terms, term)
+			}
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch len(han) {
+		case 0:
+		case 1:
+			terms = append(terms, string(han))
+		default:
+			for i := 0; i+1 < len(han); i++ {
+				terms = append(terms, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return terms
+}
+
+// QueryTerms returns the distinct terms of a query, in order of appearance.
+// QueryTerms 按出现顺序返回查询中不重复的词项。
+func QueryTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, term := range Tokenize(query) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// BM25Stats are the corpus statistics BM25 scores are computed from.
+// BM25Stats 是计算 BM25 分数所依据的语料统计信息。
+type BM25Stats struct {
+	Documents         int            // Number of documents in the corpus / 语料中的文档数
+	AverageLength     float64        // Average number of terms of a document / 文档的平均词项数
+	DocumentFrequency map[string]int // Number of documents containing each term / 包含各词项的文档数
+}
+
+// BM25 scores a document for the query terms.
+// BM25 计算文档对查询词项的分数。
+func BM25(queryTerms []string, docTerms []string, stats BM25Stats) float64 {
+	frequency := make(map[string]int, len(docTerms))
+	for _, term := range docTerms {
+		frequency[term]++
+	}
+	var score float64
+	for _, term := range queryTerms {
+		score += bm25Term(frequency[term], len(docTerms), stats.DocumentFrequency[term], stats.Documents, stats.AverageLength)
+	}
+	return score
+}
+
+// bm25Term is the BM25 contribution of one query term.
+// bm25Term 是单个查询词项的 BM25 贡献。
+func bm25Term(tf, length, df, documents int, averageLength float64) float64 {
+	if tf == 0 || df == 0 {
+		return 0
+	}
+	if averageLength <= 0 {
+		averageLength = 1
+	}
+	idf := math.Log(1 + (float64(documents)-float64(df)+0.5)/(float64(df)+0.5))
+	norm := float64(tf) + bm25K1*(1-bm25B+bm25B*float64(length)/averageLength)
+	return idf * float64(tf) * (bm25K1 + 1) / norm
+}
+
+// KeywordMatch is a document found by a keyword search.
+// KeywordMatch 是关键词搜索找到的文档。
+type KeywordMatch struct {
+	ID    string
+	Score float64 // BM25 score / BM25 分数
+}
+
+// KeywordIndex is an in-memory inverted index for BM25 keyword search. It is not safe for
+// concurrent use; callers guard it with the lock of the documents it indexes.
+// KeywordIndex 是用于 BM25 关键词搜索的内存倒排索引。它不是并发安全的; 调用方使用其所索引文档的锁来保护它。
+type KeywordIndex struct {
+	docs        map[string]map[string]int // Term frequencies by document / 各文档的词频
+	lengths     map[string]int
+	postings    map[string]map[string]bool // Documents by term / 各词项对应的文档
+	totalLength int
+}
+
+// NewKeywordIndex creates an empty KeywordIndex.
+// NewKeywordIndex 创建一个空的 KeywordIndex。
+func NewKeywordIndex() *KeywordIndex {
+	return &KeywordIndex{
+		docs:     map[string]map[string]int{},
+		lengths:  map[string]int{},
+		postings: map[string]map[string]bool{},
+	}
+}
+
+// Add indexes a document, replacing a document with the same ID.
+// Add 索引一个文档，替换 ID 相同的文档。
+func (x *KeywordIndex) Add(id, text string) {
+	x.Remove(id)
+	terms := Tokenize(text)
+	frequency := make(map[string]int, len(terms))
+	for _, term := range terms {
+		frequency[term]++
+	}
+	for term := range frequency {
+		if x.postings[term] == nil {
+			x.postings[term] = map[string]bool{}
+		}
+		x.postings[term][id] = true
+	}
+	x.docs[id] = frequency
+	x.lengths[id] = len(terms)
+	x.totalLength += len(terms)
+}
+
+// Remove removes a document from the index; unknown IDs are ignored.
+// Remove 从索引中删除文档; 未知的 ID 会被忽略。
+func (x *KeywordIndex) Remove(id string) {
+	frequency, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for term := range frequency {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.totalLength -= x.lengths[id]
+	delete(x.docs, id)
+	delete(x.lengths, id)
+}
+
+// Len returns the number of indexed documents.
+// Len 返回已索引的文档数。
+func (x *KeywordIndex) Len() int {
+	return len(x.docs)
+}
+
+// Search returns the k documents with the highest BM25 score for the query among those accepted by
+// accept (nil accepts all). Documents sharing no term with the query are not returned.
+// Search 在 accept 接受的文档 (nil 表示全部接受) 中返回对查询 BM25 分数最高的 k 个文档。
+// 与查询没有共同词项的文档不会返回。
+func (x *KeywordIndex) Search(query string, k int, accept func(id string) bool) []KeywordMatch {
+	if k <= 0 || len(x.docs) == 0 {
+		return nil
+	}
+	averageLength := float64(x.totalLength) / float64(len(x.docs))
+	scores := map[string]float64{}
+	for _, term := range QueryTerms(query) {
+		posting := x.postings[term]
+		for id := range posting {
+			if accept != nil && !accept(id) {
+				continue
+			}
+			scores[id] += bm25Term(x.docs[id][term], x.lengths[id], len(posting), len(x.docs), averageLength)
+		}
+	}
+
+	matches := make([]KeywordMatch, 0, len(scores))
+	for id, score := range scores {
+		matches = append(matches, KeywordMatch{ID: id, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
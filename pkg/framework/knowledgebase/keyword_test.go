@@ -0,0 +1,91 @@
+package knowledgebase
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"punctuation separates terms", "x509: certificate signed by unknown authority", []string{"x509", "certificate", "signed", "unknown", "authority"}},
+		{"terms are lower-cased", "CrashLoopBackOff OOMKilled", []string{"crashloopbackoff", "oomkilled"}},
+		{"stopwords are dropped", "the pod is in a crash loop", []string{"pod", "crash", "loop"}},
+		{"negations are kept", "no space left on device", []string{"no", "space", "left", "device"}},
+		{"han characters form bigrams", "磁盘已满 disk", []string{"磁盘", "盘已", "已满", "disk"}},
+		{"a single han character is a term", "满", []string{"满"}},
+		{"only stopwords", "is it the", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Tokenize(tc.text); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+
+	if got := QueryTerms("OOMKilled pod oomkilled again pod"); !reflect.DeepEqual(got, []string{"oomkilled", "pod", "again"}) {
+		t.Errorf("QueryTerms must return the distinct terms in order, got %q", got)
+	}
+}
+
+func TestKeywordIndexSearch(t *testing.T) {
+	index := NewKeywordIndex()
+	index.Add("oom", "Pod OOMKilled: raise the memory limit of the container")
+	index.Add("image", "ImagePullBackOff: the image tag does not exist in the registry")
+	index.Add("dns", "CoreDNS is failing: the pod cannot resolve the service name")
+	index.Add("tls", "x509: certificate signed by unknown authority when calling the registry")
+
+	ids := func(matches []KeywordMatch) []string {
+		var out []string
+		for _, m := range matches {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name   string
+		query  string
+		k      int
+		accept func(string) bool
+		want   []string
+	}{
+		{"exact error string", "OOMKilled", 5, nil, []string{"oom"}},
+		{"documents sharing more terms rank first", "registry certificate unknown authority", 5, nil, []string{"tls", "image"}},
+		{"stopwords alone match nothing", "the is of", 5, nil, nil},
+		{"k limits the matches", "registry", 1, nil, []string{"image"}},
+		{"accept filters documents", "registry", 5, func(id string) bool { return id != "image" }, []string{"tls"}},
+		{"no shared term", "disk pressure", 5, nil, nil},
+		{"k of zero", "registry", 0, nil, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ids(index.Search(tc.query, tc.k, tc.accept)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Search(%q) = %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+
+	index.Add("oom", "Disk pressure evicts pods")
+	if got := ids(index.Search("OOMKilled", 5, nil)); got != nil {
+		t.Errorf("replacing a document must drop its old terms, got %v", got)
+	}
+	index.Remove("oom")
+	index.Remove("unknown")
+	if index.Len() != 3 || len(index.Search("disk", 5, nil)) != 0 {
+		t.Errorf("Remove left %d documents", index.Len())
+	}
+}
+
+func TestBM25(t *testing.T) {
+	stats := BM25Stats{Documents: 10, AverageLength: 5, DocumentFrequency: map[string]int{"oomkilled": 1, "pod": 9}}
+	rare := BM25([]string{"oomkilled"}, Tokenize("pod oomkilled"), stats)
+	common := BM25([]string{"pod"}, Tokenize("pod oomkilled"), stats)
+	if rare <= common || common <= 0 {
+		t.Errorf("a rare term must weigh more than a common one: rare %f, common %f", rare, common)
+	}
+	if got := BM25([]string{"disk"}, Tokenize("pod oomkilled"), stats); got != 0 {
+		t.Errorf("a missing term must not score, got %f", got)
+	}
+}
@@ -3,7 +3,7 @@ package knowledgebase
 import (
 	"context"
 	"fmt"
-	"github.com/uber-go/zap"
+	"go.uber.org/zap"
 	"sync"
 
 	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
@@ -29,13 +29,10 @@ type KnowledgeBase interface {
 
 	// Store adds knowledge data to the knowledge base.
 	// Store 向知识库添加知识数据。
-	// The data can be structured (e.g., document snippets, Q&A) or unstructured.
-	// 数据可以是结构化的 (例如, 文档片段, 问答) 或非结构化的。
-	Store(ctx context.Context, data []types.KnowledgeBaseHit) error // Reusing KnowledgeBaseHit for data structure? Or define new type?
-	// Note: Rethink the data structure for storing - maybe a dedicated struct for source data.
-	// 注意: 需要重新思考存储的数据结构 - 可能需要一个专门的结构体来表示源数据。
-	// For now, using KnowledgeBaseHit for simplicity, but will refine later.
-	// 目前为简化起见使用 KnowledgeBaseHit，后续会进行改进。
+	// Documents replace stored documents with the same ID; documents without an ID get one derived
+	// from their source and content, so storing the same content twice does not duplicate it.
+	// 文档会替换 ID 相同的已存储文档; 没有 ID 的文档会获得由来源和内容派生的 ID，因此重复存储相同内容不会产生重复条目。
+	Store(ctx context.Context, docs []types.KnowledgeDocument) error
 
 	// Retrieve searches the knowledge base for information relevant to the query.
 	// Retrieve 在知识库中搜索与查询相关的信息。
@@ -43,6 +40,10 @@ type KnowledgeBase interface {
 	// 它返回一个相关的知识片段 (命中) 切片或一个错误。
 	Retrieve(ctx context.Context, query string, options map[string]interface{}) ([]types.KnowledgeBaseHit, error)
 
+	// Delete removes the entries with the given IDs from the knowledge base. Unknown IDs are ignored.
+	// Delete 从知识库中删除给定 ID 的条目。未知的 ID 会被忽略。
+	Delete(ctx context.Context, ids []string) error
+
 	// // Optional: Add a method to configure the knowledge base
 	// // 可选: 添加一个方法来配置知识库
 	// Configure(config types.KnowledgeBaseConfig) error
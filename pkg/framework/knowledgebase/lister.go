@@ -0,0 +1,165 @@
+package knowledgebase
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// DocumentLister is implemented by knowledge bases whose documents can be enumerated, so that they
+// can be inspected, exported and summarized.
+// DocumentLister 由可以枚举其文档的知识库实现，使文档可以被查看、导出和汇总。
+type DocumentLister interface {
+	KnowledgeBase
+
+	// List returns every stored document, sorted by ID.
+	// List 返回所有已存储的文档，按 ID 排序。
+	List(ctx context.Context) ([]types.KnowledgeDocument, error)
+}
+
+// Lister returns the DocumentLister behind a knowledge base, unwrapping wrappers such as
+// RerankingKnowledgeBase, or an error if the provider cannot list its documents.
+// Lister 返回知识库背后的 DocumentLister (会解开 RerankingKnowledgeBase 等包装); 如果提供商无法列出其文档则返回错误。
+func Lister(kb KnowledgeBase) (DocumentLister, error) {
+	for kb != nil {
+		if lister, ok := kb.(DocumentLister); ok {
+			return lister, nil
+		}
+		wrapper, ok := kb.(interface{ Unwrap() KnowledgeBase })
+		if !ok {
+			break
+		}
+		kb = wrapper.Unwrap()
+	}
+	name := ""
+	if kb != nil {
+		name = kb.Name()
+	}
+	return nil, errors.New(errors.ErrorCodeKnowledgeBaseError, "knowledge base provider cannot list its documents", name)
+}
+
+// Origins of knowledge base documents, derived from their source.
+// 知识库文档的来源类别，由其来源派生。
+const (
+	// OriginIngested documents were ingested from runbook files.
+	// OriginIngested 文档是从 Runbook 文件导入的。
+	OriginIngested = "ingested"
+	// OriginFeedback documents were learned from operator feedback on diagnoses.
+	// OriginFeedback 文档是从运维人员对诊断的反馈中学习的。
+	OriginFeedback = "feedback"
+	// OriginBusinessRunbook documents were synchronized from business runbooks.
+	// OriginBusinessRunbook 文档是从业务 Runbook 同步的。
+	OriginBusinessRunbook = "business-runbook"
+)
+
+// DocumentOrigin returns the origin of a document from its source.
+// DocumentOrigin 根据文档来源返回其来源类别。
+func DocumentOrigin(source string) string {
+	switch {
+	case strings.HasPrefix(source, constants.KBFeedbackSourcePrefix):
+		return OriginFeedback
+	case strings.HasPrefix(source, constants.KBBusinessRunbookSourcePrefix):
+		return OriginBusinessRunbook
+	default:
+		return OriginIngested
+	}
+}
+
+// Stats summarizes the documents of a knowledge base. Documents without a scope are counted under
+// the empty key of the scope maps.
+// Stats 汇总知识库的文档。没有范围的文档计入范围 map 的空键之下。
+type Stats struct {
+	Provider          string         `json:"provider"`
+	Documents         int            `json:"documents"`
+	Sources           int            `json:"sources"`
+	ContentChars      int            `json:"contentChars"`
+	ByOrigin          map[string]int `json:"byOrigin"`
+	ByVCluster        map[string]int `json:"byVCluster"`
+	ByBusinessService map[string]int `json:"byBusinessService"`
+	ByResourceKind    map[string]int `json:"byResourceKind"`
+	ByTag             map[string]int `json:"byTag"`
+}
+
+// ComputeStats summarizes the documents of a knowledge base provider.
+// ComputeStats 汇总某个知识库提供商的文档。
+func ComputeStats(provider string, docs []types.KnowledgeDocument) *Stats {
+	stats := &Stats{
+		Provider:          provider,
+		Documents:         len(docs),
+		ByOrigin:          map[string]int{},
+		ByVCluster:        map[string]int{},
+		ByBusinessService: map[string]int{},
+		ByResourceKind:    map[string]int{},
+		ByTag:             map[string]int{},
+	}
+	sources := map[string]bool{}
+	for _, doc := range docs {
+		sources[doc.Source] = true
+		stats.ContentChars += len([]rune(doc.Content))
+		stats.ByOrigin[DocumentOrigin(doc.Source)]++
+		stats.ByVCluster[doc.Metadata[MetadataVCluster]]++
+		stats.ByBusinessService[doc.Metadata[MetadataBusinessService]]++
+		stats.ByResourceKind[doc.Metadata[MetadataResourceKind]]++
+		for _, tag := range strings.Split(doc.Metadata[MetadataTags], ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				stats.ByTag[tag]++
+			}
+		}
+	}
+	stats.Sources = len(sources)
+	return stats
+}
+
+// SourceSummary describes the documents stored for one source.
+// SourceSummary 描述为某个来源存储的文档。
+type SourceSummary struct {
+	Source    string `json:"source"`
+	Origin    string `json:"origin"`
+	Documents int    `json:"documents"`
+	// Scope is the vcluster, business service and resource kind shared by the source's documents.
+	// Scope 是该来源的文档共同的 vcluster、业务服务和资源类型。
+	Scope map[string]string `json:"scope,omitempty"`
+}
+
+// SummarizeSources groups documents by source, sorted by source.
+// SummarizeSources 按来源对文档分组，并按来源排序。
+func SummarizeSources(docs []types.KnowledgeDocument) []SourceSummary {
+	index := map[string]int{}
+	var summaries []SourceSummary
+	for _, doc := range docs {
+		i, ok := index[doc.Source]
+		if !ok {
+			i = len(summaries)
+			index[doc.Source] = i
+			scope := map[string]string{}
+			for _, key := range ScopeKeys {
+				if value := doc.Metadata[key]; value != "" {
+					scope[key] = value
+				}
+			}
+			summaries = append(summaries, SourceSummary{Source: doc.Source, Origin: DocumentOrigin(doc.Source), Scope: scope})
+		}
+		summary := &summaries[i]
+		summary.Documents++
+		for key, value := range summary.Scope {
+			if doc.Metadata[key] != value {
+				delete(summary.Scope, key)
+			}
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Source < summaries[j].Source })
+	return summaries
+}
+
+// MatchesSource reports whether a document source is the given source or lies below it, e.g.
+// "business-runbook/orders/restart" lies below "business-runbook/orders".
+// MatchesSource 报告文档来源是否为给定来源或位于其之下，例如 "business-runbook/orders/restart" 位于
+// "business-runbook/orders" 之下。
+func MatchesSource(source, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix != "" && (source == prefix || strings.HasPrefix(source, prefix+"/"))
+}
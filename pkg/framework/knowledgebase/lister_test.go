@@ -0,0 +1,101 @@
+package knowledgebase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+)
+
+func TestLister(t *testing.T) {
+	redactor, err := redact.New(&types.RedactionConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &memoryKnowledgeBase{}
+	for _, tc := range []struct {
+		name string
+		kb   KnowledgeBase
+	}{
+		{name: "provider", kb: inner},
+		{name: "redacting", kb: NewRedactingKnowledgeBase(inner, redactor)},
+		{name: "reranking", kb: NewRerankingKnowledgeBase(inner, nil, 0)},
+		{name: "reranking over redacting", kb: NewRerankingKnowledgeBase(NewRedactingKnowledgeBase(inner, redactor), nil, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if lister, err := Lister(tc.kb); err != nil || lister != DocumentLister(inner) {
+				t.Errorf("got %v, %v, want the provider", lister, err)
+			}
+		})
+	}
+
+	for _, kb := range []KnowledgeBase{storeOnlyKnowledgeBase{}, NewRerankingKnowledgeBase(storeOnlyKnowledgeBase{}, nil, 0), nil} {
+		if _, err := Lister(kb); !errors.IsErrorCode(err, errors.ErrorCodeKnowledgeBaseError) {
+			t.Errorf("expected an error for %T, got %v", kb, err)
+		}
+	}
+}
+
+func statsDocuments() []types.KnowledgeDocument {
+	return []types.KnowledgeDocument{
+		{ID: "1", Source: "runbooks/db.md", Content: "Restart the primary.", Metadata: map[string]string{MetadataVCluster: "team-a", MetadataResourceKind: "StatefulSet", MetadataTags: "db, postgres"}},
+		{ID: "2", Source: "runbooks/db.md", Content: "Check replication.", Metadata: map[string]string{MetadataVCluster: "team-a", MetadataResourceKind: "Pod", MetadataTags: "db"}},
+		{ID: "3", Source: "feedback/analysis-7", Content: "节点磁盘已满", Metadata: map[string]string{MetadataVCluster: "team-b"}},
+		{ID: "4", Source: "business-runbook/orders/restart", Content: "Drain the queue.", Metadata: map[string]string{MetadataBusinessService: "orders", MetadataTags: ","}},
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	got := ComputeStats("local", statsDocuments())
+	want := &Stats{
+		Provider:          "local",
+		Documents:         4,
+		Sources:           3,
+		ContentChars:      20 + 18 + 6 + 16,
+		ByOrigin:          map[string]int{OriginIngested: 2, OriginFeedback: 1, OriginBusinessRunbook: 1},
+		ByVCluster:        map[string]int{"team-a": 2, "team-b": 1, "": 1},
+		ByBusinessService: map[string]int{"": 3, "orders": 1},
+		ByResourceKind:    map[string]int{"StatefulSet": 1, "Pod": 1, "": 2},
+		ByTag:             map[string]int{"db": 2, "postgres": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	if empty := ComputeStats("local", nil); empty.Documents != 0 || empty.Sources != 0 || empty.ByOrigin == nil {
+		t.Errorf("unexpected stats of an empty knowledge base %+v", empty)
+	}
+}
+
+func TestSummarizeSources(t *testing.T) {
+	got := SummarizeSources(statsDocuments())
+	want := []SourceSummary{
+		{Source: "business-runbook/orders/restart", Origin: OriginBusinessRunbook, Documents: 1, Scope: map[string]string{MetadataBusinessService: "orders"}},
+		{Source: "feedback/analysis-7", Origin: OriginFeedback, Documents: 1, Scope: map[string]string{MetadataVCluster: "team-b"}},
+		{Source: "runbooks/db.md", Origin: OriginIngested, Documents: 2, Scope: map[string]string{MetadataVCluster: "team-a"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestMatchesSource(t *testing.T) {
+	for _, tc := range []struct {
+		source, prefix string
+		want           bool
+	}{
+		{"business-runbook/orders/restart", "business-runbook/orders", true},
+		{"business-runbook/orders/restart", "business-runbook/orders/", true},
+		{"business-runbook/orders", "business-runbook/orders", true},
+		{"business-runbook/orders-v2/restart", "business-runbook/orders", false},
+		{"runbooks/db.md", "runbooks/db", false},
+		{"runbooks/db.md", "", false},
+		{"runbooks/db.md", "/", false},
+	} {
+		if got := MatchesSource(tc.source, tc.prefix); got != tc.want {
+			t.Errorf("MatchesSource(%q, %q) = %v, want %v", tc.source, tc.prefix, got, tc.want)
+		}
+	}
+}
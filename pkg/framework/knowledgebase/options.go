@@ -0,0 +1,180 @@
+package knowledgebase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Options understood by Retrieve.
+// Retrieve 支持的选项。
+const (
+	// OptionK is the number of hits to return.
+	// OptionK 是要返回的命中数。
+	OptionK = "k"
+	// OptionMinScore is the lowest relevance score returned.
+	// OptionMinScore 是返回的最低相关性分数。
+	OptionMinScore = "minScore"
+	// OptionFilter restricts the hits to entries whose metadata has all the given key/value pairs.
+	// OptionFilter 将命中限制为元数据包含所有给定键值对的条目。
+	OptionFilter = "filter"
+	// OptionVCluster, OptionBusinessService and OptionResourceKind restrict the hits to entries of
+	// the scope and to entries without that scope, which apply everywhere.
+	// OptionVCluster、OptionBusinessService 和 OptionResourceKind 将命中限制为该范围内的条目以及
+	// 没有该范围、适用于所有地方的条目。
+	OptionVCluster        = MetadataVCluster
+	OptionBusinessService = MetadataBusinessService
+	OptionResourceKind    = MetadataResourceKind
+	// OptionMode overrides the configured retrieval mode: hybrid, vector or keyword.
+	// OptionMode 覆盖配置的检索模式: hybrid、vector 或 keyword。
+	OptionMode = "mode"
+	// OptionRerank enables or disables LLM reranking for one query.
+	// OptionRerank 为单次查询启用或禁用 LLM 重排序。
+	OptionRerank = "rerank"
+)
+
+// Metadata keys attached to ingested and learned documents; they can be used in filters.
+// 附加到导入文档和学习所得文档的元数据键; 可以在过滤条件中使用。
+const (
+	// MetadataPath is the path of the source file, relative to its source directory.
+	// MetadataPath 是源文件相对于其来源目录的路径。
+	MetadataPath = "path"
+	// MetadataSection is the heading path of the chunk, e.g. "Troubleshooting > OOMKilled".
+	// MetadataSection 是分块的标题路径，例如 "Troubleshooting > OOMKilled"。
+	MetadataSection = "section"
+	// MetadataTags is the comma-separated list of tags of the source.
+	// MetadataTags 是来源标签的逗号分隔列表。
+	MetadataTags = "tags"
+	// MetadataVCluster is the vcluster the document applies to.
+	// MetadataVCluster 是文档适用的 vcluster。
+	MetadataVCluster = "vcluster"
+	// MetadataBusinessService is the business service the document applies to.
+	// MetadataBusinessService 是文档适用的业务服务。
+	MetadataBusinessService = "businessService"
+	// MetadataResourceKind is the kind of Kubernetes resource the document applies to, e.g. Pod.
+	// MetadataResourceKind 是文档适用的 Kubernetes 资源类型，例如 Pod。
+	MetadataResourceKind = "resourceKind"
+	// MetadataContentHash is the SHA-256 of the source file the chunk was cut from.
+	// MetadataContentHash 是分块所属源文件的 SHA-256。
+	MetadataContentHash = "contentHash"
+	// MetadataCommit is the git commit of the checkout the file was read from.
+	// MetadataCommit 是读取该文件的 git 检出目录所在的提交。
+	MetadataCommit = "commit"
+	// MetadataFormat is the format of the source file: markdown, html or text.
+	// MetadataFormat 是源文件的格式: markdown、html 或 text。
+	MetadataFormat = "format"
+	// MetadataAnalysisResultID is the analysis result a document learned from feedback describes.
+	// MetadataAnalysisResultID 是从反馈中学习的文档所描述的分析结果。
+	MetadataAnalysisResultID = "analysisResultId"
+	// MetadataVerdict is the operator's verdict a document was learned from: confirmed or corrected.
+	// MetadataVerdict 是文档所依据的运维人员结论: confirmed 或 corrected。
+	MetadataVerdict = "verdict"
+	// MetadataOperator is the operator who gave the feedback.
+	// MetadataOperator 是给出反馈的运维人员。
+	MetadataOperator = "operator"
+	// MetadataConfirmedAt is the RFC 3339 time the feedback was given.
+	// MetadataConfirmedAt 是给出反馈的 RFC 3339 时间。
+	MetadataConfirmedAt = "confirmedAt"
+	// MetadataRunbookID is the ID of the business runbook a document was synchronized from.
+	// MetadataRunbookID 是文档所同步自的业务 Runbook 的 ID。
+	MetadataRunbookID = "runbookId"
+	// MetadataAutomated is "true" if the business runbook can be executed by the agent.
+	// MetadataAutomated 在业务 Runbook 可由代理执行时为 "true"。
+	MetadataAutomated = "automated"
+)
+
+// IntOption returns an integer option, accepting the numeric types produced by Go callers and by JSON.
+// IntOption 返回整数选项，接受 Go 调用方和 JSON 产生的数值类型。
+func IntOption(options map[string]interface{}, key string, def int) int {
+	switch v := options[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// FloatOption returns a floating-point option.
+// FloatOption 返回浮点数选项。
+func FloatOption(options map[string]interface{}, key string, def float64) float64 {
+	switch v := options[key].(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	}
+	return def
+}
+
+// ScopeKeys are the metadata keys that scope a document. A filter on a scope key also matches
+// documents without that key, since they apply to every scope.
+// ScopeKeys 是限定文档范围的元数据键。对范围键的过滤也会匹配没有该键的文档，因为它们适用于所有范围。
+var ScopeKeys = []string{MetadataVCluster, MetadataBusinessService, MetadataResourceKind}
+
+// IsScopeKey reports whether a metadata key is one of the ScopeKeys.
+// IsScopeKey 报告元数据键是否属于 ScopeKeys。
+func IsScopeKey(key string) bool {
+	for _, scope := range ScopeKeys {
+		if key == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterOption returns the metadata filter of the options, given as a map[string]string or as a
+// map[string]interface{} decoded from JSON, together with the scope options. Empty values are ignored.
+// FilterOption 返回选项中的元数据过滤条件 (可以是 map[string]string 或从 JSON 解码的
+// map[string]interface{})，并合并范围选项。空值会被忽略。
+func FilterOption(options map[string]interface{}) map[string]string {
+	filter := map[string]string{}
+	switch v := options[OptionFilter].(type) {
+	case map[string]string:
+		for key, value := range v {
+			if value != "" {
+				filter[key] = value
+			}
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			if value != nil && fmt.Sprint(value) != "" {
+				filter[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	for _, key := range ScopeKeys {
+		if value, ok := options[key].(string); ok && value != "" {
+			filter[key] = value
+		}
+	}
+	return filter
+}
+
+// MatchesFilter reports whether the metadata has all the key/value pairs of the filter. Scope keys
+// missing from the metadata match any value.
+// MatchesFilter 报告元数据是否包含过滤条件中的所有键值对。元数据中缺失的范围键匹配任意值。
+func MatchesFilter(metadata, filter map[string]string) bool {
+	for key, value := range filter {
+		if actual, ok := metadata[key]; !ok || actual == "" {
+			if IsScopeKey(key) {
+				continue
+			}
+			return false
+		} else if actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// DocumentID derives a stable ID from an entry's source and content, for entries stored without an ID.
+// DocumentID 根据条目的来源和内容派生稳定的 ID，用于未带 ID 存储的条目。
+func DocumentID(source, content string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + content))
+	return hex.EncodeToString(sum[:16])
+}
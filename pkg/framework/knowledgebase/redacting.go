@@ -0,0 +1,61 @@
+package knowledgebase
+
+import (
+	"context"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+)
+
+// RedactingKnowledgeBase wraps a knowledge base and redacts every document before it is stored, so
+// that ingested runbooks, synced business runbooks, imports and feedback never reach the embedding
+// provider or the store with secrets in them.
+// RedactingKnowledgeBase 包装一个知识库，并在存储每个文档之前对其脱敏，使导入的 Runbook、同步的业务 Runbook、
+// 导入的文档和反馈在到达 embedding 提供商或存储时都不含机密。
+type RedactingKnowledgeBase struct {
+	KnowledgeBase
+	redactor *redact.Redactor
+}
+
+// NewRedactingKnowledgeBase wraps a knowledge base. A nil redactor (redaction disabled) returns the
+// knowledge base unchanged.
+// NewRedactingKnowledgeBase 包装一个知识库。redactor 为 nil (脱敏被禁用) 时原样返回该知识库。
+func NewRedactingKnowledgeBase(kb KnowledgeBase, redactor *redact.Redactor) KnowledgeBase {
+	if redactor == nil {
+		return kb
+	}
+	return &RedactingKnowledgeBase{KnowledgeBase: kb, redactor: redactor}
+}
+
+// Unwrap returns the wrapped knowledge base.
+// Unwrap 返回被包装的知识库。
+func (kb *RedactingKnowledgeBase) Unwrap() KnowledgeBase {
+	return kb.KnowledgeBase
+}
+
+// Store redacts the documents, each in its own session, and stores them in the wrapped knowledge base.
+// Store 对文档脱敏 (每个文档使用独立的会话)，并将其存入被包装的知识库。
+func (kb *RedactingKnowledgeBase) Store(ctx context.Context, docs []types.KnowledgeDocument) error {
+	redacted := make([]types.KnowledgeDocument, len(docs))
+	for i, doc := range docs {
+		redacted[i] = kb.redactor.NewSession().RedactDocument(doc)
+	}
+	return kb.KnowledgeBase.Store(ctx, redacted)
+}
+
+// Redacts reports whether documents stored in the knowledge base are redacted, looking through
+// wrappers such as RerankingKnowledgeBase.
+// Redacts 报告存入该知识库的文档是否会被脱敏 (会穿过 RerankingKnowledgeBase 等包装查找)。
+func Redacts(kb KnowledgeBase) bool {
+	for kb != nil {
+		if _, ok := kb.(*RedactingKnowledgeBase); ok {
+			return true
+		}
+		wrapper, ok := kb.(interface{ Unwrap() KnowledgeBase })
+		if !ok {
+			return false
+		}
+		kb = wrapper.Unwrap()
+	}
+	return false
+}
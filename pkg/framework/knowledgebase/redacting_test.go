@@ -0,0 +1,77 @@
+package knowledgebase
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/redact"
+)
+
+// memoryKnowledgeBase keeps the stored documents and lists them.
+type memoryKnowledgeBase struct {
+	docs []types.KnowledgeDocument
+	hits []types.KnowledgeBaseHit
+}
+
+func (kb *memoryKnowledgeBase) Name() string        { return "memory" }
+func (kb *memoryKnowledgeBase) Description() string { return "Keeps documents in memory." }
+func (kb *memoryKnowledgeBase) Store(ctx context.Context, docs []types.KnowledgeDocument) error {
+	kb.docs = append(kb.docs, docs...)
+	return nil
+}
+func (kb *memoryKnowledgeBase) Retrieve(ctx context.Context, query string, options map[string]interface{}) ([]types.KnowledgeBaseHit, error) {
+	k := IntOption(options, OptionK, len(kb.hits))
+	if k > len(kb.hits) {
+		k = len(kb.hits)
+	}
+	return append([]types.KnowledgeBaseHit(nil), kb.hits[:k]...), nil
+}
+func (kb *memoryKnowledgeBase) Delete(ctx context.Context, ids []string) error { return nil }
+func (kb *memoryKnowledgeBase) List(ctx context.Context) ([]types.KnowledgeDocument, error) {
+	return kb.docs, nil
+}
+
+func TestRedactingKnowledgeBaseStore(t *testing.T) {
+	redactor, err := redact.New(&types.RedactionConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &memoryKnowledgeBase{}
+	kb := NewRedactingKnowledgeBase(inner, redactor)
+	docs := []types.KnowledgeDocument{
+		{ID: "a", Source: "runbooks/db.md", Content: "export DB_PASSWORD=hunter2", Metadata: map[string]string{"section": "Reset"}},
+		{ID: "b", Source: "runbooks/web.md", Content: "curl http://10.0.0.9/health"},
+	}
+	if err := kb.Store(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inner.docs) != 2 || inner.docs[0].ID != "a" || inner.docs[1].Source != "runbooks/web.md" {
+		t.Fatalf("unexpected stored documents %+v", inner.docs)
+	}
+	for i, doc := range inner.docs {
+		if strings.Contains(doc.Content, "hunter2") || strings.Contains(doc.Content, "10.0.0.9") {
+			t.Errorf("document %d stored unredacted: %q", i, doc.Content)
+		}
+	}
+	// Every document gets its own session, so placeholders do not leak across documents.
+	if inner.docs[1].Content != "curl http://[REDACTED:IP:1]/health" {
+		t.Errorf("unexpected redaction %q", inner.docs[1].Content)
+	}
+	if docs[0].Content != "export DB_PASSWORD=hunter2" {
+		t.Error("Store modified the caller's documents")
+	}
+
+	reranking := NewRerankingKnowledgeBase(kb, nil, 0)
+	if !Redacts(reranking) || Redacts(inner) {
+		t.Error("Redacts must look through wrappers and only report redacting knowledge bases")
+	}
+	if lister, err := Lister(reranking); err != nil || lister != DocumentLister(inner) {
+		t.Errorf("Lister must unwrap to the stored documents, got %v, %v", lister, err)
+	}
+	if NewRedactingKnowledgeBase(inner, nil) != KnowledgeBase(inner) {
+		t.Error("disabled redaction must not wrap the knowledge base")
+	}
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker.
+// BreakerState 是熔断器的状态。
+type BreakerState string
+
+const (
+	// BreakerClosed lets all calls through.
+	// BreakerClosed 允许所有调用通过。
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects calls until the cooldown has passed.
+	// BreakerOpen 在冷却时间结束前拒绝调用。
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single trial call through after the cooldown.
+	// BreakerHalfOpen 在冷却时间结束后允许一次试探调用通过。
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitBreaker stops calling a failing dependency for a while after consecutive failures.
+// CircuitBreaker 在连续失败后暂停调用出错的依赖一段时间。
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool // A half-open trial call is in flight / 有一次半开试探调用正在进行
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive failures and allows a
+// trial call after cooldown.
+// NewCircuitBreaker 创建一个熔断器，在连续失败 threshold 次后打开，并在 cooldown 后允许一次试探调用。
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// Allow reports whether a call may be made now.
+// Allow 报告当前是否可以发起调用。
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a successful call and closes the breaker.
+// Success 记录一次成功调用并关闭熔断器。
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed call. A failed trial call reopens the breaker immediately.
+// Failure 记录一次失败调用。试探调用失败会立即重新打开熔断器。
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+	b.trial = false
+}
+
+// Abort releases a call that ended without an outcome, e.g. because the caller gave up.
+// Abort 释放一次没有结果的调用，例如调用方已放弃。
+// A half-open breaker returns to open so that the next call becomes the trial.
+// 半开状态的熔断器会回到打开状态，使下一次调用成为试探调用。
+func (b *CircuitBreaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+	b.trial = false
+}
+
+// State returns the current state of the breaker.
+// State 返回熔断器的当前状态。
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,222 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// Role is the author of a chat message.
+// Role 是聊天消息的发送方。
+type Role string
+
+const (
+	// RoleSystem carries instructions that frame the conversation.
+	// RoleSystem 携带为对话设定框架的指令。
+	RoleSystem Role = "system"
+	// RoleUser carries the request and its evidence.
+	// RoleUser 携带请求及其证据。
+	RoleUser Role = "user"
+	// RoleAssistant carries a previous answer of the model, including its tool calls.
+	// RoleAssistant 携带模型之前的回答，包括其工具调用。
+	RoleAssistant Role = "assistant"
+	// RoleTool carries the result of a tool call back to the model.
+	// RoleTool 将工具调用的结果回传给模型。
+	RoleTool Role = "tool"
+)
+
+// FinishReason describes why the model stopped generating.
+// FinishReason 描述模型停止生成的原因。
+type FinishReason string
+
+const (
+	// FinishReasonStop means the model finished its answer or hit a stop sequence.
+	// FinishReasonStop 表示模型完成了回答或遇到停止序列。
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonLength means the answer was cut off by the token limit.
+	// FinishReasonLength 表示回答因 token 限制被截断。
+	FinishReasonLength FinishReason = "length"
+	// FinishReasonToolCalls means the model is waiting for tool results.
+	// FinishReasonToolCalls 表示模型正在等待工具结果。
+	FinishReasonToolCalls FinishReason = "tool_calls"
+	// FinishReasonContentFilter means the answer was withheld by a content filter.
+	// FinishReasonContentFilter 表示回答被内容过滤器拦截。
+	FinishReasonContentFilter FinishReason = "content_filter"
+)
+
+// ResponseFormat constrains the format of the model's answer.
+// ResponseFormat 约束模型回答的格式。
+type ResponseFormat string
+
+const (
+	// ResponseFormatText lets the model answer in free text (the default).
+	// ResponseFormatText 允许模型以自由文本回答 (默认)。
+	ResponseFormatText ResponseFormat = "text"
+	// ResponseFormatJSON asks the model to answer with a single JSON object.
+	// ResponseFormatJSON 要求模型以单个 JSON 对象回答。
+	ResponseFormatJSON ResponseFormat = "json_object"
+)
+
+// Message is a single chat message.
+// Message 是一条聊天消息。
+type Message struct {
+	Role       Role       `json:"role"`                 // Author of the message / 消息发送方
+	Content    string     `json:"content"`              // Text of the message / 消息文本
+	Name       string     `json:"name,omitempty"`       // Optional name of the author / 可选的发送方名称
+	ToolCalls  []ToolCall `json:"toolCalls,omitempty"`  // Tool calls requested by the assistant / 助手请求的工具调用
+	ToolCallID string     `json:"toolCallId,omitempty"` // Call answered by a tool message / 工具消息所响应的调用
+}
+
+// ToolCall is a function call requested by the model.
+// ToolCall 是模型请求的函数调用。
+type ToolCall struct {
+	ID        string `json:"id"`        // Identifier to echo in the tool message / 需要在工具消息中回传的标识符
+	Name      string `json:"name"`      // Name of the tool / 工具名称
+	Arguments string `json:"arguments"` // Arguments as a JSON object / JSON 对象形式的参数
+}
+
+// ToolDefinition describes a tool the model may call.
+// ToolDefinition 描述模型可调用的工具。
+type ToolDefinition struct {
+	Name        string                 `json:"name"`        // Name of the tool / 工具名称
+	Description string                 `json:"description"` // What the tool does / 工具功能
+	Parameters  map[string]interface{} `json:"parameters"`  // JSON schema of the arguments / 参数的 JSON schema
+}
+
+// ChatRequest is a request to a chat model.
+// ChatRequest 是发送给聊天模型的请求。
+type ChatRequest struct {
+	Messages       []Message        // Conversation so far / 目前为止的对话
+	Tools          []ToolDefinition // Tools the model may call / 模型可调用的工具
+	ResponseFormat ResponseFormat   // Answer format; empty means text / 回答格式; 为空表示文本
+	Stop           []string         // Stop sequences / 停止序列
+	MaxTokens      int              // Completion token limit; 0 means the provider's configured default / 补全 token 上限; 0 表示使用提供商配置的默认值
+	Temperature    *float64         // Sampling temperature; nil means the provider's default / 采样温度; nil 表示使用提供商默认值
+	// Options are passed to the provider's API unchanged and override the fields above.
+	// Options 原样传递给提供商 API，并覆盖上述字段。
+	Options map[string]interface{}
+}
+
+// Usage reports the tokens consumed by a request.
+// Usage 报告请求消耗的 token。
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`     // Tokens in the prompt / 提示的 token 数
+	CompletionTokens int `json:"completionTokens"` // Tokens in the answer / 回答的 token 数
+	TotalTokens      int `json:"totalTokens"`      // Sum of both / 两者之和
+}
+
+// Add returns the sum of two usages.
+// Add 返回两个用量之和。
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// ChatResponse is the answer of a chat model.
+// ChatResponse 是聊天模型的回答。
+type ChatResponse struct {
+	Message      Message      // The assistant's message / 助手消息
+	FinishReason FinishReason // Why generation stopped / 停止生成的原因
+	Usage        Usage        // Tokens consumed / 消耗的 token
+	Model        string       // Model that answered, as reported by the provider / 提供商报告的应答模型
+	// Provider is the provider that answered; set by composite providers such as FallbackLLM.
+	// Provider 是应答的提供商; 由 FallbackLLM 等组合提供商设置。
+	Provider string
+	// FailedAttempts lists the provider calls that failed before this answer.
+	// FailedAttempts 列出在此回答之前失败的提供商调用。
+	FailedAttempts []types.LLMAttempt
+}
+
+// ChatLLM is implemented by providers that support chat messages, tools and usage reporting.
+// ChatLLM 由支持聊天消息、工具和用量报告的提供商实现。
+type ChatLLM interface {
+	LLM
+
+	// Chat sends a conversation to the model and returns its answer.
+	// Chat 将对话发送给模型并返回其回答。
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+}
+
+// Chat sends a chat request to any provider.
+// Chat 向任意提供商发送聊天请求。
+// Providers without chat support receive the conversation flattened into a single prompt; tools,
+// response format and usage are not available for them.
+// 不支持聊天的提供商会收到展平为单个提示的对话; 对它们而言工具、响应格式和用量均不可用。
+func Chat(ctx context.Context, provider LLM, req *ChatRequest) (*ChatResponse, error) {
+	if chat, ok := provider.(ChatLLM); ok {
+		return chat.Chat(ctx, req)
+	}
+
+	options := map[string]interface{}{}
+	if req.MaxTokens > 0 {
+		options["max_tokens"] = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+	for key, value := range req.Options {
+		options[key] = value
+	}
+
+	text, err := provider.GenerateText(ctx, FlattenMessages(req.Messages), options)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponse{
+		Message:      Message{Role: RoleAssistant, Content: text},
+		FinishReason: FinishReasonStop,
+	}, nil
+}
+
+// GenerateTextViaChat implements GenerateText on top of Chat, sending the prompt as a user message.
+// GenerateTextViaChat 基于 Chat 实现 GenerateText，将提示作为用户消息发送。
+// Chat providers use it to keep the single-prompt method working.
+// 聊天提供商使用它来保持单提示方法可用。
+func GenerateTextViaChat(ctx context.Context, provider ChatLLM, prompt string, options map[string]interface{}) (string, error) {
+	resp, err := provider.Chat(ctx, &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: prompt}},
+		Options:  options,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+// FlattenMessages renders a conversation as a single prompt for completion-only models.
+// FlattenMessages 将对话渲染为单个提示，供仅支持补全的模型使用。
+// A lone user message is returned as is.
+// 单条用户消息会原样返回。
+func FlattenMessages(messages []Message) string {
+	if len(messages) == 1 && messages[0].Role == RoleUser {
+		return messages[0].Content
+	}
+
+	var sb strings.Builder
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			sb.WriteString(msg.Content)
+		case RoleTool:
+			fmt.Fprintf(&sb, "Tool result (%s):\n%s", msg.Name, msg.Content)
+		case RoleAssistant:
+			fmt.Fprintf(&sb, "Assistant:\n%s", msg.Content)
+			for _, call := range msg.ToolCalls {
+				fmt.Fprintf(&sb, "\n{\"tool\": %q, \"arguments\": %s}", call.Name, call.Arguments)
+			}
+		default:
+			fmt.Fprintf(&sb, "User:\n%s", msg.Content)
+		}
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("Assistant:\n")
+	return sb.String()
+}
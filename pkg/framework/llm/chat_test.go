@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// textLLM is a provider without chat support that records the prompt and options it receives.
+type textLLM struct {
+	reply   string
+	err     error
+	prompt  string
+	options map[string]interface{}
+}
+
+func (p *textLLM) Name() string        { return "text" }
+func (p *textLLM) Description() string { return "" }
+func (p *textLLM) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
+	p.prompt = prompt
+	p.options = options
+	return p.reply, p.err
+}
+
+// chatLLM is a chat provider that records the requests it receives.
+type chatLLM struct {
+	textLLM
+	requests []*ChatRequest
+}
+
+func (p *chatLLM) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
+	return GenerateTextViaChat(ctx, p, prompt, options)
+}
+func (p *chatLLM) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	p.requests = append(p.requests, req)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &ChatResponse{Message: Message{Role: RoleAssistant, Content: p.reply}, FinishReason: FinishReasonStop, Usage: Usage{TotalTokens: 3}}, nil
+}
+
+func TestChatWithoutChatSupport(t *testing.T) {
+	provider := &textLLM{reply: "the pod is out of memory"}
+	temperature := 0.2
+	resp, err := Chat(context.Background(), provider, &ChatRequest{
+		Messages:       []Message{{Role: RoleSystem, Content: "You are an SRE."}, {Role: RoleUser, Content: "Why?"}},
+		Tools:          []ToolDefinition{{Name: "list_events"}},
+		ResponseFormat: ResponseFormatJSON,
+		Stop:           []string{"END"},
+		MaxTokens:      50,
+		Temperature:    &temperature,
+		Options:        map[string]interface{}{"max_tokens": 80, "top_p": 0.9},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ChatResponse{Message: Message{Role: RoleAssistant, Content: "the pod is out of memory"}, FinishReason: FinishReasonStop}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got %+v, want %+v", resp, want)
+	}
+	if provider.prompt != "You are an SRE.\n\nUser:\nWhy?\n\nAssistant:\n" {
+		t.Errorf("unexpected prompt %q", provider.prompt)
+	}
+	wantOptions := map[string]interface{}{"max_tokens": 80, "temperature": 0.2, "stop": []string{"END"}, "top_p": 0.9}
+	if !reflect.DeepEqual(provider.options, wantOptions) {
+		t.Errorf("got options %v, want %v", provider.options, wantOptions)
+	}
+
+	if _, err := Chat(context.Background(), &textLLM{err: fmt.Errorf("unavailable")}, &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Why?"}}}); err == nil {
+		t.Error("expected the provider error")
+	}
+}
+
+func TestChatWithChatSupport(t *testing.T) {
+	provider := &chatLLM{textLLM: textLLM{reply: "ok"}}
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Why?"}}, Tools: []ToolDefinition{{Name: "list_events"}}}
+	resp, err := Chat(context.Background(), provider, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.requests) != 1 || provider.requests[0] != req || resp.Usage.TotalTokens != 3 {
+		t.Errorf("the request must be sent unchanged, got %v, %+v", provider.requests, resp)
+	}
+}
+
+func TestGenerateTextViaChat(t *testing.T) {
+	provider := &chatLLM{textLLM: textLLM{reply: "ok"}}
+	options := map[string]interface{}{"temperature": 0.1}
+	text, err := provider.GenerateText(context.Background(), "Why?", options)
+	if err != nil || text != "ok" {
+		t.Fatalf("got %q, %v", text, err)
+	}
+	want := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Why?"}}, Options: options}
+	if len(provider.requests) != 1 || !reflect.DeepEqual(provider.requests[0], want) {
+		t.Errorf("got %+v, want %+v", provider.requests, want)
+	}
+
+	provider.err = fmt.Errorf("unavailable")
+	if _, err := provider.GenerateText(context.Background(), "Why?", nil); err == nil {
+		t.Error("expected the provider error")
+	}
+}
+
+func TestFlattenMessages(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		messages []Message
+		want     string
+	}{
+		{
+			name:     "lone user message",
+			messages: []Message{{Role: RoleUser, Content: "Why is web-0 failing?"}},
+			want:     "Why is web-0 failing?",
+		},
+		{
+			name:     "system and user",
+			messages: []Message{{Role: RoleSystem, Content: "You are an SRE."}, {Role: RoleUser, Content: "Why?"}},
+			want:     "You are an SRE.\n\nUser:\nWhy?\n\nAssistant:\n",
+		},
+		{
+			name: "tool calls and results",
+			messages: []Message{
+				{Role: RoleUser, Content: "Why?"},
+				{Role: RoleAssistant, Content: "Checking.", ToolCalls: []ToolCall{{ID: "call_1", Name: "list_events", Arguments: `{"namespace": "web"}`}}},
+				{Role: RoleTool, Name: "list_events", ToolCallID: "call_1", Content: "OOMKilled"},
+			},
+			want: "User:\nWhy?\n\n" +
+				"Assistant:\nChecking.\n{\"tool\": \"list_events\", \"arguments\": {\"namespace\": \"web\"}}\n\n" +
+				"Tool result (list_events):\nOOMKilled\n\n" +
+				"Assistant:\n",
+		},
+		{
+			name:     "lone system message",
+			messages: []Message{{Role: RoleSystem, Content: "You are an SRE."}},
+			want:     "You are an SRE.\n\nAssistant:\n",
+		},
+		{
+			name: "no messages",
+			want: "Assistant:\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FlattenMessages(tc.messages); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
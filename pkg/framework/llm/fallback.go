@@ -0,0 +1,297 @@
+package llm
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"go.uber.org/zap"
+)
+
+// FallbackLLM is a composite provider that calls an ordered list of providers. Transient errors
+// are retried with jittered backoff, a circuit breaker per provider skips providers that keep
+// failing, and any remaining error moves on to the next provider. When the context has a deadline,
+// each provider gets an equal share of the time that is left, so a hanging provider cannot use up
+// the deadline of the providers after it.
+// FallbackLLM 是按顺序调用一组提供商的组合提供商。暂时性错误会以带抖动的退避重试，
+// 每个提供商的熔断器会跳过持续失败的提供商，其余错误则转向下一个提供商。当 context 带有截止时间时，
+// 每个提供商获得剩余时间的均等份额，因此挂起的提供商无法耗尽其后提供商的时间。
+type FallbackLLM struct {
+	providers []LLM
+	breakers  []*CircuitBreaker
+	cfg       types.LLMFallbackConfig
+}
+
+// Ensure FallbackLLM implements the ChatLLM interface.
+// 确保 FallbackLLM 实现了 ChatLLM 接口。
+var _ ChatLLM = &FallbackLLM{}
+
+// NewFallbackLLM creates a fallback chain over the providers, tried in order.
+// NewFallbackLLM 基于给定提供商创建降级链，按顺序尝试。
+func NewFallbackLLM(providers []LLM, cfg types.LLMFallbackConfig) (*FallbackLLM, error) {
+	if len(providers) == 0 {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "empty LLM fallback chain", "at least one provider is required")
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = constants.DefaultLLMMaxRetries
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = constants.DefaultLLMInitialBackoff * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = constants.DefaultLLMMaxBackoff * time.Millisecond
+	}
+	if cfg.BreakerThreshold <= 0 {
+		cfg.BreakerThreshold = constants.DefaultLLMBreakerThreshold
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = constants.DefaultLLMBreakerCooldown * time.Second
+	}
+
+	breakers := make([]*CircuitBreaker, len(providers))
+	for i := range providers {
+		breakers[i] = NewCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown)
+	}
+	return &FallbackLLM{providers: providers, breakers: breakers, cfg: cfg}, nil
+}
+
+// Name returns the name of the composite provider.
+// Name 返回组合提供商的名称。
+func (f *FallbackLLM) Name() string {
+	return constants.LLMProviderFallback
+}
+
+// Description returns a brief description including the chain.
+// Description 返回包含降级链的简要描述。
+func (f *FallbackLLM) Description() string {
+	names := make([]string, 0, len(f.providers))
+	for _, p := range f.providers {
+		names = append(names, p.Name())
+	}
+	return fmt.Sprintf("Falls back across LLM providers in order: %s.", strings.Join(names, " -> "))
+}
+
+// Providers returns the providers of the chain in order.
+// Providers 按顺序返回降级链中的提供商。
+func (f *FallbackLLM) Providers() []LLM {
+	return append([]LLM(nil), f.providers...)
+}
+
+// ChainMembers returns the providers a call to the provider may be answered by: the providers of a
+// fallback chain, or the provider itself.
+// ChainMembers 返回调用该提供商时可能应答的提供商: 降级链中的提供商，或该提供商本身。
+func ChainMembers(provider LLM) []LLM {
+	if chain, ok := provider.(*FallbackLLM); ok {
+		return chain.Providers()
+	}
+	return []LLM{provider}
+}
+
+// GenerateText sends the prompt as a single user message through the chain.
+// GenerateText 将提示作为单条用户消息通过降级链发送。
+func (f *FallbackLLM) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
+	return GenerateTextViaChat(ctx, f, prompt, options)
+}
+
+// Chat sends the request to the first available provider that answers.
+// Chat 将请求发送给第一个可用且能应答的提供商。
+// The response names the provider that answered and lists the failed attempts before it.
+// 响应会注明应答的提供商，并列出此前失败的尝试。
+func (f *FallbackLLM) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	logger := log.LWithContext(ctx).With(zap.String("llmProvider", f.Name()))
+
+	var attempts []types.LLMAttempt
+	var lastErr error
+	for i, provider := range f.providers {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "LLM call aborted", err, summarizeAttempts(attempts))
+		}
+		breaker := f.breakers[i]
+		if !breaker.Allow() {
+			logger.Debug("Skipping LLM provider with open circuit breaker", zap.String("provider", provider.Name()))
+			attempts = append(attempts, types.LLMAttempt{Provider: provider.Name(), Error: "circuit breaker open"})
+			continue
+		}
+
+		providerCtx, cancel := providerDeadline(ctx, len(f.providers)-i)
+		resp, err := f.try(ctx, providerCtx, logger, provider, breaker, req, &attempts)
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "LLM call aborted", err, summarizeAttempts(attempts))
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = stderrors.New("all circuit breakers are open")
+	}
+	return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "all LLM providers failed", lastErr, summarizeAttempts(attempts))
+}
+
+// try calls a single provider of the chain, retrying transient errors until its share of the
+// deadline (providerCtx) is used up, and records failed attempts. ctx is the caller's context.
+// try 调用降级链中的单个提供商，在其截止时间份额 (providerCtx) 用尽之前重试暂时性错误，并记录失败的尝试。
+// ctx 是调用方的 context。
+func (f *FallbackLLM) try(ctx, providerCtx context.Context, logger *zap.Logger, provider LLM, breaker *CircuitBreaker, req *ChatRequest, attempts *[]types.LLMAttempt) (*ChatResponse, error) {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		resp, err := Chat(providerCtx, provider, req)
+		duration := time.Since(start)
+		if err == nil {
+			breaker.Success()
+			if resp.Provider == "" {
+				resp.Provider = provider.Name()
+			}
+			resp.FailedAttempts = append(*attempts, resp.FailedAttempts...)
+			if len(*attempts) > 0 {
+				logger.Info("LLM call answered by fallback provider", zap.String("provider", resp.Provider), zap.Int("failedAttempts", len(*attempts)))
+			}
+			return resp, nil
+		}
+
+		*attempts = append(*attempts, types.LLMAttempt{Provider: provider.Name(), Attempt: attempt, Error: err.Error(), Duration: duration})
+		if ctx.Err() != nil {
+			// The caller gave up or the overall deadline passed; no provider can answer any more.
+			// 调用方已放弃或整体截止时间已到; 已没有提供商能够应答。
+			breaker.Abort()
+			return nil, err
+		}
+		if isClientError(err) {
+			// The provider answered and rejected the request; that says nothing about its health.
+			// 提供商已应答并拒绝了该请求; 这并不说明其健康状况。
+			breaker.Abort()
+			logger.Warn("LLM provider rejected the request, trying next provider", zap.String("provider", provider.Name()), zap.Int("attempts", attempt), zap.Error(err))
+			return nil, err
+		}
+		if !IsTransient(err) || attempt > f.cfg.MaxRetries || providerCtx.Err() != nil {
+			breaker.Failure()
+			logger.Warn("LLM provider failed, trying next provider", zap.String("provider", provider.Name()), zap.Int("attempts", attempt), zap.Error(err))
+			return nil, err
+		}
+
+		delay := Backoff(attempt, f.cfg.InitialBackoff, f.cfg.MaxBackoff)
+		if after := retryAfter(err); after > delay {
+			delay = after
+		}
+		logger.Debug("Retrying LLM provider after transient error", zap.String("provider", provider.Name()), zap.Int("attempt", attempt), zap.Duration("delay", delay), zap.Error(err))
+		if err := sleep(providerCtx, delay); err != nil {
+			if ctx.Err() != nil {
+				breaker.Abort()
+				return nil, err
+			}
+			// The provider's share of the deadline ran out while waiting.
+			// 等待期间该提供商的截止时间份额已用尽。
+			breaker.Failure()
+			return nil, err
+		}
+	}
+}
+
+// providerDeadline returns the context for the next provider of the chain: with a deadline, the
+// provider gets an equal share of the remaining time among the remaining providers.
+// providerDeadline 返回降级链中下一个提供商的 context: 有截止时间时，该提供商在剩余提供商中获得剩余时间的均等份额。
+func providerDeadline(ctx context.Context, remaining int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || remaining <= 1 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(remaining))
+}
+
+// summarizeAttempts renders the failed attempts for error details.
+// summarizeAttempts 将失败的尝试渲染为错误详情。
+func summarizeAttempts(attempts []types.LLMAttempt) string {
+	parts := make([]string, 0, len(attempts))
+	for _, a := range attempts {
+		parts = append(parts, fmt.Sprintf("%s#%d: %s", a.Provider, a.Attempt, a.Error))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ChainTimeout returns the deadline for a single logical LLM call to the provider: the configured
+// timeout, or for a fallback chain the total timeout (by default the timeout times the number of
+// providers of the chain). The chain is the one of the provider in use, e.g. a routed chain, rather
+// than the configured fallback providers.
+// ChainTimeout 返回对该提供商单次逻辑 LLM 调用的截止时长: 配置的超时时间，或对于降级链而言的总超时时间
+// (默认为超时时间乘以链中提供商数量)。所指的链是实际使用的提供商的链 (例如路由得到的链)，而非配置的降级提供商。
+func ChainTimeout(cfg types.LLMConfig, provider LLM) time.Duration {
+	members := len(ChainMembers(provider))
+	if members <= 1 || cfg.Timeout <= 0 {
+		return cfg.Timeout
+	}
+	if cfg.Fallback.TotalTimeout > 0 {
+		return cfg.Fallback.TotalTimeout
+	}
+	return cfg.Timeout * time.Duration(members)
+}
+
+// FallbackChain returns the provider names of the chain: the configured provider followed by the
+// fallback providers, without duplicates.
+// FallbackChain 返回降级链中的提供商名称: 所配置的提供商，其后是降级提供商 (去重)。
+func FallbackChain(cfg types.LLMConfig) []string {
+	seen := map[string]bool{}
+	var chain []string
+	for _, name := range append([]string{cfg.Provider}, cfg.Fallback.Providers...) {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		chain = append(chain, name)
+	}
+	return chain
+}
+
+// NewConfiguredLLM creates and registers every configured provider (see ConfiguredProviders) and
+// returns the provider diagnoses are sent to: the configured provider, or a registered FallbackLLM
+// over the fallback chain when fallback providers are configured.
+// NewConfiguredLLM 创建并注册每个已配置的提供商 (见 ConfiguredProviders)，并返回诊断所使用的提供商:
+// 所配置的提供商; 配置了降级提供商时，返回基于降级链并已注册的 FallbackLLM。
+func NewConfiguredLLM(cfg *types.LLMConfig) (LLM, error) {
+	for _, name := range ConfiguredProviders(*cfg) {
+		provider, err := NewLLMProvider(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		RegisterLLMProvider(provider)
+	}
+
+	var chain []LLM
+	for _, name := range FallbackChain(*cfg) {
+		provider, found := GetLLMProvider(name)
+		if !found {
+			return nil, errors.New(errors.ErrorCodeNotFound, "LLM provider of the fallback chain is not registered", name)
+		}
+		chain = append(chain, provider)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "no LLM provider configured", "set llm.provider")
+	}
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	fallback, err := NewFallbackLLM(chain, cfg.Fallback)
+	if err != nil {
+		return nil, err
+	}
+	RegisterLLMProvider(fallback)
+	return fallback, nil
+}
+
+// ChainPromptBudget returns the prompt budget that fits every provider of the fallback chain, so that
+// a prompt sized for the primary provider never overflows a fallback with a smaller context window.
+// ChainPromptBudget 返回适用于降级链中所有提供商的提示预算，使按主提供商确定大小的提示
+// 不会超出上下文窗口更小的降级提供商。
+func ChainPromptBudget(cfg types.LLMConfig) int {
+	return PromptBudgetForProviders(cfg, FallbackChain(cfg))
+}
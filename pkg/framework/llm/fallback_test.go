@@ -0,0 +1,216 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// httpLLM is a chat provider calling a fake server the way the HTTP providers do: the client
+// timeout is the whole LLM timeout and non-200 answers become StatusErrors.
+type httpLLM struct {
+	name   string
+	url    string
+	client *http.Client
+	calls  int32
+}
+
+func newHTTPLLM(name, url string, timeout time.Duration) *httpLLM {
+	return &httpLLM{name: name, url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (p *httpLLM) Name() string        { return p.name }
+func (p *httpLLM) Description() string { return "" }
+func (p *httpLLM) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
+	return GenerateTextViaChat(ctx, p, prompt, options)
+}
+func (p *httpLLM) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	atomic.AddInt32(&p.calls, 1)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewStatusError(resp, body)
+	}
+	return &ChatResponse{Message: Message{Role: RoleAssistant, Content: string(body)}, FinishReason: FinishReasonStop}, nil
+}
+func (p *httpLLM) Calls() int { return int(atomic.LoadInt32(&p.calls)) }
+
+// fakeServer answers with the statuses in order, repeating the last one; status 0 hangs until the
+// client gives up.
+func fakeServer(t *testing.T, statuses ...int) *httptest.Server {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&calls, 1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		if statuses[i] == 0 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(statuses[i])
+		io.WriteString(w, http.StatusText(statuses[i]))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fastFallback() types.LLMFallbackConfig {
+	return types.LLMFallbackConfig{InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, BreakerThreshold: 2, BreakerCooldown: time.Hour}
+}
+
+func TestFallbackReachesTheNextProviderWhenThePrimaryHangs(t *testing.T) {
+	timeout := 300 * time.Millisecond
+	primary := newHTTPLLM("hanging", fakeServer(t, 0).URL, timeout)
+	secondary := newHTTPLLM("secondary", fakeServer(t, http.StatusOK).URL, timeout)
+	chain, err := NewFallbackLLM([]LLM{primary, secondary}, fastFallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := types.LLMConfig{Provider: "hanging", Timeout: timeout, Fallback: types.LLMFallbackConfig{Providers: []string{"secondary"}}}
+	ctx, cancel := WithTimeout(context.Background(), ChainTimeout(cfg, chain))
+	defer cancel()
+	resp, err := chain.Chat(ctx, &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "diagnose"}}})
+	if err != nil {
+		t.Fatalf("the fallback provider was not reached within the chain deadline: %v", err)
+	}
+	if resp.Provider != "secondary" || len(resp.FailedAttempts) == 0 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestFallbackRetriesAndBreakers(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		primary          []int
+		answeredBy       string
+		primaryCalls     int
+		secondaryAnswers bool
+	}{
+		{"transient errors are retried", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, "primary", 3, true},
+		{"retries are exhausted", []int{http.StatusBadGateway}, "secondary", 3, true},
+		{"client errors are not retried nor counted", []int{http.StatusBadRequest}, "secondary", 1, true},
+		{"all providers fail", []int{http.StatusInternalServerError}, "", 3, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			primary := newHTTPLLM("primary", fakeServer(t, tc.primary...).URL, time.Second)
+			secondaryStatus := http.StatusOK
+			if !tc.secondaryAnswers {
+				secondaryStatus = http.StatusInternalServerError
+			}
+			secondary := newHTTPLLM("secondary", fakeServer(t, secondaryStatus).URL, time.Second)
+			cfg := fastFallback()
+			cfg.BreakerThreshold = 5
+			chain, err := NewFallbackLLM([]LLM{primary, secondary}, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := chain.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "diagnose"}}})
+			if tc.answeredBy == "" {
+				if err == nil || !strings.Contains(err.Error(), "all LLM providers failed") {
+					t.Fatalf("expected the chain to fail, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if resp.Provider != tc.answeredBy {
+				t.Errorf("answered by %q, want %q", resp.Provider, tc.answeredBy)
+			}
+			if primary.Calls() != tc.primaryCalls {
+				t.Errorf("primary called %d times, want %d", primary.Calls(), tc.primaryCalls)
+			}
+		})
+	}
+}
+
+func TestFallbackBreakerSkipsAFailingProvider(t *testing.T) {
+	primary := newHTTPLLM("primary", fakeServer(t, http.StatusServiceUnavailable).URL, time.Second)
+	secondary := newHTTPLLM("secondary", fakeServer(t, http.StatusOK).URL, time.Second)
+	cfg := fastFallback()
+	cfg.MaxRetries = -1
+	chain, err := NewFallbackLLM([]LLM{primary, secondary}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "diagnose"}}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := chain.Chat(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The breaker opens after two failures; the third call goes straight to the secondary.
+	if primary.Calls() != 2 || secondary.Calls() != 3 {
+		t.Fatalf("expected the open breaker to skip the primary, got %d primary and %d secondary calls", primary.Calls(), secondary.Calls())
+	}
+	if state := chain.breakers[0].State(); state != BreakerOpen {
+		t.Fatalf("primary breaker %s, want open", state)
+	}
+}
+
+func TestFallbackClientErrorsKeepTheBreakerClosed(t *testing.T) {
+	primary := newHTTPLLM("primary", fakeServer(t, http.StatusUnprocessableEntity).URL, time.Second)
+	secondary := newHTTPLLM("secondary", fakeServer(t, http.StatusOK).URL, time.Second)
+	chain, err := NewFallbackLLM([]LLM{primary, secondary}, fastFallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := chain.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "diagnose"}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if primary.Calls() != 3 || chain.breakers[0].State() != BreakerClosed {
+		t.Fatalf("client errors must not open the breaker: %d calls, breaker %s", primary.Calls(), chain.breakers[0].State())
+	}
+}
+
+func TestChainTimeout(t *testing.T) {
+	a, b, c := newHTTPLLM("a", "", time.Second), newHTTPLLM("b", "", time.Second), newHTTPLLM("c", "", time.Second)
+	pair, err := NewFallbackLLM([]LLM{a, b}, fastFallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	routed, err := NewFallbackLLM([]LLM{a, b, c}, fastFallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		cfg      types.LLMConfig
+		provider LLM
+		want     time.Duration
+	}{
+		{name: "single provider", cfg: types.LLMConfig{Timeout: time.Minute}, provider: a, want: time.Minute},
+		{name: "single routed provider with fallback configured", cfg: types.LLMConfig{Timeout: time.Minute, Fallback: types.LLMFallbackConfig{Providers: []string{"b"}}}, provider: a, want: time.Minute},
+		{name: "configured chain", cfg: types.LLMConfig{Timeout: time.Minute, Fallback: types.LLMFallbackConfig{Providers: []string{"b"}}}, provider: pair, want: 2 * time.Minute},
+		{name: "routed chain without fallback providers", cfg: types.LLMConfig{Timeout: time.Minute}, provider: routed, want: 3 * time.Minute},
+		{name: "routed chain larger than the configured one", cfg: types.LLMConfig{Timeout: time.Minute, Fallback: types.LLMFallbackConfig{Providers: []string{"b"}}}, provider: routed, want: 3 * time.Minute},
+		{name: "total timeout", cfg: types.LLMConfig{Timeout: time.Minute, Fallback: types.LLMFallbackConfig{TotalTimeout: 90 * time.Second}}, provider: routed, want: 90 * time.Second},
+		{name: "total timeout of a single provider", cfg: types.LLMConfig{Timeout: time.Minute, Fallback: types.LLMFallbackConfig{TotalTimeout: 90 * time.Second}}, provider: a, want: time.Minute},
+		{name: "no timeout", cfg: types.LLMConfig{}, provider: routed, want: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ChainTimeout(tc.cfg, tc.provider); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
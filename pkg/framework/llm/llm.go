@@ -9,6 +9,7 @@ import (
 	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"go.uber.org/zap"
 )
 
 // Package llm defines the interface for LLM providers and a registry.
@@ -95,6 +96,55 @@ func GetEnabledLLMProvider(cfg *types.LLMConfig) (LLM, error) {
 	return provider, nil
 }
 
+// ProviderFactory creates a provider instance with the given name from its configuration.
+// ProviderFactory 根据配置创建具有给定名称的提供商实例。
+type ProviderFactory func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (LLM, error)
+
+// Global factory registry keyed by provider type.
+// 以提供商类型为键的全局工厂注册表。
+var (
+	factoriesMu       sync.RWMutex
+	providerFactories = make(map[string]ProviderFactory)
+)
+
+// RegisterLLMProviderFactory registers the factory of a provider type (e.g., "openai").
+// RegisterLLMProviderFactory 注册某一提供商类型 (例如 "openai") 的工厂。
+// Provider packages call it from init(). It panics if the type is already registered.
+// 提供商包在 init() 中调用此函数。如果该类型已被注册，则会 panic。
+func RegisterLLMProviderFactory(providerType string, factory ProviderFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if _, exists := providerFactories[providerType]; exists {
+		panic(fmt.Sprintf("LLM provider factory for type '%s' already registered", providerType))
+	}
+	providerFactories[providerType] = factory
+}
+
+// NewLLMProvider creates the provider instance with the given name from the LLM configuration.
+// NewLLMProvider 根据 LLM 配置创建具有给定名称的提供商实例。
+// The instance is not registered; call RegisterLLMProvider to make it available by name.
+// 该实例不会被注册; 调用 RegisterLLMProvider 使其可按名称获取。
+func NewLLMProvider(name string, cfg *types.LLMConfig) (LLM, error) {
+	providerCfg, ok := cfg.ProviderConfig(name)
+	if !ok {
+		return nil, errors.New(errors.ErrorCodeNotFound, "LLM provider not configured", fmt.Sprintf("no configuration found for LLM provider '%s'", name))
+	}
+
+	factoriesMu.RLock()
+	factory, found := providerFactories[providerCfg.Type]
+	factoriesMu.RUnlock()
+	if !found {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "unsupported LLM provider type", fmt.Sprintf("LLM provider '%s' has unknown type '%s'", name, providerCfg.Type))
+	}
+
+	provider, err := factory(name, &providerCfg, cfg.Timeout)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to create LLM provider", err, name)
+	}
+	return provider, nil
+}
+
 // WithTimeout adds a timeout to the context for LLM calls.
 // WithTimeout 为 LLM 调用向 context 添加超时。
 func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
@@ -0,0 +1,145 @@
+package llmtest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+// Package llmtest provides a scripted LLM provider for tests that must run without a model or
+// network access.
+// 包 llmtest 提供一个脚本化的 LLM 提供商，用于必须在没有模型或网络访问的情况下运行的测试。
+
+// Reply is a scripted answer of a ScriptedLLM.
+// Reply 是 ScriptedLLM 的一个脚本化回答。
+type Reply struct {
+	// Match selects the requests the reply answers: the last message must contain it.
+	// An empty Match answers any request.
+	// Match 选择该回答所应答的请求: 最后一条消息必须包含它。为空时应答任何请求。
+	Match        string
+	Content      string           // Text of the answer / 回答文本
+	ToolCalls    []llm.ToolCall   // Tool calls to request / 要请求的工具调用
+	FinishReason llm.FinishReason // Defaults to stop, or tool_calls when ToolCalls are set / 默认为 stop，设置了 ToolCalls 时为 tool_calls
+	Usage        llm.Usage        // Tokens to report / 要报告的 token 数
+	Err          error            // Error to return instead of an answer / 代替回答返回的错误
+	// Times is how often the reply can be used; 0 means without limit.
+	// Times 是该回答可被使用的次数; 0 表示不限次数。
+	Times int
+	Delay time.Duration // Latency to simulate / 要模拟的延迟
+}
+
+// ScriptedLLM answers chat requests with the first scripted reply that matches and is not used up.
+// ScriptedLLM 使用第一个匹配且未用尽的脚本化回答来应答聊天请求。
+type ScriptedLLM struct {
+	name string
+
+	mu       sync.Mutex
+	replies  []Reply
+	used     []int
+	requests []*llm.ChatRequest
+}
+
+// Ensure ScriptedLLM implements the llm.ChatLLM interface.
+// 确保 ScriptedLLM 实现了 llm.ChatLLM 接口。
+var _ llm.ChatLLM = &ScriptedLLM{}
+
+// NewScriptedLLM creates a scripted provider with the replies in order of preference.
+// NewScriptedLLM 按优先顺序使用给定回答创建脚本化提供商。
+func NewScriptedLLM(name string, replies ...Reply) *ScriptedLLM {
+	return &ScriptedLLM{name: name, replies: replies, used: make([]int, len(replies))}
+}
+
+// Name returns the name of the provider.
+// Name 返回提供商名称。
+func (s *ScriptedLLM) Name() string {
+	return s.name
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (s *ScriptedLLM) Description() string {
+	return "Scripted LLM provider for tests."
+}
+
+// GenerateText sends the prompt as a single user message and returns the answer.
+// GenerateText 将提示作为单条用户消息发送并返回回答。
+func (s *ScriptedLLM) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
+	return llm.GenerateTextViaChat(ctx, s, prompt, options)
+}
+
+// Chat records the request and answers with the matching scripted reply.
+// Chat 记录请求并使用匹配的脚本化回答应答。
+func (s *ScriptedLLM) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	reply, err := s.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Delay > 0 {
+		select {
+		case <-time.After(reply.Delay):
+		case <-ctx.Done():
+			return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "scripted LLM call cancelled", ctx.Err(), s.name)
+		}
+	}
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+
+	finish := reply.FinishReason
+	if finish == "" {
+		finish = llm.FinishReasonStop
+		if len(reply.ToolCalls) > 0 {
+			finish = llm.FinishReasonToolCalls
+		}
+	}
+	return &llm.ChatResponse{
+		Message:      llm.Message{Role: llm.RoleAssistant, Content: reply.Content, ToolCalls: reply.ToolCalls},
+		FinishReason: finish,
+		Usage:        reply.Usage,
+		Model:        s.name,
+	}, nil
+}
+
+// next records the request and picks the reply for it.
+// next 记录请求并为其选择回答。
+func (s *ScriptedLLM) next(req *llm.ChatRequest) (Reply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	last := ""
+	if len(req.Messages) > 0 {
+		last = req.Messages[len(req.Messages)-1].Content
+	}
+	for i, reply := range s.replies {
+		if reply.Times > 0 && s.used[i] >= reply.Times {
+			continue
+		}
+		if reply.Match != "" && !strings.Contains(last, reply.Match) {
+			continue
+		}
+		s.used[i]++
+		return reply, nil
+	}
+	return Reply{}, errors.New(errors.ErrorCodeNotFound, "no scripted reply for the request", s.name)
+}
+
+// Requests returns the requests received so far.
+// Requests 返回目前收到的请求。
+func (s *ScriptedLLM) Requests() []*llm.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*llm.ChatRequest(nil), s.requests...)
+}
+
+// Calls returns the number of requests received so far.
+// Calls 返回目前收到的请求数。
+func (s *ScriptedLLM) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
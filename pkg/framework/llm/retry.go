@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+)
+
+// StatusError is returned (wrapped) by HTTP-based providers when the API answers with a non-200 status.
+// StatusError 在 API 返回非 200 状态码时由基于 HTTP 的提供商返回 (被包装)。
+type StatusError struct {
+	StatusCode int           // HTTP status code / HTTP 状态码
+	Body       string        // Response body / 响应体
+	RetryAfter time.Duration // Delay requested by the Retry-After header, if any / Retry-After 请求头要求的延迟 (如有)
+}
+
+// Error implements the error interface.
+// Error 实现 error 接口。
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// NewStatusError creates a StatusError from an HTTP response and its body.
+// NewStatusError 根据 HTTP 响应及其响应体创建 StatusError。
+func NewStatusError(resp *http.Response, body []byte) *StatusError {
+	statusErr := &StatusError{StatusCode: resp.StatusCode, Body: BodyExcerpt(body)}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After"))); err == nil && seconds > 0 {
+		statusErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return statusErr
+}
+
+// ReadResponseBody reads a provider response body, failing if it is larger than
+// constants.MaxLLMResponseBytes so that a misbehaving server cannot exhaust the agent's memory.
+// ReadResponseBody 读取提供商响应体，超过 constants.MaxLLMResponseBytes 时失败，
+// 以防行为异常的服务端耗尽代理的内存。
+func ReadResponseBody(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, constants.MaxLLMResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > constants.MaxLLMResponseBytes {
+		return nil, fmt.Errorf("response body is larger than %d bytes", constants.MaxLLMResponseBytes)
+	}
+	return data, nil
+}
+
+// BodyExcerpt returns the start of a response body, short enough for logs and error messages.
+// BodyExcerpt 返回响应体的开头部分，其长度适合日志和错误信息。
+func BodyExcerpt(body []byte) string {
+	text := string(body)
+	if utf8.RuneCountInString(text) <= constants.MaxLLMErrorBodyChars {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:constants.MaxLLMErrorBodyChars]) + fmt.Sprintf("... (%d bytes)", len(body))
+}
+
+// IsTransient reports whether a provider error is worth retrying: timeouts, rate limiting (429)
+// and server errors (5xx).
+// IsTransient 报告提供商错误是否值得重试: 超时、限流 (429) 和服务端错误 (5xx)。
+// Cancellation by the caller is never transient.
+// 调用方的取消永远不是暂时性错误。
+func IsTransient(err error) bool {
+	if err == nil || stderrors.Is(err, context.Canceled) {
+		return false
+	}
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var statusErr *StatusError
+	if stderrors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return stderrors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isClientError reports whether the provider rejected the request with a 4xx status other than
+// 429: the provider is reachable, so the error neither is retried nor trips its circuit breaker.
+// isClientError 报告提供商是否以 429 以外的 4xx 状态码拒绝了请求: 此时提供商是可达的，
+// 因此该错误既不会重试，也不会触发其熔断器。
+func isClientError(err error) bool {
+	var statusErr *StatusError
+	return stderrors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != http.StatusTooManyRequests
+}
+
+// retryAfter returns the delay requested by the provider, or 0.
+// retryAfter 返回提供商要求的延迟，或 0。
+func retryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if stderrors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+// Backoff returns the delay before retry number attempt (starting at 1): exponential growth from
+// initial, capped at max, with "equal jitter" so that the delay lies in [d/2, d].
+// Backoff 返回第 attempt 次重试 (从 1 开始) 之前的延迟: 从 initial 开始指数增长，上限为 max，
+// 并使用 "equal jitter" 使延迟落在 [d/2, d] 区间内。
+func Backoff(attempt int, initial, max time.Duration) time.Duration {
+	if initial <= 0 {
+		return 0
+	}
+	d := initial
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// sleep waits for d or until the context is done.
+// sleep 等待 d 或直到 context 结束。
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
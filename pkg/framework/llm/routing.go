@@ -0,0 +1,250 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"go.uber.org/zap"
+)
+
+// Route is the outcome of a routing decision for a set of issues.
+// Route 是针对一组问题的路由决策结果。
+type Route struct {
+	Provider   LLM      // Provider to call; a fallback chain if several providers are allowed / 要调用的提供商; 允许多个提供商时为降级链
+	Providers  []string // Names of the providers the evidence may be sent to, in order / 证据可发送到的提供商名称，按顺序排列
+	Rules      []string // Names of the matched rules / 匹配的规则名称
+	Restricted bool     // Whether a restricted rule limited the providers / 是否有受限规则限制了提供商
+}
+
+// Router chooses which providers may see the evidence of an incident.
+// Router 选择哪些提供商可以看到某个事件的证据。
+type Router struct {
+	cfg      types.LLMConfig
+	fallback LLM
+
+	mu     sync.Mutex
+	chains map[string]LLM // Fallback chains per provider list, so that breakers persist / 按提供商列表缓存的降级链，使熔断器状态得以保留
+}
+
+// NewRouter creates a router for the routing rules of the configuration.
+// NewRouter 为配置中的路由规则创建路由器。
+// defaultProvider answers issues that match no rule. Providers named by rules must be registered
+// with RegisterLLMProvider before routing.
+// defaultProvider 应答不匹配任何规则的问题。规则中指定的提供商必须在路由前通过 RegisterLLMProvider 注册。
+func NewRouter(cfg types.LLMConfig, defaultProvider LLM) *Router {
+	for i := range cfg.Routing.Rules {
+		if cfg.Routing.Rules[i].Name == "" {
+			cfg.Routing.Rules[i].Name = fmt.Sprintf("rule-%d", i+1)
+		}
+	}
+	return &Router{cfg: cfg, fallback: defaultProvider, chains: map[string]LLM{}}
+}
+
+// Route decides which providers may receive the evidence of the issues and logs the decision.
+// Route 决定哪些提供商可以接收这些问题的证据，并记录该决策。
+// The providers of every issue are preferred by the first rule it matches, while every restricted
+// rule it matches applies regardless of its position, so an earlier unrestricted rule cannot route
+// a restricted tenant's evidence elsewhere. Providers of matched rules are preferred over the
+// default chain. If any matched rule is restricted, only providers approved by every restricted
+// rule remain; if none of them is available an ErrorCodePermissionDenied error is returned and the
+// evidence must not be sent to any LLM.
+// 每个问题的首选提供商由其匹配的第一条规则决定，而其匹配的每条受限规则无论位置如何都会生效，
+// 因此靠前的非受限规则无法将受限租户的证据路由到别处。匹配规则中的提供商优先于默认降级链。
+// 如果任一匹配规则是受限的，则只保留所有受限规则都批准的提供商; 若其中没有可用的提供商，
+// 则返回 ErrorCodePermissionDenied 错误，证据不得发送给任何 LLM。
+func (r *Router) Route(ctx context.Context, issues []types.Issue) (*Route, error) {
+	logger := log.LWithContext(ctx)
+
+	matched := make([]bool, len(r.cfg.Routing.Rules))
+	for _, issue := range issues {
+		for _, i := range r.match(issue) {
+			matched[i] = true
+		}
+	}
+
+	route := &Route{}
+	var preferred []string
+	var approved map[string]bool
+	for i, rule := range r.cfg.Routing.Rules {
+		if !matched[i] {
+			continue
+		}
+		route.Rules = append(route.Rules, rule.Name)
+		preferred = append(preferred, rule.Providers...)
+		if rule.Restricted {
+			route.Restricted = true
+			allowed := make(map[string]bool, len(rule.Providers))
+			for _, name := range rule.Providers {
+				if approved == nil || approved[name] {
+					allowed[name] = true
+				}
+			}
+			approved = allowed
+		}
+	}
+
+	if len(route.Rules) == 0 {
+		route.Provider = r.fallback
+		route.Providers = FallbackChain(r.cfg)
+		if len(route.Providers) == 0 {
+			route.Providers = []string{r.fallback.Name()}
+		}
+		logger.Debug("LLM routing decision: no rule matched, using the default provider", zap.String("provider", r.fallback.Name()))
+		return route, nil
+	}
+
+	// Preferred providers first, then the default chain; restricted rules filter both.
+	// 首选提供商在前，其后是默认降级链; 受限规则对两者都进行过滤。
+	seen := map[string]bool{}
+	var providers []LLM
+	for _, name := range append(preferred, FallbackChain(r.cfg)...) {
+		if seen[name] || (route.Restricted && !approved[name]) {
+			continue
+		}
+		seen[name] = true
+		provider, found := GetLLMProvider(name)
+		if !found {
+			logger.Warn("LLM provider named by routing rule is not available", zap.String("provider", name), zap.Strings("rules", route.Rules))
+			continue
+		}
+		route.Providers = append(route.Providers, name)
+		providers = append(providers, provider)
+	}
+
+	if len(providers) == 0 {
+		logger.Warn("LLM routing decision: no approved provider for restricted issues, evidence will not be sent to any LLM", zap.Strings("rules", route.Rules))
+		return route, errors.New(errors.ErrorCodePermissionDenied, "no approved LLM provider", fmt.Sprintf("routing rules %s restrict the evidence to providers that are not available", strings.Join(route.Rules, ", ")))
+	}
+
+	provider, err := r.chain(providers)
+	if err != nil {
+		return route, err
+	}
+	route.Provider = provider
+	logger.Info("LLM routing decision", zap.Strings("rules", route.Rules), zap.Strings("providers", route.Providers), zap.Bool("restricted", route.Restricted))
+	return route, nil
+}
+
+// match returns the indexes of the rules that govern the issue: the first rule it matches and
+// every restricted rule it matches.
+// match 返回决定该问题的规则索引: 其匹配的第一条规则以及其匹配的每条受限规则。
+func (r *Router) match(issue types.Issue) []int {
+	var vcluster, namespace, service string
+	if issue.Resource != nil {
+		vcluster = issue.Resource.VCluster
+		namespace = issue.Resource.Namespace
+		if issue.Resource.Type == "BusinessService" {
+			service = issue.Resource.Name
+		}
+	}
+	severity := issue.Severity.String()
+
+	var matched []int
+	for i, rule := range r.cfg.Routing.Rules {
+		if len(matched) > 0 && !rule.Restricted {
+			continue
+		}
+		if matchesAny(rule.VClusters, vcluster, false) &&
+			matchesAny(rule.Namespaces, namespace, false) &&
+			matchesAny(rule.Services, service, false) &&
+			matchesAny(rule.Severities, severity, true) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
+
+// matchesAny reports whether value matches one of the glob patterns; an empty list matches anything.
+// matchesAny 报告 value 是否匹配其中一个通配符模式; 空列表匹配任何值。
+func matchesAny(patterns []string, value string, foldCase bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+	for _, pattern := range patterns {
+		if foldCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// chain returns the single provider or a cached fallback chain over the providers.
+// chain 返回单个提供商，或基于这些提供商的缓存降级链。
+func (r *Router) chain(providers []LLM) (LLM, error) {
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	key := strings.Join(names, ",")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if chain, ok := r.chains[key]; ok {
+		return chain, nil
+	}
+	chain, err := NewFallbackLLM(providers, r.cfg.Fallback)
+	if err != nil {
+		return nil, err
+	}
+	r.chains[key] = chain
+	return chain, nil
+}
+
+// ConfiguredProviders returns the names of all providers that must be created: the fallback
+// chain followed by the providers named by routing rules and the upstreams of replay providers.
+// ConfiguredProviders 返回必须创建的所有提供商名称: 降级链，其后是路由规则中指定的提供商以及回放提供商的上游提供商。
+func ConfiguredProviders(cfg types.LLMConfig) []string {
+	names := FallbackChain(cfg)
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, rule := range cfg.Routing.Rules {
+		for _, name := range rule.Providers {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for i := 0; i < len(names); i++ {
+		if providerCfg, ok := cfg.ProviderConfig(names[i]); ok && providerCfg.Upstream != "" && !seen[providerCfg.Upstream] {
+			seen[providerCfg.Upstream] = true
+			names = append(names, providerCfg.Upstream)
+		}
+	}
+	return names
+}
+
+// PromptBudgetForProviders returns the prompt budget that fits every named provider.
+// PromptBudgetForProviders 返回适用于所有指定提供商的提示预算。
+func PromptBudgetForProviders(cfg types.LLMConfig, names []string) int {
+	budget := 0
+	for _, name := range names {
+		if providerCfg, ok := cfg.ProviderConfig(name); ok {
+			if b := PromptBudgetFor(providerCfg); budget == 0 || b < budget {
+				budget = b
+			}
+		}
+	}
+	if budget == 0 {
+		budget = PromptBudgetFor(cfg.EnabledProviderConfig())
+	}
+	return budget
+}
@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types/enum"
+)
+
+type namedLLM struct{ name string }
+
+func (p namedLLM) Name() string        { return p.name }
+func (p namedLLM) Description() string { return "" }
+func (p namedLLM) GenerateText(context.Context, string, map[string]interface{}) (string, error) {
+	return p.name, nil
+}
+
+var registerRouteProviders sync.Once
+
+// routeProviders registers the providers named by the routing tests once per test binary.
+func routeProviders() {
+	registerRouteProviders.Do(func() {
+		for _, name := range []string{"route-local", "route-eu", "route-deepseek", "route-default"} {
+			RegisterLLMProvider(namedLLM{name: name})
+		}
+	})
+}
+
+func routeConfig(rules ...types.LLMRoutingRule) types.LLMConfig {
+	return types.LLMConfig{Provider: "route-default", Routing: types.LLMRoutingConfig{Rules: rules}}
+}
+
+var (
+	criticalRule = types.LLMRoutingRule{Name: "critical", Severities: []string{"critical"}, Providers: []string{"route-deepseek"}}
+	financeRule  = types.LLMRoutingRule{Name: "finance", VClusters: []string{"finance-*"}, Providers: []string{"route-local"}, Restricted: true}
+)
+
+func routeIssue(vcluster string, severity enum.IssueSeverity) types.Issue {
+	return types.Issue{Severity: severity, Resource: &types.IssueResource{Type: "Pod", VCluster: vcluster, Namespace: "web", Name: "web-0"}}
+}
+
+func TestRouterRoute(t *testing.T) {
+	routeProviders()
+	for _, tc := range []struct {
+		name       string
+		rules      []types.LLMRoutingRule
+		issues     []types.Issue
+		matched    []string
+		providers  []string
+		restricted bool
+		denied     bool
+	}{
+		{
+			name:      "no rule matches",
+			rules:     []types.LLMRoutingRule{financeRule},
+			issues:    []types.Issue{routeIssue("team-a", enum.IssueSeverityError)},
+			providers: []string{"route-default"},
+		},
+		{
+			name:      "unrestricted rule prefers its provider over the default chain",
+			rules:     []types.LLMRoutingRule{criticalRule},
+			issues:    []types.Issue{routeIssue("team-a", enum.IssueSeverityCritical)},
+			matched:   []string{"critical"},
+			providers: []string{"route-deepseek", "route-default"},
+		},
+		{
+			name:       "restricted rule after an unrestricted rule still applies",
+			rules:      []types.LLMRoutingRule{criticalRule, financeRule},
+			issues:     []types.Issue{routeIssue("finance-a", enum.IssueSeverityCritical)},
+			matched:    []string{"critical", "finance"},
+			providers:  []string{"route-local"},
+			restricted: true,
+		},
+		{
+			name:       "restricted rule before an unrestricted rule",
+			rules:      []types.LLMRoutingRule{financeRule, criticalRule},
+			issues:     []types.Issue{routeIssue("finance-a", enum.IssueSeverityCritical)},
+			matched:    []string{"finance"},
+			providers:  []string{"route-local"},
+			restricted: true,
+		},
+		{
+			name:       "mixed-tenant incident is limited to the restricted tenant's providers",
+			rules:      []types.LLMRoutingRule{criticalRule, financeRule},
+			issues:     []types.Issue{routeIssue("team-a", enum.IssueSeverityCritical), routeIssue("finance-a", enum.IssueSeverityWarning)},
+			matched:    []string{"critical", "finance"},
+			providers:  []string{"route-local"},
+			restricted: true,
+		},
+		{
+			name: "restricted rules with overlapping providers use the intersection",
+			rules: []types.LLMRoutingRule{
+				{Name: "eu", VClusters: []string{"*-eu"}, Providers: []string{"route-eu", "route-local"}, Restricted: true},
+				{Name: "finance", VClusters: []string{"finance-*"}, Providers: []string{"route-local", "route-eu"}, Restricted: true},
+			},
+			issues:     []types.Issue{routeIssue("finance-eu", enum.IssueSeverityError)},
+			matched:    []string{"eu", "finance"},
+			providers:  []string{"route-eu", "route-local"},
+			restricted: true,
+		},
+		{
+			name: "restricted rules of a mixed-tenant incident without common providers are refused",
+			rules: []types.LLMRoutingRule{
+				{Name: "eu", VClusters: []string{"*-eu"}, Providers: []string{"route-eu"}, Restricted: true},
+				financeRule,
+			},
+			issues:     []types.Issue{routeIssue("team-eu", enum.IssueSeverityError), routeIssue("finance-a", enum.IssueSeverityError)},
+			matched:    []string{"eu", "finance"},
+			restricted: true,
+			denied:     true,
+		},
+		{
+			name:       "restricted rule naming an unavailable provider is refused",
+			rules:      []types.LLMRoutingRule{{Name: "gov", VClusters: []string{"gov-*"}, Providers: []string{"route-missing"}, Restricted: true}},
+			issues:     []types.Issue{routeIssue("gov-a", enum.IssueSeverityError)},
+			matched:    []string{"gov"},
+			restricted: true,
+			denied:     true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			router := NewRouter(routeConfig(tc.rules...), namedLLM{name: "route-default"})
+			route, err := router.Route(context.Background(), tc.issues)
+			if tc.denied {
+				if !errors.IsErrorCode(err, errors.ErrorCodePermissionDenied) {
+					t.Fatalf("expected permission denied, got %v (providers %v)", err, route.Providers)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(route.Rules, tc.matched) {
+				t.Errorf("rules = %v, want %v", route.Rules, tc.matched)
+			}
+			if !tc.denied && !reflect.DeepEqual(route.Providers, tc.providers) {
+				t.Errorf("providers = %v, want %v", route.Providers, tc.providers)
+			}
+			if route.Restricted != tc.restricted {
+				t.Errorf("restricted = %v, want %v", route.Restricted, tc.restricted)
+			}
+		})
+	}
+}
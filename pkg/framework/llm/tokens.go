@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// TokenEstimator estimates how many tokens a text occupies for a specific model.
+// TokenEstimator 估算文本在特定模型下占用的 token 数量。
+// Providers that know their exact tokenizer can implement this interface themselves;
+// otherwise EstimatorFor falls back to a per-model-family heuristic.
+// 知道确切分词器的提供商可以自行实现此接口; 否则 EstimatorFor 会回退到按模型系列的启发式估算。
+type TokenEstimator interface {
+	EstimateTokens(text string) int
+}
+
+// HeuristicEstimator estimates tokens from character classes.
+// HeuristicEstimator 根据字符类别估算 token 数量。
+// Ratios are tokens per character; CJK text is much denser than Latin text for most tokenizers.
+// 比例为每个字符对应的 token 数; 对大多数分词器而言，中日韩文本比拉丁文本密度高得多。
+type HeuristicEstimator struct {
+	LatinRatio float64 // Tokens per ASCII/Latin character / 每个 ASCII/拉丁字符的 token 数
+	CJKRatio   float64 // Tokens per CJK character / 每个中日韩字符的 token 数
+	OtherRatio float64 // Tokens per other character / 每个其他字符的 token 数
+}
+
+// EstimateTokens implements TokenEstimator.
+// EstimateTokens 实现 TokenEstimator 接口。
+func (h HeuristicEstimator) EstimateTokens(text string) int {
+	var latin, cjk, other int
+	for _, r := range text {
+		switch {
+		case r < unicode.MaxASCII:
+			latin++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		default:
+			other++
+		}
+	}
+	tokens := float64(latin)*h.LatinRatio + float64(cjk)*h.CJKRatio + float64(other)*h.OtherRatio
+	return int(math.Ceil(tokens))
+}
+
+// modelFamily holds token heuristics and the default context window for a family of models.
+// modelFamily 保存一个模型系列的 token 启发式参数和默认上下文窗口。
+type modelFamily struct {
+	prefix        string
+	estimator     HeuristicEstimator
+	contextWindow int
+}
+
+// modelFamilies is matched by model name prefix, most specific first.
+// modelFamilies 按模型名前缀匹配，越具体的越靠前。
+var modelFamilies = []modelFamily{
+	{prefix: "deepseek-coder", estimator: HeuristicEstimator{LatinRatio: 0.3, CJKRatio: 0.6, OtherRatio: 0.6}, contextWindow: 16384},
+	{prefix: "deepseek", estimator: HeuristicEstimator{LatinRatio: 0.3, CJKRatio: 0.6, OtherRatio: 0.6}, contextWindow: 65536},
+	{prefix: "gpt-4o", estimator: HeuristicEstimator{LatinRatio: 0.25, CJKRatio: 0.8, OtherRatio: 0.5}, contextWindow: 128000},
+	{prefix: "gpt-4", estimator: HeuristicEstimator{LatinRatio: 0.25, CJKRatio: 1.0, OtherRatio: 0.5}, contextWindow: 8192},
+	{prefix: "gpt-3.5", estimator: HeuristicEstimator{LatinRatio: 0.25, CJKRatio: 1.0, OtherRatio: 0.5}, contextWindow: 16385},
+	{prefix: "qwen", estimator: HeuristicEstimator{LatinRatio: 0.27, CJKRatio: 0.7, OtherRatio: 0.6}, contextWindow: 32768},
+	{prefix: "llama3", estimator: HeuristicEstimator{LatinRatio: 0.27, CJKRatio: 1.2, OtherRatio: 0.7}, contextWindow: 8192},
+	{prefix: "llama", estimator: HeuristicEstimator{LatinRatio: 0.3, CJKRatio: 1.5, OtherRatio: 0.8}, contextWindow: 4096},
+	{prefix: "mistral", estimator: HeuristicEstimator{LatinRatio: 0.3, CJKRatio: 1.5, OtherRatio: 0.8}, contextWindow: 8192},
+}
+
+// defaultEstimator is deliberately conservative so unknown local models are not overrun.
+// defaultEstimator 刻意保守，避免超出未知本地模型的上下文。
+var defaultEstimator = HeuristicEstimator{LatinRatio: 0.33, CJKRatio: 1.5, OtherRatio: 1.0}
+
+// lookupModelFamily returns the family matching a model name, if any.
+// lookupModelFamily 返回与模型名称匹配的模型系列 (如果存在)。
+func lookupModelFamily(model string) (modelFamily, bool) {
+	model = strings.ToLower(model)
+	// Strip registry/path prefixes such as "TheBloke/" or "library/".
+	// 去掉诸如 "TheBloke/" 或 "library/" 的仓库/路径前缀。
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, family := range modelFamilies {
+		if strings.HasPrefix(model, family.prefix) {
+			return family, true
+		}
+	}
+	return modelFamily{}, false
+}
+
+// EstimatorFor returns the token estimator for a provider and model.
+// EstimatorFor 返回提供商和模型对应的 token 估算器。
+func EstimatorFor(provider LLM, model string) TokenEstimator {
+	if estimator, ok := provider.(TokenEstimator); ok {
+		return estimator
+	}
+	if family, ok := lookupModelFamily(model); ok {
+		return family.estimator
+	}
+	return defaultEstimator
+}
+
+// ContextWindowFor returns the context window of a configured model in tokens.
+// ContextWindowFor 返回已配置模型的上下文窗口 (以 token 计)。
+// An explicit contextWindow in the config wins over the built-in model table.
+// 配置中显式设置的 contextWindow 优先于内置模型表。
+func ContextWindowFor(cfg types.LLMProviderConfig) int {
+	if cfg.ContextWindow > 0 {
+		return cfg.ContextWindow
+	}
+	if family, ok := lookupModelFamily(cfg.Model); ok {
+		return family.contextWindow
+	}
+	return constants.DefaultLLMContextWindow
+}
+
+// MaxOutputTokensFor returns the completion token limit requested from a configured model.
+// MaxOutputTokensFor 返回向已配置模型请求的补全 token 上限。
+func MaxOutputTokensFor(cfg types.LLMProviderConfig) int {
+	if cfg.MaxTokens > 0 {
+		return cfg.MaxTokens
+	}
+	return constants.DefaultLLMMaxTokens
+}
+
+// PromptBudgetFor returns how many tokens a prompt may use so that the prompt plus the
+// requested completion fit into the model's context window.
+// PromptBudgetFor 返回提示可使用的 token 数，使提示加上请求的补全能够放入模型的上下文窗口。
+func PromptBudgetFor(cfg types.LLMProviderConfig) int {
+	window := ContextWindowFor(cfg)
+	// Keep a safety margin because estimates are approximate and chat templates add overhead.
+	// 保留安全余量，因为估算是近似值，且对话模板会增加额外开销。
+	budget := window - MaxOutputTokensFor(cfg) - window/20
+	if budget < window/4 {
+		budget = window / 4
+	}
+	return budget
+}
@@ -0,0 +1,395 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"go.uber.org/zap"
+)
+
+// dateLayout formats the day key of the usage meter.
+// dateLayout 用于格式化用量计量器的日期键。
+const dateLayout = "2006-01-02"
+
+// UsageMeter aggregates LLM calls per day (UTC), per provider/model and per vcluster, and enforces
+// the daily budgets of the configuration.
+// UsageMeter 按天 (UTC)、按提供商/模型和按 vcluster 汇总 LLM 调用，并执行配置中的每日预算。
+type UsageMeter struct {
+	cfg types.LLMConfig
+
+	mu       sync.Mutex
+	days     map[string]*types.LLMDailyUsage
+	reserved map[string]*types.LLMDailyUsage // Estimates of the calls in flight, per day / 进行中调用的估算用量，按天
+}
+
+// NewUsageMeter creates a usage meter for the LLM configuration.
+// NewUsageMeter 为 LLM 配置创建用量计量器。
+func NewUsageMeter(cfg types.LLMConfig) *UsageMeter {
+	return &UsageMeter{cfg: cfg, days: map[string]*types.LLMDailyUsage{}, reserved: map[string]*types.LLMDailyUsage{}}
+}
+
+// Cost estimates the cost of a call from the prices configured for the provider.
+// Cost 根据为提供商配置的价格估算一次调用的成本。
+func (m *UsageMeter) Cost(provider string, promptTokens, completionTokens int) float64 {
+	providerCfg, ok := m.cfg.ProviderConfig(provider)
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*providerCfg.PromptPrice + float64(completionTokens)*providerCfg.CompletionPrice) / 1e6
+}
+
+// Costliest prices the estimates of a call for each provider that may answer it and returns the
+// most expensive one, or the one with the most tokens if none is priced. Reserving it keeps a
+// fallback chain from overspending whichever provider answers.
+// Costliest 为可能应答一次调用的每个提供商的估算用量定价，并返回最昂贵的一个; 若都没有定价，则返回 token 最多的一个。
+// 预留该估算可确保降级链无论由哪个提供商应答都不会超支。
+func (m *UsageMeter) Costliest(estimates ...types.LLMCall) types.LLMCall {
+	var costliest types.LLMCall
+	for i, estimate := range estimates {
+		estimate = m.priced(estimate)
+		if i == 0 || estimate.Cost > costliest.Cost ||
+			(estimate.Cost == costliest.Cost && estimate.PromptTokens+estimate.CompletionTokens > costliest.PromptTokens+costliest.CompletionTokens) {
+			costliest = estimate
+		}
+	}
+	return costliest
+}
+
+// recordLocked adds a call to the day, for each of the vclusters. The caller must hold m.mu.
+// recordLocked 将一次调用计入该日期，并计入每个 vcluster。调用方必须持有 m.mu。
+func (m *UsageMeter) recordLocked(day *types.LLMDailyUsage, vclusters []string, call types.LLMCall) {
+	day.Total.Add(call)
+
+	key := call.Provider
+	if call.Model != "" {
+		key += "/" + call.Model
+	}
+	usage := day.Providers[key]
+	usage.Add(call)
+	day.Providers[key] = usage
+
+	for _, vcluster := range vclusters {
+		usage := day.VClusters[vcluster]
+		usage.Add(call)
+		day.VClusters[vcluster] = usage
+	}
+}
+
+// dayLocked returns the usage of the day of now, creating it and dropping days past the retention.
+// The caller must hold m.mu.
+// dayLocked 返回 now 所在日期的用量，必要时创建该日期并丢弃超出保留期的日期。调用方必须持有 m.mu。
+func (m *UsageMeter) dayLocked(now time.Time) *types.LLMDailyUsage {
+	date := now.UTC().Format(dateLayout)
+	if day, ok := m.days[date]; ok {
+		return day
+	}
+
+	// A new day started: report the previous days once and forget the oldest ones.
+	// 新的一天开始: 报告之前的日期，并丢弃最旧的日期。
+	cutoff := now.UTC().AddDate(0, 0, -constants.DefaultLLMUsageRetentionDays).Format(dateLayout)
+	for d, usage := range m.days {
+		if d < cutoff {
+			delete(m.days, d)
+			delete(m.reserved, d)
+			continue
+		}
+		if d == now.UTC().AddDate(0, 0, -1).Format(dateLayout) {
+			log.L().Info("LLM usage for the previous day", zap.String("date", d), zap.Int("runs", usage.Runs), zap.Int("calls", usage.Total.Calls),
+				zap.Int("tokens", usage.Total.Tokens()), zap.Float64("cost", usage.Total.Cost), zap.Any("providers", usage.Providers))
+		}
+	}
+
+	day := &types.LLMDailyUsage{Date: date, Providers: map[string]types.LLMUsage{}, VClusters: map[string]types.LLMUsage{}}
+	m.days[date] = day
+	return day
+}
+
+// Day returns a copy of the usage of the day (UTC) of t.
+// Day 返回 t 所在日期 (UTC) 用量的副本。
+func (m *UsageMeter) Day(t time.Time) types.LLMDailyUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	date := t.UTC().Format(dateLayout)
+	out := types.LLMDailyUsage{Date: date, Providers: map[string]types.LLMUsage{}, VClusters: map[string]types.LLMUsage{}}
+	if day, ok := m.days[date]; ok {
+		out.Total = day.Total
+		out.Runs = day.Runs
+		for k, v := range day.Providers {
+			out.Providers[k] = v
+		}
+		for k, v := range day.VClusters {
+			out.VClusters[k] = v
+		}
+	}
+	return out
+}
+
+// CheckBudget returns an ErrorCodeBudgetExceeded error if the overall daily budget or the budget
+// of any of the vclusters is used up for the day of now, counting the calls in flight.
+// CheckBudget 如果 now 所在日期的总体每日预算或任一 vcluster 的预算已用尽 (包括进行中的调用)，
+// 则返回 ErrorCodeBudgetExceeded 错误。
+func (m *UsageMeter) CheckBudget(now time.Time, vclusters []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checkLocked(now.UTC().Format(dateLayout), vclusters, types.LLMCall{})
+}
+
+// checkLocked checks that the usage of the day plus the reserved usage plus the estimate stays
+// within the budgets. The caller must hold m.mu.
+// checkLocked 检查当天用量加上预留用量和估算用量是否仍在预算之内。调用方必须持有 m.mu。
+func (m *UsageMeter) checkLocked(date string, vclusters []string, estimate types.LLMCall) error {
+	budget := m.cfg.Budget
+	var extra types.LLMUsage
+	extra.Add(estimate)
+	used := func(vcluster string, total bool) types.LLMUsage {
+		var usage types.LLMUsage
+		for _, days := range []map[string]*types.LLMDailyUsage{m.days, m.reserved} {
+			if day, ok := days[date]; ok {
+				if total {
+					addUsage(&usage, day.Total)
+				} else {
+					addUsage(&usage, day.VClusters[vcluster])
+				}
+			}
+		}
+		return usage
+	}
+
+	if reason := exceeded(budget.LLMBudgetLimit, used("", true), extra); reason != "" {
+		return errors.New(errors.ErrorCodeBudgetExceeded, "daily LLM budget exhausted", reason)
+	}
+	if len(vclusters) == 0 {
+		vclusters = []string{""}
+	}
+	sorted := append([]string(nil), vclusters...)
+	sort.Strings(sorted)
+	for _, vcluster := range sorted {
+		limit, ok := budget.VClusters[vcluster]
+		if !ok {
+			continue
+		}
+		if reason := exceeded(limit, used(vcluster, false), extra); reason != "" {
+			return errors.New(errors.ErrorCodeBudgetExceeded, "daily LLM budget of vcluster exhausted", fmt.Sprintf("vcluster %q: %s", vcluster, reason))
+		}
+	}
+	return nil
+}
+
+// Reservation accounts the LLM calls of one diagnosis run. Each call reserves its estimated usage
+// before it is sent and settles the actual usage when it returns, so that concurrent runs and the
+// later calls of a run cannot overspend the budget between the check and the accounting.
+// Reservation 为一次诊断运行的 LLM 调用计量。每次调用在发送前预留其估算用量，返回时结算实际用量，
+// 使并发运行和同一运行中的后续调用无法在检查与计量之间超出预算。
+// The methods of a nil Reservation do nothing, so callers outside a diagnosis run need no checks.
+// nil Reservation 的方法不执行任何操作，因此诊断运行以外的调用方无需检查。
+type Reservation struct {
+	meter     *UsageMeter
+	now       time.Time
+	date      string
+	vclusters []string
+	reserved  types.LLMUsage
+	calls     int
+	settled   bool
+}
+
+// Reserve checks the budgets for the day of now and starts the accounting of a run for the vclusters.
+// Reserve 检查 now 所在日期的预算，并为这些 vcluster 开始一次运行的计量。
+func (m *UsageMeter) Reserve(now time.Time, vclusters []string) (*Reservation, error) {
+	if len(vclusters) == 0 {
+		vclusters = []string{""}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	date := now.UTC().Format(dateLayout)
+	if err := m.checkLocked(date, vclusters, types.LLMCall{}); err != nil {
+		return nil, err
+	}
+	return &Reservation{meter: m, now: now, date: date, vclusters: append([]string(nil), vclusters...)}, nil
+}
+
+// Costliest returns the most expensive of the estimates (see UsageMeter.Costliest); without a
+// reservation the first one.
+// Costliest 返回估算用量中最昂贵的一个 (见 UsageMeter.Costliest); 没有预留时返回第一个。
+func (r *Reservation) Costliest(estimates ...types.LLMCall) types.LLMCall {
+	if r == nil {
+		if len(estimates) == 0 {
+			return types.LLMCall{}
+		}
+		return estimates[0]
+	}
+	return r.meter.Costliest(estimates...)
+}
+
+// Acquire reserves the estimated usage of a call, or returns an ErrorCodeBudgetExceeded error if
+// the budgets cannot cover it. Every successful Acquire must be followed by a Release.
+// Acquire 预留一次调用的估算用量; 如果预算无法覆盖，则返回 ErrorCodeBudgetExceeded 错误。
+// 每次成功的 Acquire 之后都必须调用 Release。
+func (r *Reservation) Acquire(estimate types.LLMCall) error {
+	if r == nil {
+		return nil
+	}
+	m := r.meter
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	estimate = m.priced(estimate)
+	if err := m.checkLocked(r.date, r.vclusters, estimate); err != nil {
+		return err
+	}
+	r.reserved.Add(estimate)
+	reserved, ok := m.reserved[r.date]
+	if !ok {
+		reserved = &types.LLMDailyUsage{Date: r.date, VClusters: map[string]types.LLMUsage{}}
+		m.reserved[r.date] = reserved
+	}
+	reserved.Total.Add(estimate)
+	for _, vcluster := range r.vclusters {
+		usage := reserved.VClusters[vcluster]
+		usage.Add(estimate)
+		reserved.VClusters[vcluster] = usage
+	}
+	return nil
+}
+
+// Release returns the estimate of a call reserved with Acquire and records the calls it resulted
+// in, e.g. the failed attempts of a fallback chain and the answering call.
+// Release 归还通过 Acquire 预留的调用估算用量，并记录其产生的调用，例如降级链中失败的尝试和应答的调用。
+func (r *Reservation) Release(estimate types.LLMCall, calls ...types.LLMCall) {
+	if r == nil {
+		return
+	}
+	m := r.meter
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	estimate = m.priced(estimate)
+	var usage types.LLMUsage
+	usage.Add(estimate)
+	m.unreserveLocked(r, usage)
+
+	day := m.dayLocked(r.now)
+	for _, call := range calls {
+		m.recordLocked(day, r.vclusters, m.priced(call))
+	}
+	r.calls += len(calls)
+}
+
+// Settle ends the run. Estimates that were not released are returned, and the run is counted if
+// it made any call.
+// Settle 结束本次运行。未归还的估算用量会被归还，若运行进行过任何调用则计入运行次数。
+func (r *Reservation) Settle() {
+	if r == nil {
+		return
+	}
+	m := r.meter
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r.settled {
+		return
+	}
+	r.settled = true
+	m.unreserveLocked(r, r.reserved)
+	if r.calls > 0 {
+		m.dayLocked(r.now).Runs++
+	}
+}
+
+// unreserveLocked returns reserved usage of the reservation. The caller must hold m.mu.
+// unreserveLocked 归还该预留的预留用量。调用方必须持有 m.mu。
+func (m *UsageMeter) unreserveLocked(r *Reservation, usage types.LLMUsage) {
+	subtractUsage(&r.reserved, usage)
+	if reserved, ok := m.reserved[r.date]; ok {
+		subtractUsage(&reserved.Total, usage)
+		for _, vcluster := range r.vclusters {
+			vclusterUsage := reserved.VClusters[vcluster]
+			subtractUsage(&vclusterUsage, usage)
+			reserved.VClusters[vcluster] = vclusterUsage
+		}
+	}
+}
+
+// priced sets the cost of a call from the configured prices unless it is known.
+// priced 在调用成本未知时根据配置的价格设置其成本。
+func (m *UsageMeter) priced(call types.LLMCall) types.LLMCall {
+	if call.Cost == 0 {
+		call.Cost = m.Cost(call.Provider, call.PromptTokens, call.CompletionTokens)
+	}
+	return call
+}
+
+// reservationKey is the context key of the reservation of a run.
+// reservationKey 是运行预留的上下文键。
+type reservationKey struct{}
+
+// WithReservation returns a context carrying the reservation of a diagnosis run, so that the LLM
+// and embedding calls made on its behalf, e.g. for reranking, are accounted to it.
+// WithReservation 返回携带诊断运行预留的上下文，使代表该运行进行的 LLM 和 embedding 调用 (例如重排序)
+// 计入该运行。
+func WithReservation(ctx context.Context, reservation *Reservation) context.Context {
+	return context.WithValue(ctx, reservationKey{}, reservation)
+}
+
+// ReservationFrom returns the reservation carried by the context, or nil.
+// ReservationFrom 返回上下文携带的预留，没有时返回 nil。
+func ReservationFrom(ctx context.Context) *Reservation {
+	reservation, _ := ctx.Value(reservationKey{}).(*Reservation)
+	return reservation
+}
+
+// addUsage adds the tokens and cost of one usage to another.
+// addUsage 将一个用量的 token 和成本加到另一个用量上。
+func addUsage(u *types.LLMUsage, other types.LLMUsage) {
+	u.Calls += other.Calls
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.Cost += other.Cost
+}
+
+// subtractUsage removes the tokens and cost of one usage from another, never going below zero.
+// subtractUsage 从一个用量中减去另一个用量的 token 和成本，不会低于零。
+func subtractUsage(u *types.LLMUsage, other types.LLMUsage) {
+	u.Calls = nonNegative(u.Calls - other.Calls)
+	u.PromptTokens = nonNegative(u.PromptTokens - other.PromptTokens)
+	u.CompletionTokens = nonNegative(u.CompletionTokens - other.CompletionTokens)
+	u.Cost -= other.Cost
+	if u.Cost < 0 {
+		u.Cost = 0
+	}
+}
+
+// nonNegative returns n, or 0 if n is negative.
+// nonNegative 返回 n; n 为负数时返回 0。
+func nonNegative(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// exceeded describes which limit the usage plus the estimate would exceed, or returns "".
+// exceeded 描述用量加上估算用量将超出的限制，未超出时返回 ""。
+func exceeded(limit types.LLMBudgetLimit, usage, estimate types.LLMUsage) string {
+	if limit.DailyTokens > 0 && (usage.Tokens() >= limit.DailyTokens || usage.Tokens()+estimate.Tokens() > limit.DailyTokens) {
+		if estimate.Tokens() > 0 {
+			return fmt.Sprintf("%d of %d tokens used or reserved today, %d more needed", usage.Tokens(), limit.DailyTokens, estimate.Tokens())
+		}
+		return fmt.Sprintf("%d of %d tokens used today", usage.Tokens(), limit.DailyTokens)
+	}
+	if limit.DailyCost > 0 && (usage.Cost >= limit.DailyCost || usage.Cost+estimate.Cost > limit.DailyCost) {
+		if estimate.Cost > 0 {
+			return fmt.Sprintf("estimated cost %.4f of %.4f used or reserved today, %.4f more needed", usage.Cost, limit.DailyCost, estimate.Cost)
+		}
+		return fmt.Sprintf("estimated cost %.4f of %.4f used today", usage.Cost, limit.DailyCost)
+	}
+	return ""
+}
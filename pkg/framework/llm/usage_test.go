@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+func TestReservationBlocksConcurrentOverspend(t *testing.T) {
+	cfg := types.LLMConfig{Budget: types.LLMBudgetConfig{LLMBudgetLimit: types.LLMBudgetLimit{DailyTokens: 1000}}}
+	meter := NewUsageMeter(cfg)
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	first, err := meter.Reserve(now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := meter.Reserve(now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	estimate := types.LLMCall{Provider: "p", PromptTokens: 400, CompletionTokens: 200}
+	if err := first.Acquire(estimate); err != nil {
+		t.Fatal(err)
+	}
+	// The first run's call is in flight: the second run cannot reserve another 600 tokens.
+	if err := second.Acquire(estimate); !errors.IsErrorCode(err, errors.ErrorCodeBudgetExceeded) {
+		t.Fatalf("expected the budget to be exceeded, got %v", err)
+	}
+
+	// The call used less than estimated; the rest of the reservation is returned.
+	first.Release(estimate, types.LLMCall{Provider: "p", PromptTokens: 300, CompletionTokens: 100})
+	if err := second.Acquire(estimate); err != nil {
+		t.Fatalf("expected the released tokens to be available, got %v", err)
+	}
+	second.Release(estimate, types.LLMCall{Provider: "p", PromptTokens: 500, CompletionTokens: 100})
+	first.Settle()
+	second.Settle()
+
+	day := meter.Day(now)
+	if day.Total.Tokens() != 1000 || day.Runs != 2 || day.Total.Calls != 2 {
+		t.Fatalf("unexpected usage: %+v", day)
+	}
+	if err := meter.CheckBudget(now, nil); !errors.IsErrorCode(err, errors.ErrorCodeBudgetExceeded) {
+		t.Fatalf("expected the budget to be used up, got %v", err)
+	}
+}
+
+func TestReservationSettleReturnsUnreleasedEstimates(t *testing.T) {
+	cfg := types.LLMConfig{Budget: types.LLMBudgetConfig{VClusters: map[string]types.LLMBudgetLimit{"team-a": {DailyTokens: 100}}}}
+	meter := NewUsageMeter(cfg)
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	run, err := meter.Reserve(now, []string{"team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Acquire(types.LLMCall{PromptTokens: 80}); err != nil {
+		t.Fatal(err)
+	}
+	run.Settle()
+	run.Settle()
+
+	next, err := meter.Reserve(now, []string{"team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := next.Acquire(types.LLMCall{PromptTokens: 80}); err != nil {
+		t.Fatalf("expected the settled reservation to be returned, got %v", err)
+	}
+	if day := meter.Day(now); day.Runs != 0 || day.Total.Tokens() != 0 {
+		t.Fatalf("runs without calls must not be counted: %+v", day)
+	}
+
+	var none *Reservation
+	if err := none.Acquire(types.LLMCall{PromptTokens: 1 << 20}); err != nil {
+		t.Fatalf("a nil reservation must not limit calls: %v", err)
+	}
+	none.Release(types.LLMCall{})
+	none.Settle()
+}
+
+func TestCostliestEstimateOfAChain(t *testing.T) {
+	cfg := types.LLMConfig{Providers: map[string]types.LLMProviderConfig{
+		"cheap":  {PromptPrice: 1, CompletionPrice: 2},
+		"costly": {PromptPrice: 10, CompletionPrice: 30},
+	}}
+	meter := NewUsageMeter(cfg)
+	chain := []types.LLMCall{
+		{Provider: "cheap", PromptTokens: 1000, CompletionTokens: 1000},
+		{Provider: "costly", PromptTokens: 800, CompletionTokens: 500},
+		{Provider: "unpriced", PromptTokens: 5000, CompletionTokens: 5000},
+	}
+	if got := meter.Costliest(chain...); got.Provider != "costly" || got.Cost != 0.023 {
+		t.Fatalf("expected the costly provider's estimate, got %+v", got)
+	}
+	if got := meter.Costliest(chain[2], types.LLMCall{Provider: "other", PromptTokens: 10}); got.Provider != "unpriced" {
+		t.Fatalf("expected the largest unpriced estimate, got %+v", got)
+	}
+}
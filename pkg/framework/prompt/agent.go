@@ -0,0 +1,97 @@
+package prompt
+
+import (
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+)
+
+// agentTemplate is the template wrapping a diagnosis prompt with tool-use instructions.
+// agentTemplate 是在诊断提示外附加工具调用说明的模板。
+const agentTemplate = "agent"
+
+// agentResultTemplate is the template of a tool result sent back to the LLM.
+// agentResultTemplate 是回传给 LLM 的工具结果的模板。
+const agentResultTemplate = "agent_result"
+
+// omittedToolResult replaces old tool results that no longer fit into the context window.
+// omittedToolResult 用于替换无法再放入上下文窗口的旧工具结果。
+const omittedToolResult = "[result omitted to fit the context window]"
+
+// AgentData is the data passed to the agent template during a tool-use diagnosis.
+// AgentData 是工具调用诊断过程中传递给 agent 模板的数据。
+type AgentData struct {
+	Language       string         // Language of the rendered prompt / 渲染提示的语言
+	Cluster        ClusterContext // Cluster context of the incident / 事件的集群上下文
+	Diagnosis      string         // Rendered diagnosis prompt (task and evidence) / 渲染后的诊断提示 (任务和证据)
+	Tools          []tool.Tool    // Tools offered to the LLM / 提供给 LLM 的工具
+	Steps          []AgentStep    // Tool calls made so far, replayed as messages after the prompt / 目前为止的工具调用，在提示之后以消息形式回放
+	RemainingSteps int            // Number of tool calls still allowed / 仍允许的工具调用次数
+}
+
+// AgentStep is a tool call and its result as shown to the LLM.
+// AgentStep 是展示给 LLM 的一次工具调用及其结果。
+type AgentStep struct {
+	Index  int    // 1-based step number / 步骤编号 (从 1 开始)
+	Call   string // The call as JSON / JSON 形式的调用
+	Result string // The tool's result or error / 工具的结果或错误
+	// ToolCall is the native tool call of the step; nil when the LLM used the JSON protocol.
+	// ToolCall 是该步骤的原生工具调用; LLM 使用 JSON 协议时为 nil。
+	ToolCall *llm.ToolCall
+}
+
+// FitAgent renders the agent conversation, omitting the oldest tool results until it fits the budget.
+// FitAgent 渲染 agent 对话，并从最早的工具结果开始省略，直至符合预算。
+// The most recent result is always kept, so the LLM can react to what it just asked for. The
+// result's Prompt holds the conversation flattened into a single text.
+// 最近一次的结果总是保留，以便 LLM 能够针对刚请求的内容作出反应。结果的 Prompt 为展平为单个文本的对话。
+func (te *TemplateEngine) FitAgent(data *AgentData, budget Budget) (*FitResult, error) {
+	agent := *data
+	agent.Steps = append([]AgentStep(nil), data.Steps...)
+
+	for omitted := 0; ; omitted++ {
+		messages, err := te.agentMessages(&agent)
+		if err != nil {
+			return nil, err
+		}
+		rendered := llm.FlattenMessages(messages)
+		tokens, ok := budget.fits(rendered)
+		if ok || omitted >= len(agent.Steps)-1 {
+			return &FitResult{Prompt: rendered, Messages: messages, Tokens: tokens, TrimLevel: omitted}, nil
+		}
+		agent.Steps[omitted].Result = omittedToolResult
+	}
+}
+
+// agentMessages renders the agent prompt as a user message followed by one call and one result
+// message per step.
+// agentMessages 将 agent 提示渲染为一条用户消息，其后每个步骤各跟随一条调用消息和一条结果消息。
+// A native call is answered by a tool message carrying its ID, as providers pair them; a call made
+// with the JSON protocol is answered by a user message.
+// 原生调用由携带其 ID 的工具消息应答，因为提供商会将二者配对; 使用 JSON 协议的调用由用户消息应答。
+func (te *TemplateEngine) agentMessages(agent *AgentData) ([]llm.Message, error) {
+	instructions := *agent
+	instructions.Steps = nil
+	rendered, err := te.Render(agent.Cluster.VCluster, agent.Language, []string{agentTemplate}, &instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []llm.Message{{Role: llm.RoleUser, Content: rendered}}
+	for i := range agent.Steps {
+		step := &agent.Steps[i]
+		result, err := te.Render(agent.Cluster.VCluster, agent.Language, []string{agentResultTemplate}, step)
+		if err != nil {
+			return nil, err
+		}
+		if step.ToolCall == nil {
+			messages = append(messages,
+				llm.Message{Role: llm.RoleAssistant, Content: step.Call},
+				llm.Message{Role: llm.RoleUser, Content: result})
+			continue
+		}
+		messages = append(messages,
+			llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{*step.ToolCall}},
+			llm.Message{Role: llm.RoleTool, Name: step.ToolCall.Name, ToolCallID: step.ToolCall.ID, Content: result})
+	}
+	return messages, nil
+}
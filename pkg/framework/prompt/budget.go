@@ -0,0 +1,261 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+// Budget describes how many tokens a rendered prompt may occupy and how to count them.
+// Budget 描述渲染后的提示可占用的 token 数量以及如何计数。
+type Budget struct {
+	MaxTokens int                   // Maximum prompt size in tokens / 提示的最大 token 数
+	Estimate  func(text string) int // Token estimator for the target model / 目标模型的 token 估算器
+}
+
+// fits reports whether a rendered prompt fits into the budget, returning its estimated size.
+// fits 报告渲染后的提示是否在预算内，并返回其估算大小。
+func (b Budget) fits(text string) (int, bool) {
+	if b.Estimate == nil {
+		return 0, true
+	}
+	tokens := b.Estimate(text)
+	return tokens, b.MaxTokens <= 0 || tokens <= b.MaxTokens
+}
+
+// trimLevel limits how much of each evidence item, message and knowledge snippet is kept.
+// trimLevel 限制每个证据项、描述和知识片段保留的长度。
+// A zero limit means "unlimited"; maxKBHits < 0 means "keep all hits".
+// 限制为零表示 "不限制"; maxKBHits < 0 表示 "保留所有命中"。
+type trimLevel struct {
+	maxEvidence  int // Characters kept per evidence value (logs, events, ...) / 每个证据值 (日志, 事件等) 保留的字符数
+	maxMessage   int // Characters kept per issue message / 每个问题描述保留的字符数
+	maxKBContent int // Characters kept per knowledge base snippet / 每个知识库片段保留的字符数
+	maxKBHits    int // Number of knowledge base hits kept / 保留的知识库命中数
+}
+
+// trimLevels are tried in order until the prompt fits.
+// trimLevels 按顺序尝试，直到提示符合预算。
+var trimLevels = []trimLevel{
+	{maxEvidence: 0, maxMessage: 0, maxKBContent: 0, maxKBHits: -1},
+	{maxEvidence: 2000, maxMessage: 1000, maxKBContent: 1500, maxKBHits: -1},
+	{maxEvidence: 800, maxMessage: 500, maxKBContent: 600, maxKBHits: 5},
+	{maxEvidence: 300, maxMessage: 300, maxKBContent: 300, maxKBHits: 3},
+	{maxEvidence: 120, maxMessage: 200, maxKBContent: 0, maxKBHits: 0},
+}
+
+// FitResult is a diagnosis prompt that fits into a budget.
+// FitResult 是符合预算的诊断提示。
+type FitResult struct {
+	Prompt    string         // Rendered prompt / 渲染后的提示
+	Messages  []llm.Message  // Rendered conversation of an agent prompt / agent 提示渲染后的对话
+	Data      *DiagnosisData // Trimmed data the prompt was rendered from / 用于渲染提示的裁剪后数据
+	Tokens    int            // Estimated prompt size / 估算的提示大小
+	TrimLevel int            // Index of the trim level applied (0 = untrimmed) / 应用的裁剪级别 (0 = 未裁剪)
+}
+
+// PrioritizeIssues returns the issues ordered by severity, most severe first.
+// PrioritizeIssues 返回按严重性排序的问题，最严重的在前。
+// Issues of equal severity keep their original order.
+// 严重性相同的问题保持原有顺序。
+func PrioritizeIssues(issues []types.Issue) []types.Issue {
+	sorted := append([]types.Issue(nil), issues...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Severity > sorted[j].Severity
+	})
+	return sorted
+}
+
+// FitDiagnosis renders the diagnosis prompt with the least trimming that fits the budget.
+// FitDiagnosis 以符合预算的最少裁剪渲染诊断提示。
+// Issues are prioritized by severity before rendering. It returns ok=false with the most
+// trimmed rendering if no trim level fits, in which case the caller should split the issues.
+// 渲染前会按严重性对问题排序。如果所有裁剪级别都无法满足预算，则返回裁剪最多的渲染结果且 ok=false，
+// 此时调用方应拆分问题。
+func (te *TemplateEngine) FitDiagnosis(data *DiagnosisData, budget Budget) (*FitResult, bool, error) {
+	prioritized := *data
+	prioritized.Issues = prioritizeIssueData(data.Issues)
+
+	var last *FitResult
+	for i, level := range trimLevels {
+		trimmed := level.apply(&prioritized)
+		rendered, err := te.RenderDiagnosis(trimmed)
+		if err != nil {
+			return nil, false, err
+		}
+		tokens, ok := budget.fits(rendered)
+		last = &FitResult{Prompt: rendered, Data: trimmed, Tokens: tokens, TrimLevel: i}
+		if ok {
+			return last, true, nil
+		}
+	}
+	return last, false, nil
+}
+
+// PartitionDiagnosis splits the issues into groups whose prompts each fit the budget.
+// PartitionDiagnosis 将问题拆分为多个分组，使每个分组的提示都符合预算。
+// Issues are taken in severity order and packed greedily. A single issue that does not
+// fit even when fully trimmed still gets its own group.
+// 问题按严重性顺序贪心打包。即使完全裁剪后仍无法满足预算的单个问题也会单独成组。
+func (te *TemplateEngine) PartitionDiagnosis(data *DiagnosisData, budget Budget) ([]*FitResult, error) {
+	var groups []*FitResult
+	var current []IssueData
+
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		group := *data
+		group.Issues = renumber(current)
+		fit, _, err := te.FitDiagnosis(&group, budget)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, fit)
+		current = nil
+		return nil
+	}
+
+	for _, issue := range prioritizeIssueData(data.Issues) {
+		candidate := *data
+		candidate.Issues = renumber(append(append([]IssueData(nil), current...), issue))
+		_, ok, err := te.FitDiagnosis(&candidate, budget)
+		if err != nil {
+			return nil, err
+		}
+		if !ok && len(current) > 0 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		current = append(current, issue)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "no issues to partition", "")
+	}
+	return groups, nil
+}
+
+// SummaryData is the data passed to the summary template that merges group diagnoses.
+// SummaryData 是传递给汇总模板的数据，用于合并各分组的诊断。
+type SummaryData struct {
+	Language string             // Language of the rendered prompt / 渲染提示的语言
+	Cluster  ClusterContext     // Cluster context of the incident / 事件的集群上下文
+	Issues   []IssueData        // All issues, without evidence / 所有问题 (不含证据)
+	Partials []PartialDiagnosis // Diagnoses of the individual groups / 各分组的诊断
+}
+
+// PartialDiagnosis is the diagnosis of one issue group.
+// PartialDiagnosis 是一个问题分组的诊断。
+type PartialDiagnosis struct {
+	Index      int      // 1-based group number / 分组编号 (从 1 开始)
+	IssueNames []string // Names of the issues in the group / 分组中问题的名称
+	Response   string   // The LLM's diagnosis of the group / LLM 对该分组的诊断
+}
+
+// FitSummary renders the summary prompt, shortening partial diagnoses until it fits the budget.
+// FitSummary 渲染汇总提示，并缩短各分组诊断直至符合预算。
+func (te *TemplateEngine) FitSummary(data *SummaryData, budget Budget) (*FitResult, error) {
+	summary := *data
+	summary.Issues = make([]IssueData, len(data.Issues))
+	for i, issue := range data.Issues {
+		issue.Evidence = nil
+		summary.Issues[i] = issue
+	}
+
+	var rendered string
+	var tokens int
+	for _, limit := range []int{0, 4000, 2000, 1000, 500, 250} {
+		summary.Partials = make([]PartialDiagnosis, len(data.Partials))
+		for i, partial := range data.Partials {
+			partial.Response = TruncateMiddle(partial.Response, limit)
+			summary.Partials[i] = partial
+		}
+		var err error
+		rendered, err = te.Render(summary.Cluster.VCluster, summary.Language, []string{"summary"}, &summary)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if tokens, ok = budget.fits(rendered); ok {
+			break
+		}
+	}
+	return &FitResult{Prompt: rendered, Tokens: tokens}, nil
+}
+
+// apply returns a copy of the data trimmed to the level's limits.
+// apply 返回按级别限制裁剪后的数据副本。
+func (l trimLevel) apply(data *DiagnosisData) *DiagnosisData {
+	trimmed := *data
+	trimmed.Issues = make([]IssueData, len(data.Issues))
+	for i, issue := range data.Issues {
+		issue.Message = TruncateMiddle(issue.Message, l.maxMessage)
+		evidence := make([]Evidence, len(issue.Evidence))
+		for j, item := range issue.Evidence {
+			evidence[j] = Evidence{Key: item.Key, Value: TruncateMiddle(item.Value, l.maxEvidence)}
+		}
+		issue.Evidence = evidence
+		trimmed.Issues[i] = issue
+	}
+
+	hits := data.KnowledgeBaseHits
+	if l.maxKBHits >= 0 && len(hits) > l.maxKBHits {
+		hits = topHits(hits, l.maxKBHits)
+	}
+	trimmed.KnowledgeBaseHits = make([]types.KnowledgeBaseHit, 0, len(hits))
+	for _, hit := range hits {
+		hit.Content = TruncateMiddle(hit.Content, l.maxKBContent)
+		trimmed.KnowledgeBaseHits = append(trimmed.KnowledgeBaseHits, hit)
+	}
+	return &trimmed
+}
+
+// topHits returns the n highest scoring hits, in score order.
+// topHits 返回得分最高的 n 个命中 (按得分排序)。
+func topHits(hits []types.KnowledgeBaseHit, n int) []types.KnowledgeBaseHit {
+	sorted := append([]types.KnowledgeBaseHit(nil), hits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	return sorted[:n]
+}
+
+// prioritizeIssueData orders issue data by severity and renumbers it.
+// prioritizeIssueData 按严重性对问题数据排序并重新编号。
+func prioritizeIssueData(issues []IssueData) []IssueData {
+	sorted := append([]IssueData(nil), issues...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Severity > sorted[j].Severity
+	})
+	return renumber(sorted)
+}
+
+// renumber assigns 1-based indexes in slice order.
+// renumber 按切片顺序分配从 1 开始的序号。
+func renumber(issues []IssueData) []IssueData {
+	out := make([]IssueData, len(issues))
+	for i, issue := range issues {
+		issue.Index = i + 1
+		out[i] = issue
+	}
+	return out
+}
+
+// TruncateMiddle shortens s to at most max runes, keeping the beginning and the end,
+// which is where the most useful log lines usually are. max <= 0 disables truncation.
+// TruncateMiddle 将 s 缩短为最多 max 个字符，保留开头和结尾 (通常最有用的日志行在这两处)。max <= 0 表示不截断。
+func TruncateMiddle(s string, max int) string {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s
+	}
+	marker := fmt.Sprintf(" ...[%d chars truncated]... ", len(runes)-max)
+	head := max * 2 / 3
+	tail := max - head
+	return string(runes[:head]) + marker + string(runes[len(runes)-tail:])
+}
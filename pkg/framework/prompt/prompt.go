@@ -0,0 +1,469 @@
+package prompt
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"go.uber.org/zap"
+)
+
+// Package prompt renders LLM prompts from Go text/template files.
+// 包 prompt 使用 Go text/template 文件渲染 LLM 提示。
+// Templates are selected per issue category and per language, and can be overridden
+// from a directory on disk (globally or per vcluster) without recompiling the agent.
+// 模板按问题类别和语言选择，并且可以通过磁盘目录 (全局或按 vcluster) 覆盖，无需重新编译代理。
+
+// Template categories. A category names the template file ("<category>.tmpl").
+// 模板类别。类别对应模板文件名 ("<category>.tmpl")。
+const (
+	// CategoryDefault is used for mixed or unrecognized issues and as the fallback for every category.
+	// CategoryDefault 用于混合或无法识别的问题，也是所有类别的回退模板。
+	CategoryDefault = "default"
+	// CategoryKubernetes is used when all issues concern Kubernetes resources.
+	// CategoryKubernetes 用于所有问题都涉及 Kubernetes 资源的情况。
+	CategoryKubernetes = "kubernetes"
+	// CategoryBusiness is used when all issues concern business services.
+	// CategoryBusiness 用于所有问题都涉及业务服务的情况。
+	CategoryBusiness = "business"
+)
+
+// Supported languages.
+// 支持的语言。
+const (
+	// LanguageEnglish selects English templates.
+	// LanguageEnglish 选择英文模板。
+	LanguageEnglish = "en"
+	// LanguageChinese selects Chinese templates.
+	// LanguageChinese 选择中文模板。
+	LanguageChinese = "zh"
+)
+
+// partialsTemplate is the file holding shared {{define}} blocks for a language.
+// partialsTemplate 是存放某种语言共享 {{define}} 块的文件。
+const partialsTemplate = "partials"
+
+// builtinTemplates holds the default templates compiled into the agent.
+// builtinTemplates 保存编译进代理的默认模板。
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+// DiagnosisData is the data passed to diagnosis templates.
+// DiagnosisData 是传递给诊断模板的数据。
+type DiagnosisData struct {
+	Language          string                   // Language of the rendered prompt / 渲染提示的语言
+	Category          string                   // Issue category / 问题类别
+	Cluster           ClusterContext           // Cluster context of the incident / 事件的集群上下文
+	Issues            []IssueData              // Issues to diagnose / 需要诊断的问题
+	KnowledgeBaseHits []types.KnowledgeBaseHit // Relevant knowledge base entries / 相关的知识库条目
+}
+
+// ClusterContext describes where the diagnosed issues live.
+// ClusterContext 描述被诊断问题所在的位置。
+type ClusterContext struct {
+	VCluster   string   // The single vcluster all issues belong to, empty if mixed / 所有问题所属的唯一 vcluster，混合时为空
+	VClusters  []string // All vclusters involved / 涉及的所有 vcluster
+	Namespaces []string // All namespaces involved / 涉及的所有命名空间
+}
+
+// IssueData wraps an issue with its position and flattened evidence for templates.
+// IssueData 为模板包装问题及其序号和扁平化的证据。
+type IssueData struct {
+	types.Issue
+	Index    int        // 1-based position in the prompt / 在提示中的位置 (从 1 开始)
+	Evidence []Evidence // Issue context as sorted key/value pairs / 按键排序的问题上下文键值对
+}
+
+// Evidence is a single key/value item of issue context.
+// Evidence 是问题上下文中的单个键值项。
+type Evidence struct {
+	Key   string
+	Value string
+}
+
+// NewDiagnosisData builds template data from issues and knowledge base hits.
+// NewDiagnosisData 根据问题和知识库命中构建模板数据。
+func NewDiagnosisData(language string, issues []types.Issue, kbHits []types.KnowledgeBaseHit) *DiagnosisData {
+	data := &DiagnosisData{
+		Language:          language,
+		Category:          CategoryForIssues(issues),
+		Cluster:           ClusterContextForIssues(issues),
+		KnowledgeBaseHits: kbHits,
+	}
+	for i, issue := range issues {
+		data.Issues = append(data.Issues, IssueData{
+			Issue:    issue,
+			Index:    i + 1,
+			Evidence: flattenEvidence(issue.Context),
+		})
+	}
+	return data
+}
+
+// CategoryForIssue returns the template category of a single issue.
+// CategoryForIssue 返回单个问题的模板类别。
+func CategoryForIssue(issue types.Issue) string {
+	if issue.Resource == nil {
+		return CategoryDefault
+	}
+	switch issue.Resource.Type {
+	case "BusinessService":
+		return CategoryBusiness
+	case "":
+		return CategoryDefault
+	default:
+		return CategoryKubernetes
+	}
+}
+
+// CategoryForIssues returns the shared category of the issues, or CategoryDefault if they differ.
+// CategoryForIssues 返回这些问题共同的类别，如果类别不同则返回 CategoryDefault。
+func CategoryForIssues(issues []types.Issue) string {
+	category := ""
+	for _, issue := range issues {
+		c := CategoryForIssue(issue)
+		if category != "" && c != category {
+			return CategoryDefault
+		}
+		category = c
+	}
+	if category == "" {
+		return CategoryDefault
+	}
+	return category
+}
+
+// ClusterContextForIssues collects the vclusters and namespaces the issues belong to.
+// ClusterContextForIssues 收集问题所属的 vcluster 和命名空间。
+func ClusterContextForIssues(issues []types.Issue) ClusterContext {
+	vclusters := map[string]struct{}{}
+	namespaces := map[string]struct{}{}
+	for _, issue := range issues {
+		if issue.Resource == nil {
+			continue
+		}
+		if issue.Resource.VCluster != "" {
+			vclusters[issue.Resource.VCluster] = struct{}{}
+		}
+		if issue.Resource.Namespace != "" && issue.Resource.Namespace != "N/A" {
+			namespaces[issue.Resource.Namespace] = struct{}{}
+		}
+	}
+	cc := ClusterContext{VClusters: sortedKeys(vclusters), Namespaces: sortedKeys(namespaces)}
+	if len(cc.VClusters) == 1 {
+		cc.VCluster = cc.VClusters[0]
+	}
+	return cc
+}
+
+// TemplateEngine resolves and renders prompt templates.
+// TemplateEngine 解析并渲染提示模板。
+// Lookup order for "<lang>/<name>.tmpl" is: "<dir>/vclusters/<vcluster>/", then "<dir>/",
+// then the built-in templates; within each language the requested name falls back to
+// "default", and a missing language falls back to English.
+// "<lang>/<name>.tmpl" 的查找顺序为: "<dir>/vclusters/<vcluster>/"，然后 "<dir>/"，最后是内置模板;
+// 每种语言中请求的名称会回退到 "default"，缺失的语言回退到英文。
+type TemplateEngine struct {
+	dir               string
+	language          string
+	vclusterLanguages map[string]string
+
+	// parsed caches parsed templates by their resolved source signature, so edited
+	// files on disk are picked up on the next render. It holds at most
+	// constants.MaxParsedPromptTemplates entries.
+	// parsed 按解析出的源文件签名缓存已解析的模板，因此磁盘上被修改的文件会在下次渲染时生效。
+	// 它最多保存 constants.MaxParsedPromptTemplates 个条目。
+	parsed map[string]*template.Template
+	mu     sync.Mutex
+}
+
+// NewTemplateEngine creates a new TemplateEngine from configuration.
+// NewTemplateEngine 根据配置创建一个新的 TemplateEngine。
+func NewTemplateEngine(cfg *types.PromptConfig) *TemplateEngine {
+	te := &TemplateEngine{
+		language:          LanguageEnglish,
+		vclusterLanguages: map[string]string{},
+		parsed:            make(map[string]*template.Template),
+	}
+	if cfg != nil {
+		te.dir = cfg.TemplateDir
+		if cfg.Language != "" {
+			te.language = cfg.Language
+		}
+		for vcluster, lang := range cfg.VClusterLanguages {
+			te.vclusterLanguages[vcluster] = lang
+		}
+	}
+	log.L().Info("Initialized prompt template engine", zap.String("templateDir", te.dir), zap.String("language", te.language))
+	return te
+}
+
+// LanguageFor returns the prompt language configured for a vcluster.
+// LanguageFor 返回为 vcluster 配置的提示语言。
+func (te *TemplateEngine) LanguageFor(vcluster string) string {
+	if lang, ok := te.vclusterLanguages[vcluster]; ok && lang != "" {
+		return lang
+	}
+	return te.language
+}
+
+// RenderDiagnosis renders the diagnosis prompt for the data's category.
+// RenderDiagnosis 按数据的类别渲染诊断提示。
+func (te *TemplateEngine) RenderDiagnosis(data *DiagnosisData) (string, error) {
+	return te.Render(data.Cluster.VCluster, data.Language, []string{data.Category, CategoryDefault}, data)
+}
+
+// Render renders the first template found among names for the vcluster and language.
+// Render 为指定 vcluster 和语言渲染 names 中第一个找到的模板。
+// If a template from disk fails to parse or execute, the built-in template is used instead.
+// 如果磁盘上的模板解析或执行失败，则改用内置模板。
+func (te *TemplateEngine) Render(vcluster, language string, names []string, data interface{}) (string, error) {
+	if language == "" {
+		language = te.LanguageFor(vcluster)
+	}
+
+	out, err := te.render(te.sources(vcluster), language, names, data)
+	if err == nil {
+		return out, nil
+	}
+	if te.dir == "" {
+		return "", err
+	}
+	log.L().Warn("Failed to render prompt template from disk, falling back to built-in templates",
+		zap.String("vcluster", vcluster), zap.String("language", language), zap.Strings("names", names), zap.Error(err))
+	return te.render([]templateSource{builtinSource{}}, language, names, data)
+}
+
+// render resolves, parses (or reuses) and executes a template.
+// render 解析 (或复用) 并执行模板。
+func (te *TemplateEngine) render(sources []templateSource, language string, names []string, data interface{}) (string, error) {
+	languages := []string{language}
+	if language != LanguageEnglish {
+		languages = append(languages, LanguageEnglish)
+	}
+
+	var main, partials *resolvedTemplate
+	for _, lang := range languages {
+		for _, name := range names {
+			if main = resolve(sources, lang, name); main != nil {
+				break
+			}
+		}
+		if main != nil {
+			partials = resolve(sources, lang, partialsTemplate)
+			break
+		}
+	}
+	if main == nil {
+		return "", errors.New(errors.ErrorCodeNotFound, "prompt template not found", fmt.Sprintf("language=%s names=%v", language, names))
+	}
+
+	signature := main.signature
+	if partials != nil {
+		signature = partials.signature + "|" + signature
+	}
+
+	te.mu.Lock()
+	tmpl, cached := te.parsed[signature]
+	te.mu.Unlock()
+
+	if !cached {
+		tmpl = template.New(main.name).Funcs(templateFuncs)
+		var err error
+		if partials != nil {
+			if tmpl, err = tmpl.Parse(partials.content); err != nil {
+				return "", errors.Wrap(errors.ErrorCodeInvalidInput, "failed to parse prompt partials", err, partials.signature)
+			}
+		}
+		if tmpl, err = tmpl.New(main.name).Parse(main.content); err != nil {
+			return "", errors.Wrap(errors.ErrorCodeInvalidInput, "failed to parse prompt template", err, main.signature)
+		}
+		te.mu.Lock()
+		if len(te.parsed) >= constants.MaxParsedPromptTemplates {
+			// Every edit of a file on disk adds a signature; drop an arbitrary entry, it is re-parsed if needed.
+			// 磁盘文件的每次修改都会新增一个签名; 丢弃任意一个条目，需要时会重新解析。
+			for key := range te.parsed {
+				delete(te.parsed, key)
+				break
+			}
+		}
+		te.parsed[signature] = tmpl
+		te.mu.Unlock()
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, main.name, data); err != nil {
+		return "", errors.Wrap(errors.ErrorCodeInvalidInput, "failed to execute prompt template", err, main.signature)
+	}
+	return buf.String(), nil
+}
+
+// sources returns the template sources in lookup order for a vcluster.
+// sources 返回 vcluster 的模板来源 (按查找顺序)。
+// A vcluster name that is not a single path element has no override directory.
+// 不是单个路径元素的 vcluster 名称没有覆盖目录。
+func (te *TemplateEngine) sources(vcluster string) []templateSource {
+	var sources []templateSource
+	if te.dir != "" {
+		if vcluster != "" {
+			if isPathElement(vcluster) {
+				sources = append(sources, dirSource(filepath.Join(te.dir, "vclusters", vcluster)))
+			} else {
+				log.L().Warn("Ignoring prompt template overrides for vcluster with an unsafe name", zap.String("vcluster", vcluster))
+			}
+		}
+		sources = append(sources, dirSource(te.dir))
+	}
+	return append(sources, builtinSource{})
+}
+
+// resolvedTemplate is a template file found in one of the sources.
+// resolvedTemplate 是在某个来源中找到的模板文件。
+type resolvedTemplate struct {
+	name      string
+	content   string
+	signature string // Identifies the exact file version / 标识确切的文件版本
+}
+
+// templateSource is a place templates can be loaded from.
+// templateSource 是可以加载模板的位置。
+type templateSource interface {
+	load(language, name string) *resolvedTemplate
+}
+
+// resolve returns the first template found for language/name among sources.
+// resolve 返回在来源中为 language/name 找到的第一个模板。
+func resolve(sources []templateSource, language, name string) *resolvedTemplate {
+	for _, src := range sources {
+		if rt := src.load(language, name); rt != nil {
+			return rt
+		}
+	}
+	return nil
+}
+
+// dirSource loads templates from a directory on disk.
+// dirSource 从磁盘目录加载模板。
+type dirSource string
+
+func (d dirSource) load(language, name string) *resolvedTemplate {
+	if !isPathElement(language) || !isPathElement(name) {
+		return nil
+	}
+	path := filepath.Join(string(d), language, name+".tmpl")
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.L().Warn("Failed to read prompt template", zap.String("path", path), zap.Error(err))
+		return nil
+	}
+	return &resolvedTemplate{
+		name:      name,
+		content:   string(content),
+		signature: fmt.Sprintf("%s@%d", path, info.ModTime().UnixNano()),
+	}
+}
+
+// isPathElement reports whether a name is a single path element that cannot escape the directory
+// it is joined to: it is not empty or ".", and contains neither ".." nor a path separator.
+// isPathElement 报告名称是否为无法逃出其所连接目录的单个路径元素: 不为空或 "."，且不含 ".." 和路径分隔符。
+func isPathElement(name string) bool {
+	return name != "" && name != "." && !strings.Contains(name, "..") && !strings.ContainsAny(name, `/\`)
+}
+
+// builtinSource loads templates embedded in the binary.
+// builtinSource 加载嵌入在二进制文件中的模板。
+type builtinSource struct{}
+
+func (builtinSource) load(language, name string) *resolvedTemplate {
+	path := "templates/" + language + "/" + name + ".tmpl"
+	content, err := fs.ReadFile(builtinTemplates, path)
+	if err != nil {
+		return nil
+	}
+	return &resolvedTemplate{name: name, content: string(content), signature: "builtin:" + path}
+}
+
+// templateFuncs are the helper functions available in prompt templates.
+// templateFuncs 是提示模板中可用的辅助函数。
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	// untrusted delimits and escapes content that may be controlled by an attacker.
+	// untrusted 对可能被攻击者控制的内容进行分隔和转义。
+	"untrusted": Untrusted,
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	"json": func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	},
+	"timestamp": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+}
+
+// flattenEvidence converts issue context into sorted key/value pairs.
+// flattenEvidence 将问题上下文转换为按键排序的键值对。
+func flattenEvidence(context map[string]interface{}) []Evidence {
+	keys := make([]string, 0, len(context))
+	for key := range context {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	evidence := make([]Evidence, 0, len(keys))
+	for _, key := range keys {
+		var value string
+		switch v := context[key].(type) {
+		case string:
+			value = v
+		case fmt.Stringer:
+			value = v.String()
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				value = fmt.Sprintf("%v", v)
+			} else {
+				value = string(b)
+			}
+		}
+		evidence = append(evidence, Evidence{Key: key, Value: value})
+	}
+	return evidence
+}
+
+// sortedKeys returns the keys of a set in sorted order.
+// sortedKeys 返回集合中按排序顺序排列的键。
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
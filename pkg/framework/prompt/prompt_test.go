@@ -0,0 +1,70 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+func writeTemplate(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderIgnoresVClusterOutsideTemplateDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "templates")
+	writeTemplate(t, filepath.Join(dir, "en", "default.tmpl"), "global")
+	writeTemplate(t, filepath.Join(dir, "vclusters", "team-a", "en", "default.tmpl"), "team-a")
+	// A template outside the template directory that "../../" would reach from "vclusters/".
+	writeTemplate(t, filepath.Join(root, "en", "default.tmpl"), "escaped")
+
+	te := NewTemplateEngine(&types.PromptConfig{TemplateDir: dir})
+	for vcluster, want := range map[string]string{
+		"team-a":     "team-a",
+		"../..":      "global",
+		"../../":     "global",
+		"a/../../..": "global",
+		`..\..`:      "global",
+	} {
+		got, err := te.Render(vcluster, LanguageEnglish, []string{CategoryDefault}, nil)
+		if err != nil {
+			t.Fatalf("vcluster %q: %v", vcluster, err)
+		}
+		if got != want {
+			t.Errorf("vcluster %q rendered %q, want %q", vcluster, got, want)
+		}
+	}
+
+	if _, err := te.Render("", "../..", []string{CategoryDefault}, nil); err != nil {
+		t.Fatalf("unsafe language: %v", err)
+	}
+}
+
+func TestParsedTemplateCacheIsBounded(t *testing.T) {
+	dir := t.TempDir()
+	te := NewTemplateEngine(&types.PromptConfig{TemplateDir: dir})
+	for i := 0; i < constants.MaxParsedPromptTemplates+10; i++ {
+		name := fmt.Sprintf("t%d", i)
+		writeTemplate(t, filepath.Join(dir, "en", name+".tmpl"), name)
+		got, err := te.Render("", LanguageEnglish, []string{name}, nil)
+		if err != nil || !strings.HasPrefix(got, "t") {
+			t.Fatalf("render %s: %q, %v", name, got, err)
+		}
+	}
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	if len(te.parsed) > constants.MaxParsedPromptTemplates {
+		t.Fatalf("cache holds %d templates, want at most %d", len(te.parsed), constants.MaxParsedPromptTemplates)
+	}
+}
@@ -0,0 +1,138 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// Untrusted content (log lines, event messages, business data, knowledge snippets and tool results)
+// is wrapped in <untrusted> tags by the templates. The templates tell the LLM never to follow
+// instructions inside these tags, and the content is escaped so that it cannot close the tag itself.
+// 不可信内容 (日志行、事件消息、业务数据、知识片段和工具结果) 会被模板包裹在 <untrusted> 标签中。
+// 模板告知 LLM 绝不执行这些标签内的指令，并且内容会被转义，使其无法自行闭合标签。
+
+// Delimiters of untrusted content.
+// 不可信内容的分隔符。
+const (
+	untrustedOpen  = "<untrusted>"
+	untrustedClose = "</untrusted>"
+)
+
+// untrustedTag matches anything that could be read as an untrusted tag, including variants with
+// spaces or different case.
+// untrustedTag 匹配可能被解读为 untrusted 标签的任何内容，包括带空格或大小写不同的变体。
+var untrustedTag = regexp.MustCompile(`(?i)<\s*(/?)\s*untrusted\b`)
+
+// EscapeUntrusted neutralizes untrusted tags and control characters in the text.
+// EscapeUntrusted 消除文本中的 untrusted 标签和控制字符。
+func EscapeUntrusted(text string) string {
+	text = untrustedTag.ReplaceAllString(text, "&lt;${1}untrusted")
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// Untrusted wraps escaped text in untrusted tags. It is available in templates as "untrusted".
+// Untrusted 将转义后的文本包裹在 untrusted 标签中。在模板中以 "untrusted" 提供。
+func Untrusted(text string) string {
+	return untrustedOpen + EscapeUntrusted(text) + untrustedClose
+}
+
+// Names of the injection patterns reported by DetectInjection.
+// DetectInjection 报告的注入模式名称。
+const (
+	InjectionIgnoreInstructions = "ignore-instructions"
+	InjectionRoleOverride       = "role-override"
+	InjectionChatMarkup         = "chat-markup"
+	InjectionDelimiter          = "delimiter-escape"
+	InjectionForgedAnswer       = "forged-answer"
+	InjectionForgedToolCall     = "forged-tool-call"
+	InjectionExfiltration       = "exfiltration"
+)
+
+// injectionPattern is a named regular expression for text that tries to instruct the LLM.
+// injectionPattern 是针对试图向 LLM 下达指令的文本的命名正则表达式。
+type injectionPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// injectionPatterns are checked against every piece of untrusted content.
+// injectionPatterns 用于检查每一段不可信内容。
+var injectionPatterns = []injectionPattern{
+	{InjectionIgnoreInstructions, regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget|override)\b[^.\n]{0,40}\b(?:previous|prior|above|earlier|all|any|system|your|the)\b[^.\n]{0,20}\b(?:instructions?|prompts?|rules|directions|guidelines)\b|忽略(?:之前|以上|上述|所有)[^。\n]{0,10}(?:指令|提示|规则)`)},
+	{InjectionRoleOverride, regexp.MustCompile(`(?i)\byou are now\b|\bfrom now on,? you\b|\bnew (?:system )?instructions?\s*:|\bact as (?:an? )?(?:system|admin|administrator|root|developer)\b|你现在是`)},
+	{InjectionChatMarkup, regexp.MustCompile(`(?im)^\s*(?:system|assistant)\s*:|<\|im_(?:start|end)\|>|\[/?INST\]|<</?SYS>>`)},
+	{InjectionDelimiter, untrustedTag},
+	{InjectionForgedAnswer, regexp.MustCompile(`(?im)^\W*(?:root cause|suggestions)\s*:`)},
+	{InjectionForgedToolCall, regexp.MustCompile(`\{\s*"tool"\s*:\s*"`)},
+	{InjectionExfiltration, regexp.MustCompile(`(?i)\b(?:reveal|print|show|output|repeat|send)\b[^.\n]{0,30}\b(?:system prompt|your instructions|secrets?|api[ _-]?keys?|credentials|passwords?)\b`)},
+}
+
+// DetectInjection returns the names of the injection patterns found in the text.
+// DetectInjection 返回在文本中发现的注入模式名称。
+func DetectInjection(text string) []string {
+	var found []string
+	for _, p := range injectionPatterns {
+		if p.re.MatchString(text) {
+			found = append(found, p.name)
+		}
+	}
+	return found
+}
+
+// ScanText reports injection attempts in a piece of untrusted content from the named source.
+// ScanText 报告来自指定来源的一段不可信内容中的注入尝试。
+func ScanText(source, text string) []types.InjectionFinding {
+	var findings []types.InjectionFinding
+	for _, p := range injectionPatterns {
+		if loc := p.re.FindStringIndex(text); loc != nil {
+			findings = append(findings, types.InjectionFinding{Source: source, Pattern: p.name, Excerpt: excerpt(text, loc[0], loc[1])})
+		}
+	}
+	return findings
+}
+
+// ScanDiagnosisData reports injection attempts in the untrusted parts of diagnosis data: issue
+// messages, evidence and knowledge base snippets.
+// ScanDiagnosisData 报告诊断数据中不可信部分的注入尝试: 问题消息、证据和知识库片段。
+func ScanDiagnosisData(data *DiagnosisData) []types.InjectionFinding {
+	var findings []types.InjectionFinding
+	for _, issue := range data.Issues {
+		findings = append(findings, ScanText(fmt.Sprintf("issue %s message", issue.ID), issue.Message)...)
+		for _, e := range issue.Evidence {
+			findings = append(findings, ScanText(fmt.Sprintf("issue %s evidence %s", issue.ID, e.Key), e.Value)...)
+		}
+	}
+	for _, hit := range data.KnowledgeBaseHits {
+		findings = append(findings, ScanText(fmt.Sprintf("knowledge %s", hit.Source), hit.Content)...)
+	}
+	return findings
+}
+
+// excerptContext is the number of characters shown around a match.
+// excerptContext 是匹配项前后显示的字符数。
+const excerptContext = 40
+
+// excerpt returns the match with some surrounding text, on a single line.
+// excerpt 返回匹配项及其周围的部分文本 (单行)。
+func excerpt(text string, start, end int) string {
+	from, to := start-excerptContext, end+excerptContext
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+	out := EscapeUntrusted(strings.ToValidUTF8(text[from:to], ""))
+	return strings.Join(strings.Fields(out), " ")
+}
@@ -0,0 +1,58 @@
+package redact
+
+import (
+	"regexp"
+)
+
+// Names of the built-in detectors, usable in the detectors list of the configuration.
+// 内置检测器名称，可用于配置中的 detectors 列表。
+const (
+	DetectorPrivateKey    = "private-key"
+	DetectorJWT           = "jwt"
+	DetectorURLCredential = "url-credential"
+	DetectorBearerToken   = "bearer-token"
+	DetectorAWSKey        = "aws-access-key"
+	DetectorGitHubToken   = "github-token"
+	DetectorSlackToken    = "slack-token"
+	DetectorEnvSecret     = "env-secret"
+	DetectorKeyValue      = "key-value-secret"
+	DetectorEmail         = "email"
+	DetectorIPv4          = "ipv4"
+)
+
+// secretKeyPattern matches names of keys, variables and fields that usually hold secrets.
+// secretKeyPattern 匹配通常保存机密的键、变量和字段名称。
+const secretKeyPattern = `[\w.-]*(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credential)s?[\w.-]*`
+
+// secretKey matches a whole field name that usually holds a secret.
+// secretKey 匹配通常保存机密的完整字段名。
+var secretKey = regexp.MustCompile(`(?i)^` + secretKeyPattern + `$`)
+
+// detector replaces a capture group (or the whole match if group is 0) of a regular expression.
+// detector 替换正则表达式的某个捕获组 (group 为 0 时替换整个匹配)。
+type detector struct {
+	name  string
+	kind  string // Placeholder kind / 占位符类型
+	re    *regexp.Regexp
+	group int
+	// skipNumeric leaves purely numeric values alone, e.g. "max_tokens=1024".
+	// skipNumeric 不处理纯数字的值，例如 "max_tokens=1024"。
+	skipNumeric bool
+}
+
+// builtinDetectors are applied in order; more specific formats come before generic ones so that
+// a JWT inside "token=..." is reported as a JWT.
+// builtinDetectors 按顺序应用; 更具体的格式排在通用格式之前，使 "token=..." 中的 JWT 被识别为 JWT。
+var builtinDetectors = []detector{
+	{name: DetectorPrivateKey, kind: "PRIVATE_KEY", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{name: DetectorJWT, kind: "JWT", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`)},
+	{name: DetectorURLCredential, kind: "PASSWORD", re: regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`), group: 1},
+	{name: DetectorBearerToken, kind: "TOKEN", re: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/=-]{16,})`), group: 1},
+	{name: DetectorAWSKey, kind: "AWS_KEY", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: DetectorGitHubToken, kind: "TOKEN", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{name: DetectorSlackToken, kind: "TOKEN", re: regexp.MustCompile(`\bxox[baprs]-[A-Za-z0-9-]{10,}`)},
+	{name: DetectorEnvSecret, kind: "SECRET", re: regexp.MustCompile(`(?i)name:\s*["']?` + secretKeyPattern + `["']?\s*\n\s*value:\s*["']?([^\s"']+)`), group: 1, skipNumeric: true},
+	{name: DetectorKeyValue, kind: "SECRET", re: regexp.MustCompile(`(?i)\b` + secretKeyPattern + `["']?\s*[:=]\s*["']?([^\s"',;}&]+)`), group: 1, skipNumeric: true},
+	{name: DetectorEmail, kind: "EMAIL", re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{name: DetectorIPv4, kind: "IP", re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)},
+}
@@ -0,0 +1,601 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// Package redact replaces secrets and PII in evidence with reversible placeholders before the
+// evidence is sent to an LLM or a knowledge base.
+// Package redact 在证据发送给 LLM 或知识库之前，用可还原的占位符替换其中的机密和个人信息。
+
+// placeholderPrefix starts every placeholder, e.g. "[REDACTED:JWT:1]".
+// placeholderPrefix 是每个占位符的开头，例如 "[REDACTED:JWT:1]"。
+const placeholderPrefix = "[REDACTED:"
+
+// placeholder matches the placeholders produced by a session.
+// placeholder 匹配会话生成的占位符。
+var placeholder = regexp.MustCompile(`\[REDACTED:[A-Z0-9_]+:\d+\]`)
+
+// placeholderParts splits a placeholder into its kind and number.
+// placeholderParts 将占位符拆分为其类型和编号。
+var placeholderParts = regexp.MustCompile(`^\[REDACTED:([A-Z0-9_]+):(\d+)\]$`)
+
+// Redactor holds the compiled detectors and denylist of a redaction configuration.
+// Redactor 保存脱敏配置中编译好的检测器和拒绝列表。
+type Redactor struct {
+	detectors  []detector
+	denyFields []string
+}
+
+// New creates a redactor from the configuration. It returns nil if redaction is disabled.
+// New 根据配置创建脱敏器。如果脱敏被禁用则返回 nil。
+// A nil *Redactor is valid: its sessions pass everything through unchanged.
+// nil *Redactor 是有效的: 其会话会原样传递所有内容。
+func New(cfg *types.RedactionConfig) (*Redactor, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	r := &Redactor{}
+	if len(cfg.Detectors) == 0 {
+		r.detectors = append(r.detectors, builtinDetectors...)
+	} else {
+		enabled := make(map[string]bool, len(cfg.Detectors))
+		for _, name := range cfg.Detectors {
+			enabled[name] = true
+		}
+		for _, d := range builtinDetectors {
+			if enabled[d.name] {
+				r.detectors = append(r.detectors, d)
+				delete(enabled, d.name)
+			}
+		}
+		for name := range enabled {
+			return nil, errors.New(errors.ErrorCodeInvalidInput, "unknown redaction detector", name)
+		}
+	}
+
+	// Custom patterns are usually more specific than the generic detectors, so they run first.
+	// 自定义模式通常比通用检测器更具体，因此先执行。
+	custom := make([]detector, 0, len(cfg.Patterns))
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrorCodeInvalidInput, fmt.Sprintf("invalid redaction pattern %q", p.Name), err, p.Regex)
+		}
+		d := detector{name: p.Name, kind: kindOf(p.Name), re: re}
+		if re.NumSubexp() > 0 {
+			d.group = 1
+		}
+		custom = append(custom, d)
+	}
+	r.detectors = append(custom, r.detectors...)
+
+	for _, field := range cfg.DenyFields {
+		if _, err := path.Match(fieldPath(field), ""); err != nil {
+			return nil, errors.Wrap(errors.ErrorCodeInvalidInput, "invalid redaction field path", err, field)
+		}
+		r.denyFields = append(r.denyFields, fieldPath(field))
+	}
+	return r, nil
+}
+
+// NewSession starts a redaction session. Within a session the same value always maps to the same
+// placeholder, so that the LLM can still correlate evidence.
+// NewSession 开始一个脱敏会话。在同一会话中相同的值总是映射到相同的占位符，使 LLM 仍能关联证据。
+func (r *Redactor) NewSession() *Session {
+	return &Session{redactor: r, placeholders: map[string]string{}, values: map[string]string{}, counts: map[string]int{}}
+}
+
+// ResumeSession starts a session that knows the placeholders of an earlier one (see Session.Values),
+// e.g. to redact what a diagnosis run kept in clear text with the placeholders its root cause and
+// suggestions already use.
+// ResumeSession 开始一个知道先前会话占位符的会话 (参见 Session.Values)，例如用诊断运行的根因和建议中已使用的
+// 占位符，对该运行以明文保留的内容进行脱敏。
+func (r *Redactor) ResumeSession(values map[string]string) *Session {
+	s := r.NewSession()
+	for p, v := range values {
+		m := placeholderParts.FindStringSubmatch(p)
+		if m == nil {
+			continue
+		}
+		s.placeholders[v] = p
+		s.values[p] = v
+		if n, _ := strconv.Atoi(m[2]); n > s.counts[m[1]] {
+			s.counts[m[1]] = n
+		}
+	}
+	return s
+}
+
+// sessionKey is the context key of the redaction session of a diagnosis run.
+// sessionKey 是诊断运行的脱敏会话的上下文键。
+type sessionKey struct{}
+
+// WithSession returns a context carrying the session, so that components reached through the
+// context (e.g. the knowledge base reranker) redact with the placeholders of the run.
+// WithSession 返回携带该会话的上下文，使通过上下文调用的组件 (例如知识库重排序器) 使用本次运行的占位符进行脱敏。
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFrom returns the session carried by the context, or nil.
+// SessionFrom 返回上下文携带的会话; 没有时返回 nil。
+func SessionFrom(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// Session redacts the evidence of a single diagnosis run and remembers the original values.
+// Session 对单次诊断运行的证据进行脱敏，并记住原始值。
+type Session struct {
+	redactor *Redactor
+
+	mu           sync.Mutex
+	placeholders map[string]string // Original value -> placeholder / 原始值 -> 占位符
+	values       map[string]string // Placeholder -> original value / 占位符 -> 原始值
+	counts       map[string]int    // Placeholders issued per kind / 每种类型已发出的占位符数量
+}
+
+// RedactString replaces every detected secret in the text with a placeholder.
+// RedactString 将文本中检测到的每个机密替换为占位符。
+func (s *Session) RedactString(text string) string {
+	if s == nil || s.redactor == nil || text == "" {
+		return text
+	}
+	for _, d := range s.redactor.detectors {
+		text = s.apply(d, text)
+	}
+	return text
+}
+
+// apply replaces the matches of one detector.
+// apply 替换单个检测器的匹配项。
+func (s *Session) apply(d detector, text string) string {
+	matches := d.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	// Matches that start inside an earlier placeholder (e.g. the "PASSWORD:1]" of
+	// "[REDACTED:PASSWORD:1]") or whose value is a placeholder are left alone.
+	// 起始于先前占位符内部的匹配 (例如 "[REDACTED:PASSWORD:1]" 中的 "PASSWORD:1]") 或值本身为占位符的匹配保持不变。
+	redacted := placeholder.FindAllStringIndex(text, -1)
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*d.group], m[2*d.group+1]
+		if start < 0 || start < last || end == start || within(redacted, m[0]) {
+			continue
+		}
+		value := text[start:end]
+		if strings.HasPrefix(value, placeholderPrefix) || placeholder.MatchString(value) || (d.skipNumeric && numeric(value)) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(s.placeholderFor(d.kind, value))
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// within reports whether pos lies inside one of the spans.
+// within 报告 pos 是否位于其中某个区间内。
+func within(spans [][]int, pos int) bool {
+	for _, span := range spans {
+		if pos > span[0] && pos < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// numeric reports whether the value consists of digits only.
+// numeric 报告该值是否仅由数字组成。
+func numeric(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+// placeholderFor returns the placeholder of a value, issuing a new one if necessary.
+// placeholderFor 返回某个值的占位符，必要时发出一个新的占位符。
+func (s *Session) placeholderFor(kind, value string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.placeholders[value]; ok {
+		return p
+	}
+	s.counts[kind]++
+	p := fmt.Sprintf("%s%s:%d]", placeholderPrefix, kind, s.counts[kind])
+	s.placeholders[value] = p
+	s.values[p] = value
+	return p
+}
+
+// RedactValue redacts a structured value (as decoded from JSON or YAML) found at the dotted field path.
+// RedactValue 对位于点分字段路径处的结构化值 (从 JSON 或 YAML 解码而来) 进行脱敏。
+// Besides the detectors it handles fields on the denylist, the data of Kubernetes Secrets and
+// environment variables whose name suggests a secret. The value is copied, never modified in place.
+// 除检测器外，它还处理拒绝列表中的字段、Kubernetes Secret 的数据以及名称表明为机密的环境变量。
+// 值会被复制，不会被原地修改。
+func (s *Session) RedactValue(fieldPath string, value interface{}) interface{} {
+	if s == nil || s.redactor == nil || value == nil {
+		return value
+	}
+	if s.denied(fieldPath) {
+		return s.redactWhole("FIELD", value)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return s.RedactString(v)
+	case []byte:
+		return s.RedactString(string(v))
+	case map[string]interface{}:
+		if isSecretObject(v) {
+			return s.redactSecretObject(fieldPath, v)
+		}
+		out := make(map[string]interface{}, len(v))
+		for _, key := range sortedKeys(v) {
+			out[key] = s.RedactValue(join(fieldPath, key), v[key])
+		}
+		if name, ok := v["name"].(string); ok && secretKey.MatchString(name) {
+			if val, ok := v["value"]; ok && val != nil {
+				out["value"] = s.redactWhole("SECRET", val)
+			}
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = item
+		}
+		return s.RedactValue(fieldPath, out)
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[fmt.Sprint(key)] = item
+		}
+		return s.RedactValue(fieldPath, out)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = s.RedactValue(fieldPath, item)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, item := range v {
+			out[i] = s.RedactString(item)
+		}
+		return out
+	case fmt.Stringer:
+		return s.RedactString(v.String())
+	default:
+		return value
+	}
+}
+
+// redactSecretObject redacts every value of the data and stringData fields of a Kubernetes Secret.
+// redactSecretObject 脱敏 Kubernetes Secret 的 data 和 stringData 字段中的每个值。
+func (s *Session) redactSecretObject(fieldPath string, obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for _, key := range sortedKeys(obj) {
+		item := obj[key]
+		switch key {
+		case "data", "stringData":
+			if data, ok := toStringMap(item); ok {
+				redacted := make(map[string]interface{}, len(data))
+				for _, k := range sortedKeys(data) {
+					redacted[k] = s.redactWhole("SECRET", data[k])
+				}
+				out[key] = redacted
+				continue
+			}
+			out[key] = s.redactWhole("SECRET", item)
+		default:
+			out[key] = s.RedactValue(join(fieldPath, key), item)
+		}
+	}
+	return out
+}
+
+// sortedKeys returns the keys of a map in order, so that placeholders are numbered the same way on every run.
+// sortedKeys 按顺序返回映射的键，使每次运行时占位符的编号保持一致。
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// redactWhole replaces a value entirely with a placeholder of the given kind.
+// redactWhole 用给定类型的占位符完整替换一个值。
+func (s *Session) redactWhole(kind string, value interface{}) interface{} {
+	text := fmt.Sprint(value)
+	if text == "" || placeholder.MatchString(text) {
+		return value
+	}
+	return s.placeholderFor(kind, text)
+}
+
+// denied reports whether the field path is on the denylist.
+// denied 报告字段路径是否在拒绝列表中。
+func (s *Session) denied(fieldPath string) bool {
+	if fieldPath == "" {
+		return false
+	}
+	p := strings.ToLower(strings.ReplaceAll(fieldPath, ".", "/"))
+	for _, pattern := range s.redactor.denyFields {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactIssue returns a copy of the issue with its message and context redacted. The resource
+// identity is kept so that findings can still be attributed.
+// RedactIssue 返回消息和上下文已脱敏的问题副本。资源标识被保留，使发现仍可归属。
+func (s *Session) RedactIssue(issue types.Issue) types.Issue {
+	if s == nil || s.redactor == nil {
+		return issue
+	}
+	if s.denied("message") {
+		issue.Message = fmt.Sprint(s.redactWhole("FIELD", issue.Message))
+	} else {
+		issue.Message = s.RedactString(issue.Message)
+	}
+	if issue.Context != nil {
+		redacted := make(map[string]interface{}, len(issue.Context))
+		for _, key := range sortedKeys(issue.Context) {
+			redacted[key] = s.RedactValue(join("context", key), issue.Context[key])
+		}
+		issue.Context = redacted
+	}
+	return issue
+}
+
+// RedactIssues returns redacted copies of the issues.
+// RedactIssues 返回问题的脱敏副本。
+func (s *Session) RedactIssues(issues []types.Issue) []types.Issue {
+	if s == nil || s.redactor == nil {
+		return issues
+	}
+	out := make([]types.Issue, len(issues))
+	for i, issue := range issues {
+		out[i] = s.RedactIssue(issue)
+	}
+	return out
+}
+
+// RedactDocument returns a copy of a knowledge base document with its content and metadata
+// redacted. The ID and source are kept so that stored documents can still be replaced and deleted.
+// RedactDocument 返回内容和元数据已脱敏的知识库文档副本。ID 和来源被保留，使已存储的文档仍可被替换和删除。
+func (s *Session) RedactDocument(doc types.KnowledgeDocument) types.KnowledgeDocument {
+	if s == nil || s.redactor == nil {
+		return doc
+	}
+	doc.Content = s.RedactString(doc.Content)
+	if doc.Metadata != nil {
+		redacted := make(map[string]string, len(doc.Metadata))
+		for key, value := range doc.Metadata {
+			redacted[key] = s.RedactString(value)
+		}
+		doc.Metadata = redacted
+	}
+	return doc
+}
+
+// Count returns the number of distinct values redacted in this session.
+// Count 返回本会话中被脱敏的不同值的数量。
+func (s *Session) Count() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.values)
+}
+
+// Values returns a copy of the placeholder to original value mapping. It must stay local and never
+// be sent to an LLM, a knowledge base or a log.
+// Values 返回占位符到原始值映射的副本。它必须保留在本地，绝不能发送给 LLM、知识库或日志。
+func (s *Session) Values() map[string]string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.values) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(s.values))
+	for p, v := range s.values {
+		out[p] = v
+	}
+	return out
+}
+
+// Kinds returns the sorted placeholder kinds issued in this session, for logging.
+// Kinds 返回本会话中已发出的占位符类型 (已排序)，用于日志记录。
+func (s *Session) Kinds() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kinds := make([]string, 0, len(s.counts))
+	for kind := range s.counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Rehydrate replaces the placeholders in the text with their original values, e.g. to turn a
+// suggested command into one that can be run locally. Unknown placeholders are left as they are.
+// Rehydrate 将文本中的占位符替换为原始值，例如将建议的命令还原为可在本地执行的命令。未知的占位符保持不变。
+func Rehydrate(text string, values map[string]string) string {
+	if len(values) == 0 || !strings.Contains(text, placeholderPrefix) {
+		return text
+	}
+	return placeholder.ReplaceAllStringFunc(text, func(p string) string {
+		if v, ok := values[p]; ok {
+			return v
+		}
+		return p
+	})
+}
+
+// RehydrateSuggestion returns a copy of a remediation suggestion with the placeholders in its
+// description, command and payload replaced by their original values, so that the operator sees and
+// runs the real command. The result must stay local, like the values.
+// RehydrateSuggestion 返回处置建议的副本，其描述、命令和载荷中的占位符已替换为原始值，使运维人员看到并执行真实的命令。
+// 与原始值一样，结果必须保留在本地。
+func RehydrateSuggestion(suggestion types.RemediationSuggestion, values map[string]string) types.RemediationSuggestion {
+	if len(values) == 0 {
+		return suggestion
+	}
+	suggestion.Description = Rehydrate(suggestion.Description, values)
+	suggestion.Command = Rehydrate(suggestion.Command, values)
+	if suggestion.Payload != nil {
+		payload := make(map[string]interface{}, len(suggestion.Payload))
+		for key, value := range suggestion.Payload {
+			payload[key] = rehydrateValue(value, values)
+		}
+		suggestion.Payload = payload
+	}
+	return suggestion
+}
+
+// rehydrateValue rehydrates the strings within a decoded value.
+// rehydrateValue 还原已解码值中的字符串。
+func rehydrateValue(value interface{}, values map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return Rehydrate(v, values)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = rehydrateValue(item, values)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = rehydrateValue(item, values)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(v))
+		for key, item := range v {
+			out[key] = Rehydrate(item, values)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, item := range v {
+			out[i] = Rehydrate(item, values)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// StripPlaceholders removes the placeholders from the text, e.g. before it is used as a search query
+// in which a placeholder would only add noise.
+// StripPlaceholders 从文本中删除占位符，例如在将其用作搜索查询之前，因为占位符只会增加噪声。
+func StripPlaceholders(text string) string {
+	if !strings.Contains(text, placeholderPrefix) {
+		return text
+	}
+	return placeholder.ReplaceAllString(text, " ")
+}
+
+// isSecretObject reports whether the map looks like a Kubernetes Secret.
+// isSecretObject 报告该 map 是否看起来像 Kubernetes Secret。
+func isSecretObject(obj map[string]interface{}) bool {
+	kind, _ := obj["kind"].(string)
+	if kind != "Secret" {
+		return false
+	}
+	_, hasData := obj["data"]
+	_, hasStringData := obj["stringData"]
+	return hasData || hasStringData
+}
+
+// toStringMap converts the map types produced by JSON and YAML decoding.
+// toStringMap 转换 JSON 和 YAML 解码产生的 map 类型。
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[string]string:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = item
+		}
+		return out, true
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[fmt.Sprint(key)] = item
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// join appends a key to a dotted field path.
+// join 将键追加到点分字段路径。
+func join(fieldPath, key string) string {
+	if fieldPath == "" {
+		return key
+	}
+	return fieldPath + "." + key
+}
+
+// fieldPath converts a dotted denylist entry into a lower-case slash-separated glob for path.Match.
+// fieldPath 将点分的拒绝列表条目转换为供 path.Match 使用的小写斜杠分隔通配符。
+func fieldPath(field string) string {
+	return strings.ToLower(strings.ReplaceAll(field, ".", "/"))
+}
+
+// kindOf derives a placeholder kind from a pattern name.
+// kindOf 从模式名称派生占位符类型。
+func kindOf(name string) string {
+	kind := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if kind == "" {
+		return "CUSTOM"
+	}
+	return kind
+}
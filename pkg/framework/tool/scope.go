@@ -0,0 +1,203 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+// hostCluster is the vcluster name of the host cluster; an empty name means the same.
+// hostCluster 是宿主机集群的 vcluster 名称; 空名称含义相同。
+const hostCluster = "host"
+
+// Target is a tenant resource a tool call reads from.
+// Target 是工具调用读取的租户资源。
+type Target struct {
+	VCluster      string // vcluster the call reads from; empty or "host" for the host cluster / 调用读取的 vcluster; 空或 "host" 表示宿主机集群
+	Namespace     string // namespace the call reads from; empty for all namespaces / 调用读取的命名空间; 空表示所有命名空间
+	ClusterScoped bool   // the call reads a cluster-scoped resource such as a Node / 调用读取的是 Node 等集群级资源
+	Service       string // business service the call reads from; set for business calls only / 调用读取的业务服务; 仅业务调用设置
+}
+
+// ScopedTool is implemented by tools that read tenant data selected by their arguments.
+// ScopedTool 由根据参数选择读取租户数据的工具实现。
+// The engine only runs a call whose targets are all within the scope of the incident.
+// 引擎只运行所有目标都在事件范围内的调用。
+type ScopedTool interface {
+	Tool
+
+	// Targets returns the resources a call with the given arguments reads from.
+	// Targets 返回使用给定参数的调用所读取的资源。
+	// A tool that reads no tenant data returns no targets and filters its results with ScopeFrom.
+	// 不读取租户数据的工具不返回目标，并使用 ScopeFrom 过滤其结果。
+	Targets(args map[string]interface{}) ([]Target, error)
+}
+
+// Scope is the set of tenants and resources an incident is about.
+// Scope 是事件所涉及的租户和资源集合。
+type Scope struct {
+	// Restricted is set when a routing rule restricts the incident's evidence. Tools that do not
+	// declare their targets are then refused.
+	// Restricted 在路由规则限制事件证据时设置。此时拒绝未声明目标的工具。
+	Restricted bool
+
+	vclusters  map[string]bool
+	namespaces map[string]bool // keyed by vcluster + "/" + namespace / 以 vcluster + "/" + namespace 为键
+	services   map[string]bool
+	disabled   map[string]string // reasons of the tools disabled for the incident, by name / 按名称记录的为该事件禁用的工具及原因
+}
+
+// NewScope returns the scope of the resources referenced by the issues.
+// NewScope 返回问题所引用资源的范围。
+func NewScope(issues []types.Issue) *Scope {
+	scope := &Scope{vclusters: map[string]bool{}, namespaces: map[string]bool{}, services: map[string]bool{}, disabled: map[string]string{}}
+	for _, issue := range issues {
+		if issue.Resource == nil {
+			continue
+		}
+		resource := issue.Resource
+		if resource.Type == "BusinessService" {
+			if resource.Name != "" {
+				scope.services[resource.Name] = true
+			}
+			continue
+		}
+		vcluster := normalizeVCluster(resource.VCluster)
+		scope.vclusters[vcluster] = true
+		if resource.Namespace != "" && resource.Namespace != "N/A" {
+			scope.namespaces[vcluster+"/"+resource.Namespace] = true
+		}
+	}
+	return scope
+}
+
+// Allows returns an ErrorCodePermissionDenied error unless the target is within the scope.
+// Allows 在目标不在范围内时返回 ErrorCodePermissionDenied 错误。
+func (s *Scope) Allows(target Target) error {
+	if target.Service != "" {
+		if !s.services[target.Service] {
+			return errors.New(errors.ErrorCodePermissionDenied, "business service outside the incident", fmt.Sprintf("service '%s' is not affected by the incident; allowed services: %s", target.Service, keys(s.services)))
+		}
+		return nil
+	}
+
+	vcluster := normalizeVCluster(target.VCluster)
+	if !s.vclusters[vcluster] {
+		return errors.New(errors.ErrorCodePermissionDenied, "vcluster outside the incident", fmt.Sprintf("vcluster '%s' is not affected by the incident; allowed vclusters: %s", vcluster, keys(s.vclusters)))
+	}
+	if target.ClusterScoped {
+		return nil
+	}
+	if !s.namespaces[vcluster+"/"+target.Namespace] {
+		var allowed []string
+		for key := range s.namespaces {
+			if strings.HasPrefix(key, vcluster+"/") {
+				allowed = append(allowed, strings.TrimPrefix(key, vcluster+"/"))
+			}
+		}
+		sort.Strings(allowed)
+		return errors.New(errors.ErrorCodePermissionDenied, "namespace outside the incident", fmt.Sprintf("namespace '%s' of vcluster '%s' is not affected by the incident; allowed namespaces: %s", target.Namespace, vcluster, strings.Join(allowed, ", ")))
+	}
+	return nil
+}
+
+// Check returns an ErrorCodePermissionDenied error unless a call of the tool with the arguments
+// stays within the scope.
+// Check 在使用该参数调用工具会超出范围时返回 ErrorCodePermissionDenied 错误。
+func (s *Scope) Check(t Tool, args map[string]interface{}) error {
+	if reason, disabled := s.disabled[t.Name()]; disabled {
+		return errors.New(errors.ErrorCodePermissionDenied, "tool disabled for the incident", fmt.Sprintf("tool '%s': %s", t.Name(), reason))
+	}
+	scoped, ok := t.(ScopedTool)
+	if !ok {
+		if s.Restricted {
+			return errors.New(errors.ErrorCodePermissionDenied, "tool disabled by routing rules", fmt.Sprintf("tool '%s' cannot be limited to the incident's tenants", t.Name()))
+		}
+		return nil
+	}
+	targets, err := scoped.Targets(args)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if err := s.Allows(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Disable refuses every call of the named tool for the incident.
+// Disable 拒绝该事件中对指定工具的所有调用。
+func (s *Scope) Disable(name, reason string) {
+	if s.disabled == nil {
+		s.disabled = map[string]string{}
+	}
+	s.disabled[name] = reason
+}
+
+// Offers reports whether the tool may be offered to the LLM: it is neither disabled nor refused
+// on a restricted route for not declaring its targets.
+// Offers 报告是否可以向 LLM 提供该工具: 它既未被禁用，也未因在受限路由上未声明目标而被拒绝。
+func (s *Scope) Offers(t Tool) bool {
+	if _, disabled := s.disabled[t.Name()]; disabled {
+		return false
+	}
+	_, scoped := t.(ScopedTool)
+	return scoped || !s.Restricted
+}
+
+// AllowsMetadata reports whether a document with the metadata may be shown for the incident.
+// AllowsMetadata 报告带有该元数据的文档是否可以在此事件中展示。
+// Documents without a vcluster or business service apply everywhere and are always allowed.
+// 没有 vcluster 或业务服务的文档适用于所有地方，始终允许。
+func (s *Scope) AllowsMetadata(vcluster, service string) bool {
+	if vcluster != "" && !s.vclusters[normalizeVCluster(vcluster)] {
+		return false
+	}
+	return service == "" || s.services[service]
+}
+
+// scopeKey is the context key of the scope.
+// scopeKey 是范围的上下文键。
+type scopeKey struct{}
+
+// WithScope returns a context carrying the scope of the incident being diagnosed.
+// WithScope 返回携带正在诊断的事件范围的上下文。
+func WithScope(ctx context.Context, scope *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFrom returns the scope carried by the context. A context without a scope yields an empty,
+// restricted scope, so that tools never read tenant data without one.
+// ScopeFrom 返回上下文携带的范围。没有范围的上下文得到一个空的受限范围，使工具在没有范围时绝不读取租户数据。
+func ScopeFrom(ctx context.Context) *Scope {
+	if scope, ok := ctx.Value(scopeKey{}).(*Scope); ok && scope != nil {
+		return scope
+	}
+	return &Scope{Restricted: true}
+}
+
+// normalizeVCluster maps the names of the host cluster to "host".
+// normalizeVCluster 将宿主机集群的名称映射为 "host"。
+func normalizeVCluster(vcluster string) string {
+	if vcluster == "" {
+		return hostCluster
+	}
+	return vcluster
+}
+
+// keys returns the keys of a set, sorted and comma-separated.
+// keys 返回集合的键 (已排序，逗号分隔)。
+func keys(set map[string]bool) string {
+	list := make([]string, 0, len(set))
+	for key := range set {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,115 @@
+package tool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+)
+
+type fakeTool struct{}
+
+func (fakeTool) Name() string            { return "fake" }
+func (fakeTool) Description() string     { return "" }
+func (fakeTool) Parameters() []Parameter { return nil }
+func (fakeTool) ReadOnly() bool          { return true }
+func (fakeTool) Invoke(context.Context, map[string]interface{}) (string, error) {
+	return "", nil
+}
+
+type fakeScopedTool struct{ fakeTool }
+
+func (fakeScopedTool) Targets(args map[string]interface{}) ([]Target, error) {
+	return []Target{{VCluster: StringArg(args, "vcluster"), Namespace: StringArg(args, "namespace"), Service: StringArg(args, "service")}}, nil
+}
+
+func testScope() *Scope {
+	return NewScope([]types.Issue{
+		{Resource: &types.IssueResource{Type: "Pod", VCluster: "team-a", Namespace: "web", Name: "web-0"}},
+		{Resource: &types.IssueResource{Type: "Node", Namespace: "N/A", Name: "node-1"}},
+		{Resource: &types.IssueResource{Type: "BusinessService", Name: "checkout"}},
+	})
+}
+
+func TestScopeAllows(t *testing.T) {
+	scope := testScope()
+	for _, tc := range []struct {
+		target  Target
+		allowed bool
+	}{
+		{Target{VCluster: "team-a", Namespace: "web"}, true},
+		{Target{VCluster: "team-a", Namespace: "db"}, false},
+		{Target{VCluster: "team-a"}, false},
+		{Target{VCluster: "team-b", Namespace: "web"}, false},
+		{Target{VCluster: "team-a", ClusterScoped: true}, true},
+		{Target{VCluster: "host", ClusterScoped: true}, true},
+		{Target{ClusterScoped: true}, true},
+		{Target{Namespace: "kube-system"}, false},
+		{Target{Service: "checkout"}, true},
+		{Target{Service: "billing"}, false},
+	} {
+		err := scope.Allows(tc.target)
+		if tc.allowed && err != nil {
+			t.Errorf("%+v: unexpected error %v", tc.target, err)
+		}
+		if !tc.allowed && !errors.IsErrorCode(err, errors.ErrorCodePermissionDenied) {
+			t.Errorf("%+v: expected permission denied, got %v", tc.target, err)
+		}
+	}
+}
+
+func TestScopeCheck(t *testing.T) {
+	scope := testScope()
+	if err := scope.Check(fakeScopedTool{}, map[string]interface{}{"vcluster": "team-b", "namespace": "web"}); !errors.IsErrorCode(err, errors.ErrorCodePermissionDenied) {
+		t.Errorf("expected permission denied for another tenant, got %v", err)
+	}
+	if err := scope.Check(fakeScopedTool{}, map[string]interface{}{"vcluster": "team-a", "namespace": "web"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := scope.Check(fakeTool{}, nil); err != nil {
+		t.Errorf("unscoped tool refused on an unrestricted route: %v", err)
+	}
+	scope.Restricted = true
+	if err := scope.Check(fakeTool{}, nil); !errors.IsErrorCode(err, errors.ErrorCodePermissionDenied) {
+		t.Errorf("expected unscoped tool to be refused on a restricted route, got %v", err)
+	}
+}
+
+func TestScopeDisable(t *testing.T) {
+	scope := testScope()
+	if !scope.Offers(fakeTool{}) || !scope.Offers(fakeScopedTool{}) {
+		t.Fatal("expected both tools to be offered on an unrestricted route")
+	}
+	scope.Disable("fake", "not approved")
+	if scope.Offers(fakeScopedTool{}) {
+		t.Error("a disabled tool must not be offered")
+	}
+	if err := scope.Check(fakeScopedTool{}, map[string]interface{}{"vcluster": "team-a", "namespace": "web"}); !errors.IsErrorCode(err, errors.ErrorCodePermissionDenied) {
+		t.Errorf("expected a disabled tool to be refused, got %v", err)
+	}
+
+	restricted := testScope()
+	restricted.Restricted = true
+	if restricted.Offers(fakeTool{}) || !restricted.Offers(fakeScopedTool{}) {
+		t.Error("only scoped tools may be offered on a restricted route")
+	}
+	var empty Scope
+	empty.Disable("fake", "not approved")
+	if empty.Offers(fakeTool{}) {
+		t.Error("a disabled tool must not be offered by a zero scope")
+	}
+}
+
+func TestScopeFromContext(t *testing.T) {
+	if scope := ScopeFrom(context.Background()); !scope.Restricted || scope.Allows(Target{VCluster: "team-a", Namespace: "web"}) == nil {
+		t.Error("a context without a scope must allow nothing")
+	}
+	ctx := WithScope(context.Background(), testScope())
+	if err := ScopeFrom(ctx).Allows(Target{VCluster: "team-a", Namespace: "web"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !ScopeFrom(ctx).AllowsMetadata("", "") || ScopeFrom(ctx).AllowsMetadata("team-b", "") || ScopeFrom(ctx).AllowsMetadata("", "billing") {
+		t.Error("unexpected metadata scope")
+	}
+}
@@ -0,0 +1,273 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"go.uber.org/zap"
+)
+
+// Package tool defines the interface for diagnosis tools the LLM may call and a registry.
+// 包 tool 定义了 LLM 可调用的诊断工具接口和一个注册表。
+
+// Parameter describes a single argument accepted by a tool.
+// Parameter 描述工具接受的单个参数。
+type Parameter struct {
+	Name        string `json:"name"`        // Argument name / 参数名称
+	Type        string `json:"type"`        // JSON type: "string", "integer", "boolean" / JSON 类型: "string", "integer", "boolean"
+	Description string `json:"description"` // What the argument means / 参数含义
+	Required    bool   `json:"required"`    // Whether the argument must be supplied / 是否必须提供
+}
+
+// Tool is the interface that all diagnosis tools must implement.
+// Tool 是所有诊断工具必须实现的接口。
+// Tools are invoked on behalf of the LLM and must never change the state of the system.
+// 工具代表 LLM 被调用，绝不能改变系统状态。
+type Tool interface {
+	// Name returns the unique name the LLM uses to call the tool.
+	// Name 返回 LLM 调用工具时使用的唯一名称。
+	Name() string
+
+	// Description returns a description of what the tool returns, shown to the LLM.
+	// Description 返回工具返回内容的描述，展示给 LLM。
+	Description() string
+
+	// Parameters returns the arguments accepted by the tool.
+	// Parameters 返回工具接受的参数。
+	Parameters() []Parameter
+
+	// ReadOnly reports whether the tool only reads data. Only read-only tools can be registered.
+	// ReadOnly 报告工具是否只读取数据。只有只读工具才能被注册。
+	ReadOnly() bool
+
+	// Invoke runs the tool with the arguments supplied by the LLM and returns its textual result.
+	// Invoke 使用 LLM 提供的参数运行工具，并返回文本结果。
+	Invoke(ctx context.Context, args map[string]interface{}) (string, error)
+}
+
+// ToolRegistry is a global registry for managing Tool implementations.
+// ToolRegistry 是一个用于管理 Tool 实现的全局注册表。
+type ToolRegistry struct {
+	tools map[string]Tool
+	mu    sync.RWMutex
+}
+
+// Global registry instance.
+// 全局注册表实例。
+var globalToolRegistry = &ToolRegistry{
+	tools: make(map[string]Tool),
+}
+
+// RegisterTool registers a Tool with the global registry.
+// RegisterTool 在全局注册表中注册一个 Tool。
+// It panics if a tool with the same name is already registered or if the tool is not read-only,
+// so that a tool able to mutate the system can never be offered to the LLM.
+// 如果同名工具已被注册或工具不是只读的，则会 panic，以确保能够修改系统的工具永远不会提供给 LLM。
+func RegisterTool(tool Tool) {
+	globalToolRegistry.mu.Lock()
+	defer globalToolRegistry.mu.Unlock()
+
+	name := tool.Name()
+	if !tool.ReadOnly() {
+		panic(fmt.Sprintf("tool '%s' is not read-only and cannot be offered to the LLM", name))
+	}
+	if _, exists := globalToolRegistry.tools[name]; exists {
+		panic(fmt.Sprintf("tool with name '%s' already registered", name))
+	}
+	globalToolRegistry.tools[name] = tool
+	log.L().Info("Registered tool", zap.String("name", name))
+}
+
+// GetTool retrieves a Tool from the global registry by name.
+// GetTool 按名称从全局注册表中检索一个 Tool。
+func GetTool(name string) (Tool, bool) {
+	globalToolRegistry.mu.RLock()
+	defer globalToolRegistry.mu.RUnlock()
+
+	tool, found := globalToolRegistry.tools[name]
+	return tool, found
+}
+
+// ListTools returns the names of all registered tools, sorted.
+// ListTools 返回所有已注册工具的名称 (已排序)。
+func ListTools() []string {
+	globalToolRegistry.mu.RLock()
+	defer globalToolRegistry.mu.RUnlock()
+
+	names := make([]string, 0, len(globalToolRegistry.tools))
+	for name := range globalToolRegistry.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetEnabledTools returns the tools offered to the LLM based on the configuration.
+// GetEnabledTools 根据配置返回提供给 LLM 的工具。
+// If no tools are listed, all registered tools are returned.
+// 如果未列出任何工具，则返回所有已注册的工具。
+func GetEnabledTools(cfg *types.DiagnosisAgentConfig) ([]Tool, error) {
+	names := cfg.Tools
+	if len(names) == 0 {
+		names = ListTools()
+	}
+
+	globalToolRegistry.mu.RLock()
+	defer globalToolRegistry.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(names))
+	for _, name := range names {
+		tool, found := globalToolRegistry.tools[name]
+		if !found {
+			return nil, errors.New(errors.ErrorCodeInvalidInput, "tool not found", fmt.Sprintf("tool '%s' is enabled in config but not registered", name))
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// Definition describes a tool for providers that support native tool calling.
+// Definition 为支持原生工具调用的提供商描述工具。
+func Definition(t Tool) llm.ToolDefinition {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, param := range t.Parameters() {
+		properties[param.Name] = map[string]interface{}{
+			"type":        param.Type,
+			"description": param.Description,
+		}
+		if param.Required {
+			required = append(required, param.Name)
+		}
+	}
+	return llm.ToolDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		},
+	}
+}
+
+// CallFromMessage extracts a tool call from an assistant message.
+// CallFromMessage 从助手消息中提取工具调用。
+// Native tool calls take precedence; otherwise the content is parsed with ParseCall.
+// 原生工具调用优先; 否则使用 ParseCall 解析消息内容。
+func CallFromMessage(msg llm.Message) (*Call, bool) {
+	if len(msg.ToolCalls) == 0 {
+		return ParseCall(msg.Content)
+	}
+
+	native := msg.ToolCalls[0]
+	call := &Call{Tool: native.Name, Arguments: map[string]interface{}{}}
+	if strings.TrimSpace(native.Arguments) != "" {
+		if err := json.Unmarshal([]byte(native.Arguments), &call.Arguments); err != nil || call.Arguments == nil {
+			call.Arguments = map[string]interface{}{}
+		}
+	}
+	return call, call.Tool != ""
+}
+
+// Call is a tool call requested by the LLM.
+// Call 是 LLM 请求的工具调用。
+type Call struct {
+	Tool      string                 `json:"tool"`      // Name of the tool to call / 要调用的工具名称
+	Arguments map[string]interface{} `json:"arguments"` // Arguments for the tool / 工具参数
+}
+
+// ParseCall extracts a tool call from an LLM response.
+// ParseCall 从 LLM 响应中提取工具调用。
+// The LLM requests a tool by answering with a JSON object such as
+// {"tool": "get_pod_logs", "arguments": {"namespace": "default", "pod": "web-0"}},
+// optionally wrapped in a Markdown code fence. Any other response is treated as the final answer.
+// LLM 通过返回诸如 {"tool": "get_pod_logs", "arguments": {...}} 的 JSON 对象来请求工具 (可包裹在 Markdown 代码块中)。
+// 其他任何响应都被视为最终答案。
+func ParseCall(response string) (*Call, bool) {
+	text := strings.TrimSpace(response)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start != 0 || end < start {
+		// The call must be the whole answer; a final diagnosis that merely quotes JSON is not a call.
+		// 工具调用必须是完整的回答; 仅引用了 JSON 的最终诊断不视为调用。
+		return nil, false
+	}
+
+	var call Call
+	if err := json.Unmarshal([]byte(text[start:end+1]), &call); err != nil || call.Tool == "" {
+		return nil, false
+	}
+	if call.Arguments == nil {
+		call.Arguments = map[string]interface{}{}
+	}
+	return &call, true
+}
+
+// StringArg returns a string argument, or "" if it is missing.
+// StringArg 返回字符串参数，缺失时返回 ""。
+func StringArg(args map[string]interface{}, name string) string {
+	switch v := args[name].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// RequiredStringArg returns a string argument, or an error if it is missing.
+// RequiredStringArg 返回字符串参数，缺失时返回错误。
+func RequiredStringArg(args map[string]interface{}, name string) (string, error) {
+	value := StringArg(args, name)
+	if value == "" {
+		return "", errors.New(errors.ErrorCodeInvalidInput, "missing tool argument", fmt.Sprintf("argument '%s' is required", name))
+	}
+	return value, nil
+}
+
+// IntArg returns an integer argument, or def if it is missing or invalid.
+// IntArg 返回整数参数，缺失或无效时返回 def。
+// JSON numbers decode as float64 and some models quote numbers, so both are accepted.
+// JSON 数字会被解码为 float64，且部分模型会给数字加引号，因此两者都被接受。
+func IntArg(args map[string]interface{}, name string, def int) int {
+	switch v := args[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// BoolArg returns a boolean argument, or false if it is missing or invalid.
+// BoolArg 返回布尔参数，缺失或无效时返回 false。
+func BoolArg(args map[string]interface{}, name string) bool {
+	switch v := args[name].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(v))
+		return b
+	}
+	return false
+}
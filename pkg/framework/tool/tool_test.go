@@ -0,0 +1,164 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+func TestParseCall(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		response string
+		want     *Call
+	}{
+		{
+			name:     "plain JSON",
+			response: `{"tool": "get_pod_logs", "arguments": {"namespace": "web", "pod": "web-0"}}`,
+			want:     &Call{Tool: "get_pod_logs", Arguments: map[string]interface{}{"namespace": "web", "pod": "web-0"}},
+		},
+		{
+			name:     "fenced JSON",
+			response: "```json\n{\"tool\": \"list_events\", \"arguments\": {\"namespace\": \"web\"}}\n```",
+			want:     &Call{Tool: "list_events", Arguments: map[string]interface{}{"namespace": "web"}},
+		},
+		{
+			name:     "fence without a language",
+			response: "  ```\n{\"tool\": \"list_events\"}\n```  ",
+			want:     &Call{Tool: "list_events", Arguments: map[string]interface{}{}},
+		},
+		{
+			name:     "missing arguments",
+			response: `{"tool": "list_events"}`,
+			want:     &Call{Tool: "list_events", Arguments: map[string]interface{}{}},
+		},
+		{
+			name:     "null arguments",
+			response: `{"tool": "list_events", "arguments": null}`,
+			want:     &Call{Tool: "list_events", Arguments: map[string]interface{}{}},
+		},
+		{name: "malformed JSON", response: `{"tool": "list_events", "arguments": {"namespace": }`},
+		{name: "truncated JSON", response: `{"tool": "list_events"`},
+		{name: "missing name", response: `{"arguments": {"namespace": "web"}}`},
+		{name: "empty name", response: `{"tool": "", "arguments": {}}`},
+		{name: "array arguments", response: `{"tool": "list_events", "arguments": ["web"]}`},
+		{name: "string arguments", response: `{"tool": "list_events", "arguments": "namespace=web"}`},
+		{name: "JSON array", response: `[{"tool": "list_events"}]`},
+		{name: "final answer", response: "Root cause: the container ran out of memory."},
+		{name: "final answer quoting JSON", response: `The agent called {"tool": "list_events"} before.`},
+		{name: "empty", response: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseCall(tc.response)
+			if ok != (tc.want != nil) {
+				t.Fatalf("ParseCall(%q) ok = %v, want %v", tc.response, ok, tc.want != nil)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseCall(%q) = %#v, want %#v", tc.response, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCallFromMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		msg  llm.Message
+		want *Call
+	}{
+		{
+			name: "native call",
+			msg: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{
+				{ID: "call-1", Name: "get_pod_logs", Arguments: `{"namespace": "web", "pod": "web-0"}`},
+				{ID: "call-2", Name: "list_events", Arguments: `{}`},
+			}},
+			want: &Call{Tool: "get_pod_logs", Arguments: map[string]interface{}{"namespace": "web", "pod": "web-0"}},
+		},
+		{
+			name: "native call takes precedence over the content",
+			msg: llm.Message{Role: llm.RoleAssistant, Content: `{"tool": "list_events"}`, ToolCalls: []llm.ToolCall{
+				{ID: "call-1", Name: "get_resource", Arguments: `{"kind": "Pod"}`},
+			}},
+			want: &Call{Tool: "get_resource", Arguments: map[string]interface{}{"kind": "Pod"}},
+		},
+		{
+			name: "native call without arguments",
+			msg:  llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call-1", Name: "list_events"}}},
+			want: &Call{Tool: "list_events", Arguments: map[string]interface{}{}},
+		},
+		{
+			name: "native call with malformed arguments",
+			msg:  llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call-1", Name: "list_events", Arguments: `{"namespace": `}}},
+			want: &Call{Tool: "list_events", Arguments: map[string]interface{}{}},
+		},
+		{
+			name: "native call with non-object arguments",
+			msg:  llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call-1", Name: "list_events", Arguments: `["web"]`}}},
+			want: &Call{Tool: "list_events", Arguments: map[string]interface{}{}},
+		},
+		{
+			name: "native call with null arguments",
+			msg:  llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call-1", Name: "list_events", Arguments: `null`}}},
+			want: &Call{Tool: "list_events", Arguments: map[string]interface{}{}},
+		},
+		{
+			name: "native call without a name",
+			msg:  llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call-1", Arguments: `{}`}}},
+		},
+		{
+			name: "call in the content",
+			msg:  llm.Message{Role: llm.RoleAssistant, Content: "```json\n{\"tool\": \"list_events\", \"arguments\": {\"namespace\": \"web\"}}\n```"},
+			want: &Call{Tool: "list_events", Arguments: map[string]interface{}{"namespace": "web"}},
+		},
+		{
+			name: "final answer",
+			msg:  llm.Message{Role: llm.RoleAssistant, Content: "Root cause: the container ran out of memory."},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := CallFromMessage(tc.msg)
+			if ok != (tc.want != nil) {
+				t.Fatalf("CallFromMessage ok = %v, want %v (call %#v)", ok, tc.want != nil, got)
+			}
+			if tc.want != nil && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("CallFromMessage = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args := map[string]interface{}{
+		"name":     "  web-0 ",
+		"float":    float64(20),
+		"int":      7,
+		"quoted":   " 30 ",
+		"invalid":  "many",
+		"bool":     true,
+		"boolText": "true",
+		"number":   float64(3),
+	}
+	if got := StringArg(args, "name"); got != "web-0" {
+		t.Errorf("StringArg(name) = %q", got)
+	}
+	if got := StringArg(args, "number"); got != "3" {
+		t.Errorf("StringArg(number) = %q", got)
+	}
+	if got := StringArg(args, "missing"); got != "" {
+		t.Errorf("StringArg(missing) = %q", got)
+	}
+	if _, err := RequiredStringArg(args, "missing"); err == nil {
+		t.Error("expected an error for a missing required argument")
+	}
+	for name, want := range map[string]int{"float": 20, "int": 7, "quoted": 30, "invalid": 5, "missing": 5} {
+		if got := IntArg(args, name, 5); got != want {
+			t.Errorf("IntArg(%s) = %d, want %d", name, got, want)
+		}
+	}
+	for name, want := range map[string]bool{"bool": true, "boolText": true, "invalid": false, "missing": false} {
+		if got := BoolArg(args, name); got != want {
+			t.Errorf("BoolArg(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,654 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/embedding"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"go.uber.org/zap"
+)
+
+// Package local provides an embedded knowledge base: a flat vector index with cosine similarity,
+// kept in memory and persisted to a local directory, so RAG works without an external database.
+// 包 local 提供嵌入式知识库: 一个使用余弦相似度的扁平向量索引，保存在内存中并持久化到本地目录，
+// 使 RAG 无需外部数据库即可工作。
+
+const (
+	// indexFile is the name of the index file in the knowledge base directory.
+	// indexFile 是知识库目录中索引文件的名称。
+	indexFile = "index.json"
+	// lockFile is the name of the file locked by the processes sharing the knowledge base directory.
+	// lockFile 是共享知识库目录的进程所锁定的文件名称。
+	lockFile = "index.json.lock"
+	// snapshotDir is the directory of the snapshots, relative to the knowledge base directory.
+	// snapshotDir 是快照所在目录，相对于知识库目录。
+	snapshotDir = "snapshots"
+	// snapshotTimeFormat names snapshots so that they sort by creation time.
+	// snapshotTimeFormat 用于命名快照，使其按创建时间排序。
+	snapshotTimeFormat = "20060102T150405.000000000Z"
+	// indexVersion is the version of the index file format.
+	// indexVersion 是索引文件格式的版本。
+	indexVersion = 1
+)
+
+// document is an entry of the index. Its vector is normalized, so that the dot product of two
+// vectors is their cosine similarity.
+// document 是索引中的一个条目。其向量已归一化，因此两个向量的点积即为它们的余弦相似度。
+type document struct {
+	ID       string            `json:"id"`
+	Source   string            `json:"source"`
+	Content  string            `json:"content"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Vector   []float32         `json:"vector"`
+}
+
+// index is the persisted form of the knowledge base.
+// index 是知识库的持久化形式。
+type index struct {
+	Version   int         `json:"version"`
+	Dimension int         `json:"dimension"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+	Documents []*document `json:"documents"`
+}
+
+// LocalKnowledgeBase implements the KnowledgeBase interface with an in-process vector index.
+// LocalKnowledgeBase 使用进程内向量索引实现 KnowledgeBase 接口。
+type LocalKnowledgeBase struct {
+	config   *types.KnowledgeBaseConfig
+	dir      string
+	embedder embedding.Embedder
+
+	mu        sync.RWMutex
+	docs      map[string]*document
+	dimension int // Dimension of the vectors, 0 while the index is empty / 向量维度，索引为空时为 0
+	// keywords indexes the content of docs for BM25 keyword search; it is rebuilt when the index is loaded.
+	// keywords 为 BM25 关键词搜索索引 docs 的内容; 加载索引时会重建。
+	keywords *knowledgebase.KeywordIndex
+	// indexInfo describes the index file the in-memory state was loaded from or written to, nil when
+	// there is none. Other processes (the kb and feedback commands) write the same file; the state is
+	// reloaded when the file changes.
+	// indexInfo 描述内存状态所加载或写入的索引文件，不存在时为 nil。其他进程 (kb 和 feedback 命令) 会写入同一文件;
+	// 文件变化时会重新加载状态。
+	indexInfo os.FileInfo
+}
+
+// Ensure LocalKnowledgeBase implements the knowledgebase.KnowledgeBase and knowledgebase.DocumentLister interfaces.
+// 确保 LocalKnowledgeBase 实现了 knowledgebase.KnowledgeBase 和 knowledgebase.DocumentLister 接口。
+var _ knowledgebase.DocumentLister = &LocalKnowledgeBase{}
+
+// NewLocalKnowledgeBase creates a new LocalKnowledgeBase instance and loads the index from the
+// configured directory, creating the directory if needed. The embedder must produce vectors of the
+// index's dimension.
+// NewLocalKnowledgeBase 创建一个新的 LocalKnowledgeBase 实例并从配置的目录加载索引，必要时创建该目录。
+// embedder 产生的向量必须符合索引的维度。
+func NewLocalKnowledgeBase(cfg *types.KnowledgeBaseConfig, embedder embedding.Embedder) (*LocalKnowledgeBase, error) {
+	if cfg == nil || embedder == nil {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "local knowledge base configuration is incomplete", "a configuration and an embedder are required")
+	}
+	dir := cfg.Local.Dir
+	if dir == "" {
+		dir = constants.DefaultLocalKBDir
+	}
+	if err := os.MkdirAll(filepath.Join(dir, snapshotDir), 0o755); err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to create knowledge base directory", err, dir)
+	}
+
+	kb := &LocalKnowledgeBase{
+		config:   cfg,
+		dir:      dir,
+		embedder: embedder,
+		docs:     make(map[string]*document),
+		keywords: knowledgebase.NewKeywordIndex(),
+	}
+	if err := kb.refresh(); err != nil {
+		return nil, err
+	}
+	// An index built with another embedding model cannot be searched with this one.
+	// 使用其他 embedding 模型构建的索引无法用当前模型检索。
+	if dimension := embedder.Dimension(); dimension > 0 && kb.dimension > 0 && dimension != kb.dimension {
+		return nil, errors.New(errors.ErrorCodeKnowledgeBaseError, "embedding dimension does not match the index", fmt.Sprintf("%s has dimension %d, the index in %s has dimension %d; re-ingest the knowledge base after changing the embedding model", embedder.Name(), dimension, dir, kb.dimension))
+	}
+
+	log.L().Info("Initialized local knowledge base", zap.String("dir", dir), zap.Int("documents", len(kb.docs)), zap.Int("dimension", kb.dimension))
+	return kb, nil
+}
+
+// Name returns the name of the knowledge base provider.
+// Name 返回知识库提供商的名称。
+func (kb *LocalKnowledgeBase) Name() string {
+	return constants.KBProviderLocal
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (kb *LocalKnowledgeBase) Description() string {
+	return "Embedded vector knowledge base persisted to a local directory."
+}
+
+// Store embeds the entries and adds them to the index, replacing entries with the same ID. Entries
+// without an ID get one derived from their source and content.
+// Store 计算条目的 embedding 并将其加入索引，替换 ID 相同的条目。没有 ID 的条目会获得由来源和内容派生的 ID。
+func (kb *LocalKnowledgeBase) Store(ctx context.Context, docs []types.KnowledgeDocument) error {
+	logger := log.LWithContext(ctx).With(zap.String("kb", kb.Name()))
+
+	var entries []types.KnowledgeDocument
+	var texts []string
+	for _, entry := range docs {
+		if strings.TrimSpace(entry.Content) == "" {
+			continue
+		}
+		if entry.ID == "" {
+			entry.ID = knowledgebase.DocumentID(entry.Source, entry.Content)
+		}
+		entries = append(entries, entry)
+		texts = append(texts, entry.Content)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	vectors, err := kb.embedder.Embed(ctx, texts)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to embed knowledge base entries", err, "")
+	}
+	if len(vectors) != len(entries) {
+		return errors.New(errors.ErrorCodeKnowledgeBaseError, "unexpected number of embeddings", fmt.Sprintf("requested %d, received %d", len(entries), len(vectors)))
+	}
+
+	unlock, err := kb.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	// Entries written by other processes since the index was loaded are kept.
+	// 保留自索引加载以来其他进程写入的条目。
+	if err := kb.refreshLocked(); err != nil {
+		return err
+	}
+
+	// The new state is only kept once it is persisted.
+	// 新状态只有在持久化成功后才会生效。
+	dimension := kb.dimension
+	next := make(map[string]*document, len(kb.docs)+len(entries))
+	for id, doc := range kb.docs {
+		next[id] = doc
+	}
+	for i, entry := range entries {
+		if dimension == 0 {
+			dimension = len(vectors[i])
+		}
+		if len(vectors[i]) != dimension {
+			return errors.New(errors.ErrorCodeKnowledgeBaseError, "embedding dimension does not match the index", fmt.Sprintf("entry %s has dimension %d, index has dimension %d", entry.ID, len(vectors[i]), dimension))
+		}
+		vector, ok := normalize(vectors[i])
+		if !ok {
+			return errors.New(errors.ErrorCodeKnowledgeBaseError, "embedding is a zero vector", entry.ID)
+		}
+		next[entry.ID] = &document{ID: entry.ID, Source: entry.Source, Content: entry.Content, Metadata: entry.Metadata, Vector: vector}
+	}
+	if err := kb.persist(next, dimension); err != nil {
+		return err
+	}
+	kb.docs = next
+	kb.dimension = dimension
+	for _, entry := range entries {
+		kb.keywords.Add(entry.ID, entry.Content)
+	}
+
+	logger.Debug("Stored entries in local knowledge base", zap.Int("count", len(entries)), zap.Int("documents", len(next)))
+	return nil
+}
+
+// Retrieve returns the entries most relevant to the query. In hybrid mode (the default) the
+// cosine similarity ranking and the BM25 keyword ranking are fused with reciprocal rank fusion, so
+// exact error strings are found even when their embeddings are not close. The options "k" (number
+// of hits), "minScore" (lowest cosine similarity of hits without a keyword match), "filter" and the
+// scope options "vcluster", "businessService" and "resourceKind" (metadata to match) and "mode" are
+// honoured.
+// Retrieve 返回与查询最相关的条目。在 hybrid 模式 (默认) 下，余弦相似度排序和 BM25 关键词排序通过倒数排名融合合并，
+// 因此即使 embedding 不接近也能找到精确的错误字符串。支持选项 "k" (命中数)、"minScore" (没有关键词匹配的命中的最低余弦相似度)、
+// "filter" 以及范围选项 "vcluster"、"businessService" 和 "resourceKind" (要匹配的元数据) 和 "mode"。
+func (kb *LocalKnowledgeBase) Retrieve(ctx context.Context, query string, options map[string]interface{}) ([]types.KnowledgeBaseHit, error) {
+	logger := log.LWithContext(ctx).With(zap.String("kb", kb.Name()))
+	logger.Debug("Retrieving knowledge from local knowledge base", zap.String("query", query))
+
+	retrieval := knowledgebase.NewRetrieval(kb.config.Retrieval, options)
+	if strings.TrimSpace(query) == "" || retrieval.K <= 0 {
+		return nil, nil
+	}
+
+	if err := kb.refresh(); err != nil {
+		return nil, err
+	}
+	kb.mu.RLock()
+	empty := len(kb.docs) == 0
+	kb.mu.RUnlock()
+	if empty {
+		return nil, nil
+	}
+
+	// The similarity of keyword matches is reported too, so the query is embedded in every mode.
+	// 关键词匹配的相似度也会被报告，因此每种模式下都会计算查询的 embedding。
+	vectors, err := kb.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to embed query", err, query)
+	}
+	if len(vectors) != 1 {
+		return nil, errors.New(errors.ErrorCodeKnowledgeBaseError, "unexpected number of embeddings", fmt.Sprintf("requested 1, received %d", len(vectors)))
+	}
+	vector, ok := normalize(vectors[0])
+	if !ok && !retrieval.UsesKeyword() {
+		return nil, nil
+	}
+
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	if !ok {
+		// A zero query vector is similar to nothing; keyword matches are still returned.
+		// 零查询向量与任何内容都不相似; 仍会返回关键词匹配。
+		vector = make([]float32, len(vectors[0]))
+	}
+	if len(vector) != kb.dimension {
+		return nil, errors.New(errors.ErrorCodeKnowledgeBaseError, "query embedding dimension does not match the index", fmt.Sprintf("query has dimension %d, index has dimension %d", len(vector), kb.dimension))
+	}
+	hit := func(doc *document) types.KnowledgeBaseHit {
+		return types.KnowledgeBaseHit{ID: doc.ID, Source: doc.Source, Content: doc.Content, Score: dot(vector, doc.Vector), Metadata: doc.Metadata}
+	}
+
+	var vectorHits []types.KnowledgeBaseHit
+	if retrieval.UsesVector() && ok {
+		vectorHits = make([]types.KnowledgeBaseHit, 0, len(kb.docs))
+		for _, doc := range kb.docs {
+			if knowledgebase.MatchesFilter(doc.Metadata, retrieval.Filter) {
+				vectorHits = append(vectorHits, hit(doc))
+			}
+		}
+		sort.Slice(vectorHits, func(i, j int) bool {
+			if vectorHits[i].Score != vectorHits[j].Score {
+				return vectorHits[i].Score > vectorHits[j].Score
+			}
+			return vectorHits[i].ID < vectorHits[j].ID
+		})
+		if len(vectorHits) > retrieval.Candidates {
+			vectorHits = vectorHits[:retrieval.Candidates]
+		}
+	}
+
+	var keywordHits []types.KnowledgeBaseHit
+	if retrieval.UsesKeyword() {
+		accept := func(id string) bool { return knowledgebase.MatchesFilter(kb.docs[id].Metadata, retrieval.Filter) }
+		for _, match := range kb.keywords.Search(query, retrieval.Candidates, accept) {
+			keywordHit := hit(kb.docs[match.ID])
+			keywordHit.KeywordScore = match.Score
+			keywordHits = append(keywordHits, keywordHit)
+		}
+	}
+
+	return retrieval.Combine(vectorHits, keywordHits), nil
+}
+
+// Delete removes the entries with the given IDs. Unknown IDs are ignored.
+// Delete 删除给定 ID 的条目。未知的 ID 会被忽略。
+func (kb *LocalKnowledgeBase) Delete(ctx context.Context, ids []string) error {
+	unlock, err := kb.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if err := kb.refreshLocked(); err != nil {
+		return err
+	}
+
+	docs := make(map[string]*document, len(kb.docs))
+	for id, doc := range kb.docs {
+		docs[id] = doc
+	}
+	for _, id := range ids {
+		delete(docs, id)
+	}
+	if len(docs) == len(kb.docs) {
+		return nil
+	}
+	dimension := kb.dimension
+	if len(docs) == 0 {
+		dimension = 0
+	}
+	if err := kb.persist(docs, dimension); err != nil {
+		return err
+	}
+	log.LWithContext(ctx).Debug("Deleted entries from local knowledge base", zap.String("kb", kb.Name()), zap.Int("count", len(kb.docs)-len(docs)))
+	kb.docs = docs
+	kb.dimension = dimension
+	for _, id := range ids {
+		kb.keywords.Remove(id)
+	}
+	return nil
+}
+
+// Len returns the number of entries in the index.
+// Len 返回索引中的条目数。
+func (kb *LocalKnowledgeBase) Len() int {
+	if err := kb.refresh(); err != nil {
+		log.L().Warn("Failed to reload local knowledge base index", zap.String("dir", kb.dir), zap.Error(err))
+	}
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	return len(kb.docs)
+}
+
+// List returns every entry of the index, sorted by ID.
+// List 返回索引中的所有条目，按 ID 排序。
+func (kb *LocalKnowledgeBase) List(ctx context.Context) ([]types.KnowledgeDocument, error) {
+	if err := kb.refresh(); err != nil {
+		return nil, err
+	}
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	docs := make([]types.KnowledgeDocument, 0, len(kb.docs))
+	for _, doc := range kb.docs {
+		docs = append(docs, types.KnowledgeDocument{ID: doc.ID, Source: doc.Source, Content: doc.Content, Metadata: doc.Metadata})
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return docs, nil
+}
+
+// Snapshot writes a copy of the index to the snapshot directory and returns the snapshot's name.
+// Only the newest snapshots are kept.
+// Snapshot 将索引的副本写入快照目录并返回快照名称。只保留最新的若干个快照。
+func (kb *LocalKnowledgeBase) Snapshot(ctx context.Context) (string, error) {
+	if err := kb.refresh(); err != nil {
+		return "", err
+	}
+	kb.mu.RLock()
+	idx := newIndex(kb.docs, kb.dimension)
+	kb.mu.RUnlock()
+
+	name := time.Now().UTC().Format(snapshotTimeFormat) + ".json"
+	if err := writeIndex(filepath.Join(kb.dir, snapshotDir, name), idx); err != nil {
+		return "", err
+	}
+
+	max := kb.config.Local.MaxSnapshots
+	if max <= 0 {
+		max = constants.DefaultLocalKBMaxSnapshots
+	}
+	snapshots, err := kb.Snapshots()
+	if err != nil {
+		return name, err
+	}
+	for len(snapshots) > max {
+		if err := os.Remove(filepath.Join(kb.dir, snapshotDir, snapshots[0])); err != nil {
+			return name, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to remove old snapshot", err, snapshots[0])
+		}
+		snapshots = snapshots[1:]
+	}
+
+	log.LWithContext(ctx).Info("Created local knowledge base snapshot", zap.String("snapshot", name), zap.Int("documents", len(idx.Documents)))
+	return name, nil
+}
+
+// Snapshots returns the names of the snapshots, oldest first.
+// Snapshots 返回快照名称，最旧的在前。
+func (kb *LocalKnowledgeBase) Snapshots() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(kb.dir, snapshotDir))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to list snapshots", err, kb.dir)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Restore replaces the index with the content of a snapshot.
+// Restore 用快照的内容替换索引。
+func (kb *LocalKnowledgeBase) Restore(ctx context.Context, name string) error {
+	if name == "" || name != filepath.Base(name) {
+		return errors.New(errors.ErrorCodeInvalidInput, "invalid snapshot name", name)
+	}
+	path := filepath.Join(kb.dir, snapshotDir, name)
+	if !fileExists(path) {
+		return errors.New(errors.ErrorCodeNotFound, "snapshot not found", name)
+	}
+	idx, err := readIndex(path)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := kb.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if err := writeIndex(filepath.Join(kb.dir, indexFile), idx); err != nil {
+		return err
+	}
+	kb.load(idx)
+	kb.indexInfo = statIndex(filepath.Join(kb.dir, indexFile))
+
+	log.LWithContext(ctx).Info("Restored local knowledge base snapshot", zap.String("snapshot", name), zap.Int("documents", len(kb.docs)))
+	return nil
+}
+
+// load replaces the in-memory state with an index read from disk.
+// load 用从磁盘读取的索引替换内存中的状态。
+func (kb *LocalKnowledgeBase) load(idx *index) {
+	kb.docs = make(map[string]*document, len(idx.Documents))
+	kb.keywords = knowledgebase.NewKeywordIndex()
+	for _, doc := range idx.Documents {
+		kb.docs[doc.ID] = doc
+		kb.keywords.Add(doc.ID, doc.Content)
+	}
+	kb.dimension = idx.Dimension
+}
+
+// persist writes the given state to the index file. The caller holds the exclusive file lock.
+// persist 将给定状态写入索引文件。调用方持有排他文件锁。
+func (kb *LocalKnowledgeBase) persist(docs map[string]*document, dimension int) error {
+	path := filepath.Join(kb.dir, indexFile)
+	if err := writeIndex(path, newIndex(docs, dimension)); err != nil {
+		return err
+	}
+	kb.indexInfo = statIndex(path)
+	return nil
+}
+
+// lock takes the lock shared with the other processes using the knowledge base directory.
+// lock 获取与使用该知识库目录的其他进程共享的锁。
+func (kb *LocalKnowledgeBase) lock(exclusive bool) (func(), error) {
+	return knowledgebase.LockFile(filepath.Join(kb.dir, lockFile), exclusive)
+}
+
+// refresh reloads the index if another process changed the index file since it was loaded.
+// refresh 在索引文件自加载以来被其他进程修改时重新加载索引。
+func (kb *LocalKnowledgeBase) refresh() error {
+	kb.mu.RLock()
+	unchanged := sameIndex(kb.indexInfo, statIndex(filepath.Join(kb.dir, indexFile)))
+	kb.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	unlock, err := kb.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	return kb.refreshLocked()
+}
+
+// refreshLocked reloads the index if the index file changed. The caller holds the file lock and
+// kb.mu. A removed index file is an empty knowledge base.
+// refreshLocked 在索引文件变化时重新加载索引。调用方持有文件锁和 kb.mu。索引文件被删除表示知识库为空。
+func (kb *LocalKnowledgeBase) refreshLocked() error {
+	path := filepath.Join(kb.dir, indexFile)
+	info := statIndex(path)
+	if sameIndex(kb.indexInfo, info) {
+		return nil
+	}
+	idx := &index{Version: indexVersion}
+	if info != nil {
+		var err error
+		if idx, err = readIndex(path); err != nil {
+			return err
+		}
+	}
+	kb.load(idx)
+	kb.indexInfo = info
+	return nil
+}
+
+// newIndex returns the persisted form of a state, with the documents sorted by ID.
+// newIndex 返回某个状态的持久化形式，文档按 ID 排序。
+func newIndex(docs map[string]*document, dimension int) *index {
+	idx := &index{Version: indexVersion, Dimension: dimension, UpdatedAt: time.Now().UTC(), Documents: make([]*document, 0, len(docs))}
+	for _, doc := range docs {
+		idx.Documents = append(idx.Documents, doc)
+	}
+	sort.Slice(idx.Documents, func(i, j int) bool { return idx.Documents[i].ID < idx.Documents[j].ID })
+	return idx
+}
+
+// readIndex reads an index file and checks that its vectors have the index's dimension.
+// readIndex 读取索引文件并检查其向量是否符合索引的维度。
+func readIndex(path string) (*index, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to read knowledge base index", err, path)
+	}
+	var idx index
+	if err := json.Unmarshal(content, &idx); err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "invalid knowledge base index", err, path)
+	}
+	if idx.Version != indexVersion {
+		return nil, errors.New(errors.ErrorCodeKnowledgeBaseError, "unsupported knowledge base index version", fmt.Sprintf("%s has version %d", path, idx.Version))
+	}
+	for _, doc := range idx.Documents {
+		if len(doc.Vector) != idx.Dimension {
+			return nil, errors.New(errors.ErrorCodeKnowledgeBaseError, "invalid knowledge base index", fmt.Sprintf("%s: entry %s has dimension %d, index has dimension %d", path, doc.ID, len(doc.Vector), idx.Dimension))
+		}
+	}
+	return &idx, nil
+}
+
+// writeIndex writes an index file atomically: a temporary file is written and renamed.
+// writeIndex 原子地写入索引文件: 先写入临时文件再重命名。
+func writeIndex(path string, idx *index) error {
+	content, err := json.Marshal(idx)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to encode knowledge base index", err, path)
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to write knowledge base index", err, path)
+	}
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to write knowledge base index", err, path)
+	}
+	return nil
+}
+
+// statIndex returns the description of the index file, or nil if there is none.
+// statIndex 返回索引文件的描述; 文件不存在时返回 nil。
+func statIndex(path string) os.FileInfo {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return info
+}
+
+// sameIndex reports whether two descriptions are of the same, unmodified index file. The index is
+// replaced by renaming a new file, so a write changes the file's identity as well as its time.
+// sameIndex 报告两个描述是否属于同一个未修改的索引文件。索引通过重命名新文件来替换，因此写入会同时改变文件标识和时间。
+func sameIndex(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// fileExists reports whether a regular file exists at the path.
+// fileExists 报告该路径上是否存在普通文件。
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// normalize returns the vector scaled to unit length, or false for a zero vector.
+// normalize 返回缩放为单位长度的向量; 零向量返回 false。
+func normalize(v []float32) ([]float32, bool) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return nil, false
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out, true
+}
+
+// dot returns the dot product of two vectors of the same dimension.
+// dot 返回两个同维度向量的点积。
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// Global instance placeholder, will be initialized in main.
+// 全局实例占位符，将在 main 中初始化。
+var LocalKnowledgeBaseInstance *LocalKnowledgeBase
+
+// RegisterLocalKnowledgeBase registers the initialized LocalKnowledgeBase instance.
+// RegisterLocalKnowledgeBase 注册已初始化的 LocalKnowledgeBase 实例。
+// This should be called after NewLocalKnowledgeBase is successful.
+// 应在 NewLocalKnowledgeBase 成功后调用此函数。
+func RegisterLocalKnowledgeBase(kb *LocalKnowledgeBase) {
+	knowledgebase.RegisterKnowledgeBase(kb)
+	LocalKnowledgeBaseInstance = kb
+}
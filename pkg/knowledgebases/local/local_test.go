@@ -0,0 +1,69 @@
+package local
+
+import (
+	"context"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/embedding"
+)
+
+func newTestKnowledgeBase(t *testing.T, dir string) *LocalKnowledgeBase {
+	t.Helper()
+	cfg := &types.KnowledgeBaseConfig{}
+	cfg.Local.Dir = dir
+	kb, err := NewLocalKnowledgeBase(cfg, embedding.NewHashingEmbedder(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kb
+}
+
+func ids(t *testing.T, kb *LocalKnowledgeBase) []string {
+	t.Helper()
+	docs, err := kb.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, doc := range docs {
+		list = append(list, doc.ID)
+	}
+	return list
+}
+
+func TestWritesOfAnotherProcessAreKept(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// agent and cli share the directory like the agent and the kb commands do.
+	agent := newTestKnowledgeBase(t, dir)
+	cli := newTestKnowledgeBase(t, dir)
+
+	if err := agent.Store(ctx, []types.KnowledgeDocument{{ID: "runbook", Content: "Restart the pod when the image pull fails."}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Store(ctx, []types.KnowledgeDocument{{ID: "feedback", Content: "The registry credentials had expired."}}); err != nil {
+		t.Fatal(err)
+	}
+	// The agent's next write must not drop the document stored by the other process.
+	if err := agent.Store(ctx, []types.KnowledgeDocument{{ID: "postmortem", Content: "Disk pressure evicted the pods."}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, cli); len(got) != 3 {
+		t.Fatalf("expected three documents, got %v", got)
+	}
+
+	if err := cli.Delete(ctx, []string{"runbook"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, agent); len(got) != 2 || got[0] != "feedback" || got[1] != "postmortem" {
+		t.Fatalf("expected the deletion to be seen, got %v", got)
+	}
+	hits, err := agent.Retrieve(ctx, "registry credentials expired", map[string]interface{}{"k": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].ID != "feedback" {
+		t.Fatalf("expected the other process's document to be found, got %+v", hits)
+	}
+}
@@ -0,0 +1,169 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+// Client is the interface of the vector database backends used by VectorDBKnowledgeBase.
+// Client 是 VectorDBKnowledgeBase 使用的向量数据库后端接口。
+type Client interface {
+	// EnsureCollection creates the collection for vectors of the dimension if it does not exist,
+	// and fails if it exists with another dimension.
+	// EnsureCollection 在集合不存在时为该维度的向量创建集合; 如果集合已存在但维度不同则失败。
+	EnsureCollection(ctx context.Context, dimension int) error
+
+	// Upsert inserts the points, replacing points with the same ID.
+	// Upsert 插入这些点，替换 ID 相同的点。
+	Upsert(ctx context.Context, points []Point) error
+
+	// Search returns the k points most similar to the vector whose metadata matches the filter.
+	// Search 返回与该向量最相似且元数据匹配过滤条件的 k 个点。
+	Search(ctx context.Context, vector []float32, k int, filter map[string]string) ([]ScoredPoint, error)
+
+	// KeywordSearch returns the k points with the highest BM25 score for the query whose metadata
+	// matches the filter. The points carry their vector, and their BM25 score as Score.
+	// KeywordSearch 返回对查询 BM25 分数最高且元数据匹配过滤条件的 k 个点。这些点携带其向量，Score 为 BM25 分数。
+	KeywordSearch(ctx context.Context, query string, k int, filter map[string]string) ([]ScoredPoint, error)
+
+	// Scroll returns up to limit points without their vectors in a stable order, starting at the
+	// cursor (empty for the first page), and the cursor of the next page, which is empty after the
+	// last page.
+	// Scroll 按稳定顺序从游标处 (第一页为空) 开始返回最多 limit 个不含向量的点，以及下一页的游标; 最后一页之后游标为空。
+	Scroll(ctx context.Context, cursor string, limit int) ([]Point, string, error)
+
+	// Delete removes the points with the given IDs. Unknown IDs are ignored.
+	// Delete 删除给定 ID 的点。未知的 ID 会被忽略。
+	Delete(ctx context.Context, ids []string) error
+}
+
+// Point is a knowledge base entry stored in a vector database.
+// Point 是存储在向量数据库中的知识库条目。
+type Point struct {
+	ID       string
+	Source   string
+	Content  string
+	Metadata map[string]string
+	Vector   []float32
+}
+
+// ScoredPoint is a point found by a search, with its cosine similarity to the query (Search) or its
+// BM25 score (KeywordSearch).
+// ScoredPoint 是搜索找到的点，附带其与查询的余弦相似度 (Search) 或 BM25 分数 (KeywordSearch)。
+type ScoredPoint struct {
+	Point
+	Score float64
+}
+
+// restClient sends JSON requests to the REST API of a vector database.
+// restClient 向向量数据库的 REST API 发送 JSON 请求。
+type restClient struct {
+	baseURL    string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// newRESTClient creates a restClient for the base URL, sending the headers with every request.
+// newRESTClient 为基础 URL 创建 restClient，每个请求都会携带这些请求头。
+func newRESTClient(baseURL string, headers map[string]string, timeout time.Duration) *restClient {
+	return &restClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		headers:    headers,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// do sends a request with an optional JSON body and decodes a successful JSON response into out,
+// if out is not nil. A non-2xx status is returned as a wrapped llm.StatusError.
+// do 发送带可选 JSON 请求体的请求，并在 out 非 nil 时将成功的 JSON 响应解码到 out 中。
+// 非 2xx 状态以包装的 llm.StatusError 返回。
+func (c *restClient) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to marshal request body", err, path)
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to create http request", err, path)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "vector database request failed", err, path)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to read response body", err, path)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, fmt.Sprintf("vector database returned status %d", resp.StatusCode), llm.NewStatusError(resp, bodyBytes), method+" "+path)
+	}
+	if out != nil && len(bodyBytes) > 0 {
+		if err := json.Unmarshal(bodyBytes, out); err != nil {
+			return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to unmarshal response body", err, string(bodyBytes))
+		}
+	}
+	return nil
+}
+
+// isNotFound reports whether the vector database answered 404 Not Found.
+// isNotFound 报告向量数据库是否返回 404 Not Found。
+func isNotFound(err error) bool {
+	var statusErr *llm.StatusError
+	return stderrors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// pointID maps a knowledge base ID to the UUID the vector database stores it under. Both Qdrant and
+// Weaviate only accept UUIDs (or integers), so a name-based UUID (version 5 layout) is derived from
+// the ID; the original ID is stored with the point.
+// pointID 将知识库 ID 映射为向量数据库存储它所用的 UUID。Qdrant 和 Weaviate 都只接受 UUID (或整数)，
+// 因此根据 ID 派生一个基于名称的 UUID (版本 5 格式); 原始 ID 与点一起存储。
+func pointID(id string) string {
+	sum := sha1.Sum([]byte(id))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// cosine returns the cosine similarity of two vectors, or 0 if either is empty or zero.
+// cosine 返回两个向量的余弦相似度; 任一向量为空或为零时返回 0。
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
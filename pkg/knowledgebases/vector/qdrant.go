@@ -0,0 +1,348 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+)
+
+// QdrantClient stores knowledge base entries in a Qdrant collection through the REST API. The
+// entry's ID, source, content and metadata are kept in the point's payload; the content has a
+// full-text index for keyword search.
+// QdrantClient 通过 REST API 将知识库条目存储在 Qdrant 集合中。条目的 ID、来源、内容和元数据保存在点的 payload 中;
+// 内容带有用于关键词搜索的全文索引。
+type QdrantClient struct {
+	rest       *restClient
+	collection string
+}
+
+// Ensure QdrantClient implements the Client interface.
+// 确保 QdrantClient 实现了 Client 接口。
+var _ Client = &QdrantClient{}
+
+// Limits of the keyword search, which Qdrant does not score itself.
+// 关键词搜索的限制，Qdrant 本身不对其评分。
+const (
+	// qdrantMaxKeywordTerms is the number of distinct query terms looked up.
+	// qdrantMaxKeywordTerms 是查找的不同查询词项数。
+	qdrantMaxKeywordTerms = 16
+	// qdrantMaxKeywordCandidates is the number of matching points fetched and scored locally.
+	// qdrantMaxKeywordCandidates 是获取并在本地评分的匹配点数。
+	qdrantMaxKeywordCandidates = 1000
+)
+
+// qdrantPayload is the payload of a point.
+// qdrantPayload 是点的 payload。
+type qdrantPayload struct {
+	ID       string            `json:"id"`
+	Source   string            `json:"source"`
+	Content  string            `json:"content"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// NewQdrantClient creates a new QdrantClient. The API key, if any, is sent in the api-key header.
+// NewQdrantClient 创建一个新的 QdrantClient。API Key (如有) 通过 api-key 请求头发送。
+func NewQdrantClient(baseURL, collection, apiKey string, timeout time.Duration) *QdrantClient {
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["api-key"] = apiKey
+	}
+	return &QdrantClient{
+		rest:       newRESTClient(baseURL, headers, timeout),
+		collection: collection,
+	}
+}
+
+// EnsureCollection creates the collection with cosine distance if it does not exist, and the
+// full-text index of the content.
+// EnsureCollection 在集合不存在时以余弦距离创建集合，并创建内容的全文索引。
+func (c *QdrantClient) EnsureCollection(ctx context.Context, dimension int) error {
+	if err := c.ensureCollection(ctx, dimension); err != nil {
+		return err
+	}
+	// Creating an existing index is a no-op.
+	// 创建已存在的索引不会产生任何效果。
+	index := map[string]interface{}{
+		"field_name":   "content",
+		"field_schema": map[string]interface{}{"type": "text", "tokenizer": "word", "lowercase": true},
+	}
+	return c.rest.do(ctx, http.MethodPut, c.path("/index?wait=true"), index, nil)
+}
+
+// ensureCollection creates the collection if it does not exist and checks its dimension.
+// ensureCollection 在集合不存在时创建集合，并检查其维度。
+func (c *QdrantClient) ensureCollection(ctx context.Context, dimension int) error {
+	var info struct {
+		Result struct {
+			Config struct {
+				Params struct {
+					Vectors struct {
+						Size int `json:"size"`
+					} `json:"vectors"`
+				} `json:"params"`
+			} `json:"config"`
+		} `json:"result"`
+	}
+	err := c.rest.do(ctx, http.MethodGet, c.path(""), nil, &info)
+	if err == nil {
+		if size := info.Result.Config.Params.Vectors.Size; size != 0 && size != dimension {
+			return errors.New(errors.ErrorCodeKnowledgeBaseError, "embedding dimension does not match the collection", fmt.Sprintf("collection %s has dimension %d, embeddings have dimension %d", c.collection, size, dimension))
+		}
+		return nil
+	}
+	if !isNotFound(err) {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"vectors": map[string]interface{}{"size": dimension, "distance": "Cosine"},
+	}
+	return c.rest.do(ctx, http.MethodPut, c.path(""), body, nil)
+}
+
+// Upsert inserts the points, replacing points with the same ID.
+// Upsert 插入这些点，替换 ID 相同的点。
+func (c *QdrantClient) Upsert(ctx context.Context, points []Point) error {
+	wire := make([]map[string]interface{}, 0, len(points))
+	for _, point := range points {
+		wire = append(wire, map[string]interface{}{
+			"id":      pointID(point.ID),
+			"vector":  point.Vector,
+			"payload": qdrantPayload{ID: point.ID, Source: point.Source, Content: point.Content, Metadata: point.Metadata},
+		})
+	}
+	return c.rest.do(ctx, http.MethodPut, c.path("/points?wait=true"), map[string]interface{}{"points": wire}, nil)
+}
+
+// Search returns the k points most similar to the vector whose metadata matches the filter.
+// Search 返回与该向量最相似且元数据匹配过滤条件的 k 个点。
+func (c *QdrantClient) Search(ctx context.Context, vector []float32, k int, filter map[string]string) ([]ScoredPoint, error) {
+	body := map[string]interface{}{
+		"vector":       vector,
+		"limit":        k,
+		"with_payload": true,
+	}
+	if len(filter) > 0 {
+		body["filter"] = qdrantFilter(filter)
+	}
+
+	var resp struct {
+		Result []struct {
+			Score   float64       `json:"score"`
+			Payload qdrantPayload `json:"payload"`
+		} `json:"result"`
+	}
+	if err := c.rest.do(ctx, http.MethodPost, c.path("/points/search"), body, &resp); err != nil {
+		if isNotFound(err) {
+			// Nothing has been stored yet.
+			// 尚未存储任何内容。
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	points := make([]ScoredPoint, 0, len(resp.Result))
+	for _, hit := range resp.Result {
+		points = append(points, ScoredPoint{
+			Point: Point{ID: hit.Payload.ID, Source: hit.Payload.Source, Content: hit.Payload.Content, Metadata: hit.Payload.Metadata},
+			Score: hit.Score,
+		})
+	}
+	return points, nil
+}
+
+// KeywordSearch fetches the points whose content contains any query term and scores them with
+// BM25 locally. The document frequencies and the number of points are counted exactly; the average
+// document length is estimated from the fetched points.
+// KeywordSearch 获取内容包含任一查询词项的点，并在本地使用 BM25 评分。文档频率和点数是精确统计的;
+// 平均文档长度根据获取的点估算。
+func (c *QdrantClient) KeywordSearch(ctx context.Context, query string, k int, filter map[string]string) ([]ScoredPoint, error) {
+	terms := knowledgebase.QueryTerms(query)
+	if len(terms) > qdrantMaxKeywordTerms {
+		terms = terms[:qdrantMaxKeywordTerms]
+	}
+	if len(terms) == 0 || k <= 0 {
+		return nil, nil
+	}
+
+	stats := knowledgebase.BM25Stats{DocumentFrequency: map[string]int{}}
+	total, err := c.count(ctx, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	stats.Documents = total
+	var should []map[string]interface{}
+	for _, term := range terms {
+		condition := map[string]interface{}{"key": "content", "match": map[string]interface{}{"text": term}}
+		df, err := c.count(ctx, map[string]interface{}{"must": []map[string]interface{}{condition}})
+		if err != nil {
+			return nil, err
+		}
+		if df > 0 {
+			stats.DocumentFrequency[term] = df
+			should = append(should, condition)
+		}
+	}
+	if len(should) == 0 {
+		return nil, nil
+	}
+
+	// Qdrant requires every must condition and at least one should condition to match.
+	// Qdrant 要求匹配所有 must 条件以及至少一个 should 条件。
+	scrollFilter := qdrantFilter(filter)
+	scrollFilter["should"] = should
+	body := map[string]interface{}{
+		"filter":       scrollFilter,
+		"limit":        qdrantMaxKeywordCandidates,
+		"with_payload": true,
+		"with_vector":  true,
+	}
+	var resp struct {
+		Result struct {
+			Points []struct {
+				Payload qdrantPayload `json:"payload"`
+				Vector  []float32     `json:"vector"`
+			} `json:"points"`
+		} `json:"result"`
+	}
+	if err := c.rest.do(ctx, http.MethodPost, c.path("/points/scroll"), body, &resp); err != nil {
+		return nil, err
+	}
+
+	candidates := resp.Result.Points
+	tokens := make([][]string, len(candidates))
+	totalLength := 0
+	for i, point := range candidates {
+		tokens[i] = knowledgebase.Tokenize(point.Payload.Content)
+		totalLength += len(tokens[i])
+	}
+	if len(candidates) > 0 {
+		stats.AverageLength = float64(totalLength) / float64(len(candidates))
+	}
+	points := make([]ScoredPoint, 0, len(candidates))
+	for i, point := range candidates {
+		points = append(points, ScoredPoint{
+			Point: Point{ID: point.Payload.ID, Source: point.Payload.Source, Content: point.Payload.Content, Metadata: point.Payload.Metadata, Vector: point.Vector},
+			Score: knowledgebase.BM25(terms, tokens[i], stats),
+		})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Score > points[j].Score })
+	if len(points) > k {
+		points = points[:k]
+	}
+	return points, nil
+}
+
+// Scroll pages through the points of the collection in ID order.
+// Scroll 按 ID 顺序分页遍历集合中的点。
+func (c *QdrantClient) Scroll(ctx context.Context, cursor string, limit int) ([]Point, string, error) {
+	body := map[string]interface{}{
+		"limit":        limit,
+		"with_payload": true,
+		"with_vector":  false,
+	}
+	if cursor != "" {
+		body["offset"] = cursor
+	}
+	var resp struct {
+		Result struct {
+			Points []struct {
+				Payload qdrantPayload `json:"payload"`
+			} `json:"points"`
+			// NextPageOffset is the ID of the first point of the next page, null after the last page.
+			// NextPageOffset 是下一页第一个点的 ID，最后一页之后为 null。
+			NextPageOffset *string `json:"next_page_offset"`
+		} `json:"result"`
+	}
+	if err := c.rest.do(ctx, http.MethodPost, c.path("/points/scroll"), body, &resp); err != nil {
+		if isNotFound(err) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	points := make([]Point, 0, len(resp.Result.Points))
+	for _, point := range resp.Result.Points {
+		points = append(points, Point{ID: point.Payload.ID, Source: point.Payload.Source, Content: point.Payload.Content, Metadata: point.Payload.Metadata})
+	}
+	next := ""
+	if resp.Result.NextPageOffset != nil {
+		next = *resp.Result.NextPageOffset
+	}
+	return points, next, nil
+}
+
+// count returns the exact number of points matching the filter; a nil filter counts all points.
+// count 返回匹配过滤条件的点的精确数量; nil 过滤条件统计所有点。
+func (c *QdrantClient) count(ctx context.Context, filter map[string]interface{}) (int, error) {
+	body := map[string]interface{}{"exact": true}
+	if filter != nil {
+		body["filter"] = filter
+	}
+	var resp struct {
+		Result struct {
+			Count int `json:"count"`
+		} `json:"result"`
+	}
+	if err := c.rest.do(ctx, http.MethodPost, c.path("/points/count"), body, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Result.Count, nil
+}
+
+// Delete removes the points with the given IDs.
+// Delete 删除给定 ID 的点。
+func (c *QdrantClient) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	pointIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		pointIDs = append(pointIDs, pointID(id))
+	}
+	err := c.rest.do(ctx, http.MethodPost, c.path("/points/delete?wait=true"), map[string]interface{}{"points": pointIDs}, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// path returns the API path of the collection followed by the suffix.
+// path 返回集合的 API 路径并追加后缀。
+func (c *QdrantClient) path(suffix string) string {
+	return "/collections/" + url.PathEscape(c.collection) + suffix
+}
+
+// qdrantFilter converts a metadata filter to a Qdrant filter matching every key/value pair. Scope
+// keys also match points without the key.
+// qdrantFilter 将元数据过滤条件转换为匹配所有键值对的 Qdrant 过滤条件。范围键也匹配没有该键的点。
+func qdrantFilter(filter map[string]string) map[string]interface{} {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	must := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		condition := map[string]interface{}{
+			"key":   "metadata." + key,
+			"match": map[string]interface{}{"value": filter[key]},
+		}
+		if knowledgebase.IsScopeKey(key) {
+			condition = map[string]interface{}{"should": []map[string]interface{}{
+				condition,
+				{"is_empty": map[string]interface{}{"key": "metadata." + key}},
+			}}
+		}
+		must = append(must, condition)
+	}
+	return map[string]interface{}{"must": must}
+}
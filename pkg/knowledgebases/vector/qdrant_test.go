@@ -0,0 +1,164 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+)
+
+// uuidV5 matches a name-based UUID in the version 5 layout.
+var uuidV5 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// fakeQdrant is an in-memory Qdrant collection serving the REST calls of QdrantClient.
+type fakeQdrant struct {
+	t      *testing.T
+	apiKey string
+
+	mu         sync.Mutex
+	size       int // 0 while the collection does not exist
+	indexed    bool
+	points     map[string]map[string]interface{}
+	lastFilter map[string]interface{}
+}
+
+func newFakeQdrant(t *testing.T, apiKey string) (*fakeQdrant, *httptest.Server) {
+	fake := &fakeQdrant{t: t, apiKey: apiKey, points: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("api-key") != f.apiKey {
+		http.Error(w, `{"status":{"error":"unauthorized"}}`, http.StatusUnauthorized)
+		return
+	}
+	var body map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	reply := func(result interface{}) { _ = json.NewEncoder(w).Encode(map[string]interface{}{"result": result}) }
+
+	path := strings.TrimPrefix(r.URL.Path, "/collections/kb")
+	if f.size == 0 && !(r.Method == http.MethodPut && path == "") {
+		http.Error(w, `{"status":{"error":"Not found: Collection kb doesn't exist"}}`, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		reply(map[string]interface{}{"config": map[string]interface{}{"params": map[string]interface{}{"vectors": map[string]interface{}{"size": f.size}}}})
+	case r.Method == http.MethodPut && path == "":
+		vectors := body["vectors"].(map[string]interface{})
+		if vectors["distance"] != "Cosine" {
+			f.t.Errorf("unexpected distance %v", vectors["distance"])
+		}
+		f.size = int(vectors["size"].(float64))
+		reply(true)
+	case r.Method == http.MethodPut && path == "/index":
+		f.indexed = true
+		reply(map[string]interface{}{"status": "completed"})
+	case r.Method == http.MethodPut && path == "/points":
+		for _, raw := range body["points"].([]interface{}) {
+			point := raw.(map[string]interface{})
+			f.points[point["id"].(string)] = point
+		}
+		reply(map[string]interface{}{"status": "completed"})
+	case r.Method == http.MethodPost && path == "/points/search":
+		f.lastFilter, _ = body["filter"].(map[string]interface{})
+		var hits []map[string]interface{}
+		for _, point := range f.points {
+			hits = append(hits, map[string]interface{}{"id": point["id"], "score": 0.9, "payload": point["payload"]})
+		}
+		reply(hits)
+	case r.Method == http.MethodPost && path == "/points/delete":
+		for _, id := range body["points"].([]interface{}) {
+			delete(f.points, id.(string))
+		}
+		reply(map[string]interface{}{"status": "completed"})
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+	}
+}
+
+func TestQdrantClient(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeQdrant(t, "secret")
+	client := NewQdrantClient(server.URL, "kb", "secret", 5*time.Second)
+
+	// Nothing has been stored yet.
+	if hits, err := client.Search(ctx, []float32{1, 0, 0, 0}, 3, nil); err != nil || len(hits) != 0 {
+		t.Fatalf("expected no hits before the collection exists, got %v, %v", hits, err)
+	}
+
+	if err := client.EnsureCollection(ctx, 4); err != nil {
+		t.Fatal(err)
+	}
+	if fake.size != 4 || !fake.indexed {
+		t.Fatalf("expected the collection and its content index to be created, got size %d, indexed %v", fake.size, fake.indexed)
+	}
+	if err := client.EnsureCollection(ctx, 4); err != nil {
+		t.Fatalf("an existing collection must be reused: %v", err)
+	}
+	if err := client.EnsureCollection(ctx, 8); !errors.IsErrorCode(err, errors.ErrorCodeKnowledgeBaseError) {
+		t.Fatalf("expected a dimension mismatch, got %v", err)
+	}
+
+	points := []Point{
+		{ID: "runbook#1", Source: "runbook.md", Content: "Restart the pod.", Metadata: map[string]string{"vcluster": "team-a"}, Vector: []float32{1, 0, 0, 0}},
+		{ID: "runbook#2", Source: "runbook.md", Content: "Check the registry.", Vector: []float32{0, 1, 0, 0}},
+	}
+	if err := client.Upsert(ctx, points); err != nil {
+		t.Fatal(err)
+	}
+	// Storing an entry again replaces its point.
+	if err := client.Upsert(ctx, points[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.points) != 2 {
+		t.Fatalf("expected two points, got %d", len(fake.points))
+	}
+	for id := range fake.points {
+		if !uuidV5.MatchString(id) {
+			t.Errorf("point ID %q is not a version 5 UUID", id)
+		}
+	}
+	// The ID is derived from the entry's ID only, so every process stores the entry under the same point.
+	if _, ok := fake.points["afbb1d23-fffe-5cf5-ba28-6c79bf412717"]; !ok {
+		t.Fatalf("expected runbook#1 to be stored under its stable ID, got %v", fake.points)
+	}
+
+	hits, err := client.Search(ctx, []float32{1, 0, 0, 0}, 3, map[string]string{"vcluster": "team-a", "type": "runbook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].Score != 0.9 {
+		t.Fatalf("unexpected hits %+v", hits)
+	}
+	filter, _ := json.Marshal(fake.lastFilter)
+	want := `{"must":[{"key":"metadata.type","match":{"value":"runbook"}},{"should":[{"key":"metadata.vcluster","match":{"value":"team-a"}},{"is_empty":{"key":"metadata.vcluster"}}]}]}`
+	if string(filter) != want {
+		t.Fatalf("unexpected filter\n got %s\nwant %s", filter, want)
+	}
+
+	if err := client.Delete(ctx, []string{"runbook#1", "unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.points[pointID("runbook#2")]; len(fake.points) != 1 || !ok {
+		t.Fatalf("expected only runbook#2 to remain, got %v", fake.points)
+	}
+
+	unauthorized := NewQdrantClient(server.URL, "kb", "", 5*time.Second)
+	if err := unauthorized.Upsert(ctx, points); !errors.IsErrorCode(err, errors.ErrorCodeKnowledgeBaseError) || isNotFound(err) {
+		t.Fatalf("expected the request without the API key to be refused, got %v", err)
+	}
+}
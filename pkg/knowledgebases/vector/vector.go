@@ -3,15 +3,18 @@ package vector
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/embedding"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
 	"go.uber.org/zap"
-	// Add import for vector database client library
-	// 添加向量数据库客户端库的导入
-	// "github.com/weaviate/weaviate-go-client/v4/weaviate" // Example Weaviate client
 )
 
 // Package vector provides a knowledge base implementation using a vector database.
@@ -19,63 +22,73 @@ import (
 
 // VectorDBKnowledgeBase implements the KnowledgeBase interface using a vector database.
 // VectorDBKnowledgeBase 使用向量数据库实现 KnowledgeBase 接口。
+// Embeddings are computed client-side; the database stores and searches the vectors.
+// Embedding 在客户端计算; 数据库负责存储和检索向量。
 type VectorDBKnowledgeBase struct {
 	config *types.KnowledgeBaseConfig
 	// client is the vector database client instance.
 	// client 是向量数据库客户端实例。
-	// client *weaviate.Client // Example client type / 示例客户端类型
-	// Add dependency for embedding model provider if embeddings are done client-side
-	// 如果 embedding 在客户端完成，添加 embedding 模型提供商的依赖项
-	// embeddingProvider llm.LLM // Placeholder, assuming LLM interface includes EmbedText
+	client Client
+	// embedder computes the embeddings of entries and queries.
+	// embedder 计算条目和查询的 embedding。
+	embedder embedding.Embedder
+
+	mu sync.Mutex
+	// ensured records that the collection exists with the embedder's dimension.
+	// ensured 记录集合已按 embedder 的维度存在。
+	ensured bool
 }
 
-// Ensure VectorDBKnowledgeBase implements the knowledgebase.KnowledgeBase interface.
-// 确保 VectorDBKnowledgeBase 实现了 knowledgebase.KnowledgeBase 接口。
-var _ knowledgebase.KnowledgeBase = &VectorDBKnowledgeBase{}
+// Ensure VectorDBKnowledgeBase implements the knowledgebase.KnowledgeBase and knowledgebase.DocumentLister interfaces.
+// 确保 VectorDBKnowledgeBase 实现了 knowledgebase.KnowledgeBase 和 knowledgebase.DocumentLister 接口。
+var _ knowledgebase.DocumentLister = &VectorDBKnowledgeBase{}
 
 // NewVectorDBKnowledgeBase creates a new VectorDBKnowledgeBase instance.
 // NewVectorDBKnowledgeBase 创建一个新的 VectorDBKnowledgeBase 实例。
-// It initializes the connection to the vector database.
-// 它初始化与向量数据库的连接。
-func NewVectorDBKnowledgeBase(cfg *types.KnowledgeBaseConfig) (*VectorDBKnowledgeBase, error) {
-	if cfg == nil || cfg.VectorDB.URL == "" {
-		return nil, fmt.Errorf("vector database configuration is incomplete")
-	}
-
-	// TODO: Initialize vector database client based on cfg.VectorDB
-	// TODO: 根据 cfg.VectorDB 初始化向量数据库客户端
-	// Example for Weaviate:
-	// cfgWeaviate := weaviate.Config{
-	// 	Scheme: "http", // or "https"
-	// 	Host:   cfg.VectorDB.URL, // Extract host from URL
-	// 	// ... other config like apiKey, headers ...
-	// }
-	// client, err := weaviate.New(cfgWeaviate)
-	// if err != nil {
-	// 	return nil, fmt.Errorf("failed to create Weaviate client: %w", err)
-	// }
-
-	// TODO: Initialize embedding model provider if needed for client-side embedding
-	// embeddingProvider, err := llm.GetEnabledLLMProvider(&cfg.Embedding) // Assuming LLM config can be used for embedding provider
-	// if err != nil && cfg.Embedding.Provider != "" { // Embedding config might be empty if KB handles embedding internally
-	// 	log.L().Warn("Failed to initialize embedding provider for KB", zap.Error(err))
-	// 	// Decide if embedding provider failure is fatal for the KB
-	// 	// 决定 embedding 提供商失败是否对知识库是致命的
-	// }
-
-	log.L().Info("Initialized Vector DB Knowledge Base", zap.String("url", cfg.VectorDB.URL), zap.String("collection", cfg.VectorDB.Collection))
+// It creates the client of the configured vector database type; the collection is created on the
+// first Store.
+// 它创建所配置向量数据库类型的客户端; 集合在第一次 Store 时创建。
+func NewVectorDBKnowledgeBase(cfg *types.KnowledgeBaseConfig, embedder embedding.Embedder) (*VectorDBKnowledgeBase, error) {
+	if cfg == nil || cfg.VectorDB.URL == "" || cfg.VectorDB.Collection == "" {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "vector database configuration is incomplete", "url and collection are required")
+	}
+	if embedder == nil {
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "vector database configuration is incomplete", "an embedder is required")
+	}
+
+	timeout := cfg.VectorDB.Timeout
+	if timeout <= 0 {
+		timeout = constants.DefaultVectorDBTimeout * time.Second
+	}
+	var client Client
+	switch strings.ToLower(cfg.VectorDB.Type) {
+	case constants.VectorDBTypeQdrant:
+		client = NewQdrantClient(cfg.VectorDB.URL, cfg.VectorDB.Collection, cfg.VectorDB.APIKey, timeout)
+	case constants.VectorDBTypeWeaviate:
+		client = NewWeaviateClient(cfg.VectorDB.URL, cfg.VectorDB.Collection, cfg.VectorDB.APIKey, timeout)
+	default:
+		return nil, errors.New(errors.ErrorCodeInvalidInput, "unsupported vector database type", fmt.Sprintf("vectorDB.type is '%s', expected %s or %s", cfg.VectorDB.Type, constants.VectorDBTypeQdrant, constants.VectorDBTypeWeaviate))
+	}
 
+	log.L().Info("Initialized Vector DB Knowledge Base", zap.String("type", cfg.VectorDB.Type), zap.String("url", cfg.VectorDB.URL), zap.String("collection", cfg.VectorDB.Collection), zap.String("embedder", embedder.Name()))
+
+	return NewVectorDBKnowledgeBaseWithClient(cfg, client, embedder), nil
+}
+
+// NewVectorDBKnowledgeBaseWithClient creates a new VectorDBKnowledgeBase instance using the given client.
+// NewVectorDBKnowledgeBaseWithClient 使用给定的客户端创建一个新的 VectorDBKnowledgeBase 实例。
+func NewVectorDBKnowledgeBaseWithClient(cfg *types.KnowledgeBaseConfig, client Client, embedder embedding.Embedder) *VectorDBKnowledgeBase {
 	return &VectorDBKnowledgeBase{
-		config: cfg,
-		// client: client, // Assign initialized client
-		// embeddingProvider: embeddingProvider, // Assign embedding provider
-	}, nil
+		config:   cfg,
+		client:   client,
+		embedder: embedder,
+	}
 }
 
 // Name returns the name of the knowledge base provider.
 // Name 返回知识库提供商的名称。
 func (kb *VectorDBKnowledgeBase) Name() string {
-	return "vector-db" // Using the constant defined in common.types
+	return constants.KBProviderVectorDB
 }
 
 // Description returns a brief description.
@@ -86,37 +99,61 @@ func (kb *VectorDBKnowledgeBase) Description() string {
 
 // Store adds knowledge data to the vector database.
 // Store 向向量数据库添加知识数据。
-// Requires converting data content into embeddings and storing them with the original text.
-// 需要将数据内容转换为 embedding 并将其与原始文本一起存储。
-func (kb *VectorDBKnowledgeBase) Store(ctx context.Context, data []types.KnowledgeBaseHit) error {
+// The content of each entry is embedded and upserted under a UUID derived from the entry's ID, so
+// storing an entry again replaces it. Entries without an ID get one derived from their source and
+// content.
+// 每个条目的内容会被计算 embedding，并以由条目 ID 派生的 UUID 进行 upsert，因此再次存储同一条目会替换它。
+// 没有 ID 的条目会获得由来源和内容派生的 ID。
+func (kb *VectorDBKnowledgeBase) Store(ctx context.Context, docs []types.KnowledgeDocument) error {
 	logger := log.LWithContext(ctx).With(zap.String("kb", kb.Name()))
-	logger.Debug("Storing data in vector knowledge base", zap.Int("count", len(data)))
+	logger.Debug("Storing data in vector knowledge base", zap.Int("count", len(docs)))
 
 	if kb.client == nil {
 		return fmt.Errorf("vector database client is not initialized")
 	}
-	// If embedding is done client-side
-	// If kb.embeddingProvider == nil {
-	// 	return fmt.Errorf("embedding provider is not initialized for KB")
-	// }
-
-	// TODO: Implement logic to process each data item:
-	// 1. Extract text content.
-	// 2. Generate embedding vector using the embeddingProvider.
-	// 3. Store the vector, original text, and metadata (like source, ID) in the vector database collection.
-	// TODO: 实现处理每个数据项的逻辑:
-	// 1. 提取文本内容。
-	// 2. 使用 embeddingProvider 生成 embedding 向量。
-	// 3. 将向量、原始文本和元数据 (如来源, ID) 存储到向量数据库集合中。
-
-	logger.Warn("VectorDBKnowledgeBase.Store not fully implemented")
-	return nil // Placeholder / 占位符
+
+	var points []Point
+	var texts []string
+	for _, entry := range docs {
+		if strings.TrimSpace(entry.Content) == "" {
+			continue
+		}
+		if entry.ID == "" {
+			entry.ID = knowledgebase.DocumentID(entry.Source, entry.Content)
+		}
+		points = append(points, Point{ID: entry.ID, Source: entry.Source, Content: entry.Content, Metadata: entry.Metadata})
+		texts = append(texts, entry.Content)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	vectors, err := kb.embedder.Embed(ctx, texts)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to embed knowledge base entries", err, "")
+	}
+	for i := range points {
+		points[i].Vector = vectors[i]
+	}
+	if err := kb.ensureCollection(ctx, len(vectors[0])); err != nil {
+		return err
+	}
+	if err := kb.client.Upsert(ctx, points); err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to store knowledge base entries", err, kb.config.VectorDB.Collection)
+	}
+
+	logger.Debug("Stored entries in vector knowledge base", zap.Int("count", len(points)))
+	return nil
 }
 
-// Retrieve searches the vector database for information relevant to the query.
-// Retrieve 在向量数据库中搜索与查询相关的信息。
-// Requires converting the query into an embedding vector and performing a vector search.
-// 需要将查询转换为 embedding 向量并执行向量搜索。
+// Retrieve searches the vector database for information relevant to the query. In hybrid mode
+// (the default) the database's vector ranking and keyword ranking are fused with reciprocal rank
+// fusion. The options "k" (number of hits), "minScore" (lowest cosine similarity of hits without a
+// keyword match), "filter" and the scope options "vcluster", "businessService" and "resourceKind"
+// (metadata to match, applied by the database) and "mode" are honoured.
+// Retrieve 在向量数据库中搜索与查询相关的信息。在 hybrid 模式 (默认) 下，数据库的向量排序和关键词排序通过倒数排名融合合并。
+// 支持选项 "k" (命中数)、"minScore" (没有关键词匹配的命中的最低余弦相似度)、"filter" 以及范围选项 "vcluster"、
+// "businessService" 和 "resourceKind" (要匹配的元数据，由数据库执行) 和 "mode"。
 func (kb *VectorDBKnowledgeBase) Retrieve(ctx context.Context, query string, options map[string]interface{}) ([]types.KnowledgeBaseHit, error) {
 	logger := log.LWithContext(ctx).With(zap.String("kb", kb.Name()))
 	logger.Debug("Retrieving knowledge from vector knowledge base", zap.String("query", query))
@@ -124,42 +161,92 @@ func (kb *VectorDBKnowledgeBase) Retrieve(ctx context.Context, query string, opt
 	if kb.client == nil {
 		return nil, fmt.Errorf("vector database client is not initialized")
 	}
-	// If embedding is done client-side
-	// If kb.embeddingProvider == nil {
-	// 	return nil, fmt.Errorf("embedding provider is not initialized for KB")
-	// }
-
-	// TODO: Implement retrieval logic:
-	// 1. Generate embedding vector for the query using the embeddingProvider.
-	// 2. Perform a vector search in the configured collection.
-	// 3. Retrieve the original text and metadata for the top N results (N specified in options, e.g., "k").
-	// 4. Convert results into []types.KnowledgeBaseHit.
-	// TODO: 实现检索逻辑:
-	// 1. 使用 embeddingProvider 为查询生成 embedding 向量。
-	// 2. 在配置的集合中执行向量搜索。
-	// 3. 检索前 N 个结果 (N 在 options 中指定，例如 "k") 的原始文本和元数据。
-	// 4. 将结果转换为 []types.KnowledgeBaseHit。
-
-	logger.Warn("VectorDBKnowledgeBase.Retrieve not fully implemented")
-
-	// Placeholder results
-	// 占位符结果
-	hits := []types.KnowledgeBaseHit{
-		{
-			ID:      "kb-doc-abc",
-			Source:  "SRE Runbook: Restarting Failed Pods",
-			Content: "To resolve a CrashLoopBackOff issue, check pod logs (`kubectl logs <pod-name>`) and then try deleting the pod (`kubectl delete pod <pod-name>`). The Deployment/StatefulSet will recreate it.",
-			Score:   0.95,
-		},
-		{
-			ID:      "kb-doc-xyz",
-			Source:  "Business App A Troubleshooting Guide",
-			Content: "If Business App A reports database connection errors, verify the database service endpoint in its configuration ConfigMap.",
-			Score:   0.80,
-		},
-	}
-
-	return hits, nil // Placeholder / 占位符
+	retrieval := knowledgebase.NewRetrieval(kb.config.Retrieval, options)
+	if strings.TrimSpace(query) == "" || retrieval.K <= 0 {
+		return nil, nil
+	}
+
+	// The similarity of keyword matches is reported too, so the query is embedded in every mode.
+	// 关键词匹配的相似度也会被报告，因此每种模式下都会计算查询的 embedding。
+	vectors, err := kb.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to embed query", err, query)
+	}
+
+	var vectorHits, keywordHits []types.KnowledgeBaseHit
+	if retrieval.UsesVector() {
+		points, err := kb.client.Search(ctx, vectors[0], retrieval.Candidates, retrieval.Filter)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "vector search failed", err, kb.config.VectorDB.Collection)
+		}
+		for _, point := range points {
+			vectorHits = append(vectorHits, types.KnowledgeBaseHit{ID: point.ID, Source: point.Source, Content: point.Content, Score: point.Score, Metadata: point.Metadata})
+		}
+	}
+	if retrieval.UsesKeyword() {
+		points, err := kb.client.KeywordSearch(ctx, query, retrieval.Candidates, retrieval.Filter)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "keyword search failed", err, kb.config.VectorDB.Collection)
+		}
+		for _, point := range points {
+			keywordHits = append(keywordHits, types.KnowledgeBaseHit{ID: point.ID, Source: point.Source, Content: point.Content, Score: cosine(vectors[0], point.Vector), KeywordScore: point.Score, Metadata: point.Metadata})
+		}
+	}
+
+	return retrieval.Combine(vectorHits, keywordHits), nil
+}
+
+// Delete removes entries from the vector database collection.
+// Delete 从向量数据库集合中删除条目。
+func (kb *VectorDBKnowledgeBase) Delete(ctx context.Context, ids []string) error {
+	if kb.client == nil {
+		return fmt.Errorf("vector database client is not initialized")
+	}
+	if err := kb.client.Delete(ctx, ids); err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to delete knowledge base entries", err, kb.config.VectorDB.Collection)
+	}
+	log.LWithContext(ctx).Debug("Deleted entries from vector knowledge base", zap.String("kb", kb.Name()), zap.Int("count", len(ids)))
+	return nil
+}
+
+// List pages through the collection and returns every entry, sorted by ID.
+// List 分页遍历集合并返回所有条目，按 ID 排序。
+func (kb *VectorDBKnowledgeBase) List(ctx context.Context) ([]types.KnowledgeDocument, error) {
+	if kb.client == nil {
+		return nil, fmt.Errorf("vector database client is not initialized")
+	}
+	var docs []types.KnowledgeDocument
+	cursor := ""
+	for {
+		points, next, err := kb.client.Scroll(ctx, cursor, constants.DefaultKBPageSize)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to list knowledge base entries", err, kb.config.VectorDB.Collection)
+		}
+		for _, point := range points {
+			docs = append(docs, types.KnowledgeDocument{ID: point.ID, Source: point.Source, Content: point.Content, Metadata: point.Metadata})
+		}
+		if next == "" || next == cursor {
+			break
+		}
+		cursor = next
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return docs, nil
+}
+
+// ensureCollection creates the collection once per process.
+// ensureCollection 在每个进程中只创建一次集合。
+func (kb *VectorDBKnowledgeBase) ensureCollection(ctx context.Context, dimension int) error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if kb.ensured {
+		return nil
+	}
+	if err := kb.client.EnsureCollection(ctx, dimension); err != nil {
+		return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to create vector database collection", err, kb.config.VectorDB.Collection)
+	}
+	kb.ensured = true
+	return nil
 }
 
 // Register the knowledge base provider with the global registry.
@@ -0,0 +1,334 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+)
+
+// WeaviateClient stores knowledge base entries as objects of a Weaviate class through the REST and
+// GraphQL APIs. Vectors are supplied by the agent, so the class has no vectorizer. Metadata is kept
+// as JSON, and as "key=value" labels for filtering; keyword search uses Weaviate's BM25 on the content.
+// WeaviateClient 通过 REST 和 GraphQL API 将知识库条目存储为 Weaviate 类的对象。向量由代理提供，
+// 因此该类没有 vectorizer。元数据以 JSON 形式保存，并以 "key=value" 标签形式用于过滤; 关键词搜索使用 Weaviate 对内容的 BM25。
+type WeaviateClient struct {
+	rest  *restClient
+	class string
+}
+
+// Ensure WeaviateClient implements the Client interface.
+// 确保 WeaviateClient 实现了 Client 接口。
+var _ Client = &WeaviateClient{}
+
+// weaviateProperties are the properties of an object. "id" is reserved by Weaviate, so the
+// knowledge base ID is stored as kbId.
+// weaviateProperties 是对象的属性。"id" 被 Weaviate 保留，因此知识库 ID 存储为 kbId。
+type weaviateProperties struct {
+	KBID     string   `json:"kbId"`
+	Source   string   `json:"source"`
+	Content  string   `json:"content"`
+	Metadata string   `json:"metadata"`
+	Labels   []string `json:"labels"`
+}
+
+// NewWeaviateClient creates a new WeaviateClient. The collection name is turned into a valid class
+// name; the API key, if any, is sent as a bearer token.
+// NewWeaviateClient 创建一个新的 WeaviateClient。集合名称会被转换为有效的类名; API Key (如有) 作为 bearer token 发送。
+func NewWeaviateClient(baseURL, collection, apiKey string, timeout time.Duration) *WeaviateClient {
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["Authorization"] = "Bearer " + apiKey
+	}
+	return &WeaviateClient{
+		rest:  newRESTClient(baseURL, headers, timeout),
+		class: weaviateClassName(collection),
+	}
+}
+
+// EnsureCollection creates the class with cosine distance if it does not exist. Weaviate itself
+// rejects vectors whose dimension differs from the stored ones.
+// EnsureCollection 在类不存在时以余弦距离创建该类。Weaviate 自身会拒绝维度与已存储向量不同的向量。
+func (c *WeaviateClient) EnsureCollection(ctx context.Context, dimension int) error {
+	err := c.rest.do(ctx, http.MethodGet, "/v1/schema/"+url.PathEscape(c.class), nil, nil)
+	if err == nil || !isNotFound(err) {
+		return err
+	}
+
+	field := func(name, dataType string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "dataType": []string{dataType}, "tokenization": "field"}
+	}
+	body := map[string]interface{}{
+		"class":             c.class,
+		"description":       "SRE knowledge base entries",
+		"vectorizer":        "none",
+		"vectorIndexConfig": map[string]interface{}{"distance": "cosine"},
+		"properties": []map[string]interface{}{
+			field("kbId", "text"),
+			{"name": "source", "dataType": []string{"text"}},
+			{"name": "content", "dataType": []string{"text"}},
+			{"name": "metadata", "dataType": []string{"text"}, "indexFilterable": false, "indexSearchable": false},
+			field("labels", "text[]"),
+		},
+	}
+	return c.rest.do(ctx, http.MethodPost, "/v1/schema", body, nil)
+}
+
+// Upsert inserts the points with the batch API; objects with the same UUID are replaced.
+// Upsert 使用批量 API 插入这些点; UUID 相同的对象会被替换。
+func (c *WeaviateClient) Upsert(ctx context.Context, points []Point) error {
+	objects := make([]map[string]interface{}, 0, len(points))
+	for _, point := range points {
+		metadata, err := json.Marshal(point.Metadata)
+		if err != nil {
+			return errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to marshal metadata", err, point.ID)
+		}
+		objects = append(objects, map[string]interface{}{
+			"class":      c.class,
+			"id":         pointID(point.ID),
+			"vector":     point.Vector,
+			"properties": weaviateProperties{KBID: point.ID, Source: point.Source, Content: point.Content, Metadata: string(metadata), Labels: storedLabels(point.Metadata)},
+		})
+	}
+
+	var resp []struct {
+		ID     string `json:"id"`
+		Result struct {
+			Errors *struct {
+				Error []struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"errors"`
+		} `json:"result"`
+	}
+	if err := c.rest.do(ctx, http.MethodPost, "/v1/batch/objects", map[string]interface{}{"objects": objects}, &resp); err != nil {
+		return err
+	}
+	// The batch API answers 200 even if single objects fail.
+	// 即使单个对象失败，批量 API 也会返回 200。
+	for _, object := range resp {
+		if object.Result.Errors != nil && len(object.Result.Errors.Error) > 0 {
+			return errors.New(errors.ErrorCodeKnowledgeBaseError, "failed to store object in Weaviate", fmt.Sprintf("%s: %s", object.ID, object.Result.Errors.Error[0].Message))
+		}
+	}
+	return nil
+}
+
+// Search runs a nearVector GraphQL query; the cosine distance is converted to a similarity.
+// Search 执行 nearVector GraphQL 查询; 余弦距离会被转换为相似度。
+func (c *WeaviateClient) Search(ctx context.Context, vector []float32, k int, filter map[string]string) ([]ScoredPoint, error) {
+	vectorJSON, err := json.Marshal(vector)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to marshal query vector", err, "")
+	}
+	objects, err := c.get(ctx, fmt.Sprintf("nearVector: {vector: %s}, limit: %d%s", vectorJSON, k, weaviateWhere(filter)), "distance")
+	if err != nil {
+		return nil, err
+	}
+	points := make([]ScoredPoint, 0, len(objects))
+	for _, object := range objects {
+		points = append(points, ScoredPoint{Point: object.point(), Score: 1 - object.Additional.Distance})
+	}
+	return points, nil
+}
+
+// KeywordSearch runs a bm25 GraphQL query on the content.
+// KeywordSearch 对内容执行 bm25 GraphQL 查询。
+func (c *WeaviateClient) KeywordSearch(ctx context.Context, query string, k int, filter map[string]string) ([]ScoredPoint, error) {
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "failed to marshal keyword query", err, query)
+	}
+	objects, err := c.get(ctx, fmt.Sprintf(`bm25: {query: %s, properties: ["content"]}, limit: %d%s`, queryJSON, k, weaviateWhere(filter)), "score vector")
+	if err != nil {
+		return nil, err
+	}
+	points := make([]ScoredPoint, 0, len(objects))
+	for _, object := range objects {
+		// Weaviate reports the BM25 score as a string.
+		// Weaviate 以字符串形式报告 BM25 分数。
+		score, _ := strconv.ParseFloat(object.Additional.Score, 64)
+		point := object.point()
+		point.Vector = object.Additional.Vector
+		points = append(points, ScoredPoint{Point: point, Score: score})
+	}
+	return points, nil
+}
+
+// Scroll pages through the objects of the class with Weaviate's cursor API, which orders them by UUID.
+// Scroll 使用 Weaviate 的游标 API 分页遍历该类的对象，对象按 UUID 排序。
+func (c *WeaviateClient) Scroll(ctx context.Context, cursor string, limit int) ([]Point, string, error) {
+	arguments := fmt.Sprintf("limit: %d", limit)
+	if cursor != "" {
+		cursorJSON, _ := json.Marshal(cursor)
+		arguments += fmt.Sprintf(", after: %s", cursorJSON)
+	}
+	objects, err := c.get(ctx, arguments, "id")
+	if err != nil {
+		return nil, "", err
+	}
+	points := make([]Point, 0, len(objects))
+	for _, object := range objects {
+		points = append(points, object.point())
+	}
+	next := ""
+	if len(objects) == limit {
+		next = objects[len(objects)-1].Additional.ID
+	}
+	return points, next, nil
+}
+
+// weaviateObject is an object returned by a Get query.
+// weaviateObject 是 Get 查询返回的对象。
+type weaviateObject struct {
+	KBID       string `json:"kbId"`
+	Source     string `json:"source"`
+	Content    string `json:"content"`
+	Metadata   string `json:"metadata"`
+	Additional struct {
+		ID       string    `json:"id"`
+		Distance float64   `json:"distance"`
+		Score    string    `json:"score"`
+		Vector   []float32 `json:"vector"`
+	} `json:"_additional"`
+}
+
+// point converts the object to a point, decoding its metadata.
+// point 将对象转换为点，并解码其元数据。
+func (o weaviateObject) point() Point {
+	point := Point{ID: o.KBID, Source: o.Source, Content: o.Content}
+	if o.Metadata != "" && o.Metadata != "null" {
+		_ = json.Unmarshal([]byte(o.Metadata), &point.Metadata)
+	}
+	return point
+}
+
+// get runs a GraphQL Get query on the class with the arguments, returning the additional fields.
+// get 使用给定参数对该类执行 GraphQL Get 查询，并返回附加字段。
+func (c *WeaviateClient) get(ctx context.Context, arguments, additional string) ([]weaviateObject, error) {
+	query := fmt.Sprintf("{ Get { %s(%s) { kbId source content metadata _additional { %s } } } }", c.class, arguments, additional)
+	var resp struct {
+		Data struct {
+			Get map[string][]weaviateObject `json:"Get"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := c.rest.do(ctx, http.MethodPost, "/v1/graphql", map[string]interface{}{"query": query}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		// The class only exists once something has been stored.
+		// 只有在存储过内容之后，该类才会存在。
+		if strings.Contains(resp.Errors[0].Message, "Cannot query field") {
+			return nil, nil
+		}
+		return nil, errors.New(errors.ErrorCodeKnowledgeBaseError, "Weaviate query failed", resp.Errors[0].Message)
+	}
+	return resp.Data.Get[c.class], nil
+}
+
+// Delete removes the objects with the given IDs one by one; missing objects are ignored.
+// Delete 逐个删除给定 ID 的对象; 不存在的对象会被忽略。
+func (c *WeaviateClient) Delete(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		path := "/v1/objects/" + url.PathEscape(c.class) + "/" + pointID(id)
+		if err := c.rest.do(ctx, http.MethodDelete, path, nil, nil); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// labels returns the metadata as sorted "key=value" labels.
+// labels 以排序后的 "key=value" 标签形式返回元数据。
+func labels(metadata map[string]string) []string {
+	out := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		out = append(out, key+"="+value)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// storedLabels returns the labels stored with an object: its metadata plus an empty "key=" label
+// for every scope key it does not have, so that filters can match unscoped objects.
+// storedLabels 返回与对象一起存储的标签: 其元数据，以及它所缺少的每个范围键的空标签 "key="，
+// 使过滤条件能够匹配没有范围的对象。
+func storedLabels(metadata map[string]string) []string {
+	out := labels(metadata)
+	for _, key := range knowledgebase.ScopeKeys {
+		if metadata[key] == "" {
+			out = append(out, key+"=")
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// weaviateWhere converts a metadata filter to the where argument of a Get query, prefixed with a
+// comma; it is empty for an empty filter. A scope key matches its value or the empty label.
+// weaviateWhere 将元数据过滤条件转换为 Get 查询的 where 参数，并以逗号开头; 过滤条件为空时返回空字符串。
+// 范围键匹配其值或空标签。
+func weaviateWhere(filter map[string]string) string {
+	var operands []string
+	exact := map[string]string{}
+	for key, value := range filter {
+		if !knowledgebase.IsScopeKey(key) {
+			exact[key] = value
+		}
+	}
+	if len(exact) > 0 {
+		labelsJSON, _ := json.Marshal(labels(exact))
+		operands = append(operands, fmt.Sprintf(`{operator: ContainsAll, path: ["labels"], valueText: %s}`, labelsJSON))
+	}
+	for _, key := range knowledgebase.ScopeKeys {
+		if value, ok := filter[key]; ok {
+			labelsJSON, _ := json.Marshal([]string{key + "=" + value, key + "="})
+			operands = append(operands, fmt.Sprintf(`{operator: ContainsAny, path: ["labels"], valueText: %s}`, labelsJSON))
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return ""
+	case 1:
+		return ", where: " + operands[0]
+	default:
+		return ", where: {operator: And, operands: [" + strings.Join(operands, ", ") + "]}"
+	}
+}
+
+// weaviateClassName turns a collection name such as "sre-knowledge" into a valid class name
+// such as "SreKnowledge": letters and digits only, starting with an upper-case letter.
+// weaviateClassName 将 "sre-knowledge" 这样的集合名称转换为 "SreKnowledge" 这样的有效类名:
+// 只包含字母和数字，并以大写字母开头。
+func weaviateClassName(collection string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range collection {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	name := sb.String()
+	if name == "" || !unicode.IsUpper(rune(name[0])) {
+		name = "Kb" + name
+	}
+	return name
+}
@@ -0,0 +1,154 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+)
+
+// fakeWeaviate is an in-memory Weaviate class serving the REST and GraphQL calls of WeaviateClient.
+type fakeWeaviate struct {
+	t      *testing.T
+	apiKey string
+
+	mu        sync.Mutex
+	schema    map[string]interface{} // nil while the class does not exist
+	objects   map[string]map[string]interface{}
+	lastQuery string
+}
+
+func newFakeWeaviate(t *testing.T, apiKey string) (*fakeWeaviate, *httptest.Server) {
+	fake := &fakeWeaviate{t: t, apiKey: apiKey, objects: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeWeaviate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer "+f.apiKey {
+		http.Error(w, `{"error":[{"message":"unauthorized"}]}`, http.StatusUnauthorized)
+		return
+	}
+	var body map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	reply := func(v interface{}) { _ = json.NewEncoder(w).Encode(v) }
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/schema/SreKnowledge":
+		if f.schema == nil {
+			http.NotFound(w, r)
+			return
+		}
+		reply(f.schema)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/schema":
+		f.schema = body
+		reply(body)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/batch/objects":
+		var results []map[string]interface{}
+		for _, raw := range body["objects"].([]interface{}) {
+			object := raw.(map[string]interface{})
+			f.objects[object["id"].(string)] = object
+			results = append(results, map[string]interface{}{"id": object["id"], "result": map[string]interface{}{}})
+		}
+		reply(results)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/graphql":
+		f.lastQuery = body["query"].(string)
+		var objects []map[string]interface{}
+		for id, object := range f.objects {
+			properties := object["properties"].(map[string]interface{})
+			objects = append(objects, map[string]interface{}{
+				"kbId": properties["kbId"], "source": properties["source"], "content": properties["content"], "metadata": properties["metadata"],
+				"_additional": map[string]interface{}{"id": id, "distance": 0.25},
+			})
+		}
+		reply(map[string]interface{}{"data": map[string]interface{}{"Get": map[string]interface{}{"SreKnowledge": objects}}})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v1/objects/SreKnowledge/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/objects/SreKnowledge/")
+		if _, ok := f.objects[id]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.objects, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+	}
+}
+
+func TestWeaviateClient(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeWeaviate(t, "secret")
+	client := NewWeaviateClient(server.URL, "sre-knowledge", "secret", 5*time.Second)
+
+	if err := client.EnsureCollection(ctx, 4); err != nil {
+		t.Fatal(err)
+	}
+	if fake.schema == nil || fake.schema["class"] != "SreKnowledge" || fake.schema["vectorizer"] != "none" {
+		t.Fatalf("expected the class to be created without a vectorizer, got %v", fake.schema)
+	}
+	if err := client.EnsureCollection(ctx, 4); err != nil {
+		t.Fatalf("an existing class must be reused: %v", err)
+	}
+
+	points := []Point{
+		{ID: "runbook#1", Source: "runbook.md", Content: "Restart the pod.", Metadata: map[string]string{"vcluster": "team-a", "type": "runbook"}, Vector: []float32{1, 0, 0, 0}},
+		{ID: "runbook#2", Source: "runbook.md", Content: "Check the registry.", Vector: []float32{0, 1, 0, 0}},
+	}
+	if err := client.Upsert(ctx, points); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Upsert(ctx, points[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.objects) != 2 {
+		t.Fatalf("expected two objects, got %d", len(fake.objects))
+	}
+	object, ok := fake.objects["afbb1d23-fffe-5cf5-ba28-6c79bf412717"]
+	if !ok {
+		t.Fatalf("expected runbook#1 to be stored under its stable ID, got %v", fake.objects)
+	}
+	labels, _ := json.Marshal(object["properties"].(map[string]interface{})["labels"])
+	if string(labels) != `["businessService=","resourceKind=","type=runbook","vcluster=team-a"]` {
+		t.Fatalf("unexpected labels %s", labels)
+	}
+
+	hits, err := client.Search(ctx, []float32{1, 0, 0, 0}, 3, map[string]string{"vcluster": "team-a", "type": "runbook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].Score != 0.75 {
+		t.Fatalf("unexpected hits %+v", hits)
+	}
+	for _, want := range []string{
+		`SreKnowledge(nearVector: {vector: [1,0,0,0]}, limit: 3`,
+		`{operator: ContainsAll, path: ["labels"], valueText: ["type=runbook"]}`,
+		`{operator: ContainsAny, path: ["labels"], valueText: ["vcluster=team-a","vcluster="]}`,
+	} {
+		if !strings.Contains(fake.lastQuery, want) {
+			t.Errorf("the query does not contain %s:\n%s", want, fake.lastQuery)
+		}
+	}
+
+	if err := client.Delete(ctx, []string{"runbook#1", "unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects[pointID("runbook#2")]; len(fake.objects) != 1 || !ok {
+		t.Fatalf("expected only runbook#2 to remain, got %v", fake.objects)
+	}
+
+	unauthorized := NewWeaviateClient(server.URL, "sre-knowledge", "", 5*time.Second)
+	if err := unauthorized.Upsert(ctx, points); !errors.IsErrorCode(err, errors.ErrorCodeKnowledgeBaseError) || isNotFound(err) {
+		t.Fatalf("expected the request without the API key to be refused, got %v", err)
+	}
+}
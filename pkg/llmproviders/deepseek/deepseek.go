@@ -1,34 +1,32 @@
 package deepseek
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"time"
 
-	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
 	"github.com/turtacn/chasi-sreagent/pkg/common/log"
 	"github.com/turtacn/chasi-sreagent/pkg/common/types"
 	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/llmproviders/openaicompat"
 	"go.uber.org/zap"
 )
 
 // Package deepseek provides an LLM implementation for the DeepSeek API.
 // 包 deepseek 提供一个用于 DeepSeek API 的 LLM 实现。
 
-// DeepSeekProvider implements the llm.LLM interface for the DeepSeek API.
-// DeepSeekProvider 为 DeepSeek API 实现 llm.LLM 接口。
+// DeepSeekProvider implements the llm.ChatLLM interface for the DeepSeek API.
+// DeepSeekProvider 为 DeepSeek API 实现 llm.ChatLLM 接口。
 type DeepSeekProvider struct {
+	name   string
 	config *types.LLMProviderConfig
-	client *http.Client
+	chat   *openaicompat.Client
 }
 
-// Ensure DeepSeekProvider implements the llm.LLM interface.
-// 确保 DeepSeekProvider 实现了 llm.LLM 接口。
-var _ llm.LLM = &DeepSeekProvider{}
+// Ensure DeepSeekProvider implements the llm.ChatLLM interface.
+// 确保 DeepSeekProvider 实现了 llm.ChatLLM 接口。
+var _ llm.ChatLLM = &DeepSeekProvider{}
 
 // NewDeepSeekProvider creates a new DeepSeekProvider instance.
 // NewDeepSeekProvider 创建一个新的 DeepSeekProvider 实例。
@@ -37,24 +35,19 @@ func NewDeepSeekProvider(cfg *types.LLMProviderConfig, timeout time.Duration) (*
 		return nil, fmt.Errorf("deepseek provider configuration is incomplete (URL, Model, or APIKey missing)")
 	}
 
-	// Basic HTTP client
-	// 基本 HTTP 客户端
-	httpClient := &http.Client{
-		Timeout: timeout, // Use the global LLM timeout / 使用全局 LLM 超时时间
-	}
-
 	log.L().Info("Initialized DeepSeek LLM Provider", zap.String("url", cfg.URL), zap.String("model", cfg.Model))
 
 	return &DeepSeekProvider{
+		name:   constants.LLMProviderDeepSeek,
 		config: cfg,
-		client: httpClient,
+		chat:   openaicompat.NewClient(constants.LLMProviderDeepSeek, cfg, timeout),
 	}, nil
 }
 
 // Name returns the name of the LLM provider.
 // Name 返回 LLM 提供商的名称。
 func (p *DeepSeekProvider) Name() string {
-	return "deepseek" // Using the constant defined in common.types
+	return p.name
 }
 
 // Description returns a brief description.
@@ -63,115 +56,25 @@ func (p *DeepSeekProvider) Description() string {
 	return "Provides access to the DeepSeek API."
 }
 
-// GenerateText sends a chat completions request to the DeepSeek API.
-// GenerateText 向 DeepSeek API 发送一个 chat completions 请求。
-// DeepSeek primarily uses the chat completions endpoint.
-// DeepSeek 主要使用 chat completions 终点。
-// Assumes the prompt is a simple string and sends it as a user message.
-// 假设 prompt 是一个简单字符串，并将其作为用户消息发送。
+// GenerateText sends the prompt as a single user message and returns the answer.
+// GenerateText 将提示作为单条用户消息发送并返回回答。
+// It is kept for callers of the llm.LLM interface; new code should use Chat.
+// 保留此方法供 llm.LLM 接口的调用方使用; 新代码应使用 Chat。
 // Options map can be used to pass additional parameters like temperature, max_tokens.
 // Options map 可用于传递其他参数，如 temperature, max_tokens。
 func (p *DeepSeekProvider) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
-	logger := log.LWithContext(ctx).With(zap.String("llmProvider", p.Name()), zap.String("model", p.config.Model))
-	logger.Debug("Generating text with DeepSeek")
-
-	if p.client == nil {
-		return "", fmt.Errorf("deepseek client is not initialized")
-	}
-
-	// Construct the request body based on DeepSeek's API spec (OpenAI compatible chat completions)
-	// 根据 DeepSeek 的 API 规范构建请求体 (与 OpenAI 兼容的 chat completions)
-	requestBody := map[string]interface{}{
-		"model": p.config.Model,
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
-		"max_tokens":  500, // Default max tokens, can be overridden by options
-		"temperature": 0.7, // Default temperature, can be overridden by options
-		// Add other parameters from options
-		// 添加 options 中的其他参数
-	}
-
-	// Override defaults with options
-	// 使用 options 覆盖默认值
-	if options != nil {
-		for key, value := range options {
-			// Special handling for 'messages' if needed, but generally options apply to top level
-			// 如果需要特殊处理 'messages'，但通常 options 应用于顶级
-			if key != "messages" {
-				requestBody[key] = value
-			}
-		}
-	}
-
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		logger.Error("Failed to marshal request body", zap.Error(err))
-		return "", errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to marshal request body", err, "")
-	}
-
-	// DeepSeek chat completions endpoint
-	// DeepSeek chat completions 终点
-	reqURL := fmt.Sprintf("%s/chat/completions", p.config.URL)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		logger.Error("Failed to create HTTP request", zap.Error(err))
-		return "", errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to create http request", err, "")
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	// DeepSeek requires API Key in Authorization header
-	// DeepSeek 需要在 Authorization header 中包含 API Key
-	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		logger.Error("HTTP request failed", zap.Error(err))
-		return "", errors.Wrap(errors.ErrorCodeLLMProviderError, "http request failed", err, "")
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Error("Failed to read response body", zap.Error(err))
-		return "", errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to read response body", err, "")
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("API returned non-200 status", zap.Int("statusCode", resp.StatusCode), zap.ByteString("body", bodyBytes))
-		return "", errors.New(errors.ErrorCodeLLMProviderError, fmt.Sprintf("API returned status %d", resp.StatusCode), string(bodyBytes))
-	}
-
-	// Parse the response body (OpenAI chat completions format)
-	// 解析响应体 (OpenAI chat completions 格式)
-	var response map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &response); err != nil {
-		logger.Error("Failed to unmarshal response body", zap.Error(err), zap.ByteString("body", bodyBytes))
-		return "", errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to unmarshal response body", err, string(bodyBytes))
-	}
+	return llm.GenerateTextViaChat(ctx, p, prompt, options)
+}
 
-	// Extract the generated text: choices[0].message.content
-	// 提取生成的文本: choices[0].message.content
-	choices, ok := response["choices"].([]interface{})
-	if !ok || len(choices) == 0 {
-		return "", errors.New(errors.ErrorCodeLLMProviderError, "no choices found in LLM response", string(bodyBytes))
-	}
-	firstChoice, ok := choices[0].(map[string]interface{})
-	if !ok {
-		return "", errors.New(errors.ErrorCodeLLMProviderError, "invalid choice format in LLM response", string(bodyBytes))
-	}
-	message, msgOK := firstChoice["message"].(map[string]interface{})
-	if !msgOK {
-		return "", errors.New(errors.ErrorCodeLLMProviderError, "message field not found in LLM response", string(bodyBytes))
-	}
-	generatedText, ok := message["content"].(string)
-	if !ok {
-		return "", errors.New(errors.ErrorCodeLLMProviderError, "content field not found in message in LLM response", string(bodyBytes))
+// Chat sends a conversation to the DeepSeek chat completions endpoint.
+// Chat 将对话发送到 DeepSeek chat completions 终点。
+// The configured max tokens apply unless the request sets its own limit.
+// 除非请求设置了自身上限，否则使用配置的最大 token 数。
+func (p *DeepSeekProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	if p.chat == nil {
+		return nil, fmt.Errorf("deepseek client is not initialized")
 	}
-
-	logger.Debug("Text generated successfully")
-	return generatedText, nil
+	return p.chat.ChatCompletion(ctx, p.config.Model, llm.MaxOutputTokensFor(*p.config), req)
 }
 
 // // Placeholder for EmbedText if needed (DeepSeek has embedding models)
@@ -188,13 +91,18 @@ func (p *DeepSeekProvider) GenerateText(ctx context.Context, prompt string, opti
 // Register the LLM provider with the global registry.
 // 在全局注册表中注册 LLM 提供商。
 func init() {
-	// This stateful provider needs configuration and a timeout
-	// before registration. We will rely on the engine initialization to create and register it.
-	// 这个有状态的提供商在注册之前需要配置和超时时间。
-	// 我们将依赖于引擎初始化来创建和注册它。
-
-	// llm.RegisterLLMProvider(&DeepSeekProvider{}) // Cannot register like this
-	log.L().Debug("DeepSeek LLM provider init() called, will be registered during engine setup.")
+	// This stateful provider needs configuration and a timeout before registration, so only its
+	// factory is registered here. The instance is created and registered during engine setup.
+	// 这个有状态的提供商在注册之前需要配置和超时时间，因此这里只注册其工厂。实例在引擎初始化期间创建并注册。
+	llm.RegisterLLMProviderFactory(constants.LLMProviderDeepSeek, func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (llm.LLM, error) {
+		provider, err := NewDeepSeekProvider(cfg, timeout)
+		if err != nil {
+			return nil, err
+		}
+		provider.name = name
+		return provider, nil
+	})
+	log.L().Debug("DeepSeek LLM provider factory registered")
 }
 
 // Global instance placeholder, will be initialized in main.
@@ -0,0 +1,86 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+func TestNewDeepSeekProviderIncomplete(t *testing.T) {
+	for _, cfg := range []*types.LLMProviderConfig{
+		nil,
+		{Model: "deepseek-chat", APIKey: "key"},
+		{URL: "https://api.deepseek.com", APIKey: "key"},
+		{URL: "https://api.deepseek.com", Model: "deepseek-chat"},
+	} {
+		if _, err := NewDeepSeekProvider(cfg, time.Second); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestDeepSeekChat(t *testing.T) {
+	var body map[string]interface{}
+	var r *http.Request
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r = req
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"model": "deepseek-chat", "choices": [{"message": {"role": "assistant", "content": "", "tool_calls": [
+			{"id": "call_0", "type": "function", "function": {"name": "list_events", "arguments": "{}"}}
+		]}, "finish_reason": "tool_calls"}], "usage": {"prompt_tokens": 7, "completion_tokens": 3, "total_tokens": 10}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewDeepSeekProvider(&types.LLMProviderConfig{URL: server.URL, Model: "deepseek-chat", APIKey: "sk-test"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := provider.Chat(context.Background(), &llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Why?"}},
+		Tools:    []llm.ToolDefinition{{Name: "list_events"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0].Name != "list_events" || resp.FinishReason != llm.FinishReasonToolCalls || resp.Usage.TotalTokens != 10 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+		t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+	}
+	if body["model"] != "deepseek-chat" || body["max_tokens"] != float64(constants.DefaultLLMMaxTokens) || body["tools"] == nil {
+		t.Errorf("unexpected request body %v", body)
+	}
+
+	status = http.StatusTooManyRequests
+	if _, err := provider.GenerateText(context.Background(), "Why?", nil); !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) || !llm.IsTransient(err) {
+		t.Errorf("expected a transient provider error, got %v", err)
+	}
+}
+
+func TestDeepSeekFactory(t *testing.T) {
+	provider, err := llm.NewLLMProvider(constants.LLMProviderDeepSeek, &types.LLMConfig{
+		DeepSeek: types.LLMProviderConfig{URL: "https://api.deepseek.com", Model: "deepseek-chat", APIKey: "sk-test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.(*DeepSeekProvider); !ok || provider.Name() != constants.LLMProviderDeepSeek {
+		t.Errorf("got %T named %q", provider, provider.Name())
+	}
+	if _, err := llm.NewLLMProvider(constants.LLMProviderDeepSeek, &types.LLMConfig{}); !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) {
+		t.Errorf("expected an error without an API key, got %v", err)
+	}
+}
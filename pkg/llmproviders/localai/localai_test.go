@@ -0,0 +1,114 @@
+package localai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+// fakeLocalAI serves the chat completions and embeddings endpoints and records the last request.
+func fakeLocalAI(t *testing.T) (*httptest.Server, *map[string]interface{}, *string) {
+	var body map[string]interface{}
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("%s: invalid request body: %v", r.URL.Path, err)
+		}
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "out of memory"}, "finish_reason": "stop"}], "usage": {"total_tokens": 42}}`))
+		case "/v1/embeddings":
+			_, _ = w.Write([]byte(`{"data": [{"index": 0, "embedding": [0.5, 0.5]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &body, &path
+}
+
+func TestNewLocalAIProviderIncomplete(t *testing.T) {
+	for _, cfg := range []*types.LLMProviderConfig{nil, {Model: "llama"}, {URL: "http://localai:8080/v1"}} {
+		if _, err := NewLocalAIProvider(cfg, time.Second); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestLocalAIChat(t *testing.T) {
+	server, body, path := fakeLocalAI(t)
+	provider, err := NewLocalAIProvider(&types.LLMProviderConfig{URL: server.URL + "/v1", Model: "llama", MaxTokens: 300}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := provider.Chat(context.Background(), &llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: "Why?"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message.Content != "out of memory" || resp.Usage.TotalTokens != 42 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if *path != "/v1/chat/completions" || (*body)["model"] != "llama" || (*body)["max_tokens"] != float64(300) {
+		t.Errorf("unexpected request %s %v", *path, *body)
+	}
+
+	text, err := provider.GenerateText(context.Background(), "Why?", map[string]interface{}{"max_tokens": 10})
+	if err != nil || text != "out of memory" {
+		t.Fatalf("got %q, %v", text, err)
+	}
+	if (*body)["max_tokens"] != float64(10) {
+		t.Errorf("options must override the configured limit, got %v", (*body)["max_tokens"])
+	}
+}
+
+func TestLocalAIEmbed(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		embeddingModel string
+		want           string
+	}{
+		{name: "embedding model", embeddingModel: "nomic-embed", want: "nomic-embed"},
+		{name: "chat model", want: "llama"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, body, path := fakeLocalAI(t)
+			provider, err := NewLocalAIProvider(&types.LLMProviderConfig{URL: server.URL + "/v1", Model: "llama", EmbeddingModel: tc.embeddingModel}, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := provider.Embed(context.Background(), []string{"pod restarts"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, [][]float32{{0.5, 0.5}}) {
+				t.Errorf("unexpected embeddings %v", got)
+			}
+			if *path != "/v1/embeddings" || (*body)["model"] != tc.want {
+				t.Errorf("unexpected request %s %v", *path, *body)
+			}
+		})
+	}
+}
+
+func TestLocalAIFactory(t *testing.T) {
+	provider, err := llm.NewLLMProvider("local-fast", &types.LLMConfig{
+		Providers: map[string]types.LLMProviderConfig{"local-fast": {Type: constants.LLMProviderLocalAI, URL: "http://localai:8080/v1", Model: "llama"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.(*LocalAIProvider); !ok || provider.Name() != "local-fast" {
+		t.Errorf("got %T named %q", provider, provider.Name())
+	}
+}
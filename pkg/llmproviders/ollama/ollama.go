@@ -0,0 +1,406 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/embedding"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"go.uber.org/zap"
+)
+
+// Package ollama provides an LLM implementation using Ollama's native API.
+// 包 ollama 提供一个使用 Ollama 原生 API 的 LLM 实现。
+
+// OllamaProvider implements the llm.ChatLLM interface for the native Ollama API.
+// OllamaProvider 为原生 Ollama API 实现 llm.ChatLLM 接口。
+type OllamaProvider struct {
+	name   string
+	config *types.LLMProviderConfig
+	client *http.Client
+	// pullClient has no client timeout because downloading a model can take a long time; each
+	// pull is bounded by the pull timeout instead.
+	// pullClient 没有客户端超时时间，因为下载模型可能需要很长时间; 每次拉取改由拉取超时时间限制。
+	pullClient *http.Client
+}
+
+// Ensure OllamaProvider implements the llm.ChatLLM interface.
+// 确保 OllamaProvider 实现了 llm.ChatLLM 接口。
+var _ llm.ChatLLM = &OllamaProvider{}
+
+// Ensure OllamaProvider implements the embedding.Backend interface.
+// 确保 OllamaProvider 实现了 embedding.Backend 接口。
+var _ embedding.Backend = &OllamaProvider{}
+
+// NewOllamaProvider creates a new OllamaProvider instance with the given name.
+// NewOllamaProvider 创建一个具有给定名称的新 OllamaProvider 实例。
+func NewOllamaProvider(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (*OllamaProvider, error) {
+	if cfg == nil || cfg.Model == "" {
+		return nil, fmt.Errorf("ollama provider configuration is incomplete (Model missing)")
+	}
+	resolved := *cfg
+	if resolved.URL == "" {
+		resolved.URL = constants.DefaultOllamaURL
+	}
+	resolved.URL = strings.TrimSuffix(resolved.URL, "/")
+
+	log.L().Info("Initialized Ollama LLM Provider", zap.String("name", name), zap.String("url", resolved.URL), zap.String("model", resolved.Model), zap.String("keepAlive", resolved.KeepAlive))
+
+	return &OllamaProvider{
+		name:       name,
+		config:     &resolved,
+		client:     &http.Client{Timeout: timeout}, // Use the global LLM timeout / 使用全局 LLM 超时时间
+		pullClient: &http.Client{},
+	}, nil
+}
+
+// Name returns the name of the LLM provider instance.
+// Name 返回 LLM 提供商实例的名称。
+func (p *OllamaProvider) Name() string {
+	return p.name
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (p *OllamaProvider) Description() string {
+	return "Provides access to models served by Ollama through its native API."
+}
+
+// ollamaMessage is a chat message in the Ollama format.
+// ollamaMessage 是 Ollama 格式的聊天消息。
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// ollamaToolCall is a tool call in the Ollama format; unlike OpenAI, arguments are a JSON object.
+// ollamaToolCall 是 Ollama 格式的工具调用; 与 OpenAI 不同，参数是 JSON 对象。
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatResponse is the subset of the /api/chat and /api/generate responses the provider reads.
+// chatResponse 是提供商读取的 /api/chat 和 /api/generate 响应子集。
+type chatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Response        string        `json:"response"` // Set by /api/generate / 由 /api/generate 设置
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// GenerateText sends a raw prompt to the /api/generate endpoint.
+// GenerateText 将原始提示发送到 /api/generate 终点。
+// Options map can be used to pass additional parameters like temperature, max_tokens.
+// Options map 可用于传递其他参数，如 temperature, max_tokens。
+func (p *OllamaProvider) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
+	body := p.requestBody(0, nil, nil, options)
+	body["prompt"] = prompt
+
+	resp, err := p.post(ctx, "/api/generate", body)
+	if err != nil {
+		return "", err
+	}
+	return resp.Response, nil
+}
+
+// Chat sends a conversation to the /api/chat endpoint.
+// Chat 将对话发送到 /api/chat 终点。
+func (p *OllamaProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	messages := make([]ollamaMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		wire := ollamaMessage{Role: string(msg.Role), Content: msg.Content}
+		if msg.Role == llm.RoleTool {
+			wire.ToolName = msg.Name
+		}
+		for _, call := range msg.ToolCalls {
+			var wireCall ollamaToolCall
+			wireCall.Function.Name = call.Name
+			_ = json.Unmarshal([]byte(call.Arguments), &wireCall.Function.Arguments)
+			wire.ToolCalls = append(wire.ToolCalls, wireCall)
+		}
+		messages = append(messages, wire)
+	}
+
+	body := p.requestBody(req.MaxTokens, req.Temperature, req.Stop, req.Options)
+	body["messages"] = messages
+	if req.ResponseFormat == llm.ResponseFormatJSON {
+		body["format"] = "json"
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(req.Tools))
+		for _, def := range req.Tools {
+			tools = append(tools, map[string]interface{}{"type": "function", "function": def})
+		}
+		body["tools"] = tools
+	}
+
+	resp, err := p.post(ctx, "/api/chat", body)
+	if err != nil {
+		return nil, err
+	}
+
+	message := llm.Message{Role: llm.RoleAssistant, Content: resp.Message.Content}
+	for i, call := range resp.Message.ToolCalls {
+		args, _ := json.Marshal(call.Function.Arguments)
+		// Ollama does not assign call IDs, so they are numbered per response.
+		// Ollama 不分配调用 ID，因此按响应内顺序编号。
+		message.ToolCalls = append(message.ToolCalls, llm.ToolCall{ID: fmt.Sprintf("call_%d", i+1), Name: call.Function.Name, Arguments: string(args)})
+	}
+	finish := llm.FinishReason(resp.DoneReason)
+	if len(message.ToolCalls) > 0 {
+		finish = llm.FinishReasonToolCalls
+	}
+	return &llm.ChatResponse{
+		Message:      message,
+		FinishReason: finish,
+		Usage: llm.Usage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+		Model: resp.Model,
+	}, nil
+}
+
+// Embed returns the embeddings of the texts using the /api/embed endpoint.
+// Embed 使用 /api/embed 终点返回文本的 embedding。
+func (p *OllamaProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	model := p.config.EmbeddingModel
+	if model == "" {
+		model = p.config.Model
+	}
+	body := map[string]interface{}{"model": model, "input": texts}
+	if p.config.KeepAlive != "" {
+		body["keep_alive"] = p.config.KeepAlive
+	}
+
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := p.do(ctx, p.client, http.MethodPost, "/api/embed", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, errors.New(errors.ErrorCodeLLMProviderError, "unexpected number of embeddings", fmt.Sprintf("requested %d, received %d", len(texts), len(resp.Embeddings)))
+	}
+	return resp.Embeddings, nil
+}
+
+// EnsureModels checks that the chat and embedding models are available on the server and pulls
+// missing ones if autoPull is enabled. A missing model is reported with ErrorCodeNotFound.
+// Each pull is bounded by the configured pull timeout.
+// EnsureModels 检查聊天和 embedding 模型是否在服务端可用，如果启用了 autoPull 则拉取缺失的模型。
+// 缺失的模型以 ErrorCodeNotFound 报告。每次拉取都受配置的拉取超时时间限制。
+func (p *OllamaProvider) EnsureModels(ctx context.Context) error {
+	logger := log.LWithContext(ctx).With(zap.String("llmProvider", p.name))
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := p.do(ctx, p.client, http.MethodGet, "/api/tags", nil, &tags); err != nil {
+		return err
+	}
+	available := make(map[string]bool, len(tags.Models))
+	for _, model := range tags.Models {
+		available[model.Name] = true
+	}
+
+	models := []string{p.config.Model}
+	if p.config.EmbeddingModel != "" && p.config.EmbeddingModel != p.config.Model {
+		models = append(models, p.config.EmbeddingModel)
+	}
+	for _, model := range models {
+		if hasModel(available, model) {
+			continue
+		}
+		if !p.config.AutoPull {
+			return errors.New(errors.ErrorCodeNotFound, "Ollama model not available", fmt.Sprintf("model '%s' is not present on %s and autoPull is disabled", model, p.config.URL))
+		}
+		logger.Info("Pulling missing Ollama model", zap.String("model", model))
+		start := time.Now()
+		var status struct {
+			Status string `json:"status"`
+		}
+		pullCtx, cancel := context.WithTimeout(ctx, p.pullTimeout())
+		err := p.do(pullCtx, p.pullClient, http.MethodPost, "/api/pull", map[string]interface{}{"model": model, "stream": false}, &status)
+		cancel()
+		if err != nil {
+			return err
+		}
+		logger.Info("Pulled Ollama model", zap.String("model", model), zap.String("status", status.Status), zap.Duration("duration", time.Since(start)))
+	}
+	return nil
+}
+
+// pullTimeout returns the configured pull timeout or the default.
+// pullTimeout 返回配置的拉取超时时间或默认值。
+func (p *OllamaProvider) pullTimeout() time.Duration {
+	if p.config.PullTimeout > 0 {
+		return p.config.PullTimeout
+	}
+	return constants.DefaultOllamaPullTimeout * time.Second
+}
+
+// hasModel reports whether a model is in the list; a name without a tag matches its ":latest" tag.
+// hasModel 报告模型是否在列表中; 不带标签的名称匹配其 ":latest" 标签。
+func hasModel(available map[string]bool, model string) bool {
+	if available[model] {
+		return true
+	}
+	return !strings.Contains(model, ":") && available[model+":latest"]
+}
+
+// requestBody builds the fields shared by /api/chat and /api/generate.
+// requestBody 构建 /api/chat 和 /api/generate 共用的字段。
+// Generation parameters go into Ollama's options object; OpenAI-style names used by other callers are translated.
+// 生成参数放入 Ollama 的 options 对象; 其他调用方使用的 OpenAI 风格名称会被转换。
+func (p *OllamaProvider) requestBody(maxTokens int, temperature *float64, stop []string, extra map[string]interface{}) map[string]interface{} {
+	if maxTokens <= 0 {
+		maxTokens = llm.MaxOutputTokensFor(*p.config)
+	}
+	options := map[string]interface{}{
+		"num_ctx":     llm.ContextWindowFor(*p.config),
+		"num_predict": maxTokens,
+	}
+	if temperature != nil {
+		options["temperature"] = *temperature
+	}
+	if len(stop) > 0 {
+		options["stop"] = stop
+	}
+
+	body := map[string]interface{}{
+		"model":  p.config.Model,
+		"stream": false,
+	}
+	if p.config.KeepAlive != "" {
+		body["keep_alive"] = p.config.KeepAlive
+	}
+	for key, value := range extra {
+		switch key {
+		case "keep_alive", "format":
+			body[key] = value
+		case "max_tokens":
+			options["num_predict"] = value
+		case "messages", "prompt", "model", "stream":
+			// Owned by the request / 由请求本身决定
+		default:
+			options[key] = value
+		}
+	}
+	body["options"] = options
+	return body
+}
+
+// post sends a generation request and logs its outcome.
+// post 发送生成请求并记录其结果。
+func (p *OllamaProvider) post(ctx context.Context, path string, body map[string]interface{}) (*chatResponse, error) {
+	logger := log.LWithContext(ctx).With(zap.String("llmProvider", p.name), zap.String("model", p.config.Model))
+	logger.Debug("Sending request to Ollama", zap.String("path", path))
+
+	var resp chatResponse
+	if err := p.do(ctx, p.client, http.MethodPost, path, body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.DoneReason == string(llm.FinishReasonLength) {
+		logger.Warn("LLM answer was cut off by the token limit", zap.Int("completionTokens", resp.EvalCount))
+	}
+	logger.Debug("Ollama response received", zap.String("doneReason", resp.DoneReason), zap.Int("promptTokens", resp.PromptEvalCount), zap.Int("completionTokens", resp.EvalCount))
+	return &resp, nil
+}
+
+// do performs a JSON request against the Ollama API and decodes the response into out.
+// do 对 Ollama API 执行 JSON 请求，并将响应解码到 out 中。
+func (p *OllamaProvider) do(ctx context.Context, client *http.Client, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to marshal request body", err, path)
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.config.URL+path, reader)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to create http request", err, path)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.config.APIKey != "" {
+		// Ollama itself has no authentication, but it is often placed behind an authenticating proxy.
+		// Ollama 本身没有认证，但常部署在带认证的代理之后。
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+	for key, value := range p.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeLLMProviderError, "http request failed", err, path)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := llm.ReadResponseBody(resp.Body)
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to read response body", err, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Wrap(errors.ErrorCodeLLMProviderError, fmt.Sprintf("API returned status %d", resp.StatusCode), llm.NewStatusError(resp, bodyBytes), llm.BodyExcerpt(bodyBytes))
+	}
+	if err := json.Unmarshal(bodyBytes, out); err != nil {
+		return errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to unmarshal response body", err, llm.BodyExcerpt(bodyBytes))
+	}
+	return nil
+}
+
+// Register the provider factory with the global registry.
+// 在全局注册表中注册提供商工厂。
+func init() {
+	// Instances need configuration and a reachable server, so only the factory is registered here.
+	// The created instance is registered with llm.RegisterLLMProvider during engine setup.
+	// 实例需要配置和可访问的服务端，因此这里只注册工厂。创建的实例在引擎初始化期间通过 llm.RegisterLLMProvider 注册。
+	llm.RegisterLLMProviderFactory(constants.LLMProviderOllama, func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (llm.LLM, error) {
+		provider, err := NewOllamaProvider(name, cfg, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		// Check the models at startup so that a missing model is reported before the first diagnosis.
+		// 在启动时检查模型，以便在首次诊断之前报告缺失的模型。
+		if err := provider.EnsureModels(context.Background()); err != nil {
+			if errors.IsErrorCode(err, errors.ErrorCodeNotFound) {
+				return nil, err
+			}
+			// An unreachable server may still come up later; only a missing model is fatal.
+			// 无法访问的服务端之后仍可能启动; 只有缺失模型才是致命错误。
+			log.L().Warn("Could not check Ollama models", zap.String("name", name), zap.Error(err))
+		}
+		return provider, nil
+	})
+	embedding.RegisterBackendFactory(constants.LLMProviderOllama, func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (embedding.Backend, error) {
+		return NewOllamaProvider(name, cfg, timeout)
+	})
+	log.L().Debug("Ollama LLM provider factory registered")
+}
@@ -0,0 +1,377 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+// fakeOllama serves the native Ollama API endpoints used by OllamaProvider and records the requests.
+type fakeOllama struct {
+	t      *testing.T
+	models []string
+	reply  map[string]interface{} // Response of /api/chat and /api/generate
+	status int                    // Status of every response; 0 means 200
+	hang   bool                   // Pulls hang until the client gives up
+
+	mu       sync.Mutex
+	requests map[string][]map[string]interface{}
+	headers  http.Header
+}
+
+func newFakeOllama(t *testing.T, models ...string) (*fakeOllama, *httptest.Server) {
+	fake := &fakeOllama{t: t, models: models, requests: map[string][]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.hang && r.URL.Path == "/api/pull" {
+		// The server only notices the client going away once the body is read.
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body map[string]interface{}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("%s: invalid request body: %v", r.URL.Path, err)
+		}
+	}
+	f.requests[r.URL.Path] = append(f.requests[r.URL.Path], body)
+	f.headers = r.Header.Clone()
+	if f.status != 0 {
+		http.Error(w, `{"error":"model runner crashed"}`, f.status)
+		return
+	}
+
+	reply := func(v interface{}) { _ = json.NewEncoder(w).Encode(v) }
+	switch r.URL.Path {
+	case "/api/tags":
+		var models []map[string]string
+		for _, name := range f.models {
+			models = append(models, map[string]string{"name": name})
+		}
+		reply(map[string]interface{}{"models": models})
+	case "/api/pull":
+		f.models = append(f.models, body["model"].(string))
+		reply(map[string]string{"status": "success"})
+	case "/api/embed":
+		var embeddings [][]float32
+		for i := range body["input"].([]interface{}) {
+			embeddings = append(embeddings, []float32{float32(i), 1})
+		}
+		reply(map[string]interface{}{"embeddings": embeddings})
+	case "/api/chat", "/api/generate":
+		reply(f.reply)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeOllama) last(path string) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests[path]
+	if len(requests) == 0 {
+		f.t.Fatalf("no request to %s", path)
+	}
+	return requests[len(requests)-1]
+}
+
+func (f *fakeOllama) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests[path])
+}
+
+func newProvider(t *testing.T, cfg types.LLMProviderConfig) *OllamaProvider {
+	t.Helper()
+	provider, err := NewOllamaProvider("ollama-test", &cfg, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestNewOllamaProvider(t *testing.T) {
+	if _, err := NewOllamaProvider("ollama-test", &types.LLMProviderConfig{}, time.Second); err == nil {
+		t.Error("expected an error without a model")
+	}
+	if _, err := NewOllamaProvider("ollama-test", nil, time.Second); err == nil {
+		t.Error("expected an error without a configuration")
+	}
+	if got := newProvider(t, types.LLMProviderConfig{Model: "llama3"}).config.URL; got != constants.DefaultOllamaURL {
+		t.Errorf("URL = %q, want the default %q", got, constants.DefaultOllamaURL)
+	}
+	if got := newProvider(t, types.LLMProviderConfig{Model: "llama3", URL: "http://gpu-0:11434/"}).config.URL; got != "http://gpu-0:11434" {
+		t.Errorf("URL = %q, want the trailing slash trimmed", got)
+	}
+}
+
+func TestRequestBody(t *testing.T) {
+	temperature := 0.2
+	for _, tc := range []struct {
+		name        string
+		cfg         types.LLMProviderConfig
+		maxTokens   int
+		temperature *float64
+		stop        []string
+		extra       map[string]interface{}
+		wantBody    map[string]interface{}
+		wantOptions map[string]interface{}
+	}{
+		{
+			name:        "defaults",
+			cfg:         types.LLMProviderConfig{Model: "llama3", ContextWindow: 8192},
+			wantBody:    map[string]interface{}{"model": "llama3", "stream": false},
+			wantOptions: map[string]interface{}{"num_ctx": 8192, "num_predict": constants.DefaultLLMMaxTokens},
+		},
+		{
+			name:        "request settings",
+			cfg:         types.LLMProviderConfig{Model: "llama3", ContextWindow: 8192, MaxTokens: 512, KeepAlive: "10m"},
+			maxTokens:   256,
+			temperature: &temperature,
+			stop:        []string{"\n\n"},
+			wantBody:    map[string]interface{}{"model": "llama3", "stream": false, "keep_alive": "10m"},
+			wantOptions: map[string]interface{}{"num_ctx": 8192, "num_predict": 256, "temperature": 0.2, "stop": []string{"\n\n"}},
+		},
+		{
+			name: "openai style options are translated",
+			cfg:  types.LLMProviderConfig{Model: "llama3", ContextWindow: 8192, MaxTokens: 512},
+			extra: map[string]interface{}{
+				"max_tokens": 64, "keep_alive": "-1", "format": "json", "top_p": 0.9,
+				"model": "other", "stream": true, "prompt": "ignored",
+			},
+			wantBody:    map[string]interface{}{"model": "llama3", "stream": false, "keep_alive": "-1", "format": "json"},
+			wantOptions: map[string]interface{}{"num_ctx": 8192, "num_predict": 64, "top_p": 0.9},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body := newProvider(t, tc.cfg).requestBody(tc.maxTokens, tc.temperature, tc.stop, tc.extra)
+			options := body["options"]
+			delete(body, "options")
+			if !reflect.DeepEqual(body, tc.wantBody) {
+				t.Errorf("body = %v, want %v", body, tc.wantBody)
+			}
+			if !reflect.DeepEqual(options, tc.wantOptions) {
+				t.Errorf("options = %v, want %v", options, tc.wantOptions)
+			}
+		})
+	}
+}
+
+func TestChat(t *testing.T) {
+	fake, server := newFakeOllama(t)
+	fake.reply = map[string]interface{}{
+		"model": "llama3",
+		"message": map[string]interface{}{
+			"role": "assistant",
+			"tool_calls": []interface{}{
+				map[string]interface{}{"function": map[string]interface{}{"name": "pod_logs", "arguments": map[string]interface{}{"pod": "web-0"}}},
+				map[string]interface{}{"function": map[string]interface{}{"name": "pod_events", "arguments": map[string]interface{}{"pod": "web-0"}}},
+			},
+		},
+		"done_reason":       "stop",
+		"prompt_eval_count": 120,
+		"eval_count":        30,
+	}
+	provider := newProvider(t, types.LLMProviderConfig{Model: "llama3", URL: server.URL, APIKey: "proxy-key", Headers: map[string]string{"X-Tenant": "sre"}})
+
+	resp, err := provider.Chat(context.Background(), &llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: "diagnose web-0"},
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "pod_logs", Arguments: `{"pod":"web-0"}`}}},
+			{Role: llm.RoleTool, Name: "pod_logs", ToolCallID: "call_1", Content: "OOMKilled"},
+		},
+		Tools:          []llm.ToolDefinition{{Name: "pod_logs", Description: "Reads pod logs"}},
+		ResponseFormat: llm.ResponseFormatJSON,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &llm.ChatResponse{
+		Message: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{
+			{ID: "call_1", Name: "pod_logs", Arguments: `{"pod":"web-0"}`},
+			{ID: "call_2", Name: "pod_events", Arguments: `{"pod":"web-0"}`},
+		}},
+		FinishReason: llm.FinishReasonToolCalls,
+		Usage:        llm.Usage{PromptTokens: 120, CompletionTokens: 30, TotalTokens: 150},
+		Model:        "llama3",
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("Chat\n got %+v\nwant %+v", resp, want)
+	}
+
+	sent := fake.last("/api/chat")
+	if sent["format"] != "json" || sent["stream"] != false {
+		t.Errorf("format = %v, stream = %v", sent["format"], sent["stream"])
+	}
+	messages := sent["messages"].([]interface{})
+	call := messages[1].(map[string]interface{})["tool_calls"].([]interface{})[0].(map[string]interface{})["function"].(map[string]interface{})
+	if !reflect.DeepEqual(call["arguments"], map[string]interface{}{"pod": "web-0"}) {
+		t.Errorf("tool call arguments must be sent as an object, got %#v", call["arguments"])
+	}
+	if name := messages[2].(map[string]interface{})["tool_name"]; name != "pod_logs" {
+		t.Errorf("tool_name = %v, want pod_logs", name)
+	}
+	tool := sent["tools"].([]interface{})[0].(map[string]interface{})
+	if tool["type"] != "function" || tool["function"].(map[string]interface{})["name"] != "pod_logs" {
+		t.Errorf("unexpected tool definition %v", tool)
+	}
+	if got := fake.headers.Get("Authorization"); got != "Bearer proxy-key" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := fake.headers.Get("X-Tenant"); got != "sre" {
+		t.Errorf("X-Tenant = %q", got)
+	}
+}
+
+func TestChatFinishReason(t *testing.T) {
+	fake, server := newFakeOllama(t)
+	provider := newProvider(t, types.LLMProviderConfig{Model: "llama3", URL: server.URL})
+	for _, reason := range []llm.FinishReason{llm.FinishReasonStop, llm.FinishReasonLength} {
+		fake.reply = map[string]interface{}{"message": map[string]interface{}{"role": "assistant", "content": "Root Cause: OOM"}, "done_reason": string(reason)}
+		resp, err := provider.Chat(context.Background(), &llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: "diagnose"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.FinishReason != reason || resp.Message.Content != "Root Cause: OOM" {
+			t.Errorf("got %q / %q, want %q", resp.FinishReason, resp.Message.Content, reason)
+		}
+	}
+	if _, ok := fake.last("/api/chat")["tools"]; ok {
+		t.Error("tools must be omitted when none are offered")
+	}
+}
+
+func TestGenerateText(t *testing.T) {
+	fake, server := newFakeOllama(t)
+	fake.reply = map[string]interface{}{"response": "Root Cause: OOM", "done_reason": "stop"}
+	provider := newProvider(t, types.LLMProviderConfig{Model: "llama3", URL: server.URL})
+
+	got, err := provider.GenerateText(context.Background(), "diagnose web-0", map[string]interface{}{"max_tokens": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Root Cause: OOM" {
+		t.Errorf("GenerateText = %q", got)
+	}
+	sent := fake.last("/api/generate")
+	if sent["prompt"] != "diagnose web-0" {
+		t.Errorf("prompt = %v", sent["prompt"])
+	}
+	if predict := sent["options"].(map[string]interface{})["num_predict"]; predict != float64(100) {
+		t.Errorf("num_predict = %v, want 100", predict)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	fake, server := newFakeOllama(t)
+	for _, tc := range []struct {
+		name      string
+		cfg       types.LLMProviderConfig
+		wantModel string
+	}{
+		{name: "chat model", cfg: types.LLMProviderConfig{Model: "llama3"}, wantModel: "llama3"},
+		{name: "embedding model", cfg: types.LLMProviderConfig{Model: "llama3", EmbeddingModel: "nomic-embed-text", KeepAlive: "5m"}, wantModel: "nomic-embed-text"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.URL = server.URL
+			vectors, err := newProvider(t, tc.cfg).Embed(context.Background(), []string{"a", "b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := [][]float32{{0, 1}, {1, 1}}; !reflect.DeepEqual(vectors, want) {
+				t.Errorf("Embed = %v, want %v", vectors, want)
+			}
+			sent := fake.last("/api/embed")
+			if sent["model"] != tc.wantModel {
+				t.Errorf("model = %v, want %s", sent["model"], tc.wantModel)
+			}
+			if keepAlive, ok := sent["keep_alive"]; ok != (tc.cfg.KeepAlive != "") || (ok && keepAlive != tc.cfg.KeepAlive) {
+				t.Errorf("keep_alive = %v, want %q", keepAlive, tc.cfg.KeepAlive)
+			}
+		})
+	}
+}
+
+func TestEnsureModels(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		available []string
+		cfg       types.LLMProviderConfig
+		wantPulls int
+		wantCode  errors.ErrorCode // Empty means success
+	}{
+		{name: "present", available: []string{"llama3:8b"}, cfg: types.LLMProviderConfig{Model: "llama3:8b"}},
+		{name: "untagged name matches latest", available: []string{"llama3:latest", "nomic-embed-text:latest"}, cfg: types.LLMProviderConfig{Model: "llama3", EmbeddingModel: "nomic-embed-text"}},
+		{name: "missing without auto pull", available: []string{"llama3:latest"}, cfg: types.LLMProviderConfig{Model: "llama3:70b"}, wantCode: errors.ErrorCodeNotFound},
+		{name: "missing embedding model", available: []string{"llama3:latest"}, cfg: types.LLMProviderConfig{Model: "llama3", EmbeddingModel: "nomic-embed-text"}, wantCode: errors.ErrorCodeNotFound},
+		{name: "auto pull", cfg: types.LLMProviderConfig{Model: "llama3", EmbeddingModel: "nomic-embed-text", AutoPull: true}, wantPulls: 2},
+		{name: "same model pulled once", cfg: types.LLMProviderConfig{Model: "llama3", EmbeddingModel: "llama3", AutoPull: true}, wantPulls: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, server := newFakeOllama(t, tc.available...)
+			tc.cfg.URL = server.URL
+			err := newProvider(t, tc.cfg).EnsureModels(context.Background())
+			if tc.wantCode == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantCode != "" && !errors.IsErrorCode(err, tc.wantCode) {
+				t.Fatalf("err = %v, want code %s", err, tc.wantCode)
+			}
+			if pulls := fake.count("/api/pull"); pulls != tc.wantPulls {
+				t.Errorf("pulled %d models, want %d", pulls, tc.wantPulls)
+			}
+		})
+	}
+}
+
+func TestEnsureModelsPullTimeout(t *testing.T) {
+	fake, server := newFakeOllama(t)
+	fake.hang = true
+	provider := newProvider(t, types.LLMProviderConfig{Model: "llama3", URL: server.URL, AutoPull: true, PullTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	err := provider.EnsureModels(context.Background())
+	if !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) {
+		t.Fatalf("err = %v, want an LLM provider error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the pull was not bounded by the pull timeout, took %v", elapsed)
+	}
+
+	if timeout := newProvider(t, types.LLMProviderConfig{Model: "llama3"}).pullTimeout(); timeout != constants.DefaultOllamaPullTimeout*time.Second {
+		t.Errorf("default pull timeout = %v", timeout)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	fake, server := newFakeOllama(t)
+	fake.status = http.StatusInternalServerError
+	provider := newProvider(t, types.LLMProviderConfig{Model: "llama3", URL: server.URL})
+
+	_, err := provider.Chat(context.Background(), &llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: "diagnose"}}})
+	if !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) {
+		t.Fatalf("err = %v, want an LLM provider error", err)
+	}
+	if err := provider.EnsureModels(context.Background()); errors.IsErrorCode(err, errors.ErrorCodeNotFound) || err == nil {
+		t.Errorf("an unreachable server must not be reported as a missing model, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/embedding"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/llmproviders/openaicompat"
+	"go.uber.org/zap"
+)
+
+// Package openai provides an LLM implementation for OpenAI and any OpenAI-compatible API,
+// such as vLLM, Ollama's OpenAI endpoint, Azure OpenAI or a corporate gateway.
+// 包 openai 提供一个用于 OpenAI 及任意 OpenAI 兼容 API (如 vLLM、Ollama 的 OpenAI 终点、
+// Azure OpenAI 或企业网关) 的 LLM 实现。
+
+// OpenAIProvider implements the llm.ChatLLM interface for OpenAI-compatible APIs.
+// OpenAIProvider 为 OpenAI 兼容 API 实现 llm.ChatLLM 接口。
+type OpenAIProvider struct {
+	name   string
+	config *types.LLMProviderConfig
+	chat   *openaicompat.Client
+}
+
+// Ensure OpenAIProvider implements the llm.ChatLLM interface.
+// 确保 OpenAIProvider 实现了 llm.ChatLLM 接口。
+var _ llm.ChatLLM = &OpenAIProvider{}
+
+// Ensure OpenAIProvider implements the embedding.Backend interface.
+// 确保 OpenAIProvider 实现了 embedding.Backend 接口。
+var _ embedding.Backend = &OpenAIProvider{}
+
+// NewOpenAIProvider creates a new OpenAIProvider instance with the given name.
+// NewOpenAIProvider 创建一个具有给定名称的新 OpenAIProvider 实例。
+// The URL defaults to the public OpenAI API. For Azure deployments the model may be omitted;
+// the deployment name is then used as the model name.
+// URL 默认为公共 OpenAI API。对于 Azure 部署可以省略模型，此时使用部署名称作为模型名称。
+func NewOpenAIProvider(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (*OpenAIProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("openai provider '%s' configuration is missing", name)
+	}
+	resolved := *cfg
+	if resolved.URL == "" {
+		if resolved.Deployment != "" {
+			return nil, fmt.Errorf("openai provider '%s' configuration is incomplete (URL is required for Azure deployments)", name)
+		}
+		resolved.URL = constants.DefaultOpenAIURL
+	}
+	if resolved.Model == "" {
+		resolved.Model = resolved.Deployment
+	}
+	if resolved.Model == "" {
+		return nil, fmt.Errorf("openai provider '%s' configuration is incomplete (Model or Deployment missing)", name)
+	}
+
+	log.L().Info("Initialized OpenAI-compatible LLM Provider", zap.String("name", name), zap.String("url", resolved.URL), zap.String("model", resolved.Model), zap.String("deployment", resolved.Deployment))
+
+	return &OpenAIProvider{
+		name:   name,
+		config: &resolved,
+		chat:   openaicompat.NewClient(name, &resolved, timeout),
+	}, nil
+}
+
+// Name returns the name of the LLM provider instance.
+// Name 返回 LLM 提供商实例的名称。
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (p *OpenAIProvider) Description() string {
+	return "Provides access to OpenAI and OpenAI-compatible APIs."
+}
+
+// GenerateText sends the prompt as a single user message and returns the answer.
+// GenerateText 将提示作为单条用户消息发送并返回回答。
+func (p *OpenAIProvider) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
+	return llm.GenerateTextViaChat(ctx, p, prompt, options)
+}
+
+// Chat sends a conversation to the chat completions endpoint.
+// Chat 将对话发送到 chat completions 终点。
+// The configured max tokens apply unless the request sets its own limit.
+// 除非请求设置了自身上限，否则使用配置的最大 token 数。
+func (p *OpenAIProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	if p.chat == nil {
+		return nil, fmt.Errorf("openai client is not initialized")
+	}
+	return p.chat.ChatCompletion(ctx, p.config.Model, llm.MaxOutputTokensFor(*p.config), req)
+}
+
+// Embed returns the embeddings of the texts computed by the embedding model, or the chat model if
+// no embedding model is configured.
+// Embed 返回由 embedding 模型计算的文本 embedding; 未配置 embedding 模型时使用聊天模型。
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.chat == nil {
+		return nil, fmt.Errorf("openai client is not initialized")
+	}
+	model := p.config.EmbeddingModel
+	if model == "" {
+		model = p.config.Model
+	}
+	return p.chat.Embeddings(ctx, model, texts)
+}
+
+// Register the provider factory with the global registry.
+// 在全局注册表中注册提供商工厂。
+func init() {
+	// Instances need configuration, so only the factory is registered here; any number of named
+	// instances can be created from it during engine setup.
+	// 实例需要配置，因此这里只注册工厂; 在引擎初始化期间可以由它创建任意数量的命名实例。
+	llm.RegisterLLMProviderFactory(constants.LLMProviderOpenAI, func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (llm.LLM, error) {
+		return NewOpenAIProvider(name, cfg, timeout)
+	})
+	embedding.RegisterBackendFactory(constants.LLMProviderOpenAI, func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (embedding.Backend, error) {
+		return NewOpenAIProvider(name, cfg, timeout)
+	})
+	log.L().Debug("OpenAI LLM provider factory registered")
+}
@@ -0,0 +1,182 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+// request is what the fake API saw of a call.
+type request struct {
+	path   string
+	query  string
+	header http.Header
+	body   map[string]interface{}
+}
+
+func newFakeAPI(t *testing.T) (*httptest.Server, *request) {
+	seen := &request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen.path, seen.query, seen.header, seen.body = r.URL.Path, r.URL.RawQuery, r.Header.Clone(), nil
+		if err := json.NewDecoder(r.Body).Decode(&seen.body); err != nil {
+			t.Errorf("%s: invalid request body: %v", r.URL.Path, err)
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, seen
+}
+
+func TestNewOpenAIProvider(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     *types.LLMProviderConfig
+		wantURL string
+		model   string
+		err     bool
+	}{
+		{name: "default base URL", cfg: &types.LLMProviderConfig{Model: "gpt-4o-mini"}, wantURL: constants.DefaultOpenAIURL, model: "gpt-4o-mini"},
+		{name: "configured URL", cfg: &types.LLMProviderConfig{URL: "http://vllm:8000/v1", Model: "qwen"}, wantURL: "http://vllm:8000/v1", model: "qwen"},
+		{name: "deployment as model", cfg: &types.LLMProviderConfig{URL: "https://res.openai.azure.com", Deployment: "gpt4o-prod"}, wantURL: "https://res.openai.azure.com/openai/deployments/gpt4o-prod", model: "gpt4o-prod"},
+		{name: "missing configuration", err: true},
+		{name: "missing model", cfg: &types.LLMProviderConfig{URL: "http://vllm:8000/v1"}, err: true},
+		{name: "deployment without URL", cfg: &types.LLMProviderConfig{Deployment: "gpt4o-prod"}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, err := NewOpenAIProvider("test", tc.cfg, time.Second)
+			if tc.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if provider.chat.BaseURL != tc.wantURL || provider.config.Model != tc.model {
+				t.Errorf("got URL %q and model %q, want %q and %q", provider.chat.BaseURL, provider.config.Model, tc.wantURL, tc.model)
+			}
+		})
+	}
+}
+
+func TestOpenAIChat(t *testing.T) {
+	server, seen := newFakeAPI(t)
+	provider, err := NewOpenAIProvider("test", &types.LLMProviderConfig{URL: server.URL + "/v1", Model: "gpt-4o-mini", APIKey: "sk-test", Organization: "org-1"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, err := provider.GenerateText(context.Background(), "Why?", nil); err != nil || text != "ok" {
+		t.Fatalf("got %q, %v", text, err)
+	}
+	if seen.path != "/v1/chat/completions" || seen.query != "" || seen.body["model"] != "gpt-4o-mini" {
+		t.Errorf("unexpected request %s?%s %v", seen.path, seen.query, seen.body)
+	}
+	if seen.header.Get("Authorization") != "Bearer sk-test" || seen.header.Get("OpenAI-Organization") != "org-1" || seen.header.Get("api-key") != "" {
+		t.Errorf("unexpected headers %v", seen.header)
+	}
+}
+
+func TestOpenAIAzureDeployment(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		apiVersion string
+		want       string
+	}{
+		{name: "default api-version", want: "api-version=" + constants.DefaultAzureOpenAIAPIVersion},
+		{name: "configured api-version", apiVersion: "2024-10-21", want: "api-version=2024-10-21"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, seen := newFakeAPI(t)
+			provider, err := NewOpenAIProvider("azure", &types.LLMProviderConfig{
+				URL: server.URL + "/", Deployment: "gpt4o prod", APIVersion: tc.apiVersion, APIKey: "azure-key",
+				Headers: map[string]string{"X-Team": "sre"},
+			}, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := provider.Chat(context.Background(), &llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: "Why?"}}}); err != nil {
+				t.Fatal(err)
+			}
+			if seen.path != "/openai/deployments/gpt4o prod/chat/completions" || seen.query != tc.want {
+				t.Errorf("got %s?%s", seen.path, seen.query)
+			}
+			if seen.header.Get("api-key") != "azure-key" || seen.header.Get("Authorization") != "" || seen.header.Get("X-Team") != "sre" {
+				t.Errorf("unexpected headers %v", seen.header)
+			}
+			if seen.body["model"] != "gpt4o prod" {
+				t.Errorf("the deployment must be sent as the model, got %v", seen.body["model"])
+			}
+		})
+	}
+}
+
+func TestNamedProviderInstances(t *testing.T) {
+	cfg := &types.LLMConfig{
+		Provider: "fast",
+		Providers: map[string]types.LLMProviderConfig{
+			"fast":  {URL: "http://vllm:8000/v1", Model: "qwen"},
+			"other": {Type: constants.LLMProviderDeepSeek, Model: "deepseek-chat"},
+		},
+	}
+	providerCfg, ok := cfg.ProviderConfig("fast")
+	if !ok || providerCfg.Type != constants.LLMProviderOpenAI {
+		t.Errorf("an instance without a type must default to openai, got %q", providerCfg.Type)
+	}
+	if providerCfg, _ := cfg.ProviderConfig("other"); providerCfg.Type != constants.LLMProviderDeepSeek {
+		t.Errorf("the configured type must be kept, got %q", providerCfg.Type)
+	}
+	if _, ok := cfg.ProviderConfig("missing"); ok {
+		t.Error("an unknown name must not resolve")
+	}
+
+	provider, err := llm.NewLLMProvider("fast", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.(*OpenAIProvider); !ok || provider.Name() != "fast" {
+		t.Errorf("got %T named %q", provider, provider.Name())
+	}
+}
+
+func TestNewConfiguredLLMChain(t *testing.T) {
+	providers := map[string]types.LLMProviderConfig{
+		"chain-primary":   {URL: "http://primary/v1", Model: "qwen"},
+		"chain-secondary": {URL: "http://secondary/v1", Model: "qwen"},
+		"chain-lonely":    {URL: "http://lonely/v1", Model: "qwen"},
+	}
+	for _, tc := range []struct {
+		name     string
+		provider string
+		fallback []string
+		code     errors.ErrorCode
+	}{
+		{name: "missing fallback provider", provider: "chain-lonely", fallback: []string{"chain-missing"}, code: errors.ErrorCodeNotFound},
+		{name: "missing primary provider", provider: "chain-unknown", code: errors.ErrorCodeNotFound},
+		{name: "empty chain", code: errors.ErrorCodeInvalidInput},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &types.LLMConfig{Provider: tc.provider, Providers: providers, Fallback: types.LLMFallbackConfig{Providers: tc.fallback}}
+			if _, err := llm.NewConfiguredLLM(cfg); !errors.IsErrorCode(err, tc.code) {
+				t.Errorf("expected error code %s, got %v", tc.code, err)
+			}
+		})
+	}
+
+	cfg := &types.LLMConfig{Provider: "chain-primary", Providers: providers, Fallback: types.LLMFallbackConfig{Providers: []string{"chain-secondary"}}}
+	provider, err := llm.NewConfiguredLLM(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members := llm.ChainMembers(provider); len(members) != 2 || members[0].Name() != "chain-primary" || members[1].Name() != "chain-secondary" {
+		t.Errorf("unexpected chain %v", members)
+	}
+}
@@ -0,0 +1,330 @@
+package openaicompat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"go.uber.org/zap"
+)
+
+// Package openaicompat implements the OpenAI chat completions wire protocol shared by
+// LocalAI, DeepSeek and other OpenAI-compatible APIs.
+// 包 openaicompat 实现了 LocalAI、DeepSeek 及其他 OpenAI 兼容 API 共用的 OpenAI chat completions 协议。
+
+// defaultTemperature is used when a request does not set a temperature.
+// defaultTemperature 在请求未设置温度时使用。
+const defaultTemperature = 0.7
+
+// Client sends chat completion requests to an OpenAI-compatible API.
+// Client 向 OpenAI 兼容 API 发送 chat completions 请求。
+type Client struct {
+	// Provider is the name of the provider using the client, used in logs.
+	// Provider 是使用该客户端的提供商名称，用于日志。
+	Provider string
+	// BaseURL is the API root; "/chat/completions" is appended to it.
+	// BaseURL 是 API 根地址; 会在其后追加 "/chat/completions"。
+	BaseURL string
+	// APIKey is sent as a bearer token if set.
+	// APIKey 如果设置，将作为 bearer token 发送。
+	APIKey string
+	// Headers are added to every request.
+	// Headers 会添加到每个请求中。
+	Headers map[string]string
+	// Query parameters are added to every request URL.
+	// Query 参数会添加到每个请求 URL 中。
+	Query url.Values
+	// HTTPClient performs the requests.
+	// HTTPClient 执行请求。
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client from a provider configuration.
+// NewClient 根据提供商配置创建客户端。
+// If a deployment is configured, the client talks to Azure OpenAI: the deployment is part of the
+// path, the api-version is sent as a query parameter and the API key as the api-key header.
+// 如果配置了 deployment，客户端将对接 Azure OpenAI: deployment 是路径的一部分，
+// api-version 作为查询参数发送，API Key 作为 api-key 请求头发送。
+func NewClient(provider string, cfg *types.LLMProviderConfig, timeout time.Duration) *Client {
+	client := &Client{
+		Provider: provider,
+		BaseURL:  cfg.URL,
+		APIKey:   cfg.APIKey,
+		Headers:  map[string]string{},
+		HTTPClient: &http.Client{
+			Timeout: timeout, // Use the global LLM timeout / 使用全局 LLM 超时时间
+		},
+	}
+
+	if cfg.Deployment != "" {
+		apiVersion := cfg.APIVersion
+		if apiVersion == "" {
+			apiVersion = constants.DefaultAzureOpenAIAPIVersion
+		}
+		client.BaseURL = strings.TrimSuffix(cfg.URL, "/") + "/openai/deployments/" + url.PathEscape(cfg.Deployment)
+		client.Query = url.Values{"api-version": []string{apiVersion}}
+		client.APIKey = ""
+		if cfg.APIKey != "" {
+			client.Headers["api-key"] = cfg.APIKey
+		}
+	}
+	if cfg.Organization != "" {
+		client.Headers["OpenAI-Organization"] = cfg.Organization
+	}
+	// Configured headers come last so that they can override the defaults above.
+	// 配置的请求头最后设置，以便覆盖上面的默认值。
+	for key, value := range cfg.Headers {
+		client.Headers[key] = value
+	}
+	return client
+}
+
+// wireMessage is a chat message in the OpenAI format.
+// wireMessage 是 OpenAI 格式的聊天消息。
+type wireMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	Name       string         `json:"name,omitempty"`
+	ToolCalls  []wireToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// wireToolCall is a tool call in the OpenAI format.
+// wireToolCall 是 OpenAI 格式的工具调用。
+type wireToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatCompletionResponse is the subset of the OpenAI response the client reads.
+// chatCompletionResponse 是客户端读取的 OpenAI 响应子集。
+type chatCompletionResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      wireMessage `json:"message"`
+		Text         string      `json:"text"` // Legacy completions format / 旧版 completions 格式
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// ChatCompletion sends a chat request for the model and returns the first choice.
+// ChatCompletion 为指定模型发送聊天请求并返回第一个选项。
+// maxTokens is used when the request does not set its own limit.
+// 当请求未设置自身上限时使用 maxTokens。
+func (c *Client) ChatCompletion(ctx context.Context, model string, maxTokens int, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	logger := log.LWithContext(ctx).With(zap.String("llmProvider", c.Provider), zap.String("model", model))
+	logger.Debug("Sending chat completion request", zap.Int("messages", len(req.Messages)), zap.Int("tools", len(req.Tools)))
+
+	if c.HTTPClient == nil {
+		return nil, fmt.Errorf("%s client is not initialized", c.Provider)
+	}
+
+	bodyBytes, err := c.post(ctx, logger, "/chat/completions", c.requestBody(model, maxTokens, req))
+	if err != nil {
+		return nil, err
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(bodyBytes, &completion); err != nil {
+		logger.Error("Failed to unmarshal response body", zap.Error(err), zap.String("body", llm.BodyExcerpt(bodyBytes)))
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to unmarshal response body", err, llm.BodyExcerpt(bodyBytes))
+	}
+	if len(completion.Choices) == 0 {
+		return nil, errors.New(errors.ErrorCodeLLMProviderError, "no choices found in LLM response", llm.BodyExcerpt(bodyBytes))
+	}
+
+	choice := completion.Choices[0]
+	message := llm.Message{
+		Role:    llm.RoleAssistant,
+		Content: choice.Message.Content,
+	}
+	if message.Content == "" {
+		message.Content = choice.Text
+	}
+	for _, call := range choice.Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, llm.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+
+	result := &llm.ChatResponse{
+		Message:      message,
+		FinishReason: llm.FinishReason(choice.FinishReason),
+		Usage: llm.Usage{
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+			TotalTokens:      completion.Usage.TotalTokens,
+		},
+		Model: completion.Model,
+	}
+	if result.FinishReason == llm.FinishReasonLength {
+		logger.Warn("LLM answer was cut off by the token limit", zap.Int("completionTokens", result.Usage.CompletionTokens))
+	}
+	logger.Debug("Chat completion received", zap.String("finishReason", string(result.FinishReason)), zap.Int("promptTokens", result.Usage.PromptTokens), zap.Int("completionTokens", result.Usage.CompletionTokens))
+	return result, nil
+}
+
+// embeddingsResponse is the subset of the OpenAI embeddings response the client reads.
+// embeddingsResponse 是客户端读取的 OpenAI embeddings 响应子集。
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embeddings returns the embeddings of the texts computed by the model, in the order of the texts.
+// Embeddings 返回由模型计算的文本 embedding，顺序与文本一致。
+func (c *Client) Embeddings(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	logger := log.LWithContext(ctx).With(zap.String("llmProvider", c.Provider), zap.String("model", model))
+	logger.Debug("Sending embeddings request", zap.Int("texts", len(texts)))
+
+	if c.HTTPClient == nil {
+		return nil, fmt.Errorf("%s client is not initialized", c.Provider)
+	}
+
+	bodyBytes, err := c.post(ctx, logger, "/embeddings", map[string]interface{}{"model": model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	var resp embeddingsResponse
+	if err := json.Unmarshal(bodyBytes, &resp); err != nil {
+		logger.Error("Failed to unmarshal response body", zap.Error(err))
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to unmarshal response body", err, llm.BodyExcerpt(bodyBytes))
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, errors.New(errors.ErrorCodeLLMProviderError, "unexpected number of embeddings", fmt.Sprintf("requested %d, received %d", len(texts), len(resp.Data)))
+	}
+
+	// The data is ordered by index, which some servers do not guarantee.
+	// 数据按 index 排序，某些服务端并不保证这一点。
+	embeddings := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(texts) || embeddings[item.Index] != nil {
+			return nil, errors.New(errors.ErrorCodeLLMProviderError, "invalid embedding index", fmt.Sprintf("index %d of %d texts", item.Index, len(texts)))
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+	return embeddings, nil
+}
+
+// post sends a JSON request to the API path and returns the body of a successful response.
+// post 向 API 路径发送 JSON 请求并返回成功响应的响应体。
+func (c *Client) post(ctx context.Context, logger *zap.Logger, path string, body interface{}) ([]byte, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		logger.Error("Failed to marshal request body", zap.Error(err))
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to marshal request body", err, "")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(path), bytes.NewBuffer(jsonBody))
+	if err != nil {
+		logger.Error("Failed to create HTTP request", zap.Error(err))
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to create http request", err, "")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	for key, value := range c.Headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		logger.Error("HTTP request failed", zap.Error(err))
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "http request failed", err, "")
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := llm.ReadResponseBody(resp.Body)
+	if err != nil {
+		logger.Error("Failed to read response body", zap.Error(err))
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, "failed to read response body", err, "")
+	}
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("API returned non-200 status", zap.Int("statusCode", resp.StatusCode), zap.String("body", llm.BodyExcerpt(bodyBytes)))
+		return nil, errors.Wrap(errors.ErrorCodeLLMProviderError, fmt.Sprintf("API returned status %d", resp.StatusCode), llm.NewStatusError(resp, bodyBytes), llm.BodyExcerpt(bodyBytes))
+	}
+	return bodyBytes, nil
+}
+
+// requestBody builds the JSON request body. Options override the generated fields.
+// requestBody 构建 JSON 请求体。Options 会覆盖生成的字段。
+func (c *Client) requestBody(model string, maxTokens int, req *llm.ChatRequest) map[string]interface{} {
+	messages := make([]wireMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		wire := wireMessage{Role: string(msg.Role), Content: msg.Content, Name: msg.Name, ToolCallID: msg.ToolCallID}
+		for _, call := range msg.ToolCalls {
+			wireCall := wireToolCall{ID: call.ID, Type: "function"}
+			wireCall.Function.Name = call.Name
+			wireCall.Function.Arguments = call.Arguments
+			wire.ToolCalls = append(wire.ToolCalls, wireCall)
+		}
+		messages = append(messages, wire)
+	}
+
+	if req.MaxTokens > 0 {
+		maxTokens = req.MaxTokens
+	}
+	temperature := defaultTemperature
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+
+	body := map[string]interface{}{
+		"model":       model,
+		"messages":    messages,
+		"max_tokens":  maxTokens,
+		"temperature": temperature,
+	}
+	if len(req.Stop) > 0 {
+		body["stop"] = req.Stop
+	}
+	if req.ResponseFormat != "" && req.ResponseFormat != llm.ResponseFormatText {
+		body["response_format"] = map[string]string{"type": string(req.ResponseFormat)}
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(req.Tools))
+		for _, def := range req.Tools {
+			tools = append(tools, map[string]interface{}{"type": "function", "function": def})
+		}
+		body["tools"] = tools
+	}
+
+	for key, value := range req.Options {
+		// The conversation is owned by the request; options only tune generation.
+		// 对话由请求本身决定; options 仅用于调整生成参数。
+		if key != "messages" {
+			body[key] = value
+		}
+	}
+	return body
+}
+
+// endpoint joins the base URL, path and query parameters.
+// endpoint 拼接基础 URL、路径和查询参数。
+func (c *Client) endpoint(path string) string {
+	endpoint := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(c.Query) > 0 {
+		endpoint += "?" + c.Query.Encode()
+	}
+	return endpoint
+}
@@ -0,0 +1,285 @@
+package openaicompat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+)
+
+// fakeAPI serves an OpenAI-compatible API and records the requests.
+type fakeAPI struct {
+	t      *testing.T
+	reply  string // Body of every response
+	status int    // Status of every response; 0 means 200
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []map[string]interface{}
+}
+
+func newFakeAPI(t *testing.T, reply string) (*fakeAPI, *httptest.Server) {
+	fake := &fakeAPI{t: t, reply: reply}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.t.Errorf("%s: invalid request body: %v", r.URL.Path, err)
+	}
+	f.requests = append(f.requests, r)
+	f.bodies = append(f.bodies, body)
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+	}
+	_, _ = w.Write([]byte(f.reply))
+}
+
+func (f *fakeAPI) last() (*http.Request, map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		f.t.Fatal("no request was sent")
+	}
+	return f.requests[len(f.requests)-1], f.bodies[len(f.bodies)-1]
+}
+
+func newTestClient(server *httptest.Server) *Client {
+	return NewClient("test", &types.LLMProviderConfig{URL: server.URL + "/v1", APIKey: "secret"}, 5*time.Second)
+}
+
+func userRequest(text string) *llm.ChatRequest {
+	return &llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: text}}}
+}
+
+func TestChatCompletionErrorBody(t *testing.T) {
+	fake, server := newFakeAPI(t, `{"error": {"message": "`+strings.Repeat("overloaded ", 1000)+`"}}`)
+	fake.status = http.StatusServiceUnavailable
+
+	_, err := newTestClient(server).ChatCompletion(context.Background(), "gpt", 100, userRequest("hello"))
+	if !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) {
+		t.Fatalf("expected a provider error, got %v", err)
+	}
+	if !llm.IsTransient(err) {
+		t.Errorf("a 503 must be transient: %v", err)
+	}
+	if n := len(err.Error()); n > 3*constants.MaxLLMErrorBodyChars {
+		t.Errorf("the error holds %d characters of the body, want it truncated", n)
+	}
+}
+
+func TestChatCompletionLargeBody(t *testing.T) {
+	large := strings.Repeat("x", constants.MaxLLMResponseBytes+1)
+	_, server := newFakeAPI(t, large)
+
+	_, err := newTestClient(server).ChatCompletion(context.Background(), "gpt", 100, userRequest("hello"))
+	if !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) || !strings.Contains(err.Error(), "larger than") {
+		t.Fatalf("expected a response size error, got %v", err)
+	}
+}
+
+func TestBodyExcerpt(t *testing.T) {
+	short := `{"error": "bad request"}`
+	if got := llm.BodyExcerpt([]byte(short)); got != short {
+		t.Errorf("a short body must be kept, got %q", got)
+	}
+	long := strings.Repeat("é", constants.MaxLLMErrorBodyChars+10)
+	got := llm.BodyExcerpt([]byte(long))
+	if !strings.HasPrefix(got, strings.Repeat("é", constants.MaxLLMErrorBodyChars)+"...") || strings.Contains(got, strings.Repeat("é", constants.MaxLLMErrorBodyChars+1)) {
+		t.Errorf("unexpected excerpt %q", got)
+	}
+}
+
+func TestChatCompletionRequest(t *testing.T) {
+	fake, server := newFakeAPI(t, `{"choices": [{"message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}]}`)
+	temperature := 0.1
+	req := &llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "You are an SRE."},
+			{Role: llm.RoleUser, Content: "Why is web-0 failing?"},
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "get_pod_logs", Arguments: `{"name": "web-0"}`}}},
+			{Role: llm.RoleTool, Name: "get_pod_logs", ToolCallID: "call_1", Content: "OOMKilled"},
+		},
+		Tools:          []llm.ToolDefinition{{Name: "get_pod_logs", Description: "Reads pod logs.", Parameters: map[string]interface{}{"type": "object"}}},
+		ResponseFormat: llm.ResponseFormatJSON,
+		Stop:           []string{"END"},
+		Temperature:    &temperature,
+		Options:        map[string]interface{}{"seed": 7, "messages": "ignored"},
+	}
+	if _, err := newTestClient(server).ChatCompletion(context.Background(), "gpt", 100, req); err != nil {
+		t.Fatal(err)
+	}
+
+	r, body := fake.last()
+	if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+	}
+	want := map[string]interface{}{
+		"model": "gpt",
+		"messages": []interface{}{
+			map[string]interface{}{"role": "system", "content": "You are an SRE."},
+			map[string]interface{}{"role": "user", "content": "Why is web-0 failing?"},
+			map[string]interface{}{"role": "assistant", "content": "", "tool_calls": []interface{}{
+				map[string]interface{}{"id": "call_1", "type": "function", "function": map[string]interface{}{"name": "get_pod_logs", "arguments": `{"name": "web-0"}`}},
+			}},
+			map[string]interface{}{"role": "tool", "content": "OOMKilled", "name": "get_pod_logs", "tool_call_id": "call_1"},
+		},
+		"max_tokens":      float64(100),
+		"temperature":     0.1,
+		"stop":            []interface{}{"END"},
+		"response_format": map[string]interface{}{"type": "json_object"},
+		"tools": []interface{}{
+			map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_pod_logs", "description": "Reads pod logs.", "parameters": map[string]interface{}{"type": "object"}}},
+		},
+		"seed": float64(7),
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("got body\n%v\nwant\n%v", body, want)
+	}
+}
+
+func TestChatCompletionRequestDefaults(t *testing.T) {
+	fake, server := newFakeAPI(t, `{"choices": [{"message": {"content": "ok"}}]}`)
+	req := userRequest("hello")
+	req.ResponseFormat = llm.ResponseFormatText
+	if _, err := newTestClient(server).ChatCompletion(context.Background(), "gpt", 100, req); err != nil {
+		t.Fatal(err)
+	}
+	_, body := fake.last()
+	if body["max_tokens"] != float64(100) || body["temperature"] != defaultTemperature {
+		t.Errorf("unexpected defaults %v", body)
+	}
+	for _, key := range []string{"stop", "response_format", "tools"} {
+		if _, found := body[key]; found {
+			t.Errorf("%s must not be sent, got %v", key, body[key])
+		}
+	}
+
+	req.MaxTokens = 20
+	if _, err := newTestClient(server).ChatCompletion(context.Background(), "gpt", 100, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := fake.last(); body["max_tokens"] != float64(20) {
+		t.Errorf("the request limit must win, got %v", body["max_tokens"])
+	}
+}
+
+func TestChatCompletionResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		reply string
+		want  *llm.ChatResponse
+	}{
+		{
+			name: "tool calls",
+			reply: `{"model": "gpt-4o-2024", "choices": [{"message": {"role": "assistant", "content": "", "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "list_events", "arguments": "{\"namespace\": \"web\"}"}}
+			]}, "finish_reason": "tool_calls"}], "usage": {"prompt_tokens": 120, "completion_tokens": 15, "total_tokens": 135}}`,
+			want: &llm.ChatResponse{
+				Message:      llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "list_events", Arguments: `{"namespace": "web"}`}}},
+				FinishReason: llm.FinishReasonToolCalls,
+				Usage:        llm.Usage{PromptTokens: 120, CompletionTokens: 15, TotalTokens: 135},
+				Model:        "gpt-4o-2024",
+			},
+		},
+		{
+			name:  "cut off",
+			reply: `{"choices": [{"message": {"content": "The pod"}, "finish_reason": "length"}], "usage": {"prompt_tokens": 10, "completion_tokens": 100, "total_tokens": 110}}`,
+			want: &llm.ChatResponse{
+				Message:      llm.Message{Role: llm.RoleAssistant, Content: "The pod"},
+				FinishReason: llm.FinishReasonLength,
+				Usage:        llm.Usage{PromptTokens: 10, CompletionTokens: 100, TotalTokens: 110},
+			},
+		},
+		{
+			name:  "legacy text",
+			reply: `{"choices": [{"text": "done", "finish_reason": "stop"}]}`,
+			want: &llm.ChatResponse{
+				Message:      llm.Message{Role: llm.RoleAssistant, Content: "done"},
+				FinishReason: llm.FinishReasonStop,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, server := newFakeAPI(t, tc.reply)
+			got, err := newTestClient(server).ChatCompletion(context.Background(), "gpt", 100, userRequest("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChatCompletionInvalidResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{name: "not JSON", reply: "<html>bad gateway</html>", want: "failed to unmarshal"},
+		{name: "no choices", reply: `{"choices": []}`, want: "no choices"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, server := newFakeAPI(t, tc.reply)
+			_, err := newTestClient(server).ChatCompletion(context.Background(), "gpt", 100, userRequest("hello"))
+			if !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	fake, server := newFakeAPI(t, `{"data": [{"index": 1, "embedding": [0.2]}, {"index": 0, "embedding": [0.1]}]}`)
+	got, err := newTestClient(server).Embeddings(context.Background(), "embed", []string{"first", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]float32{{0.1}, {0.2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v in the order of the texts", got, want)
+	}
+	r, body := fake.last()
+	if r.URL.Path != "/v1/embeddings" || body["model"] != "embed" || !reflect.DeepEqual(body["input"], []interface{}{"first", "second"}) {
+		t.Errorf("unexpected request %s %v", r.URL.Path, body)
+	}
+}
+
+func TestEmbeddingsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{name: "missing embedding", reply: `{"data": [{"index": 0, "embedding": [0.1]}]}`, want: "unexpected number"},
+		{name: "duplicate index", reply: `{"data": [{"index": 0, "embedding": [0.1]}, {"index": 0, "embedding": [0.2]}]}`, want: "invalid embedding index"},
+		{name: "index out of range", reply: `{"data": [{"index": 0, "embedding": [0.1]}, {"index": 2, "embedding": [0.2]}]}`, want: "invalid embedding index"},
+		{name: "negative index", reply: `{"data": [{"index": -1, "embedding": [0.1]}, {"index": 0, "embedding": [0.2]}]}`, want: "invalid embedding index"},
+		{name: "not JSON", reply: "bad gateway", want: "failed to unmarshal"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, server := newFakeAPI(t, tc.reply)
+			_, err := newTestClient(server).Embeddings(context.Background(), "embed", []string{"first", "second"})
+			if !errors.IsErrorCode(err, errors.ErrorCodeLLMProviderError) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
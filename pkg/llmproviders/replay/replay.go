@@ -0,0 +1,408 @@
+package replay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"go.uber.org/zap"
+)
+
+// Package replay provides an LLM provider that records the interactions of an upstream provider
+// to cassette files and replays them later, so that the engine can be exercised without a model.
+// 包 replay 提供一个 LLM 提供商，它将上游提供商的交互录制到磁带文件中并在之后回放，
+// 使引擎无需模型即可运行。
+
+// Modes of a replay provider.
+// 回放提供商的模式。
+const (
+	// ModeReplay only answers from cassettes; a missing interaction is an error.
+	// ModeReplay 只从磁带应答; 缺失的交互视为错误。
+	ModeReplay = "replay"
+	// ModeRecord always calls the upstream provider and records its answers.
+	// ModeRecord 总是调用上游提供商并录制其回答。
+	ModeRecord = "record"
+	// ModeAuto replays what is recorded and records what is missing.
+	// ModeAuto 回放已录制的内容，并录制缺失的内容。
+	ModeAuto = "auto"
+)
+
+// DefaultScenario is the cassette used when no scenario is configured or set on the context.
+// DefaultScenario 是未配置场景且 context 中也未设置场景时使用的磁带。
+const DefaultScenario = "default"
+
+// Cassette is the content of a cassette file: the recorded interactions of one scenario.
+// Cassette 是磁带文件的内容: 某个场景录制的交互。
+type Cassette struct {
+	Scenario     string        `json:"scenario"`     // Scenario ID / 场景 ID
+	Interactions []Interaction `json:"interactions"` // Recorded interactions in order / 按顺序录制的交互
+}
+
+// Interaction is a recorded request and the answer of the upstream provider.
+// Interaction 是一次录制的请求及上游提供商的回答。
+type Interaction struct {
+	Key        string           `json:"key"`        // Hash of the normalized request / 规范化请求的哈希
+	Request    RecordedRequest  `json:"request"`    // The request, for humans reviewing the cassette / 请求内容，供人工审阅磁带
+	Response   RecordedResponse `json:"response"`   // The answer / 回答
+	RecordedAt time.Time        `json:"recordedAt"` // When it was recorded / 录制时间
+}
+
+// RecordedRequest is the part of a chat request stored in a cassette.
+// RecordedRequest 是存储在磁带中的聊天请求部分。
+type RecordedRequest struct {
+	Messages []llm.Message `json:"messages"`        // Conversation / 对话
+	Tools    []string      `json:"tools,omitempty"` // Names of the tools offered / 提供的工具名称
+}
+
+// RecordedResponse is a chat response stored in a cassette.
+// RecordedResponse 是存储在磁带中的聊天响应。
+type RecordedResponse struct {
+	Message      llm.Message      `json:"message"`            // The assistant's message / 助手消息
+	FinishReason llm.FinishReason `json:"finishReason"`       // Why generation stopped / 停止生成的原因
+	Usage        llm.Usage        `json:"usage"`              // Tokens reported by the upstream provider / 上游提供商报告的 token 数
+	Model        string           `json:"model,omitempty"`    // Model that answered / 应答的模型
+	Upstream     string           `json:"upstream,omitempty"` // Provider that answered / 应答的提供商
+}
+
+// ReplayProvider implements llm.ChatLLM on top of cassette files.
+// ReplayProvider 基于磁带文件实现 llm.ChatLLM。
+type ReplayProvider struct {
+	name     string
+	dir      string
+	mode     string
+	upstream string
+	scenario string
+
+	mu        sync.Mutex
+	cassettes map[string]*cassetteState
+}
+
+// cassetteState is a loaded cassette and the interactions already replayed from it.
+// cassetteState 是已加载的磁带以及已从中回放的交互。
+type cassetteState struct {
+	Cassette
+	path string
+	used map[int]bool
+}
+
+// Ensure ReplayProvider implements the llm.ChatLLM interface.
+// 确保 ReplayProvider 实现了 llm.ChatLLM 接口。
+var _ llm.ChatLLM = &ReplayProvider{}
+
+// NewReplayProvider creates a replay provider from configuration.
+// NewReplayProvider 根据配置创建回放提供商。
+// The upstream provider is looked up in the global registry when the first interaction has to be
+// recorded, so it only needs to be configured for the record and auto modes.
+// 上游提供商在第一次需要录制交互时从全局注册表中查找，因此只有 record 和 auto 模式需要配置它。
+func NewReplayProvider(name string, cfg *types.LLMProviderConfig) (*ReplayProvider, error) {
+	if cfg == nil || cfg.Cassette == "" {
+		return nil, fmt.Errorf("replay provider '%s' configuration is incomplete (cassette directory missing)", name)
+	}
+	mode := cfg.Mode
+	if mode == "" {
+		mode = ModeReplay
+	}
+	switch mode {
+	case ModeReplay:
+	case ModeRecord, ModeAuto:
+		if cfg.Upstream == "" {
+			return nil, fmt.Errorf("replay provider '%s' in %s mode needs an upstream provider", name, mode)
+		}
+	default:
+		return nil, fmt.Errorf("replay provider '%s' has unknown mode '%s'", name, mode)
+	}
+
+	log.L().Info("Initialized replay LLM Provider", zap.String("name", name), zap.String("cassette", cfg.Cassette), zap.String("mode", mode), zap.String("upstream", cfg.Upstream))
+	return &ReplayProvider{
+		name:      name,
+		dir:       cfg.Cassette,
+		mode:      mode,
+		upstream:  cfg.Upstream,
+		scenario:  cfg.Scenario,
+		cassettes: map[string]*cassetteState{},
+	}, nil
+}
+
+// Name returns the name of the LLM provider instance.
+// Name 返回 LLM 提供商实例的名称。
+func (p *ReplayProvider) Name() string {
+	return p.name
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (p *ReplayProvider) Description() string {
+	return fmt.Sprintf("Replays recorded LLM interactions from %s (mode %s).", p.dir, p.mode)
+}
+
+// GenerateText sends the prompt as a single user message and returns the answer.
+// GenerateText 将提示作为单条用户消息发送并返回回答。
+func (p *ReplayProvider) GenerateText(ctx context.Context, prompt string, options map[string]interface{}) (string, error) {
+	return llm.GenerateTextViaChat(ctx, p, prompt, options)
+}
+
+// Chat answers from the cassette of the scenario, recording the upstream answer if allowed.
+// Chat 从场景的磁带中应答，在允许时录制上游提供商的回答。
+// An interaction matches if the hash of its normalized request is equal. If no hash matches and
+// a scenario was chosen explicitly, the next interaction of the scenario that has not been
+// replayed yet is used, so that scenarios survive small prompt template changes.
+// 当规范化请求的哈希相同时交互匹配。如果没有哈希匹配且显式选择了场景，则使用该场景中下一个尚未
+// 回放的交互，使场景在提示模板小幅变化后仍然可用。
+func (p *ReplayProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	scenario, explicit := ScenarioFromContext(ctx), true
+	if scenario == "" {
+		scenario, explicit = p.scenario, p.scenario != ""
+	}
+	if scenario == "" {
+		scenario = DefaultScenario
+	}
+	key := RequestKey(req)
+
+	p.mu.Lock()
+	c, err := p.loadLocked(scenario)
+	if err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	if p.mode != ModeRecord {
+		if i := c.match(key, explicit); i >= 0 {
+			c.used[i] = true
+			resp := c.Interactions[i].Response
+			p.mu.Unlock()
+			log.LWithContext(ctx).Debug("Replayed LLM interaction", zap.String("provider", p.name), zap.String("scenario", scenario), zap.String("key", key))
+			return &llm.ChatResponse{Message: resp.Message, FinishReason: resp.FinishReason, Usage: resp.Usage, Model: resp.Model}, nil
+		}
+	}
+	p.mu.Unlock()
+
+	if p.mode == ModeReplay {
+		return nil, errors.New(errors.ErrorCodeNotFound, "no recorded LLM interaction", fmt.Sprintf("scenario '%s', request key %s", scenario, key))
+	}
+	return p.record(ctx, scenario, key, req)
+}
+
+// record calls the upstream provider and appends its answer to the cassette of the scenario.
+// record 调用上游提供商，并将其回答追加到场景的磁带中。
+func (p *ReplayProvider) record(ctx context.Context, scenario, key string, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	upstream, found := llm.GetLLMProvider(p.upstream)
+	if !found {
+		return nil, errors.New(errors.ErrorCodeNotFound, "upstream LLM provider not registered", p.upstream)
+	}
+	resp, err := llm.Chat(ctx, upstream, req)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Key:     key,
+		Request: RecordedRequest{Messages: req.Messages, Tools: toolNames(req.Tools)},
+		Response: RecordedResponse{
+			Message:      resp.Message,
+			FinishReason: resp.FinishReason,
+			Usage:        resp.Usage,
+			Model:        resp.Model,
+			Upstream:     resp.Provider,
+		},
+		RecordedAt: time.Now().UTC(),
+	}
+	if interaction.Response.Upstream == "" {
+		interaction.Response.Upstream = upstream.Name()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, err := p.loadLocked(scenario)
+	if err != nil {
+		return nil, err
+	}
+	c.Interactions = append(c.Interactions, interaction)
+	c.used[len(c.Interactions)-1] = true
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	log.LWithContext(ctx).Debug("Recorded LLM interaction", zap.String("provider", p.name), zap.String("scenario", scenario), zap.String("key", key))
+	return resp, nil
+}
+
+// loadLocked returns the cassette of the scenario, reading it from disk on first use.
+// The caller must hold p.mu.
+// loadLocked 返回场景的磁带，首次使用时从磁盘读取。调用方必须持有 p.mu。
+func (p *ReplayProvider) loadLocked(scenario string) (*cassetteState, error) {
+	if c, ok := p.cassettes[scenario]; ok {
+		return c, nil
+	}
+	c := &cassetteState{
+		Cassette: Cassette{Scenario: scenario},
+		path:     CassettePath(p.dir, scenario),
+		used:     map[int]bool{},
+	}
+	content, err := os.ReadFile(c.path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(content, &c.Cassette); err != nil {
+			return nil, errors.Wrap(errors.ErrorCodeInvalidInput, "invalid cassette file", err, c.path)
+		}
+	case os.IsNotExist(err):
+		// A new cassette; it is created when the first interaction is recorded.
+		// 新磁带; 在录制第一个交互时创建。
+	default:
+		return nil, errors.Wrap(errors.ErrorCodeUnknown, "failed to read cassette file", err, c.path)
+	}
+	p.cassettes[scenario] = c
+	return c, nil
+}
+
+// match returns the index of the interaction to replay for the key, or -1.
+// match 返回针对该键要回放的交互索引，未找到时返回 -1。
+// Unused interactions with the key are preferred, so that repeated identical requests replay
+// their recorded answers in order.
+// 优先使用具有该键且尚未使用的交互，使重复的相同请求按顺序回放其录制的回答。
+func (c *cassetteState) match(key string, sequential bool) int {
+	last := -1
+	for i, interaction := range c.Interactions {
+		if interaction.Key != key {
+			continue
+		}
+		if !c.used[i] {
+			return i
+		}
+		last = i
+	}
+	if last >= 0 {
+		return last
+	}
+	if sequential {
+		for i := range c.Interactions {
+			if !c.used[i] {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// save writes the cassette atomically.
+// save 以原子方式写入磁带。
+func (c *cassetteState) save() error {
+	content, err := json.MarshalIndent(c.Cassette, "", "  ")
+	if err != nil {
+		return errors.Wrap(errors.ErrorCodeUnknown, "failed to encode cassette", err, c.path)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return errors.Wrap(errors.ErrorCodeUnknown, "failed to create cassette directory", err, c.path)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return errors.Wrap(errors.ErrorCodeUnknown, "failed to write cassette file", err, tmp)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return errors.Wrap(errors.ErrorCodeUnknown, "failed to write cassette file", err, c.path)
+	}
+	return nil
+}
+
+// CassettePath returns the file of a scenario's cassette in the directory.
+// CassettePath 返回目录中某个场景磁带的文件路径。
+func CassettePath(dir, scenario string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, scenario)
+	return filepath.Join(dir, safe+".json")
+}
+
+// volatile matches parts of prompts that change between otherwise identical runs.
+// volatile 匹配提示中在其他方面相同的运行之间会变化的部分。
+var volatile = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?`), "<TIME>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<UUID>"},
+	{regexp.MustCompile(`\b\d+(?:\.\d+)?(?:ns|µs|us|ms|s|m|h)\b`), "<DURATION>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// NormalizePrompt removes timestamps, UUIDs, durations and whitespace differences from a prompt.
+// NormalizePrompt 去除提示中的时间戳、UUID、时长以及空白差异。
+func NormalizePrompt(text string) string {
+	for _, v := range volatile {
+		text = v.re.ReplaceAllString(text, v.replacement)
+	}
+	return strings.TrimSpace(text)
+}
+
+// RequestKey returns the hash of a normalized chat request: its messages and the names of its tools.
+// RequestKey 返回规范化聊天请求的哈希: 其消息以及工具名称。
+func RequestKey(req *llm.ChatRequest) string {
+	h := sha256.New()
+	for _, m := range req.Messages {
+		fmt.Fprintf(h, "%s\x00%s\x00", m.Role, NormalizePrompt(m.Content))
+		for _, call := range m.ToolCalls {
+			fmt.Fprintf(h, "call\x00%s\x00%s\x00", call.Name, call.Arguments)
+		}
+	}
+	for _, name := range toolNames(req.Tools) {
+		fmt.Fprintf(h, "tool\x00%s\x00", name)
+	}
+	fmt.Fprintf(h, "format\x00%s", req.ResponseFormat)
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// toolNames returns the sorted names of the tool definitions.
+// toolNames 返回工具定义的名称 (已排序)。
+func toolNames(tools []llm.ToolDefinition) []string {
+	if len(tools) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scenarioKey is the context key of the scenario ID.
+// scenarioKey 是场景 ID 的 context 键。
+type scenarioKey struct{}
+
+// WithScenario returns a context that makes replay providers use the cassette of the scenario.
+// WithScenario 返回一个 context，使回放提供商使用该场景的磁带。
+func WithScenario(ctx context.Context, scenario string) context.Context {
+	return context.WithValue(ctx, scenarioKey{}, scenario)
+}
+
+// ScenarioFromContext returns the scenario ID set by WithScenario, or "".
+// ScenarioFromContext 返回由 WithScenario 设置的场景 ID，未设置时返回 ""。
+func ScenarioFromContext(ctx context.Context) string {
+	scenario, _ := ctx.Value(scenarioKey{}).(string)
+	return scenario
+}
+
+// Register the provider factory with the global registry.
+// 在全局注册表中注册提供商工厂。
+func init() {
+	llm.RegisterLLMProviderFactory(constants.LLMProviderReplay, func(name string, cfg *types.LLMProviderConfig, timeout time.Duration) (llm.LLM, error) {
+		return NewReplayProvider(name, cfg)
+	})
+	log.L().Debug("Replay LLM provider factory registered")
+}
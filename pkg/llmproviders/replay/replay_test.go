@@ -0,0 +1,71 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/llm/llmtest"
+)
+
+func chatRequest(content string) *llm.ChatRequest {
+	return &llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: content}}}
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	upstream := llmtest.NewScriptedLLM("replay-test-upstream",
+		llmtest.Reply{Match: "web-0", Content: "Root Cause: image not found", Usage: llm.Usage{PromptTokens: 12, CompletionTokens: 5}},
+	)
+	llm.RegisterLLMProvider(upstream)
+	dir := t.TempDir()
+	ctx := WithScenario(context.Background(), "image-pull")
+
+	recorder, err := NewReplayProvider("recorder", &types.LLMProviderConfig{Cassette: dir, Mode: ModeRecord, Upstream: upstream.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := recorder.Chat(ctx, chatRequest("Diagnose pod web-0 at 2026-01-02T10:00:00Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.Message.Content != "Root Cause: image not found" || upstream.Calls() != 1 {
+		t.Fatalf("unexpected recorded answer %+v after %d upstream calls", recorded, upstream.Calls())
+	}
+
+	content, err := os.ReadFile(CassettePath(dir, "image-pull"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(content, &cassette); err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 1 || cassette.Interactions[0].Response.Upstream != upstream.Name() {
+		t.Fatalf("unexpected cassette %+v", cassette)
+	}
+
+	// A fresh provider replays the answer without the upstream provider; the timestamp differs
+	// but is normalized away.
+	player, err := NewReplayProvider("player", &types.LLMProviderConfig{Cassette: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := player.Chat(ctx, chatRequest("Diagnose pod web-0 at 2026-03-04T11:22:33Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Message.Content != recorded.Message.Content || replayed.Usage != recorded.Usage {
+		t.Fatalf("replayed %+v, recorded %+v", replayed, recorded)
+	}
+	if upstream.Calls() != 1 {
+		t.Fatalf("replay must not call the upstream provider, got %d calls", upstream.Calls())
+	}
+
+	if _, err := player.Chat(context.Background(), chatRequest("Diagnose pod db-0")); !errors.IsErrorCode(err, errors.ErrorCodeNotFound) {
+		t.Fatalf("expected a missing interaction in replay mode, got %v", err)
+	}
+}
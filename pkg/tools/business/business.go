@@ -0,0 +1,179 @@
+package business
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/adaptors/businesssdk"
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+	"go.uber.org/zap"
+)
+
+// Package business provides read-only diagnosis tools backed by the Business SDK.
+// 包 business 提供基于业务 SDK 的只读诊断工具。
+
+// ServiceSource provides the discovered Business SDK clients keyed by endpoint URL.
+// ServiceSource 提供已发现的业务 SDK 客户端 (以终点 URL 为键)。
+// It is implemented by the business data collector.
+// 它由业务数据采集器实现。
+type ServiceSource interface {
+	Services() map[string]businesssdk.BusinessAdaptorService
+}
+
+// logQuerier is the only part of BusinessAdaptorService the tool uses.
+// logQuerier 是该工具使用的 BusinessAdaptorService 的唯一部分。
+// Narrowing the interface keeps mutating calls such as ExecuteRunbook out of reach of the LLM.
+// 缩小接口范围，使 ExecuteRunbook 等修改性调用无法被 LLM 触达。
+type logQuerier interface {
+	QueryLogs(ctx context.Context, options map[string]interface{}) ([]businesssdk.LogEntry, error)
+}
+
+const (
+	// defaultLogLimit is the number of log entries returned when the LLM does not ask for a specific amount.
+	// defaultLogLimit 是 LLM 未指定数量时返回的日志条目数。
+	defaultLogLimit = 50
+	// maxLogLimit caps the number of log entries a single call may return.
+	// maxLogLimit 限制单次调用可返回的日志条目数。
+	maxLogLimit = 200
+	// defaultTimeRange is the look-back window used when the LLM does not specify one.
+	// defaultTimeRange 是 LLM 未指定时使用的回溯时间窗口。
+	defaultTimeRange = 30 * time.Minute
+)
+
+// QueryBusinessLogsTool queries business logs through the Business SDK.
+// QueryBusinessLogsTool 通过业务 SDK 查询业务日志。
+type QueryBusinessLogsTool struct {
+	services ServiceSource
+}
+
+// Ensure QueryBusinessLogsTool implements the tool.ScopedTool interface.
+// 确保 QueryBusinessLogsTool 实现了 tool.ScopedTool 接口。
+var _ tool.ScopedTool = &QueryBusinessLogsTool{}
+
+// NewQueryBusinessLogsTool creates a new QueryBusinessLogsTool instance.
+// NewQueryBusinessLogsTool 创建一个新的 QueryBusinessLogsTool 实例。
+func NewQueryBusinessLogsTool(services ServiceSource) *QueryBusinessLogsTool {
+	return &QueryBusinessLogsTool{services: services}
+}
+
+// Name returns the name of the tool.
+// Name 返回工具名称。
+func (t *QueryBusinessLogsTool) Name() string {
+	return constants.ToolQueryBusinessLogs
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (t *QueryBusinessLogsTool) Description() string {
+	return "Queries application (business) logs exposed by business systems through the Business SDK, newest first."
+}
+
+// Parameters returns the arguments accepted by the tool.
+// Parameters 返回工具接受的参数。
+func (t *QueryBusinessLogsTool) Parameters() []tool.Parameter {
+	return []tool.Parameter{
+		{Name: "serviceId", Type: "string", Description: "Identifier of the business service", Required: true},
+		{Name: "keywords", Type: "string", Description: "Keywords the log message must contain"},
+		{Name: "level", Type: "string", Description: "Minimum log level, e.g. WARN or ERROR"},
+		{Name: "timeRange", Type: "string", Description: fmt.Sprintf("Look-back window as a Go duration, e.g. 15m or 2h (default %s)", defaultTimeRange)},
+		{Name: "limit", Type: "integer", Description: fmt.Sprintf("Maximum number of entries (default %d, max %d)", defaultLogLimit, maxLogLimit)},
+	}
+}
+
+// ReadOnly reports that the tool only reads data.
+// ReadOnly 报告该工具只读取数据。
+func (t *QueryBusinessLogsTool) ReadOnly() bool {
+	return true
+}
+
+// Targets returns the business service the logs are queried for.
+// Targets 返回查询日志的业务服务。
+func (t *QueryBusinessLogsTool) Targets(args map[string]interface{}) ([]tool.Target, error) {
+	serviceID, err := tool.RequiredStringArg(args, "serviceId")
+	if err != nil {
+		return nil, err
+	}
+	return []tool.Target{{Service: serviceID}}, nil
+}
+
+// Invoke queries all discovered business endpoints and merges their log entries.
+// Invoke 查询所有已发现的业务终点并合并其日志条目。
+func (t *QueryBusinessLogsTool) Invoke(ctx context.Context, args map[string]interface{}) (string, error) {
+	serviceID, err := tool.RequiredStringArg(args, "serviceId")
+	if err != nil {
+		return "", err
+	}
+	timeRange := defaultTimeRange
+	if raw := tool.StringArg(args, "timeRange"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return "", errors.New(errors.ErrorCodeInvalidInput, "invalid tool argument", fmt.Sprintf("timeRange '%s' is not a valid duration", raw))
+		}
+		timeRange = parsed
+	}
+	limit := tool.IntArg(args, "limit", defaultLogLimit)
+	if limit <= 0 || limit > maxLogLimit {
+		limit = maxLogLimit
+	}
+
+	options := map[string]interface{}{"timeRange": timeRange, "serviceId": serviceID}
+	for _, key := range []string{"keywords", "level"} {
+		if value := tool.StringArg(args, key); value != "" {
+			options[key] = value
+		}
+	}
+
+	services := t.services.Services()
+	if len(services) == 0 {
+		return "", errors.New(errors.ErrorCodeBusinessSDKError, "no business SDK endpoints available", "")
+	}
+
+	var entries []businesssdk.LogEntry
+	var failures []string
+	for url, service := range services {
+		var querier logQuerier = service
+		logs, err := querier.QueryLogs(ctx, options)
+		if err != nil {
+			log.LWithContext(ctx).Warn("Business log query failed", zap.String("endpoint", url), zap.Error(err))
+			failures = append(failures, fmt.Sprintf("%s: %v", url, err))
+			continue
+		}
+		entries = append(entries, logs...)
+	}
+	if len(entries) == 0 && len(failures) == len(services) {
+		return "", errors.New(errors.ErrorCodeBusinessSDKError, "business log query failed on all endpoints", strings.Join(failures, "; "))
+	}
+	if len(entries) == 0 {
+		return "(no log entries found)", nil
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.After(entries[j].Timestamp) })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	var sb strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&sb, "%s %s [%s]", entry.Timestamp.UTC().Format(time.RFC3339), entry.Level, entry.ServiceID)
+		if entry.TraceID != "" {
+			fmt.Fprintf(&sb, " trace=%s", entry.TraceID)
+		}
+		fmt.Fprintf(&sb, " %s\n", strings.TrimSpace(entry.Message))
+	}
+	return sb.String(), nil
+}
+
+// RegisterBusinessTools creates the business tools and registers them with the global tool registry.
+// RegisterBusinessTools 创建业务工具并将其注册到全局工具注册表。
+// This should be called after the business data collector is initialized.
+// 应在业务数据采集器初始化后调用此函数。
+func RegisterBusinessTools(services ServiceSource) {
+	tool.RegisterTool(NewQueryBusinessLogsTool(services))
+	log.L().Debug("Business diagnosis tools registered")
+}
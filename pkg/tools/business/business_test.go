@@ -0,0 +1,143 @@
+package business
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/adaptors/businesssdk"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+)
+
+// fakeService returns fixed log entries, or fails, and records the query options.
+type fakeService struct {
+	businesssdk.BusinessAdaptorService
+	logs    []businesssdk.LogEntry
+	err     error
+	options map[string]interface{}
+}
+
+func (s *fakeService) QueryLogs(ctx context.Context, options map[string]interface{}) ([]businesssdk.LogEntry, error) {
+	s.options = options
+	return s.logs, s.err
+}
+
+type serviceSource map[string]businesssdk.BusinessAdaptorService
+
+func (s serviceSource) Services() map[string]businesssdk.BusinessAdaptorService { return s }
+
+func entries(n int, start time.Time) []businesssdk.LogEntry {
+	logs := make([]businesssdk.LogEntry, n)
+	for i := range logs {
+		logs[i] = businesssdk.LogEntry{Timestamp: start.Add(time.Duration(i) * time.Second), Level: "ERROR", ServiceID: "checkout", Message: fmt.Sprintf("entry %03d", i)}
+	}
+	return logs
+}
+
+func TestQueryBusinessLogs(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	first := &fakeService{logs: []businesssdk.LogEntry{
+		{Timestamp: start, Level: "WARN", ServiceID: "checkout", Message: " slow payment ", TraceID: "abc"},
+	}}
+	second := &fakeService{logs: []businesssdk.LogEntry{
+		{Timestamp: start.Add(time.Minute), Level: "ERROR", ServiceID: "checkout", Message: "payment failed"},
+	}}
+	out, err := NewQueryBusinessLogsTool(serviceSource{"http://a": first, "http://b": second}).Invoke(context.Background(), map[string]interface{}{
+		"serviceId": "checkout", "keywords": "payment", "timeRange": "2h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2026-10-18T12:01:00Z ERROR [checkout] payment failed\n" +
+		"2026-10-18T12:00:00Z WARN [checkout] trace=abc slow payment\n"
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+	if first.options["serviceId"] != "checkout" || first.options["keywords"] != "payment" || first.options["timeRange"] != 2*time.Hour {
+		t.Errorf("unexpected query options %v", first.options)
+	}
+	if _, found := first.options["level"]; found {
+		t.Error("an empty level must not be passed on")
+	}
+}
+
+func TestQueryBusinessLogsLimit(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name  string
+		limit interface{}
+		want  int
+	}{
+		{name: "default", want: defaultLogLimit},
+		{name: "requested", limit: float64(5), want: 5},
+		{name: "above the cap", limit: float64(10000), want: maxLogLimit},
+		{name: "zero", limit: float64(0), want: maxLogLimit},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			args := map[string]interface{}{"serviceId": "checkout"}
+			if tc.limit != nil {
+				args["limit"] = tc.limit
+			}
+			out, err := NewQueryBusinessLogsTool(serviceSource{"http://a": &fakeService{logs: entries(maxLogLimit+50, start)}}).Invoke(context.Background(), args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+			if len(lines) != tc.want {
+				t.Fatalf("got %d entries, want %d", len(lines), tc.want)
+			}
+			if !strings.HasSuffix(lines[0], fmt.Sprintf("entry %03d", maxLogLimit+49)) {
+				t.Errorf("the newest entry must come first, got %q", lines[0])
+			}
+		})
+	}
+}
+
+func TestQueryBusinessLogsErrors(t *testing.T) {
+	failing := &fakeService{err: fmt.Errorf("connection refused")}
+	for _, tc := range []struct {
+		name     string
+		services serviceSource
+		args     map[string]interface{}
+		code     errors.ErrorCode
+		want     string
+	}{
+		{name: "missing service", services: serviceSource{"http://a": &fakeService{}}, args: map[string]interface{}{}, code: errors.ErrorCodeInvalidInput},
+		{name: "invalid time range", services: serviceSource{"http://a": &fakeService{}}, args: map[string]interface{}{"serviceId": "checkout", "timeRange": "yesterday"}, code: errors.ErrorCodeInvalidInput},
+		{name: "negative time range", services: serviceSource{"http://a": &fakeService{}}, args: map[string]interface{}{"serviceId": "checkout", "timeRange": "-1h"}, code: errors.ErrorCodeInvalidInput},
+		{name: "no endpoints", services: serviceSource{}, args: map[string]interface{}{"serviceId": "checkout"}, code: errors.ErrorCodeBusinessSDKError},
+		{name: "every endpoint fails", services: serviceSource{"http://a": failing, "http://b": failing}, args: map[string]interface{}{"serviceId": "checkout"}, code: errors.ErrorCodeBusinessSDKError},
+		{name: "one endpoint fails", services: serviceSource{"http://a": failing, "http://b": &fakeService{}}, args: map[string]interface{}{"serviceId": "checkout"}, want: "(no log entries found)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := NewQueryBusinessLogsTool(tc.services).Invoke(context.Background(), tc.args)
+			if tc.code != "" {
+				if !errors.IsErrorCode(err, tc.code) {
+					t.Errorf("expected error code %s, got %v", tc.code, err)
+				}
+				return
+			}
+			if err != nil || out != tc.want {
+				t.Errorf("got %q, %v, want %q", out, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryBusinessLogsScope(t *testing.T) {
+	scope := tool.NewScope([]types.Issue{{Resource: &types.IssueResource{Type: "BusinessService", Name: "checkout"}}})
+	logs := NewQueryBusinessLogsTool(serviceSource{})
+	if err := scope.Check(logs, map[string]interface{}{"serviceId": "checkout"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := scope.Check(logs, map[string]interface{}{"serviceId": "billing"}); !errors.IsErrorCode(err, errors.ErrorCodePermissionDenied) {
+		t.Errorf("expected permission denied for another service, got %v", err)
+	}
+	if err := scope.Check(logs, map[string]interface{}{}); !errors.IsErrorCode(err, errors.ErrorCodeInvalidInput) {
+		t.Errorf("expected invalid input without a service, got %v", err)
+	}
+}
@@ -0,0 +1,435 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Package k8s provides read-only diagnosis tools for Kubernetes resources.
+// 包 k8s 提供 Kubernetes 资源的只读诊断工具。
+// The tools only issue get, list and log requests against the API server.
+// 这些工具只向 API server 发起 get、list 和日志请求。
+
+// ClientSource provides Kubernetes clients by vcluster name ("host" for the host cluster).
+// ClientSource 按 vcluster 名称提供 Kubernetes 客户端 ("host" 表示宿主机集群)。
+// It is implemented by the Kubernetes data collector.
+// 它由 Kubernetes 数据采集器实现。
+type ClientSource interface {
+	Client(vcluster string) (kubernetes.Interface, bool)
+}
+
+const (
+	// defaultTailLines is the number of log lines returned when the LLM does not ask for a specific amount.
+	// defaultTailLines 是 LLM 未指定数量时返回的日志行数。
+	defaultTailLines = 100
+	// maxTailLines caps the number of log lines a single call may return.
+	// maxTailLines 限制单次调用可返回的日志行数。
+	maxTailLines = 500
+	// maxEvents caps the number of events a single call may return.
+	// maxEvents 限制单次调用可返回的事件数。
+	maxEvents = 30
+)
+
+// vclusterParameter is shared by all Kubernetes tools.
+// vclusterParameter 由所有 Kubernetes 工具共享。
+var vclusterParameter = tool.Parameter{Name: "vcluster", Type: "string", Description: "Name of the vcluster; empty or \"host\" for the host cluster"}
+
+// clientFor resolves the client for the vcluster argument.
+// clientFor 根据 vcluster 参数解析客户端。
+func clientFor(clients ClientSource, args map[string]interface{}) (kubernetes.Interface, error) {
+	vcluster := tool.StringArg(args, "vcluster")
+	client, found := clients.Client(vcluster)
+	if !found {
+		return nil, errors.New(errors.ErrorCodeNotFound, "vcluster client not found", fmt.Sprintf("no client for vcluster '%s'", vcluster))
+	}
+	return client, nil
+}
+
+// GetResourceTool returns the manifest of a Kubernetes resource as YAML.
+// GetResourceTool 以 YAML 形式返回 Kubernetes 资源的清单。
+type GetResourceTool struct {
+	clients ClientSource
+}
+
+// Ensure GetResourceTool implements the tool.ScopedTool interface.
+// 确保 GetResourceTool 实现了 tool.ScopedTool 接口。
+var _ tool.ScopedTool = &GetResourceTool{}
+
+// NewGetResourceTool creates a new GetResourceTool instance.
+// NewGetResourceTool 创建一个新的 GetResourceTool 实例。
+func NewGetResourceTool(clients ClientSource) *GetResourceTool {
+	return &GetResourceTool{clients: clients}
+}
+
+// Name returns the name of the tool.
+// Name 返回工具名称。
+func (t *GetResourceTool) Name() string {
+	return constants.ToolGetResource
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (t *GetResourceTool) Description() string {
+	return "Returns the YAML manifest and status of a Kubernetes resource. Supported kinds: " + strings.Join(supportedKinds(), ", ") + "."
+}
+
+// Parameters returns the arguments accepted by the tool.
+// Parameters 返回工具接受的参数。
+func (t *GetResourceTool) Parameters() []tool.Parameter {
+	return []tool.Parameter{
+		vclusterParameter,
+		{Name: "kind", Type: "string", Description: "Resource kind, e.g. Pod, Deployment, Node", Required: true},
+		{Name: "namespace", Type: "string", Description: "Namespace of the resource; empty for cluster-scoped kinds"},
+		{Name: "name", Type: "string", Description: "Name of the resource", Required: true},
+	}
+}
+
+// ReadOnly reports that the tool only reads data.
+// ReadOnly 报告该工具只读取数据。
+func (t *GetResourceTool) ReadOnly() bool {
+	return true
+}
+
+// Targets returns the vcluster and namespace the resource is read from.
+// Targets 返回读取资源所在的 vcluster 和命名空间。
+func (t *GetResourceTool) Targets(args map[string]interface{}) ([]tool.Target, error) {
+	getter := resourceGetters[strings.ToLower(tool.StringArg(args, "kind"))]
+	return []tool.Target{{
+		VCluster:      tool.StringArg(args, "vcluster"),
+		Namespace:     tool.StringArg(args, "namespace"),
+		ClusterScoped: getter.clusterScoped,
+	}}, nil
+}
+
+// Invoke fetches the resource and renders it as YAML.
+// Invoke 获取资源并将其渲染为 YAML。
+func (t *GetResourceTool) Invoke(ctx context.Context, args map[string]interface{}) (string, error) {
+	kind, err := tool.RequiredStringArg(args, "kind")
+	if err != nil {
+		return "", err
+	}
+	name, err := tool.RequiredStringArg(args, "name")
+	if err != nil {
+		return "", err
+	}
+	namespace := tool.StringArg(args, "namespace")
+	client, err := clientFor(t.clients, args)
+	if err != nil {
+		return "", err
+	}
+
+	getter, found := resourceGetters[strings.ToLower(kind)]
+	if !found {
+		// Secrets are deliberately not supported so that credentials never reach the LLM.
+		// 刻意不支持 Secret，以确保凭据永远不会发送给 LLM。
+		return "", errors.New(errors.ErrorCodeInvalidInput, "unsupported resource kind", fmt.Sprintf("kind '%s' is not supported; supported kinds: %s", kind, strings.Join(supportedKinds(), ", ")))
+	}
+	obj, err := getter.get(ctx, client, namespace, name)
+	if err != nil {
+		return "", errors.Wrap(errors.ErrorCodeKubernetesConnectionFailed, "failed to get resource", err, fmt.Sprintf("%s %s/%s", kind, namespace, name))
+	}
+	return toYAML(getter.kind, obj)
+}
+
+// resourceGetter fetches one kind of resource.
+// resourceGetter 获取一种资源。
+type resourceGetter struct {
+	kind          string
+	clusterScoped bool
+	get           func(ctx context.Context, client kubernetes.Interface, namespace, name string) (interface{}, error)
+}
+
+// resourceGetters maps lower-cased kinds to getters. Only get requests are issued.
+// resourceGetters 将小写的资源类型映射到获取函数。只发起 get 请求。
+var resourceGetters = map[string]resourceGetter{
+	"pod": {kind: "Pod", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+	"node": {kind: "Node", clusterScoped: true, get: func(ctx context.Context, c kubernetes.Interface, _, name string) (interface{}, error) {
+		return c.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	}},
+	"service": {kind: "Service", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+	"configmap": {kind: "ConfigMap", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.CoreV1().ConfigMaps(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+	"persistentvolumeclaim": {kind: "PersistentVolumeClaim", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.CoreV1().PersistentVolumeClaims(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+	"persistentvolume": {kind: "PersistentVolume", clusterScoped: true, get: func(ctx context.Context, c kubernetes.Interface, _, name string) (interface{}, error) {
+		return c.CoreV1().PersistentVolumes().Get(ctx, name, metav1.GetOptions{})
+	}},
+	"deployment": {kind: "Deployment", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+	"statefulset": {kind: "StatefulSet", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+	"daemonset": {kind: "DaemonSet", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.AppsV1().DaemonSets(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+	"replicaset": {kind: "ReplicaSet", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.AppsV1().ReplicaSets(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+	"job": {kind: "Job", get: func(ctx context.Context, c kubernetes.Interface, ns, name string) (interface{}, error) {
+		return c.BatchV1().Jobs(ns).Get(ctx, name, metav1.GetOptions{})
+	}},
+}
+
+// supportedKinds returns the supported kinds in alphabetical order.
+// supportedKinds 按字母顺序返回支持的资源类型。
+func supportedKinds() []string {
+	kinds := make([]string, 0, len(resourceGetters))
+	for _, getter := range resourceGetters {
+		kinds = append(kinds, getter.kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// toYAML renders an API object as YAML, honouring its JSON field names and dropping noisy metadata.
+// toYAML 将 API 对象渲染为 YAML，使用其 JSON 字段名并去除冗余元数据。
+func toYAML(kind string, obj interface{}) (string, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return "", errors.Wrap(errors.ErrorCodeUnknown, "failed to encode resource", err, kind)
+	}
+	var manifest map[string]interface{}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return "", errors.Wrap(errors.ErrorCodeUnknown, "failed to encode resource", err, kind)
+	}
+	manifest["kind"] = kind
+	if metadata, ok := manifest["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "managedFields")
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, corev1.LastAppliedConfigAnnotation)
+		}
+	}
+	out, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", errors.Wrap(errors.ErrorCodeUnknown, "failed to encode resource", err, kind)
+	}
+	return string(out), nil
+}
+
+// GetPodLogsTool returns the logs of a pod's container.
+// GetPodLogsTool 返回 Pod 容器的日志。
+type GetPodLogsTool struct {
+	clients ClientSource
+}
+
+// Ensure GetPodLogsTool implements the tool.ScopedTool interface.
+// 确保 GetPodLogsTool 实现了 tool.ScopedTool 接口。
+var _ tool.ScopedTool = &GetPodLogsTool{}
+
+// NewGetPodLogsTool creates a new GetPodLogsTool instance.
+// NewGetPodLogsTool 创建一个新的 GetPodLogsTool 实例。
+func NewGetPodLogsTool(clients ClientSource) *GetPodLogsTool {
+	return &GetPodLogsTool{clients: clients}
+}
+
+// Name returns the name of the tool.
+// Name 返回工具名称。
+func (t *GetPodLogsTool) Name() string {
+	return constants.ToolGetPodLogs
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (t *GetPodLogsTool) Description() string {
+	return "Returns the last lines of a pod container's logs. Set previous=true for the logs of the previous (crashed) container instance."
+}
+
+// Parameters returns the arguments accepted by the tool.
+// Parameters 返回工具接受的参数。
+func (t *GetPodLogsTool) Parameters() []tool.Parameter {
+	return []tool.Parameter{
+		vclusterParameter,
+		{Name: "namespace", Type: "string", Description: "Namespace of the pod", Required: true},
+		{Name: "pod", Type: "string", Description: "Name of the pod", Required: true},
+		{Name: "container", Type: "string", Description: "Container name; required if the pod has more than one container"},
+		{Name: "previous", Type: "boolean", Description: "Return logs of the previous container instance"},
+		{Name: "tailLines", Type: "integer", Description: fmt.Sprintf("Number of lines from the end of the log (default %d, max %d)", defaultTailLines, maxTailLines)},
+	}
+}
+
+// ReadOnly reports that the tool only reads data.
+// ReadOnly 报告该工具只读取数据。
+func (t *GetPodLogsTool) ReadOnly() bool {
+	return true
+}
+
+// Targets returns the vcluster and namespace of the pod.
+// Targets 返回 Pod 所在的 vcluster 和命名空间。
+func (t *GetPodLogsTool) Targets(args map[string]interface{}) ([]tool.Target, error) {
+	return []tool.Target{{VCluster: tool.StringArg(args, "vcluster"), Namespace: tool.StringArg(args, "namespace")}}, nil
+}
+
+// Invoke fetches the container logs.
+// Invoke 获取容器日志。
+func (t *GetPodLogsTool) Invoke(ctx context.Context, args map[string]interface{}) (string, error) {
+	namespace, err := tool.RequiredStringArg(args, "namespace")
+	if err != nil {
+		return "", err
+	}
+	pod, err := tool.RequiredStringArg(args, "pod")
+	if err != nil {
+		return "", err
+	}
+	client, err := clientFor(t.clients, args)
+	if err != nil {
+		return "", err
+	}
+
+	tailLines := int64(tool.IntArg(args, "tailLines", defaultTailLines))
+	if tailLines <= 0 || tailLines > maxTailLines {
+		tailLines = maxTailLines
+	}
+	opts := &corev1.PodLogOptions{
+		Container: tool.StringArg(args, "container"),
+		Previous:  tool.BoolArg(args, "previous"),
+		TailLines: &tailLines,
+	}
+	raw, err := client.CoreV1().Pods(namespace).GetLogs(pod, opts).DoRaw(ctx)
+	if err != nil {
+		return "", errors.Wrap(errors.ErrorCodeKubernetesConnectionFailed, "failed to get pod logs", err, fmt.Sprintf("%s/%s", namespace, pod))
+	}
+	if len(raw) == 0 {
+		return "(no log output)", nil
+	}
+	return string(raw), nil
+}
+
+// ListEventsTool lists Kubernetes events, optionally for a single object.
+// ListEventsTool 列出 Kubernetes 事件，可选仅列出单个对象的事件。
+type ListEventsTool struct {
+	clients ClientSource
+}
+
+// Ensure ListEventsTool implements the tool.ScopedTool interface.
+// 确保 ListEventsTool 实现了 tool.ScopedTool 接口。
+var _ tool.ScopedTool = &ListEventsTool{}
+
+// NewListEventsTool creates a new ListEventsTool instance.
+// NewListEventsTool 创建一个新的 ListEventsTool 实例。
+func NewListEventsTool(clients ClientSource) *ListEventsTool {
+	return &ListEventsTool{clients: clients}
+}
+
+// Name returns the name of the tool.
+// Name 返回工具名称。
+func (t *ListEventsTool) Name() string {
+	return constants.ToolListEvents
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (t *ListEventsTool) Description() string {
+	return fmt.Sprintf("Lists the most recent Kubernetes events (at most %d) in a namespace, optionally only those about one object.", maxEvents)
+}
+
+// Parameters returns the arguments accepted by the tool.
+// Parameters 返回工具接受的参数。
+func (t *ListEventsTool) Parameters() []tool.Parameter {
+	return []tool.Parameter{
+		vclusterParameter,
+		{Name: "namespace", Type: "string", Description: "Namespace to list events in", Required: true},
+		{Name: "kind", Type: "string", Description: "Kind of the involved object, e.g. Pod"},
+		{Name: "name", Type: "string", Description: "Name of the involved object"},
+	}
+}
+
+// ReadOnly reports that the tool only reads data.
+// ReadOnly 报告该工具只读取数据。
+func (t *ListEventsTool) ReadOnly() bool {
+	return true
+}
+
+// Targets returns the vcluster and namespace the events are listed in.
+// Targets 返回列出事件所在的 vcluster 和命名空间。
+func (t *ListEventsTool) Targets(args map[string]interface{}) ([]tool.Target, error) {
+	return []tool.Target{{VCluster: tool.StringArg(args, "vcluster"), Namespace: tool.StringArg(args, "namespace")}}, nil
+}
+
+// Invoke lists the events, newest first.
+// Invoke 列出事件 (最新的在前)。
+func (t *ListEventsTool) Invoke(ctx context.Context, args map[string]interface{}) (string, error) {
+	namespace, err := tool.RequiredStringArg(args, "namespace")
+	if err != nil {
+		return "", err
+	}
+	client, err := clientFor(t.clients, args)
+	if err != nil {
+		return "", err
+	}
+
+	selector := fields.Set{}
+	if kind := tool.StringArg(args, "kind"); kind != "" {
+		selector["involvedObject.kind"] = kind
+	}
+	if name := tool.StringArg(args, "name"); name != "" {
+		selector["involvedObject.name"] = name
+	}
+	list, err := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector.AsSelector().String()})
+	if err != nil {
+		return "", errors.Wrap(errors.ErrorCodeKubernetesConnectionFailed, "failed to list events", err, namespace)
+	}
+	if len(list.Items) == 0 {
+		return "(no events found)", nil
+	}
+
+	events := list.Items
+	sort.Slice(events, func(i, j int) bool {
+		return eventTime(events[i]).After(eventTime(events[j]).Time)
+	})
+	if len(events) > maxEvents {
+		events = events[:maxEvents]
+	}
+
+	var sb strings.Builder
+	for _, ev := range events {
+		fmt.Fprintf(&sb, "%s %s %s %s %s/%s: %s (x%d)\n",
+			eventTime(ev).UTC().Format("2006-01-02T15:04:05Z"), ev.Type, ev.Reason,
+			ev.InvolvedObject.Kind, ev.InvolvedObject.Namespace, ev.InvolvedObject.Name,
+			strings.TrimSpace(ev.Message), ev.Count)
+	}
+	return sb.String(), nil
+}
+
+// eventTime returns the most meaningful timestamp of an event.
+// eventTime 返回事件最有意义的时间戳。
+func eventTime(ev corev1.Event) metav1.Time {
+	switch {
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp
+	case !ev.EventTime.IsZero():
+		return metav1.NewTime(ev.EventTime.Time)
+	default:
+		return ev.CreationTimestamp
+	}
+}
+
+// RegisterK8sTools creates the Kubernetes tools and registers them with the global tool registry.
+// RegisterK8sTools 创建 Kubernetes 工具并将其注册到全局工具注册表。
+// This should be called after the Kubernetes data collector is initialized.
+// 应在 Kubernetes 数据采集器初始化后调用此函数。
+func RegisterK8sTools(clients ClientSource) {
+	tool.RegisterTool(NewGetResourceTool(clients))
+	tool.RegisterTool(NewGetPodLogsTool(clients))
+	tool.RegisterTool(NewListEventsTool(clients))
+	log.L().Debug("Kubernetes diagnosis tools registered")
+}
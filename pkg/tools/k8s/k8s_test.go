@@ -0,0 +1,244 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// clientSource maps vcluster names to clients; an empty name is the host cluster.
+type clientSource map[string]kubernetes.Interface
+
+func (s clientSource) Client(vcluster string) (kubernetes.Interface, bool) {
+	if vcluster == "" {
+		vcluster = "host"
+	}
+	client, found := s[vcluster]
+	return client, found
+}
+
+func newClient(objects ...runtime.Object) (*fake.Clientset, clientSource) {
+	client := fake.NewSimpleClientset(objects...)
+	return client, clientSource{"host": client, "team-a": client}
+}
+
+func TestGetResource(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:          "web-0",
+		Namespace:     "web",
+		Annotations:   map[string]string{corev1.LastAppliedConfigAnnotation: "{}", "team": "checkout"},
+		ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+	}}
+	_, clients := newClient(pod)
+
+	out, err := NewGetResourceTool(clients).Invoke(context.Background(), map[string]interface{}{"kind": "pod", "namespace": "web", "name": "web-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"kind: Pod", "name: web-0", "team: checkout"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("manifest does not contain %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"managedFields", "kubectl", corev1.LastAppliedConfigAnnotation} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("manifest contains %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestGetResourceErrors(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "web"}, StringData: map[string]string{"password": "hunter2"}}
+	for _, tc := range []struct {
+		name string
+		args map[string]interface{}
+		code errors.ErrorCode
+	}{
+		{name: "secret", args: map[string]interface{}{"kind": "Secret", "namespace": "web", "name": "db"}, code: errors.ErrorCodeInvalidInput},
+		{name: "secret in lower case", args: map[string]interface{}{"kind": "secrets", "namespace": "web", "name": "db"}, code: errors.ErrorCodeInvalidInput},
+		{name: "missing kind", args: map[string]interface{}{"namespace": "web", "name": "db"}, code: errors.ErrorCodeInvalidInput},
+		{name: "missing name", args: map[string]interface{}{"kind": "Pod", "namespace": "web"}, code: errors.ErrorCodeInvalidInput},
+		{name: "unknown vcluster", args: map[string]interface{}{"vcluster": "team-b", "kind": "Pod", "namespace": "web", "name": "web-0"}, code: errors.ErrorCodeNotFound},
+		{name: "missing resource", args: map[string]interface{}{"kind": "Pod", "namespace": "web", "name": "web-1"}, code: errors.ErrorCodeKubernetesConnectionFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, clients := newClient(secret)
+			out, err := NewGetResourceTool(clients).Invoke(context.Background(), tc.args)
+			if !errors.IsErrorCode(err, tc.code) {
+				t.Fatalf("expected error code %s, got %v", tc.code, err)
+			}
+			if strings.Contains(out, "hunter2") {
+				t.Errorf("secret data reached the result: %q", out)
+			}
+			for _, action := range client.Actions() {
+				if action.GetResource().Resource == "secrets" {
+					t.Errorf("the tool read a secret: %v", action)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPodLogsTailLines(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		tailLines interface{}
+		want      int64
+	}{
+		{name: "default", want: defaultTailLines},
+		{name: "requested", tailLines: float64(20), want: 20},
+		{name: "quoted", tailLines: "40", want: 40},
+		{name: "at the cap", tailLines: float64(maxTailLines), want: maxTailLines},
+		{name: "above the cap", tailLines: float64(100000), want: maxTailLines},
+		{name: "zero", tailLines: float64(0), want: maxTailLines},
+		{name: "negative", tailLines: float64(-1), want: maxTailLines},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, clients := newClient()
+			args := map[string]interface{}{"namespace": "web", "pod": "web-0", "container": "app", "previous": true}
+			if tc.tailLines != nil {
+				args["tailLines"] = tc.tailLines
+			}
+			out, err := NewGetPodLogsTool(clients).Invoke(context.Background(), args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != "fake logs" {
+				t.Errorf("logs = %q", out)
+			}
+
+			actions := client.Actions()
+			if len(actions) != 1 || actions[0].GetSubresource() != "log" || actions[0].GetNamespace() != "web" {
+				t.Fatalf("unexpected actions %v", actions)
+			}
+			opts := actions[0].(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+			if opts.TailLines == nil || *opts.TailLines != tc.want {
+				t.Errorf("tailLines = %v, want %d", opts.TailLines, tc.want)
+			}
+			if opts.Container != "app" || !opts.Previous {
+				t.Errorf("unexpected log options %+v", opts)
+			}
+		})
+	}
+}
+
+func TestGetPodLogsErrors(t *testing.T) {
+	_, clients := newClient()
+	for _, args := range []map[string]interface{}{
+		{"pod": "web-0"},
+		{"namespace": "web"},
+	} {
+		if _, err := NewGetPodLogsTool(clients).Invoke(context.Background(), args); !errors.IsErrorCode(err, errors.ErrorCodeInvalidInput) {
+			t.Errorf("%v: expected invalid input, got %v", args, err)
+		}
+	}
+	if _, err := NewGetPodLogsTool(clients).Invoke(context.Background(), map[string]interface{}{"vcluster": "team-b", "namespace": "web", "pod": "web-0"}); !errors.IsErrorCode(err, errors.ErrorCodeNotFound) {
+		t.Errorf("expected not found for an unknown vcluster, got %v", err)
+	}
+}
+
+func TestListEvents(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	var objects []runtime.Object
+	for i := 0; i < maxEvents+10; i++ {
+		objects = append(objects, &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: fmt.Sprintf("event-%02d", i), Namespace: "web"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "web", Name: "web-0"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "BackOff",
+			Message:        fmt.Sprintf("event %02d", i),
+			Count:          int32(i),
+			LastTimestamp:  metav1.NewTime(start.Add(time.Duration(i) * time.Minute)),
+		})
+	}
+	objects = append(objects, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "other-namespace", Namespace: "db"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "db", Name: "db-0"},
+		Message:        "other namespace",
+		LastTimestamp:  metav1.NewTime(start.Add(24 * time.Hour)),
+	})
+	client, clients := newClient(objects...)
+
+	out, err := NewListEventsTool(clients).Invoke(context.Background(), map[string]interface{}{"namespace": "web", "kind": "Pod", "name": "web-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != maxEvents {
+		t.Fatalf("got %d events, want %d:\n%s", len(lines), maxEvents, out)
+	}
+	newest := fmt.Sprintf("event %02d", maxEvents+9)
+	if !strings.HasPrefix(lines[0], "2026-10-18T12:39:00Z Warning BackOff Pod web/web-0: "+newest+" (x39)") {
+		t.Errorf("the newest event must come first, got %q", lines[0])
+	}
+	if !strings.Contains(lines[maxEvents-1], "event 10") {
+		t.Errorf("the oldest events must be dropped, got %q last", lines[maxEvents-1])
+	}
+	if strings.Contains(out, "other namespace") {
+		t.Error("events of another namespace were listed")
+	}
+
+	list := client.Actions()[0].(k8stesting.ListAction)
+	selector := list.GetListRestrictions().Fields.String()
+	if !strings.Contains(selector, "involvedObject.kind=Pod") || !strings.Contains(selector, "involvedObject.name=web-0") {
+		t.Errorf("field selector = %q", selector)
+	}
+}
+
+func TestListEventsEmpty(t *testing.T) {
+	_, clients := newClient()
+	out, err := NewListEventsTool(clients).Invoke(context.Background(), map[string]interface{}{"namespace": "web"})
+	if err != nil || out != "(no events found)" {
+		t.Errorf("got %q, %v", out, err)
+	}
+	if _, err := NewListEventsTool(clients).Invoke(context.Background(), map[string]interface{}{}); !errors.IsErrorCode(err, errors.ErrorCodeInvalidInput) {
+		t.Errorf("expected invalid input without a namespace, got %v", err)
+	}
+}
+
+func TestToolScope(t *testing.T) {
+	_, clients := newClient()
+	scope := tool.NewScope([]types.Issue{
+		{Resource: &types.IssueResource{Type: "Pod", VCluster: "team-a", Namespace: "web", Name: "web-0"}},
+	})
+	for _, tc := range []struct {
+		name    string
+		tool    tool.Tool
+		args    map[string]interface{}
+		allowed bool
+	}{
+		{name: "pod in the incident", tool: NewGetResourceTool(clients), args: map[string]interface{}{"vcluster": "team-a", "kind": "Pod", "namespace": "web", "name": "web-0"}, allowed: true},
+		{name: "pod in another namespace", tool: NewGetResourceTool(clients), args: map[string]interface{}{"vcluster": "team-a", "kind": "Pod", "namespace": "db", "name": "db-0"}},
+		{name: "pod in another vcluster", tool: NewGetResourceTool(clients), args: map[string]interface{}{"vcluster": "team-b", "kind": "Pod", "namespace": "web", "name": "web-0"}},
+		{name: "pod in the host cluster", tool: NewGetResourceTool(clients), args: map[string]interface{}{"kind": "Pod", "namespace": "web", "name": "web-0"}},
+		{name: "node of the incident's vcluster", tool: NewGetResourceTool(clients), args: map[string]interface{}{"vcluster": "team-a", "kind": "Node", "name": "node-1"}, allowed: true},
+		{name: "node of the host cluster", tool: NewGetResourceTool(clients), args: map[string]interface{}{"kind": "Node", "name": "node-1"}},
+		{name: "logs in the incident", tool: NewGetPodLogsTool(clients), args: map[string]interface{}{"vcluster": "team-a", "namespace": "web", "pod": "web-0"}, allowed: true},
+		{name: "logs in another namespace", tool: NewGetPodLogsTool(clients), args: map[string]interface{}{"vcluster": "team-a", "namespace": "kube-system", "pod": "coredns-0"}},
+		{name: "events in the incident", tool: NewListEventsTool(clients), args: map[string]interface{}{"vcluster": "team-a", "namespace": "web"}, allowed: true},
+		{name: "events in another vcluster", tool: NewListEventsTool(clients), args: map[string]interface{}{"vcluster": "team-b", "namespace": "web"}},
+		{name: "events of every namespace", tool: NewListEventsTool(clients), args: map[string]interface{}{"vcluster": "team-a"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := scope.Check(tc.tool, tc.args)
+			if tc.allowed && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if !tc.allowed && !errors.IsErrorCode(err, errors.ErrorCodePermissionDenied) {
+				t.Errorf("expected permission denied, got %v", err)
+			}
+		})
+	}
+}
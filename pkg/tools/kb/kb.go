@@ -0,0 +1,121 @@
+package kb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/constants"
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/log"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+)
+
+// Package kb provides a diagnosis tool for searching the SRE knowledge base.
+// 包 kb 提供用于搜索 SRE 知识库的诊断工具。
+
+const (
+	// defaultHits is the number of hits returned when the LLM does not ask for a specific amount.
+	// defaultHits 是 LLM 未指定数量时返回的命中数。
+	defaultHits = 3
+	// maxHits caps the number of hits a single call may return.
+	// maxHits 限制单次调用可返回的命中数。
+	maxHits = 10
+)
+
+// SearchKnowledgeBaseTool searches the knowledge base for runbooks and past incidents.
+// SearchKnowledgeBaseTool 在知识库中搜索 Runbook 和历史事件。
+type SearchKnowledgeBaseTool struct {
+	kb knowledgebase.KnowledgeBase
+}
+
+// Ensure SearchKnowledgeBaseTool implements the tool.ScopedTool interface.
+// 确保 SearchKnowledgeBaseTool 实现了 tool.ScopedTool 接口。
+var _ tool.ScopedTool = &SearchKnowledgeBaseTool{}
+
+// NewSearchKnowledgeBaseTool creates a new SearchKnowledgeBaseTool instance.
+// NewSearchKnowledgeBaseTool 创建一个新的 SearchKnowledgeBaseTool 实例。
+func NewSearchKnowledgeBaseTool(kb knowledgebase.KnowledgeBase) *SearchKnowledgeBaseTool {
+	return &SearchKnowledgeBaseTool{kb: kb}
+}
+
+// Name returns the name of the tool.
+// Name 返回工具名称。
+func (t *SearchKnowledgeBaseTool) Name() string {
+	return constants.ToolSearchKnowledgeBase
+}
+
+// Description returns a brief description.
+// Description 返回简要描述。
+func (t *SearchKnowledgeBaseTool) Description() string {
+	return "Searches the SRE knowledge base (runbooks, documentation, past incidents) with a free-text query."
+}
+
+// Parameters returns the arguments accepted by the tool.
+// Parameters 返回工具接受的参数。
+func (t *SearchKnowledgeBaseTool) Parameters() []tool.Parameter {
+	return []tool.Parameter{
+		{Name: "query", Type: "string", Description: "What to search for, e.g. an error message", Required: true},
+		{Name: "k", Type: "integer", Description: fmt.Sprintf("Number of results (default %d, max %d)", defaultHits, maxHits)},
+	}
+}
+
+// ReadOnly reports that the tool only reads data.
+// ReadOnly 报告该工具只读取数据。
+func (t *SearchKnowledgeBaseTool) ReadOnly() bool {
+	return true
+}
+
+// Targets returns no targets: the query selects no tenant, and Invoke drops the hits of tenants
+// outside the scope of the incident.
+// Targets 不返回目标: 查询不选择租户，Invoke 会丢弃事件范围以外租户的命中。
+func (t *SearchKnowledgeBaseTool) Targets(args map[string]interface{}) ([]tool.Target, error) {
+	return nil, nil
+}
+
+// Invoke retrieves the best matching knowledge base entries of the incident's scope.
+// Invoke 检索事件范围内最匹配的知识库条目。
+func (t *SearchKnowledgeBaseTool) Invoke(ctx context.Context, args map[string]interface{}) (string, error) {
+	query, err := tool.RequiredStringArg(args, "query")
+	if err != nil {
+		return "", err
+	}
+	k := tool.IntArg(args, "k", defaultHits)
+	if k <= 0 || k > maxHits {
+		k = maxHits
+	}
+
+	// Hits of other tenants are dropped after the search, so up to maxHits candidates are retrieved.
+	// 其他租户的命中在搜索后被丢弃，因此最多检索 maxHits 个候选。
+	candidates, err := t.kb.Retrieve(ctx, query, map[string]interface{}{knowledgebase.OptionK: maxHits})
+	if err != nil {
+		return "", errors.Wrap(errors.ErrorCodeKnowledgeBaseError, "knowledge base search failed", err, query)
+	}
+	scope := tool.ScopeFrom(ctx)
+	var hits []types.KnowledgeBaseHit
+	for _, hit := range candidates {
+		if len(hits) < k && scope.AllowsMetadata(hit.Metadata[knowledgebase.MetadataVCluster], hit.Metadata[knowledgebase.MetadataBusinessService]) {
+			hits = append(hits, hit)
+		}
+	}
+	if len(hits) == 0 {
+		return "(no matching knowledge found)", nil
+	}
+
+	var sb strings.Builder
+	for i, hit := range hits {
+		fmt.Fprintf(&sb, "[%d] Source: %s (Score: %.2f)\n%s\n\n", i+1, hit.Source, hit.Score, strings.TrimSpace(hit.Content))
+	}
+	return sb.String(), nil
+}
+
+// RegisterKnowledgeBaseTools creates the knowledge base tools and registers them with the global tool registry.
+// RegisterKnowledgeBaseTools 创建知识库工具并将其注册到全局工具注册表。
+// This should be called after the knowledge base is initialized.
+// 应在知识库初始化后调用此函数。
+func RegisterKnowledgeBaseTools(kb knowledgebase.KnowledgeBase) {
+	tool.RegisterTool(NewSearchKnowledgeBaseTool(kb))
+	log.L().Debug("Knowledge base diagnosis tools registered")
+}
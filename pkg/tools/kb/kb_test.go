@@ -0,0 +1,129 @@
+package kb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/turtacn/chasi-sreagent/pkg/common/errors"
+	"github.com/turtacn/chasi-sreagent/pkg/common/types"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/knowledgebase"
+	"github.com/turtacn/chasi-sreagent/pkg/framework/tool"
+)
+
+// fakeKnowledgeBase returns fixed hits, or fails, and records the retrieval options.
+type fakeKnowledgeBase struct {
+	hits    []types.KnowledgeBaseHit
+	err     error
+	options map[string]interface{}
+}
+
+func (kb *fakeKnowledgeBase) Name() string        { return "fake" }
+func (kb *fakeKnowledgeBase) Description() string { return "" }
+func (kb *fakeKnowledgeBase) Store(context.Context, []types.KnowledgeDocument) error {
+	return nil
+}
+func (kb *fakeKnowledgeBase) Delete(context.Context, []string) error { return nil }
+func (kb *fakeKnowledgeBase) Retrieve(ctx context.Context, query string, options map[string]interface{}) ([]types.KnowledgeBaseHit, error) {
+	kb.options = options
+	return kb.hits, kb.err
+}
+
+func hit(source, vcluster, service string) types.KnowledgeBaseHit {
+	return types.KnowledgeBaseHit{
+		Source:   source,
+		Content:  " content of " + source + " ",
+		Score:    0.5,
+		Metadata: map[string]string{knowledgebase.MetadataVCluster: vcluster, knowledgebase.MetadataBusinessService: service},
+	}
+}
+
+func scopedContext() context.Context {
+	return tool.WithScope(context.Background(), tool.NewScope([]types.Issue{
+		{Resource: &types.IssueResource{Type: "Pod", VCluster: "team-a", Namespace: "web", Name: "web-0"}},
+		{Resource: &types.IssueResource{Type: "BusinessService", Name: "checkout"}},
+	}))
+}
+
+func TestSearchKnowledgeBaseScope(t *testing.T) {
+	kb := &fakeKnowledgeBase{hits: []types.KnowledgeBaseHit{
+		hit("shared.md", "", ""),
+		hit("team-b.md", "team-b", ""),
+		hit("team-a.md", "team-a", ""),
+		hit("billing.md", "", "billing"),
+		hit("checkout.md", "", "checkout"),
+	}}
+	out, err := NewSearchKnowledgeBaseTool(kb).Invoke(scopedContext(), map[string]interface{}{"query": "oom", "k": float64(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[1] Source: shared.md (Score: 0.50)\ncontent of shared.md\n\n" +
+		"[2] Source: team-a.md (Score: 0.50)\ncontent of team-a.md\n\n" +
+		"[3] Source: checkout.md (Score: 0.50)\ncontent of checkout.md\n\n"
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+	if kb.options[knowledgebase.OptionK] != maxHits {
+		t.Errorf("retrieved k = %v, want %d candidates", kb.options[knowledgebase.OptionK], maxHits)
+	}
+	if targets, err := NewSearchKnowledgeBaseTool(kb).Targets(nil); err != nil || len(targets) != 0 {
+		t.Errorf("the search selects no tenant, got %v, %v", targets, err)
+	}
+}
+
+func TestSearchKnowledgeBaseWithoutScope(t *testing.T) {
+	kb := &fakeKnowledgeBase{hits: []types.KnowledgeBaseHit{hit("shared.md", "", ""), hit("team-a.md", "team-a", "")}}
+	out, err := NewSearchKnowledgeBaseTool(kb).Invoke(context.Background(), map[string]interface{}{"query": "oom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "shared.md") || strings.Contains(out, "team-a.md") {
+		t.Errorf("a context without a scope must only show shared documents, got\n%s", out)
+	}
+}
+
+func TestSearchKnowledgeBaseK(t *testing.T) {
+	var hits []types.KnowledgeBaseHit
+	for i := 0; i < maxHits; i++ {
+		hits = append(hits, hit(fmt.Sprintf("doc-%d.md", i), "", ""))
+	}
+	for _, tc := range []struct {
+		name string
+		k    interface{}
+		want int
+	}{
+		{name: "default", want: defaultHits},
+		{name: "requested", k: float64(5), want: 5},
+		{name: "above the cap", k: float64(100), want: maxHits},
+		{name: "zero", k: float64(0), want: maxHits},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			args := map[string]interface{}{"query": "oom"}
+			if tc.k != nil {
+				args["k"] = tc.k
+			}
+			out, err := NewSearchKnowledgeBaseTool(&fakeKnowledgeBase{hits: hits}).Invoke(scopedContext(), args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Count(out, "Source: "); got != tc.want {
+				t.Errorf("got %d hits, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchKnowledgeBaseErrors(t *testing.T) {
+	if _, err := NewSearchKnowledgeBaseTool(&fakeKnowledgeBase{}).Invoke(scopedContext(), map[string]interface{}{}); !errors.IsErrorCode(err, errors.ErrorCodeInvalidInput) {
+		t.Errorf("expected invalid input without a query, got %v", err)
+	}
+	failing := &fakeKnowledgeBase{err: fmt.Errorf("index unavailable")}
+	if _, err := NewSearchKnowledgeBaseTool(failing).Invoke(scopedContext(), map[string]interface{}{"query": "oom"}); !errors.IsErrorCode(err, errors.ErrorCodeKnowledgeBaseError) {
+		t.Errorf("expected a knowledge base error, got %v", err)
+	}
+	out, err := NewSearchKnowledgeBaseTool(&fakeKnowledgeBase{}).Invoke(scopedContext(), map[string]interface{}{"query": "oom"})
+	if err != nil || out != "(no matching knowledge found)" {
+		t.Errorf("got %q, %v", out, err)
+	}
+}